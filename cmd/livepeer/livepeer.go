@@ -5,6 +5,7 @@ package main
 
 import (
 	"context"
+	"encoding/base64"
 	"errors"
 	"flag"
 	"fmt"
@@ -81,6 +82,7 @@ var defaultCapabilities = []core.Capability{
 	core.Capability_ProfileH264High,
 	core.Capability_ProfileH264ConstrainedHigh,
 	core.Capability_GOP,
+	core.Capability_StorageAzure,
 }
 
 // Add to this list as certain features become mandatory. Orchestrator only
@@ -111,6 +113,8 @@ func main() {
 
 	verifierPath := flag.String("verifierPath", "", "Path to verifier shared volume")
 	localVerify := flag.Bool("localVerify", true, "Set to true to enable local verification i.e. pixel count and signature verification.")
+	verificationSampleRate := flag.Float64("verificationSampleRate", 0, "Share of the segments (0-1) transcoded again by another orchestrator to check the outputs of the first one match. Each checked segment is paid for twice")
+	verificationTolerance := flag.Float64("verificationTolerance", 0.1, "Max difference (0-1) of the encoded sizes of the renditions of the checked segment between the orchestrators, relative to the larger one, for the check to pass. Decoded frame and pixel counts must be equal")
 	httpIngest := flag.Bool("httpIngest", true, "Set to true to enable HTTP ingest")
 
 	// Transcoding:
@@ -120,10 +124,27 @@ func main() {
 	orchSecret := flag.String("orchSecret", "", "Shared secret with the orchestrator as a standalone transcoder")
 	transcodingOptions := flag.String("transcodingOptions", "P240p30fps16x9,P360p30fps16x9", "Transcoding options for broadcast job, or path to json config")
 	maxAttempts := flag.Int("maxAttempts", 3, "Maximum transcode attempts")
+	orchWarmup := flag.Bool("orchWarmup", false, "Have newly selected orchestrators transcode a tiny test segment before the stream's segments, moving cold start off the first segment. The test segment is paid for like a segment of the stream")
+	maxOrchestratorsPerSegment := flag.Int("maxOrchestratorsPerSegment", 0, "Maximum number of distinct orchestrators to try for a segment before failing it. 0 for no limit besides -maxAttempts")
+	selectionSeed := flag.Int64("selectionSeed", 0, "Seed for the random selection of orchestrators, both in discovery and among the sessions, for reproducible selection when debugging. 0 for time based seed")
+	transcodeTimeoutFactor := flag.Float64("transcodeTimeoutFactor", server.TranscodeTimeoutFactor, "Cancel transcode of a segment and retry with another orchestrator if it takes longer than this many times the segment duration")
+	tenantMaxStreams := flag.Int("tenantMaxStreams", 0, "Max number of streams each tenant can have at once. Tenant is set by the auth webhook or is the manifest ID prefix before -tenantSeparator. No limit if 0")
+	tenantMaxNewStreams := flag.Int("tenantMaxNewStreams", 0, "Max number of streams each tenant can create within -tenantNewStreamsWindow. No limit if 0")
+	tenantNewStreamsWindow := flag.Duration("tenantNewStreamsWindow", time.Minute, "Window of -tenantMaxNewStreams")
+	tenantSeparator := flag.String("tenantSeparator", "", "Separator of the tenant prefix of the manifest ID for the streams without tenant set by the auth webhook")
+	inOrderUploads := flag.Bool("inOrderUploads", false, "Upload source segments of a stream strictly in seqNo order. Can be overridden per stream by the auth webhook")
+	warmupSegments := flag.Int("warmupSegments", 0, "Number of segments a new stream should buffer before its playlists are served to viewers. Disabled if 0")
+	warmupTimeout := flag.Duration("warmupTimeout", server.WarmupTimeout, "Serve playlists of a new stream to viewers after this long even if it hasn't buffered -warmupSegments yet")
+	healthMinSuccessRate := flag.Float64("healthMinSuccessRate", 0, "Orchestrator migrates its streams to other nodes while the share of segments it transcoded successfully since the previous health check is below this, from 0 to 1. Disabled if 0")
+	healthMaxTranscodeTime := flag.Duration("healthMaxTranscodeTime", 0, "Orchestrator migrates its streams to other nodes while average segment transcode time is above this. Disabled if 0")
+	healthCheckInterval := flag.Duration("healthCheckInterval", time.Minute, "How often orchestrator health is checked against -healthMinSuccessRate and -healthMaxTranscodeTime")
 	maxSessions := flag.Int("maxSessions", 10, "Maximum number of concurrent transcoding sessions for Orchestrator, maximum number or RTMP streams for Broadcaster, or maximum capacity for transcoder")
 	currentManifest := flag.Bool("currentManifest", false, "Expose the currently active ManifestID as \"/stream/current.m3u8\"")
+	playlistCacheControl := flag.String("playlistCacheControl", server.PlaylistCacheControl, "Cache-Control header of the HLS playlists served under /stream/")
+	segmentCacheControl := flag.String("segmentCacheControl", server.SegmentCacheControl, "Cache-Control header of the HLS segments served under /stream/")
 	nvidia := flag.String("nvidia", "", "Comma-separated list of Nvidia GPU device IDs to use for transcoding")
 	testTranscoder := flag.Bool("testTranscoder", true, "Test Nvidia GPU transcoding at startup")
+	bitrateCeilings := flag.String("bitrateCeilings", "", "Comma-separated list of profile=bitrate pairs capping the output bitrate of the profile, eg P720p30fps16x9=3000k")
 
 	// Onchain:
 	ethAcctAddr := flag.String("ethAcctAddr", "", "Existing Eth account address")
@@ -140,14 +161,40 @@ func main() {
 	maxTicketEV := flag.String("maxTicketEV", "100000000000000", "The maximum acceptable expected value for PM tickets")
 	// Broadcaster deposit multiplier to determine max acceptable ticket faceValue
 	depositMultiplier := flag.Int("depositMultiplier", 1, "The deposit multiplier used to determine max acceptable faceValue for PM tickets")
+	// Broadcaster automatic deposit top-up
+	depositTopUpThreshold := flag.String("depositTopUpThreshold", "", "Broadcaster deposit (in wei) below which it is topped up automatically with -depositTopUpAmount. Disabled if not set")
+	depositTopUpAmount := flag.String("depositTopUpAmount", "", "Amount (in wei) the broadcaster deposit is topped up with once it falls below -depositTopUpThreshold. Must be at least -depositTopUpThreshold")
 	// Orchestrator base pricing info
 	pricePerUnit := flag.Int("pricePerUnit", 0, "The price per 'pixelsPerUnit' amount pixels")
 	// Broadcaster max acceptable price
 	maxPricePerUnit := flag.Int("maxPricePerUnit", 0, "The maximum transcoding price (in wei) per 'pixelsPerUnit' a broadcaster is willing to accept. If not set explicitly, broadcaster is willing to accept ANY price")
+	// Broadcaster orchestrator selection weighted by price
+	priceWeightedSelection := flag.Bool("priceWeightedSelection", false, "Pick orchestrators at random weighted inversely by their price per pixel among all the orchestrators responding in time, instead of the first ones to respond")
+	// Broadcaster orchestrator selection by round trip time
+	latencyAwareSelection := flag.Bool("latencyAwareSelection", false, "Pick the orchestrators with the lowest recorded round trip time among all the orchestrators responding in time, instead of the first ones to respond. Round trip times are recorded in on-chain mode only")
+	// Broadcaster orchestrator selection weighted by stake
+	selectByStake := flag.Bool("selectByStake", false, "Pick orchestrators at random weighted by their stake among all the orchestrators responding in time, instead of the first ones to respond. Stake is known in on-chain mode only")
+	// Broadcaster orchestrator selection along the fallback chain of the stream
+	fallbackChainSelection := flag.Bool("fallbackChainSelection", false, "Send segments of a stream to the orchestrators along the stream's fallback chain: the first orchestrator found at stream start, then the other ones in order, moving on to the next one on failure and back to the first one once it is available again")
+	// Broadcaster orchestrator info requests refreshing the on-chain orchestrators cache
+	maxConcurrentOrchProbes := flag.Int("maxConcurrentOrchProbes", discovery.MaxConcurrentOrchProbes, "Max number of on-chain orchestrators requested for their info at once when refreshing the orchestrators cache. No limit if 0")
+	maxOrchProbeFailures := flag.Int("maxOrchProbeFailures", discovery.MaxOrchProbeFailures, "Number of info requests in a row an on-chain orchestrator can fail before it isn't selected, till its next successful request. Never left out if 0")
+	orchInfoTTL := flag.Duration("orchInfoTTL", discovery.OrchInfoTTL, "How often the info of the on-chain orchestrators is requested to refresh the orchestrators cache")
+	maxOrchInfoStaleness := flag.Duration("maxOrchInfoStaleness", discovery.MaxOrchInfoStaleness, "How long the last known good info of an on-chain orchestrator failing info requests is still used, even past -maxOrchProbeFailures. Not used if 0")
+	region := flag.String("region", "", "Region the on-chain orchestrators are preferably selected from. Only the orchestrators of the region are selected if there are any, otherwise the ones of unknown region")
+	orchRegions := flag.String("orchRegions", "", "Comma separated mappings of on-chain orchestrator service URI hosts to their regions (e.g. orch1.example.com=eu,orch2.example.com=us-east)")
+	orchScheme := flag.String("orchScheme", "https", "Scheme of the on-chain orchestrator service URIs which have none, https or http")
+	orchSchemeFallback := flag.Bool("orchSchemeFallback", false, "Try the info requests to https orchestrators which fail again over http, and reach the orchestrators which respond over http only that way. This disables TLS for all the requests to these orchestrators, including the payments. Insecure, for development and test setups only")
 	// Unit of pixels for both O's basePriceInfo and B's MaxBroadcastPrice
 	pixelsPerUnit := flag.Int("pixelsPerUnit", 1, "Amount of pixels per unit. Set to '> 1' to have smaller price granularity than 1 wei / pixel")
 	// Interval to poll for blocks
 	blockPollingInterval := flag.Int("blockPollingInterval", 5, "Interval in seconds at which different blockchain event services poll for blocks")
+	// ETH/USD price feed used to report ticket redemption cost in USD
+	ethUsdPriceUrl := flag.String("ethUsdPriceUrl", "", "URL of a JSON ETH/USD price feed, e.g. https://api.coingecko.com/api/v3/simple/price?ids=ethereum&vs_currencies=usd")
+	ethUsdPriceField := flag.String("ethUsdPriceField", "ethereum.usd", "Dot separated path to the price in the -ethUsdPriceUrl response")
+	ethUsdPriceInterval := flag.Duration("ethUsdPriceInterval", time.Minute, "How often to fetch the ETH/USD price")
+	// Defer ticket redemption on gas price spikes
+	maxRedeemGasCostRatio := flag.Float64("maxRedeemGasCostRatio", 0, "Defer ticket redemption while the redemption tx cost exceeds this fraction of the ticket face value (e.g. 0.5). Disabled if 0")
 	// Redemption service
 	redeemer := flag.Bool("redeemer", false, "Set to true to run a ticket redemption service")
 	redeemerAddr := flag.String("redeemerAddr", "", "URL of the ticket redemption service to use")
@@ -155,6 +202,11 @@ func main() {
 	reward := flag.Bool("reward", false, "Set to true to run a reward service")
 	// Metrics & logging:
 	monitor := flag.Bool("monitor", false, "Set to true to send performance metrics")
+	otlpEndpoint := flag.String("otlpEndpoint", "", "OpenTelemetry collector endpoint (host:port) to push metrics to using OTLP/HTTP. Requires -monitor")
+	otlpInsecure := flag.Bool("otlpInsecure", false, "Set to true to push metrics to the OTLP endpoint over plain HTTP")
+	metricsLabels := flag.String("metricsLabels", "", "Comma separated static labels added to all metrics (e.g. region=us-east,datacenter=dc1)")
+	segmentTimeout := flag.Duration("segmentTimeout", 0, "How long to wait for a segment to be transcoded before it is counted as lost in metrics. Defaults to 8.5s")
+	segmentTimeoutInterval := flag.Duration("segmentTimeoutInterval", 0, "How often to sweep for segments lost in metrics. Defaults to 15s")
 	version := flag.Bool("version", false, "Print out the version")
 	verbosity := flag.String("v", "", "Log verbosity.  {4|5|6}")
 
@@ -162,11 +214,37 @@ func main() {
 	datadir := flag.String("datadir", "", "data directory")
 	s3bucket := flag.String("s3bucket", "", "S3 region/bucket (e.g. eu-central-1/testbucket)")
 	s3creds := flag.String("s3creds", "", "S3 credentials (in form ACCESSKEYID/ACCESSKEY)")
+	s3endpoint := flag.String("s3endpoint", "", "URL of S3 compatible storage endpoint, e.g. https://minio.example.com:9000. AWS S3 if empty")
+	s3pathStyle := flag.Bool("s3pathStyle", false, "Use path-style addressing of the S3 bucket instead of virtual-hosted-style")
+	s3sse := flag.String("s3sse", "", "Server-side encryption of objects saved to S3: AES256 (SSE-S3) or aws:kms (SSE-KMS). No encryption if empty")
+	s3kmsKeyID := flag.String("s3kmsKeyId", "", "AWS KMS key ID used with -s3sse=aws:kms. AWS managed key if empty")
+	s3acl := flag.String("s3acl", drivers.S3DefaultACL, "Canned ACL of objects saved to S3: private, public-read, public-read-write, authenticated-read, aws-exec-read, bucket-owner-read or bucket-owner-full-control. URLs of objects that aren't public are presigned")
+	s3MultipartThreshold := flag.Int64("s3MultipartThreshold", drivers.S3MultipartThreshold, "Size in bytes above which data is saved to own S3 bucket with multipart upload")
+	s3MultipartPartSize := flag.Int64("s3MultipartPartSize", drivers.S3MultipartPartSize, "Size in bytes of the parts of S3 multipart upload, at least 5MB")
+	s3MultipartConcurrency := flag.Int("s3MultipartConcurrency", drivers.S3MultipartConcurrency, "Number of parts of S3 multipart upload uploaded in parallel")
+	uploadRetryQueueSize := flag.Int("uploadRetryQueueSize", 0, "Max number of failed segment uploads retried in background while the segment proceeds. Disabled if 0")
+	uploadRetryMaxAttempts := flag.Int("uploadRetryMaxAttempts", 5, "Max number of background retries of failed segment upload")
+	uploadRetryDelay := flag.Duration("uploadRetryDelay", time.Second, "Delay before the first background retry of failed segment upload, doubled for every next retry")
+	s3StrictChecksum := flag.Bool("s3StrictChecksum", false, "Fail uploads to own S3 bucket if ETag of the saved object doesn't match the data. Mismatch is only logged otherwise")
+	s3MaxConcurrentUploads := flag.Int("s3MaxConcurrentUploads", drivers.S3MaxConcurrentUploads, "Max number of segments uploaded to own S3 bucket at once, across all streams. No limit if 0")
+	s3PostMaxAttempts := flag.Int("s3PostMaxAttempts", drivers.S3PostMaxAttempts, "Max number of attempts to upload data to S3 with POST policy, retrying network errors and 5xx responses")
+	s3PostRetryDelay := flag.Duration("s3PostRetryDelay", drivers.S3PostRetryDelay, "Delay before the first retry of S3 upload, doubled for every next retry")
+	s3PostRetryJitter := flag.Float64("s3PostRetryJitter", drivers.S3PostRetryJitter, "Fraction by which delays between S3 upload retries are randomized, from 0 to 1")
 	gsBucket := flag.String("gsbucket", "", "Google storage bucket")
 	gsKey := flag.String("gskey", "", "Google Storage private key file name (in json format)")
+	azureContainer := flag.String("azureContainer", "", "Azure Blob Storage container in the form <account>/<container>")
+	storageCacheSize := flag.Int64("storageCacheSize", 0, "Max number of bytes of segments saved to S3, Google or Azure storage also kept in memory for fast re-serving. Disabled if 0")
+	maxSegmentFetches := flag.Int("maxSegmentFetches", 0, "Max number of segments not in -storageCacheSize cache fetched from the storage at once to be served, across all streams. No limit if 0")
+	fsStorageDir := flag.String("fsStorageDir", "", "Directory to save segments to, served by the node's HTTP server, instead of keeping them in memory")
+	azureKey := flag.String("azureKey", "", "Access key of the Azure storage account (base64 encoded)")
+	storagePathTemplate := flag.String("storagePathTemplate", "", "Layout of objects in own S3 or Google storage, e.g. year={year}/month={month}/day={day}/{stream}. Placeholders {year}, {month}, {day} and {hour} are filled with UTC time the stream's session is created")
+	storageRetention := flag.Duration("storageRetention", 0, "Delete segments older than this from the node's own S3 bucket set with -s3bucket and -s3creds (e.g. 72h). Other objects in the bucket are kept. Disabled if 0")
+	storageReapInterval := flag.Duration("storageReapInterval", time.Hour, "How often to check the object storage for segments older than -storageRetention")
+	contentTypes := flag.String("contentTypes", "", "Comma separated extension to content type mappings used for uploads, consulted before sniffing the data (e.g. .ts=video/mp2t,.mpd=application/dash+xml)")
 
 	// API
 	authWebhookURL := flag.String("authWebhookUrl", "", "RTMP authentication webhook URL")
+	streamKeyRotation := flag.Bool("streamKeyRotation", false, "Allow publishing an active stream again under a new stream key accepted by -authWebhookUrl, continuing the stream from the new publisher")
 	orchWebhookURL := flag.String("orchWebhookUrl", "", "Orchestrator discovery callback URL")
 
 	flag.Parse()
@@ -256,6 +334,11 @@ func main() {
 		return
 	}
 	defer dbh.Close()
+	if *orchScheme != "https" && *orchScheme != "http" {
+		glog.Error("-orchScheme should be https or http")
+		return
+	}
+	dbh.SetServiceURIScheme(*orchScheme)
 
 	n, err := core.NewLivepeerNode(nil, *datadir, dbh)
 	if err != nil {
@@ -266,6 +349,14 @@ func main() {
 		n.OrchSecret, _ = common.GetPass(*orchSecret)
 	}
 
+	if *bitrateCeilings != "" {
+		ceilings, err := core.ParseBitrateCeilings(*bitrateCeilings)
+		if err != nil {
+			glog.Fatalf("Invalid -bitrateCeilings err=%v", err)
+		}
+		core.BitrateCeilings = ceilings
+	}
+
 	if *transcoder {
 		core.WorkDir = *datadir
 		if *nvidia != "" {
@@ -297,6 +388,9 @@ func main() {
 		glog.Fatalf("No services enabled; must be at least one of -broadcaster, -transcoder, -orchestrator, -redeemer, -reward or -initializeRound")
 	}
 
+	if *otlpEndpoint != "" && !*monitor {
+		glog.Fatal("-otlpEndpoint requires -monitor")
+	}
 	if *monitor {
 		lpmon.Enabled = true
 		nodeID := *ethAcctAddr
@@ -315,7 +409,21 @@ func main() {
 		case core.RedeemerNode:
 			nodeType = "rdmr"
 		}
-		lpmon.InitCensus(nodeType, nodeID, core.LivepeerVersion)
+		labels, err := lpmon.ParseLabels(*metricsLabels)
+		if err != nil {
+			glog.Fatalf("Error parsing -metricsLabels: %v", err)
+		}
+		lpmon.InitCensus(nodeType, nodeID, core.LivepeerVersion, labels)
+		if *segmentTimeout > 0 {
+			lpmon.SetSegmentTimeout(*segmentTimeout)
+		}
+		if *segmentTimeoutInterval > 0 {
+			lpmon.SetTimeoutWatcherInterval(*segmentTimeoutInterval)
+		}
+		if *otlpEndpoint != "" {
+			stopOTLP := lpmon.InitCensusOTLP(*otlpEndpoint, *otlpInsecure)
+			defer stopOTLP()
+		}
 	}
 
 	if n.NodeType == core.TranscoderNode {
@@ -500,6 +608,10 @@ func main() {
 			recipientAddr = ethcommon.HexToAddress(*ethOrchAddr)
 		}
 
+		if *maxRedeemGasCostRatio < 0 {
+			glog.Errorf("-maxRedeemGasCostRatio must not be negative")
+			return
+		}
 		smCfg := &pm.LocalSenderMonitorConfig{
 			Claimant:        recipientAddr,
 			CleanupInterval: cleanupInterval,
@@ -507,6 +619,8 @@ func main() {
 			RedeemGas:       redeemGas,
 			SuggestGasPrice: backend.SuggestGasPrice,
 			RPCTimeout:      ethRPCTimeout,
+
+			MaxRedeemGasCostRatio: *maxRedeemGasCostRatio,
 		}
 
 		if *orchestrator {
@@ -555,6 +669,11 @@ func main() {
 			}
 			defer gpm.Stop()
 
+			if *ethUsdPriceUrl != "" {
+				src := eth.NewHTTPETHUSDPriceSource(*ethUsdPriceUrl, *ethUsdPriceField)
+				go eth.MonitorRedemptionCostUSD(ctx, gpm, src, redeemGas, *ethUsdPriceInterval)
+			}
+
 			var sm pm.SenderMonitor
 			if *redeemerAddr != "" {
 				*redeemerAddr = defaultAddr(*redeemerAddr, "127.0.0.1", RpcPort)
@@ -606,6 +725,19 @@ func main() {
 				panic(fmt.Errorf("-depositMultiplier must be greater than 0, but %v provided. Restart the node with a valid value for -depositMultiplier", *depositMultiplier))
 			}
 
+			if *depositTopUpThreshold != "" || *depositTopUpAmount != "" {
+				threshold, err := common.ParseBigInt(*depositTopUpThreshold)
+				if err != nil || threshold.Sign() <= 0 {
+					panic(fmt.Errorf("-depositTopUpThreshold must be greater than 0, but %v provided. Restart the node with a valid value for -depositTopUpThreshold", *depositTopUpThreshold))
+				}
+				amount, err := common.ParseBigInt(*depositTopUpAmount)
+				if err != nil || amount.Cmp(threshold) < 0 {
+					panic(fmt.Errorf("-depositTopUpAmount must be at least -depositTopUpThreshold, but %v provided. Restart the node with a valid value for -depositTopUpAmount", *depositTopUpAmount))
+				}
+				senderWatcher.EnableDepositTopUp(threshold, amount)
+				glog.Infof("Broadcaster deposit is topped up with %v once it falls below %v", eth.FormatUnits(amount, "ETH"), eth.FormatUnits(threshold, "ETH"))
+			}
+
 			// Fetch and cache broadcaster on-chain info
 			info, err := senderWatcher.GetSenderInfo(n.Eth.Account().Address)
 			if err != nil {
@@ -717,20 +849,83 @@ func main() {
 		glog.Error("Should specify both s3bucket and s3creds")
 		return
 	}
-	if *s3bucket != "" {
-		s3bp := strings.Split(*s3bucket, "/")
-		drivers.S3BUCKET = s3bp[1]
+	if *s3endpoint != "" {
+		if u, err := url.Parse(*s3endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			glog.Errorf("Invalid -s3endpoint=%s, should be http(s) URL", *s3endpoint)
+			return
+		}
+	}
+	if *s3sse != "" && *s3sse != "AES256" && *s3sse != "aws:kms" {
+		glog.Errorf("Invalid -s3sse=%s, should be AES256 or aws:kms", *s3sse)
+		return
+	}
+	if *s3kmsKeyID != "" && *s3sse != "aws:kms" {
+		glog.Error("-s3kmsKeyId requires -s3sse=aws:kms")
+		return
+	}
+	switch *s3acl {
+	case "private", "public-read", "public-read-write", "authenticated-read", "aws-exec-read", "bucket-owner-read", "bucket-owner-full-control":
+	default:
+		glog.Errorf("Invalid -s3acl=%s", *s3acl)
+		return
 	}
 	if *gsBucket != "" && *gsKey == "" || *gsBucket == "" && *gsKey != "" {
 		glog.Error("Should specify both gsbucket and gskey")
 		return
 	}
 
+	if *uploadRetryQueueSize < 0 || *uploadRetryMaxAttempts < 1 || *uploadRetryDelay < 0 {
+		glog.Error("-uploadRetryQueueSize should not be negative, -uploadRetryMaxAttempts should be at least 1 and -uploadRetryDelay non-negative")
+		return
+	}
+	if *uploadRetryQueueSize > 0 {
+		drivers.UploadRetries = drivers.NewUploadRetryQueue(*uploadRetryQueueSize, *uploadRetryMaxAttempts, *uploadRetryDelay)
+	}
+	if *s3MaxConcurrentUploads < 0 {
+		glog.Error("-s3MaxConcurrentUploads should not be negative")
+		return
+	}
+	drivers.S3MaxConcurrentUploads = *s3MaxConcurrentUploads
+	drivers.S3StrictChecksum = *s3StrictChecksum
 	// XXX get s3 credentials from local env vars?
+	// the reaper deletes from S3 even if other storage is used for the node
+	var s3Storage drivers.OSDriver
 	if *s3bucket != "" && *s3creds != "" {
 		br := strings.Split(*s3bucket, "/")
 		cr := strings.Split(*s3creds, "/")
-		drivers.NodeStorage = drivers.NewS3Driver(br[0], br[1], cr[0], cr[1])
+		s3Storage = drivers.NewS3Driver(drivers.S3Options{
+			Region:          br[0],
+			Bucket:          br[1],
+			AccessKey:       cr[0],
+			AccessKeySecret: cr[1],
+			Endpoint:        *s3endpoint,
+			PathStyle:       *s3pathStyle,
+			SSE:             *s3sse,
+			KMSKeyID:        *s3kmsKeyID,
+			ACL:             *s3acl,
+		})
+		drivers.NodeStorage = s3Storage
+	}
+	if *s3MultipartPartSize < 5*1024*1024 || *s3MultipartConcurrency < 1 {
+		glog.Error("-s3MultipartPartSize should be at least 5MB and -s3MultipartConcurrency at least 1")
+		return
+	}
+	drivers.S3MultipartThreshold = *s3MultipartThreshold
+	drivers.S3MultipartPartSize = *s3MultipartPartSize
+	drivers.S3MultipartConcurrency = *s3MultipartConcurrency
+	if *s3PostMaxAttempts < 1 || *s3PostRetryDelay < 0 || *s3PostRetryJitter < 0 || *s3PostRetryJitter > 1 {
+		glog.Error("-s3PostMaxAttempts should be at least 1, -s3PostRetryDelay non-negative and -s3PostRetryJitter from 0 to 1")
+		return
+	}
+	drivers.S3PostMaxAttempts = *s3PostMaxAttempts
+	drivers.S3PostRetryDelay = *s3PostRetryDelay
+	drivers.S3PostRetryJitter = *s3PostRetryJitter
+	if *storagePathTemplate != "" {
+		if err := drivers.ValidatePathTemplate(*storagePathTemplate); err != nil {
+			glog.Errorf("Invalid -storagePathTemplate: %v", err)
+			return
+		}
+		drivers.StoragePathTemplate = *storagePathTemplate
 	}
 
 	if *gsBucket != "" && *gsKey != "" {
@@ -742,6 +937,67 @@ func main() {
 		}
 	}
 
+	if *azureContainer != "" || *azureKey != "" {
+		ac := strings.Split(*azureContainer, "/")
+		if len(ac) != 2 || ac[0] == "" || ac[1] == "" || *azureKey == "" {
+			glog.Error("Should specify both azureContainer in the form <account>/<container> and azureKey")
+			return
+		}
+		if _, err := base64.StdEncoding.DecodeString(*azureKey); err != nil {
+			glog.Errorf("Invalid -azureKey, should be base64 encoded: %v", err)
+			return
+		}
+		drivers.AZUREACCOUNT = ac[0]
+		drivers.AZURECONTAINER = ac[1]
+		drivers.NodeStorage = drivers.NewAzureDriver(ac[0], *azureKey, ac[1])
+	}
+
+	if *storageCacheSize < 0 {
+		glog.Error("-storageCacheSize should not be negative")
+		return
+	}
+	if *storageCacheSize > 0 {
+		if drivers.NodeStorage == nil {
+			glog.Error("-storageCacheSize requires S3, Google or Azure storage")
+			return
+		}
+		drivers.NodeStorage = drivers.NewCacheDriver(drivers.NodeStorage, *storageCacheSize)
+	}
+	if *maxSegmentFetches < 0 {
+		glog.Error("-maxSegmentFetches should not be negative")
+		return
+	}
+	if *maxSegmentFetches > 0 {
+		server.SegmentFetches = server.NewSegmentFetchLimiter(*maxSegmentFetches)
+	}
+
+	if *contentTypes != "" {
+		types, err := drivers.ParseContentTypes(*contentTypes)
+		if err != nil {
+			glog.Errorf("Error parsing -contentTypes: %v", err)
+			return
+		}
+		for ext, typ := range types {
+			drivers.ContentTypes[ext] = typ
+		}
+	}
+
+	server.PlaylistCacheControl = *playlistCacheControl
+	server.SegmentCacheControl = *segmentCacheControl
+
+	if *storageRetention > 0 {
+		if s3Storage == nil {
+			glog.Error("-storageRetention requires -s3bucket and -s3creds")
+			return
+		}
+		if *storageReapInterval <= 0 {
+			glog.Error("-storageReapInterval must be greater than zero")
+			return
+		}
+		policy := drivers.RetentionPolicy{MaxAge: *storageRetention, Interval: *storageReapInterval}
+		go drivers.StartReaper(ctx, s3Storage.NewSession(""), policy)
+	}
+
 	core.MaxSessions = *maxSessions
 	if lpmon.Enabled {
 		lpmon.MaxSessions(core.MaxSessions)
@@ -754,11 +1010,62 @@ func main() {
 		*httpAddr = defaultAddr(*httpAddr, "127.0.0.1", RpcPort)
 
 		bcast := core.NewBroadcaster(n)
+		modes := 0
+		for _, mode := range []bool{*priceWeightedSelection, *latencyAwareSelection, *selectByStake} {
+			if mode {
+				modes++
+			}
+		}
+		if modes > 1 {
+			glog.Error("Only one of -priceWeightedSelection, -latencyAwareSelection and -selectByStake can be used")
+			return
+		}
+		server.BroadcastCfg.SetPriceWeightedSelection(*priceWeightedSelection)
+		server.BroadcastCfg.SetLatencyAwareSelection(*latencyAwareSelection)
+		server.BroadcastCfg.SetSelectByStake(*selectByStake)
+		server.BroadcastCfg.SetFallbackChainSelection(*fallbackChainSelection)
+		// set before the orchestrator pools are created, as they seed their
+		// selection with it
+		if *selectionSeed != 0 {
+			glog.Infof("Using orchestrator selection seed %d", *selectionSeed)
+		}
+		server.SelectionSeed = *selectionSeed
 
 		// When the node is on-chain mode always cache the on-chain orchestrators and poll for updates
 		// Right now we rely on the DBOrchestratorPoolCache constructor to do this. Consider separating the logic
 		// caching/polling from the logic for fetching orchestrators during discovery
 		if *network != "offchain" {
+			if *maxConcurrentOrchProbes < 0 {
+				glog.Error("-maxConcurrentOrchProbes should not be negative")
+				return
+			}
+			discovery.MaxConcurrentOrchProbes = *maxConcurrentOrchProbes
+			if *maxOrchProbeFailures < 0 {
+				glog.Error("-maxOrchProbeFailures should not be negative")
+				return
+			}
+			discovery.MaxOrchProbeFailures = *maxOrchProbeFailures
+			if *orchInfoTTL <= 0 {
+				glog.Error("-orchInfoTTL should be positive")
+				return
+			}
+			discovery.OrchInfoTTL = *orchInfoTTL
+			if *maxOrchInfoStaleness < 0 {
+				glog.Error("-maxOrchInfoStaleness should not be negative")
+				return
+			}
+			discovery.MaxOrchInfoStaleness = *maxOrchInfoStaleness
+			if *orchRegions != "" {
+				regions, err := discovery.ParseOrchRegions(*orchRegions)
+				if err != nil {
+					glog.Errorf("Error parsing -orchRegions: %v", err)
+					return
+				}
+				discovery.OrchRegions = regions
+			}
+			discovery.Region = *region
+			discovery.DefaultOrchScheme = *orchScheme
+			discovery.OrchSchemeFallback = *orchSchemeFallback
 			ctx, cancel := context.WithCancel(ctx)
 			defer cancel()
 			dbOrchPoolCache, err := discovery.NewDBOrchestratorPoolCache(ctx, n, timeWatcher)
@@ -793,6 +1100,13 @@ func main() {
 			glog.Info("Using auth webhook URL ", *authWebhookURL)
 			server.AuthWebhookURL = *authWebhookURL
 		}
+		if *streamKeyRotation {
+			if server.AuthWebhookURL == "" {
+				glog.Error("-streamKeyRotation requires -authWebhookUrl")
+				return
+			}
+			server.StreamKeyRotation = true
+		}
 
 		isLocalHTTP, err := isLocalURL("https://" + *httpAddr)
 		if err != nil {
@@ -829,8 +1143,45 @@ func main() {
 			server.Policy = &verification.Policy{Retries: 2}
 		}
 
+		if *verificationSampleRate < 0 || *verificationSampleRate > 1 {
+			glog.Error("-verificationSampleRate should be between 0 and 1")
+			return
+		}
+		if *verificationTolerance < 0 || *verificationTolerance > 1 {
+			glog.Error("-verificationTolerance should be between 0 and 1")
+			return
+		}
+		server.VerificationSampleRate = *verificationSampleRate
+		server.VerificationTolerance = *verificationTolerance
+
 		// Set max transcode attempts. <=0 is OK; it just means "don't transcode"
 		server.MaxAttempts = *maxAttempts
+		if *maxOrchestratorsPerSegment < 0 {
+			glog.Errorf("-maxOrchestratorsPerSegment must not be negative")
+			return
+		}
+		server.MaxOrchestratorsPerSegment = *maxOrchestratorsPerSegment
+		server.OrchestratorWarmup = *orchWarmup
+		if *transcodeTimeoutFactor <= 0 {
+			glog.Errorf("-transcodeTimeoutFactor must be greater than 0")
+			return
+		}
+		server.TranscodeTimeoutFactor = *transcodeTimeoutFactor
+		server.InOrderUploads = *inOrderUploads
+		if *warmupSegments < 0 || *warmupTimeout <= 0 {
+			glog.Errorf("-warmupSegments must not be negative and -warmupTimeout must be greater than 0")
+			return
+		}
+		server.WarmupSegments = *warmupSegments
+		server.WarmupTimeout = *warmupTimeout
+		if *tenantMaxStreams < 0 || *tenantMaxNewStreams < 0 || *tenantNewStreamsWindow <= 0 {
+			glog.Errorf("-tenantMaxStreams and -tenantMaxNewStreams must not be negative and -tenantNewStreamsWindow must be greater than 0")
+			return
+		}
+		if *tenantMaxStreams > 0 || *tenantMaxNewStreams > 0 {
+			server.TenantLimits = server.NewTenantLimiter(*tenantMaxStreams, *tenantMaxNewStreams, *tenantNewStreamsWindow)
+		}
+		server.TenantSeparator = *tenantSeparator
 
 	} else if n.NodeType == core.OrchestratorNode {
 		suri, err := getServiceURI(n, *serviceAddr)
@@ -847,9 +1198,34 @@ func main() {
 		if !*transcoder && n.OrchSecret == "" {
 			glog.Fatal("Running an orchestrator requires an -orchSecret for standalone mode or -transcoder for orchestrator+transcoder mode")
 		}
+
+		thresholds := core.HealthThresholds{MinSuccessRate: *healthMinSuccessRate, MaxTranscodeTime: *healthMaxTranscodeTime}
+		if thresholds.Enabled() {
+			if *healthMaxTranscodeTime > 0 && !*monitor {
+				glog.Error("-healthMaxTranscodeTime requires -monitor")
+				return
+			}
+			if *healthMinSuccessRate > 1 || *healthMaxTranscodeTime < 0 || *healthCheckInterval <= 0 {
+				glog.Error("-healthMinSuccessRate should be from 0 to 1, -healthMaxTranscodeTime non-negative and -healthCheckInterval greater than zero")
+				return
+			}
+			go core.NewHealthWatcher(n, thresholds).Watch(ctx, *healthCheckInterval)
+		}
 	}
 	*cliAddr = defaultAddr(*cliAddr, "127.0.0.1", CliPort)
 
+	if *fsStorageDir != "" {
+		if drivers.NodeStorage != nil {
+			glog.Error("-fsStorageDir can't be used together with S3, Google or Azure storage")
+			return
+		}
+		if err := os.MkdirAll(*fsStorageDir, 0755); err != nil {
+			glog.Errorf("Error creating -fsStorageDir: %v", err)
+			return
+		}
+		// base URL will be empty for broadcasters; that's OK
+		drivers.NodeStorage = drivers.NewFSDriver(*fsStorageDir, n.GetServiceURI().String())
+	}
 	if drivers.NodeStorage == nil {
 		// base URI will be empty for broadcasters; that's OK
 		drivers.NodeStorage = drivers.NewMemoryDriver(n.GetServiceURI())
@@ -5,10 +5,13 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"math"
 	"math/big"
+	"net/url"
 	"strconv"
 	"strings"
 	"text/template"
+	"time"
 
 	ethcommon "github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -23,8 +26,14 @@ import (
 type DB struct {
 	dbh *sql.DB
 
+	// scheme the service URIs of the orchestrators which have none are stored with
+	serviceURIScheme string
+
 	// prepared statements
 	updateOrch                       *sql.Stmt
+	updateOrchProbe                  *sql.Stmt
+	blacklistOrch                    *sql.Stmt
+	unblacklistOrch                  *sql.Stmt
 	selectKV                         *sql.Stmt
 	updateKV                         *sql.Stmt
 	insertUnbondingLock              *sql.Stmt
@@ -33,7 +42,7 @@ type DB struct {
 	unbondingLocks                   *sql.Stmt
 	withdrawableUnbondingLocks       *sql.Stmt
 	insertWinningTicket              *sql.Stmt
-	selectEarliestWinningTicket      *sql.Stmt
+	selectWinningTicket              *sql.Stmt
 	winningTicketCount               *sql.Stmt
 	markWinningTicketRedeemed        *sql.Stmt
 	removeWinningTicket              *sql.Stmt
@@ -51,6 +60,26 @@ type DBOrch struct {
 	ActivationRound   int64
 	DeactivationRound int64
 	Stake             int64 // Stored as a fixed point number
+	// RTT is the round trip time of the orchestrator info requests in
+	// milliseconds, 0 if unknown. Each update moves the stored value a quarter
+	// of the way towards the new one, so it decays the older measurements.
+	RTT int64
+	// ProbeFailures is the number of consecutive failed orchestrator info
+	// requests
+	ProbeFailures int64
+	// ProbedAt is the time of the last successful orchestrator info request,
+	// zero if there was none
+	ProbedAt time.Time
+	// Region the orchestrator is in, empty if unknown
+	Region string
+}
+
+// DBBlacklistedOrch is the type binding for a row result from the
+// orchestratorBlacklist table
+type DBBlacklistedOrch struct {
+	EthereumAddr string
+	Reason       string
+	ExpiresAt    time.Time
 }
 
 // DBOrch is the type binding for a row result from the unbondingLocks table
@@ -66,12 +95,38 @@ type DBOrchFilter struct {
 	MaxPrice     *big.Rat
 	CurrentRound *big.Int
 	Addresses    []ethcommon.Address
+	// ExcludeBlacklisted leaves out the orchestrators blacklisted until later
+	ExcludeBlacklisted bool
+	// MaxProbeFailures leaves out the orchestrators with at least as many
+	// consecutive failed info requests, if greater than 0
+	MaxProbeFailures int64
+	// MaxStaleness keeps the orchestrators failing info requests till their
+	// last successful request is older, if greater than 0. With
+	// MaxProbeFailures, only the orchestrators past both are left out.
+	MaxStaleness time.Duration
+	// Region leaves out the orchestrators of the other regions, if not empty.
+	// Orchestrators of unknown region are kept.
+	Region string
 }
 
-var LivepeerDBVersion = 1
+var LivepeerDBVersion = 5
 
 var ErrDBTooNew = errors.New("DB Too New")
 
+var ErrInvalidServiceURI = errors.New("invalid service URI")
+
+// migrations upgrade the DB to the version following the index + 1
+var migrations = []string{
+	// 1 -> 2
+	"ALTER TABLE orchestrators ADD COLUMN rtt int64 DEFAULT 0",
+	// 2 -> 3
+	"ALTER TABLE orchestrators ADD COLUMN probeFailures int64 DEFAULT 0",
+	// 3 -> 4
+	"ALTER TABLE orchestrators ADD COLUMN probedAt STRING",
+	// 4 -> 5
+	"ALTER TABLE orchestrators ADD COLUMN region STRING DEFAULT ''",
+}
+
 var schema = `
 	CREATE TABLE IF NOT EXISTS kv (
 		key STRING PRIMARY KEY,
@@ -88,7 +143,18 @@ var schema = `
 		pricePerPixel int64,
 		activationRound int64,
 		deactivationRound int64,
-		stake int64
+		stake int64,
+		rtt int64 DEFAULT 0,
+		probeFailures int64 DEFAULT 0,
+		probedAt STRING,
+		region STRING DEFAULT ''
+	);
+
+	CREATE TABLE IF NOT EXISTS orchestratorBlacklist (
+		ethereumAddr STRING PRIMARY KEY,
+		createdAt STRING DEFAULT CURRENT_TIMESTAMP NOT NULL,
+		expiresAt STRING NOT NULL,
+		reason STRING
 	);
 
 	CREATE TABLE IF NOT EXISTS unbondingLocks (
@@ -157,10 +223,31 @@ func NewDBOrch(ethereumAddr string, serviceURI string, pricePerPixel int64, acti
 	}
 }
 
+// NormalizeServiceURI validates the service URI of the orchestrator and
+// returns it as it is stored and dialed, with the scheme if it has none
+func NormalizeServiceURI(addr, scheme string) (string, error) {
+	addr = strings.TrimSpace(addr)
+	if !strings.Contains(addr, "://") {
+		addr = scheme + "://" + addr
+	}
+	uri, err := url.ParseRequestURI(addr)
+	if err != nil {
+		return "", fmt.Errorf("%w=%s: %v", ErrInvalidServiceURI, addr, err)
+	}
+	// scheme is lowercased by the parser
+	if uri.Scheme != "http" && uri.Scheme != "https" {
+		return "", fmt.Errorf("%w=%s: unsupported scheme", ErrInvalidServiceURI, addr)
+	}
+	if uri.Host == "" {
+		return "", fmt.Errorf("%w=%s: no host", ErrInvalidServiceURI, addr)
+	}
+	return uri.String(), nil
+}
+
 func InitDB(dbPath string) (*DB, error) {
 	// XXX need a way to ensure (via unit tests?) that all DB{} fields are
 	// properly closed / cleaned up in the case of an error
-	d := DB{}
+	d := DB{serviceURIScheme: "https"}
 	db, err := sql.Open("sqlite3", dbPath)
 	if err != nil {
 		glog.Error("Unable to open DB ", dbPath, err)
@@ -197,6 +284,11 @@ func InitDB(dbPath string) (*DB, error) {
 	} else if dbVersion < LivepeerDBVersion {
 		// Upgrade stepwise up to the correct version using the migration
 		// procedure for each version
+		if err := migrateDB(db, dbVersion); err != nil {
+			glog.Error("Unable to upgrade DB ", err)
+			d.Close()
+			return nil, err
+		}
 	} else if dbVersion == LivepeerDBVersion {
 		// all good; nothing to do
 	}
@@ -221,8 +313,8 @@ func InitDB(dbPath string) (*DB, error) {
 
 	// updateOrch prepared statement
 	stmt, err = db.Prepare(`
-	INSERT INTO orchestrators(updatedAt, ethereumAddr, serviceURI, pricePerPixel, activationRound, deactivationRound, stake, createdAt) 
-	VALUES(datetime(), :ethereumAddr, :serviceURI, :pricePerPixel, :activationRound, :deactivationRound, :stake, datetime()) 
+	INSERT INTO orchestrators(updatedAt, ethereumAddr, serviceURI, pricePerPixel, activationRound, deactivationRound, stake, rtt, region, createdAt) 
+	VALUES(datetime(), :ethereumAddr, :serviceURI, :pricePerPixel, :activationRound, :deactivationRound, :stake, :rtt, :region, datetime()) 
 	ON CONFLICT(ethereumAddr) DO UPDATE SET 
 	updatedAt = excluded.updatedAt,
 	serviceURI =
@@ -244,7 +336,17 @@ func InitDB(dbPath string) (*DB, error) {
 	stake = 
 		CASE WHEN excluded.stake == 0
 		THEN orchestrators.stake
-		ELSE excluded.stake END 
+		ELSE excluded.stake END,
+	rtt =
+		CASE WHEN excluded.rtt == 0
+		THEN orchestrators.rtt
+		WHEN orchestrators.rtt == 0
+		THEN excluded.rtt
+		ELSE (orchestrators.rtt * 3 + excluded.rtt) / 4 END,
+	region =
+		CASE WHEN excluded.region == ""
+		THEN orchestrators.region
+		ELSE excluded.region END
 	`)
 	if err != nil {
 		glog.Error("Unable to prepare updateOrch ", err)
@@ -252,6 +354,42 @@ func InitDB(dbPath string) (*DB, error) {
 		return nil, err
 	}
 	d.updateOrch = stmt
+	stmt, err = db.Prepare(`
+	UPDATE orchestrators SET probeFailures =
+		CASE WHEN :success
+		THEN 0
+		ELSE probeFailures + 1 END,
+	probedAt =
+		CASE WHEN :success
+		THEN datetime('now')
+		ELSE probedAt END
+	WHERE ethereumAddr = :ethereumAddr
+	`)
+	if err != nil {
+		glog.Error("Unable to prepare updateOrchProbe ", err)
+		d.Close()
+		return nil, err
+	}
+	d.updateOrchProbe = stmt
+
+	// Orchestrator blacklist prepared statements
+	stmt, err = db.Prepare(`
+	INSERT OR REPLACE INTO orchestratorBlacklist(ethereumAddr, reason, expiresAt, createdAt)
+	VALUES(:ethereumAddr, :reason, datetime('now', :ttl), datetime())
+	`)
+	if err != nil {
+		glog.Error("Unable to prepare blacklistOrch ", err)
+		d.Close()
+		return nil, err
+	}
+	d.blacklistOrch = stmt
+	stmt, err = db.Prepare("DELETE FROM orchestratorBlacklist WHERE ethereumAddr=? OR expiresAt <= datetime('now')")
+	if err != nil {
+		glog.Error("Unable to prepare unblacklistOrch ", err)
+		d.Close()
+		return nil, err
+	}
+	d.unblacklistOrch = stmt
 
 	// Unbonding locks prepared statements
 	stmt, err = db.Prepare("INSERT INTO unbondingLocks(id, delegator, amount, withdrawRound) VALUES(?, ?, ?, ?)")
@@ -302,14 +440,14 @@ func InitDB(dbPath string) (*DB, error) {
 	}
 	d.insertWinningTicket = stmt
 
-	// Select earliest ticket
-	stmt, err = db.Prepare("SELECT sender, recipient, faceValue, winProb, senderNonce, recipientRand, recipientRandHash, sig, creationRound, creationRoundBlockHash, paramsExpirationBlock FROM ticketQueue WHERE sender=? AND redeemedAt IS NULL AND txHash IS NULL ORDER BY createdAt ASC LIMIT 1")
+	// Select ticket at offset, earliest first
+	stmt, err = db.Prepare("SELECT sender, recipient, faceValue, winProb, senderNonce, recipientRand, recipientRandHash, sig, creationRound, creationRoundBlockHash, paramsExpirationBlock FROM ticketQueue WHERE sender=? AND redeemedAt IS NULL AND txHash IS NULL ORDER BY createdAt ASC LIMIT 1 OFFSET ?")
 	if err != nil {
-		glog.Error("Unable to prepare selectEarliestWinningTicket ", err)
+		glog.Error("Unable to prepare selectWinningTicket ", err)
 		d.Close()
 		return nil, err
 	}
-	d.selectEarliestWinningTicket = stmt
+	d.selectWinningTicket = stmt
 
 	stmt, err = db.Prepare("SELECT count(sig) FROM ticketQueue WHERE sender=? AND redeemedAt IS NULL AND txHash IS NULL")
 	if err != nil {
@@ -377,6 +515,29 @@ func InitDB(dbPath string) (*DB, error) {
 	return &d, nil
 }
 
+// migrateDB upgrades the DB from the version one migration at a time
+func migrateDB(db *sql.DB, version int) error {
+	for ; version < LivepeerDBVersion; version++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(migrations[version-1]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration to version %v failed: %v", version+1, err)
+		}
+		if _, err := tx.Exec("UPDATE kv SET value=?, updatedAt=datetime() WHERE key='dbVersion'", version+1); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		glog.Infof("Upgraded DB to version %v", version+1)
+	}
+	return nil
+}
+
 func (db *DB) Close() {
 	glog.V(DEBUG).Info("Closing DB")
 	if db.selectKV != nil {
@@ -388,6 +549,15 @@ func (db *DB) Close() {
 	if db.updateOrch != nil {
 		db.updateOrch.Close()
 	}
+	if db.updateOrchProbe != nil {
+		db.updateOrchProbe.Close()
+	}
+	if db.blacklistOrch != nil {
+		db.blacklistOrch.Close()
+	}
+	if db.unblacklistOrch != nil {
+		db.unblacklistOrch.Close()
+	}
 	if db.insertUnbondingLock != nil {
 		db.insertUnbondingLock.Close()
 	}
@@ -406,8 +576,8 @@ func (db *DB) Close() {
 	if db.insertWinningTicket != nil {
 		db.insertWinningTicket.Close()
 	}
-	if db.selectEarliestWinningTicket != nil {
-		db.selectEarliestWinningTicket.Close()
+	if db.selectWinningTicket != nil {
+		db.selectWinningTicket.Close()
 	}
 	if db.winningTicketCount != nil {
 		db.winningTicketCount.Close()
@@ -466,6 +636,12 @@ func (db *DB) ChainID() (*big.Int, error) {
 	return id, nil
 }
 
+// SetServiceURIScheme sets the scheme the service URIs of the orchestrators
+// which have none are stored with, https by default
+func (db *DB) SetServiceURIScheme(scheme string) {
+	db.serviceURIScheme = scheme
+}
+
 func (db *DB) SetChainID(id *big.Int) error {
 	if err := db.updateKVStore("chainID", id.String()); err != nil {
 		return err
@@ -499,13 +675,24 @@ func (db *DB) UpdateOrch(orch *DBOrch) error {
 		return nil
 	}
 
+	// empty service URI keeps the stored one
+	serviceURI := orch.ServiceURI
+	if serviceURI != "" {
+		var err error
+		if serviceURI, err = NormalizeServiceURI(serviceURI, db.serviceURIScheme); err != nil {
+			return err
+		}
+	}
+
 	_, err := db.updateOrch.Exec(
 		sql.Named("ethereumAddr", orch.EthereumAddr),
-		sql.Named("serviceURI", orch.ServiceURI),
+		sql.Named("serviceURI", serviceURI),
 		sql.Named("pricePerPixel", orch.PricePerPixel),
 		sql.Named("activationRound", orch.ActivationRound),
 		sql.Named("deactivationRound", orch.DeactivationRound),
 		sql.Named("stake", orch.Stake),
+		sql.Named("rtt", orch.RTT),
+		sql.Named("region", orch.Region),
 	)
 
 	if err != nil {
@@ -520,7 +707,11 @@ func (db *DB) SelectOrchs(filter *DBOrchFilter) ([]*DBOrch, error) {
 		return nil, nil
 	}
 
-	rows, err := db.dbh.Query(buildSelectOrchsQuery(filter))
+	qry, args, err := buildSelectOrchsQuery(filter)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.dbh.Query(qry, args...)
 	defer rows.Close()
 	if err != nil {
 		glog.Error("db: Unable to get orchestrators updated in the last 24 hours: ", err)
@@ -535,13 +726,111 @@ func (db *DB) SelectOrchs(filter *DBOrchFilter) ([]*DBOrch, error) {
 			activationRound   int64
 			deactivationRound int64
 			stake             int64
+			rtt               int64
+			probeFailures     int64
+			probedAt          sql.NullString
+			region            sql.NullString
 		)
-		if err := rows.Scan(&serviceURI, &ethereumAddr, &pricePerPixel, &activationRound, &deactivationRound, &stake); err != nil {
+		if err := rows.Scan(&serviceURI, &ethereumAddr, &pricePerPixel, &activationRound, &deactivationRound, &stake, &rtt, &probeFailures, &probedAt, &region); err != nil {
 			glog.Error("db: Unable to fetch orchestrator ", err)
 			continue
 		}
 
-		orchs = append(orchs, NewDBOrch(serviceURI, ethereumAddr, pricePerPixel, activationRound, deactivationRound, stake))
+		orch := NewDBOrch(serviceURI, ethereumAddr, pricePerPixel, activationRound, deactivationRound, stake)
+		orch.RTT = rtt
+		orch.ProbeFailures = probeFailures
+		orch.Region = region.String
+		if probedAt.Valid {
+			if orch.ProbedAt, err = time.Parse("2006-01-02 15:04:05", probedAt.String); err != nil {
+				glog.Error("db: Unable to parse orchestrator probe time ", err)
+			}
+		}
+		orchs = append(orchs, orch)
+	}
+	return orchs, nil
+}
+
+// UpdateOrchProbe records the result of the orchestrator info request. Failed
+// requests are counted till the next successful one, which is timestamped.
+func (db *DB) UpdateOrchProbe(ethereumAddr string, success bool) error {
+	if db == nil || ethereumAddr == "" {
+		return nil
+	}
+
+	_, err := db.updateOrchProbe.Exec(
+		sql.Named("ethereumAddr", ethereumAddr),
+		sql.Named("success", success),
+	)
+	if err != nil {
+		glog.Error("db: Unable to update orchestrator probe ", err)
+	}
+	return err
+}
+
+// BlacklistOrch excludes the orchestrator from the selection for the ttl,
+// replacing its previous blacklisting if any
+func (db *DB) BlacklistOrch(ethereumAddr string, ttl time.Duration, reason string) error {
+	if db == nil || ethereumAddr == "" {
+		return nil
+	}
+	if ttl <= 0 {
+		return fmt.Errorf("invalid blacklist ttl %v", ttl)
+	}
+
+	_, err := db.blacklistOrch.Exec(
+		sql.Named("ethereumAddr", ethereumAddr),
+		sql.Named("reason", reason),
+		sql.Named("ttl", fmt.Sprintf("+%d seconds", int64(math.Ceil(ttl.Seconds())))),
+	)
+	if err != nil {
+		glog.Error("db: Unable to blacklist orchestrator ", err)
+	}
+	return err
+}
+
+// UnblacklistOrch removes the orchestrator from the blacklist before its
+// blacklisting expires. Expired blacklistings are removed along the way.
+func (db *DB) UnblacklistOrch(ethereumAddr string) error {
+	if db == nil {
+		return nil
+	}
+
+	_, err := db.unblacklistOrch.Exec(ethereumAddr)
+	if err != nil {
+		glog.Error("db: Unable to unblacklist orchestrator ", err)
+	}
+	return err
+}
+
+// BlacklistedOrchs returns the orchestrators blacklisted until later
+func (db *DB) BlacklistedOrchs() ([]*DBBlacklistedOrch, error) {
+	if db == nil {
+		return nil, nil
+	}
+
+	rows, err := db.dbh.Query("SELECT ethereumAddr, reason, expiresAt FROM orchestratorBlacklist WHERE expiresAt > datetime('now') ORDER BY expiresAt")
+	if err != nil {
+		glog.Error("db: Unable to get blacklisted orchestrators ", err)
+		return nil, err
+	}
+	defer rows.Close()
+	orchs := []*DBBlacklistedOrch{}
+	for rows.Next() {
+		var (
+			ethereumAddr string
+			reason       sql.NullString
+			expiresAt    string
+		)
+		if err := rows.Scan(&ethereumAddr, &reason, &expiresAt); err != nil {
+			glog.Error("db: Unable to fetch blacklisted orchestrator ", err)
+			continue
+		}
+		expires, err := time.Parse("2006-01-02 15:04:05", expiresAt)
+		if err != nil {
+			glog.Error("db: Unable to parse blacklisted orchestrator expiration ", err)
+			continue
+		}
+		orchs = append(orchs, &DBBlacklistedOrch{EthereumAddr: ethereumAddr, Reason: reason.String, ExpiresAt: expires})
 	}
 	return orchs, nil
 }
@@ -551,12 +840,12 @@ func (db *DB) OrchCount(filter *DBOrchFilter) (int, error) {
 		return 0, nil
 	}
 
-	qry, err := buildOrchCountQuery(filter)
+	qry, args, err := buildOrchCountQuery(filter)
 	if err != nil {
 		return 0, err
 	}
 
-	row := db.dbh.QueryRow(qry)
+	row := db.dbh.QueryRow(qry, args...)
 
 	var count64 int64
 	if err := row.Scan(&count64); err != nil {
@@ -749,7 +1038,13 @@ func (db *DB) RemoveWinningTicket(ticket *pm.SignedTicket) error {
 // SelectEarliestWinningTicket selects the earliest stored winning ticket for a 'sender'
 // which is not yet redeemed
 func (db *DB) SelectEarliestWinningTicket(sender ethcommon.Address) (*pm.SignedTicket, error) {
-	row := db.selectEarliestWinningTicket.QueryRow(sender.Hex())
+	return db.SelectWinningTicketAt(sender, 0)
+}
+
+// SelectWinningTicketAt selects the stored winning ticket for a 'sender' which is not
+// yet redeemed, skipping the 'offset' earliest ones
+func (db *DB) SelectWinningTicketAt(sender ethcommon.Address, offset int) (*pm.SignedTicket, error) {
+	row := db.selectWinningTicket.QueryRow(sender.Hex(), offset)
 	var (
 		senderString           string
 		recipient              string
@@ -803,31 +1098,34 @@ func (db *DB) WinningTicketCount(sender ethcommon.Address) (int, error) {
 	return int(count64), nil
 }
 
-func buildSelectOrchsQuery(filter *DBOrchFilter) (string, error) {
-	query := "SELECT ethereumAddr, serviceURI, pricePerPixel, activationRound, deactivationRound, stake FROM orchestrators "
-	fil, err := buildFilterOrchsQuery(filter)
+func buildSelectOrchsQuery(filter *DBOrchFilter) (string, []interface{}, error) {
+	query := "SELECT ethereumAddr, serviceURI, pricePerPixel, activationRound, deactivationRound, stake, rtt, probeFailures, probedAt, region FROM orchestrators "
+	fil, args, err := buildFilterOrchsQuery(filter)
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
-	return query + fil, nil
+	return query + fil, args, nil
 }
 
-func buildOrchCountQuery(filter *DBOrchFilter) (string, error) {
+func buildOrchCountQuery(filter *DBOrchFilter) (string, []interface{}, error) {
 	query := "SELECT count(ethereumAddr) FROM orchestrators "
-	fil, err := buildFilterOrchsQuery(filter)
+	fil, args, err := buildFilterOrchsQuery(filter)
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
-	return query + fil, nil
+	return query + fil, args, nil
 }
 
-func buildFilterOrchsQuery(filter *DBOrchFilter) (string, error) {
+// buildFilterOrchsQuery returns the WHERE clause of the filter and the named
+// arguments it refers to
+func buildFilterOrchsQuery(filter *DBOrchFilter) (string, []interface{}, error) {
 	qry := "WHERE updatedAt >= datetime('now','-1 day')"
+	var args []interface{}
 	if filter != nil {
 		if filter.MaxPrice != nil {
 			fixedPrice, err := PriceToFixed(filter.MaxPrice)
 			if err != nil {
-				return "", err
+				return "", nil, err
 			}
 			qry += " AND pricePerPixel <= " + strconv.FormatInt(fixedPrice, 10)
 		}
@@ -844,8 +1142,29 @@ func buildFilterOrchsQuery(filter *DBOrchFilter) (string, error) {
 			}
 			qry += fmt.Sprintf(" AND ethereumAddr IN (%v)", strings.Join(hexAddrs, ", "))
 		}
+
+		if filter.ExcludeBlacklisted {
+			qry += " AND ethereumAddr NOT IN (SELECT ethereumAddr FROM orchestratorBlacklist WHERE expiresAt > datetime('now'))"
+		}
+
+		var failing []string
+		if filter.MaxProbeFailures > 0 {
+			failing = append(failing, fmt.Sprintf("probeFailures >= %v", filter.MaxProbeFailures))
+		}
+		if filter.MaxStaleness > 0 {
+			cutoff := fmt.Sprintf("datetime('now','-%d seconds')", int64(filter.MaxStaleness/time.Second))
+			failing = append(failing, fmt.Sprintf("probeFailures > 0 AND (probedAt IS NULL OR probedAt < %v)", cutoff))
+		}
+		if len(failing) > 0 {
+			qry += fmt.Sprintf(" AND NOT (%v)", strings.Join(failing, " AND "))
+		}
+
+		if filter.Region != "" {
+			qry += " AND (region = :region OR region = '' OR region IS NULL)"
+			args = append(args, sql.Named("region", filter.Region))
+		}
 	}
-	return qry, nil
+	return qry, args, nil
 }
 
 // FindLatestMiniHeader returns the MiniHeader with the highest blocknumber in the DB
@@ -2,6 +2,7 @@ package common
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"math"
 	"math/big"
@@ -225,7 +226,7 @@ func TestSelectUpdateOrchs_AddingUpdatingRow_NoError(t *testing.T) {
 	orchAddress := pm.RandAddress().String()
 	orch := &DBOrch{
 		EthereumAddr:      orchAddress,
-		ServiceURI:        "127.0.0.1:8936",
+		ServiceURI:        "https://127.0.0.1:8936",
 		PricePerPixel:     1,
 		ActivationRound:   0,
 		DeactivationRound: 0,
@@ -242,7 +243,7 @@ func TestSelectUpdateOrchs_AddingUpdatingRow_NoError(t *testing.T) {
 	assert.Equal(orchs[0].Stake, int64(0))
 
 	// updating row with same orchAddress
-	orchUpdate := NewDBOrch(orchAddress, "127.0.0.1:8937", 1000, 5, 10, 50)
+	orchUpdate := NewDBOrch(orchAddress, "https://127.0.0.1:8937", 1000, 5, 10, 50)
 	err = dbh.UpdateOrch(orchUpdate)
 	require.Nil(err)
 
@@ -257,7 +258,7 @@ func TestSelectUpdateOrchs_AddingUpdatingRow_NoError(t *testing.T) {
 	// updating only serviceURI
 	serviceURIUpdate := &DBOrch{
 		EthereumAddr: orchAddress,
-		ServiceURI:   "127.0.0.1:8938",
+		ServiceURI:   "https://127.0.0.1:8938",
 	}
 	err = dbh.UpdateOrch(serviceURIUpdate)
 	require.Nil(err)
@@ -347,7 +348,7 @@ func TestSelectUpdateOrchs_AddingMultipleRows_NoError(t *testing.T) {
 	// adding one row
 	orchAddress := pm.RandAddress().String()
 
-	orch := NewDBOrch(orchAddress, "127.0.0.1:8936", 1, 0, 0, 0)
+	orch := NewDBOrch(orchAddress, "https://127.0.0.1:8936", 1, 0, 0, 0)
 	err = dbh.UpdateOrch(orch)
 	require.Nil(err)
 
@@ -359,7 +360,7 @@ func TestSelectUpdateOrchs_AddingMultipleRows_NoError(t *testing.T) {
 	// adding second row
 	orchAddress = pm.RandAddress().String()
 
-	orchAdd := NewDBOrch(orchAddress, "127.0.0.1:8938", 1, 0, 0, 0)
+	orchAdd := NewDBOrch(orchAddress, "https://127.0.0.1:8938", 1, 0, 0, 0)
 	err = dbh.UpdateOrch(orchAdd)
 	require.Nil(err)
 
@@ -369,6 +370,173 @@ func TestSelectUpdateOrchs_AddingMultipleRows_NoError(t *testing.T) {
 	assert.Equal(orchsUpdated[1].ServiceURI, orchAdd.ServiceURI)
 }
 
+func TestSelectUpdateOrchs_ServiceURI(t *testing.T) {
+	dbh, dbraw, err := TempDB(t)
+	defer dbh.Close()
+	defer dbraw.Close()
+	require := require.New(t)
+	assert := assert.New(t)
+	require.Nil(err)
+
+	orchAddress := pm.RandAddress().String()
+	selectURIs := func() []string {
+		orchs, err := dbh.SelectOrchs(nil)
+		require.Nil(err)
+		var uris []string
+		for _, o := range orchs {
+			uris = append(uris, o.ServiceURI)
+		}
+		return uris
+	}
+
+	// stored with https scheme if it has none
+	require.Nil(dbh.UpdateOrch(NewDBOrch(orchAddress, " 127.0.0.1:8936 ", 1, 0, 0, 0)))
+	assert.Equal([]string{"https://127.0.0.1:8936"}, selectURIs())
+
+	// invalid ones are rejected, the stored one is kept
+	for _, uri := range []string{"badUrl\\://127.0.0.1:8936", "https://", "http://%41:8936"} {
+		err := dbh.UpdateOrch(NewDBOrch(orchAddress, uri, 1, 0, 0, 0))
+		assert.True(errors.Is(err, ErrInvalidServiceURI), uri)
+	}
+	require.True(errors.Is(dbh.UpdateOrch(NewDBOrch(pm.RandAddress().String(), "https://", 1, 0, 0, 0)), ErrInvalidServiceURI))
+	assert.Equal([]string{"https://127.0.0.1:8936"}, selectURIs())
+
+	// empty one keeps the stored one
+	require.Nil(dbh.UpdateOrch(&DBOrch{EthereumAddr: orchAddress, PricePerPixel: 2}))
+	assert.Equal([]string{"https://127.0.0.1:8936"}, selectURIs())
+
+	// stored with the configured scheme
+	dbh.SetServiceURIScheme("http")
+	require.Nil(dbh.UpdateOrch(NewDBOrch(orchAddress, "127.0.0.1:8937", 1, 0, 0, 0)))
+	assert.Equal([]string{"http://127.0.0.1:8937"}, selectURIs())
+	require.Nil(dbh.UpdateOrch(NewDBOrch(orchAddress, "https://127.0.0.1:8937", 1, 0, 0, 0)))
+	assert.Equal([]string{"https://127.0.0.1:8937"}, selectURIs())
+}
+
+func TestNormalizeServiceURI(t *testing.T) {
+	assert := assert.New(t)
+
+	for addr, expected := range map[string]string{
+		"127.0.0.1:8935":           "https://127.0.0.1:8935",
+		"httpbin.example.com:8935": "https://httpbin.example.com:8935",
+		"http://127.0.0.1:8935":    "http://127.0.0.1:8935",
+		"HTTPS://x:1":              "https://x:1",
+	} {
+		uri, err := NormalizeServiceURI(addr, "https")
+		assert.Nil(err, addr)
+		assert.Equal(expected, uri, addr)
+	}
+	for _, addr := range []string{"ftp://127.0.0.1:8935", "https://", ""} {
+		_, err := NormalizeServiceURI(addr, "https")
+		assert.True(errors.Is(err, ErrInvalidServiceURI), addr)
+	}
+}
+
+func TestSelectUpdateOrchs_RTT(t *testing.T) {
+	dbh, dbraw, err := TempDB(t)
+	defer dbh.Close()
+	defer dbraw.Close()
+	require := require.New(t)
+	assert := assert.New(t)
+	require.Nil(err)
+
+	orchAddress := pm.RandAddress().String()
+	selectRTT := func() int64 {
+		orchs, err := dbh.SelectOrchs(nil)
+		require.Nil(err)
+		require.Len(orchs, 1)
+		return orchs[0].RTT
+	}
+
+	// unknown by default
+	require.Nil(dbh.UpdateOrch(NewDBOrch(orchAddress, "https://127.0.0.1:8936", 1, 0, 0, 0)))
+	assert.Equal(int64(0), selectRTT())
+
+	// first measurement is stored as is
+	require.Nil(dbh.UpdateOrch(&DBOrch{EthereumAddr: orchAddress, RTT: 100}))
+	assert.Equal(int64(100), selectRTT())
+
+	// single slow measurement moves it a quarter of the way
+	require.Nil(dbh.UpdateOrch(&DBOrch{EthereumAddr: orchAddress, RTT: 3100}))
+	assert.Equal(int64(850), selectRTT())
+
+	// and decays with the following measurements
+	for i := 0; i < 10; i++ {
+		require.Nil(dbh.UpdateOrch(&DBOrch{EthereumAddr: orchAddress, RTT: 100}))
+	}
+	assert.InDelta(100, selectRTT(), 50)
+
+	// updates without measurement keep it
+	rtt := selectRTT()
+	require.Nil(dbh.UpdateOrch(&DBOrch{EthereumAddr: orchAddress, Stake: 10}))
+	assert.Equal(rtt, selectRTT())
+}
+
+func TestDBMigration(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	// version 1 DB
+	dbraw, err := sql.Open("sqlite3", dbPath(t))
+	require.Nil(err)
+	defer dbraw.Close()
+	_, err = dbraw.Exec(`
+	CREATE TABLE kv (
+		key STRING PRIMARY KEY,
+		value STRING,
+		updatedAt STRING DEFAULT CURRENT_TIMESTAMP
+	);
+	INSERT INTO kv(key, value) VALUES('dbVersion', '1');
+	CREATE TABLE orchestrators (
+		ethereumAddr STRING PRIMARY KEY,
+		createdAt STRING DEFAULT CURRENT_TIMESTAMP NOT NULL,
+		updatedAt STRING DEFAULT CURRENT_TIMESTAMP NOT NULL,
+		serviceURI STRING,
+		pricePerPixel int64,
+		activationRound int64,
+		deactivationRound int64,
+		stake int64
+	);
+	INSERT INTO orchestrators(ethereumAddr, serviceURI, pricePerPixel, activationRound, deactivationRound, stake) VALUES('0x01', '127.0.0.1:8936', 1, 0, 0, 0);
+	`)
+	require.Nil(err)
+
+	dbh, err := InitDB(dbPath(t))
+	require.Nil(err)
+	defer dbh.Close()
+
+	var dbVersion int
+	require.Nil(dbraw.QueryRow("SELECT value FROM kv WHERE key = 'dbVersion'").Scan(&dbVersion))
+	assert.Equal(LivepeerDBVersion, dbVersion)
+
+	orchs, err := dbh.SelectOrchs(nil)
+	require.Nil(err)
+	require.Len(orchs, 1)
+	assert.Equal("127.0.0.1:8936", orchs[0].ServiceURI)
+	assert.Equal(int64(0), orchs[0].RTT)
+
+	require.Nil(dbh.UpdateOrch(&DBOrch{EthereumAddr: "0x01", RTT: 100}))
+	orchs, err = dbh.SelectOrchs(nil)
+	require.Nil(err)
+	require.Len(orchs, 1)
+	assert.Equal(int64(100), orchs[0].RTT)
+	assert.Equal(int64(0), orchs[0].ProbeFailures)
+
+	require.Nil(dbh.UpdateOrchProbe("0x01", false))
+	orchs, err = dbh.SelectOrchs(nil)
+	require.Nil(err)
+	require.Len(orchs, 1)
+	assert.Equal(int64(1), orchs[0].ProbeFailures)
+	assert.True(orchs[0].ProbedAt.IsZero())
+
+	require.Nil(dbh.UpdateOrchProbe("0x01", true))
+	orchs, err = dbh.SelectOrchs(nil)
+	require.Nil(err)
+	require.Len(orchs, 1)
+	assert.False(orchs[0].ProbedAt.IsZero())
+	assert.Empty(orchs[0].Region)
+}
+
 func TestOrchCount(t *testing.T) {
 	assert := assert.New(t)
 	require := require.New(t)
@@ -538,6 +706,234 @@ func TestDBFilterOrchs(t *testing.T) {
 	assert.Len(orchsFiltered, 0)
 }
 
+func TestDBOrchBlacklist(t *testing.T) {
+	assert := assert.New(t)
+	var nilDb *DB
+	assert.Nil(nilDb.BlacklistOrch("foo", time.Hour, ""))
+	assert.Nil(nilDb.UnblacklistOrch("foo"))
+	nilOrchs, nilErr := nilDb.BlacklistedOrchs()
+	assert.Nil(nilOrchs)
+	assert.Nil(nilErr)
+
+	dbh, dbraw, err := TempDB(t)
+	defer dbh.Close()
+	defer dbraw.Close()
+	require := require.New(t)
+	require.Nil(err)
+
+	var addrs []string
+	for i := 0; i < 3; i++ {
+		orch := NewDBOrch(pm.RandAddress().String(), "https://127.0.0.1:"+strconv.Itoa(8936+i), 1, 0, 0, 0)
+		require.Nil(dbh.UpdateOrch(orch))
+		addrs = append(addrs, orch.EthereumAddr)
+	}
+	selectAddrs := func(filter *DBOrchFilter) []string {
+		orchs, err := dbh.SelectOrchs(filter)
+		require.Nil(err)
+		var addrs []string
+		for _, o := range orchs {
+			addrs = append(addrs, o.EthereumAddr)
+		}
+		return addrs
+	}
+
+	assert.NotNil(dbh.BlacklistOrch(addrs[0], 0, ""))
+	require.Nil(dbh.BlacklistOrch(addrs[0], time.Hour, "invalid ticket params"))
+	require.Nil(dbh.BlacklistOrch(addrs[1], 2*time.Hour, ""))
+
+	// blacklisted orchestrators are excluded on request only
+	assert.Equal(addrs[2:], selectAddrs(&DBOrchFilter{ExcludeBlacklisted: true}))
+	assert.Len(selectAddrs(nil), 3)
+	count, err := dbh.OrchCount(&DBOrchFilter{ExcludeBlacklisted: true})
+	require.Nil(err)
+	assert.Equal(1, count)
+
+	blacklisted, err := dbh.BlacklistedOrchs()
+	require.Nil(err)
+	require.Len(blacklisted, 2)
+	assert.Equal(addrs[0], blacklisted[0].EthereumAddr)
+	assert.Equal("invalid ticket params", blacklisted[0].Reason)
+	assert.WithinDuration(time.Now().Add(time.Hour), blacklisted[0].ExpiresAt, 5*time.Second)
+	assert.Equal(addrs[1], blacklisted[1].EthereumAddr)
+	assert.Equal("", blacklisted[1].Reason)
+
+	// removed from the blacklist
+	require.Nil(dbh.UnblacklistOrch(addrs[1]))
+	assert.Equal(addrs[1:], selectAddrs(&DBOrchFilter{ExcludeBlacklisted: true}))
+
+	// expired
+	_, err = dbraw.Exec("UPDATE orchestratorBlacklist SET expiresAt = datetime('now', '-1 second')")
+	require.Nil(err)
+	assert.Equal(addrs, selectAddrs(&DBOrchFilter{ExcludeBlacklisted: true}))
+	blacklisted, err = dbh.BlacklistedOrchs()
+	require.Nil(err)
+	assert.Empty(blacklisted)
+
+	// blacklisted again
+	require.Nil(dbh.BlacklistOrch(addrs[0], time.Minute, ""))
+	assert.Equal(addrs[1:], selectAddrs(&DBOrchFilter{ExcludeBlacklisted: true}))
+}
+
+func TestDBOrchProbeFailures(t *testing.T) {
+	assert := assert.New(t)
+	var nilDb *DB
+	assert.Nil(nilDb.UpdateOrchProbe("foo", false))
+
+	dbh, dbraw, err := TempDB(t)
+	defer dbh.Close()
+	defer dbraw.Close()
+	require := require.New(t)
+	require.Nil(err)
+
+	var addrs []string
+	for i := 0; i < 2; i++ {
+		orch := NewDBOrch(pm.RandAddress().String(), "https://127.0.0.1:"+strconv.Itoa(8936+i), 1, 0, 0, 0)
+		require.Nil(dbh.UpdateOrch(orch))
+		addrs = append(addrs, orch.EthereumAddr)
+	}
+	probeFailures := func() map[string]int64 {
+		orchs, err := dbh.SelectOrchs(nil)
+		require.Nil(err)
+		failures := make(map[string]int64)
+		for _, o := range orchs {
+			failures[o.EthereumAddr] = o.ProbeFailures
+		}
+		return failures
+	}
+
+	// counted in a row
+	for i := 0; i < 3; i++ {
+		require.Nil(dbh.UpdateOrchProbe(addrs[0], false))
+	}
+	require.Nil(dbh.UpdateOrchProbe(addrs[1], false))
+	assert.Equal(map[string]int64{addrs[0]: 3, addrs[1]: 1}, probeFailures())
+
+	// excluded on request only
+	orchs, err := dbh.SelectOrchs(&DBOrchFilter{MaxProbeFailures: 3})
+	require.Nil(err)
+	require.Len(orchs, 1)
+	assert.Equal(addrs[1], orchs[0].EthereumAddr)
+	count, err := dbh.OrchCount(&DBOrchFilter{MaxProbeFailures: 3})
+	require.Nil(err)
+	assert.Equal(1, count)
+	count, err = dbh.OrchCount(&DBOrchFilter{MaxProbeFailures: 1})
+	require.Nil(err)
+	assert.Equal(0, count)
+	count, err = dbh.OrchCount(nil)
+	require.Nil(err)
+	assert.Equal(2, count)
+
+	// kept by other updates, reset by successful probe
+	require.Nil(dbh.UpdateOrch(&DBOrch{EthereumAddr: addrs[0], Stake: 5}))
+	assert.Equal(map[string]int64{addrs[0]: 3, addrs[1]: 1}, probeFailures())
+	require.Nil(dbh.UpdateOrchProbe(addrs[0], true))
+	assert.Equal(map[string]int64{addrs[0]: 0, addrs[1]: 1}, probeFailures())
+
+	// unknown orchestrator
+	require.Nil(dbh.UpdateOrchProbe(pm.RandAddress().String(), false))
+	assert.Len(probeFailures(), 2)
+}
+
+func TestDBOrchStaleness(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	dbh, dbraw, err := TempDB(t)
+	defer dbh.Close()
+	defer dbraw.Close()
+	require.Nil(err)
+
+	var addrs []string
+	for i := 0; i < 3; i++ {
+		orch := NewDBOrch(pm.RandAddress().String(), "https://127.0.0.1:"+strconv.Itoa(8936+i), 1, 0, 0, 0)
+		require.Nil(dbh.UpdateOrch(orch))
+		addrs = append(addrs, orch.EthereumAddr)
+	}
+	selected := func(filter *DBOrchFilter) []string {
+		orchs, err := dbh.SelectOrchs(filter)
+		require.Nil(err)
+		count, err := dbh.OrchCount(filter)
+		require.Nil(err)
+		assert.Len(orchs, count)
+		var res []string
+		for _, o := range orchs {
+			res = append(res, o.EthereumAddr)
+		}
+		return res
+	}
+
+	// recently probed, probed long ago, never probed
+	start := time.Now().UTC().Add(-time.Second).Truncate(time.Second)
+	for _, addr := range addrs[:2] {
+		require.Nil(dbh.UpdateOrchProbe(addr, true))
+	}
+	orchs, err := dbh.SelectOrchs(nil)
+	require.Nil(err)
+	for _, o := range orchs {
+		if o.EthereumAddr != addrs[2] {
+			assert.False(o.ProbedAt.Before(start))
+		}
+	}
+	_, err = dbraw.Exec("UPDATE orchestrators SET probedAt = datetime('now','-2 hours') WHERE ethereumAddr = ?", addrs[1])
+	require.Nil(err)
+	for _, addr := range addrs {
+		for i := 0; i < 3; i++ {
+			require.Nil(dbh.UpdateOrchProbe(addr, false))
+		}
+	}
+
+	// failing orchestrators are kept till their last successful probe is stale
+	assert.Empty(selected(&DBOrchFilter{MaxProbeFailures: 3}))
+	assert.Equal([]string{addrs[0]}, selected(&DBOrchFilter{MaxProbeFailures: 3, MaxStaleness: time.Hour}))
+	assert.Equal([]string{addrs[0]}, selected(&DBOrchFilter{MaxStaleness: time.Hour}))
+	assert.ElementsMatch(addrs, selected(&DBOrchFilter{MaxProbeFailures: 4, MaxStaleness: time.Hour}))
+
+	// stale orchestrators aren't left out until they fail
+	require.Nil(dbh.UpdateOrchProbe(addrs[2], true))
+	_, err = dbraw.Exec("UPDATE orchestrators SET probedAt = datetime('now','-2 hours') WHERE ethereumAddr = ?", addrs[2])
+	require.Nil(err)
+	assert.ElementsMatch([]string{addrs[0], addrs[2]}, selected(&DBOrchFilter{MaxStaleness: time.Hour}))
+}
+
+func TestDBOrchRegion(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	dbh, dbraw, err := TempDB(t)
+	defer dbh.Close()
+	defer dbraw.Close()
+	require.Nil(err)
+
+	regions := []string{"eu", "us", ""}
+	var addrs []string
+	for i, region := range regions {
+		orch := NewDBOrch(pm.RandAddress().String(), "https://127.0.0.1:"+strconv.Itoa(8936+i), 1, 0, 0, 0)
+		orch.Region = region
+		require.Nil(dbh.UpdateOrch(orch))
+		addrs = append(addrs, orch.EthereumAddr)
+	}
+	selected := func(filter *DBOrchFilter) map[string]string {
+		orchs, err := dbh.SelectOrchs(filter)
+		require.Nil(err)
+		count, err := dbh.OrchCount(filter)
+		require.Nil(err)
+		assert.Len(orchs, count)
+		res := make(map[string]string)
+		for _, o := range orchs {
+			res[o.EthereumAddr] = o.Region
+		}
+		return res
+	}
+
+	assert.Equal(map[string]string{addrs[0]: "eu", addrs[1]: "us", addrs[2]: ""}, selected(nil))
+	// orchestrators of unknown region are kept
+	assert.Equal(map[string]string{addrs[0]: "eu", addrs[2]: ""}, selected(&DBOrchFilter{Region: "eu"}))
+	assert.Equal(map[string]string{addrs[2]: ""}, selected(&DBOrchFilter{Region: "o'neill"}))
+
+	// kept by other updates, replaced by another region
+	require.Nil(dbh.UpdateOrch(&DBOrch{EthereumAddr: addrs[0], Stake: 5}))
+	require.Nil(dbh.UpdateOrch(&DBOrch{EthereumAddr: addrs[1], Region: "eu"}))
+	assert.Equal(map[string]string{addrs[0]: "eu", addrs[1]: "eu", addrs[2]: ""}, selected(nil))
+}
+
 func TestDBUnbondingLocks(t *testing.T) {
 	dbh, dbraw, err := TempDB(t)
 	defer dbh.Close()
@@ -989,6 +1385,14 @@ func TestSelectEarliestWinningTicket(t *testing.T) {
 	assert.Nil(err)
 	assert.Equal(earliest, signedTicket0)
 
+	// Test skipping earliest tickets
+	next, err := dbh.SelectWinningTicketAt(ethcommon.HexToAddress("charizard"), 1)
+	assert.Nil(err)
+	assert.Equal(signedTicket2, next)
+	next, err = dbh.SelectWinningTicketAt(ethcommon.HexToAddress("charizard"), 2)
+	assert.Nil(err)
+	assert.Nil(next)
+
 	// Test excluding submitted tickets
 	err = dbh.MarkWinningTicketRedeemed(signedTicket0, pm.RandHash())
 	require.Nil(err)
@@ -3,6 +3,7 @@ package common
 import (
 	"math/big"
 	"net/url"
+	"time"
 
 	ethcommon "github.com/ethereum/go-ethereum/common"
 	"github.com/livepeer/go-livepeer/net"
@@ -32,6 +33,10 @@ type OrchestratorStore interface {
 	OrchCount(filter *DBOrchFilter) (int, error)
 	SelectOrchs(filter *DBOrchFilter) ([]*DBOrch, error)
 	UpdateOrch(orch *DBOrch) error
+	UpdateOrchProbe(ethereumAddr string, success bool) error
+	BlacklistOrch(ethereumAddr string, ttl time.Duration, reason string) error
+	UnblacklistOrch(ethereumAddr string) error
+	BlacklistedOrchs() ([]*DBBlacklistedOrch, error)
 }
 
 type RoundsManager interface {
@@ -33,6 +33,7 @@ const (
 	Capability_ProfileH264High
 	Capability_ProfileH264ConstrainedHigh
 	Capability_GOP
+	Capability_StorageAzure
 )
 
 var capFormatConv = errors.New("capability: unknown format")
@@ -194,6 +195,8 @@ func storageToCapability(os drivers.OSSession) (Capability, error) {
 		return Capability_StorageS3, nil
 	case net.OSInfo_GOOGLE:
 		return Capability_StorageGCS, nil
+	case net.OSInfo_AZURE:
+		return Capability_StorageAzure, nil
 	case net.OSInfo_DIRECT:
 		return Capability_StorageDirect, nil
 	}
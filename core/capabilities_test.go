@@ -1,6 +1,7 @@
 package core
 
 import (
+	"io"
 	"sort"
 	"testing"
 
@@ -112,7 +113,7 @@ func TestCapability_JobCapabilities(t *testing.T) {
 		{Profile: ffmpeg.ProfileH264High},
 		{GOP: 1},
 	}
-	storage := drivers.NewS3Driver("", "", "", "").NewSession("")
+	storage := drivers.NewS3Driver(drivers.S3Options{}).NewSession("")
 	params := &StreamParameters{Profiles: profs, OS: storage}
 	assert.True(checkSuccess(params, []Capability{
 		Capability_H264,
@@ -258,9 +259,13 @@ func (os *stubOS) GetInfo() *net.OSInfo {
 	}
 	return &net.OSInfo{StorageType: net.OSInfo_StorageType(os.storageType)}
 }
-func (os *stubOS) EndSession()                             {}
-func (os *stubOS) SaveData(string, []byte) (string, error) { return "", nil }
-func (os *stubOS) IsExternal() bool                        { return false }
+func (os *stubOS) EndSession()                                                      {}
+func (os *stubOS) SaveData(string, []byte, *drivers.FileProperties) (string, error) { return "", nil }
+func (os *stubOS) SaveDataReader(string, io.Reader, int64) (string, error)          { return "", nil }
+func (os *stubOS) IsExternal() bool                                                 { return false }
+func (os *stubOS) ListData() ([]*drivers.FileInfo, error)                           { return nil, nil }
+func (os *stubOS) DeleteData(string) error                                          { return nil }
+func (os *stubOS) ReadData(string) ([]byte, error)                                  { return nil, drivers.ErrNotFound }
 
 func TestCapability_StorageToCapability(t *testing.T) {
 	assert := assert.New(t)
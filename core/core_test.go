@@ -117,6 +117,14 @@ func TestTranscodeSeg(t *testing.T) {
 	}
 	resHash := ethCrypto.Keccak256(resHashes...)
 	assert.Equal(resHash, res.Sig)
+
+	// results are counted for the health watcher
+	n.Transcoder = nil
+	res = n.transcodeSeg(conf, seg, md)
+	assert.Equal(ErrTranscoderAvail, res.Err)
+	succeeded, failed := n.TranscodeResults()
+	assert.Equal(int64(2), succeeded)
+	assert.Equal(int64(1), failed)
 }
 
 func TestTranscodeLoop_GivenNoSegmentsPastTimeout_CleansSegmentChan(t *testing.T) {
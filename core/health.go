@@ -0,0 +1,101 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/golang/glog"
+	lpmon "github.com/livepeer/go-livepeer/monitor"
+)
+
+// ErrOrchUnhealthy is returned for the segments of all the streams while the
+// orchestrator is unhealthy, so that broadcasters move them to other orchestrators
+var ErrOrchUnhealthy = errors.New("OrchestratorUnhealthy")
+
+// HealthThresholds are the limits of node-level health metrics. Crossing any
+// of them makes the orchestrator unhealthy. Zero disables the check.
+type HealthThresholds struct {
+	// MinSuccessRate lowest acceptable share of the segments transcoded
+	// successfully since the previous check
+	MinSuccessRate float64
+	// MaxTranscodeTime highest acceptable average transcode time of the
+	// segments transcoded since the previous check
+	MaxTranscodeTime time.Duration
+}
+
+// Enabled returns whether any of the checks is enabled
+func (t HealthThresholds) Enabled() bool {
+	return t.MinSuccessRate > 0 || t.MaxTranscodeTime > 0
+}
+
+// HealthWatcher marks the orchestrator unhealthy when its health metrics
+// cross the thresholds, and healthy again once they are back within them
+type HealthWatcher struct {
+	node       *LivepeerNode
+	thresholds HealthThresholds
+
+	// node-level metrics: transcode results counted by the node and
+	// transcode times of the monitor by default
+	transcodeResults func() (int64, int64)
+	transcodeTimes   func() (float64, int64)
+
+	lastSucceeded int64
+	lastFailed    int64
+	lastTimeSum   float64
+	lastTimeCount int64
+}
+
+// NewHealthWatcher returns watcher of the node's health
+func NewHealthWatcher(node *LivepeerNode, thresholds HealthThresholds) *HealthWatcher {
+	return &HealthWatcher{
+		node:             node,
+		thresholds:       thresholds,
+		transcodeResults: node.TranscodeResults,
+		transcodeTimes:   lpmon.TranscodeTimeTotals,
+	}
+}
+
+// Check compares the metrics with the thresholds and updates node's health
+func (w *HealthWatcher) Check() {
+	var reason string
+	succeeded, failed := w.transcodeResults()
+	// like with transcode time, only the segments since the previous check count
+	if total := succeeded + failed - w.lastSucceeded - w.lastFailed; w.thresholds.MinSuccessRate > 0 && total > 0 {
+		if rate := float64(succeeded-w.lastSucceeded) / float64(total); rate < w.thresholds.MinSuccessRate {
+			reason = "success_rate"
+		}
+	}
+	w.lastSucceeded, w.lastFailed = succeeded, failed
+	sum, count := w.transcodeTimes()
+	if count < w.lastTimeCount {
+		// counters were reset
+		w.lastTimeSum, w.lastTimeCount = 0, 0
+	}
+	// no segments since the previous check, e.g. after the streams migrated,
+	// doesn't count as slow transcoding
+	if reason == "" && w.thresholds.MaxTranscodeTime > 0 && count > w.lastTimeCount {
+		avg := (sum - w.lastTimeSum) / float64(count-w.lastTimeCount)
+		if avg > w.thresholds.MaxTranscodeTime.Seconds() {
+			reason = "transcode_time"
+		}
+	}
+	w.lastTimeSum, w.lastTimeCount = sum, count
+	w.node.SetUnhealthy(reason)
+}
+
+// Watch checks the health every interval until the context is done
+func (w *HealthWatcher) Watch(ctx context.Context, interval time.Duration) {
+	glog.Infof("Starting health watcher minSuccessRate=%v maxTranscodeTime=%s interval=%s",
+		w.thresholds.MinSuccessRate, w.thresholds.MaxTranscodeTime, interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			w.Check()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
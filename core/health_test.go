@@ -0,0 +1,112 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/livepeer/go-livepeer/drivers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthThresholds_Enabled(t *testing.T) {
+	assert := assert.New(t)
+	assert.False(HealthThresholds{}.Enabled())
+	assert.True(HealthThresholds{MinSuccessRate: 0.9}.Enabled())
+	assert.True(HealthThresholds{MaxTranscodeTime: time.Second}.Enabled())
+}
+
+func TestHealthWatcher_Migration(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	drivers.NodeStorage = drivers.NewMemoryDriver(nil)
+	n, _ := NewLivepeerNode(nil, "", nil)
+	o := NewOrchestrator(n, nil)
+	md := StubSegTranscodingMetadata()
+	_, err := n.getSegmentChan(md)
+	require.Nil(err)
+
+	var succeeded, failed int64 = 10, 0
+	var timeSum float64
+	var timeCount int64
+	w := NewHealthWatcher(n, HealthThresholds{MinSuccessRate: 0.9, MaxTranscodeTime: 2 * time.Second})
+	w.transcodeResults = func() (int64, int64) { return succeeded, failed }
+	w.transcodeTimes = func() (float64, int64) { return timeSum, timeCount }
+
+	// healthy node keeps its streams
+	w.Check()
+	assert.Equal("", n.Unhealthy())
+	assert.Nil(o.CheckCapacity(md.ManifestID))
+	_, err = n.getSegmentChan(md)
+	assert.Nil(err)
+
+	// degraded success rate since the previous check migrates existing and
+	// rejects new streams
+	succeeded, failed = 15, 5
+	w.Check()
+	assert.Equal("success_rate", n.Unhealthy())
+	assert.Equal(ErrOrchUnhealthy, o.CheckCapacity(md.ManifestID))
+	assert.Equal(ErrOrchUnhealthy, o.CheckCapacity(ManifestID("new")))
+	_, err = n.getSegmentChan(md)
+	assert.Equal(ErrOrchUnhealthy, err)
+	_, err = n.getSegmentChan(md)
+	assert.Equal(ErrOrchUnhealthy, err)
+	assert.Equal(map[ManifestID]bool{md.ManifestID: true}, n.migrated)
+
+	// recovers once the metrics are back within thresholds
+	succeeded = 25
+	w.Check()
+	assert.Equal("", n.Unhealthy())
+	assert.Nil(n.migrated)
+	assert.Nil(o.CheckCapacity(ManifestID("new")))
+	_, err = n.getSegmentChan(md)
+	assert.Nil(err)
+
+	// slow transcoding since the previous check
+	timeSum, timeCount = 30, 10
+	w.Check()
+	assert.Equal("transcode_time", n.Unhealthy())
+	_, err = n.getSegmentChan(md)
+	assert.Equal(ErrOrchUnhealthy, err)
+
+	// no segments transcoded since the previous check isn't slow transcoding
+	w.Check()
+	assert.Equal("", n.Unhealthy())
+
+	// only the segments since the previous check are averaged
+	timeSum, timeCount = 32, 12
+	w.Check()
+	assert.Equal("", n.Unhealthy())
+
+	// counters reset
+	timeSum, timeCount = 9, 3
+	w.Check()
+	assert.Equal("transcode_time", n.Unhealthy())
+}
+
+func TestHealthWatcher_Watch(t *testing.T) {
+	n, _ := NewLivepeerNode(nil, "", nil)
+	w := NewHealthWatcher(n, HealthThresholds{MinSuccessRate: 0.9})
+	var checks int64
+	w.transcodeResults = func() (int64, int64) {
+		checks++
+		return checks, checks
+	}
+	w.transcodeTimes = func() (float64, int64) { return 0, 0 }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		w.Watch(ctx, time.Millisecond)
+		close(done)
+	}()
+	assert.Eventually(t, func() bool { return n.Unhealthy() == "success_rate" }, time.Second, time.Millisecond)
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("watcher didn't stop")
+	}
+}
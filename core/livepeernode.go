@@ -20,8 +20,10 @@ import (
 
 	"github.com/livepeer/go-livepeer/pm"
 
+	"github.com/golang/glog"
 	"github.com/livepeer/go-livepeer/common"
 	"github.com/livepeer/go-livepeer/eth"
+	lpmon "github.com/livepeer/go-livepeer/monitor"
 )
 
 var ErrTranscoderAvail = errors.New("ErrTranscoderUnavailable")
@@ -72,7 +74,14 @@ type LivepeerNode struct {
 	// Transcoder private fields
 	priceInfo    *big.Rat
 	serviceURI   url.URL
+	draining     bool
+	unhealthy    string // reason the node is unhealthy, empty if healthy
 	segmentMutex *sync.RWMutex
+	// streams rejected while unhealthy, guarded by segmentMutex
+	migrated map[ManifestID]bool
+	// segments transcoded and failed to transcode so far
+	transcodeSucceeded int64
+	transcodeFailed    int64
 }
 
 //NewLivepeerNode creates a new Livepeer Node. Eth can be nil.
@@ -106,6 +115,72 @@ func (n *LivepeerNode) SetBasePrice(price *big.Rat) {
 	n.priceInfo = price
 }
 
+// SetDrainMode toggles drain mode for an orchestrator. While draining, new
+// sessions are rejected but existing sessions continue to be served
+func (n *LivepeerNode) SetDrainMode(drain bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.draining = drain
+	if lpmon.Enabled {
+		lpmon.DrainMode(drain)
+	}
+}
+
+// Draining returns whether the orchestrator is in drain mode
+func (n *LivepeerNode) Draining() bool {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.draining
+}
+
+// SetUnhealthy marks the orchestrator unhealthy for the reason, or healthy if
+// the reason is empty. Segments of all the streams of unhealthy orchestrator
+// are rejected, so that broadcasters migrate them to other orchestrators
+func (n *LivepeerNode) SetUnhealthy(reason string) {
+	n.segmentMutex.Lock()
+	defer n.segmentMutex.Unlock()
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if reason == n.unhealthy {
+		return
+	}
+	if reason != "" {
+		glog.Errorf("Orchestrator is unhealthy, migrating streams reason=%s", reason)
+	} else {
+		glog.Infof("Orchestrator is healthy again, migrated streams=%d", len(n.migrated))
+		n.migrated = nil
+	}
+	n.unhealthy = reason
+	if lpmon.Enabled {
+		lpmon.NodeUnhealthy(reason != "")
+	}
+}
+
+// TranscodeResults returns the number of segments the node transcoded and
+// failed to transcode so far
+func (n *LivepeerNode) TranscodeResults() (succeeded, failed int64) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.transcodeSucceeded, n.transcodeFailed
+}
+
+func (n *LivepeerNode) countTranscodeResult(err error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if err != nil {
+		n.transcodeFailed++
+	} else {
+		n.transcodeSucceeded++
+	}
+}
+
+// Unhealthy returns the reason the orchestrator is unhealthy, empty if healthy
+func (n *LivepeerNode) Unhealthy() string {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.unhealthy
+}
+
 // GetBasePrice gets the base price for an orchestrator
 func (n *LivepeerNode) GetBasePrice() *big.Rat {
 	n.mu.RLock()
@@ -547,6 +547,21 @@ func TestGetSegmentChan(t *testing.T) {
 	}
 	MaxSessions = oldTranscodeSessions
 
+	// Test drain mode
+	n.SetDrainMode(true)
+	segData.ManifestID = StubSegTranscodingMetadata().ManifestID
+	if _, err := n.getSegmentChan(segData); err != nil {
+		t.Error("Existing mid should continue processing in drain mode: ", err)
+	}
+	segData.ManifestID = ManifestID(t.Name() + "_drain")
+	if _, err := n.getSegmentChan(segData); err != ErrOrchCap {
+		t.Error("Didn't reject new session in drain mode: ", err)
+	}
+	n.SetDrainMode(false)
+	if _, err := n.getSegmentChan(segData); err != nil {
+		t.Error("Didn't accept new session after leaving drain mode: ", err)
+	}
+
 	// Test what happens when invoking the transcode loop fails
 	drivers.NodeStorage = nil // will make the transcode loop fail
 	node, _ := NewLivepeerNode(nil, "", nil)
@@ -595,6 +610,14 @@ func TestOrchCheckCapacity(t *testing.T) {
 	assert.Nil(err)
 	MaxSessions = 0
 	assert.Nil(o.CheckCapacity(md.ManifestID))
+
+	// drain mode rejects new sessions but not existing ones
+	MaxSessions = cap
+	n.SetDrainMode(true)
+	assert.Nil(o.CheckCapacity(md.ManifestID))
+	assert.Equal(ErrOrchCap, o.CheckCapacity(ManifestID("new")))
+	n.SetDrainMode(false)
+	assert.Nil(o.CheckCapacity(ManifestID("new")))
 }
 
 func TestProcessPayment_GivenRecipientError_ReturnsNil(t *testing.T) {
@@ -80,10 +80,13 @@ func (orch *orchestrator) TranscoderSecret() string {
 func (orch *orchestrator) CheckCapacity(mid ManifestID) error {
 	orch.node.segmentMutex.RLock()
 	defer orch.node.segmentMutex.RUnlock()
+	if orch.node.Unhealthy() != "" {
+		return ErrOrchUnhealthy
+	}
 	if _, ok := orch.node.SegmentChans[mid]; ok {
 		return nil
 	}
-	if len(orch.node.SegmentChans) >= MaxSessions {
+	if len(orch.node.SegmentChans) >= MaxSessions || orch.node.Draining() {
 		return ErrOrchCap
 	}
 	return nil
@@ -130,12 +133,15 @@ func (orch *orchestrator) ProcessPayment(payment net.Payment, manifestID Manifes
 	seed := new(big.Int).SetBytes(payment.TicketParams.Seed)
 
 	priceInfoRat, err := common.RatPriceInfo(priceInfo)
+	if err == nil && priceInfoRat == nil {
+		err = errors.New("expected price is nil")
+	}
 	if err != nil {
+		if monitor.Enabled {
+			monitor.PaymentRecvError(sender.String(), string(manifestID), monitor.PaymentValidationPrice)
+		}
 		return fmt.Errorf("invalid expected price sent with payment err=%v", err)
 	}
-	if priceInfoRat == nil {
-		return fmt.Errorf("invalid expected price sent with payment err=%v", "expected price is nil")
-	}
 
 	ticketParams := &pm.TicketParams{
 		Recipient:         ethcommon.BytesToAddress(payment.TicketParams.Recipient),
@@ -178,7 +184,7 @@ func (orch *orchestrator) ProcessPayment(payment net.Payment, manifestID Manifes
 			glog.Errorf("Error receiving ticket manifestID=%v recipientRandHash=%x senderNonce=%v: %v", manifestID, ticket.RecipientRandHash, ticket.SenderNonce, err)
 
 			if monitor.Enabled {
-				monitor.PaymentRecvError(sender.String(), string(manifestID), err.Error())
+				monitor.PaymentRecvError(sender.String(), string(manifestID), pm.ValidationStage(err))
 			}
 			if _, ok := err.(*pm.FatalReceiveErr); ok {
 				return err
@@ -360,7 +366,8 @@ type TranscodeResult struct {
 // TranscodeData contains the transcoding output for an input segment
 type TranscodeData struct {
 	Segments []*TranscodedSegmentData
-	Pixels   int64 // Decoded pixels
+	Pixels   int64  // Decoded pixels
+	Device   string // GPU device used for transcoding, empty for CPU
 }
 
 // TranscodedSegmentData contains encoded data for a profile
@@ -425,10 +432,23 @@ func (n *LivepeerNode) getSegmentChan(md *SegTranscodingMetadata) (SegmentChan,
 	// concurrency concerns here? what if a chan is added mid-call?
 	n.segmentMutex.Lock()
 	defer n.segmentMutex.Unlock()
+	if reason := n.Unhealthy(); reason != "" {
+		if _, ok := n.SegmentChans[md.ManifestID]; ok && !n.migrated[md.ManifestID] {
+			if n.migrated == nil {
+				n.migrated = make(map[ManifestID]bool)
+			}
+			n.migrated[md.ManifestID] = true
+			glog.Infof("Migrating stream manifestID=%s reason=%s", md.ManifestID, reason)
+			if lpmon.Enabled {
+				lpmon.StreamMigrated(reason)
+			}
+		}
+		return nil, ErrOrchUnhealthy
+	}
 	if sc, ok := n.SegmentChans[md.ManifestID]; ok {
 		return sc, nil
 	}
-	if len(n.SegmentChans) >= MaxSessions {
+	if len(n.SegmentChans) >= MaxSessions || n.Draining() {
 		return nil, ErrOrchCap
 	}
 	sc := make(SegmentChan, 1)
@@ -463,7 +483,11 @@ func (n *LivepeerNode) sendToTranscodeLoop(md *SegTranscodingMetadata, seg *stre
 	return res, res.Err
 }
 
-func (n *LivepeerNode) transcodeSeg(config transcodeConfig, seg *stream.HLSSegment, md *SegTranscodingMetadata) *TranscodeResult {
+func (n *LivepeerNode) transcodeSeg(config transcodeConfig, seg *stream.HLSSegment, md *SegTranscodingMetadata) (res *TranscodeResult) {
+	if n != nil {
+		// success rate the health watcher checks
+		defer func() { n.countTranscodeResult(res.Err) }()
+	}
 	var fnamep *string
 	terr := func(err error) *TranscodeResult {
 		if fnamep != nil {
@@ -512,7 +536,7 @@ func (n *LivepeerNode) transcodeSeg(config transcodeConfig, seg *stream.HLSSegme
 		// Need to store segment in our local OS
 		var err error
 		name := fmt.Sprintf("%d.tempfile", seg.SeqNo)
-		url, err = config.LocalOS.SaveData(name, seg.Data)
+		url, err = config.LocalOS.SaveData(name, seg.Data, nil)
 		if err != nil {
 			return terr(err)
 		}
@@ -538,12 +562,15 @@ func (n *LivepeerNode) transcodeSeg(config transcodeConfig, seg *stream.HLSSegme
 	took := time.Since(start)
 	glog.V(common.DEBUG).Infof("Transcoding of segment manifestID=%s seqNo=%d took=%v", string(md.ManifestID), seg.SeqNo, took)
 	if !isRemote && monitor.Enabled {
-		monitor.SegmentTranscoded(0, seg.SeqNo, took, common.ProfilesNames(md.Profiles))
+		monitor.SegmentTranscoded(0, seg.SeqNo, took, common.ProfilesNames(md.Profiles), tData.Device)
+		monitor.SegmentTranscodedOn(tData.Device)
 	}
 
 	// Prepare the result object
 	var tr TranscodeResult
 	segHashes := make([][]byte, len(tSegments))
+	var outputBytes int64
+	sizes := make([]int64, len(md.Profiles))
 
 	for i := range md.Profiles {
 		if tSegments[i].Data == nil || len(tSegments[i].Data) < 25 {
@@ -555,6 +582,13 @@ func (n *LivepeerNode) transcodeSeg(config transcodeConfig, seg *stream.HLSSegme
 			string(md.ManifestID), seg.SeqNo, md.Profiles[i].Name, len(tSegments[i].Data))
 		hash := crypto.Keccak256(tSegments[i].Data)
 		segHashes[i] = hash
+		outputBytes += int64(len(tSegments[i].Data))
+		sizes[i] = int64(len(tSegments[i].Data))
+	}
+	if monitor.Enabled {
+		profiles := common.ProfilesNames(md.Profiles)
+		monitor.TranscodeCompressionRatio(profiles, int64(len(seg.Data)), outputBytes)
+		monitor.RenditionSizeVariance(profiles, sizes)
 	}
 	os.Remove(fname)
 	tr.OS = config.OS
@@ -17,12 +17,19 @@ type PlaylistManager interface {
 	ManifestID() ManifestID
 	// Implicitly creates master and media playlists
 	// Inserts in media playlist given a link to a segment
-	InsertHLSSegment(profile *ffmpeg.VideoProfile, seqNo uint64, uri string, duration float64) error
+	// Size of the segment in bytes, if known, updates the bandwidth of the
+	// rendition in the master playlist
+	InsertHLSSegment(profile *ffmpeg.VideoProfile, seqNo uint64, uri string, duration float64, size int) error
 
 	GetHLSMasterPlaylist() *m3u8.MasterPlaylist
 
 	GetHLSMediaPlaylist(rendition string) *m3u8.MediaPlaylist
 
+	// Presentation time in seconds of the first segment of the media playlist,
+	// the total duration of the segments that slid out of its window. Lets
+	// DASH share the seqNo and duration bookkeeping of the HLS playlists.
+	GetHLSMediaPlaylistStart(rendition string) float64
+
 	GetOSSession() drivers.OSSession
 
 	Cleanup()
@@ -34,7 +41,13 @@ type BasicPlaylistManager struct {
 	// Live playlist used for broadcasting
 	masterPList *m3u8.MasterPlaylist
 	mediaLists  map[string]*m3u8.MediaPlaylist
-	mapSync     *sync.RWMutex
+	// bitrates of the recent segments measured from their sizes, by rendition
+	// and sequence number
+	segBitrates map[string]map[uint64]uint32
+	// presentation times of the first segments of the media playlists, by
+	// rendition
+	plStarts map[string]float64
+	mapSync  *sync.RWMutex
 }
 
 // NewBasicPlaylistManager create new BasicPlaylistManager struct
@@ -46,6 +59,8 @@ func NewBasicPlaylistManager(manifestID ManifestID,
 		manifestID:     manifestID,
 		masterPList:    m3u8.NewMasterPlaylist(),
 		mediaLists:     make(map[string]*m3u8.MediaPlaylist),
+		segBitrates:    make(map[string]map[uint64]uint32),
+		plStarts:       make(map[string]float64),
 		mapSync:        &sync.RWMutex{},
 	}
 	return bplm
@@ -89,7 +104,7 @@ func (mgr *BasicPlaylistManager) getOrCreatePL(profile *ffmpeg.VideoProfile) (*m
 }
 
 func (mgr *BasicPlaylistManager) InsertHLSSegment(profile *ffmpeg.VideoProfile, seqNo uint64, uri string,
-	duration float64) error {
+	duration float64, size int) error {
 
 	mpl, err := mgr.getOrCreatePL(profile)
 	if err != nil {
@@ -97,17 +112,96 @@ func (mgr *BasicPlaylistManager) InsertHLSSegment(profile *ffmpeg.VideoProfile,
 	}
 	mseg := newMediaSegment(uri, duration)
 	if mpl.Count() >= mpl.WinSize() {
-		mpl.Remove()
+		mgr.slide(profile.Name, mpl)
 	}
 	if mpl.Count() == 0 {
 		mpl.SeqNo = mseg.SeqId
 	}
 
-	return mpl.InsertSegment(seqNo, mseg)
+	if err := mpl.InsertSegment(seqNo, mseg); err != nil {
+		return err
+	}
+	if size > 0 && duration > 0 {
+		mgr.updateBandwidth(profile.Name, mpl, seqNo, uint32(float64(size*8)/duration))
+	}
+	return nil
+}
+
+// updateBandwidth sets the BANDWIDTH of the rendition in the master playlist
+// to the peak bitrate of its recent segments, as measured from their actual
+// sizes rather than the nominal bitrate of the profile. The m3u8 package can't
+// write per segment EXT-X-BITRATE tags, so players get the measured bitrate
+// from the master playlist only.
+func (mgr *BasicPlaylistManager) updateBandwidth(rendition string, mpl *m3u8.MediaPlaylist, seqNo uint64, bitrate uint32) {
+	mgr.mapSync.Lock()
+	defer mgr.mapSync.Unlock()
+	bitrates, ok := mgr.segBitrates[rendition]
+	if !ok {
+		bitrates = make(map[uint64]uint32)
+		mgr.segBitrates[rendition] = bitrates
+	}
+	bitrates[seqNo] = bitrate
+
+	// only the segments within the live window of the media playlist count
+	var last uint64
+	for sn := range bitrates {
+		if sn > last {
+			last = sn
+		}
+	}
+	var peak uint32
+	for sn, b := range bitrates {
+		if sn+uint64(mpl.WinSize()) <= last {
+			delete(bitrates, sn)
+		} else if b > peak {
+			peak = b
+		}
+	}
+
+	// the master playlist caches its encoding, so replace it rather than
+	// change the variant in place
+	changed := false
+	for _, v := range mgr.masterPList.Variants {
+		if v.Chunklist == mpl && v.Bandwidth != peak {
+			changed = true
+		}
+	}
+	if !changed {
+		return
+	}
+	master := m3u8.NewMasterPlaylist()
+	for _, v := range mgr.masterPList.Variants {
+		params := v.VariantParams
+		if v.Chunklist == mpl {
+			params.Bandwidth = peak
+		}
+		master.Append(v.URI, v.Chunklist, params)
+	}
+	mgr.masterPList = master
+}
+
+// slide removes the first segment of the full media playlist, moving the
+// start of the playlist past it. The playlist is full, so every slot of its
+// ring buffer holds a segment of the window.
+func (mgr *BasicPlaylistManager) slide(rendition string, mpl *m3u8.MediaPlaylist) {
+	var first *m3u8.MediaSegment
+	for _, seg := range mpl.Segments {
+		if seg != nil && (first == nil || seg.SeqId < first.SeqId) {
+			first = seg
+		}
+	}
+	if mpl.Remove() != nil || first == nil {
+		return
+	}
+	mgr.mapSync.Lock()
+	mgr.plStarts[rendition] += first.Duration
+	mgr.mapSync.Unlock()
 }
 
 // GetHLSMasterPlaylist ..
 func (mgr *BasicPlaylistManager) GetHLSMasterPlaylist() *m3u8.MasterPlaylist {
+	mgr.mapSync.RLock()
+	defer mgr.mapSync.RUnlock()
 	return mgr.masterPList
 }
 
@@ -116,6 +210,14 @@ func (mgr *BasicPlaylistManager) GetHLSMediaPlaylist(rendition string) *m3u8.Med
 	return mgr.getPL(rendition)
 }
 
+// GetHLSMediaPlaylistStart returns the presentation time of the first segment
+// of the media playlist
+func (mgr *BasicPlaylistManager) GetHLSMediaPlaylistStart(rendition string) float64 {
+	mgr.mapSync.RLock()
+	defer mgr.mapSync.RUnlock()
+	return mgr.plStarts[rendition]
+}
+
 func newMediaSegment(uri string, duration float64) *m3u8.MediaSegment {
 	return &m3u8.MediaSegment{
 		URI:      uri,
@@ -8,6 +8,7 @@ import (
 	"github.com/livepeer/go-livepeer/drivers"
 	ffmpeg "github.com/livepeer/lpms/ffmpeg"
 	"github.com/livepeer/m3u8"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestGetMasterPlaylist(t *testing.T) {
@@ -16,7 +17,7 @@ func TestGetMasterPlaylist(t *testing.T) {
 	mid := hlsStrmID.ManifestID
 	c := NewBasicPlaylistManager(mid, nil)
 	segName := "test_seg/1.ts"
-	err := c.InsertHLSSegment(&vProfile, 1, segName, 12)
+	err := c.InsertHLSSegment(&vProfile, 1, segName, 12, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -100,7 +101,7 @@ func TestPlaylists(t *testing.T) {
 		return a.SeqId == b.SeqId && a.URI == b.URI && a.Duration == b.Duration
 	}
 	seg := &m3u8.MediaSegment{SeqId: 9, URI: "abc", Duration: -11.1}
-	if err := c.InsertHLSSegment(vProfile, seg.SeqId, seg.URI, seg.Duration); err != nil {
+	if err := c.InsertHLSSegment(vProfile, seg.SeqId, seg.URI, seg.Duration, 0); err != nil {
 		t.Error("HLS insertion")
 	}
 	// Sanity check some PL properties
@@ -112,7 +113,7 @@ func TestPlaylists(t *testing.T) {
 		t.Error("Unexpected playlist/segment properties")
 	}
 
-	if err := c.InsertHLSSegment(vProfile, seg.SeqId, seg.URI, seg.Duration); err == nil {
+	if err := c.InsertHLSSegment(vProfile, seg.SeqId, seg.URI, seg.Duration, 0); err == nil {
 		t.Error("Unexpected HLS insertion: segment already exists and should not have been inserted")
 	}
 
@@ -123,7 +124,7 @@ func TestPlaylists(t *testing.T) {
 
 	// Insert out of order. Playlist should accommodate this.
 	seg1 := &m3u8.MediaSegment{SeqId: 3, URI: "def", Duration: -11.1}
-	if err := c.InsertHLSSegment(vProfile, seg1.SeqId, seg1.URI, seg1.Duration); err != nil {
+	if err := c.InsertHLSSegment(vProfile, seg1.SeqId, seg1.URI, seg1.Duration, 0); err != nil {
 		t.Error("HLS insertion")
 	}
 
@@ -133,7 +134,7 @@ func TestPlaylists(t *testing.T) {
 
 	// Sanity Check. Insert out of order. Playlist should accommodate this.
 	seg2 := &m3u8.MediaSegment{SeqId: 1, URI: "ghi", Duration: -11.1}
-	if err := c.InsertHLSSegment(vProfile, seg2.SeqId, seg2.URI, seg2.Duration); err != nil {
+	if err := c.InsertHLSSegment(vProfile, seg2.SeqId, seg2.URI, seg2.Duration, 0); err != nil {
 		t.Error("HLS insertion")
 	}
 
@@ -144,7 +145,7 @@ func TestPlaylists(t *testing.T) {
 	// Ensure we have different segments between two playlists
 	newSeg := &m3u8.MediaSegment{SeqId: 3, URI: "def", Duration: -11.1}
 	newProfile := &ffmpeg.P240p30fps16x9
-	if err := c.InsertHLSSegment(newProfile, newSeg.SeqId, newSeg.URI, newSeg.Duration); err != nil {
+	if err := c.InsertHLSSegment(newProfile, newSeg.SeqId, newSeg.URI, newSeg.Duration, 0); err != nil {
 		t.Error("HLS insertion")
 	}
 
@@ -155,6 +156,73 @@ func TestPlaylists(t *testing.T) {
 
 }
 
+func TestSegmentBandwidth(t *testing.T) {
+	assert := assert.New(t)
+	c := NewBasicPlaylistManager(RandomManifestID(), nil)
+	vProfile := &ffmpeg.P144p30fps16x9
+	otherProfile := &ffmpeg.P240p30fps16x9
+	bandwidth := func(profile *ffmpeg.VideoProfile) uint32 {
+		for _, v := range c.GetHLSMasterPlaylist().Variants {
+			if v.Chunklist == c.GetHLSMediaPlaylist(profile.Name) {
+				return v.Bandwidth
+			}
+		}
+		t.Fatal("No variant for ", profile.Name)
+		return 0
+	}
+
+	// nominal bitrate of the profile until the size is known
+	assert.Nil(c.InsertHLSSegment(vProfile, 1, "1.ts", 2, 0))
+	assert.Nil(c.InsertHLSSegment(otherProfile, 1, "1.ts", 2, 0))
+	assert.Equal(ffmpeg.VideoProfileToVariantParams(*vProfile).Bandwidth, bandwidth(vProfile))
+
+	// peak bitrate of the segments measured from their sizes
+	assert.Nil(c.InsertHLSSegment(vProfile, 2, "2.ts", 2, 50000))
+	assert.Equal(uint32(200000), bandwidth(vProfile))
+	assert.Nil(c.InsertHLSSegment(vProfile, 3, "3.ts", 4, 150000))
+	assert.Equal(uint32(300000), bandwidth(vProfile))
+	assert.Nil(c.InsertHLSSegment(vProfile, 4, "4.ts", 2, 25000))
+	assert.Equal(uint32(300000), bandwidth(vProfile))
+	master := c.GetHLSMasterPlaylist().String()
+	assert.Contains(master, "BANDWIDTH=300000,RESOLUTION=256x144")
+
+	// other renditions aren't affected
+	assert.Equal(ffmpeg.VideoProfileToVariantParams(*otherProfile).Bandwidth, bandwidth(otherProfile))
+
+	// segments out of the live window don't count
+	for i := uint64(5); i < 5+uint64(LIVE_LIST_LENGTH); i++ {
+		assert.Nil(c.InsertHLSSegment(vProfile, i, "seg.ts", 2, 25000))
+	}
+	assert.Equal(uint32(100000), bandwidth(vProfile))
+	assert.NotEqual(master, c.GetHLSMasterPlaylist().String())
+	assert.Contains(c.GetHLSMasterPlaylist().String(), "BANDWIDTH=100000,RESOLUTION=256x144")
+}
+
+func TestPlaylistStart(t *testing.T) {
+	assert := assert.New(t)
+	c := NewBasicPlaylistManager(RandomManifestID(), nil)
+	vProfile := &ffmpeg.P144p30fps16x9
+	otherProfile := &ffmpeg.P240p30fps16x9
+	assert.Equal(0.0, c.GetHLSMediaPlaylistStart(vProfile.Name))
+
+	// fill the window; the playlist still starts at zero
+	for i := uint64(1); i <= uint64(LIVE_LIST_LENGTH); i++ {
+		assert.Nil(c.InsertHLSSegment(vProfile, i, "seg.ts", float64(i), 0))
+	}
+	assert.Nil(c.InsertHLSSegment(otherProfile, 1, "seg.ts", 2, 0))
+	assert.Equal(0.0, c.GetHLSMediaPlaylistStart(vProfile.Name))
+
+	// segments sliding out of the window move the start past them
+	next := uint64(LIVE_LIST_LENGTH) + 1
+	assert.Nil(c.InsertHLSSegment(vProfile, next, "seg.ts", 2, 0))
+	assert.Equal(1.0, c.GetHLSMediaPlaylistStart(vProfile.Name))
+	assert.Nil(c.InsertHLSSegment(vProfile, next+1, "seg.ts", 2, 0))
+	assert.Equal(3.0, c.GetHLSMediaPlaylistStart(vProfile.Name))
+
+	// other renditions aren't affected
+	assert.Equal(0.0, c.GetHLSMediaPlaylistStart(otherProfile.Name))
+}
+
 func TestCleanup(t *testing.T) {
 	vProfile := ffmpeg.P144p30fps16x9
 	hlsStrmID := MakeStreamID(RandomManifestID(), &vProfile)
@@ -166,7 +234,7 @@ func TestCleanup(t *testing.T) {
 	testData := []byte{1, 2, 3, 4}
 
 	c := NewBasicPlaylistManager(mid, osSession)
-	uri, err := c.GetOSSession().SaveData("testName", testData)
+	uri, err := c.GetOSSession().SaveData("testName", testData, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -30,6 +30,10 @@ type StreamParameters struct {
 	Format       ffmpeg.Format
 	OS           drivers.OSSession
 	Capabilities *Capabilities
+	// Source segments are uploaded in seqNo order
+	InOrderUploads bool
+	// Tenant the stream is counted against for the stream limits, if any
+	Tenant string
 }
 
 func (s *StreamParameters) StreamID() string {
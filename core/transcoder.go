@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -29,6 +30,10 @@ type LocalTranscoder struct {
 
 var WorkDir string
 
+// BitrateCeilings holds the maximum output bitrate in bits per second keyed by
+// profile name. Profiles with a higher nominal bitrate are encoded at the ceiling.
+var BitrateCeilings map[string]int
+
 func (lt *LocalTranscoder) Transcode(md *SegTranscodingMetadata) (*TranscodeData, error) {
 	// Set up in / out config
 	in := &ffmpeg.TranscodeOptionsIn{
@@ -51,10 +56,16 @@ func (lt *LocalTranscoder) Transcode(md *SegTranscodingMetadata) (*TranscodeData
 		// When orchestrator works as transcoder, `fname` will be relative path to file in local
 		// filesystem and will not contain seqNo in it. For that case `SegmentTranscoded` will
 		// be called in orchestrator.go
-		monitor.SegmentTranscoded(0, seqNo, time.Since(start), common.ProfilesNames(profiles))
+		monitor.SegmentTranscoded(0, seqNo, time.Since(start), common.ProfilesNames(profiles), "")
+		monitor.SegmentTranscodedOn("")
 	}
 
-	return resToTranscodeData(res, opts)
+	td, err := resToTranscodeData(res, opts)
+	if err != nil {
+		return nil, err
+	}
+	recordFramesDropped(res, opts, md.Duration)
+	return td, nil
 }
 
 func NewLocalTranscoder(workDir string) Transcoder {
@@ -74,23 +85,48 @@ func (nv *NvidiaTranscoder) Transcode(md *SegTranscodingMetadata) (*TranscodeDat
 		Device: nv.device,
 	}
 	out := profilesToTranscodeOptions(WorkDir, ffmpeg.Nvidia, md.Profiles)
+
+	_, seqNo, parseErr := parseURI(md.Fname)
+	start := time.Now()
+
 	res, err := nv.session.Transcode(in, out)
 	if err != nil {
 		return nil, err
 	}
-	return resToTranscodeData(res, out)
+
+	if monitor.Enabled && parseErr == nil {
+		// Same as for LocalTranscoder, only runs when working as a standalone transcoder
+		monitor.SegmentTranscoded(0, seqNo, time.Since(start), common.ProfilesNames(md.Profiles), nv.device)
+		monitor.SegmentTranscodedOn(nv.device)
+	}
+
+	td, err := resToTranscodeData(res, out)
+	if err != nil {
+		return nil, err
+	}
+	recordFramesDropped(res, out, md.Duration)
+	td.Device = nv.device
+	return td, nil
+}
+
+// TestSegmentDuration is the duration in seconds of the test segment
+const TestSegmentDuration = 0.2
+
+// TestSegmentData returns tiny MPEG-TS segment of TestSegmentDuration, five
+// 48x48 frames, that is cheap to transcode
+func TestSegmentData() ([]byte, error) {
+	z, err := gzip.NewReader(bytes.NewReader(testSegment))
+	if err != nil {
+		return nil, err
+	}
+	defer z.Close()
+	return ioutil.ReadAll(z)
 }
 
 // TestNvidiaTranscoder tries to transcode test segment on all the devices
 func TestNvidiaTranscoder(gpu string) error {
 	devices := strings.Split(gpu, ",")
-	b := bytes.NewReader(testSegment)
-	z, err := gzip.NewReader(b)
-	if err != nil {
-		return err
-	}
-	mp4testSeg, err := ioutil.ReadAll(z)
-	z.Close()
+	mp4testSeg, err := TestSegmentData()
 	if err != nil {
 		return err
 	}
@@ -167,12 +203,57 @@ func resToTranscodeData(res *ffmpeg.TranscodeResults, opts []ffmpeg.TranscodeOpt
 	}, nil
 }
 
+// frameDropTolerance is the number of missing output frames that aren't
+// counted as dropped, covering rounding of the segment duration
+const frameDropTolerance = 1
+
+// framesDropped returns the number of frames missing in the output of the
+// profile, given the segment duration and the number of decoded frames. Zero if
+// the expected number of frames is unknown.
+func framesDropped(profile ffmpeg.VideoProfile, dur time.Duration, decoded, encoded int) int {
+	if decoded == 0 {
+		// no video
+		return 0
+	}
+	// output keeps the source frame rate unless the profile sets it
+	expected := decoded
+	if profile.Framerate > 0 {
+		if dur <= 0 {
+			return 0
+		}
+		den := profile.FramerateDen
+		if den == 0 {
+			den = 1
+		}
+		expected = int(math.Round(dur.Seconds() * float64(profile.Framerate) / float64(den)))
+	}
+	if dropped := expected - encoded; dropped > frameDropTolerance {
+		return dropped
+	}
+	return 0
+}
+
+// recordFramesDropped records the frames dropped in each output of validated
+// transcode results
+func recordFramesDropped(res *ffmpeg.TranscodeResults, opts []ffmpeg.TranscodeOptions, dur time.Duration) {
+	for i, o := range opts {
+		dropped := framesDropped(o.Profile, dur, res.Decoded.Frames, res.Encoded[i].Frames)
+		if dropped == 0 {
+			continue
+		}
+		glog.Warningf("Transcoder dropped frames profile=%s dropped=%d encoded=%d dur=%v", o.Profile.Name, dropped, res.Encoded[i].Frames, dur)
+		if monitor.Enabled {
+			monitor.TranscodeFramesDropped(o.Profile.Name, dropped)
+		}
+	}
+}
+
 func profilesToTranscodeOptions(workDir string, accel ffmpeg.Acceleration, profiles []ffmpeg.VideoProfile) []ffmpeg.TranscodeOptions {
 	opts := make([]ffmpeg.TranscodeOptions, len(profiles), len(profiles))
 	for i := range profiles {
 		o := ffmpeg.TranscodeOptions{
 			Oname:        fmt.Sprintf("%s/out_%s.tempfile", workDir, common.RandName()),
-			Profile:      profiles[i],
+			Profile:      capProfileBitrate(profiles[i]),
 			Accel:        accel,
 			AudioEncoder: ffmpeg.ComponentOptions{Name: "copy"},
 		}
@@ -180,3 +261,76 @@ func profilesToTranscodeOptions(workDir string, accel ffmpeg.Acceleration, profi
 	}
 	return opts
 }
+
+func capProfileBitrate(profile ffmpeg.VideoProfile) ffmpeg.VideoProfile {
+	ceiling, ok := BitrateCeilings[profile.Name]
+	if !ok {
+		return profile
+	}
+	bitrate, err := parseBitrate(profile.Bitrate)
+	if err != nil {
+		glog.Errorf("Unable to parse bitrate for profile=%s bitrate=%s err=%v", profile.Name, profile.Bitrate, err)
+		return profile
+	}
+	if bitrate <= ceiling {
+		return profile
+	}
+	glog.V(common.DEBUG).Infof("Capping bitrate for profile=%s bitrate=%d ceiling=%d", profile.Name, bitrate, ceiling)
+	profile.Bitrate = strconv.Itoa(ceiling)
+	if monitor.Enabled {
+		monitor.ProfileBitrateCapped(profile.Name)
+	}
+	return profile
+}
+
+// parseBitrate converts bitrate strings like "600k", "1.5M" or "400000" to bits per second
+func parseBitrate(bitrate string) (int, error) {
+	mult := 1.0
+	s := strings.TrimSpace(bitrate)
+	switch {
+	case strings.HasSuffix(s, "k"), strings.HasSuffix(s, "K"):
+		mult = 1000
+		s = s[:len(s)-1]
+	case strings.HasSuffix(s, "M"):
+		mult = 1000000
+		s = s[:len(s)-1]
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	if v <= 0 {
+		return 0, fmt.Errorf("bitrate must be positive bitrate=%s", bitrate)
+	}
+	return int(v * mult), nil
+}
+
+// ParseBitrateCeilings parses a comma separated list of profile=bitrate pairs,
+// eg "P720p30fps16x9=3000k,P360p30fps16x9=800k". Ceilings for the predefined
+// profiles must be lower than the profile's nominal bitrate.
+func ParseBitrateCeilings(s string) (map[string]int, error) {
+	ceilings := make(map[string]int)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid bitrate ceiling=%s", pair)
+		}
+		name := strings.TrimSpace(kv[0])
+		ceiling, err := parseBitrate(kv[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid bitrate ceiling for profile=%s err=%v", name, err)
+		}
+		if p, ok := ffmpeg.VideoProfileLookup[name]; ok {
+			nominal, err := parseBitrate(p.Bitrate)
+			if err == nil && ceiling >= nominal {
+				return nil, fmt.Errorf("bitrate ceiling=%d for profile=%s is not lower than nominal bitrate=%d", ceiling, name, nominal)
+			}
+		}
+		ceilings[name] = ceiling
+	}
+	return ceilings, nil
+}
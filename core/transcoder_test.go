@@ -4,6 +4,7 @@ import (
 	"io/ioutil"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/livepeer/go-livepeer/common"
 	"github.com/livepeer/lpms/ffmpeg"
@@ -78,6 +79,15 @@ func TestNvidia_Transcoder(t *testing.T) {
 	}
 }
 
+func TestTestSegmentData(t *testing.T) {
+	data, err := TestSegmentData()
+	require.Nil(t, err)
+	// whole MPEG-TS packets
+	assert.NotEmpty(t, data)
+	assert.Zero(t, len(data)%188)
+	assert.Equal(t, byte(0x47), data[0])
+}
+
 func TestResToTranscodeData(t *testing.T) {
 	assert := assert.New(t)
 	require := require.New(t)
@@ -152,6 +162,38 @@ func TestResToTranscodeData(t *testing.T) {
 	assert.True(fileDNE(file2.Name()))
 }
 
+func TestFramesDropped(t *testing.T) {
+	assert := assert.New(t)
+	p30 := ffmpeg.P240p30fps16x9
+	source := p30
+	source.Framerate = 0
+	ntsc := p30
+	ntsc.Framerate, ntsc.FramerateDen = 30000, 1001
+
+	tests := []struct {
+		name    string
+		profile ffmpeg.VideoProfile
+		dur     time.Duration
+		decoded int
+		encoded int
+		dropped int
+	}{
+		{"all frames", p30, 2 * time.Second, 60, 60, 0},
+		{"frame-dropped segment", p30, 2 * time.Second, 60, 45, 15},
+		{"within tolerance", p30, 2 * time.Second, 60, 59, 0},
+		{"source rate lower than profile", p30, 2 * time.Second, 48, 60, 0},
+		{"fractional rate", ntsc, 2 * time.Second, 60, 60, 0},
+		{"fractional rate dropped", ntsc, 2 * time.Second, 60, 50, 10},
+		{"unknown duration", p30, 0, 60, 10, 0},
+		{"source rate kept", source, 0, 60, 60, 0},
+		{"source rate kept dropped", source, 2 * time.Second, 60, 30, 30},
+		{"no video", p30, 2 * time.Second, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		assert.Equal(tt.dropped, framesDropped(tt.profile, tt.dur, tt.decoded, tt.encoded), tt.name)
+	}
+}
+
 func TestProfilesToTranscodeOptions(t *testing.T) {
 	workDir := "foo"
 
@@ -199,6 +241,58 @@ func TestProfilesToTranscodeOptions(t *testing.T) {
 		assert.Equal(p, opts[i].Profile)
 		assert.Equal("copy", opts[i].AudioEncoder.Name)
 	}
+
+	// Test keyframe interval is passed to the encoder
+	gopProfile := ffmpeg.P144p30fps16x9
+	gopProfile.GOP = 2 * time.Second
+	opts = profilesToTranscodeOptions(workDir, ffmpeg.Software, []ffmpeg.VideoProfile{gopProfile, ffmpeg.P240p30fps16x9})
+	assert.Equal(2*time.Second, opts[0].Profile.GOP)
+	assert.Equal(time.Duration(0), opts[1].Profile.GOP)
+}
+
+func TestProfilesToTranscodeOptions_BitrateCeiling(t *testing.T) {
+	assert := assert.New(t)
+
+	defer func() { BitrateCeilings = nil }()
+	BitrateCeilings = map[string]int{
+		ffmpeg.P720p30fps16x9.Name: 3000000,
+		ffmpeg.P144p30fps16x9.Name: 1000000,
+	}
+
+	profiles := []ffmpeg.VideoProfile{ffmpeg.P720p30fps16x9, ffmpeg.P144p30fps16x9, ffmpeg.P360p30fps16x9}
+	opts := profilesToTranscodeOptions("foo", ffmpeg.Software, profiles)
+	assert.Equal(3, len(opts))
+	// nominal bitrate above the ceiling is capped
+	assert.Equal("3000000", opts[0].Profile.Bitrate)
+	assert.Equal(ffmpeg.P720p30fps16x9.Resolution, opts[0].Profile.Resolution)
+	// nominal bitrate below the ceiling is kept
+	assert.Equal(ffmpeg.P144p30fps16x9, opts[1].Profile)
+	// no ceiling for the profile
+	assert.Equal(ffmpeg.P360p30fps16x9, opts[2].Profile)
+	// original profiles are not modified
+	assert.Equal("4000k", profiles[0].Bitrate)
+}
+
+func TestParseBitrateCeilings(t *testing.T) {
+	assert := assert.New(t)
+
+	ceilings, err := ParseBitrateCeilings("P720p30fps16x9=3000k, P360p30fps16x9=0.8M,custom=500000")
+	assert.Nil(err)
+	assert.Equal(map[string]int{"P720p30fps16x9": 3000000, "P360p30fps16x9": 800000, "custom": 500000}, ceilings)
+
+	ceilings, err = ParseBitrateCeilings("")
+	assert.Nil(err)
+	assert.Len(ceilings, 0)
+
+	_, err = ParseBitrateCeilings("P720p30fps16x9")
+	assert.EqualError(err, "invalid bitrate ceiling=P720p30fps16x9")
+
+	_, err = ParseBitrateCeilings("P720p30fps16x9=-1k")
+	assert.EqualError(err, "invalid bitrate ceiling for profile=P720p30fps16x9 err=bitrate must be positive bitrate=-1k")
+
+	// ceiling must be lower than the nominal bitrate of a predefined profile
+	_, err = ParseBitrateCeilings("P720p30fps16x9=4000k")
+	assert.EqualError(err, "bitrate ceiling=4000000 for profile=P720p30fps16x9 is not lower than nominal bitrate=4000000")
 }
 
 func TestAudioCopy(t *testing.T) {
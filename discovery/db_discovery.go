@@ -2,10 +2,14 @@ package discovery
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/big"
+	"math/rand"
 	"net/url"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	ethcommon "github.com/ethereum/go-ethereum/common"
@@ -13,6 +17,7 @@ import (
 	"github.com/livepeer/go-livepeer/core"
 	"github.com/livepeer/go-livepeer/eth"
 	lpTypes "github.com/livepeer/go-livepeer/eth/types"
+	"github.com/livepeer/go-livepeer/monitor"
 	"github.com/livepeer/go-livepeer/net"
 	"github.com/livepeer/go-livepeer/pm"
 	"github.com/livepeer/go-livepeer/server"
@@ -20,24 +25,96 @@ import (
 	"github.com/golang/glog"
 )
 
-var cacheRefreshInterval = 1 * time.Hour
+// OrchInfoTTL is how long the cached orchestrator info is fresh for. The
+// info of all the orchestrators is requested again once it expires.
+var OrchInfoTTL = 1 * time.Hour
+
+// MaxConcurrentOrchProbes is the max number of orchestrators the DB pool cache
+// requests the info from at once when refreshing the cache. The rest waits for
+// a free slot within the same timeout. No limit if 0.
+var MaxConcurrentOrchProbes = 20
+
+// MaxOrchProbeFailures is the number of info requests in a row an orchestrator
+// can fail before it is left out of the selection as unhealthy, till its next
+// successful request. Never left out if 0.
+var MaxOrchProbeFailures = 3
+
+// MaxOrchInfoStaleness is how long the last known good info of the
+// orchestrator failing info requests is still used. It is left out only once
+// past both MaxOrchProbeFailures and MaxOrchInfoStaleness. Not used if 0.
+var MaxOrchInfoStaleness = 6 * time.Hour
+
+// Region is the region the orchestrators are preferably selected from. Only
+// the orchestrators of the region are selected if there are any, otherwise
+// the ones of unknown region. Not used if empty.
+var Region string
+
+// OrchRegions are the regions of the orchestrators by the host of their
+// service URI
+var OrchRegions map[string]string
+
+// DefaultOrchScheme is the scheme of the orchestrator service URIs which have
+// none, https or http
+var DefaultOrchScheme = "https"
+
+// OrchSchemeFallback makes the failed info requests to https orchestrators be
+// tried again over http. The orchestrators reachable over http only are then
+// reached over http, till it fails too. This disables TLS for all the requests
+// to these orchestrators, including the payments.
+var OrchSchemeFallback bool
+
 var getTicker = func() *time.Ticker {
-	return time.NewTicker(cacheRefreshInterval)
+	return time.NewTicker(OrchInfoTTL)
 }
 
 type ticketParamsValidator interface {
 	ValidateTicketParams(ticketParams *pm.TicketParams) error
 }
 
+// OrchestratorSource is the source of the on-chain orchestrators besides the
+// node's own, e.g. the orchestrators cached from another chain or registry.
+// Its store is kept up to date with the orchestrators by other means.
+type OrchestratorSource struct {
+	Store common.OrchestratorStore
+	RM    common.RoundsManager
+}
+
+// PoolChange is the change of the orchestrators eligible for the selection, by
+// their ETH addresses
+type PoolChange struct {
+	Added   []string
+	Removed []string
+}
+
 type DBOrchestratorPoolCache struct {
 	store                 common.OrchestratorStore
 	lpEth                 eth.LivepeerEthClient
 	ticketParamsValidator ticketParamsValidator
 	rm                    common.RoundsManager
 	bcast                 common.Broadcaster
+	sources               []OrchestratorSource // all the sources, the node's first
+	rand                  *rand.Rand           // of the selection, shared by the pools created for it
+
+	mu            sync.Mutex
+	httpOnly      map[string]bool // service URIs of the orchestrators which fell back to http
+	poolAddrs     map[string]bool // addresses of the orchestrators eligible for the selection
+	poolChangeCbs []func(PoolChange)
+	refreshing    *poolRefresh // on-demand refresh in progress, if any
+
+	// serializes on-demand refreshes and the polling of orchestrator info
+	refreshMu sync.Mutex
+}
+
+// poolRefresh is the on-demand refresh of the pool shared by the overlapping
+// callers of Refresh
+type poolRefresh struct {
+	done chan struct{}
+	err  error
 }
 
-func NewDBOrchestratorPoolCache(ctx context.Context, node *core.LivepeerNode, rm common.RoundsManager) (*DBOrchestratorPoolCache, error) {
+// NewDBOrchestratorPoolCache returns pool of the orchestrators of the node's
+// chain and of the other sources, if any
+func NewDBOrchestratorPoolCache(ctx context.Context, node *core.LivepeerNode, rm common.RoundsManager, sources ...OrchestratorSource) (*DBOrchestratorPoolCache, error) {
 	if node.Eth == nil {
 		return nil, fmt.Errorf("could not create DBOrchestratorPoolCache: LivepeerEthClient is nil")
 	}
@@ -48,6 +125,8 @@ func NewDBOrchestratorPoolCache(ctx context.Context, node *core.LivepeerNode, rm
 		ticketParamsValidator: node.Sender,
 		rm:                    rm,
 		bcast:                 core.NewBroadcaster(node),
+		sources:               append([]OrchestratorSource{{Store: node.Database, RM: rm}}, sources...),
+		rand:                  server.NewSelectionRand(),
 	}
 
 	if err := dbo.cacheTranscoderPool(); err != nil {
@@ -65,33 +144,96 @@ func NewDBOrchestratorPoolCache(ctx context.Context, node *core.LivepeerNode, rm
 	return dbo, nil
 }
 
-func (dbo *DBOrchestratorPoolCache) getURLs() ([]*url.URL, error) {
-	orchs, err := dbo.store.SelectOrchs(
-		&common.DBOrchFilter{
-			MaxPrice:     server.BroadcastCfg.MaxPrice(),
-			CurrentRound: dbo.rm.LastInitializedRound(),
-		},
-	)
+// getURLs returns the URIs of the orchestrators and the orchestrators by URI
+func (dbo *DBOrchestratorPoolCache) getURLs() ([]*url.URL, map[string]*common.DBOrch, error) {
+	orchs, _, err := dbo.selectOrchs(dbo.selectionFilter)
+	if err == nil && len(orchs) > 0 {
+		orchs = preferRegion(orchs, Region)
+	}
+	if monitor.Enabled {
+		// the pool has nothing to fall back on without the cached orchestrators
+		monitor.DiscoveryCacheLookup(err == nil && len(orchs) > 0 && infoFresh(orchs, time.Now()))
+	}
 	if err != nil || len(orchs) <= 0 {
-		return nil, err
+		return nil, nil, err
 	}
 
 	var uris []*url.URL
+	byURI := make(map[string]*common.DBOrch)
+	for _, orch := range orchs {
+		// stored URIs are validated, the ones which aren't were stored before
+		uri, err := dbo.orchURI(orch.ServiceURI)
+		if err != nil {
+			glog.Errorf("Orchestrator %v left out of the pool: %v", orch.EthereumAddr, err)
+			continue
+		}
+		uris = append(uris, uri)
+		byURI[uri.String()] = orch
+	}
+	return uris, byURI, nil
+}
+
+// infoFresh returns whether the info of all the orchestrators was requested
+// within OrchInfoTTL
+func infoFresh(orchs []*common.DBOrch, now time.Time) bool {
 	for _, orch := range orchs {
-		if uri, err := url.Parse(orch.ServiceURI); err == nil {
-			uris = append(uris, uri)
+		if orch.ProbedAt.IsZero() || now.Sub(orch.ProbedAt) >= OrchInfoTTL {
+			return false
 		}
 	}
-	return uris, nil
+	return true
+}
+
+// preferRegion returns the orchestrators of the region if there are any,
+// otherwise all the orchestrators
+func preferRegion(orchs []*common.DBOrch, region string) []*common.DBOrch {
+	if region == "" {
+		return orchs
+	}
+	var inRegion []*common.DBOrch
+	for _, orch := range orchs {
+		if orch.Region == region {
+			inRegion = append(inRegion, orch)
+		}
+	}
+	if len(inRegion) <= 0 {
+		return orchs
+	}
+	return inRegion
 }
 
 func (dbo *DBOrchestratorPoolCache) GetURLs() []*url.URL {
-	uris, _ := dbo.getURLs()
+	uris, _, _ := dbo.getURLs()
 	return uris
 }
 
 func (dbo *DBOrchestratorPoolCache) GetOrchestrators(numOrchestrators int, suspender common.Suspender, caps common.CapabilityComparator) ([]*net.OrchestratorInfo, error) {
-	uris, err := dbo.getURLs()
+	orchPool, err := dbo.orchestratorPool()
+	if err != nil || orchPool == nil {
+		return nil, err
+	}
+	orchInfos, err := orchPool.GetOrchestrators(numOrchestrators, suspender, caps)
+	if err != nil || len(orchInfos) <= 0 {
+		return nil, err
+	}
+
+	return orchInfos, nil
+}
+
+// GetOrchestratorsDetailed selects n orchestrators like GetOrchestrators does
+// and returns them with their details, to debug the selection
+func (dbo *DBOrchestratorPoolCache) GetOrchestratorsDetailed(n int) ([]OrchCandidate, error) {
+	orchPool, err := dbo.orchestratorPool()
+	if err != nil || orchPool == nil {
+		return nil, err
+	}
+	return orchPool.GetOrchestratorsDetailed(n)
+}
+
+// orchestratorPool returns pool of the orchestrators eligible for the
+// selection, nil if there are none
+func (dbo *DBOrchestratorPoolCache) orchestratorPool() (*orchestratorPool, error) {
+	uris, orchs, err := dbo.getURLs()
 	if err != nil || len(uris) <= 0 {
 		return nil, err
 	}
@@ -103,6 +245,9 @@ func (dbo *DBOrchestratorPoolCache) GetOrchestrators(numOrchestrators int, suspe
 				info.GetTranscoder(),
 				err,
 			)
+			if monitor.Enabled {
+				monitor.OrchestratorRejectedTicketParams()
+			}
 			return false
 		}
 
@@ -115,28 +260,92 @@ func (dbo *DBOrchestratorPoolCache) GetOrchestrators(numOrchestrators int, suspe
 				price.FloatString(3),
 				maxPrice.FloatString(3),
 			)
+			if monitor.Enabled {
+				monitor.OrchestratorRejectedPrice()
+			}
 			return false
 		}
 		return true
 	}
 
 	orchPool := NewOrchestratorPoolWithPred(dbo.bcast, uris, pred)
-	orchInfos, err := orchPool.GetOrchestrators(numOrchestrators, suspender, caps)
-	if err != nil || len(orchInfos) <= 0 {
-		return nil, err
+	orchPool.orchs = orchs
+	orchPool.rand = dbo.rand
+	return orchPool, nil
+}
+
+func (dbo *DBOrchestratorPoolCache) Size() int {
+	if Region != "" || len(dbo.sources) > 1 {
+		// orchestrators of unknown region may be left out, the ones of
+		// several sources are counted once
+		return len(dbo.GetURLs())
 	}
+	count, _ := dbo.store.OrchCount(dbo.selectionFilter(dbo.rm))
+	return count
+}
 
-	return orchInfos, nil
+// selectionFilter leaves out the orchestrators which can't be selected in the
+// current round of the source
+func (dbo *DBOrchestratorPoolCache) selectionFilter(rm common.RoundsManager) *common.DBOrchFilter {
+	return &common.DBOrchFilter{
+		MaxPrice:           server.BroadcastCfg.MaxPrice(),
+		CurrentRound:       rm.LastInitializedRound(),
+		ExcludeBlacklisted: true,
+		MaxProbeFailures:   int64(MaxOrchProbeFailures),
+		MaxStaleness:       MaxOrchInfoStaleness,
+		Region:             Region,
+	}
 }
 
-func (dbo *DBOrchestratorPoolCache) Size() int {
-	count, _ := dbo.store.OrchCount(
-		&common.DBOrchFilter{
-			MaxPrice:     server.BroadcastCfg.MaxPrice(),
-			CurrentRound: dbo.rm.LastInitializedRound(),
-		},
+// selectOrchs queries all the sources at once for the orchestrators passing
+// the filter of the source and merges them. Each orchestrator is returned
+// once, the first source's, along with the stores of all the sources it is
+// in by its address. Failing sources are skipped, error is returned only if
+// all of them fail.
+func (dbo *DBOrchestratorPoolCache) selectOrchs(filter func(rm common.RoundsManager) *common.DBOrchFilter) ([]*common.DBOrch, map[string][]common.OrchestratorStore, error) {
+	type result struct {
+		orchs []*common.DBOrch
+		err   error
+	}
+	results := make([]result, len(dbo.sources))
+	var wg sync.WaitGroup
+	for i, src := range dbo.sources {
+		wg.Add(1)
+		go func(i int, src OrchestratorSource) {
+			defer wg.Done()
+			orchs, err := src.Store.SelectOrchs(filter(src.RM))
+			results[i] = result{orchs: orchs, err: err}
+		}(i, src)
+	}
+	wg.Wait()
+
+	var (
+		orchs  []*common.DBOrch
+		stores = make(map[string][]common.OrchestratorStore)
+		err    error
 	)
-	return count
+	failed := 0
+	for i, res := range results {
+		if res.err != nil {
+			glog.Errorf("Could not retrieve orchestrators from source=%d: %v", i, res.err)
+			err = res.err
+			failed++
+			continue
+		}
+		for _, orch := range res.orchs {
+			if orch == nil {
+				continue
+			}
+			if _, ok := stores[orch.EthereumAddr]; !ok {
+				orchs = append(orchs, orch)
+			}
+			stores[orch.EthereumAddr] = append(stores[orch.EthereumAddr], dbo.sources[i].Store)
+		}
+	}
+	if failed == len(results) {
+		return nil, nil, err
+	}
+	return orchs, stores, nil
 }
 
 func (dbo *DBOrchestratorPoolCache) cacheTranscoderPool() error {
@@ -145,8 +354,15 @@ func (dbo *DBOrchestratorPoolCache) cacheTranscoderPool() error {
 		return fmt.Errorf("Could not refresh DB list of orchestrators: %v", err)
 	}
 
+	defer dbo.checkPoolChange()
 	for _, o := range orchestrators {
-		if err := dbo.store.UpdateOrch(ethOrchToDBOrch(o)); err != nil {
+		err := dbo.store.UpdateOrch(ethOrchToDBOrch(o))
+		if errors.Is(err, common.ErrInvalidServiceURI) {
+			glog.Errorf("Orchestrator %v left out of the pool: %v", o.Address.Hex(), err)
+			if monitor.Enabled {
+				monitor.OrchestratorInvalidURI()
+			}
+		} else if err != nil {
 			glog.Errorf("Unable to update orchestrator %v in DB: %v", o.Address.Hex(), err)
 		}
 	}
@@ -220,7 +436,7 @@ func (dbo *DBOrchestratorPoolCache) pollOrchestratorInfo(ctx context.Context) er
 			case <-ctx.Done():
 				return
 			case <-ticker.C:
-				if err := dbo.cacheDBOrchs(); err != nil {
+				if err := dbo.pollOnce(); err != nil {
 					glog.Errorf("unable to poll orchestrator info: %v", err)
 				}
 			}
@@ -230,77 +446,303 @@ func (dbo *DBOrchestratorPoolCache) pollOrchestratorInfo(ctx context.Context) er
 	return nil
 }
 
+// pollOnce refreshes the orchestrator info unless the on-demand refresh, which
+// refreshes it as well, is in progress
+func (dbo *DBOrchestratorPoolCache) pollOnce() error {
+	dbo.mu.Lock()
+	refreshing := dbo.refreshing != nil
+	dbo.mu.Unlock()
+	if refreshing {
+		return nil
+	}
+
+	dbo.refreshMu.Lock()
+	defer dbo.refreshMu.Unlock()
+	return dbo.cacheDBOrchs()
+}
+
+// Refresh updates the pool from the chain and refreshes the orchestrator info
+// right away instead of waiting for the next poll, e.g. after a new round is
+// initialized. Overlapping calls share a single refresh. Returns early with
+// the context error if the context is done before the refresh completes.
+func (dbo *DBOrchestratorPoolCache) Refresh(ctx context.Context) error {
+	dbo.mu.Lock()
+	r := dbo.refreshing
+	if r == nil {
+		r = &poolRefresh{done: make(chan struct{})}
+		dbo.refreshing = r
+		go func() {
+			r.err = dbo.refresh()
+			dbo.mu.Lock()
+			dbo.refreshing = nil
+			dbo.mu.Unlock()
+			close(r.done)
+		}()
+	}
+	dbo.mu.Unlock()
+
+	select {
+	case <-r.done:
+		return r.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (dbo *DBOrchestratorPoolCache) refresh() error {
+	dbo.refreshMu.Lock()
+	defer dbo.refreshMu.Unlock()
+	if err := dbo.cacheTranscoderPool(); err != nil {
+		return err
+	}
+	return dbo.cacheDBOrchs()
+}
+
 func (dbo *DBOrchestratorPoolCache) cacheDBOrchs() error {
-	orchs, err := dbo.store.SelectOrchs(
-		&common.DBOrchFilter{
-			CurrentRound: dbo.rm.LastInitializedRound(),
-		},
-	)
+	dbOrchs, stores, err := dbo.selectOrchs(func(rm common.RoundsManager) *common.DBOrchFilter {
+		return &common.DBOrchFilter{CurrentRound: rm.LastInitializedRound()}
+	})
 	if err != nil {
 		return fmt.Errorf("could not retrieve orchestrators from DB: %v", err)
 	}
+	numOrchs := len(dbOrchs)
 
-	resc, errc := make(chan *common.DBOrch), make(chan error)
+	// buffered, so the probes finishing after the timeout don't block
+	resc, errc := make(chan *common.DBOrch, numOrchs), make(chan *common.DBOrch, numOrchs)
 	ctx, cancel := context.WithTimeout(context.Background(), getOrchestratorsTimeoutLoop)
 	defer cancel()
 
-	getOrchInfo := func(dbOrch *common.DBOrch) {
-		uri, err := parseURI(dbOrch.ServiceURI)
+	getOrchInfo := func(dbOrch *common.DBOrch) error {
+		uri, err := dbo.orchURI(dbOrch.ServiceURI)
 		if err != nil {
-			errc <- err
-			return
+			return err
 		}
+		start := time.Now()
 		info, err := serverGetOrchInfo(ctx, dbo.bcast, uri)
-		if err != nil {
-			errc <- err
-			return
+		if err != nil && dbo.isHTTPOnly(dbOrch.ServiceURI) {
+			glog.Warningf("Could not get info of orch=%v over http, trying https next time", uri)
+			dbo.setHTTPOnly(dbOrch.ServiceURI, false)
+		} else if err != nil && OrchSchemeFallback && uri.Scheme == "https" {
+			glog.Warningf("Could not get info of orch=%v over https, falling back to http err=%v", uri, err)
+			httpURI := *uri
+			httpURI.Scheme = "http"
+			start = time.Now()
+			if info, err = serverGetOrchInfo(ctx, dbo.bcast, &httpURI); err == nil {
+				glog.Errorf("Orch=%v is reachable over http only, reaching it over http without TLS, including for payments", uri)
+				if monitor.Enabled {
+					monitor.OrchestratorSchemeDowngrade(uri.String())
+				}
+				dbo.setHTTPOnly(dbOrch.ServiceURI, true)
+			}
 		}
-		dbOrch.PricePerPixel, err = common.PriceToFixed(big.NewRat(info.PriceInfo.GetPricePerUnit(), info.PriceInfo.GetPixelsPerUnit()))
 		if err != nil {
-			errc <- err
-			return
+			return err
 		}
-		resc <- dbOrch
+		// in milliseconds, at least 1 as 0 means unknown
+		dbOrch.RTT = int64(time.Since(start)/time.Millisecond) + 1
+		dbOrch.PricePerPixel, err = common.PriceToFixed(big.NewRat(info.PriceInfo.GetPricePerUnit(), info.PriceInfo.GetPixelsPerUnit()))
+		return err
 	}
 
-	numOrchs := 0
-	for _, orch := range orchs {
-		if orch == nil {
-			continue
-		}
-		numOrchs++
-		go getOrchInfo(orch)
-
+	workers := MaxConcurrentOrchProbes
+	if workers <= 0 || workers > numOrchs {
+		workers = numOrchs
+	}
+	jobs := make(chan *common.DBOrch)
+	for i := 0; i < workers; i++ {
+		go func() {
+			for dbOrch := range jobs {
+				if err := getOrchInfo(dbOrch); err != nil {
+					glog.Errorln(err)
+					errc <- dbOrch
+					continue
+				}
+				resc <- dbOrch
+			}
+		}()
 	}
+	go func() {
+		defer close(jobs)
+		for _, dbOrch := range dbOrchs {
+			select {
+			case jobs <- dbOrch:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
 
+	defer dbo.recordHealthy()
+	defer dbo.checkPoolChange()
 	for i := 0; i < numOrchs; i++ {
 		select {
 		case res := <-resc:
-			if err := dbo.store.UpdateOrch(res); err != nil {
-				glog.Error("Error updating Orchestrator in DB: ", err)
+			// orchestrator of several sources is updated in all of them
+			for _, store := range stores[res.EthereumAddr] {
+				probed := &common.DBOrch{EthereumAddr: res.EthereumAddr, PricePerPixel: res.PricePerPixel, RTT: res.RTT}
+				if err := store.UpdateOrch(probed); err != nil {
+					glog.Error("Error updating Orchestrator in DB: ", err)
+				}
+				store.UpdateOrchProbe(res.EthereumAddr, true)
+			}
+		case failed := <-errc:
+			for _, store := range stores[failed.EthereumAddr] {
+				store.UpdateOrchProbe(failed.EthereumAddr, false)
 			}
-		case err := <-errc:
-			glog.Errorln(err)
 		case <-ctx.Done():
 			glog.Info("Done fetching orch info for orchestrators, context timeout")
-			break
+			return nil
 		}
 	}
 
 	return nil
 }
 
-func parseURI(addr string) (*url.URL, error) {
-	if !strings.HasPrefix(addr, "http") {
-		addr = "https://" + addr
+// OnPoolChange registers the callback called with the change of the
+// orchestrators eligible for the selection, whenever the refresh of the cache
+// changes them. The callbacks are called one after another and shouldn't block.
+func (dbo *DBOrchestratorPoolCache) OnPoolChange(cb func(PoolChange)) {
+	dbo.mu.Lock()
+	defer dbo.mu.Unlock()
+	dbo.poolChangeCbs = append(dbo.poolChangeCbs, cb)
+}
+
+// checkPoolChange compares the orchestrators eligible for the selection with
+// the ones after the previous refresh and calls the callbacks if they changed
+func (dbo *DBOrchestratorPoolCache) checkPoolChange() {
+	_, orchs, err := dbo.getURLs()
+	if err != nil {
+		glog.Error("Error checking orchestrator pool change: ", err)
+		return
+	}
+	addrs := make(map[string]bool)
+	for _, orch := range orchs {
+		addrs[orch.EthereumAddr] = true
+	}
+
+	dbo.mu.Lock()
+	var change PoolChange
+	for addr := range addrs {
+		if !dbo.poolAddrs[addr] {
+			change.Added = append(change.Added, addr)
+		}
 	}
-	uri, err := url.ParseRequestURI(addr)
+	for addr := range dbo.poolAddrs {
+		if !addrs[addr] {
+			change.Removed = append(change.Removed, addr)
+		}
+	}
+	// the first refresh sets up the pool the callbacks are notified about
+	// the changes of
+	initial := dbo.poolAddrs == nil
+	dbo.poolAddrs = addrs
+	cbs := dbo.poolChangeCbs
+	dbo.mu.Unlock()
+
+	if initial || (len(change.Added) <= 0 && len(change.Removed) <= 0) {
+		return
+	}
+	sort.Strings(change.Added)
+	sort.Strings(change.Removed)
+	glog.Infof("Orchestrator pool changed added=%v removed=%v", change.Added, change.Removed)
+	for _, cb := range cbs {
+		cb(change)
+	}
+}
+
+// recordHealthy records the number of orchestrators of the current round
+// which haven't failed MaxOrchProbeFailures info requests in a row
+func (dbo *DBOrchestratorPoolCache) recordHealthy() {
+	if !monitor.Enabled {
+		return
+	}
+	orchs, _, err := dbo.selectOrchs(func(rm common.RoundsManager) *common.DBOrchFilter {
+		return &common.DBOrchFilter{
+			CurrentRound:     rm.LastInitializedRound(),
+			MaxProbeFailures: int64(MaxOrchProbeFailures),
+		}
+	})
 	if err != nil {
-		return nil, fmt.Errorf("Could not parse orchestrator URI: %v", err)
+		glog.Error("Error counting healthy orchestrators: ", err)
+		return
+	}
+	monitor.HealthyOrchestrators(len(orchs))
+}
+
+// orchURI returns the URI the orchestrator of the stored service URI is
+// reached at, over http if it fell back to it
+func (dbo *DBOrchestratorPoolCache) orchURI(serviceURI string) (*url.URL, error) {
+	uri, err := url.Parse(serviceURI)
+	if err != nil {
+		return nil, err
+	}
+	if dbo.isHTTPOnly(serviceURI) {
+		uri.Scheme = "http"
 	}
 	return uri, nil
 }
 
+func (dbo *DBOrchestratorPoolCache) isHTTPOnly(serviceURI string) bool {
+	dbo.mu.Lock()
+	defer dbo.mu.Unlock()
+	return dbo.httpOnly[serviceURI]
+}
+
+func (dbo *DBOrchestratorPoolCache) setHTTPOnly(serviceURI string, httpOnly bool) {
+	dbo.mu.Lock()
+	defer dbo.mu.Unlock()
+	if !httpOnly {
+		delete(dbo.httpOnly, serviceURI)
+		return
+	}
+	if dbo.httpOnly == nil {
+		dbo.httpOnly = make(map[string]bool)
+	}
+	dbo.httpOnly[serviceURI] = true
+}
+
+// parseURI parses the service URI of the orchestrator, with DefaultOrchScheme
+// if it has no scheme
+func parseURI(addr string) (*url.URL, error) {
+	serviceURI, err := common.NormalizeServiceURI(addr, DefaultOrchScheme)
+	if err != nil {
+		return nil, fmt.Errorf("Could not parse orchestrator URI: %v", err)
+	}
+	return url.Parse(serviceURI)
+}
+
+// orchRegion returns the configured region of the orchestrator, empty if
+// unknown
+func orchRegion(serviceURI string) string {
+	uri, err := parseURI(serviceURI)
+	if err != nil {
+		return ""
+	}
+	return OrchRegions[strings.ToLower(uri.Hostname())]
+}
+
+// ParseOrchRegions parses comma separated host=region mappings of the
+// orchestrators
+func ParseOrchRegions(s string) (map[string]string, error) {
+	regions := make(map[string]string)
+	for _, kv := range strings.Split(s, ",") {
+		kv = strings.TrimSpace(kv)
+		if kv == "" {
+			continue
+		}
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid orchestrator region mapping=%s", kv)
+		}
+		host, region := strings.ToLower(strings.TrimSpace(parts[0])), strings.TrimSpace(parts[1])
+		if host == "" || region == "" {
+			return nil, fmt.Errorf("invalid orchestrator region mapping=%s", kv)
+		}
+		regions[host] = region
+	}
+	return regions, nil
+}
+
 func ethOrchToDBOrch(orch *lpTypes.Transcoder) *common.DBOrch {
 	if orch == nil {
 		return nil
@@ -311,6 +753,14 @@ func ethOrchToDBOrch(orch *lpTypes.Transcoder) *common.DBOrch {
 		EthereumAddr:      orch.Address.String(),
 		ActivationRound:   common.ToInt64(orch.ActivationRound),
 		DeactivationRound: common.ToInt64(orch.DeactivationRound),
+		Region:            orchRegion(orch.ServiceURI),
+	}
+	// invalid ones are left as they are, to be rejected when stored
+	if uri, err := parseURI(orch.ServiceURI); err == nil {
+		dbo.ServiceURI = uri.String()
+	}
+	if stake, err := common.BaseTokenAmountToFixed(orch.DelegatedStake); err == nil {
+		dbo.Stake = stake
 	}
 
 	return dbo
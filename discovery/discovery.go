@@ -3,36 +3,75 @@ package discovery
 import (
 	"container/heap"
 	"context"
+	"errors"
 	"math"
+	"math/big"
 	"math/rand"
 	"net/url"
+	"sort"
 	"time"
 
 	"github.com/livepeer/go-livepeer/common"
+	"github.com/livepeer/go-livepeer/core"
 	"github.com/livepeer/go-livepeer/monitor"
 	"github.com/livepeer/go-livepeer/net"
 	"github.com/livepeer/go-livepeer/server"
 
 	"github.com/golang/glog"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 var getOrchestratorsTimeoutLoop = 3 * time.Second
 
+// pickAllWindow is how long the selections picking from all the responses
+// wait for more of them once there are enough to pick from
+var pickAllWindow = 300 * time.Millisecond
+
 var serverGetOrchInfo = server.GetOrchestratorInfo
 
 type orchestratorPool struct {
 	uris  []*url.URL
 	pred  func(info *net.OrchestratorInfo) bool
 	bcast common.Broadcaster
+	orchs map[string]*common.DBOrch // recorded orchestrators by URI, for latency aware and stake weighted selections
+	rand  *rand.Rand                // of the selection, seeded by server.SelectionSeed
+}
+
+type orchestratorResponse struct {
+	info *net.OrchestratorInfo
+	orch *common.DBOrch // recorded orchestrator, nil if unknown
+	rtt  time.Duration  // round trip time of the info request
+	pos  int            // position of the orchestrator in the shuffled pool
+}
+
+// OrchCandidate is the orchestrator picked by the selection, with the details
+// the selection may have relied on
+type OrchCandidate struct {
+	Info *net.OrchestratorInfo
+	// PricePerPixel is the price in wei per pixel of the info, nil if the info
+	// has no price
+	PricePerPixel *big.Rat
+	// RTT is the round trip time of the info request made for the selection
+	RTT time.Duration
+	// Stake and ActivationRound are the recorded ones, 0 if the orchestrator
+	// isn't recorded
+	Stake           int64
+	ActivationRound int64
 }
 
+// noSuspender doesn't suspend any orchestrator
+type noSuspender struct{}
+
+func (noSuspender) Suspended(orch string) int { return 0 }
+
 func NewOrchestratorPool(bcast common.Broadcaster, uris []*url.URL) *orchestratorPool {
 	if len(uris) <= 0 {
 		// Should we return here?
 		glog.Error("Orchestrator pool does not have any URIs")
 	}
 
-	return &orchestratorPool{uris: uris, bcast: bcast}
+	return &orchestratorPool{uris: uris, bcast: bcast, rand: server.NewSelectionRand()}
 }
 
 func NewOrchestratorPoolWithPred(bcast common.Broadcaster, addresses []*url.URL, pred func(*net.OrchestratorInfo) bool) *orchestratorPool {
@@ -46,11 +85,40 @@ func (o *orchestratorPool) GetURLs() []*url.URL {
 }
 
 func (o *orchestratorPool) GetOrchestrators(numOrchestrators int, suspender common.Suspender, caps common.CapabilityComparator) ([]*net.OrchestratorInfo, error) {
+	resps, err := o.getOrchestrators(numOrchestrators, suspender, caps)
+	if err != nil {
+		return nil, err
+	}
+	if monitor.Enabled {
+		for _, age := range infoAges(resps, time.Now()) {
+			monitor.OrchestratorInfoAge(age)
+		}
+	}
+	infos := make([]*net.OrchestratorInfo, len(resps))
+	for i, res := range resps {
+		infos[i] = res.info
+	}
+	return infos, nil
+}
+
+// GetOrchestratorsDetailed selects n orchestrators capable of any legacy job,
+// like GetOrchestrators does, and returns them with their details, to debug
+// the selection
+func (o *orchestratorPool) GetOrchestratorsDetailed(n int) ([]OrchCandidate, error) {
+	resps, err := o.getOrchestrators(n, noSuspender{}, core.NewCapabilities(nil, nil))
+	if err != nil {
+		return nil, err
+	}
+	return orchCandidates(resps), nil
+}
+
+func (o *orchestratorPool) getOrchestrators(numOrchestrators int, suspender common.Suspender, caps common.CapabilityComparator) ([]orchestratorResponse, error) {
 	numAvailableOrchs := len(o.uris)
 	numOrchestrators = int(math.Min(float64(numAvailableOrchs), float64(numOrchestrators)))
+	start := time.Now()
 	ctx, cancel := context.WithTimeout(context.Background(), getOrchestratorsTimeoutLoop)
 
-	infoCh := make(chan *net.OrchestratorInfo, numAvailableOrchs)
+	infoCh := make(chan orchestratorResponse, numAvailableOrchs)
 	errCh := make(chan error, numAvailableOrchs)
 
 	// The following allows us to avoid capability check for jobs that only
@@ -79,49 +147,85 @@ func (o *orchestratorPool) GetOrchestrators(numOrchestrators int, suspender comm
 		}
 		return caps.CompatibleWith(info.Capabilities)
 	}
-	getOrchInfo := func(uri *url.URL) {
+	getOrchInfo := func(uri *url.URL, pos int) {
+		start := time.Now()
 		info, err := serverGetOrchInfo(ctx, o.bcast, uri)
 		if err == nil && isCompatible(info) {
-			infoCh <- info
+			infoCh <- orchestratorResponse{info: info, orch: o.orchs[uri.String()], rtt: time.Since(start), pos: pos}
 			return
 		}
 		if err != nil && monitor.Enabled {
-			monitor.LogDiscoveryError(err.Error())
+			monitor.LogDiscoveryError(discoveryErrorCode(err))
 		}
 		errCh <- err
 	}
 
 	// Shuffle into new slice to avoid mutating underlying data
 	uris := make([]*url.URL, numAvailableOrchs)
-	for i, j := range rand.Perm(numAvailableOrchs) {
+	for i, j := range o.rand.Perm(numAvailableOrchs) {
 		uris[i] = o.uris[j]
 	}
 
-	for _, uri := range uris {
-		go getOrchInfo(uri)
+	for i, uri := range uris {
+		go getOrchInfo(uri, i)
 	}
 
+	// price weighted, latency aware and stake weighted selections pick from all
+	// the responses received in time, but wait no longer than pickAllWindow
+	// once there are enough of them
+	priceWeighted := server.BroadcastCfg.PriceWeightedSelection()
+	latencyAware := server.BroadcastCfg.LatencyAwareSelection() && len(o.orchs) > 0
+	byStake := server.BroadcastCfg.SelectByStake() && len(o.orchs) > 0
+	pickAll := priceWeighted || latencyAware || byStake
 	timeout := false
 	infos := []*net.OrchestratorInfo{}
+	orchs := []*common.DBOrch{}
 	suspendedInfos := newSuspensionQueue()
+	byInfo := make(map[*net.OrchestratorInfo]orchestratorResponse)
 	nbResp := 0
-	for i := 0; i < numAvailableOrchs && len(infos) < numOrchestrators && !timeout; i++ {
+	var window <-chan time.Time
+	windowClosed := false
+	for i := 0; i < numAvailableOrchs && (pickAll || len(infos) < numOrchestrators) && !timeout && !windowClosed; i++ {
 		select {
-		case info := <-infoCh:
+		case res := <-infoCh:
+			info := res.info
+			byInfo[info] = res
 			if penalty := suspender.Suspended(info.Transcoder); penalty == 0 {
 				infos = append(infos, info)
+				orchs = append(orchs, res.orch)
 			} else {
 				heap.Push(suspendedInfos, &suspension{info, penalty})
 			}
 			nbResp++
+			if pickAll && window == nil && len(infos) >= numOrchestrators {
+				window = time.After(pickAllWindow)
+			}
 		case <-errCh:
 			nbResp++
+		case <-window:
+			windowClosed = true
 		case <-ctx.Done():
 			timeout = true
 		}
 	}
 	cancel()
 
+	if pickAll {
+		// pick in the order of the shuffled pool rather than the order the
+		// responses were received in, so that the selection is reproducible
+		// with server.SelectionSeed
+		sort.Slice(infos, func(i, j int) bool { return byInfo[infos[i]].pos < byInfo[infos[j]].pos })
+		for i, info := range infos {
+			orchs[i] = byInfo[info].orch
+		}
+	}
+	if priceWeighted {
+		infos = selectPriceWeighted(o.rand, infos, numOrchestrators)
+	} else if latencyAware {
+		infos = selectLowestRTT(infos, orchs, numOrchestrators)
+	} else if byStake {
+		infos = selectStakeWeighted(o.rand, infos, orchs, numOrchestrators)
+	}
 	if len(infos) < numOrchestrators {
 		diff := numOrchestrators - len(infos)
 		for i := 0; i < diff && suspendedInfos.Len() > 0; i++ {
@@ -132,9 +236,170 @@ func (o *orchestratorPool) GetOrchestrators(numOrchestrators int, suspender comm
 
 	glog.Infof("Done fetching orch info numOrch=%d responses=%d/%d timeout=%t",
 		len(infos), nbResp, len(uris), timeout)
-	return infos, nil
+	if monitor.Enabled {
+		monitor.DiscoveryDuration(time.Since(start), discoveryOutcome(infos, timeout))
+	}
+	resps := make([]orchestratorResponse, len(infos))
+	for i, info := range infos {
+		resps[i] = byInfo[info]
+	}
+	return resps, nil
+}
+
+// infoAges returns how old the cached info of each of the selected
+// orchestrators was, leaving out the ones without successful info request
+// recorded
+func infoAges(resps []orchestratorResponse, now time.Time) []time.Duration {
+	var ages []time.Duration
+	for _, res := range resps {
+		if res.orch == nil || res.orch.ProbedAt.IsZero() {
+			continue
+		}
+		ages = append(ages, now.Sub(res.orch.ProbedAt))
+	}
+	return ages
+}
+
+func orchCandidates(resps []orchestratorResponse) []OrchCandidate {
+	candidates := make([]OrchCandidate, len(resps))
+	for i, res := range resps {
+		c := OrchCandidate{Info: res.info, RTT: res.rtt}
+		if price := res.info.GetPriceInfo(); price.GetPixelsPerUnit() > 0 {
+			c.PricePerPixel = big.NewRat(price.PricePerUnit, price.PixelsPerUnit)
+		}
+		if res.orch != nil {
+			c.Stake = res.orch.Stake
+			c.ActivationRound = res.orch.ActivationRound
+		}
+		candidates[i] = c
+	}
+	return candidates
+}
+
+// selectPriceWeighted picks n of the infos at random, with the probability of
+// each proportional to the number of pixels per wei it transcodes. The first n
+// infos are picked, as they were received, if some of them have no price.
+func selectPriceWeighted(r *rand.Rand, infos []*net.OrchestratorInfo, n int) []*net.OrchestratorInfo {
+	if len(infos) <= n {
+		return infos
+	}
+	weights := make([]float64, len(infos))
+	for i, info := range infos {
+		price := info.GetPriceInfo()
+		if price.GetPricePerUnit() <= 0 || price.GetPixelsPerUnit() <= 0 {
+			return infos[:n]
+		}
+		weights[i] = float64(price.PixelsPerUnit) / float64(price.PricePerUnit)
+	}
+	return selectWeighted(r, infos, weights, n)
+}
+
+// selectStakeWeighted picks n of the infos at random, with the probability of
+// each proportional to the recorded stake of the orchestrator. Infos without
+// stake are picked last, in the order they were received.
+func selectStakeWeighted(r *rand.Rand, infos []*net.OrchestratorInfo, orchs []*common.DBOrch, n int) []*net.OrchestratorInfo {
+	if len(infos) <= n {
+		return infos
+	}
+	weights := make([]float64, len(infos))
+	for i, orch := range orchs {
+		if orch != nil && orch.Stake > 0 {
+			weights[i] = float64(orch.Stake)
+		}
+	}
+	return selectWeighted(r, infos, weights, n)
+}
+
+// selectWeighted picks n of the infos at random without replacement, with the
+// probability of each proportional to its weight. Infos of zero weight are
+// picked once there are no others, in the order they were received.
+func selectWeighted(rnd *rand.Rand, infos []*net.OrchestratorInfo, weights []float64, n int) []*net.OrchestratorInfo {
+	remaining := append([]*net.OrchestratorInfo(nil), infos...)
+	weights = append([]float64(nil), weights...)
+	selected := make([]*net.OrchestratorInfo, 0, n)
+	for len(selected) < n && len(remaining) > 0 {
+		var total float64
+		for _, w := range weights {
+			total += w
+		}
+		i := 0
+		if total > 0 {
+			r := rnd.Float64() * total
+			for ; i < len(weights)-1 && (weights[i] == 0 || r >= weights[i]); i++ {
+				r -= weights[i]
+			}
+		}
+		selected = append(selected, remaining[i])
+		remaining = append(remaining[:i], remaining[i+1:]...)
+		weights = append(weights[:i], weights[i+1:]...)
+	}
+	return selected
+}
+
+// selectLowestRTT picks n of the infos with the lowest recorded round trip
+// times, in ascending order. Infos with unknown round trip time are picked
+// last, in the order they were received.
+func selectLowestRTT(infos []*net.OrchestratorInfo, orchs []*common.DBOrch, n int) []*net.OrchestratorInfo {
+	rtt := func(i int) int64 {
+		if orchs[i] == nil {
+			return 0
+		}
+		return orchs[i].RTT
+	}
+	idx := make([]int, len(infos))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool {
+		ri, rj := rtt(idx[i]), rtt(idx[j])
+		return ri > 0 && (rj <= 0 || ri < rj)
+	})
+	if n > len(idx) {
+		n = len(idx)
+	}
+	selected := make([]*net.OrchestratorInfo, n)
+	for i := range selected {
+		selected[i] = infos[idx[i]]
+	}
+	return selected
 }
 
 func (o *orchestratorPool) Size() int {
 	return len(o.uris)
 }
+
+// discoveryErrorCode maps error returned by orchestrator info request to the
+// discovery error code, unknown errors are returned as is
+func discoveryErrorCode(err error) string {
+	if errors.Is(err, context.Canceled) {
+		return monitor.DiscoveryErrorCanceled
+	}
+	var se interface{ GRPCStatus() *status.Status }
+	if errors.As(err, &se) {
+		st := se.GRPCStatus()
+		switch {
+		case st.Code() == codes.Canceled:
+			return monitor.DiscoveryErrorCanceled
+		case st.Message() == core.ErrOrchCap.Error():
+			return monitor.DiscoveryErrorOrchestratorCapped
+		case st.Message() == core.ErrOrchBusy.Error():
+			return monitor.DiscoveryErrorOrchestratorBusy
+		case st.Message() == core.ErrOrchUnhealthy.Error():
+			return monitor.DiscoveryErrorOrchestratorUnhealthy
+		}
+	}
+	return err.Error()
+}
+
+// discoveryOutcome returns the outcome of the orchestrators selection. Timeout
+// takes precedence, as it means that the selection took the whole timeout
+// regardless of how many orchestrators responded in time
+func discoveryOutcome(infos []*net.OrchestratorInfo, timeout bool) string {
+	switch {
+	case timeout:
+		return monitor.DiscoveryOutcomeTimeout
+	case len(infos) == 0:
+		return monitor.DiscoveryOutcomeEmpty
+	}
+	return monitor.DiscoveryOutcomeSuccess
+}
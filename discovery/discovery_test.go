@@ -4,12 +4,16 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"math"
 	"math/big"
+	"math/rand"
 	"net/url"
 	"runtime"
+	"sort"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -19,12 +23,15 @@ import (
 	"github.com/livepeer/go-livepeer/core"
 	"github.com/livepeer/go-livepeer/eth"
 	lpTypes "github.com/livepeer/go-livepeer/eth/types"
+	"github.com/livepeer/go-livepeer/monitor"
 	"github.com/livepeer/go-livepeer/net"
 	"github.com/livepeer/go-livepeer/pm"
 	"github.com/livepeer/go-livepeer/server"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func TestNewDBOrchestratorPoolCache_NilEthClient_ReturnsError(t *testing.T) {
@@ -304,11 +311,227 @@ func TestNewDBOrchestratorPoolCache_TestURLs(t *testing.T) {
 
 	pool, err := NewDBOrchestratorPoolCache(ctx, node, &stubRoundsManager{})
 	require.NoError(err)
-	// bad URLs are inserted in the database but are not included in the working set, as there is no returnable query for getting their priceInfo
+	// bad URLs are rejected when stored, so they are neither counted nor included in the working set
 	// And if URL is updated it won't be picked up until next cache update
-	assert.Equal(3, pool.Size())
+	assert.Equal(2, pool.Size())
 	urls := pool.GetURLs()
 	assert.Len(urls, 2)
+	dbOrchs, err := dbh.SelectOrchs(nil)
+	require.Nil(err)
+	assert.Len(dbOrchs, 2)
+}
+
+func TestNewDBOrchestratorPoolCache_Blacklist(t *testing.T) {
+	dbh, dbraw, err := common.TempDB(t)
+	defer dbh.Close()
+	defer dbraw.Close()
+	require := require.New(t)
+	assert := assert.New(t)
+	require.Nil(err)
+
+	addresses := []string{"https://127.0.0.1:8936", "https://127.0.0.1:8937", "https://127.0.0.1:8938"}
+	orchestrators := StubOrchestrators(addresses)
+
+	oldOrchInfo := serverGetOrchInfo
+	defer func() { serverGetOrchInfo = oldOrchInfo }()
+	serverGetOrchInfo = func(ctx context.Context, bcast common.Broadcaster, orchestratorServer *url.URL) (*net.OrchestratorInfo, error) {
+		return &net.OrchestratorInfo{
+			Transcoder: orchestratorServer.String(),
+			PriceInfo:  &net.PriceInfo{PricePerUnit: 1, PixelsPerUnit: 1},
+		}, nil
+	}
+
+	sender := &pm.MockSender{}
+	sender.On("ValidateTicketParams", mock.Anything).Return(nil)
+	node := &core.LivepeerNode{
+		Database: dbh,
+		Eth: &eth.StubClient{
+			Orchestrators: orchestrators,
+		},
+		Sender: sender,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	pool, err := NewDBOrchestratorPoolCache(ctx, node, &stubRoundsManager{})
+	require.NoError(err)
+	assert.Equal(3, pool.Size())
+
+	// blacklisted orchestrator isn't selected
+	require.Nil(dbh.BlacklistOrch(orchestrators[1].Address.Hex(), time.Hour, ""))
+	assert.Equal(2, pool.Size())
+	urls := pool.GetURLs()
+	require.Len(urls, 2)
+	assert.NotEqual(addresses[1], urls[0].String())
+	assert.NotEqual(addresses[1], urls[1].String())
+	infos, err := pool.GetOrchestrators(3, newStubSuspender(), newStubCapabilities())
+	require.Nil(err)
+	require.Len(infos, 2)
+	for _, info := range infos {
+		assert.NotEqual(addresses[1], info.Transcoder)
+	}
+
+	// until removed from the blacklist
+	require.Nil(dbh.UnblacklistOrch(orchestrators[1].Address.Hex()))
+	assert.Equal(3, pool.Size())
+	infos, err = pool.GetOrchestrators(3, newStubSuspender(), newStubCapabilities())
+	require.Nil(err)
+	assert.Len(infos, 3)
+}
+
+func TestNewDBOrchestratorPoolCache_ProbeFailures(t *testing.T) {
+	dbh, dbraw, err := common.TempDB(t)
+	defer dbh.Close()
+	defer dbraw.Close()
+	require := require.New(t)
+	assert := assert.New(t)
+	require.Nil(err)
+
+	addresses := []string{"https://127.0.0.1:8936", "https://127.0.0.1:8937", "https://127.0.0.1:8938"}
+	orchestrators := StubOrchestrators(addresses)
+
+	var mu sync.Mutex
+	unreachable := addresses[1]
+	oldOrchInfo := serverGetOrchInfo
+	defer func() { serverGetOrchInfo = oldOrchInfo }()
+	serverGetOrchInfo = func(ctx context.Context, bcast common.Broadcaster, orchestratorServer *url.URL) (*net.OrchestratorInfo, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if orchestratorServer.String() == unreachable {
+			return nil, errors.New("unreachable")
+		}
+		return &net.OrchestratorInfo{
+			Transcoder: orchestratorServer.String(),
+			PriceInfo:  &net.PriceInfo{PricePerUnit: 1, PixelsPerUnit: 1},
+		}, nil
+	}
+	defer func(max int) { MaxOrchProbeFailures = max }(MaxOrchProbeFailures)
+	MaxOrchProbeFailures = 2
+
+	sender := &pm.MockSender{}
+	sender.On("ValidateTicketParams", mock.Anything).Return(nil)
+	node := &core.LivepeerNode{
+		Database: dbh,
+		Eth: &eth.StubClient{
+			Orchestrators: orchestrators,
+		},
+		Sender: sender,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// first failure
+	pool, err := NewDBOrchestratorPoolCache(ctx, node, &stubRoundsManager{})
+	require.NoError(err)
+	assert.Equal(3, pool.Size())
+	assert.Len(pool.GetURLs(), 3)
+
+	// unhealthy after the second failure in a row
+	require.Nil(pool.cacheDBOrchs())
+	assert.Equal(2, pool.Size())
+	urls := pool.GetURLs()
+	require.Len(urls, 2)
+	assert.NotEqual(unreachable, urls[0].String())
+	assert.NotEqual(unreachable, urls[1].String())
+	infos, err := pool.GetOrchestrators(3, newStubSuspender(), newStubCapabilities())
+	require.Nil(err)
+	assert.Len(infos, 2)
+
+	// still probed, healthy again after the successful probe
+	mu.Lock()
+	unreachable = ""
+	mu.Unlock()
+	require.Nil(pool.cacheDBOrchs())
+	assert.Equal(3, pool.Size())
+	assert.Len(pool.GetURLs(), 3)
+
+	// never left out if disabled
+	mu.Lock()
+	unreachable = addresses[0]
+	mu.Unlock()
+	MaxOrchProbeFailures = 0
+	for i := 0; i < 3; i++ {
+		require.Nil(pool.cacheDBOrchs())
+	}
+	assert.Equal(3, pool.Size())
+}
+
+func TestNewDBOrchestratorPoolCache_MaxOrchInfoStaleness(t *testing.T) {
+	dbh, dbraw, err := common.TempDB(t)
+	defer dbh.Close()
+	defer dbraw.Close()
+	require := require.New(t)
+	assert := assert.New(t)
+	require.Nil(err)
+
+	addresses := []string{"https://127.0.0.1:8936", "https://127.0.0.1:8937"}
+	var mu sync.Mutex
+	refreshFails := false
+	oldOrchInfo := serverGetOrchInfo
+	defer func() { serverGetOrchInfo = oldOrchInfo }()
+	serverGetOrchInfo = func(ctx context.Context, bcast common.Broadcaster, orchestratorServer *url.URL) (*net.OrchestratorInfo, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if refreshFails {
+			return nil, errors.New("unreachable")
+		}
+		return &net.OrchestratorInfo{
+			Transcoder: orchestratorServer.String(),
+			PriceInfo:  &net.PriceInfo{PricePerUnit: 1, PixelsPerUnit: 1},
+		}, nil
+	}
+	setRefreshFails := func(fails bool) {
+		mu.Lock()
+		refreshFails = fails
+		mu.Unlock()
+	}
+	defer func(max int, staleness time.Duration) {
+		MaxOrchProbeFailures, MaxOrchInfoStaleness = max, staleness
+	}(MaxOrchProbeFailures, MaxOrchInfoStaleness)
+	MaxOrchProbeFailures = 2
+	MaxOrchInfoStaleness = time.Hour
+
+	sender := &pm.MockSender{}
+	sender.On("ValidateTicketParams", mock.Anything).Return(nil)
+	node := &core.LivepeerNode{
+		Database: dbh,
+		Eth:      &eth.StubClient{Orchestrators: StubOrchestrators(addresses)},
+		Sender:   sender,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pool, err := NewDBOrchestratorPoolCache(ctx, node, &stubRoundsManager{})
+	require.NoError(err)
+	assert.Equal(2, pool.Size())
+
+	// last known good info is still used after the failed refreshes
+	setRefreshFails(true)
+	for i := 0; i < 3; i++ {
+		require.Nil(pool.cacheDBOrchs())
+	}
+	setRefreshFails(false)
+	assert.Equal(2, pool.Size())
+	infos, err := pool.GetOrchestrators(2, newStubSuspender(), newStubCapabilities())
+	require.Nil(err)
+	assert.Len(infos, 2)
+
+	// left out once it is too stale
+	_, err = dbraw.Exec("UPDATE orchestrators SET probedAt = datetime('now','-2 hours')")
+	require.Nil(err)
+	assert.Zero(pool.Size())
+	infos, err = pool.GetOrchestrators(2, newStubSuspender(), newStubCapabilities())
+	require.Nil(err)
+	assert.Empty(infos)
+
+	// left out right after the failures if not used
+	require.Nil(pool.cacheDBOrchs())
+	assert.Equal(2, pool.Size())
+	MaxOrchInfoStaleness = 0
+	setRefreshFails(true)
+	for i := 0; i < 2; i++ {
+		require.Nil(pool.cacheDBOrchs())
+	}
+	assert.Zero(pool.Size())
 }
 
 func TestNewDBOrchestratorPoolCache_TestURLs_Empty(t *testing.T) {
@@ -414,9 +637,9 @@ func TestNewDBOrchestorPoolCache_PollOrchestratorInfo(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	origCacheRefreshInterval := cacheRefreshInterval
-	cacheRefreshInterval = 200 * time.Millisecond
-	defer func() { cacheRefreshInterval = origCacheRefreshInterval }()
+	origOrchInfoTTL := OrchInfoTTL
+	OrchInfoTTL = 200 * time.Millisecond
+	defer func() { OrchInfoTTL = origOrchInfoTTL }()
 	pool, err := NewDBOrchestratorPoolCache(ctx, node, &stubRoundsManager{})
 	require.NoError(err)
 
@@ -450,6 +673,82 @@ func TestNewDBOrchestorPoolCache_PollOrchestratorInfo(t *testing.T) {
 	mu.Unlock()
 }
 
+func TestDBOrchestratorPoolCache_MaxConcurrentOrchProbes(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	var mu sync.Mutex
+	probing, maxProbing, probed := 0, 0, 0
+	delay := 10 * time.Millisecond
+	oldOrchInfo := serverGetOrchInfo
+	defer func() { serverGetOrchInfo = oldOrchInfo }()
+	serverGetOrchInfo = func(ctx context.Context, bcast common.Broadcaster, server *url.URL) (*net.OrchestratorInfo, error) {
+		mu.Lock()
+		probing++
+		if probing > maxProbing {
+			maxProbing = probing
+		}
+		d := delay
+		mu.Unlock()
+		time.Sleep(d)
+		mu.Lock()
+		probing--
+		probed++
+		mu.Unlock()
+		return &net.OrchestratorInfo{PriceInfo: &net.PriceInfo{PricePerUnit: 1, PixelsPerUnit: 1}}, nil
+	}
+	defer func(max int) { MaxConcurrentOrchProbes = max }(MaxConcurrentOrchProbes)
+	MaxConcurrentOrchProbes = 4
+
+	dbh, dbraw, err := common.TempDB(t)
+	defer dbh.Close()
+	defer dbraw.Close()
+	require.Nil(err)
+
+	var addresses []string
+	for i := 0; i < 30; i++ {
+		addresses = append(addresses, fmt.Sprintf("https://127.0.0.1:%d", 8936+i))
+	}
+	node := &core.LivepeerNode{
+		Database: dbh,
+		Eth:      &eth.StubClient{Orchestrators: StubOrchestrators(addresses)},
+		Sender:   &pm.MockSender{},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pool, err := NewDBOrchestratorPoolCache(ctx, node, &stubRoundsManager{})
+	require.NoError(err)
+
+	// all probed, at most 4 at once
+	mu.Lock()
+	assert.Equal(30, probed)
+	assert.Equal(4, maxProbing)
+	mu.Unlock()
+	dbOrchs, err := pool.store.SelectOrchs(nil)
+	require.Nil(err)
+	require.Len(dbOrchs, 30)
+	for _, o := range dbOrchs {
+		assert.Greater(o.RTT, int64(0))
+	}
+
+	// the queued probes don't extend the timeout
+	oldTimeout := getOrchestratorsTimeoutLoop
+	defer func() { getOrchestratorsTimeoutLoop = oldTimeout }()
+	getOrchestratorsTimeoutLoop = 50 * time.Millisecond
+	mu.Lock()
+	delay = 20 * time.Millisecond
+	probed = 0
+	mu.Unlock()
+	start := time.Now()
+	require.Nil(pool.cacheDBOrchs())
+	assert.Less(int64(time.Since(start)), int64(100*time.Millisecond))
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	assert.Less(probed, 30)
+	assert.Zero(probing)
+	mu.Unlock()
+}
+
 func TestNewOrchestratorPoolCache_GivenListOfOrchs_CreatesPoolCacheCorrectly(t *testing.T) {
 	addresses := stringsToURIs([]string{"https://127.0.0.1:8936", "https://127.0.0.1:8937", "https://127.0.0.1:8938"})
 	assert := assert.New(t)
@@ -1129,6 +1428,53 @@ func TestEthOrchToDBOrch(t *testing.T) {
 	assert.Equal(dbo.EthereumAddr, o.Address.Hex())
 	assert.Equal(dbo.ActivationRound, o.ActivationRound.Int64())
 	assert.Equal(dbo.DeactivationRound, int64(math.MaxInt64))
+	assert.Empty(dbo.Region)
+
+	// configured region by the service URI host
+	defer func() { OrchRegions = nil }()
+	OrchRegions = map[string]string{"orch.example.com": "eu"}
+	o.ServiceURI = "https://Orch.example.com:8935"
+	assert.Equal("eu", ethOrchToDBOrch(o).Region)
+	o.ServiceURI = "https://other.example.com:8935"
+	assert.Empty(ethOrchToDBOrch(o).Region)
+}
+
+func TestParseURI_DefaultOrchScheme(t *testing.T) {
+	assert := assert.New(t)
+
+	uri, err := parseURI("127.0.0.1:8935")
+	assert.Nil(err)
+	assert.Equal("https://127.0.0.1:8935", uri.String())
+
+	defer func() { DefaultOrchScheme = "https" }()
+	DefaultOrchScheme = "http"
+	uri, err = parseURI(" 127.0.0.1:8935")
+	assert.Nil(err)
+	assert.Equal("http://127.0.0.1:8935", uri.String())
+	uri, err = parseURI("https://127.0.0.1:8935")
+	assert.Nil(err)
+	assert.Equal("https://127.0.0.1:8935", uri.String())
+
+	// stored with the scheme
+	o := StubOrchestrators([]string{"127.0.0.1:8935"})[0]
+	assert.Equal("http://127.0.0.1:8935", ethOrchToDBOrch(o).ServiceURI)
+}
+
+func TestParseOrchRegions(t *testing.T) {
+	assert := assert.New(t)
+
+	regions, err := ParseOrchRegions("orch1.example.com=eu, ORCH2.example.com=us-east,127.0.0.1=eu,")
+	assert.Nil(err)
+	assert.Equal(map[string]string{"orch1.example.com": "eu", "orch2.example.com": "us-east", "127.0.0.1": "eu"}, regions)
+
+	regions, err = ParseOrchRegions("")
+	assert.Nil(err)
+	assert.Empty(regions)
+
+	for _, s := range []string{"orch1.example.com", "=eu", "orch1.example.com="} {
+		_, err = ParseOrchRegions(s)
+		assert.EqualError(err, "invalid orchestrator region mapping="+s)
+	}
 }
 
 func TestOrchestratorPool_GetOrchestrators(t *testing.T) {
@@ -1487,3 +1833,899 @@ func TestOrchestratorPool_Capabilities(t *testing.T) {
 	assert.Len(infos, 1)
 	assert.Equal(i4, infos[0])
 }
+
+func TestSelectPriceWeighted(t *testing.T) {
+	assert := assert.New(t)
+	r := rand.New(rand.NewSource(1))
+
+	info := func(transcoder string, pricePerUnit, pixelsPerUnit int64) *net.OrchestratorInfo {
+		return &net.OrchestratorInfo{
+			Transcoder: transcoder,
+			PriceInfo:  &net.PriceInfo{PricePerUnit: pricePerUnit, PixelsPerUnit: pixelsPerUnit},
+		}
+	}
+	infos := []*net.OrchestratorInfo{info("a", 1, 1), info("b", 2, 1), info("c", 8, 2)}
+
+	// picked with probability proportional to pixels per wei
+	counts := map[string]int{}
+	const iterations = 7000
+	for i := 0; i < iterations; i++ {
+		selected := selectPriceWeighted(r, infos, 1)
+		assert.Len(selected, 1)
+		counts[selected[0].Transcoder]++
+	}
+	assert.InDelta(4.0/7, float64(counts["a"])/iterations, 0.03)
+	assert.InDelta(2.0/7, float64(counts["b"])/iterations, 0.03)
+	assert.InDelta(1.0/7, float64(counts["c"])/iterations, 0.03)
+
+	// without replacement
+	selected := selectPriceWeighted(r, infos, 2)
+	assert.Len(selected, 2)
+	assert.NotEqual(selected[0].Transcoder, selected[1].Transcoder)
+	assert.Equal(infos, selectPriceWeighted(r, infos, 3))
+	assert.Equal(infos, selectPriceWeighted(r, infos, 4))
+
+	// orchestrators are picked in order received if some have no price
+	noPrice := append([]*net.OrchestratorInfo{}, infos[0], infos[1], &net.OrchestratorInfo{Transcoder: "d"})
+	for i := 0; i < 10; i++ {
+		assert.Equal(noPrice[:2], selectPriceWeighted(r, noPrice, 2))
+	}
+	free := append([]*net.OrchestratorInfo{}, infos[0], infos[1], info("e", 0, 1))
+	assert.Equal(free[:1], selectPriceWeighted(r, free, 1))
+}
+
+func TestOrchestratorPool_GetOrchestrators_PriceWeighted(t *testing.T) {
+	assert := assert.New(t)
+
+	addresses := stringsToURIs([]string{"https://127.0.0.1:8936", "https://127.0.0.1:8937", "https://127.0.0.1:8938"})
+	prices := map[string]int64{addresses[0].String(): 1000, addresses[1].String(): 1, addresses[2].String(): 1000}
+
+	wg := sync.WaitGroup{}
+	oldOrchInfo := serverGetOrchInfo
+	defer func() { wg.Wait(); serverGetOrchInfo = oldOrchInfo }()
+	serverGetOrchInfo = func(ctx context.Context, bcast common.Broadcaster, server *url.URL) (*net.OrchestratorInfo, error) {
+		defer wg.Done()
+		return &net.OrchestratorInfo{
+			Transcoder: server.String(),
+			PriceInfo:  &net.PriceInfo{PricePerUnit: prices[server.String()], PixelsPerUnit: 1},
+		}, nil
+	}
+	defer server.BroadcastCfg.SetPriceWeightedSelection(false)
+	server.BroadcastCfg.SetPriceWeightedSelection(true)
+
+	pool := NewOrchestratorPool(nil, addresses)
+	cheapest := 0
+	for i := 0; i < 100; i++ {
+		wg.Add(len(addresses))
+		res, err := pool.GetOrchestrators(1, newStubSuspender(), newStubCapabilities())
+		assert.Nil(err)
+		assert.Len(res, 1)
+		if res[0].Transcoder == addresses[1].String() {
+			cheapest++
+		}
+	}
+	// probability of picking the cheapest is 1000/1002
+	assert.Greater(cheapest, 90)
+}
+
+func TestOrchestratorPool_GetOrchestrators_PickAllWindow(t *testing.T) {
+	assert := assert.New(t)
+
+	addresses := stringsToURIs([]string{"https://127.0.0.1:8936", "https://127.0.0.1:8937", "https://127.0.0.1:8938"})
+	slow := addresses[2].String()
+
+	wg := sync.WaitGroup{}
+	oldOrchInfo := serverGetOrchInfo
+	defer func() { wg.Wait(); serverGetOrchInfo = oldOrchInfo }()
+	serverGetOrchInfo = func(ctx context.Context, bcast common.Broadcaster, server *url.URL) (*net.OrchestratorInfo, error) {
+		defer wg.Done()
+		if server.String() == slow {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return &net.OrchestratorInfo{
+			Transcoder: server.String(),
+			PriceInfo:  &net.PriceInfo{PricePerUnit: 1, PixelsPerUnit: 1},
+		}, nil
+	}
+	oldWindow := pickAllWindow
+	defer func() { pickAllWindow = oldWindow }()
+	pickAllWindow = 10 * time.Millisecond
+	defer server.BroadcastCfg.SetPriceWeightedSelection(false)
+	server.BroadcastCfg.SetPriceWeightedSelection(true)
+
+	// selection doesn't wait for the slow orchestrator once there are enough
+	// responses to pick from
+	pool := NewOrchestratorPool(nil, addresses)
+	wg.Add(len(addresses))
+	start := time.Now()
+	res, err := pool.GetOrchestrators(1, newStubSuspender(), newStubCapabilities())
+	assert.Nil(err)
+	assert.Len(res, 1)
+	assert.NotEqual(slow, res[0].Transcoder)
+	assert.Less(int64(time.Since(start)), int64(getOrchestratorsTimeoutLoop/2))
+
+	// it waits for the responses while there aren't enough of them
+	wg.Add(len(addresses))
+	start = time.Now()
+	res, err = pool.GetOrchestrators(3, newStubSuspender(), newStubCapabilities())
+	assert.Nil(err)
+	assert.Len(res, 2)
+	assert.GreaterOrEqual(int64(time.Since(start)), int64(getOrchestratorsTimeoutLoop))
+}
+
+func TestOrchestratorPool_SelectionSeed(t *testing.T) {
+	assert := assert.New(t)
+
+	addresses := stringsToURIs([]string{"https://127.0.0.1:8936", "https://127.0.0.1:8937", "https://127.0.0.1:8938", "https://127.0.0.1:8939"})
+
+	wg := sync.WaitGroup{}
+	oldOrchInfo := serverGetOrchInfo
+	defer func() { wg.Wait(); serverGetOrchInfo = oldOrchInfo }()
+	serverGetOrchInfo = func(ctx context.Context, bcast common.Broadcaster, server *url.URL) (*net.OrchestratorInfo, error) {
+		defer wg.Done()
+		return &net.OrchestratorInfo{
+			Transcoder: server.String(),
+			PriceInfo:  &net.PriceInfo{PricePerUnit: 1, PixelsPerUnit: 1},
+		}, nil
+	}
+	defer server.BroadcastCfg.SetPriceWeightedSelection(false)
+	server.BroadcastCfg.SetPriceWeightedSelection(true)
+	oldSeed := server.SelectionSeed
+	defer func() { server.SelectionSeed = oldSeed }()
+	server.SelectionSeed = 42
+
+	// pools seeded alike pick the same orchestrators
+	selections := func() []string {
+		pool := NewOrchestratorPool(nil, addresses)
+		var picked []string
+		for i := 0; i < 20; i++ {
+			wg.Add(len(addresses))
+			res, err := pool.GetOrchestrators(1, newStubSuspender(), newStubCapabilities())
+			assert.Nil(err)
+			assert.Len(res, 1)
+			picked = append(picked, res[0].Transcoder)
+		}
+		return picked
+	}
+	picked := selections()
+	assert.Equal(picked, selections())
+	seen := map[string]bool{}
+	for _, p := range picked {
+		seen[p] = true
+	}
+	assert.Greater(len(seen), 1)
+}
+
+func TestSelectLowestRTT(t *testing.T) {
+	assert := assert.New(t)
+
+	infos := []*net.OrchestratorInfo{{Transcoder: "a"}, {Transcoder: "b"}, {Transcoder: "c"}, {Transcoder: "d"}}
+	orchs := []*common.DBOrch{nil, {RTT: 30}, {}, {RTT: 10}}
+
+	// lowest first, unknown last in the order received
+	assert.Equal([]*net.OrchestratorInfo{infos[3], infos[1], infos[0], infos[2]}, selectLowestRTT(infos, orchs, 4))
+	assert.Equal([]*net.OrchestratorInfo{infos[3], infos[1], infos[0], infos[2]}, selectLowestRTT(infos, orchs, 5))
+	assert.Equal([]*net.OrchestratorInfo{infos[3]}, selectLowestRTT(infos, orchs, 1))
+	assert.Equal(infos[:2], selectLowestRTT(infos, make([]*common.DBOrch, 4), 2))
+	assert.Empty(selectLowestRTT(nil, nil, 2))
+}
+
+func TestSelectStakeWeighted(t *testing.T) {
+	assert := assert.New(t)
+	r := rand.New(rand.NewSource(1))
+
+	infos := []*net.OrchestratorInfo{{Transcoder: "a"}, {Transcoder: "b"}, {Transcoder: "c"}, {Transcoder: "d"}}
+	orchs := []*common.DBOrch{{Stake: 100}, {Stake: 300}, nil, {}}
+
+	// picked with probability proportional to stake
+	counts := map[string]int{}
+	const iterations = 4000
+	for i := 0; i < iterations; i++ {
+		selected := selectStakeWeighted(r, infos, orchs, 1)
+		assert.Len(selected, 1)
+		counts[selected[0].Transcoder]++
+	}
+	assert.InDelta(0.25, float64(counts["a"])/iterations, 0.03)
+	assert.InDelta(0.75, float64(counts["b"])/iterations, 0.03)
+
+	// without replacement, orchestrators without stake last in the order received
+	for i := 0; i < 10; i++ {
+		selected := selectStakeWeighted(r, infos, orchs, 3)
+		assert.ElementsMatch(infos[:2], selected[:2])
+		assert.Equal(infos[2], selected[2])
+	}
+	assert.Equal(infos, selectStakeWeighted(r, infos, orchs, 4))
+	assert.Equal(infos[2:3], selectStakeWeighted(r, infos[2:], orchs[2:], 1))
+}
+
+func TestCachedPool_GetOrchestrators_LatencyAware(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	addresses := []string{"https://127.0.0.1:8936", "https://127.0.0.1:8937", "https://127.0.0.1:8938"}
+	delays := map[string]time.Duration{addresses[0]: 60 * time.Millisecond, addresses[1]: 0, addresses[2]: 30 * time.Millisecond}
+	wg := sync.WaitGroup{}
+	oldOrchInfo := serverGetOrchInfo
+	defer func() { wg.Wait(); serverGetOrchInfo = oldOrchInfo }()
+	serverGetOrchInfo = func(ctx context.Context, bcast common.Broadcaster, server *url.URL) (*net.OrchestratorInfo, error) {
+		wg.Add(1)
+		defer wg.Done()
+		time.Sleep(delays[server.String()])
+		return &net.OrchestratorInfo{
+			Transcoder: server.String(),
+			PriceInfo:  &net.PriceInfo{PricePerUnit: 1, PixelsPerUnit: 1},
+		}, nil
+	}
+
+	dbh, dbraw, err := common.TempDB(t)
+	defer dbh.Close()
+	defer dbraw.Close()
+	require.Nil(err)
+
+	sender := &pm.MockSender{}
+	sender.On("ValidateTicketParams", mock.Anything).Return(nil)
+	node := &core.LivepeerNode{
+		Database: dbh,
+		Eth: &eth.StubClient{
+			Orchestrators: StubOrchestrators(addresses),
+			TotalStake:    big.NewInt(0),
+		},
+		Sender: sender,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pool, err := NewDBOrchestratorPoolCache(ctx, node, &stubRoundsManager{})
+	require.NoError(err)
+
+	// round trip times are recorded when polling the orchestrators
+	dbOrchs, err := pool.store.SelectOrchs(nil)
+	require.Nil(err)
+	require.Len(dbOrchs, 3)
+	rtts := map[string]int64{}
+	for _, o := range dbOrchs {
+		assert.Greater(o.RTT, int64(0))
+		rtts[o.ServiceURI] = o.RTT
+	}
+	assert.Less(rtts[addresses[1]], rtts[addresses[2]])
+	assert.Less(rtts[addresses[2]], rtts[addresses[0]])
+
+	// first to respond without latency aware selection
+	delays = map[string]time.Duration{addresses[0]: 0, addresses[1]: 60 * time.Millisecond, addresses[2]: 60 * time.Millisecond}
+	infos, err := pool.GetOrchestrators(1, newStubSuspender(), newStubCapabilities())
+	require.Nil(err)
+	require.Len(infos, 1)
+	assert.Equal(addresses[0], infos[0].Transcoder)
+
+	// lowest recorded round trip time first
+	defer server.BroadcastCfg.SetLatencyAwareSelection(false)
+	server.BroadcastCfg.SetLatencyAwareSelection(true)
+	infos, err = pool.GetOrchestrators(2, newStubSuspender(), newStubCapabilities())
+	require.Nil(err)
+	require.Len(infos, 2)
+	assert.Equal(addresses[1], infos[0].Transcoder)
+	assert.Equal(addresses[2], infos[1].Transcoder)
+}
+
+func TestCachedPool_GetOrchestrators_SelectByStake(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	addresses := []string{"https://127.0.0.1:8936", "https://127.0.0.1:8937", "https://127.0.0.1:8938"}
+	prices := map[string]int64{addresses[0]: 1, addresses[1]: 1, addresses[2]: 1000}
+	oldOrchInfo := serverGetOrchInfo
+	defer func() { serverGetOrchInfo = oldOrchInfo }()
+	serverGetOrchInfo = func(ctx context.Context, bcast common.Broadcaster, server *url.URL) (*net.OrchestratorInfo, error) {
+		return &net.OrchestratorInfo{
+			Transcoder: server.String(),
+			PriceInfo:  &net.PriceInfo{PricePerUnit: prices[server.String()], PixelsPerUnit: 1},
+		}, nil
+	}
+
+	dbh, dbraw, err := common.TempDB(t)
+	defer dbh.Close()
+	defer dbraw.Close()
+	require.Nil(err)
+
+	// stake from the transcoder pool
+	orchestrators := StubOrchestrators(addresses)
+	token := new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
+	for i, o := range orchestrators {
+		o.DelegatedStake = new(big.Int).Mul(big.NewInt(int64([]int{1, 99, 1000}[i])), token)
+	}
+	sender := &pm.MockSender{}
+	sender.On("ValidateTicketParams", mock.Anything).Return(nil)
+	node := &core.LivepeerNode{
+		Database: dbh,
+		Eth:      &eth.StubClient{Orchestrators: orchestrators},
+		Sender:   sender,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pool, err := NewDBOrchestratorPoolCache(ctx, node, &stubRoundsManager{})
+	require.NoError(err)
+
+	dbOrchs, err := pool.store.SelectOrchs(nil)
+	require.Nil(err)
+	require.Len(dbOrchs, 3)
+	for _, o := range dbOrchs {
+		assert.Greater(o.Stake, int64(0))
+	}
+
+	// the most staked orchestrator is too expensive
+	defer server.BroadcastCfg.SetMaxPrice(nil)
+	server.BroadcastCfg.SetMaxPrice(big.NewRat(10, 1))
+	defer server.BroadcastCfg.SetSelectByStake(false)
+	server.BroadcastCfg.SetSelectByStake(true)
+	counts := map[string]int{}
+	for i := 0; i < 100; i++ {
+		infos, err := pool.GetOrchestrators(1, newStubSuspender(), newStubCapabilities())
+		require.Nil(err)
+		require.Len(infos, 1)
+		counts[infos[0].Transcoder]++
+	}
+	assert.Zero(counts[addresses[2]])
+	// probability of picking the more staked one is 99/100
+	assert.Greater(counts[addresses[1]], 90)
+}
+
+func TestCachedPool_GetOrchestrators_Region(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	addresses := []string{"https://127.0.0.1:8936", "https://127.0.0.2:8936", "https://127.0.0.3:8936"}
+	oldOrchInfo := serverGetOrchInfo
+	defer func() { serverGetOrchInfo = oldOrchInfo }()
+	serverGetOrchInfo = func(ctx context.Context, bcast common.Broadcaster, server *url.URL) (*net.OrchestratorInfo, error) {
+		return &net.OrchestratorInfo{
+			Transcoder: server.String(),
+			PriceInfo:  &net.PriceInfo{PricePerUnit: 1, PixelsPerUnit: 1},
+		}, nil
+	}
+	defer func() { Region, OrchRegions = "", nil }()
+	OrchRegions = map[string]string{"127.0.0.1": "eu", "127.0.0.2": "us"}
+
+	dbh, dbraw, err := common.TempDB(t)
+	defer dbh.Close()
+	defer dbraw.Close()
+	require.Nil(err)
+	sender := &pm.MockSender{}
+	sender.On("ValidateTicketParams", mock.Anything).Return(nil)
+	node := &core.LivepeerNode{
+		Database: dbh,
+		Eth:      &eth.StubClient{Orchestrators: StubOrchestrators(addresses)},
+		Sender:   sender,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pool, err := NewDBOrchestratorPoolCache(ctx, node, &stubRoundsManager{})
+	require.NoError(err)
+
+	transcoders := func() []string {
+		infos, err := pool.GetOrchestrators(len(addresses), newStubSuspender(), newStubCapabilities())
+		require.Nil(err)
+		var res []string
+		for _, info := range infos {
+			res = append(res, info.Transcoder)
+		}
+		return res
+	}
+
+	// any region if not set
+	assert.Equal(3, pool.Size())
+	assert.ElementsMatch(addresses, transcoders())
+
+	// only the orchestrators of the region if there are any
+	Region = "eu"
+	assert.Equal(1, pool.Size())
+	assert.Equal([]string{addresses[0]}, transcoders())
+
+	// otherwise the ones of unknown region
+	Region = "ap"
+	assert.Equal(1, pool.Size())
+	assert.Equal([]string{addresses[2]}, transcoders())
+}
+
+type failingOrchStore struct {
+	common.OrchestratorStore
+}
+
+func (s *failingOrchStore) SelectOrchs(filter *common.DBOrchFilter) ([]*common.DBOrch, error) {
+	return nil, errors.New("source unavailable")
+}
+
+func TestNewDBOrchestratorPoolCache_Sources(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	addresses := []string{"https://127.0.0.1:8936", "https://127.0.0.1:8937"}
+	otherAddr := "https://127.0.0.2:8936"
+	oldOrchInfo := serverGetOrchInfo
+	defer func() { serverGetOrchInfo = oldOrchInfo }()
+	serverGetOrchInfo = func(ctx context.Context, bcast common.Broadcaster, server *url.URL) (*net.OrchestratorInfo, error) {
+		return &net.OrchestratorInfo{
+			Transcoder: server.String(),
+			PriceInfo:  &net.PriceInfo{PricePerUnit: 2, PixelsPerUnit: 1},
+		}, nil
+	}
+
+	dbh, dbraw, err := common.TempDB(t)
+	defer dbh.Close()
+	defer dbraw.Close()
+	require.Nil(err)
+	otherDBh, err := common.InitDB(fmt.Sprintf("file:%s_other?mode=memory&cache=shared", t.Name()))
+	require.Nil(err)
+	defer otherDBh.Close()
+
+	// the other source has one of the node's orchestrators and one of its own
+	orchestrators := StubOrchestrators(addresses)
+	sharedAddr := orchestrators[0].Address.String()
+	require.Nil(otherDBh.UpdateOrch(common.NewDBOrch(sharedAddr, addresses[0], 0, 0, 0, 0)))
+	otherEthAddr := StubOrchestrators([]string{otherAddr})[0].Address.String()
+	require.Nil(otherDBh.UpdateOrch(common.NewDBOrch(otherEthAddr, otherAddr, 0, 0, 0, 0)))
+
+	sender := &pm.MockSender{}
+	sender.On("ValidateTicketParams", mock.Anything).Return(nil)
+	node := &core.LivepeerNode{
+		Database: dbh,
+		Eth:      &eth.StubClient{Orchestrators: orchestrators},
+		Sender:   sender,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pool, err := NewDBOrchestratorPoolCache(ctx, node, &stubRoundsManager{},
+		OrchestratorSource{Store: &failingOrchStore{}, RM: &stubRoundsManager{}},
+		OrchestratorSource{Store: otherDBh, RM: &stubRoundsManager{}},
+	)
+	require.NoError(err)
+
+	// failing source is skipped, shared orchestrator is returned once
+	var uris []string
+	for _, uri := range pool.GetURLs() {
+		uris = append(uris, uri.String())
+	}
+	assert.ElementsMatch(append(addresses, otherAddr), uris)
+	assert.Equal(3, pool.Size())
+
+	// probe results are stored in all the sources of the orchestrator
+	otherOrchs, err := otherDBh.SelectOrchs(nil)
+	require.Nil(err)
+	require.Len(otherOrchs, 2)
+	for _, o := range otherOrchs {
+		assert.Equal(int64(2000), o.PricePerPixel)
+		assert.Zero(o.ProbeFailures)
+		assert.False(o.ProbedAt.IsZero())
+	}
+	shared, err := dbh.SelectOrchs(&common.DBOrchFilter{Addresses: []ethcommon.Address{orchestrators[0].Address}})
+	require.Nil(err)
+	require.Len(shared, 1)
+	assert.Equal(int64(2000), shared[0].PricePerPixel)
+
+	// nothing to select from if all the sources fail
+	pool.sources = []OrchestratorSource{{Store: &failingOrchStore{}, RM: &stubRoundsManager{}}}
+	assert.Error(pool.cacheDBOrchs())
+	assert.Empty(pool.GetURLs())
+}
+
+func TestInfoFresh(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+	fresh := &common.DBOrch{ProbedAt: now.Add(-time.Minute)}
+
+	assert.True(infoFresh([]*common.DBOrch{fresh}, now))
+	assert.True(infoFresh([]*common.DBOrch{fresh, {ProbedAt: now.Add(-OrchInfoTTL + time.Second)}}, now))
+	// info past the TTL or never requested isn't served from the cache
+	assert.False(infoFresh([]*common.DBOrch{fresh, {ProbedAt: now.Add(-OrchInfoTTL)}}, now))
+	assert.False(infoFresh([]*common.DBOrch{fresh, {}}, now))
+}
+
+func TestInfoAges(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	now := time.Now()
+	resps := []orchestratorResponse{
+		{orch: &common.DBOrch{ProbedAt: now.Add(-time.Minute)}},
+		{},
+		{orch: &common.DBOrch{}},
+		{orch: &common.DBOrch{ProbedAt: now.Add(-3 * time.Hour)}},
+	}
+	ages := infoAges(resps, now)
+	assert.Equal([]time.Duration{time.Minute, 3 * time.Hour}, ages)
+	assert.Empty(infoAges(nil, now))
+
+	// ages of the cached infos of the selected orchestrators
+	addresses := []string{"https://127.0.0.1:8936", "https://127.0.0.1:8937", "https://127.0.0.1:8938"}
+	oldOrchInfo := serverGetOrchInfo
+	defer func() { serverGetOrchInfo = oldOrchInfo }()
+	serverGetOrchInfo = func(ctx context.Context, bcast common.Broadcaster, server *url.URL) (*net.OrchestratorInfo, error) {
+		return &net.OrchestratorInfo{
+			Transcoder: server.String(),
+			PriceInfo:  &net.PriceInfo{PricePerUnit: 1, PixelsPerUnit: 1},
+		}, nil
+	}
+	dbh, dbraw, err := common.TempDB(t)
+	defer dbh.Close()
+	defer dbraw.Close()
+	require.Nil(err)
+	sender := &pm.MockSender{}
+	sender.On("ValidateTicketParams", mock.Anything).Return(nil)
+	node := &core.LivepeerNode{
+		Database: dbh,
+		Eth:      &eth.StubClient{Orchestrators: StubOrchestrators(addresses)},
+		Sender:   sender,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pool, err := NewDBOrchestratorPoolCache(ctx, node, &stubRoundsManager{})
+	require.NoError(err)
+
+	for i, age := range []string{"-10 minutes", "-1 hours", "-5 hours"} {
+		_, err = dbraw.Exec("UPDATE orchestrators SET probedAt = datetime('now', ?) WHERE serviceURI = ?", age, addresses[i])
+		require.Nil(err)
+	}
+	orchPool, err := pool.orchestratorPool()
+	require.Nil(err)
+	resps, err = orchPool.getOrchestrators(len(addresses), newStubSuspender(), newStubCapabilities())
+	require.Nil(err)
+	require.Len(resps, 3)
+	ages = infoAges(resps, time.Now())
+	require.Len(ages, 3)
+	sort.Slice(ages, func(i, j int) bool { return ages[i] < ages[j] })
+	for i, want := range []time.Duration{10 * time.Minute, time.Hour, 5 * time.Hour} {
+		assert.InDelta(want.Seconds(), ages[i].Seconds(), 5)
+	}
+}
+
+func TestCachedPool_OrchSchemeFallback(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	addresses := []string{"https://127.0.0.1:8936", "https://127.0.0.1:8937"}
+	var mu sync.Mutex
+	var reqs []string
+	httpsDown, httpDown := addresses[1], ""
+	oldOrchInfo := serverGetOrchInfo
+	defer func() { serverGetOrchInfo = oldOrchInfo }()
+	serverGetOrchInfo = func(ctx context.Context, bcast common.Broadcaster, server *url.URL) (*net.OrchestratorInfo, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		reqs = append(reqs, server.String())
+		if server.String() == httpsDown || server.String() == httpDown {
+			return nil, errors.New("connection refused")
+		}
+		return &net.OrchestratorInfo{
+			Transcoder: server.String(),
+			PriceInfo:  &net.PriceInfo{PricePerUnit: 1, PixelsPerUnit: 1},
+		}, nil
+	}
+	requests := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		res := reqs
+		reqs = nil
+		return res
+	}
+	urls := func(pool *DBOrchestratorPoolCache) []string {
+		var res []string
+		for _, uri := range pool.GetURLs() {
+			res = append(res, uri.String())
+		}
+		return res
+	}
+	defer func() { OrchSchemeFallback = false }()
+	OrchSchemeFallback = true
+
+	dbh, dbraw, err := common.TempDB(t)
+	defer dbh.Close()
+	defer dbraw.Close()
+	require.Nil(err)
+	sender := &pm.MockSender{}
+	sender.On("ValidateTicketParams", mock.Anything).Return(nil)
+	node := &core.LivepeerNode{
+		Database: dbh,
+		Eth:      &eth.StubClient{Orchestrators: StubOrchestrators(addresses)},
+		Sender:   sender,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pool, err := NewDBOrchestratorPoolCache(ctx, node, &stubRoundsManager{})
+	require.NoError(err)
+
+	// falls back to http and is reached over http since
+	httpAddr := "http://127.0.0.1:8937"
+	assert.ElementsMatch([]string{addresses[0], addresses[1], httpAddr}, requests())
+	assert.ElementsMatch([]string{addresses[0], httpAddr}, urls(pool))
+	require.Nil(pool.cacheDBOrchs())
+	assert.ElementsMatch([]string{addresses[0], httpAddr}, requests())
+
+	// https is tried first again once http fails
+	mu.Lock()
+	httpDown = httpAddr
+	mu.Unlock()
+	require.Nil(pool.cacheDBOrchs())
+	assert.ElementsMatch([]string{addresses[0], httpAddr}, requests())
+	assert.ElementsMatch([]string{addresses[0], addresses[1]}, urls(pool))
+	require.Nil(pool.cacheDBOrchs())
+	assert.ElementsMatch([]string{addresses[0], addresses[1], httpAddr}, requests())
+
+	// no fallback unless enabled
+	OrchSchemeFallback = false
+	mu.Lock()
+	httpDown = ""
+	mu.Unlock()
+	require.Nil(pool.cacheDBOrchs())
+	assert.ElementsMatch([]string{addresses[0], addresses[1]}, requests())
+	assert.ElementsMatch([]string{addresses[0], addresses[1]}, urls(pool))
+}
+
+func TestCachedPool_OnPoolChange(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	addresses := []string{"https://127.0.0.1:8936", "https://127.0.0.1:8937", "https://127.0.0.1:8938"}
+	var mu sync.Mutex
+	down := make(map[string]bool)
+	setDown := func(addr string, isDown bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		down[addr] = isDown
+	}
+	oldOrchInfo := serverGetOrchInfo
+	defer func() { serverGetOrchInfo = oldOrchInfo }()
+	serverGetOrchInfo = func(ctx context.Context, bcast common.Broadcaster, server *url.URL) (*net.OrchestratorInfo, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if down[server.String()] {
+			return nil, errors.New("unreachable")
+		}
+		return &net.OrchestratorInfo{
+			Transcoder: server.String(),
+			PriceInfo:  &net.PriceInfo{PricePerUnit: 1, PixelsPerUnit: 1},
+		}, nil
+	}
+	defer func(max int, staleness time.Duration) {
+		MaxOrchProbeFailures, MaxOrchInfoStaleness = max, staleness
+	}(MaxOrchProbeFailures, MaxOrchInfoStaleness)
+	MaxOrchProbeFailures, MaxOrchInfoStaleness = 1, 0
+
+	dbh, dbraw, err := common.TempDB(t)
+	defer dbh.Close()
+	defer dbraw.Close()
+	require.Nil(err)
+	orchestrators := StubOrchestrators(addresses)
+	ethAddr := func(i int) string { return orchestrators[i].Address.String() }
+	sender := &pm.MockSender{}
+	sender.On("ValidateTicketParams", mock.Anything).Return(nil)
+	lpEth := &eth.StubClient{Orchestrators: orchestrators[:2]}
+	node := &core.LivepeerNode{
+		Database: dbh,
+		Eth:      lpEth,
+		Sender:   sender,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pool, err := NewDBOrchestratorPoolCache(ctx, node, &stubRoundsManager{})
+	require.NoError(err)
+
+	var changes []PoolChange
+	pool.OnPoolChange(func(change PoolChange) { changes = append(changes, change) })
+
+	// no change
+	require.Nil(pool.cacheDBOrchs())
+	assert.Empty(changes)
+
+	// orchestrator failing info requests leaves
+	setDown(addresses[1], true)
+	require.Nil(pool.cacheDBOrchs())
+	assert.Equal([]PoolChange{{Removed: []string{ethAddr(1)}}}, changes)
+
+	// new on-chain orchestrator joins
+	changes = nil
+	lpEth.Orchestrators = orchestrators
+	require.Nil(pool.cacheTranscoderPool())
+	assert.Equal([]PoolChange{{Added: []string{ethAddr(2)}}}, changes)
+
+	// joins and leaves at once
+	changes = nil
+	setDown(addresses[1], false)
+	setDown(addresses[2], true)
+	require.Nil(pool.cacheDBOrchs())
+	assert.Equal([]PoolChange{{Added: []string{ethAddr(1)}, Removed: []string{ethAddr(2)}}}, changes)
+}
+
+// blockingPoolClient holds TranscoderPool calls till released
+type blockingPoolClient struct {
+	*eth.StubClient
+	calls   int32
+	release chan struct{}
+}
+
+func (c *blockingPoolClient) TranscoderPool() ([]*lpTypes.Transcoder, error) {
+	atomic.AddInt32(&c.calls, 1)
+	<-c.release
+	return c.StubClient.TranscoderPool()
+}
+
+func TestCachedPool_Refresh(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	addresses := []string{"https://127.0.0.1:8936", "https://127.0.0.1:8937"}
+	oldOrchInfo := serverGetOrchInfo
+	defer func() { serverGetOrchInfo = oldOrchInfo }()
+	serverGetOrchInfo = func(ctx context.Context, bcast common.Broadcaster, server *url.URL) (*net.OrchestratorInfo, error) {
+		return &net.OrchestratorInfo{
+			Transcoder: server.String(),
+			PriceInfo:  &net.PriceInfo{PricePerUnit: 1, PixelsPerUnit: 1},
+		}, nil
+	}
+
+	dbh, dbraw, err := common.TempDB(t)
+	defer dbh.Close()
+	defer dbraw.Close()
+	require.Nil(err)
+	orchestrators := StubOrchestrators(addresses)
+	sender := &pm.MockSender{}
+	sender.On("ValidateTicketParams", mock.Anything).Return(nil)
+	lpEth := &blockingPoolClient{
+		StubClient: &eth.StubClient{Orchestrators: orchestrators[:1]},
+		release:    make(chan struct{}),
+	}
+	close(lpEth.release)
+	node := &core.LivepeerNode{
+		Database: dbh,
+		Eth:      lpEth,
+		Sender:   sender,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pool, err := NewDBOrchestratorPoolCache(ctx, node, &stubRoundsManager{})
+	require.NoError(err)
+	assert.Equal(1, pool.Size())
+
+	// new on-chain orchestrator is picked up right away
+	lpEth.Orchestrators = orchestrators
+	lpEth.calls = 0
+	require.Nil(pool.Refresh(context.Background()))
+	assert.Equal(int32(1), atomic.LoadInt32(&lpEth.calls))
+	assert.Equal(2, pool.Size())
+
+	// overlapping refreshes share a single refresh
+	lpEth.calls = 0
+	lpEth.release = make(chan struct{})
+	var wg sync.WaitGroup
+	errs := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- pool.Refresh(context.Background())
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	// polling is skipped while the refresh is in progress
+	assert.Nil(pool.pollOnce())
+	close(lpEth.release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.Nil(err)
+	}
+	assert.Equal(int32(1), atomic.LoadInt32(&lpEth.calls))
+
+	// caller gives up waiting once its context is done
+	lpEth.calls = 0
+	lpEth.release = make(chan struct{})
+	reqCtx, reqCancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer reqCancel()
+	assert.Equal(context.DeadlineExceeded, pool.Refresh(reqCtx))
+	close(lpEth.release)
+	// abandoned refresh still completes
+	assert.Nil(pool.Refresh(context.Background()))
+
+	// refresh fails if the pool can't be fetched from the chain
+	lpEth.TranscoderPoolError = errors.New("TranscoderPool error")
+	assert.EqualError(pool.Refresh(context.Background()), "Could not refresh DB list of orchestrators: TranscoderPool error")
+}
+
+func TestDiscoveryErrorCode(t *testing.T) {
+	assert := assert.New(t)
+
+	wrap := func(err error) error { return fmt.Errorf("Could not get orchestrator err=%w", err) }
+
+	assert.Equal(monitor.DiscoveryErrorOrchestratorCapped, discoveryErrorCode(wrap(status.Error(codes.Unknown, core.ErrOrchCap.Error()))))
+	assert.Equal(monitor.DiscoveryErrorOrchestratorBusy, discoveryErrorCode(wrap(status.Error(codes.Unknown, core.ErrOrchBusy.Error()))))
+	assert.Equal(monitor.DiscoveryErrorOrchestratorUnhealthy, discoveryErrorCode(wrap(status.Error(codes.Unknown, core.ErrOrchUnhealthy.Error()))))
+	assert.Equal(monitor.DiscoveryErrorCanceled, discoveryErrorCode(wrap(status.Error(codes.Canceled, "context canceled"))))
+	assert.Equal(monitor.DiscoveryErrorCanceled, discoveryErrorCode(context.Canceled))
+
+	// error message merely containing the code isn't classified
+	err := wrap(status.Error(codes.Unknown, "not OrchestratorCapped"))
+	assert.Equal(err.Error(), discoveryErrorCode(err))
+	err = errors.New("some error")
+	assert.Equal("some error", discoveryErrorCode(err))
+}
+
+func TestDiscoveryOutcome(t *testing.T) {
+	assert := assert.New(t)
+
+	infos := []*net.OrchestratorInfo{{Transcoder: "transcoder"}}
+	assert.Equal(monitor.DiscoveryOutcomeSuccess, discoveryOutcome(infos, false))
+	assert.Equal(monitor.DiscoveryOutcomeEmpty, discoveryOutcome(nil, false))
+	assert.Equal(monitor.DiscoveryOutcomeTimeout, discoveryOutcome(infos, true))
+	assert.Equal(monitor.DiscoveryOutcomeTimeout, discoveryOutcome(nil, true))
+}
+
+func TestCachedPool_GetOrchestratorsDetailed(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	addresses := []string{"https://127.0.0.1:8936", "https://127.0.0.1:8937"}
+	oldOrchInfo := serverGetOrchInfo
+	defer func() { serverGetOrchInfo = oldOrchInfo }()
+	serverGetOrchInfo = func(ctx context.Context, bcast common.Broadcaster, server *url.URL) (*net.OrchestratorInfo, error) {
+		if server.String() == addresses[1] {
+			time.Sleep(20 * time.Millisecond)
+		}
+		return &net.OrchestratorInfo{
+			Transcoder: server.String(),
+			PriceInfo:  &net.PriceInfo{PricePerUnit: 3, PixelsPerUnit: 2},
+		}, nil
+	}
+
+	dbh, dbraw, err := common.TempDB(t)
+	defer dbh.Close()
+	defer dbraw.Close()
+	require.Nil(err)
+
+	orchestrators := StubOrchestrators(addresses)
+	token := new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
+	orchestrators[0].DelegatedStake = new(big.Int).Mul(big.NewInt(10), token)
+	orchestrators[1].DelegatedStake = new(big.Int).Mul(big.NewInt(20), token)
+	orchestrators[1].ActivationRound = big.NewInt(4)
+	sender := &pm.MockSender{}
+	sender.On("ValidateTicketParams", mock.Anything).Return(nil)
+	node := &core.LivepeerNode{
+		Database: dbh,
+		Eth:      &eth.StubClient{Orchestrators: orchestrators},
+		Sender:   sender,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pool, err := NewDBOrchestratorPoolCache(ctx, node, &stubRoundsManager{})
+	require.NoError(err)
+
+	candidates, err := pool.GetOrchestratorsDetailed(len(addresses))
+	require.Nil(err)
+	require.Len(candidates, 2)
+	byURI := make(map[string]OrchCandidate)
+	for _, c := range candidates {
+		byURI[c.Info.Transcoder] = c
+		assert.Equal(big.NewRat(3, 2), c.PricePerPixel)
+	}
+	dbOrchs, err := pool.store.SelectOrchs(nil)
+	require.Nil(err)
+	for _, o := range dbOrchs {
+		c := byURI[o.ServiceURI]
+		assert.Equal(o.Stake, c.Stake)
+		assert.Equal(o.ActivationRound, c.ActivationRound)
+	}
+	assert.Greater(byURI[addresses[1]].Stake, byURI[addresses[0]].Stake)
+	assert.Equal(int64(4), byURI[addresses[1]].ActivationRound)
+	assert.GreaterOrEqual(int64(byURI[addresses[1]].RTT), int64(20*time.Millisecond))
+	assert.Less(int64(byURI[addresses[0]].RTT), int64(20*time.Millisecond))
+
+	// unrecorded orchestrators and infos without price
+	serverGetOrchInfo = func(ctx context.Context, bcast common.Broadcaster, server *url.URL) (*net.OrchestratorInfo, error) {
+		return &net.OrchestratorInfo{Transcoder: server.String()}, nil
+	}
+	uri, _ := url.Parse(addresses[0])
+	candidates, err = NewOrchestratorPool(nil, []*url.URL{uri}).GetOrchestratorsDetailed(1)
+	require.Nil(err)
+	require.Len(candidates, 1)
+	assert.Equal(addresses[0], candidates[0].Info.Transcoder)
+	assert.Nil(candidates[0].PricePerPixel)
+	assert.Zero(candidates[0].Stake)
+	assert.Zero(candidates[0].ActivationRound)
+}
@@ -3,6 +3,7 @@ package discovery
 import (
 	"encoding/json"
 	"io/ioutil"
+	"math/rand"
 	"net/http"
 	"net/url"
 	"sync"
@@ -11,7 +12,9 @@ import (
 	ethcommon "github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/livepeer/go-livepeer/common"
+	"github.com/livepeer/go-livepeer/monitor"
 	"github.com/livepeer/go-livepeer/net"
+	"github.com/livepeer/go-livepeer/server"
 
 	"github.com/golang/glog"
 )
@@ -29,12 +32,14 @@ type webhookPool struct {
 	lastRequest  time.Time
 	mu           *sync.RWMutex
 	bcast        common.Broadcaster
+	rand         *rand.Rand // of the selection, shared by the pools created for it
 }
 
 func NewWebhookPool(bcast common.Broadcaster, callback *url.URL) *webhookPool {
 	p := &webhookPool{
 		callback: callback,
 		mu:       &sync.RWMutex{},
+		rand:     server.NewSelectionRand(),
 		bcast:    bcast,
 	}
 	go p.getURLs()
@@ -49,8 +54,14 @@ func (w *webhookPool) getURLs() ([]*url.URL, error) {
 
 	// retrive addrs from cache if time since lastRequest is less than the refresh interval
 	if time.Since(lastReq) < whRefreshInterval {
+		if monitor.Enabled {
+			monitor.DiscoveryCacheLookup(true)
+		}
 		return pool.GetURLs(), nil
 	}
+	if monitor.Enabled {
+		monitor.DiscoveryCacheLookup(false)
+	}
 
 	// retrive addrs from webhook if time since lastRequest is more than the refresh interval
 	body, err := getURLsfromWebhook(w.callback)
@@ -73,6 +84,7 @@ func (w *webhookPool) getURLs() ([]*url.URL, error) {
 	}
 
 	pool = NewOrchestratorPool(w.bcast, addrs)
+	pool.rand = w.rand
 
 	w.mu.Lock()
 	w.responseHash = hash
@@ -0,0 +1,347 @@
+package drivers
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/livepeer/go-livepeer/common"
+	"github.com/livepeer/go-livepeer/net"
+)
+
+const azureAPIVersion = "2019-12-12"
+
+// AZUREACCOUNT storage account of the Azure Blob Storage container owned by this node
+var AZUREACCOUNT string
+
+// AZURECONTAINER Azure Blob Storage container owned by this node
+var AZURECONTAINER string
+
+// AzureBlockSize data bigger than this is saved to Azure as blocks of this
+// size uploaded separately and committed with the block list
+var AzureBlockSize = 64 * 1024 * 1024
+
+var azureClient = &http.Client{}
+
+// azureOS Azure Blob Storage backed object storage driver. For own storage the
+// account key should be specified. To give other nodes access to own container
+// SAS token with create and write permissions is created. The token is valid
+// for S3_POLICY_EXPIRE_IN_HOURS hours.
+type azureOS struct {
+	host      string
+	account   string
+	container string
+	key       string // base64 encoded account key
+}
+
+type azureSession struct {
+	host     string
+	key      string
+	sasToken string
+	// only set for the sessions of our own storage
+	os *azureOS
+}
+
+// IsOwnStorageAzure returns true if uri points to Azure Blob Storage container owned by this node
+func IsOwnStorageAzure(uri string) bool {
+	return AZURECONTAINER != "" && strings.HasPrefix(uri, azureHost(AZUREACCOUNT, AZURECONTAINER))
+}
+
+func azureHost(account, container string) string {
+	return fmt.Sprintf("https://%s.blob.core.windows.net/%s", account, container)
+}
+
+// NewAzureDriver returns driver for the container in Azure Blob Storage.
+// key is base64 encoded access key of the storage account. Saved blobs are
+// readable by their URL if the container allows public access to blobs.
+func NewAzureDriver(account, key, container string) OSDriver {
+	return &azureOS{
+		host:      azureHost(account, container),
+		account:   account,
+		container: container,
+		key:       key,
+	}
+}
+
+func (os *azureOS) NewSession(path string) OSSession {
+	path = renderPath(StoragePathTemplate, path, time.Now())
+	sasToken, err := os.sas("cw", time.Now().Add(S3_POLICY_EXPIRE_IN_HOURS*time.Hour))
+	if err != nil {
+		glog.Errorf("Error creating Azure SAS token account=%s container=%s err=%v", os.account, os.container, err)
+	}
+	return &azureSession{
+		host:     os.host,
+		key:      path,
+		sasToken: sasToken,
+		os:       os,
+	}
+}
+
+// sas returns service SAS token giving the permissions to the container until expireAt
+func (os *azureOS) sas(permissions string, expireAt time.Time) (string, error) {
+	key, err := base64.StdEncoding.DecodeString(os.key)
+	if err != nil {
+		return "", err
+	}
+	expiry := expireAt.UTC().Format("2006-01-02T15:04:05Z")
+	// permissions, start, expiry, resource, identifier, IP, protocol, version,
+	// resource type, snapshot time and five response headers overrides
+	toSign := strings.Join([]string{permissions, "", expiry, "/blob/" + os.account + "/" + os.container,
+		"", "", "", azureAPIVersion, "c", "", "", "", "", "", ""}, "\n")
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(toSign))
+	return url.Values{
+		"sv":  {azureAPIVersion},
+		"sr":  {"c"},
+		"sp":  {permissions},
+		"se":  {expiry},
+		"sig": {base64.StdEncoding.EncodeToString(mac.Sum(nil))},
+	}.Encode(), nil
+}
+
+func newAzureSession(info *net.AzureOSInfo) OSSession {
+	if info == nil {
+		return nil
+	}
+	return &azureSession{
+		host:     info.Host,
+		key:      info.Key,
+		sasToken: info.SasToken,
+	}
+}
+
+func (os *azureSession) IsExternal() bool {
+	return true
+}
+
+func (os *azureSession) EndSession() {
+}
+
+func (os *azureSession) GetInfo() *net.OSInfo {
+	return &net.OSInfo{
+		StorageType: net.OSInfo_AZURE,
+		AzureInfo: &net.AzureOSInfo{
+			Host:     os.host,
+			Key:      os.key,
+			SasToken: os.sasToken,
+		},
+	}
+}
+
+func (os *azureSession) blobURL(name string) string {
+	return os.host + (&url.URL{Path: "/" + path.Join(os.key, name)}).EscapedPath()
+}
+
+// ownSAS returns short-lived SAS token for reading, listing and deleting the
+// blobs, which the write-only token given to other nodes doesn't allow
+func (os *azureSession) ownSAS() (string, error) {
+	if os.os == nil {
+		return "", ErrNotSupported
+	}
+	return os.os.sas("rdl", time.Now().Add(time.Hour))
+}
+
+func (os *azureSession) SaveData(name string, data []byte, props *FileProperties) (string, error) {
+	return os.saveReader(name, bytes.NewReader(data), int64(len(data)), props)
+}
+
+// SaveDataReader streams the data to Azure, holding at most one block of it in
+// memory
+func (os *azureSession) SaveDataReader(name string, r io.Reader, size int64) (string, error) {
+	return os.saveReader(name, r, size, nil)
+}
+
+func (os *azureSession) saveReader(name string, r io.Reader, size int64, props *FileProperties) (string, error) {
+	uri := os.blobURL(name)
+	glog.V(common.VERBOSE).Infof("Saving to Azure %s", uri)
+	contentType, r, err := props.contentTypeReader(name, r)
+	if err == nil {
+		headers := map[string]string{"x-ms-blob-content-type": contentType}
+		if cacheControl := props.cacheControl(); cacheControl != "" {
+			headers["x-ms-blob-cache-control"] = cacheControl
+		}
+		if size < 0 || size > int64(AzureBlockSize) {
+			err = os.putBlocks(uri, headers, r)
+		} else {
+			err = os.putBlob(uri, headers, r, size)
+		}
+	}
+	if err != nil {
+		glog.Errorf("Save Azure error: %v", err)
+		return "", err
+	}
+	glog.V(common.VERBOSE).Infof("Saved to Azure %s", uri)
+	return uri, nil
+}
+
+// putBlob uploads the data of the size with single request
+func (os *azureSession) putBlob(uri string, headers map[string]string, r io.Reader, size int64) error {
+	data, err := ioutil.ReadAll(io.LimitReader(r, size))
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) != size {
+		return io.ErrUnexpectedEOF
+	}
+	headers["x-ms-blob-type"] = "BlockBlob"
+	return os.do("PUT", uri, nil, headers, data, http.StatusCreated)
+}
+
+// putBlocks uploads the data read from r as blocks and commits them as the
+// block blob with the blob headers
+func (os *azureSession) putBlocks(uri string, headers map[string]string, r io.Reader) error {
+	blockList := &bytes.Buffer{}
+	blockList.WriteString(`<?xml version="1.0" encoding="utf-8"?><BlockList>`)
+	block := make([]byte, AzureBlockSize)
+	for i := 0; ; i++ {
+		n, err := io.ReadFull(r, block)
+		if err == io.EOF {
+			break
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		// IDs of all the blocks of the blob should have the same length
+		id := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%08d", i)))
+		query := url.Values{"comp": {"block"}, "blockid": {id}}
+		if err := os.do("PUT", uri, query, nil, block[:n], http.StatusCreated); err != nil {
+			return err
+		}
+		blockList.WriteString("<Latest>" + id + "</Latest>")
+		if n < AzureBlockSize {
+			break
+		}
+	}
+	blockList.WriteString("</BlockList>")
+	query := url.Values{"comp": {"blocklist"}}
+	return os.do("PUT", uri, query, headers, blockList.Bytes(), http.StatusCreated)
+}
+
+// do sends the request authorized with the session's SAS token
+func (os *azureSession) do(method, uri string, query url.Values, headers map[string]string, body []byte, expected int) error {
+	_, err := os.request(method, uri, os.sasToken, query, headers, body, expected)
+	return err
+}
+
+func (os *azureSession) request(method, uri, sasToken string, query url.Values, headers map[string]string, body []byte, expected int) ([]byte, error) {
+	rawQuery := sasToken
+	if len(query) > 0 {
+		rawQuery += "&" + query.Encode()
+	}
+	req, err := http.NewRequest(method, uri+"?"+rawQuery, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-ms-version", azureAPIVersion)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := azureClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode != expected {
+		return nil, fmt.Errorf("status=%d body=%s", resp.StatusCode, string(respBody))
+	}
+	return respBody, nil
+}
+
+// ReadData returns the blob read with own credentials. Container of other
+// node is expected to allow public read access.
+func (os *azureSession) ReadData(name string) ([]byte, error) {
+	uri := os.blobURL(name)
+	if os.os == nil {
+		return getPublicData(uri)
+	}
+	sasToken, err := os.ownSAS()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.request("GET", uri, sasToken, nil, nil, nil, http.StatusOK)
+	if err != nil && err != ErrNotFound {
+		glog.Errorf("Error reading Azure uri=%s err=%v", uri, err)
+	}
+	return data, err
+}
+
+type azureBlobList struct {
+	Blobs []struct {
+		Name       string `xml:"Name"`
+		Properties struct {
+			LastModified  string `xml:"Last-Modified"`
+			ContentLength int64  `xml:"Content-Length"`
+		} `xml:"Properties"`
+	} `xml:"Blobs>Blob"`
+	NextMarker string `xml:"NextMarker"`
+}
+
+func (os *azureSession) ListData() ([]*FileInfo, error) {
+	sasToken, err := os.ownSAS()
+	if err != nil {
+		return nil, err
+	}
+	var files []*FileInfo
+	marker := ""
+	for {
+		query := url.Values{"restype": {"container"}, "comp": {"list"}, "prefix": {os.key}}
+		if marker != "" {
+			query.Set("marker", marker)
+		}
+		body, err := os.request("GET", os.host, sasToken, query, nil, nil, http.StatusOK)
+		if err != nil {
+			glog.Errorf("Error listing Azure container=%s prefix=%s err=%v", os.host, os.key, err)
+			return nil, err
+		}
+		var list azureBlobList
+		if err := xml.Unmarshal(body, &list); err != nil {
+			return nil, err
+		}
+		for _, blob := range list.Blobs {
+			lastModified, _ := time.Parse(http.TimeFormat, blob.Properties.LastModified)
+			files = append(files, &FileInfo{
+				Name:         strings.TrimPrefix(strings.TrimPrefix(blob.Name, os.key), "/"),
+				LastModified: lastModified,
+				Size:         blob.Properties.ContentLength,
+			})
+		}
+		if list.NextMarker == "" {
+			return files, nil
+		}
+		marker = list.NextMarker
+	}
+}
+
+func (os *azureSession) DeleteData(name string) error {
+	sasToken, err := os.ownSAS()
+	if err != nil {
+		return err
+	}
+	uri := os.blobURL(name)
+	_, err = os.request("DELETE", uri, sasToken, nil, nil, nil, http.StatusAccepted)
+	if err == ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		glog.Errorf("Error deleting from Azure uri=%s err=%v", uri, err)
+	}
+	return err
+}
@@ -0,0 +1,292 @@
+package drivers
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/livepeer/go-livepeer/net"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var azureTestKey = base64.StdEncoding.EncodeToString([]byte("secret"))
+
+// stubAzure handles Blob Storage requests to the container, checking SAS tokens
+type stubAzure struct {
+	mu           sync.Mutex
+	blobs        map[string][]byte
+	contentType  map[string]string
+	cacheControl map[string]string
+	blocks       map[string][]byte
+	blockPuts    int
+}
+
+func newStubAzure() *stubAzure {
+	return &stubAzure{
+		blobs:        make(map[string][]byte),
+		contentType:  make(map[string]string),
+		cacheControl: make(map[string]string),
+		blocks:       make(map[string][]byte),
+	}
+}
+
+// allowed returns whether the request has valid SAS token with the permission
+func (s *stubAzure) allowed(r *http.Request, permission string) bool {
+	q := r.URL.Query()
+	toSign := strings.Join([]string{q.Get("sp"), "", q.Get("se"), "/blob/account/container",
+		"", "", "", q.Get("sv"), q.Get("sr"), "", "", "", "", "", ""}, "\n")
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte(toSign))
+	expiry, err := time.Parse("2006-01-02T15:04:05Z", q.Get("se"))
+	return err == nil && time.Now().Before(expiry) &&
+		q.Get("sig") == base64.StdEncoding.EncodeToString(mac.Sum(nil)) &&
+		strings.Contains(q.Get("sp"), permission)
+}
+
+func (s *stubAzure) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q := r.URL.Query()
+	name := strings.TrimPrefix(r.URL.Path, "/container/")
+	body, _ := ioutil.ReadAll(r.Body)
+	if r.Header.Get("x-ms-version") != azureAPIVersion {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var permission string
+	switch r.Method {
+	case "PUT":
+		permission = "w"
+	case "DELETE":
+		permission = "d"
+	case "GET":
+		permission = "r"
+		if q.Get("comp") == "list" {
+			permission = "l"
+		}
+	}
+	if !s.allowed(r, permission) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	switch {
+	case r.Method == "PUT" && q.Get("comp") == "block":
+		s.blockPuts++
+		s.blocks[q.Get("blockid")] = body
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == "PUT" && q.Get("comp") == "blocklist":
+		var list struct {
+			Latest []string `xml:"Latest"`
+		}
+		xml.Unmarshal(body, &list)
+		var data []byte
+		for _, id := range list.Latest {
+			data = append(data, s.blocks[id]...)
+		}
+		s.blobs[name] = data
+		s.contentType[name] = r.Header.Get("x-ms-blob-content-type")
+		s.cacheControl[name] = r.Header.Get("x-ms-blob-cache-control")
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == "PUT" && r.Header.Get("x-ms-blob-type") == "BlockBlob":
+		s.blobs[name] = body
+		s.contentType[name] = r.Header.Get("x-ms-blob-content-type")
+		s.cacheControl[name] = r.Header.Get("x-ms-blob-cache-control")
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == "GET" && q.Get("comp") == "list" && q.Get("restype") == "container":
+		// one blob per page
+		var names []string
+		for n := range s.blobs {
+			if strings.HasPrefix(n, q.Get("prefix")) && n > q.Get("marker") {
+				names = append(names, n)
+			}
+		}
+		fmt.Fprint(w, `<?xml version="1.0" encoding="utf-8"?><EnumerationResults><Blobs>`)
+		if len(names) > 0 {
+			first := names[0]
+			for _, n := range names {
+				if n < first {
+					first = n
+				}
+			}
+			fmt.Fprintf(w, `<Blob><Name>%s</Name><Properties><Last-Modified>%s</Last-Modified><Content-Length>%d</Content-Length></Properties></Blob>`,
+				first, time.Now().UTC().Format(http.TimeFormat), len(s.blobs[first]))
+			fmt.Fprintf(w, `</Blobs><NextMarker>%s</NextMarker></EnumerationResults>`, first)
+		} else {
+			fmt.Fprint(w, `</Blobs><NextMarker /></EnumerationResults>`)
+		}
+	case r.Method == "GET":
+		data, ok := s.blobs[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	case r.Method == "DELETE":
+		if _, ok := s.blobs[name]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(s.blobs, name)
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func newTestAzureDriver(url string) *azureOS {
+	os := NewAzureDriver("account", azureTestKey, "container").(*azureOS)
+	os.host = url + "/container"
+	return os
+}
+
+func TestAzureSession(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	stub := newStubAzure()
+	ts := httptest.NewServer(stub)
+	defer ts.Close()
+
+	sess := newTestAzureDriver(ts.URL).NewSession("path")
+	assert.True(sess.IsExternal())
+	info := sess.GetInfo()
+	assert.Equal(net.OSInfo_AZURE, info.StorageType)
+	require.NotNil(info.AzureInfo)
+	assert.Equal(ts.URL+"/container", info.AzureInfo.Host)
+	assert.Equal("path", info.AzureInfo.Key)
+	assert.Contains(info.AzureInfo.SasToken, "sp=cw")
+
+	uri, err := sess.SaveData("name/1.ts", []byte("data"), nil)
+	require.Nil(err)
+	assert.Equal(ts.URL+"/container/path/name/1.ts", uri)
+	assert.Equal([]byte("data"), stub.blobs["path/name/1.ts"])
+	assert.Equal("video/mp2t", stub.contentType["path/name/1.ts"])
+	assert.Equal("", stub.cacheControl["path/name/1.ts"])
+
+	props := &FileProperties{ContentType: "application/vnd.apple.mpegurl", CacheControl: "no-cache"}
+	_, err = sess.SaveData("name/index.m3u8", []byte("#EXTM3U"), props)
+	require.Nil(err)
+	assert.Equal("application/vnd.apple.mpegurl", stub.contentType["path/name/index.m3u8"])
+	assert.Equal("no-cache", stub.cacheControl["path/name/index.m3u8"])
+	delete(stub.blobs, "path/name/index.m3u8")
+
+	data, err := sess.ReadData("name/1.ts")
+	assert.Nil(err)
+	assert.Equal([]byte("data"), data)
+	_, err = sess.ReadData("name/2.ts")
+	assert.Equal(ErrNotFound, err)
+
+	_, err = sess.SaveData("name/2.ts", []byte("more data"), nil)
+	require.Nil(err)
+	stub.blobs["other/1.ts"] = []byte("other")
+	files, err := sess.ListData()
+	require.Nil(err)
+	require.Len(files, 2)
+	assert.Equal("name/1.ts", files[0].Name)
+	assert.Equal(int64(4), files[0].Size)
+	assert.WithinDuration(time.Now(), files[0].LastModified, time.Minute)
+	assert.Equal("name/2.ts", files[1].Name)
+	assert.Equal(int64(9), files[1].Size)
+
+	assert.Nil(sess.DeleteData("name/1.ts"))
+	assert.NotContains(stub.blobs, "path/name/1.ts")
+	// deleting non-existing data isn't an error
+	assert.Nil(sess.DeleteData("name/1.ts"))
+	assert.Contains(stub.blobs, "other/1.ts")
+}
+
+func TestAzureSession_Blocks(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	defer func(blockSize int) { AzureBlockSize = blockSize }(AzureBlockSize)
+	AzureBlockSize = 1024
+
+	stub := newStubAzure()
+	ts := httptest.NewServer(stub)
+	defer ts.Close()
+
+	data := make([]byte, 2500)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	_, err := newTestAzureDriver(ts.URL).NewSession("path").SaveData("name/1.mp4", data, nil)
+	require.Nil(err)
+	assert.Equal(3, stub.blockPuts)
+	assert.Equal(data, stub.blobs["path/name/1.mp4"])
+	assert.Equal("video/mp4", stub.contentType["path/name/1.mp4"])
+
+	props := &FileProperties{ContentType: "video/x-custom", CacheControl: "max-age=3600"}
+	_, err = newTestAzureDriver(ts.URL).NewSession("path").SaveData("name/2.mp4", data, props)
+	require.Nil(err)
+	assert.Equal(data, stub.blobs["path/name/2.mp4"])
+	assert.Equal("video/x-custom", stub.contentType["path/name/2.mp4"])
+	assert.Equal("max-age=3600", stub.cacheControl["path/name/2.mp4"])
+
+	// streamed data of unknown size is read block by block
+	stub.blockPuts = 0
+	_, err = newTestAzureDriver(ts.URL).NewSession("path").SaveDataReader("name/3.mp4", bytes.NewReader(data[:2048]), -1)
+	require.Nil(err)
+	assert.Equal(2, stub.blockPuts)
+	assert.Equal(data[:2048], stub.blobs["path/name/3.mp4"])
+	assert.Equal("video/mp4", stub.contentType["path/name/3.mp4"])
+
+	// small data with single request
+	_, err = newTestAzureDriver(ts.URL).NewSession("path").SaveDataReader("name/4.ts", bytes.NewReader(data[:100]), 100)
+	require.Nil(err)
+	assert.Equal(2, stub.blockPuts)
+	assert.Equal(data[:100], stub.blobs["path/name/4.ts"])
+}
+
+func TestAzureSession_Foreign(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	stub := newStubAzure()
+	ts := httptest.NewServer(stub)
+	defer ts.Close()
+
+	own := newTestAzureDriver(ts.URL).NewSession("path")
+	sess := NewSession(own.GetInfo())
+	require.IsType(&azureSession{}, sess)
+
+	// write-only SAS token is enough to save data
+	uri, err := sess.SaveData("name/1.ts", []byte("data"), nil)
+	require.Nil(err)
+	assert.Equal(ts.URL+"/container/path/name/1.ts", uri)
+	assert.Equal([]byte("data"), stub.blobs["path/name/1.ts"])
+
+	// but not to manage it
+	_, err = sess.ListData()
+	assert.Equal(ErrNotSupported, err)
+	assert.Equal(ErrNotSupported, sess.DeleteData("name/1.ts"))
+
+	// bad token
+	sess = newAzureSession(&net.AzureOSInfo{Host: ts.URL + "/container", Key: "path", SasToken: "sv=" + azureAPIVersion + "&sp=cw&sig=bad"})
+	_, err = sess.SaveData("name/2.ts", []byte("data"), nil)
+	assert.EqualError(err, "status=403 body=")
+	assert.NotContains(stub.blobs, "path/name/2.ts")
+
+	assert.Nil(NewSession(&net.OSInfo{StorageType: net.OSInfo_AZURE}))
+}
+
+func TestIsOwnStorageAzure(t *testing.T) {
+	assert := assert.New(t)
+	assert.False(IsOwnStorageAzure("https://account.blob.core.windows.net/container/path/1.ts"))
+	defer func() { AZUREACCOUNT, AZURECONTAINER = "", "" }()
+	AZUREACCOUNT, AZURECONTAINER = "account", "container"
+	assert.True(IsOwnStorageAzure("https://account.blob.core.windows.net/container/path/1.ts"))
+	assert.True(IsOwnExternal("https://account.blob.core.windows.net/container/path/1.ts"))
+	assert.False(IsOwnStorageAzure("https://other.blob.core.windows.net/container/path/1.ts"))
+}
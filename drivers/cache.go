@@ -0,0 +1,207 @@
+package drivers
+
+import (
+	"container/list"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/livepeer/go-livepeer/common"
+	"github.com/livepeer/go-livepeer/monitor"
+	"github.com/livepeer/go-livepeer/net"
+)
+
+// CacheOS saves the data to the remote storage and keeps the recently saved
+// or read data in memory, so it can be served without reading it back from
+// the remote storage. The remote storage has the authoritative copy.
+type CacheOS struct {
+	remote  OSDriver
+	maxSize int64
+
+	mu      sync.Mutex
+	size    int64
+	lru     *list.List               // most recently used first
+	entries map[string]*list.Element // by the name relative to the storage
+	uris    map[string]string        // names by the URIs returned by the remote storage
+}
+
+type cacheEntry struct {
+	name string
+	uri  string
+	data []byte
+}
+
+type CacheSession struct {
+	os     *CacheOS
+	remote OSSession
+	path   string
+}
+
+// NewCacheDriver returns driver saving the data to the remote driver and
+// keeping up to maxSize bytes of it in memory, least recently used evicted
+// first
+func NewCacheDriver(remote OSDriver, maxSize int64) *CacheOS {
+	return &CacheOS{
+		remote:  remote,
+		maxSize: maxSize,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+		uris:    make(map[string]string),
+	}
+}
+
+func (c *CacheOS) NewSession(path string) OSSession {
+	return &CacheSession{os: c, remote: c.remote.NewSession(path), path: path}
+}
+
+// GetData returns the cached data saved under the name relative to the
+// storage or under the URI returned by the remote storage, nil if it isn't
+// cached
+func (c *CacheOS) GetData(name string) []byte {
+	c.mu.Lock()
+	if n, ok := c.uris[name]; ok {
+		name = n
+	}
+	var data []byte
+	if el, ok := c.entries[name]; ok {
+		c.lru.MoveToFront(el)
+		data = el.Value.(*cacheEntry).data
+	}
+	c.mu.Unlock()
+	if monitor.Enabled {
+		monitor.StorageCacheRequested(data != nil)
+	}
+	return data
+}
+
+func (c *CacheOS) put(name, uri string, data []byte) {
+	if int64(len(data)) > c.maxSize {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[name]; ok {
+		c.remove(el)
+	}
+	c.entries[name] = c.lru.PushFront(&cacheEntry{name: name, uri: uri, data: data})
+	if uri != "" {
+		c.uris[uri] = name
+	}
+	c.size += int64(len(data))
+
+	var evicted int
+	for c.size > c.maxSize {
+		c.remove(c.lru.Back())
+		evicted++
+	}
+	if evicted > 0 {
+		glog.V(common.DEBUG).Infof("Evicted from storage cache count=%d size=%d", evicted, c.size)
+		if monitor.Enabled {
+			monitor.StorageCacheEvicted(evicted)
+		}
+	}
+}
+
+func (c *CacheOS) delete(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[name]; ok {
+		c.remove(el)
+	}
+}
+
+// remove should be called with the lock held
+func (c *CacheOS) remove(el *list.Element) {
+	e := c.lru.Remove(el).(*cacheEntry)
+	delete(c.entries, e.name)
+	if e.uri != "" {
+		delete(c.uris, e.uri)
+	}
+	c.size -= int64(len(e.data))
+}
+
+func (sess *CacheSession) EndSession() {
+	sess.remote.EndSession()
+}
+
+func (sess *CacheSession) IsExternal() bool {
+	return sess.remote.IsExternal()
+}
+
+func (sess *CacheSession) GetInfo() *net.OSInfo {
+	return sess.remote.GetInfo()
+}
+
+// SaveData saves the data to the remote storage and caches it once saved
+func (sess *CacheSession) SaveData(name string, data []byte, props *FileProperties) (string, error) {
+	uri, err := sess.remote.SaveData(name, data, props)
+	if err != nil {
+		return "", err
+	}
+	sess.os.put(sess.getAbsolutePath(name), uri, data)
+	return uri, nil
+}
+
+// SaveDataReader caches the data that fits into the cache, so reads it to
+// memory before saving it. Bigger data and data of unknown size is streamed to
+// the remote storage.
+func (sess *CacheSession) SaveDataReader(name string, r io.Reader, size int64) (string, error) {
+	if size < 0 || size > sess.os.maxSize {
+		return sess.remote.SaveDataReader(name, r, size)
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	return sess.SaveData(name, data, nil)
+}
+
+func (sess *CacheSession) ListData() ([]*FileInfo, error) {
+	return sess.remote.ListData()
+}
+
+func (sess *CacheSession) DeleteData(name string) error {
+	sess.os.delete(sess.getAbsolutePath(name))
+	return sess.remote.DeleteData(name)
+}
+
+// ReadData returns the cached data if there is any, otherwise reads it from
+// the remote storage and caches it
+func (sess *CacheSession) ReadData(name string) ([]byte, error) {
+	if data := sess.os.GetData(sess.getAbsolutePath(name)); data != nil {
+		return data, nil
+	}
+	data, err := sess.remote.ReadData(name)
+	if err != nil {
+		return nil, err
+	}
+	sess.os.put(sess.getAbsolutePath(name), "", data)
+	return data, nil
+}
+
+// GetData returns the data saved under the URI returned by SaveData, nil if
+// it isn't cached anymore, so should be read from the URI. Data saved under
+// the name relative to the session is read from the remote storage if it
+// isn't cached.
+func (sess *CacheSession) GetData(name string) []byte {
+	if u, err := url.Parse(name); err == nil && u.IsAbs() {
+		return sess.os.GetData(name)
+	}
+	data, err := sess.ReadData(name)
+	if err != nil {
+		if err != ErrNotFound {
+			glog.Errorf("Error reading from remote storage name=%s err=%v", name, err)
+		}
+		return nil
+	}
+	return data
+}
+
+// getAbsolutePath returns the name relative to the storage
+func (sess *CacheSession) getAbsolutePath(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+sess.path+"/"+name), "/")
+}
@@ -0,0 +1,93 @@
+package drivers
+
+import (
+	"bytes"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheOS(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	u, _ := url.Parse("http://node:1935")
+	remote := NewMemoryDriver(u)
+	rsess := remote.NewSession("mid")
+	c := NewCacheDriver(remote, 10)
+	sess := c.NewSession("mid").(*CacheSession)
+
+	uri1, err := sess.SaveData("source/1.ts", []byte("aaaa"), nil)
+	require.Nil(err)
+	assert.Equal("http://node:1935/stream/mid/source/1.ts", uri1)
+	uri2, err := sess.SaveData("source/2.ts", []byte("bbbb"), nil)
+	require.Nil(err)
+
+	// saved to the remote storage
+	data, err := rsess.ReadData("source/1.ts")
+	assert.Nil(err)
+	assert.Equal([]byte("aaaa"), data)
+
+	// local hits by URI, name relative to the session and to the storage
+	assert.Equal([]byte("aaaa"), sess.GetData(uri1))
+	assert.Equal([]byte("bbbb"), sess.GetData("source/2.ts"))
+	assert.Equal([]byte("aaaa"), c.GetData("mid/source/1.ts"))
+
+	// least recently used is evicted over the size
+	assert.Equal([]byte("aaaa"), sess.GetData(uri1))
+	uri3, err := sess.SaveData("source/3.ts", []byte("cccc"), nil)
+	require.Nil(err)
+	assert.Equal(int64(8), c.size)
+	assert.Nil(c.GetData(uri2))
+	assert.Nil(sess.GetData(uri2))
+	assert.Equal([]byte("aaaa"), c.GetData(uri1))
+	assert.Equal([]byte("cccc"), c.GetData(uri3))
+	assert.Len(c.uris, 2)
+
+	// evicted data falls back to the remote storage and is cached again
+	data, err = sess.ReadData("source/2.ts")
+	assert.Nil(err)
+	assert.Equal([]byte("bbbb"), data)
+	assert.Equal([]byte("bbbb"), c.GetData("mid/source/2.ts"))
+	assert.Nil(c.GetData("mid/source/1.ts"))
+	assert.Equal([]byte("aaaa"), sess.GetData("source/1.ts"))
+
+	// data missing in the remote storage too
+	_, err = sess.ReadData("source/4.ts")
+	assert.Equal(ErrNotFound, err)
+	assert.Nil(sess.GetData("source/4.ts"))
+
+	// data bigger than the cache is only saved remotely
+	_, err = sess.SaveData("source/5.ts", []byte("ddddddddddd"), nil)
+	require.Nil(err)
+	assert.Nil(c.GetData("mid/source/5.ts"))
+	data, err = rsess.ReadData("source/5.ts")
+	assert.Nil(err)
+	assert.Equal([]byte("ddddddddddd"), data)
+
+	// deleted from both
+	require.Nil(sess.DeleteData("source/1.ts"))
+	assert.Nil(c.GetData("mid/source/1.ts"))
+	_, err = rsess.ReadData("source/1.ts")
+	assert.Equal(ErrNotFound, err)
+	assert.Equal(int64(4), c.size)
+
+	// streamed data is cached if it fits
+	_, err = sess.SaveDataReader("source/7.ts", bytes.NewReader([]byte("ffff")), 4)
+	require.Nil(err)
+	assert.Equal([]byte("ffff"), c.GetData("mid/source/7.ts"))
+	_, err = sess.SaveDataReader("source/8.ts", bytes.NewReader([]byte("gggg")), -1)
+	require.Nil(err)
+	assert.Nil(c.GetData("mid/source/8.ts"))
+	data, err = rsess.ReadData("source/8.ts")
+	assert.Nil(err)
+	assert.Equal([]byte("gggg"), data)
+
+	// remote errors aren't cached
+	sess.EndSession()
+	_, err = sess.SaveData("source/6.ts", []byte("ee"), nil)
+	assert.NotNil(err)
+	assert.Nil(c.GetData("mid/source/6.ts"))
+}
@@ -0,0 +1,102 @@
+package drivers
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// ContentTypes maps file extensions to the content type used when uploading
+// to object storage. Extensions not listed here fall back to sniffing the data,
+// which often yields application/octet-stream for media files
+var ContentTypes = map[string]string{
+	".ts":   "video/mp2t",
+	".m4s":  "video/iso.segment",
+	".mp4":  "video/mp4",
+	".m3u8": "application/x-mpegURL",
+	".mpd":  "application/dash+xml",
+}
+
+// ParseContentTypes parses a comma separated list of extension to content type
+// mappings, e.g. ".ts=video/mp2t,.mpd=application/dash+xml"
+func ParseContentTypes(s string) (map[string]string, error) {
+	types := make(map[string]string)
+	for _, kv := range strings.Split(s, ",") {
+		kv = strings.TrimSpace(kv)
+		if kv == "" {
+			continue
+		}
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid content type mapping=%s", kv)
+		}
+		ext, typ := strings.ToLower(strings.TrimSpace(parts[0])), strings.TrimSpace(parts[1])
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		if len(ext) < 2 || typ == "" {
+			return nil, fmt.Errorf("invalid content type mapping=%s", kv)
+		}
+		types[ext] = typ
+	}
+	return types, nil
+}
+
+// contentType returns the explicit content type if set, detected one otherwise
+func (props *FileProperties) contentType(fileName string, data []byte) string {
+	if props != nil && props.ContentType != "" {
+		return props.ContentType
+	}
+	return detectContentType(fileName, data)
+}
+
+// contentTypeReader returns the content type like contentType, reading the
+// beginning of the data from r if it has to be detected. Returned reader reads
+// all the data, it is r itself if r is io.ReadSeeker.
+func (props *FileProperties) contentTypeReader(fileName string, r io.Reader) (string, io.Reader, error) {
+	if props != nil && props.ContentType != "" {
+		return props.ContentType, r, nil
+	}
+	if typ, ok := ContentTypes[strings.ToLower(path.Ext(fileName))]; ok {
+		return typ, r, nil
+	}
+	// DetectContentType considers at most 512 bytes
+	if rs, ok := r.(io.ReadSeeker); ok {
+		start, err := rs.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return "", nil, err
+		}
+		head := make([]byte, 512)
+		n, err := io.ReadFull(rs, head)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return "", nil, err
+		}
+		if _, err := rs.Seek(start, io.SeekStart); err != nil {
+			return "", nil, err
+		}
+		return http.DetectContentType(head[:n]), rs, nil
+	}
+	br := bufio.NewReaderSize(r, 512)
+	head, err := br.Peek(512)
+	if err != nil && err != io.EOF {
+		return "", nil, err
+	}
+	return http.DetectContentType(head), br, nil
+}
+
+func (props *FileProperties) cacheControl() string {
+	if props == nil {
+		return ""
+	}
+	return props.CacheControl
+}
+
+func detectContentType(fileName string, data []byte) string {
+	if typ, ok := ContentTypes[strings.ToLower(path.Ext(fileName))]; ok {
+		return typ
+	}
+	return http.DetectContentType(data)
+}
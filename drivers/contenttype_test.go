@@ -0,0 +1,53 @@
+package drivers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectContentType(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("video/mp2t", detectContentType("stream/1.ts", []byte("abc")))
+	assert.Equal("video/iso.segment", detectContentType("stream/1.m4s", []byte("abc")))
+	assert.Equal("video/mp4", detectContentType("stream/1.mp4", []byte("abc")))
+	assert.Equal("application/x-mpegURL", detectContentType("stream/index.m3u8", []byte("#EXTM3U")))
+	assert.Equal("application/dash+xml", detectContentType("stream/index.mpd", []byte("<?xml")))
+	// extension is case insensitive
+	assert.Equal("video/mp2t", detectContentType("stream/1.TS", []byte("abc")))
+
+	// unknown extensions fall back to sniffing
+	assert.Equal("text/plain; charset=utf-8", detectContentType("stream/1.txt", []byte("abc")))
+	assert.Equal("image/png", detectContentType("stream/1", []byte("\x89PNG\x0D\x0A\x1A\x0A")))
+
+	// configured mappings are consulted first
+	ContentTypes[".txt"] = "text/x-custom"
+	defer delete(ContentTypes, ".txt")
+	assert.Equal("text/x-custom", detectContentType("stream/1.txt", []byte("abc")))
+}
+
+func TestParseContentTypes(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	types, err := ParseContentTypes(".ts=video/mp2t, m4s=video/iso.segment,.MPD=application/dash+xml,")
+	require.Nil(err)
+	assert.Equal(map[string]string{
+		".ts":  "video/mp2t",
+		".m4s": "video/iso.segment",
+		".mpd": "application/dash+xml",
+	}, types)
+
+	types, err = ParseContentTypes("")
+	assert.Nil(err)
+	assert.Len(types, 0)
+
+	_, err = ParseContentTypes(".ts")
+	assert.EqualError(err, "invalid content type mapping=.ts")
+	_, err = ParseContentTypes(".ts=")
+	assert.EqualError(err, "invalid content type mapping=.ts=")
+	_, err = ParseContentTypes("=video/mp2t")
+	assert.EqualError(err, "invalid content type mapping==video/mp2t")
+}
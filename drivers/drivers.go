@@ -3,7 +3,9 @@ package drivers
 
 import (
 	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"time"
@@ -16,15 +18,48 @@ import (
 // NodeStorage is current node's primary driver
 var NodeStorage OSDriver
 
+// ErrNotSupported is returned when an operation isn't supported by the storage
+var ErrNotSupported = errors.New("Operation not supported")
+
+// ErrNotFound is returned when there is no object stored under the name
+var ErrNotFound = errors.New("Not found")
+
 // OSDriver common interface for Object Storage
 type OSDriver interface {
 	NewSession(path string) OSSession
 }
 
+// FileInfo describes an object stored in the Object Storage
+type FileInfo struct {
+	// Name relative to the session's path
+	Name         string
+	LastModified time.Time
+	Size         int64
+}
+
+// FileProperties are optional properties of the saved object. Content type is
+// detected from the name and the data if empty, other empty properties aren't set.
+type FileProperties struct {
+	ContentType  string
+	CacheControl string
+}
+
 type OSSession interface {
-	SaveData(name string, data []byte) (string, error)
+	// SaveData saves the data under the name, props may be nil
+	SaveData(name string, data []byte, props *FileProperties) (string, error)
+	// SaveDataReader saves the data of the size, -1 if unknown, read from r
+	// under the name, without holding all of it in memory where the storage
+	// allows
+	SaveDataReader(name string, r io.Reader, size int64) (string, error)
 	EndSession()
 
+	// ListData returns info about all the objects stored under the session's path
+	ListData() ([]*FileInfo, error)
+	// DeleteData removes object saved under the name
+	DeleteData(name string) error
+	// ReadData returns object saved under the name, ErrNotFound if there is none
+	ReadData(name string) ([]byte, error)
+
 	// Info in order to have this session used via RPC
 	GetInfo() *net.OSInfo
 
@@ -32,6 +67,14 @@ type OSSession interface {
 	IsExternal() bool
 }
 
+// Presigner is implemented by the sessions that can give time-limited read
+// access to the stored objects
+type Presigner interface {
+	// PresignedGetURL returns URL the object saved under the name can be read
+	// from until the expiry passes
+	PresignedGetURL(name string, expiry time.Duration) (string, error)
+}
+
 // NewSession returns new session based on OSInfo received from the network
 func NewSession(info *net.OSInfo) OSSession {
 	if info == nil {
@@ -42,12 +85,14 @@ func NewSession(info *net.OSInfo) OSSession {
 		return newS3Session(info.S3Info)
 	case net.OSInfo_GOOGLE:
 		return newGSSession(info.S3Info)
+	case net.OSInfo_AZURE:
+		return newAzureSession(info.AzureInfo)
 	}
 	return nil
 }
 
 func IsOwnExternal(uri string) bool {
-	return IsOwnStorageS3(uri) || IsOwnStorageGS(uri)
+	return IsOwnStorageS3(uri) || IsOwnStorageGS(uri) || IsOwnStorageAzure(uri)
 }
 
 func GetSegmentData(uri string) ([]byte, error) {
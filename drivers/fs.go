@@ -0,0 +1,170 @@
+package drivers
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/livepeer/go-livepeer/common"
+	"github.com/livepeer/go-livepeer/net"
+)
+
+// FSOS saves the data to the files under the base directory, to be served by
+// the node's HTTP server under /stream/
+type FSOS struct {
+	baseDir string
+	baseURL string
+}
+
+type FSSession struct {
+	os   *FSOS
+	path string
+}
+
+// NewFSDriver returns driver saving the data under baseDir. URLs of the saved
+// data are relative to /stream/ of the node's HTTP server at baseURL, which may
+// be empty.
+func NewFSDriver(baseDir, baseURL string) OSDriver {
+	return &FSOS{
+		baseDir: baseDir,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+	}
+}
+
+func (fs *FSOS) NewSession(path string) OSSession {
+	return &FSSession{os: fs, path: path}
+}
+
+// GetData returns the data saved under the name, nil if there is none. Name is
+// either the URL returned by SaveData or the path relative to /stream/.
+func (fs *FSOS) GetData(name string) []byte {
+	name = strings.TrimPrefix(strings.TrimPrefix(name, fs.baseURL), "/stream/")
+	data, err := ioutil.ReadFile(fs.filePath(name))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			glog.Errorf("Error reading file name=%s err=%v", name, err)
+		}
+		return nil
+	}
+	return data
+}
+
+// filePath returns the file of the name relative to the base directory, never
+// outside of it
+func (fs *FSOS) filePath(name string) string {
+	return filepath.Join(fs.baseDir, filepath.FromSlash(path.Clean("/"+name)))
+}
+
+// EndSession keeps the saved files, so they are served till deleted
+func (sess *FSSession) EndSession() {
+}
+
+func (sess *FSSession) IsExternal() bool {
+	return false
+}
+
+func (sess *FSSession) GetInfo() *net.OSInfo {
+	return &net.OSInfo{StorageType: net.OSInfo_DIRECT}
+}
+
+// GetData returns the data saved under the name, nil if there is none
+func (sess *FSSession) GetData(name string) []byte {
+	return sess.os.GetData(name)
+}
+
+func (sess *FSSession) SaveData(name string, data []byte, props *FileProperties) (string, error) {
+	return sess.SaveDataReader(name, bytes.NewReader(data), int64(len(data)))
+}
+
+func (sess *FSSession) SaveDataReader(name string, r io.Reader, size int64) (string, error) {
+	name = sess.getAbsolutePath(name)
+	fname := sess.os.filePath(name)
+	if err := os.MkdirAll(filepath.Dir(fname), 0755); err != nil {
+		glog.Errorf("Error creating directory name=%s err=%v", name, err)
+		return "", err
+	}
+	// write to temporary file first, so partial data is never served
+	tmp := fname + ".tmp"
+	if err := writeFile(tmp, r); err != nil {
+		glog.Errorf("Error writing file name=%s err=%v", name, err)
+		os.Remove(tmp)
+		return "", err
+	}
+	if err := os.Rename(tmp, fname); err != nil {
+		glog.Errorf("Error writing file name=%s err=%v", name, err)
+		os.Remove(tmp)
+		return "", err
+	}
+	glog.V(common.VERBOSE).Infof("Saved to file name=%s", name)
+	return sess.os.baseURL + "/stream/" + name, nil
+}
+
+func (sess *FSSession) ListData() ([]*FileInfo, error) {
+	dir := sess.os.filePath(sess.getAbsolutePath(""))
+	var files []*FileInfo
+	err := filepath.Walk(dir, func(fname string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || strings.HasSuffix(fname, ".tmp") {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, fname)
+		if err != nil {
+			return err
+		}
+		files = append(files, &FileInfo{
+			Name:         filepath.ToSlash(rel),
+			LastModified: info.ModTime(),
+			Size:         info.Size(),
+		})
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		glog.Errorf("Error listing files dir=%s err=%v", dir, err)
+		return nil, err
+	}
+	return files, nil
+}
+
+func (sess *FSSession) DeleteData(name string) error {
+	err := os.Remove(sess.os.filePath(sess.getAbsolutePath(name)))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (sess *FSSession) ReadData(name string) ([]byte, error) {
+	data, err := ioutil.ReadFile(sess.os.filePath(sess.getAbsolutePath(name)))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+func writeFile(fname string, r io.Reader) error {
+	f, err := os.OpenFile(fname, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// getAbsolutePath returns the path of the name relative to the base directory,
+// never outside of it
+func (sess *FSSession) getAbsolutePath(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+sess.path+"/"+name), "/")
+}
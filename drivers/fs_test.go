@@ -0,0 +1,93 @@
+package drivers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/livepeer/go-livepeer/net"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFSOS(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", t.Name())
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+
+	fs := NewFSDriver(dir, "https://node:8935/").(*FSOS)
+	sess := fs.NewSession("sesspath")
+	assert.False(sess.IsExternal())
+	assert.Equal(&net.OSInfo{StorageType: net.OSInfo_DIRECT}, sess.GetInfo())
+
+	// saved to the file and served by its URL or the path relative to /stream/
+	uri, err := sess.SaveData("name1/1.ts", []byte("data1"), nil)
+	require.Nil(err)
+	assert.Equal("https://node:8935/stream/sesspath/name1/1.ts", uri)
+	data, err := ioutil.ReadFile(filepath.Join(dir, "sesspath", "name1", "1.ts"))
+	require.Nil(err)
+	assert.Equal("data1", string(data))
+	assert.Equal("data1", string(fs.GetData(uri)))
+	assert.Equal("data1", string(fs.GetData("sesspath/name1/1.ts")))
+	assert.Equal("data1", string(sess.(*FSSession).GetData("/stream/sesspath/name1/1.ts")))
+	assert.Nil(fs.GetData("sesspath/name1/2.ts"))
+
+	// overwritten
+	_, err = sess.SaveData("name1/1.ts", []byte("data2"), nil)
+	require.Nil(err)
+	data, err = sess.ReadData("name1/1.ts")
+	require.Nil(err)
+	assert.Equal("data2", string(data))
+	_, err = sess.ReadData("name1/2.ts")
+	assert.Equal(ErrNotFound, err)
+
+	_, err = sess.SaveData("name2/1.ts", []byte("data3"), nil)
+	require.Nil(err)
+	files, err := sess.ListData()
+	require.Nil(err)
+	require.Len(files, 2)
+	assert.Equal("name1/1.ts", files[0].Name)
+	assert.Equal(int64(5), files[0].Size)
+	assert.Equal("name2/1.ts", files[1].Name)
+
+	// files are kept after the session ends
+	sess.EndSession()
+	assert.Equal("data3", string(fs.GetData("sesspath/name2/1.ts")))
+
+	require.Nil(sess.DeleteData("name1/1.ts"))
+	require.Nil(sess.DeleteData("name1/1.ts"))
+	assert.Nil(fs.GetData("sesspath/name1/1.ts"))
+
+	files, err = fs.NewSession("other").ListData()
+	assert.Nil(err)
+	assert.Empty(files)
+
+	// data outside of the base directory isn't reachable
+	require.Nil(ioutil.WriteFile(filepath.Join(filepath.Dir(dir), "fs_outside"), []byte("secret"), 0644))
+	defer os.Remove(filepath.Join(filepath.Dir(dir), "fs_outside"))
+	assert.Nil(fs.GetData("../fs_outside"))
+	assert.Nil(fs.GetData("sesspath/../../fs_outside"))
+	uri, err = fs.NewSession("sesspath").SaveData("../../escape.ts", []byte("data4"), nil)
+	require.Nil(err)
+	assert.Equal("https://node:8935/stream/escape.ts", uri)
+	_, err = os.Stat(filepath.Join(dir, "escape.ts"))
+	assert.Nil(err)
+
+	// relative URLs without base URL
+	uri, err = NewFSDriver(dir, "").NewSession("sesspath").SaveData("name1/1.ts", []byte("data5"), nil)
+	require.Nil(err)
+	assert.Equal("/stream/sesspath/name1/1.ts", uri)
+	assert.Equal("data5", string(fs.GetData(uri)))
+
+	// streamed to the file
+	_, err = sess.SaveDataReader("name1/2.ts", strings.NewReader("data6"), -1)
+	require.Nil(err)
+	data, err = sess.ReadData("name1/2.ts")
+	assert.Nil(err)
+	assert.Equal("data6", string(data))
+}
@@ -1,6 +1,8 @@
 package drivers
 
 import (
+	"bytes"
+	"context"
 	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
@@ -11,11 +13,20 @@ import (
 	"encoding/pem"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net/url"
+	"path"
+	"strconv"
 	"strings"
 	"time"
 
+	"cloud.google.com/go/storage"
+	"github.com/golang/glog"
+	"github.com/livepeer/go-livepeer/common"
 	"github.com/livepeer/go-livepeer/net"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
 )
 
 type (
@@ -40,11 +51,23 @@ type (
 	gsOS struct {
 		s3OS
 		gsSigner *gsSigner
+		client   *storage.Client
+	}
+
+	// gsSession saves objects to our own bucket with the native GCS client.
+	// Embedded s3Session provides POST policy given to other nodes.
+	gsSession struct {
+		*s3Session
+		client *storage.Client
 	}
 )
 
 var GSBUCKET string
 
+// GSUploadChunkSize is the size of the chunks objects bigger than it are
+// uploaded in with the resumable upload
+var GSUploadChunkSize = 16 * 1024 * 1024
+
 // IsOwnStorageGS returns true if uri points to Google Cloud Storage bucket owned by this node
 func IsOwnStorageGS(uri string) bool {
 	return strings.HasPrefix(uri, gsHost(GSBUCKET))
@@ -95,21 +118,117 @@ func NewGoogleDriver(bucket, keyFileName string) (OSDriver, error) {
 		jsKey:     &gsKey,
 		parsedKey: parsedKey,
 	}
+	client, err := storage.NewClient(context.Background(), option.WithCredentialsJSON(rawFile))
+	if err != nil {
+		return nil, err
+	}
+	os.client = client
 	return os, nil
 }
 
 func (os *gsOS) NewSession(path string) OSSession {
+	path = renderPath(StoragePathTemplate, path, time.Now())
 	var policy, signature = gsCreatePolicy(os.gsSigner, os.bucket, os.region, path)
 	sess := &s3Session{
 		host:        gsHost(os.bucket),
+		bucket:      os.bucket,
 		key:         path,
 		policy:      policy,
 		signature:   signature,
 		credential:  os.gsSigner.clientEmail(),
 		storageType: net.OSInfo_GOOGLE,
+		gsSigner:    os.gsSigner,
+		acl:         S3DefaultACL,
 	}
 	sess.fields = gsGetFields(sess)
-	return sess
+	if os.client == nil {
+		return sess
+	}
+	return &gsSession{s3Session: sess, client: os.client}
+}
+
+func (os *gsSession) SaveData(name string, data []byte, props *FileProperties) (string, error) {
+	return os.saveReader(name, bytes.NewReader(data), props)
+}
+
+// SaveDataReader streams the data to GS in chunks of GSUploadChunkSize
+func (os *gsSession) SaveDataReader(name string, r io.Reader, size int64) (string, error) {
+	return os.saveReader(name, r, nil)
+}
+
+func (os *gsSession) saveReader(name string, r io.Reader, props *FileProperties) (string, error) {
+	key := path.Join(os.key, name)
+	glog.V(common.VERBOSE).Infof("Saving to GS bucket=%s key=%s", os.bucket, key)
+	contentType, r, err := props.contentTypeReader(name, r)
+	if err != nil {
+		glog.Errorf("Save GS error: %v", err)
+		return "", err
+	}
+	w := os.client.Bucket(os.bucket).Object(key).NewWriter(context.Background())
+	w.ContentType = contentType
+	w.CacheControl = props.cacheControl()
+	w.PredefinedACL = "publicRead"
+	w.ChunkSize = GSUploadChunkSize
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		glog.Errorf("Save GS error: %v", err)
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		glog.Errorf("Save GS error: %v", err)
+		return "", err
+	}
+	glog.V(common.VERBOSE).Infof("Saved to GS bucket=%s key=%s", os.bucket, key)
+	return os.getAbsURL(key), nil
+}
+
+func (os *gsSession) ReadData(name string) ([]byte, error) {
+	r, err := os.client.Bucket(os.bucket).Object(path.Join(os.key, name)).NewReader(context.Background())
+	if err == storage.ErrObjectNotExist {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	buf := &bytes.Buffer{}
+	if _, err := io.Copy(buf, r); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (os *gsSession) ListData() ([]*FileInfo, error) {
+	var files []*FileInfo
+	it := os.client.Bucket(os.bucket).Objects(context.Background(), &storage.Query{Prefix: os.key})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			glog.Errorf("Error listing GS bucket=%s prefix=%s err=%v", os.bucket, os.key, err)
+			return nil, err
+		}
+		files = append(files, &FileInfo{
+			Name:         strings.TrimPrefix(strings.TrimPrefix(attrs.Name, os.key), "/"),
+			LastModified: attrs.Updated,
+			Size:         attrs.Size,
+		})
+	}
+	return files, nil
+}
+
+func (os *gsSession) DeleteData(name string) error {
+	key := path.Join(os.key, name)
+	err := os.client.Bucket(os.bucket).Object(key).Delete(context.Background())
+	if err == storage.ErrObjectNotExist {
+		return nil
+	}
+	if err != nil {
+		glog.Errorf("Error deleting from GS bucket=%s key=%s err=%v", os.bucket, key, err)
+	}
+	return err
 }
 
 func newGSSession(info *net.S3OSInfo) OSSession {
@@ -120,6 +239,7 @@ func newGSSession(info *net.S3OSInfo) OSSession {
 		signature:   info.Signature,
 		credential:  info.Credential,
 		storageType: net.OSInfo_GOOGLE,
+		acl:         S3DefaultACL,
 	}
 	sess.fields = gsGetFields(sess)
 	return sess
@@ -144,6 +264,7 @@ func gsCreatePolicy(signer *gsSigner, bucket, region, path string) (string, stri
       {"bucket": "%s"},
       {"acl": "public-read"},
       ["starts-with", "$Content-Type", ""],
+      ["starts-with", "$Cache-Control", ""],
       ["starts-with", "$key", "%s"]
     ]
   }`, expireFmt, bucket, path)
@@ -152,6 +273,19 @@ func gsCreatePolicy(signer *gsSigner, bucket, region, path string) (string, stri
 	return policy, sign
 }
 
+// gsPresignedGetURL returns V2 signed URL to read the object until expireAt
+func gsPresignedGetURL(signer *gsSigner, bucket, key string, expireAt time.Time) string {
+	expires := strconv.FormatInt(expireAt.Unix(), 10)
+	object := (&url.URL{Path: "/" + key}).EscapedPath()
+	signature := signer.sign("GET\n\n\n" + expires + "\n/" + bucket + object)
+	query := url.Values{
+		"GoogleAccessId": {signer.clientEmail()},
+		"Expires":        {expires},
+		"Signature":      {signature},
+	}
+	return gsHost(bucket) + object + "?" + query.Encode()
+}
+
 func (s *gsSigner) sign(mes string) string {
 	h := sha256.New()
 	h.Write([]byte(mes))
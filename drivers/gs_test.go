@@ -0,0 +1,253 @@
+package drivers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/livepeer/go-livepeer/net"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/option"
+)
+
+// stubGS handles GCS JSON API requests of the storage client
+type stubGS struct {
+	mu           sync.Mutex
+	objects      map[string][]byte
+	contentType  map[string]string
+	cacheControl map[string]string
+	acl          string
+	chunks       int // resumable upload requests
+	resumable    map[string][]byte
+}
+
+func newStubGS() *stubGS {
+	return &stubGS{
+		objects:      make(map[string][]byte),
+		contentType:  make(map[string]string),
+		cacheControl: make(map[string]string),
+		resumable:    make(map[string][]byte),
+	}
+}
+
+type stubGSObject struct {
+	Name         string `json:"name"`
+	Bucket       string `json:"bucket"`
+	ContentType  string `json:"contentType,omitempty"`
+	CacheControl string `json:"cacheControl,omitempty"`
+	Size         string `json:"size"`
+	Updated      string `json:"updated"`
+}
+
+func (s *stubGS) object(name string) *stubGSObject {
+	return &stubGSObject{
+		Name:        name,
+		Bucket:      "bucket",
+		ContentType: s.contentType[name],
+		Size:        fmt.Sprint(len(s.objects[name])),
+		Updated:     time.Now().UTC().Format(time.RFC3339),
+	}
+}
+
+func (s *stubGS) notFound(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusNotFound)
+	fmt.Fprint(w, `{"error": {"code": 404, "message": "Not Found"}}`)
+}
+
+func (s *stubGS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q := r.URL.Query()
+	switch {
+	case r.Method == "POST" && r.URL.Path == "/b/bucket/o" && q.Get("uploadType") == "multipart":
+		s.acl = q.Get("predefinedAcl")
+		_, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		mr := multipart.NewReader(r.Body, params["boundary"])
+		var meta stubGSObject
+		part, _ := mr.NextPart()
+		json.NewDecoder(part).Decode(&meta)
+		part, _ = mr.NextPart()
+		data, _ := ioutil.ReadAll(part)
+		s.objects[meta.Name] = data
+		s.contentType[meta.Name] = meta.ContentType
+		s.cacheControl[meta.Name] = meta.CacheControl
+		json.NewEncoder(w).Encode(s.object(meta.Name))
+	case r.Method == "POST" && r.URL.Path == "/b/bucket/o" && q.Get("uploadType") == "resumable":
+		s.acl = q.Get("predefinedAcl")
+		var meta stubGSObject
+		json.NewDecoder(r.Body).Decode(&meta)
+		s.contentType[meta.Name] = meta.ContentType
+		s.resumable[meta.Name] = nil
+		w.Header().Set("Location", "http://"+r.Host+"/resumable?name="+meta.Name)
+	case r.Method == "POST" && r.URL.Path == "/resumable":
+		s.chunks++
+		name := q.Get("name")
+		data, _ := ioutil.ReadAll(r.Body)
+		s.resumable[name] = append(s.resumable[name], data...)
+		if strings.HasSuffix(r.Header.Get("Content-Range"), "/*") {
+			w.Header().Set("X-Http-Status-Code-Override", "308")
+			return
+		}
+		s.objects[name] = s.resumable[name]
+		delete(s.resumable, name)
+		json.NewEncoder(w).Encode(s.object(name))
+	case r.Method == "GET" && r.URL.Path == "/b/bucket/o":
+		var items []*stubGSObject
+		for name := range s.objects {
+			if strings.HasPrefix(name, q.Get("prefix")) {
+				items = append(items, s.object(name))
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"kind": "storage#objects", "items": items})
+	case r.Method == "DELETE" && strings.HasPrefix(r.URL.Path, "/b/bucket/o/"):
+		name := strings.TrimPrefix(r.URL.Path, "/b/bucket/o/")
+		if _, ok := s.objects[name]; !ok {
+			s.notFound(w)
+			return
+		}
+		delete(s.objects, name)
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == "GET" && strings.HasPrefix(r.URL.Path, "/bucket/"):
+		data, ok := s.objects[strings.TrimPrefix(r.URL.Path, "/bucket/")]
+		if !ok {
+			s.notFound(w)
+			return
+		}
+		w.Write(data)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func TestGSSession(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	defer func(chunkSize int) { GSUploadChunkSize = chunkSize }(GSUploadChunkSize)
+	GSUploadChunkSize = 256 * 1024
+
+	stub := newStubGS()
+	ts := httptest.NewServer(stub)
+	defer ts.Close()
+
+	// storage client reads objects from and, for uploads, uses the emulator host
+	defer os.Unsetenv("STORAGE_EMULATOR_HOST")
+	os.Setenv("STORAGE_EMULATOR_HOST", strings.TrimPrefix(ts.URL, "http://"))
+	client, err := storage.NewClient(context.Background(), option.WithEndpoint(ts.URL+"/"), option.WithoutAuthentication())
+	require.Nil(err)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(err)
+	signer := &gsSigner{jsKey: &gsKeyJSON{ClientEmail: "test@example.com"}, parsedKey: key}
+	gs := &gsOS{s3OS: s3OS{bucket: "bucket"}, gsSigner: signer, client: client}
+	sess := gs.NewSession("path")
+	require.IsType(&gsSession{}, sess)
+
+	// POST policy is still given to other nodes
+	info := sess.GetInfo()
+	assert.Equal(net.OSInfo_GOOGLE, info.StorageType)
+	assert.Equal("https://bucket.storage.googleapis.com", info.S3Info.Host)
+	assert.Equal("path", info.S3Info.Key)
+	assert.Equal("test@example.com", info.S3Info.Credential)
+	assert.NotEmpty(info.S3Info.Policy)
+	assert.NotEmpty(info.S3Info.Signature)
+	assert.IsType(&s3Session{}, NewSession(info))
+
+	// small object is uploaded with single request
+	uri, err := sess.SaveData("name/1.ts", []byte("data"), nil)
+	require.Nil(err)
+	assert.Equal("https://bucket.storage.googleapis.com/path/name/1.ts", uri)
+	assert.Equal([]byte("data"), stub.objects["path/name/1.ts"])
+	assert.Equal("video/mp2t", stub.contentType["path/name/1.ts"])
+	assert.Equal("publicRead", stub.acl)
+	assert.Equal(0, stub.chunks)
+
+	// explicit properties
+	props := &FileProperties{ContentType: "application/vnd.apple.mpegurl", CacheControl: "max-age=1"}
+	_, err = sess.SaveData("name/index.m3u8", []byte("#EXTM3U"), props)
+	require.Nil(err)
+	assert.Equal("application/vnd.apple.mpegurl", stub.contentType["path/name/index.m3u8"])
+	assert.Equal("max-age=1", stub.cacheControl["path/name/index.m3u8"])
+	delete(stub.objects, "path/name/index.m3u8")
+
+	// big object is uploaded in chunks with resumable upload
+	big := make([]byte, 600*1024)
+	big[len(big)-1] = 1
+	uri, err = sess.SaveData("name/1.mp4", big, nil)
+	require.Nil(err)
+	assert.Equal("https://bucket.storage.googleapis.com/path/name/1.mp4", uri)
+	assert.Equal(big, stub.objects["path/name/1.mp4"])
+	assert.Equal("video/mp4", stub.contentType["path/name/1.mp4"])
+	assert.Equal(3, stub.chunks)
+
+	data, err := sess.ReadData("name/1.ts")
+	assert.Nil(err)
+	assert.Equal([]byte("data"), data)
+	_, err = sess.ReadData("name/2.ts")
+	assert.Equal(ErrNotFound, err)
+
+	stub.objects["other/1.ts"] = []byte("other")
+	files, err := sess.ListData()
+	require.Nil(err)
+	require.Len(files, 2)
+	sizes := make(map[string]int64)
+	for _, f := range files {
+		sizes[f.Name] = f.Size
+		assert.WithinDuration(time.Now(), f.LastModified, time.Minute)
+	}
+	assert.Equal(map[string]int64{"name/1.ts": 4, "name/1.mp4": int64(len(big))}, sizes)
+
+	assert.Nil(sess.DeleteData("name/1.ts"))
+	assert.NotContains(stub.objects, "path/name/1.ts")
+	// deleting non-existing data isn't an error
+	assert.Nil(sess.DeleteData("name/1.ts"))
+	assert.Contains(stub.objects, "other/1.ts")
+}
+
+func TestNewGoogleDriver(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "gskey")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+
+	_, err = NewGoogleDriver("bucket", filepath.Join(dir, "missing.json"))
+	assert.NotNil(err)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(err)
+	keyJSON, err := json.Marshal(&gsKeyJSON{
+		Type:        "service_account",
+		ClientEmail: "test@example.com",
+		PrivateKey:  string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})),
+		TokenURI:    "https://oauth2.googleapis.com/token",
+	})
+	require.Nil(err)
+	keyFile := filepath.Join(dir, "key.json")
+	require.Nil(ioutil.WriteFile(keyFile, keyJSON, 0600))
+
+	driver, err := NewGoogleDriver("bucket", keyFile)
+	require.Nil(err)
+	assert.NotNil(driver.(*gsOS).client)
+	sess := driver.NewSession("path")
+	assert.IsType(&gsSession{}, sess)
+	assert.Equal("test@example.com", sess.GetInfo().S3Info.Credential)
+}
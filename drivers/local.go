@@ -2,10 +2,13 @@ package drivers
 
 import (
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/url"
 	"path"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/livepeer/go-livepeer/net"
 )
@@ -109,7 +112,7 @@ func (ostore *MemorySession) GetInfo() *net.OSInfo {
 	return nil
 }
 
-func (ostore *MemorySession) SaveData(name string, data []byte) (string, error) {
+func (ostore *MemorySession) SaveData(name string, data []byte, props *FileProperties) (string, error) {
 	path, file := path.Split(ostore.getAbsolutePath(name))
 
 	ostore.dLock.Lock()
@@ -125,6 +128,63 @@ func (ostore *MemorySession) SaveData(name string, data []byte) (string, error)
 	return ostore.getAbsoluteURI(name), nil
 }
 
+// SaveDataReader reads all the data to memory, where it is kept anyway
+func (ostore *MemorySession) SaveDataReader(name string, r io.Reader, size int64) (string, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	return ostore.SaveData(name, data, nil)
+}
+
+func (ostore *MemorySession) ListData() ([]*FileInfo, error) {
+	prefix := strings.TrimSuffix(ostore.getAbsolutePath(""), "/") + "/"
+
+	ostore.dLock.RLock()
+	defer ostore.dLock.RUnlock()
+
+	var files []*FileInfo
+	for path, dc := range ostore.dCache {
+		for _, item := range dc.cache {
+			if item.name == "" {
+				continue
+			}
+			files = append(files, &FileInfo{
+				Name:         strings.TrimPrefix(path+item.name, prefix),
+				LastModified: item.saved,
+				Size:         int64(len(item.data)),
+			})
+		}
+	}
+	return files, nil
+}
+
+func (ostore *MemorySession) DeleteData(name string) error {
+	path, file := path.Split(ostore.getAbsolutePath(name))
+
+	ostore.dLock.Lock()
+	defer ostore.dLock.Unlock()
+
+	if dc, ok := ostore.dCache[path]; ok {
+		dc.Delete(file)
+	}
+	return nil
+}
+
+func (ostore *MemorySession) ReadData(name string) ([]byte, error) {
+	path, file := path.Split(ostore.getAbsolutePath(name))
+
+	ostore.dLock.RLock()
+	defer ostore.dLock.RUnlock()
+
+	if dc, ok := ostore.dCache[path]; ok {
+		if data := dc.GetData(file); data != nil {
+			return data, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
 func (ostore *MemorySession) getCacheForStream(streamID string) *dataCache {
 	sc, ok := ostore.dCache[streamID]
 	if !ok {
@@ -153,8 +213,9 @@ type dataCache struct {
 }
 
 type dataCacheItem struct {
-	name string
-	data []byte
+	name  string
+	data  []byte
+	saved time.Time
 }
 
 func newDataCache(len int) *dataCache {
@@ -165,18 +226,26 @@ func (dc *dataCache) Insert(name string, data []byte) {
 	// replace existing item
 	for i, item := range dc.cache {
 		if item.name == name {
-			dc.cache[i] = dataCacheItem{name: name, data: data}
+			dc.cache[i] = dataCacheItem{name: name, data: data, saved: time.Now()}
 			return
 		}
 	}
-	dc.cache[dc.nextFree].name = name
-	dc.cache[dc.nextFree].data = data
+	dc.cache[dc.nextFree] = dataCacheItem{name: name, data: data, saved: time.Now()}
 	dc.nextFree++
 	if dc.nextFree >= dc.cacheLen {
 		dc.nextFree = 0
 	}
 }
 
+func (dc *dataCache) Delete(name string) {
+	for i, item := range dc.cache {
+		if item.name == name {
+			dc.cache[i] = dataCacheItem{}
+			return
+		}
+	}
+}
+
 func (dc *dataCache) GetData(name string) []byte {
 	for _, s := range dc.cache {
 		if s.name == name {
@@ -0,0 +1,67 @@
+package drivers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// StoragePathTemplate lays out the objects of the S3, Google Cloud Storage and
+// Azure Blob Storage buckets owned by this node, e.g. "year={year}/month={month}/day={day}/{stream}".
+// {stream} is replaced by the session path, {year}, {month}, {day} and {hour}
+// by the UTC time the session is created. Session path is used as is if empty.
+var StoragePathTemplate string
+
+var pathPlaceholders = map[string]func(stream string, t time.Time) string{
+	"stream": func(stream string, t time.Time) string { return stream },
+	"year":   func(stream string, t time.Time) string { return fmt.Sprintf("%04d", t.Year()) },
+	"month":  func(stream string, t time.Time) string { return fmt.Sprintf("%02d", t.Month()) },
+	"day":    func(stream string, t time.Time) string { return fmt.Sprintf("%02d", t.Day()) },
+	"hour":   func(stream string, t time.Time) string { return fmt.Sprintf("%02d", t.Hour()) },
+}
+
+// ValidatePathTemplate returns error if the template has unknown or unclosed
+// placeholders or lacks {stream}, which would mix objects of different streams
+func ValidatePathTemplate(tmpl string) error {
+	if strings.HasPrefix(tmpl, "/") {
+		return fmt.Errorf("path template=%s should not start with /", tmpl)
+	}
+	var hasStream bool
+	for rest := tmpl; rest != ""; {
+		start := strings.IndexAny(rest, "{}")
+		if start < 0 {
+			break
+		}
+		if rest[start] == '}' {
+			return fmt.Errorf("unexpected } in path template=%s", tmpl)
+		}
+		end := strings.IndexAny(rest[start+1:], "{}")
+		if end < 0 || rest[start+1+end] != '}' {
+			return fmt.Errorf("unclosed placeholder in path template=%s", tmpl)
+		}
+		name := rest[start+1 : start+1+end]
+		if _, ok := pathPlaceholders[name]; !ok {
+			return fmt.Errorf("unknown placeholder={%s} in path template=%s", name, tmpl)
+		}
+		hasStream = hasStream || name == "stream"
+		rest = rest[start+end+2:]
+	}
+	if !hasStream {
+		return fmt.Errorf("path template=%s should contain {stream}", tmpl)
+	}
+	return nil
+}
+
+// renderPath returns the session path laid out by the template at time t.
+// Empty path, that of the session over the whole bucket, isn't changed.
+func renderPath(tmpl, stream string, t time.Time) string {
+	if tmpl == "" || stream == "" {
+		return stream
+	}
+	t = t.UTC()
+	var oldnew []string
+	for name, fill := range pathPlaceholders {
+		oldnew = append(oldnew, "{"+name+"}", fill(stream, t))
+	}
+	return strings.NewReplacer(oldnew...).Replace(tmpl)
+}
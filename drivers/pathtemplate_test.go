@@ -0,0 +1,62 @@
+package drivers
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidatePathTemplate(t *testing.T) {
+	assert := assert.New(t)
+	assert.Nil(ValidatePathTemplate("{stream}"))
+	assert.Nil(ValidatePathTemplate("year={year}/month={month}/day={day}/hour={hour}/{stream}"))
+	assert.Nil(ValidatePathTemplate("live/{stream}/{year}-{month}-{day}"))
+	assert.EqualError(ValidatePathTemplate("year={year}"), "path template=year={year} should contain {stream}")
+	assert.EqualError(ValidatePathTemplate("{week}/{stream}"), "unknown placeholder={week} in path template={week}/{stream}")
+	assert.EqualError(ValidatePathTemplate("{year/{stream}"), "unclosed placeholder in path template={year/{stream}")
+	assert.EqualError(ValidatePathTemplate("{stream"), "unclosed placeholder in path template={stream")
+	assert.EqualError(ValidatePathTemplate("year}/{stream}"), "unexpected } in path template=year}/{stream}")
+	assert.EqualError(ValidatePathTemplate("/{stream}"), "path template=/{stream} should not start with /")
+}
+
+func TestRenderPath(t *testing.T) {
+	assert := assert.New(t)
+	// rendered in UTC
+	loc := time.FixedZone("UTC-5", -5*60*60)
+	ts := time.Date(2024, time.January, 14, 21, 30, 0, 0, loc)
+
+	assert.Equal("year=2024/month=01/day=15/hour=02/stream",
+		renderPath("year={year}/month={month}/day={day}/hour={hour}/{stream}", "stream", ts))
+	assert.Equal("live/stream/2024-01-15", renderPath("live/{stream}/{year}-{month}-{day}", "stream", ts))
+	// no template or empty path
+	assert.Equal("stream", renderPath("", "stream", ts))
+	assert.Equal("", renderPath("year={year}/{stream}", "", ts))
+	// placeholders in the stream aren't expanded
+	assert.Equal("2024/{year}", renderPath("{year}/{stream}", "{year}", ts))
+}
+
+func TestStoragePathTemplate(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	stub := &stubS3{parts: make(map[string]int)}
+	ts := httptest.NewServer(stub)
+	defer ts.Close()
+
+	defer func() { StoragePathTemplate = "" }()
+	StoragePathTemplate = "year={year}/month={month}/day={day}/{stream}"
+	now := time.Now().UTC()
+	partition := now.Format("year=2006/month=01/day=02/")
+
+	sess := NewS3Driver(S3Options{Region: "us-east-1", Bucket: "bucket", AccessKey: "key", AccessKeySecret: "secret", Endpoint: ts.URL, PathStyle: true}).NewSession("stream")
+	assert.Equal(partition+"stream", sess.GetInfo().S3Info.Key)
+	uri, err := sess.SaveData("seg.ts", []byte("data"), nil)
+	require.Nil(err)
+	assert.Equal(ts.URL+"/bucket/"+partition+"stream/seg.ts", uri)
+
+	// session over the whole bucket
+	assert.Equal("", NewS3Driver(S3Options{Region: "us-east-1", Bucket: "bucket", AccessKey: "key", AccessKeySecret: "secret", Endpoint: ts.URL, PathStyle: true}).NewSession("").GetInfo().S3Info.Key)
+}
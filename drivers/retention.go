@@ -0,0 +1,112 @@
+package drivers
+
+import (
+	"context"
+	"regexp"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/livepeer/go-livepeer/common"
+	"github.com/livepeer/go-livepeer/monitor"
+)
+
+// RetentionPolicy describes how long objects are kept in the storage
+type RetentionPolicy struct {
+	// MaxAge objects older than this are deleted
+	MaxAge time.Duration
+	// Interval how often the storage is checked for old objects
+	Interval time.Duration
+}
+
+// segmentKeyRe matches the keys the node stores stream segments under, i.e.
+// <stream path>/<seqNo><ext>
+var segmentKeyRe = regexp.MustCompile(`^.+/[0-9]+\.[0-9A-Za-z]+$`)
+
+// StartReaper periodically deletes segments older than the policy's MaxAge
+// from the session until the context is done. Objects with keys other than
+// segment keys are left alone.
+func StartReaper(ctx context.Context, sess OSSession, policy RetentionPolicy) {
+	glog.Infof("Starting storage reaper maxAge=%s interval=%s", policy.MaxAge, policy.Interval)
+	ticker := time.NewTicker(policy.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if _, _, err := reap(sess, policy.MaxAge); err != nil {
+				glog.Errorf("Error reaping storage err=%v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// DeleteAllData deletes all the objects stored under the session's path and
+// returns their number. Objects in our own S3 bucket are deleted in batches.
+// ErrNotSupported is returned for the sessions of storage of other nodes.
+func DeleteAllData(sess OSSession) (int, error) {
+	files, err := sess.ListData()
+	if err != nil {
+		return 0, err
+	}
+	count, _, err := deleteFiles(sess, files)
+	return count, err
+}
+
+// reap deletes segments older than maxAge, returns number of deleted objects and bytes freed
+func reap(sess OSSession, maxAge time.Duration) (int, int64, error) {
+	files, err := sess.ListData()
+	if err != nil {
+		return 0, 0, err
+	}
+	var old []*FileInfo
+	now := time.Now()
+	for _, f := range files {
+		if now.Sub(f.LastModified) > maxAge && segmentKeyRe.MatchString(f.Name) {
+			old = append(old, f)
+		}
+	}
+	// objects not deleted now will be tried again on the next round
+	count, freed, err := deleteFiles(sess, old)
+	if count > 0 {
+		glog.V(common.DEBUG).Infof("Reaped segments from storage count=%d bytes=%d", count, freed)
+	}
+	if monitor.Enabled {
+		monitor.SegmentsReaped(count, freed)
+	}
+	return count, freed, err
+}
+
+// deleteFiles deletes the files from the session, in batches for our own S3
+// bucket, returns number of deleted objects and bytes freed
+func deleteFiles(sess OSSession, files []*FileInfo) (int, int64, error) {
+	if len(files) == 0 {
+		return 0, 0, nil
+	}
+	var deleted []string
+	var err error
+	if s3sess, ok := sess.(*s3Session); ok {
+		names := make([]string, len(files))
+		for i, f := range files {
+			names[i] = f.Name
+		}
+		deleted, err = s3sess.deleteDataBatch(names)
+	} else {
+		for _, f := range files {
+			if derr := sess.DeleteData(f.Name); derr != nil {
+				err = derr
+				continue
+			}
+			deleted = append(deleted, f.Name)
+		}
+	}
+	sizes := make(map[string]int64, len(files))
+	for _, f := range files {
+		sizes[f.Name] = f.Size
+	}
+	var freed int64
+	for _, name := range deleted {
+		freed += sizes[name]
+	}
+	return len(deleted), freed, err
+}
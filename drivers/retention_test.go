@@ -0,0 +1,207 @@
+package drivers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/livepeer/go-livepeer/net"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ages the object saved under the name in the memory session
+func ageData(sess *MemorySession, name string, age time.Duration) {
+	sess.dLock.Lock()
+	defer sess.dLock.Unlock()
+	for _, dc := range sess.dCache {
+		for i, item := range dc.cache {
+			if item.name == name {
+				dc.cache[i].saved = time.Now().Add(-age)
+			}
+		}
+	}
+}
+
+func TestMemorySession_ListDeleteData(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	sess := NewMemoryDriver(nil).NewSession("sesspath").(*MemorySession)
+	_, err := sess.SaveData("name1/1.ts", []byte("abc"), nil)
+	require.Nil(err)
+	_, err = sess.SaveData("name2/1.ts", []byte("defg"), nil)
+	require.Nil(err)
+
+	files, err := sess.ListData()
+	require.Nil(err)
+	require.Len(files, 2)
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	assert.Equal("name1/1.ts", files[0].Name)
+	assert.Equal(int64(3), files[0].Size)
+	assert.WithinDuration(time.Now(), files[0].LastModified, time.Second)
+	assert.Equal("name2/1.ts", files[1].Name)
+	assert.Equal(int64(4), files[1].Size)
+
+	assert.Nil(sess.DeleteData("name1/1.ts"))
+	assert.Nil(sess.GetData("sesspath/name1/1.ts"))
+	files, err = sess.ListData()
+	require.Nil(err)
+	require.Len(files, 1)
+	assert.Equal("name2/1.ts", files[0].Name)
+
+	// deleting non-existing data isn't an error
+	assert.Nil(sess.DeleteData("name3/1.ts"))
+
+	data, err := sess.ReadData("name2/1.ts")
+	assert.Nil(err)
+	assert.Equal([]byte("defg"), data)
+	_, err = sess.ReadData("name1/1.ts")
+	assert.Equal(ErrNotFound, err)
+
+	// session with empty path
+	sess = NewMemoryDriver(nil).NewSession("").(*MemorySession)
+	_, err = sess.SaveData("name1/1.ts", []byte("abc"), nil)
+	require.Nil(err)
+	files, err = sess.ListData()
+	require.Nil(err)
+	require.Len(files, 1)
+	assert.Equal("name1/1.ts", files[0].Name)
+}
+
+func TestDeleteAllData(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	sess := NewMemoryDriver(nil).NewSession("sesspath").(*MemorySession)
+	_, err := sess.SaveData("name1/1.ts", []byte("abc"), nil)
+	require.Nil(err)
+	_, err = sess.SaveData("name2/1.ts", []byte("defg"), nil)
+	require.Nil(err)
+
+	count, err := DeleteAllData(sess)
+	assert.Nil(err)
+	assert.Equal(2, count)
+	files, err := sess.ListData()
+	require.Nil(err)
+	assert.Len(files, 0)
+
+	// list error
+	_, err = DeleteAllData(&stubReapSession{listErr: ErrNotSupported})
+	assert.Equal(ErrNotSupported, err)
+
+	// delete error doesn't stop deleting other objects
+	stub := &stubReapSession{
+		files:     []*FileInfo{{Name: "a.ts"}, {Name: "b.ts"}},
+		deleteErr: map[string]error{"a.ts": errors.New("some error")},
+	}
+	count, err = DeleteAllData(stub)
+	assert.EqualError(err, "some error")
+	assert.Equal(1, count)
+	assert.Equal([]string{"a.ts", "b.ts"}, stub.deleted)
+}
+
+func TestReap(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	sess := NewMemoryDriver(nil).NewSession("sesspath").(*MemorySession)
+	_, err := sess.SaveData("old/1.ts", []byte("aaaa"), nil)
+	require.Nil(err)
+	_, err = sess.SaveData("old/2.ts", []byte("bb"), nil)
+	require.Nil(err)
+	_, err = sess.SaveData("new/1.ts", []byte("c"), nil)
+	require.Nil(err)
+	_, err = sess.SaveData("old/config.json", []byte("ddd"), nil)
+	require.Nil(err)
+	ageData(sess, "1.ts", 2*time.Hour)
+	ageData(sess, "2.ts", 2*time.Hour)
+	ageData(sess, "config.json", 2*time.Hour)
+	// keep new/1.ts fresh
+	_, err = sess.SaveData("new/1.ts", []byte("c"), nil)
+	require.Nil(err)
+
+	count, freed, err := reap(sess, time.Hour)
+	assert.Nil(err)
+	assert.Equal(2, count)
+	assert.Equal(int64(6), freed)
+	assert.Nil(sess.GetData("sesspath/old/1.ts"))
+	assert.Nil(sess.GetData("sesspath/old/2.ts"))
+	assert.Equal([]byte("c"), sess.GetData("sesspath/new/1.ts"))
+	// objects other than segments are kept
+	assert.Equal([]byte("ddd"), sess.GetData("sesspath/old/config.json"))
+
+	// nothing left to reap
+	count, freed, err = reap(sess, time.Hour)
+	assert.Nil(err)
+	assert.Equal(0, count)
+	assert.Equal(int64(0), freed)
+
+	// list error
+	count, _, err = reap(&stubReapSession{listErr: ErrNotSupported}, time.Hour)
+	assert.Equal(ErrNotSupported, err)
+	assert.Equal(0, count)
+
+	// delete error doesn't stop reaping of other objects
+	stub := &stubReapSession{
+		files: []*FileInfo{
+			{Name: "mid/1.ts", Size: 1, LastModified: time.Now().Add(-2 * time.Hour)},
+			{Name: "mid/2.ts", Size: 2, LastModified: time.Now().Add(-2 * time.Hour)},
+		},
+		deleteErr: map[string]error{"mid/1.ts": errors.New("some error")},
+	}
+	count, freed, err = reap(stub, time.Hour)
+	assert.EqualError(err, "some error")
+	assert.Equal(1, count)
+	assert.Equal(int64(2), freed)
+	assert.Equal([]string{"mid/1.ts", "mid/2.ts"}, stub.deleted)
+}
+
+func TestStartReaper(t *testing.T) {
+	sess := NewMemoryDriver(nil).NewSession("sesspath").(*MemorySession)
+	_, err := sess.SaveData("old/1.ts", []byte("aaaa"), nil)
+	require.Nil(t, err)
+	ageData(sess, "1.ts", time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		StartReaper(ctx, sess, RetentionPolicy{MaxAge: time.Second, Interval: time.Millisecond})
+		close(done)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	assert.Nil(t, sess.GetData("sesspath/old/1.ts"))
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("reaper did not stop")
+	}
+}
+
+type stubReapSession struct {
+	files     []*FileInfo
+	listErr   error
+	deleteErr map[string]error
+	deleted   []string
+}
+
+func (s *stubReapSession) SaveData(name string, data []byte, props *FileProperties) (string, error) {
+	return "", nil
+}
+func (s *stubReapSession) SaveDataReader(name string, r io.Reader, size int64) (string, error) {
+	return "", nil
+}
+func (s *stubReapSession) EndSession()                          {}
+func (s *stubReapSession) GetInfo() *net.OSInfo                 { return nil }
+func (s *stubReapSession) IsExternal() bool                     { return false }
+func (s *stubReapSession) ListData() ([]*FileInfo, error)       { return s.files, s.listErr }
+func (s *stubReapSession) ReadData(name string) ([]byte, error) { return nil, ErrNotFound }
+func (s *stubReapSession) DeleteData(name string) error {
+	s.deleted = append(s.deleted, name)
+	return s.deleteErr[name]
+}
@@ -3,30 +3,103 @@ package drivers
 import (
 	"bytes"
 	"crypto/hmac"
+	"crypto/md5"
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
+	"io/ioutil"
 	"mime/multipart"
 	"net/http"
+	"net/url"
 	"path"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/cenkalti/backoff"
 	"github.com/golang/glog"
 	"github.com/livepeer/go-livepeer/common"
+	"github.com/livepeer/go-livepeer/monitor"
 	"github.com/livepeer/go-livepeer/net"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 )
 
 // S3_POLICY_EXPIRE_IN_HOURS how long access rights given to other node will be valid
 const S3_POLICY_EXPIRE_IN_HOURS = 24
 
+// s3MaxDeleteObjects is the max number of objects deleted with single request
+var s3MaxDeleteObjects = 1000
+
+// s3MaxPostSize is the max size of object uploaded with single request
+var s3MaxPostSize int64 = 5 * 1024 * 1024 * 1024
+
+// S3MultipartThreshold is the data size above which data is saved to our own
+// bucket using multipart upload
+var S3MultipartThreshold int64 = 100 * 1024 * 1024
+
+// S3MultipartPartSize is the size of the parts of multipart upload, at least 5MB
+var S3MultipartPartSize int64 = 16 * 1024 * 1024
+
+// S3MultipartConcurrency is the number of parts of multipart upload uploaded in parallel
+var S3MultipartConcurrency = s3manager.DefaultUploadConcurrency
+
+// S3MaxConcurrentUploads is the max number of SaveData calls uploading to the
+// bucket owned by this node at once, across all its sessions. Calls above the
+// limit wait for one of the uploads to finish. No limit if 0. Read when the
+// driver is created.
+var S3MaxConcurrentUploads = 0
+
+// S3PostMaxAttempts is the max number of attempts to save data with POST policy.
+// Network errors and 5xx responses are retried, other errors aren't.
+var S3PostMaxAttempts = 3
+
+// S3PostRetryDelay is the delay before the first retry of failed POST, doubled
+// for every next retry
+var S3PostRetryDelay = 500 * time.Millisecond
+
+// S3PostRetryJitter is the fraction by which retry delays are randomized
+var S3PostRetryJitter = 0.5
+
+// S3DefaultACL is the canned ACL of the objects saved to S3 if none is given
+const S3DefaultACL = s3.ObjectCannedACLPublicRead
+
+// S3PrivateURLExpiry is how long URLs returned for the objects saved to own
+// bucket with non-public ACL are valid
+var S3PrivateURLExpiry = S3_POLICY_EXPIRE_IN_HOURS * time.Hour
+
+// S3PresignMaxExpiry is the longest expiry of presigned URL allowed by SigV4
+const S3PresignMaxExpiry = 7 * 24 * time.Hour
+
+// ErrPresignExpiry returned for presigned URL expiry that isn't positive or
+// exceeds S3PresignMaxExpiry
+var ErrPresignExpiry = fmt.Errorf("presigned URL expiry should be positive and at most %v", S3PresignMaxExpiry)
+
+// S3StrictChecksum fails saving to own bucket if ETag of the saved object
+// doesn't match the checksum of the data, otherwise mismatch is only logged.
+// Read when the driver is created.
+var S3StrictChecksum = false
+
+// ErrS3ChecksumMismatch is returned in strict checksum mode if the saved object
+// is corrupted
+var ErrS3ChecksumMismatch = errors.New("checksum of the saved S3 object doesn't match the data")
+
+// ErrS3MultipartNotSupported returned for data too big to be saved with single
+// request to the bucket accessed with POST policy, as multipart upload needs
+// the bucket credentials
+var ErrS3MultipartNotSupported = errors.New("data is too big to be saved to S3 with POST policy and multipart upload requires bucket credentials")
+
 /* S3OS S# backed object storage driver. For own storage access key and access key secret
    should be specified. To give to other nodes access to own S3 storage so called 'POST' policy
    is created. This policy is valid for S3_POLICY_EXPIRE_IN_HOURS hours.
@@ -37,11 +110,17 @@ type s3OS struct {
 	bucket             string
 	awsAccessKeyID     string
 	awsSecretAccessKey string
+	sse                string
+	kmsKeyID           string
+	acl                string
+	strictChecksum     bool
 	s3svc              *s3.S3
+	uploads            *s3UploadPool
 }
 
 type s3Session struct {
 	host        string
+	bucket      string
 	key         string
 	policy      string
 	signature   string
@@ -49,18 +128,123 @@ type s3Session struct {
 	xAmzDate    string
 	storageType net.OSInfo_StorageType
 	fields      map[string]string
+	// server-side encryption required by the policy
+	sse      string
+	kmsKeyID string
+	// canned ACL of the saved objects required by the policy
+	acl string
+	// only set for the sessions of our own storage
+	s3svc          *s3.S3
+	gsSigner       *gsSigner
+	uploads        *s3UploadPool
+	strictChecksum bool
+}
+
+// s3UploadPool bounds the number of uploads in progress at once and reports
+// the uploads in flight and waiting for a slot
+type s3UploadPool struct {
+	slots chan struct{} // nil if unbounded
+
+	mu       sync.Mutex
+	inFlight int
+	waiting  int
+}
+
+func newS3UploadPool(size int) *s3UploadPool {
+	p := &s3UploadPool{}
+	if size > 0 {
+		p.slots = make(chan struct{}, size)
+	}
+	return p
+}
+
+// do runs the upload once there is a free slot
+func (p *s3UploadPool) do(upload func() (string, error)) (string, error) {
+	if p == nil {
+		return upload()
+	}
+	p.update(0, 1)
+	if p.slots != nil {
+		p.slots <- struct{}{}
+	}
+	p.update(1, -1)
+	defer func() {
+		if p.slots != nil {
+			<-p.slots
+		}
+		p.update(-1, 0)
+	}()
+	return upload()
+}
+
+func (p *s3UploadPool) update(inFlight, waiting int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.inFlight += inFlight
+	p.waiting += waiting
+	if monitor.Enabled {
+		monitor.S3Uploads(p.inFlight, p.waiting)
+	}
 }
 
-// S3BUCKET s3 bucket owned by this node
-var S3BUCKET string
+// s3OwnBuckets are the base URLs of the buckets owned by this node, in both
+// addressing styles, registered by the drivers
+var s3OwnBuckets = struct {
+	sync.RWMutex
+	urls map[string]bool
+}{urls: make(map[string]bool)}
+
+// registerOwnS3Bucket records the bucket at the endpoint as owned by this node,
+// at both AWS S3 global and regional hosts if the endpoint is empty
+func registerOwnS3Bucket(endpoint, region, bucket string) {
+	endpoints := []string{endpoint}
+	if endpoint == "" && region != "" {
+		endpoints = append(endpoints, "https://s3."+region+".amazonaws.com")
+	}
+	s3OwnBuckets.Lock()
+	defer s3OwnBuckets.Unlock()
+	for _, e := range endpoints {
+		s3OwnBuckets.urls[s3Host(e, bucket, false)] = true
+		// bucket is followed by the path, so it isn't taken for a longer one
+		s3OwnBuckets.urls[s3Host(e, bucket, true)+"/"] = true
+	}
+}
+
+// ResetOwnS3Buckets forgets the buckets registered by the drivers created so
+// far, for tests creating the drivers of other buckets than the node's
+func ResetOwnS3Buckets() {
+	s3OwnBuckets.Lock()
+	defer s3OwnBuckets.Unlock()
+	s3OwnBuckets.urls = make(map[string]bool)
+}
 
-func s3Host(bucket string) string {
-	return fmt.Sprintf("https://%s.s3.amazonaws.com", bucket)
+// s3Host returns base URL of the bucket at the endpoint, AWS S3 if the endpoint
+// is empty. Bucket is in the host name of virtual-hosted-style URL and in the
+// path of path-style one.
+func s3Host(endpoint, bucket string, pathStyle bool) string {
+	if endpoint == "" {
+		endpoint = "https://s3.amazonaws.com"
+	}
+	endpoint = strings.TrimSuffix(endpoint, "/")
+	u, err := url.Parse(endpoint)
+	if pathStyle || err != nil || u.Host == "" {
+		return endpoint + "/" + bucket
+	}
+	u.Host = bucket + "." + u.Host
+	return u.String()
 }
 
-// IsOwnStorageS3 returns true if uri points to S3 bucket owned by this node
+// IsOwnStorageS3 returns true if uri points to S3 bucket owned by this node,
+// addressed either virtual-hosted-style or path-style
 func IsOwnStorageS3(uri string) bool {
-	return strings.HasPrefix(uri, s3Host(S3BUCKET))
+	s3OwnBuckets.RLock()
+	defer s3OwnBuckets.RUnlock()
+	for base := range s3OwnBuckets.urls {
+		if strings.HasPrefix(uri, base) {
+			return true
+		}
+	}
+	return false
 }
 
 func newS3Session(info *net.S3OSInfo) OSSession {
@@ -73,49 +257,204 @@ func newS3Session(info *net.S3OSInfo) OSSession {
 		credential:  info.Credential,
 		storageType: net.OSInfo_S3,
 	}
+	// OSInfo doesn't convey encryption settings and ACL, so take them from the policy
+	conditions := s3PolicyConditions(info.Policy)
+	sess.sse, sess.kmsKeyID, sess.acl = conditions[s3SSEField], conditions[s3KMSKeyIDField], conditions["acl"]
+	if sess.acl == "" {
+		sess.acl = S3DefaultACL
+	}
 	sess.fields = s3GetFields(sess)
 	return sess
 }
 
-func NewS3Driver(region, bucket, accessKey, accessKeySecret string) OSDriver {
+// S3Options are the options of the S3 driver
+type S3Options struct {
+	Region          string
+	Bucket          string
+	AccessKey       string
+	AccessKeySecret string
+	// Endpoint of S3 compatible storage such as MinIO, AWS S3 if empty
+	Endpoint string
+	// PathStyle selects path-style addressing of the bucket instead of
+	// virtual-hosted-style one
+	PathStyle bool
+	// SSE sets server-side encryption of the saved objects, either
+	// s3.ServerSideEncryptionAes256 (SSE-S3) or s3.ServerSideEncryptionAwsKms
+	// (SSE-KMS) with optional KMSKeyID; no encryption if empty
+	SSE      string
+	KMSKeyID string
+	// ACL is canned ACL of the saved objects, such as s3.ObjectCannedACLPrivate,
+	// S3DefaultACL if empty
+	ACL string
+}
+
+// NewS3Driver returns driver for the bucket in AWS S3 or in S3 compatible
+// storage. The bucket is registered as owned by this node.
+func NewS3Driver(opts S3Options) OSDriver {
+	acl := opts.ACL
+	if acl == "" {
+		acl = S3DefaultACL
+	}
 	os := &s3OS{
-		host:               s3Host(bucket),
-		region:             region,
-		bucket:             bucket,
-		awsAccessKeyID:     accessKey,
-		awsSecretAccessKey: accessKeySecret,
+		host:               s3Host(opts.Endpoint, opts.Bucket, opts.PathStyle),
+		region:             opts.Region,
+		bucket:             opts.Bucket,
+		awsAccessKeyID:     opts.AccessKey,
+		awsSecretAccessKey: opts.AccessKeySecret,
+		sse:                opts.SSE,
+		kmsKeyID:           opts.KMSKeyID,
+		acl:                acl,
+		strictChecksum:     S3StrictChecksum,
+		uploads:            newS3UploadPool(S3MaxConcurrentUploads),
 	}
 	if os.awsAccessKeyID != "" {
 		creds := credentials.NewStaticCredentials(os.awsAccessKeyID, os.awsSecretAccessKey, "")
-		cfg := aws.NewConfig().WithRegion(os.region).WithCredentials(creds)
+		cfg := aws.NewConfig().WithRegion(os.region).WithCredentials(creds).WithS3ForcePathStyle(opts.PathStyle)
+		if opts.Endpoint != "" {
+			cfg = cfg.WithEndpoint(opts.Endpoint)
+		}
 		os.s3svc = s3.New(session.New(), cfg)
 	}
+	registerOwnS3Bucket(opts.Endpoint, opts.Region, opts.Bucket)
 	return os
 }
 
 func (os *s3OS) NewSession(path string) OSSession {
+	path = renderPath(StoragePathTemplate, path, time.Now())
 	policy, signature, credential, xAmzDate := createPolicy(os.awsAccessKeyID,
-		os.bucket, os.region, os.awsSecretAccessKey, path)
+		os.bucket, os.region, os.awsSecretAccessKey, path, os.sse, os.kmsKeyID, os.acl)
 	sess := &s3Session{
-		host:        s3Host(os.bucket),
+		host:        os.host,
+		bucket:      os.bucket,
 		key:         path,
 		policy:      policy,
 		signature:   signature,
 		credential:  credential,
 		xAmzDate:    xAmzDate,
 		storageType: net.OSInfo_S3,
+		sse:         os.sse,
+		kmsKeyID:    os.kmsKeyID,
+		acl:         os.acl,
+		s3svc:       os.s3svc,
+		uploads:     os.uploads,
+
+		strictChecksum: os.strictChecksum,
 	}
 	sess.fields = s3GetFields(sess)
 	return sess
 }
 
 func s3GetFields(sess *s3Session) map[string]string {
-	return map[string]string{
+	fields := map[string]string{
 		"x-amz-algorithm":  "AWS4-HMAC-SHA256",
 		"x-amz-credential": sess.credential,
 		"x-amz-date":       sess.xAmzDate,
 		"x-amz-signature":  sess.signature,
 	}
+	if sess.sse != "" {
+		fields[s3SSEField] = sess.sse
+	}
+	if sess.kmsKeyID != "" {
+		fields[s3KMSKeyIDField] = sess.kmsKeyID
+	}
+	return fields
+}
+
+const (
+	s3SSEField      = "x-amz-server-side-encryption"
+	s3KMSKeyIDField = "x-amz-server-side-encryption-aws-kms-key-id"
+)
+
+// s3PolicyConditions returns the exact match conditions of the base64 encoded
+// POST policy, such as server-side encryption settings and ACL it requires
+func s3PolicyConditions(policy string) map[string]string {
+	conditions := make(map[string]string)
+	src, err := base64.StdEncoding.DecodeString(policy)
+	if err != nil {
+		return conditions
+	}
+	var p struct {
+		Conditions []interface{} `json:"conditions"`
+	}
+	if err := json.Unmarshal(src, &p); err != nil {
+		return conditions
+	}
+	for _, c := range p.Conditions {
+		if m, ok := c.(map[string]interface{}); ok {
+			for k, v := range m {
+				if s, ok := v.(string); ok {
+					conditions[k] = s
+				}
+			}
+		}
+	}
+	return conditions
+}
+
+// s3IsPublicACL returns whether canned ACL allows anyone to read the objects
+func s3IsPublicACL(acl string) bool {
+	return acl == s3.ObjectCannedACLPublicRead || acl == s3.ObjectCannedACLPublicReadWrite
+}
+
+// ReadData returns the data saved under the name. Own bucket is read with the
+// bucket credentials; data in the bucket of other node is expected to be saved
+// public-read, so it is fetched with plain GET.
+func (os *s3Session) ReadData(name string) ([]byte, error) {
+	key := path.Join(os.key, name)
+	if os.s3svc == nil {
+		return getPublicData(os.getAbsURL(key))
+	}
+	out, err := os.s3svc.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(os.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == s3.ErrCodeNoSuchKey {
+			return nil, ErrNotFound
+		}
+		glog.Errorf("Error reading S3 bucket=%s key=%s err=%v", os.bucket, key, err)
+		return nil, err
+	}
+	defer out.Body.Close()
+	return ioutil.ReadAll(out.Body)
+}
+
+func getPublicData(uri string) ([]byte, error) {
+	resp, err := httpc.Get(uri)
+	if err != nil {
+		glog.Errorf("Error getting HTTP uri=%s err=%v", uri, err)
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		glog.Errorf("Non-200 response for status=%v uri=%s", resp.Status, uri)
+		return nil, fmt.Errorf(resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// PresignedGetURL returns URL the data saved under the name can be read from
+// until the expiry passes, signed with SigV4 for S3 and with the service
+// account key for Google Cloud Storage. Only supported for own storage.
+func (os *s3Session) PresignedGetURL(name string, expiry time.Duration) (string, error) {
+	if expiry <= 0 || expiry > S3PresignMaxExpiry {
+		return "", ErrPresignExpiry
+	}
+	key := path.Join(os.key, name)
+	if os.gsSigner != nil {
+		return gsPresignedGetURL(os.gsSigner, os.bucket, key, time.Now().Add(expiry)), nil
+	}
+	if os.s3svc == nil {
+		return "", ErrNotSupported
+	}
+	req, _ := os.s3svc.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(os.bucket),
+		Key:    aws.String(key),
+	})
+	return req.Presign(expiry)
 }
 
 func (os *s3Session) IsExternal() bool {
@@ -125,23 +464,137 @@ func (os *s3Session) IsExternal() bool {
 func (os *s3Session) EndSession() {
 }
 
-func (os *s3Session) SaveData(name string, data []byte) (string, error) {
+// SaveData returns URL of the saved data. For own bucket with non-public ACL
+// it is presigned GET URL valid for S3PrivateURLExpiry; other nodes can't sign
+// URLs, so bare URL is returned and the bucket owner reads it with credentials.
+func (os *s3Session) SaveData(name string, data []byte, props *FileProperties) (string, error) {
+	return os.saveReader(name, bytes.NewReader(data), int64(len(data)), props)
+}
+
+// SaveDataReader streams the data to S3 like SaveData. Own bucket is uploaded
+// to in parts if the size is unknown, other buckets need the length of the
+// POST body, so the data of unknown size is read to memory first. Failed POST
+// is only retried if r is io.ReadSeeker.
+func (os *s3Session) SaveDataReader(name string, r io.Reader, size int64) (string, error) {
+	return os.saveReader(name, r, size, nil)
+}
+
+// saveReader saves the data read from r, size is -1 if unknown
+func (os *s3Session) saveReader(name string, r io.Reader, size int64, props *FileProperties) (string, error) {
 	// tentativeUrl just used for logging
 	tentativeURL := path.Join(os.host, os.key, name)
 	glog.V(common.VERBOSE).Infof("Saving to S3 %s", tentativeURL)
-	path, err := os.postData(name, data)
+	path, err := os.uploads.do(func() (string, error) {
+		if (size < 0 || size > S3MultipartThreshold) && os.s3svc != nil {
+			return os.multipartUpload(name, r, size, props)
+		} else if size > s3MaxPostSize {
+			return "", ErrS3MultipartNotSupported
+		}
+		return os.postData(name, r, size, props)
+	})
 	if err != nil {
 		// handle error
 		glog.Errorf("Save S3 error: %v", err)
 		return "", err
 	}
 	url := os.getAbsURL(path)
+	if !s3IsPublicACL(os.acl) && os.s3svc != nil {
+		url, err = os.PresignedGetURL(name, S3PrivateURLExpiry)
+		if err != nil {
+			glog.Errorf("Error presigning S3 url=%s err=%v", tentativeURL, err)
+			return "", err
+		}
+	}
 
 	glog.V(common.VERBOSE).Infof("Saved to S3 %s", tentativeURL)
 
 	return url, err
 }
 
+func (os *s3Session) ListData() ([]*FileInfo, error) {
+	if os.s3svc == nil {
+		return nil, ErrNotSupported
+	}
+	var files []*FileInfo
+	params := &s3.ListObjectsV2Input{
+		Bucket: aws.String(os.bucket),
+		Prefix: aws.String(os.key),
+	}
+	err := os.s3svc.ListObjectsV2Pages(params, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, obj := range page.Contents {
+			files = append(files, &FileInfo{
+				Name:         strings.TrimPrefix(strings.TrimPrefix(aws.StringValue(obj.Key), os.key), "/"),
+				LastModified: aws.TimeValue(obj.LastModified),
+				Size:         aws.Int64Value(obj.Size),
+			})
+		}
+		return true
+	})
+	if err != nil {
+		glog.Errorf("Error listing S3 bucket=%s prefix=%s err=%v", os.bucket, os.key, err)
+		return nil, err
+	}
+	return files, nil
+}
+
+func (os *s3Session) DeleteData(name string) error {
+	if os.s3svc == nil {
+		return ErrNotSupported
+	}
+	key := path.Join(os.key, name)
+	_, err := os.s3svc.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(os.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		glog.Errorf("Error deleting from S3 bucket=%s key=%s err=%v", os.bucket, key, err)
+	}
+	return err
+}
+
+// deleteDataBatch removes objects saved under the names from our own bucket
+// with as few requests as possible, returns names of deleted objects
+func (os *s3Session) deleteDataBatch(names []string) ([]string, error) {
+	if os.s3svc == nil {
+		return nil, ErrNotSupported
+	}
+	var deleted []string
+	for start := 0; start < len(names); start += s3MaxDeleteObjects {
+		end := start + s3MaxDeleteObjects
+		if end > len(names) {
+			end = len(names)
+		}
+		objects := make([]*s3.ObjectIdentifier, 0, end-start)
+		for _, name := range names[start:end] {
+			objects = append(objects, &s3.ObjectIdentifier{Key: aws.String(path.Join(os.key, name))})
+		}
+		out, err := os.s3svc.DeleteObjects(&s3.DeleteObjectsInput{
+			Bucket: aws.String(os.bucket),
+			Delete: &s3.Delete{Objects: objects, Quiet: aws.Bool(true)},
+		})
+		if err == nil {
+			failed := make(map[string]bool, len(out.Errors))
+			for _, e := range out.Errors {
+				failed[aws.StringValue(e.Key)] = true
+			}
+			for i, name := range names[start:end] {
+				if !failed[aws.StringValue(objects[i].Key)] {
+					deleted = append(deleted, name)
+				}
+			}
+			if len(out.Errors) > 0 {
+				err = fmt.Errorf("key=%s code=%s message=%s", aws.StringValue(out.Errors[0].Key),
+					aws.StringValue(out.Errors[0].Code), aws.StringValue(out.Errors[0].Message))
+			}
+		}
+		if err != nil {
+			glog.Errorf("Error deleting from S3 bucket=%s prefix=%s err=%v", os.bucket, os.key, err)
+			return deleted, err
+		}
+	}
+	return deleted, nil
+}
+
 func (os *s3Session) getAbsURL(path string) string {
 	return os.host + "/" + path
 }
@@ -162,23 +615,79 @@ func (os *s3Session) GetInfo() *net.OSInfo {
 }
 
 // if s3 storage is not our own, we are saving data into it using POST request
-func (os *s3Session) postData(fileName string, buffer []byte) (string, error) {
-	fileBytes := bytes.NewReader(buffer)
-	fileType := http.DetectContentType(buffer)
+// postData saves the data with POST policy, retrying transient failures with
+// exponential backoff. Error of the last attempt is returned as is.
+func (os *s3Session) postData(fileName string, r io.Reader, size int64, props *FileProperties) (string, error) {
+	if size < 0 {
+		data, err := ioutil.ReadAll(io.LimitReader(r, s3MaxPostSize+1))
+		if err != nil {
+			return "", err
+		}
+		if int64(len(data)) > s3MaxPostSize {
+			return "", ErrS3MultipartNotSupported
+		}
+		r, size = bytes.NewReader(data), int64(len(data))
+	}
+	fileType, r, err := props.contentTypeReader(fileName, r)
+	if err != nil {
+		return "", err
+	}
+	// the body can only be sent again if it can be rewound
+	rs, seekable := r.(io.ReadSeeker)
+	var start int64
+	if seekable {
+		if start, err = rs.Seek(0, io.SeekCurrent); err != nil {
+			return "", err
+		}
+	}
+
+	b := backoff.NewExponentialBackOff()
+	b.InitialInterval = S3PostRetryDelay
+	b.RandomizationFactor = S3PostRetryJitter
+	b.MaxElapsedTime = 0
+	var uri string
+	attempt := 0
+	err = backoff.RetryNotify(func() error {
+		if attempt > 0 {
+			if _, err := rs.Seek(start, io.SeekStart); err != nil {
+				return backoff.Permanent(err)
+			}
+		}
+		attempt++
+		var err error
+		uri, err = os.postDataOnce(fileName, r, size, fileType, props)
+		if _, ok := err.(*backoff.PermanentError); err != nil && !ok && !seekable {
+			return backoff.Permanent(err)
+		}
+		return err
+	}, backoff.WithMaxRetries(b, uint64(S3PostMaxAttempts-1)), func(err error, next time.Duration) {
+		glog.Warningf("Retrying S3 upload name=%s in %v err=%v", fileName, next, err)
+	})
+	return uri, err
+}
+
+// postDataOnce makes single POST request, errors that shouldn't be retried are
+// wrapped with backoff.Permanent
+func (os *s3Session) postDataOnce(fileName string, r io.Reader, size int64, fileType string, props *FileProperties) (string, error) {
+	h := md5.New()
 	path, fileName := path.Split(path.Join(os.key, fileName))
 	fields := map[string]string{
-		"acl":          "public-read",
+		"acl":          os.acl,
 		"Content-Type": fileType,
 		"key":          path + "${filename}",
 		"policy":       os.policy,
 	}
+	if cacheControl := props.cacheControl(); cacheControl != "" {
+		// policies created before Cache-Control was allowed reject the field
+		fields["Cache-Control"] = cacheControl
+	}
 	for k, v := range os.fields {
 		fields[k] = v
 	}
-	req, err := newfileUploadRequest(os.host, fields, fileBytes, fileName)
+	req, err := newfileUploadRequest(os.host, fields, io.TeeReader(r, h), size, fileName)
 	if err != nil {
 		glog.Error(err)
-		return "", err
+		return "", backoff.Permanent(err)
 	}
 	client := &http.Client{}
 	resp, err := client.Do(req)
@@ -195,12 +704,229 @@ func (os *s3Session) postData(fileName string, buffer []byte) (string, error) {
 	resp.Body.Close()
 	if sz > 0 {
 		// usually there's an error at this point, so log
-		glog.Error("Got response from from S3: ", body)
-		return "", fmt.Errorf(body.String()) // sorta bad
+		err = newS3Error(resp.StatusCode, body.Bytes())
+		glog.Error("Got error response from S3: ", err)
+		glog.V(common.DEBUG).Info("S3 error response body: ", body)
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return "", err
+		}
+		// 4xx policy errors won't go away on retry
+		return "", backoff.Permanent(err)
+	}
+	// corrupted in transit, so retried
+	if err := os.verifyChecksum(path+fileName, resp.Header.Get("ETag"), hex.EncodeToString(h.Sum(nil))); err != nil {
+		return "", err
 	}
 	return path + fileName, err
 }
 
+// S3Error is the error response of S3 to the POST upload
+type S3Error struct {
+	StatusCode int
+	Code       string `xml:"Code"`
+	Message    string `xml:"Message"`
+	RequestID  string `xml:"RequestId"`
+
+	body string
+}
+
+// newS3Error parses the error response body, Code is left empty if it isn't
+// S3 error XML
+func newS3Error(status int, body []byte) *S3Error {
+	e := &S3Error{}
+	if err := xml.Unmarshal(body, e); err != nil {
+		e = &S3Error{}
+	}
+	e.StatusCode = status
+	e.body = string(body)
+	return e
+}
+
+func (e *S3Error) Error() string {
+	if e.Code == "" {
+		return e.body
+	}
+	msg := e.Code
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	if e.RequestID != "" {
+		msg += " requestId=" + e.RequestID
+	}
+	return msg
+}
+
+// String returns the raw response body
+func (e *S3Error) String() string {
+	return e.body
+}
+
+// multipartUpload saves the data to our own bucket in parts uploaded
+// concurrently. Parts of the data that isn't io.ReadSeeker are buffered by the
+// uploader, the data of unknown size that fits into one part is uploaded with
+// single request.
+func (os *s3Session) multipartUpload(fileName string, r io.Reader, size int64, props *FileProperties) (string, error) {
+	key := path.Join(os.key, fileName)
+	fileType, r, err := props.contentTypeReader(fileName, r)
+	if err != nil {
+		return "", err
+	}
+	uploader := s3manager.NewUploaderWithClient(os.s3svc, func(u *s3manager.Uploader) {
+		u.PartSize = S3MultipartPartSize
+		u.Concurrency = S3MultipartConcurrency
+	})
+	input := &s3manager.UploadInput{
+		Bucket:      aws.String(os.bucket),
+		Key:         aws.String(key),
+		ACL:         aws.String(os.acl),
+		ContentType: aws.String(fileType),
+		Body:        r,
+	}
+	if cacheControl := props.cacheControl(); cacheControl != "" {
+		input.CacheControl = aws.String(cacheControl)
+	}
+	if os.sse != "" {
+		input.ServerSideEncryption = aws.String(os.sse)
+	}
+	if os.kmsKeyID != "" {
+		input.SSEKMSKeyId = aws.String(os.kmsKeyID)
+	}
+	var etag *s3ETagHash
+	if os.strictChecksum {
+		etag = newS3ETagHash(size, S3MultipartPartSize)
+		if rs, ok := r.(io.ReadSeeker); ok {
+			// hashed in advance, so the uploader reads the parts concurrently
+			// instead of buffering them
+			start, err := rs.Seek(0, io.SeekCurrent)
+			if err == nil {
+				_, err = io.Copy(etag, rs)
+			}
+			if err == nil {
+				_, err = rs.Seek(start, io.SeekStart)
+			}
+			if err != nil {
+				return "", err
+			}
+		} else {
+			input.Body = io.TeeReader(r, etag)
+		}
+	}
+	if _, err := uploader.Upload(input); err != nil {
+		return "", err
+	}
+	if os.strictChecksum {
+		// upload result doesn't carry ETag of the completed object, so read it
+		if err := os.verifyChecksum(key, "", etag.ETag()); err != nil {
+			return "", err
+		}
+	}
+	return key, nil
+}
+
+// s3ETag returns ETag S3 gives to the object with the data uploaded by
+// s3manager in parts of partSize, or in single request if partSize is 0. It is
+// MD5 of the data for single request and MD5 of the MD5s of the parts followed
+// by the number of parts otherwise.
+func s3ETag(data []byte, partSize int64) string {
+	h := newS3ETagHash(int64(len(data)), partSize)
+	h.Write(data)
+	return h.ETag()
+}
+
+// s3ETagHash computes the ETag like s3ETag from the data written to it
+type s3ETagHash struct {
+	partSize int64
+	part     hash.Hash
+	written  int64 // to the current part
+	sums     hash.Hash
+	first    []byte
+	parts    int
+}
+
+// newS3ETagHash returns hash of the data of the size, -1 if unknown, uploaded
+// in parts of partSize
+func newS3ETagHash(size, partSize int64) *s3ETagHash {
+	if partSize > 0 && size/partSize >= s3manager.MaxUploadParts {
+		// s3manager grows the parts in the same way
+		partSize = size/s3manager.MaxUploadParts + 1
+	}
+	return &s3ETagHash{partSize: partSize, part: md5.New(), sums: md5.New()}
+}
+
+func (h *s3ETagHash) Write(p []byte) (int, error) {
+	written := len(p)
+	for len(p) > 0 {
+		n := int64(len(p))
+		if h.partSize > 0 && h.written+n > h.partSize {
+			n = h.partSize - h.written
+		}
+		h.part.Write(p[:n])
+		h.written += n
+		p = p[n:]
+		if h.written == h.partSize {
+			h.endPart()
+		}
+	}
+	return written, nil
+}
+
+func (h *s3ETagHash) endPart() {
+	sum := h.part.Sum(nil)
+	if h.parts == 0 {
+		h.first = sum
+	}
+	h.sums.Write(sum)
+	h.parts++
+	h.part.Reset()
+	h.written = 0
+}
+
+// ETag should be called once all the data is written
+func (h *s3ETagHash) ETag() string {
+	if h.written > 0 || h.parts == 0 {
+		h.endPart()
+	}
+	if h.parts == 1 {
+		return hex.EncodeToString(h.first)
+	}
+	return fmt.Sprintf("%s-%d", hex.EncodeToString(h.sums.Sum(nil)), h.parts)
+}
+
+// verifyChecksum compares ETag of the saved object with the expected one. If
+// the upload response had no ETag, it is read from own bucket in strict mode.
+// Mismatch is returned as ErrS3ChecksumMismatch in strict mode and only logged
+// otherwise.
+func (os *s3Session) verifyChecksum(key, etag, expected string) error {
+	if os.sse == s3.ServerSideEncryptionAwsKms {
+		// ETag of SSE-KMS object isn't derived from the data
+		return nil
+	}
+	if etag == "" && os.strictChecksum && os.s3svc != nil {
+		out, err := os.s3svc.HeadObject(&s3.HeadObjectInput{
+			Bucket: aws.String(os.bucket),
+			Key:    aws.String(key),
+		})
+		if err != nil {
+			glog.Errorf("Error reading ETag of S3 object key=%s err=%v", key, err)
+			return err
+		}
+		etag = aws.StringValue(out.ETag)
+	}
+	etag = strings.Trim(etag, `"`)
+	if etag == "" {
+		glog.V(common.DEBUG).Infof("No ETag to verify checksum of S3 object key=%s", key)
+		return nil
+	}
+	if etag == expected {
+		return nil
+	}
+	glog.Errorf("Checksum mismatch of S3 object key=%s etag=%s expected=%s", key, etag, expected)
+	if os.strictChecksum {
+		return ErrS3ChecksumMismatch
+	}
+	return nil
+}
+
 func makeHmac(key []byte, data []byte) []byte {
 	hash := hmac.New(sha256.New, key)
 	hash.Write(data)
@@ -218,7 +944,7 @@ func signString(stringToSign, sregion, amzDate, secret string) string {
 }
 
 // createPolicy returns policy, signature, xAmzCredentail and xAmzDate
-func createPolicy(key, bucket, region, secret, path string) (string, string, string, string) {
+func createPolicy(key, bucket, region, secret, path, sse, kmsKeyID, acl string) (string, string, string, string) {
 	const timeFormat = "2006-01-02T15:04:05.999Z"
 	const shortTimeFormat = "20060102"
 
@@ -226,42 +952,59 @@ func createPolicy(key, bucket, region, secret, path string) (string, string, str
 	expireFmt := expireAt.UTC().Format(timeFormat)
 	xAmzDate := time.Now().UTC().Format(shortTimeFormat)
 	xAmzCredential := fmt.Sprintf("%s/%s/%s/s3/aws4_request", key, xAmzDate, region)
+	var sseConditions string
+	if sse != "" {
+		sseConditions += fmt.Sprintf(`
+      {"%s": "%s"},`, s3SSEField, sse)
+	}
+	if kmsKeyID != "" {
+		sseConditions += fmt.Sprintf(`
+      {"%s": "%s"},`, s3KMSKeyIDField, kmsKeyID)
+	}
 	src := fmt.Sprintf(`{ "expiration": "%s",
     "conditions": [
       {"bucket": "%s"},
-      {"acl": "public-read"},
+      {"acl": "%s"},
       ["starts-with", "$Content-Type", ""],
-      ["starts-with", "$key", "%s"],
+      ["starts-with", "$Cache-Control", ""],
+      ["starts-with", "$key", "%s"],%s
       {"x-amz-algorithm": "AWS4-HMAC-SHA256"},
       {"x-amz-credential": "%s"},
       {"x-amz-date": "%sT000000Z" }
     ]
-  }`, expireFmt, bucket, path, xAmzCredential, xAmzDate)
+  }`, expireFmt, bucket, acl, path, sseConditions, xAmzCredential, xAmzDate)
 	policy := base64.StdEncoding.EncodeToString([]byte(src))
 	return policy, signString(policy, region, xAmzDate, secret), xAmzCredential, xAmzDate + "T000000Z"
 }
 
-func newfileUploadRequest(uri string, params map[string]string, fData io.Reader, fileName string) (*http.Request, error) {
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
+// newfileUploadRequest returns POST request of the form with the file of the
+// size streamed from fData, S3 needs the length of the whole form
+func newfileUploadRequest(uri string, params map[string]string, fData io.Reader, size int64, fileName string) (*http.Request, error) {
+	form := &bytes.Buffer{}
+	writer := multipart.NewWriter(form)
 	for key, val := range params {
 		err := writer.WriteField(key, val)
 		if err != nil {
 			glog.Error(err)
 		}
 	}
-	part, err := writer.CreateFormFile("file", fileName)
+	_, err := writer.CreateFormFile("file", fileName)
 	if err != nil {
 		return nil, err
 	}
-	_, err = io.Copy(part, fData)
-
+	head := form.Len()
 	err = writer.Close()
 	if err != nil {
 		return nil, err
 	}
 
+	// the file goes between the part header and the closing boundary
+	body := io.MultiReader(bytes.NewReader(form.Bytes()[:head]), fData, bytes.NewReader(form.Bytes()[head:]))
 	req, err := http.NewRequest("POST", uri, body)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(form.Len()) + size
 	req.Header.Set("Content-Type", writer.FormDataContentType())
-	return req, err
+	return req, nil
 }
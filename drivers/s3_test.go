@@ -0,0 +1,970 @@
+package drivers
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubS3 handles POST policy uploads and multipart uploads
+type stubS3 struct {
+	mu           sync.Mutex
+	posts        int
+	parts        map[string]int // partNumber:size
+	acl          string
+	contentType  string
+	cacheControl string
+	completed    string // key
+	sse          string // SSE of the last upload, either posted or multipart
+	kmsKeyID     string
+	keys         []string // listed keys
+	deletes      int      // DeleteObjects requests
+	deleted      []string
+	deleteErr    string // key failed to be deleted
+	etag         string // returned for uploads and HEAD requests if set
+	noPostETag   bool
+	heads        int
+	posted       []byte // file of the last POST
+	puts         int    // objects uploaded with single PUT
+}
+
+func (s *stubS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q := r.URL.Query()
+	_, initiate := q["uploads"]
+	_, del := q["delete"]
+	switch {
+	case r.Method == "POST" && del:
+		s.deletes++
+		var req struct {
+			Objects []struct {
+				Key string `xml:"Key"`
+			} `xml:"Object"`
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		xml.Unmarshal(body, &req)
+		fmt.Fprint(w, `<DeleteResult>`)
+		for _, obj := range req.Objects {
+			if obj.Key == s.deleteErr {
+				fmt.Fprintf(w, `<Error><Key>%s</Key><Code>AccessDenied</Code><Message>Access Denied</Message></Error>`, obj.Key)
+				continue
+			}
+			s.deleted = append(s.deleted, obj.Key)
+		}
+		fmt.Fprint(w, `</DeleteResult>`)
+	case r.Method == "GET" && q.Get("list-type") == "2":
+		fmt.Fprint(w, `<ListBucketResult><Name>bucket</Name><IsTruncated>false</IsTruncated>`)
+		for _, key := range s.keys {
+			if strings.HasPrefix(key, q.Get("prefix")) {
+				fmt.Fprintf(w, `<Contents><Key>%s</Key><LastModified>2020-01-01T00:00:00.000Z</LastModified><Size>4</Size></Contents>`, key)
+			}
+		}
+		fmt.Fprint(w, `</ListBucketResult>`)
+	case r.Method == "POST" && (r.URL.Path == "/" || r.URL.Path == "/bucket"):
+		s.posts++
+		r.ParseMultipartForm(32 << 20)
+		s.acl = r.FormValue("acl")
+		s.contentType = r.FormValue("Content-Type")
+		s.cacheControl = r.FormValue("Cache-Control")
+		s.sse = r.FormValue("x-amz-server-side-encryption")
+		s.kmsKeyID = r.FormValue("x-amz-server-side-encryption-aws-kms-key-id")
+		if f, _, err := r.FormFile("file"); err == nil {
+			s.posted, _ = ioutil.ReadAll(f)
+		}
+		if s.etag != "" && !s.noPostETag {
+			w.Header().Set("ETag", `"`+s.etag+`"`)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == "POST" && initiate:
+		s.acl = r.Header.Get("X-Amz-Acl")
+		s.contentType = r.Header.Get("Content-Type")
+		s.cacheControl = r.Header.Get("Cache-Control")
+		s.sse = r.Header.Get("X-Amz-Server-Side-Encryption")
+		s.kmsKeyID = r.Header.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id")
+		fmt.Fprint(w, `<InitiateMultipartUploadResult><Bucket>bucket</Bucket><Key>key</Key><UploadId>uploadid</UploadId></InitiateMultipartUploadResult>`)
+	case r.Method == "PUT" && q.Get("uploadId") == "uploadid":
+		data, _ := ioutil.ReadAll(r.Body)
+		s.parts[q.Get("partNumber")] = len(data)
+		w.Header().Set("ETag", `"etag`+q.Get("partNumber")+`"`)
+	case r.Method == "PUT" && q.Get("uploadId") == "":
+		s.puts++
+		s.contentType = r.Header.Get("Content-Type")
+		ioutil.ReadAll(r.Body)
+		w.Header().Set("ETag", `"`+s.etag+`"`)
+	case r.Method == "POST" && q.Get("uploadId") == "uploadid":
+		s.completed = r.URL.Path
+		fmt.Fprint(w, `<CompleteMultipartUploadResult><Bucket>bucket</Bucket><Key>key</Key><ETag>"etag"</ETag></CompleteMultipartUploadResult>`)
+	case r.Method == "HEAD":
+		s.heads++
+		w.Header().Set("ETag", `"`+s.etag+`"`)
+	case r.Method == "GET" && r.URL.Path == "/bucket/path/name/1.ts":
+		w.Write([]byte("data"))
+	case r.Method == "GET":
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func TestS3Session_SaveData_Multipart(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	defer func(threshold, partSize, maxPost int64) {
+		S3MultipartThreshold, S3MultipartPartSize, s3MaxPostSize = threshold, partSize, maxPost
+	}(S3MultipartThreshold, S3MultipartPartSize, s3MaxPostSize)
+	S3MultipartThreshold = 1024 * 1024
+	S3MultipartPartSize = 5 * 1024 * 1024
+
+	stub := &stubS3{parts: make(map[string]int)}
+	ts := httptest.NewServer(stub)
+	defer ts.Close()
+
+	cfg := aws.NewConfig().
+		WithRegion("us-east-1").
+		WithCredentials(credentials.NewStaticCredentials("key", "secret", "")).
+		WithEndpoint(ts.URL).
+		WithS3ForcePathStyle(true)
+	sess := &s3Session{host: ts.URL, bucket: "bucket", key: "path", acl: S3DefaultACL, s3svc: s3.New(session.New(), cfg)}
+
+	// big data is uploaded in parts
+	uri, err := sess.SaveData("name/1.mp4", make([]byte, 11*1024*1024), nil)
+	require.Nil(err)
+	assert.Equal(ts.URL+"/path/name/1.mp4", uri)
+	assert.Equal(map[string]int{"1": 5 * 1024 * 1024, "2": 5 * 1024 * 1024, "3": 1024 * 1024}, stub.parts)
+	assert.Equal("/bucket/path/name/1.mp4", stub.completed)
+	assert.Equal("public-read", stub.acl)
+	assert.Equal("video/mp4", stub.contentType)
+	assert.Equal(0, stub.posts)
+
+	// small data is posted
+	_, err = sess.SaveData("name/1.ts", make([]byte, 1024), nil)
+	require.Nil(err)
+	assert.Equal(1, stub.posts)
+
+	// bucket accessed with POST policy
+	sess.s3svc = nil
+	_, err = sess.SaveData("name/2.mp4", make([]byte, 2*1024*1024), nil)
+	require.Nil(err)
+	assert.Equal(2, stub.posts)
+	s3MaxPostSize = 1024 * 1024
+	_, err = sess.SaveData("name/3.mp4", make([]byte, 2*1024*1024), nil)
+	assert.Equal(ErrS3MultipartNotSupported, err)
+	assert.Equal(2, stub.posts)
+}
+
+func TestS3Session_SaveDataReader(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	defer func(threshold, partSize int64) {
+		S3MultipartThreshold, S3MultipartPartSize = threshold, partSize
+	}(S3MultipartThreshold, S3MultipartPartSize)
+	S3MultipartThreshold = 1024 * 1024
+	S3MultipartPartSize = 5 * 1024 * 1024
+
+	stub := &stubS3{parts: make(map[string]int)}
+	ts := httptest.NewServer(stub)
+	defer ts.Close()
+	sess := NewS3Driver(S3Options{Region: "us-east-1", Bucket: "bucket", AccessKey: "key", AccessKeySecret: "secret", Endpoint: ts.URL, PathStyle: true}).NewSession("path").(*s3Session)
+	png := []byte("\x89PNG\r\n\x1a\n0123456789")
+	// hides io.Seeker of the readers
+	stream := func(data []byte) io.Reader { return struct{ io.Reader }{bytes.NewReader(data)} }
+
+	// small data is streamed in POST body, content type detected from it
+	uri, err := sess.SaveDataReader("name/1", stream(png), int64(len(png)))
+	require.Nil(err)
+	assert.Equal(ts.URL+"/bucket/path/name/1", uri)
+	assert.Equal(1, stub.posts)
+	assert.Equal(png, stub.posted)
+	assert.Equal("image/png", stub.contentType)
+
+	// length of the data given by the size is posted
+	_, err = sess.SaveDataReader("name/1", stream(png), 4)
+	assert.NotNil(err)
+
+	// data of unknown size is uploaded in parts, the uploader doesn't need
+	// the whole data
+	_, err = sess.SaveDataReader("name/2.mp4", stream(make([]byte, 11*1024*1024)), -1)
+	require.Nil(err)
+	assert.Equal(map[string]int{"1": 5 * 1024 * 1024, "2": 5 * 1024 * 1024, "3": 1024 * 1024}, stub.parts)
+	assert.Equal("/bucket/path/name/2.mp4", stub.completed)
+	assert.Equal("video/mp4", stub.contentType)
+
+	// or with single request if it fits into one part
+	_, err = sess.SaveDataReader("name/2.ts", stream([]byte("data")), -1)
+	require.Nil(err)
+	assert.Equal(1, stub.puts)
+	assert.Equal(2, stub.posts)
+
+	// bucket accessed with POST policy needs the length of the data
+	sess.s3svc = nil
+	_, err = sess.SaveDataReader("name/3", stream(png), -1)
+	require.Nil(err)
+	assert.Equal(3, stub.posts)
+	assert.Equal(png, stub.posted)
+	assert.Equal("image/png", stub.contentType)
+}
+
+func TestS3Session_PostData_Retry(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	defer func(attempts int, delay time.Duration) {
+		S3PostMaxAttempts, S3PostRetryDelay = attempts, delay
+	}(S3PostMaxAttempts, S3PostRetryDelay)
+	S3PostMaxAttempts = 3
+	S3PostRetryDelay = time.Millisecond
+
+	var mu sync.Mutex
+	var posts int
+	var statuses []int
+	var hangup bool
+	var file string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		posts++
+		if f, _, err := r.FormFile("file"); err == nil {
+			data, _ := ioutil.ReadAll(f)
+			file = string(data)
+		}
+		if hangup {
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		status := http.StatusNoContent
+		if len(statuses) > 0 {
+			status, statuses = statuses[0], statuses[1:]
+		}
+		w.WriteHeader(status)
+		if status != http.StatusNoContent {
+			fmt.Fprintf(w, "<Error><Code>%d</Code></Error>", status)
+		}
+	}))
+	defer ts.Close()
+	sess := &s3Session{host: ts.URL, key: "path"}
+	reset := func(s ...int) {
+		mu.Lock()
+		defer mu.Unlock()
+		posts, statuses = 0, s
+	}
+	numPosts := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return posts
+	}
+
+	// transient 5xx errors are retried
+	reset(http.StatusServiceUnavailable, http.StatusInternalServerError)
+	_, err := sess.SaveData("name/1.ts", []byte("data"), nil)
+	require.Nil(err)
+	assert.Equal(3, numPosts())
+
+	// last error is returned unchanged when out of attempts
+	reset(http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusBadGateway)
+	_, err = sess.SaveData("name/1.ts", []byte("data"), nil)
+	assert.EqualError(err, "502")
+	assert.Equal(3, numPosts())
+
+	// 4xx policy errors aren't retried
+	reset(http.StatusForbidden)
+	_, err = sess.SaveData("name/1.ts", []byte("data"), nil)
+	assert.EqualError(err, "403")
+	assert.Equal(1, numPosts())
+
+	// readers that can't be rewound aren't retried
+	reset(http.StatusServiceUnavailable)
+	_, err = sess.SaveDataReader("name/1.ts", struct{ io.Reader }{strings.NewReader("data")}, 4)
+	assert.EqualError(err, "503")
+	assert.Equal(1, numPosts())
+	reset(http.StatusServiceUnavailable)
+	_, err = sess.SaveDataReader("name/1.ts", strings.NewReader("data"), 4)
+	assert.Nil(err)
+	assert.Equal(2, numPosts())
+	mu.Lock()
+	assert.Equal("data", file)
+	mu.Unlock()
+
+	// network errors are retried
+	reset()
+	mu.Lock()
+	hangup = true
+	mu.Unlock()
+	_, err = sess.SaveData("name/1.ts", []byte("data"), nil)
+	assert.NotNil(err)
+	assert.Equal(3, numPosts())
+}
+
+func TestS3Error(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	body := `<?xml version="1.0" encoding="UTF-8"?>
+<Error><Code>EntityTooLarge</Code><Message>Your proposed upload exceeds the maximum allowed size</Message><RequestId>4442587FB7D0A2F9</RequestId></Error>`
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, body)
+	}))
+	defer ts.Close()
+	sess := &s3Session{host: ts.URL, key: "path"}
+
+	_, err := sess.SaveData("name/1.ts", []byte("data"), nil)
+	var s3err *S3Error
+	require.True(errors.As(err, &s3err))
+	assert.Equal(http.StatusBadRequest, s3err.StatusCode)
+	assert.Equal("EntityTooLarge", s3err.Code)
+	assert.Equal("Your proposed upload exceeds the maximum allowed size", s3err.Message)
+	assert.Equal("4442587FB7D0A2F9", s3err.RequestID)
+	assert.EqualError(err, "EntityTooLarge: Your proposed upload exceeds the maximum allowed size requestId=4442587FB7D0A2F9")
+	assert.Equal(body, s3err.String())
+
+	// body that isn't S3 error XML is returned as is
+	s3err = newS3Error(http.StatusBadGateway, []byte("Bad Gateway"))
+	assert.Equal("", s3err.Code)
+	assert.EqualError(s3err, "Bad Gateway")
+	assert.Equal("Bad Gateway", s3err.String())
+}
+
+func TestS3Host(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("https://bucket.s3.amazonaws.com", s3Host("", "bucket", false))
+	assert.Equal("https://s3.amazonaws.com/bucket", s3Host("", "bucket", true))
+	assert.Equal("https://bucket.nyc3.digitaloceanspaces.com", s3Host("https://nyc3.digitaloceanspaces.com", "bucket", false))
+	assert.Equal("http://minio:9000/bucket", s3Host("http://minio:9000/", "bucket", true))
+	assert.Equal("http://bucket.minio:9000", s3Host("http://minio:9000", "bucket", false))
+}
+
+func TestIsOwnStorageS3(t *testing.T) {
+	assert := assert.New(t)
+	ResetOwnS3Buckets()
+	defer ResetOwnS3Buckets()
+
+	assert.False(IsOwnStorageS3("https://bucket.s3.amazonaws.com/path/1.ts"))
+
+	// AWS bucket in both addressing styles, at global and regional hosts
+	NewS3Driver(S3Options{Region: "us-east-2", Bucket: "bucket"})
+	assert.True(IsOwnStorageS3("https://bucket.s3.amazonaws.com/path/1.ts"))
+	assert.True(IsOwnStorageS3("https://s3.amazonaws.com/bucket/path/1.ts"))
+	assert.True(IsOwnStorageS3("https://bucket.s3.us-east-2.amazonaws.com/path/1.ts"))
+	assert.False(IsOwnStorageS3("https://bucket.s3.eu-west-1.amazonaws.com/path/1.ts"))
+	assert.False(IsOwnStorageS3("https://bucket2.s3.amazonaws.com/path/1.ts"))
+	assert.False(IsOwnStorageS3("https://s3.amazonaws.com/bucket2/path/1.ts"))
+
+	// multiple buckets at custom endpoints
+	NewS3Driver(S3Options{Bucket: "bucket2", Endpoint: "http://minio:9000", PathStyle: true})
+	NewS3Driver(S3Options{Bucket: "media.bucket", Endpoint: "https://nyc3.digitaloceanspaces.com"})
+	assert.True(IsOwnStorageS3("http://minio:9000/bucket2/path/1.ts"))
+	assert.True(IsOwnStorageS3("http://bucket2.minio:9000/path/1.ts"))
+	assert.False(IsOwnStorageS3("http://minio:9000/bucket/path/1.ts"))
+	assert.False(IsOwnStorageS3("http://minio/bucket2/path/1.ts"))
+	assert.True(IsOwnStorageS3("https://media.bucket.nyc3.digitaloceanspaces.com/path/1.ts"))
+	assert.False(IsOwnStorageS3("https://bucket.nyc3.digitaloceanspaces.com/path/1.ts"))
+	// still the first one
+	assert.True(IsOwnStorageS3("https://bucket.s3.amazonaws.com/path/1.ts"))
+
+	assert.False(IsOwnStorageS3(""))
+	assert.False(IsOwnStorageS3("/bucket/path/1.ts"))
+	assert.False(IsOwnStorageS3("::invalid"))
+}
+
+func TestS3Driver_CustomEndpoint(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	defer func(threshold, partSize int64) {
+		S3MultipartThreshold, S3MultipartPartSize = threshold, partSize
+	}(S3MultipartThreshold, S3MultipartPartSize)
+	S3MultipartThreshold = 1024 * 1024
+	S3MultipartPartSize = 5 * 1024 * 1024
+
+	stub := &stubS3{parts: make(map[string]int)}
+	ts := httptest.NewServer(stub)
+	defer ts.Close()
+
+	sess := NewS3Driver(S3Options{Region: "us-east-1", Bucket: "bucket", AccessKey: "key", AccessKeySecret: "secret", Endpoint: ts.URL, PathStyle: true}).NewSession("path")
+	info := sess.GetInfo().S3Info
+	assert.Equal(ts.URL+"/bucket", info.Host)
+	assert.Contains(info.Credential, "key/")
+	assert.Contains(info.Credential, "/us-east-1/s3/aws4_request")
+
+	// POST policy upload goes to the endpoint
+	uri, err := sess.SaveData("name/1.ts", []byte("data"), nil)
+	require.Nil(err)
+	assert.Equal(ts.URL+"/bucket/path/name/1.ts", uri)
+	assert.Equal(1, stub.posts)
+
+	// so does multipart upload with bucket credentials
+	uri, err = sess.SaveData("name/1.mp4", make([]byte, 6*1024*1024), nil)
+	require.Nil(err)
+	assert.Equal(ts.URL+"/bucket/path/name/1.mp4", uri)
+	assert.Equal("/bucket/path/name/1.mp4", stub.completed)
+
+	// own storage is recognized at the endpoint
+	assert.True(IsOwnStorageS3(uri))
+}
+
+func TestS3Driver_SSE(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	defer func(threshold, partSize int64) {
+		S3MultipartThreshold, S3MultipartPartSize = threshold, partSize
+	}(S3MultipartThreshold, S3MultipartPartSize)
+	S3MultipartThreshold = 1024 * 1024
+	S3MultipartPartSize = 5 * 1024 * 1024
+
+	stub := &stubS3{parts: make(map[string]int)}
+	ts := httptest.NewServer(stub)
+	defer ts.Close()
+
+	// no encryption by default
+	sess := NewS3Driver(S3Options{Region: "us-east-1", Bucket: "bucket", AccessKey: "key", AccessKeySecret: "secret", Endpoint: ts.URL, PathStyle: true}).NewSession("path")
+	_, err := sess.SaveData("name/1.ts", []byte("data"), nil)
+	require.Nil(err)
+	assert.Equal("", stub.sse)
+	policy, err := base64.StdEncoding.DecodeString(sess.GetInfo().S3Info.Policy)
+	require.Nil(err)
+	assert.NotContains(string(policy), "x-amz-server-side-encryption")
+
+	// SSE-S3
+	sess = NewS3Driver(S3Options{Region: "us-east-1", Bucket: "bucket", AccessKey: "key", AccessKeySecret: "secret", Endpoint: ts.URL, PathStyle: true, SSE: s3.ServerSideEncryptionAes256}).NewSession("path")
+	_, err = sess.SaveData("name/1.ts", []byte("data"), nil)
+	require.Nil(err)
+	assert.Equal("AES256", stub.sse)
+	assert.Equal("", stub.kmsKeyID)
+	policy, err = base64.StdEncoding.DecodeString(sess.GetInfo().S3Info.Policy)
+	require.Nil(err)
+	assert.Contains(string(policy), `{"x-amz-server-side-encryption": "AES256"}`)
+	assert.NotContains(string(policy), "x-amz-server-side-encryption-aws-kms-key-id")
+
+	// SSE-KMS is set for both posted and multipart uploads
+	sess = NewS3Driver(S3Options{Region: "us-east-1", Bucket: "bucket", AccessKey: "key", AccessKeySecret: "secret", Endpoint: ts.URL, PathStyle: true, SSE: s3.ServerSideEncryptionAwsKms, KMSKeyID: "keyid"}).NewSession("path")
+	_, err = sess.SaveData("name/1.ts", []byte("data"), nil)
+	require.Nil(err)
+	assert.Equal("aws:kms", stub.sse)
+	assert.Equal("keyid", stub.kmsKeyID)
+	policy, err = base64.StdEncoding.DecodeString(sess.GetInfo().S3Info.Policy)
+	require.Nil(err)
+	assert.Contains(string(policy), `{"x-amz-server-side-encryption": "aws:kms"}`)
+	assert.Contains(string(policy), `{"x-amz-server-side-encryption-aws-kms-key-id": "keyid"}`)
+
+	stub.sse, stub.kmsKeyID = "", ""
+	_, err = sess.SaveData("name/1.mp4", make([]byte, 6*1024*1024), nil)
+	require.Nil(err)
+	assert.Equal("aws:kms", stub.sse)
+	assert.Equal("keyid", stub.kmsKeyID)
+
+	// session created from OSInfo takes encryption settings from the policy
+	stub.sse, stub.kmsKeyID = "", ""
+	remote := NewSession(sess.GetInfo())
+	_, err = remote.SaveData("name/2.ts", []byte("data"), nil)
+	require.Nil(err)
+	assert.Equal("aws:kms", stub.sse)
+	assert.Equal("keyid", stub.kmsKeyID)
+}
+
+func TestS3UploadPool(t *testing.T) {
+	assert := assert.New(t)
+
+	pool := newS3UploadPool(2)
+	release := make(chan struct{})
+	started := make(chan struct{}, 5)
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			uri, err := pool.do(func() (string, error) {
+				started <- struct{}{}
+				<-release
+				return "uri", nil
+			})
+			assert.Nil(err)
+			assert.Equal("uri", uri)
+		}()
+	}
+	<-started
+	<-started
+	counts := func() (int, int) {
+		pool.mu.Lock()
+		defer pool.mu.Unlock()
+		return pool.inFlight, pool.waiting
+	}
+	assert.Eventually(func() bool {
+		inFlight, waiting := counts()
+		return inFlight == 2 && waiting == 3
+	}, time.Second, time.Millisecond)
+	// the rest waits for free slots
+	select {
+	case <-started:
+		t.Error("upload started above the limit")
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(release)
+	wg.Wait()
+	assert.Len(started, 3)
+	for len(started) > 0 {
+		<-started
+	}
+	inFlight, waiting := counts()
+	assert.Equal(0, inFlight)
+	assert.Equal(0, waiting)
+
+	// unbounded
+	pool = newS3UploadPool(0)
+	release = make(chan struct{})
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pool.do(func() (string, error) {
+				started <- struct{}{}
+				<-release
+				return "", nil
+			})
+		}()
+	}
+	for i := 0; i < 5; i++ {
+		<-started
+	}
+	inFlight, _ = counts()
+	assert.Equal(5, inFlight)
+	close(release)
+	wg.Wait()
+
+	// sessions without pool upload right away
+	var nilPool *s3UploadPool
+	uri, err := nilPool.do(func() (string, error) { return "uri", nil })
+	assert.Nil(err)
+	assert.Equal("uri", uri)
+}
+
+func TestS3Driver_MaxConcurrentUploads(t *testing.T) {
+	assert := assert.New(t)
+
+	defer func(max int) { S3MaxConcurrentUploads = max }(S3MaxConcurrentUploads)
+	S3MaxConcurrentUploads = 2
+
+	var mu sync.Mutex
+	var inFlight, maxInFlight int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	// the limit is shared by all the sessions of the driver
+	driver := NewS3Driver(S3Options{Region: "us-east-1", Bucket: "bucket", AccessKey: "key", AccessKeySecret: "secret", Endpoint: ts.URL, PathStyle: true})
+	sessions := []OSSession{driver.NewSession("a"), driver.NewSession("b")}
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := sessions[i%2].SaveData(fmt.Sprintf("%d.ts", i), []byte("data"), nil)
+			assert.Nil(err)
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(2, maxInFlight)
+}
+
+func TestS3PolicyConditions(t *testing.T) {
+	assert := assert.New(t)
+	assert.Empty(s3PolicyConditions("not base64"))
+	assert.Empty(s3PolicyConditions(base64.StdEncoding.EncodeToString([]byte("not json"))))
+	policy, _, _, _ := createPolicy("key", "bucket", "region", "secret", "path", "aws:kms", "keyid", "private")
+	conditions := s3PolicyConditions(policy)
+	assert.Equal("aws:kms", conditions[s3SSEField])
+	assert.Equal("keyid", conditions[s3KMSKeyIDField])
+	assert.Equal("private", conditions["acl"])
+	assert.Equal("bucket", conditions["bucket"])
+}
+
+func TestS3Session_SaveData_Properties(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	defer func(threshold, partSize int64) {
+		S3MultipartThreshold, S3MultipartPartSize = threshold, partSize
+	}(S3MultipartThreshold, S3MultipartPartSize)
+	S3MultipartThreshold = 1024 * 1024
+	S3MultipartPartSize = 5 * 1024 * 1024
+
+	stub := &stubS3{parts: make(map[string]int)}
+	ts := httptest.NewServer(stub)
+	defer ts.Close()
+
+	sess := NewS3Driver(S3Options{Region: "us-east-1", Bucket: "bucket", AccessKey: "key", AccessKeySecret: "secret", Endpoint: ts.URL, PathStyle: true}).NewSession("path")
+	policy, err := base64.StdEncoding.DecodeString(sess.GetInfo().S3Info.Policy)
+	require.Nil(err)
+	assert.Contains(string(policy), `["starts-with", "$Cache-Control", ""]`)
+
+	// content type is detected by default
+	_, err = sess.SaveData("name/1.ts", []byte("data"), nil)
+	require.Nil(err)
+	assert.Equal("video/mp2t", stub.contentType)
+	assert.Equal("", stub.cacheControl)
+
+	// explicit properties are posted
+	props := &FileProperties{ContentType: "application/vnd.apple.mpegurl", CacheControl: "max-age=1"}
+	_, err = sess.SaveData("name/index.m3u8", []byte("#EXTM3U"), props)
+	require.Nil(err)
+	assert.Equal("application/vnd.apple.mpegurl", stub.contentType)
+	assert.Equal("max-age=1", stub.cacheControl)
+
+	// and set for multipart uploads
+	props = &FileProperties{ContentType: "video/x-custom", CacheControl: "max-age=3600"}
+	_, err = sess.SaveData("name/1.mp4", make([]byte, 6*1024*1024), props)
+	require.Nil(err)
+	assert.Equal("video/x-custom", stub.contentType)
+	assert.Equal("max-age=3600", stub.cacheControl)
+
+	// only cache control given
+	_, err = sess.SaveData("name/2.ts", []byte("data"), &FileProperties{CacheControl: "no-cache"})
+	require.Nil(err)
+	assert.Equal("video/mp2t", stub.contentType)
+	assert.Equal("no-cache", stub.cacheControl)
+}
+
+func TestS3Driver_ACL(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	defer func(threshold, partSize int64) {
+		S3MultipartThreshold, S3MultipartPartSize = threshold, partSize
+	}(S3MultipartThreshold, S3MultipartPartSize)
+	S3MultipartThreshold = 1024 * 1024
+	S3MultipartPartSize = 5 * 1024 * 1024
+
+	stub := &stubS3{parts: make(map[string]int)}
+	ts := httptest.NewServer(stub)
+	defer ts.Close()
+
+	// public-read by default, bare URL is returned
+	sess := NewS3Driver(S3Options{Region: "us-east-1", Bucket: "bucket", AccessKey: "key", AccessKeySecret: "secret", Endpoint: ts.URL, PathStyle: true}).NewSession("path")
+	uri, err := sess.SaveData("name/1.ts", []byte("data"), nil)
+	require.Nil(err)
+	assert.Equal(ts.URL+"/bucket/path/name/1.ts", uri)
+	assert.Equal("public-read", stub.acl)
+	policy, err := base64.StdEncoding.DecodeString(sess.GetInfo().S3Info.Policy)
+	require.Nil(err)
+	assert.Contains(string(policy), `{"acl": "public-read"}`)
+
+	// private objects are returned with presigned URL
+	sess = NewS3Driver(S3Options{Region: "us-east-1", Bucket: "bucket", AccessKey: "key", AccessKeySecret: "secret", Endpoint: ts.URL, PathStyle: true, ACL: s3.ObjectCannedACLPrivate}).NewSession("path")
+	policy, err = base64.StdEncoding.DecodeString(sess.GetInfo().S3Info.Policy)
+	require.Nil(err)
+	assert.Contains(string(policy), `{"acl": "private"}`)
+	assert.NotContains(string(policy), "public-read")
+
+	uri, err = sess.SaveData("name/1.ts", []byte("data"), nil)
+	require.Nil(err)
+	assert.Equal("private", stub.acl)
+	u, err := url.Parse(uri)
+	require.Nil(err)
+	assert.Equal("/bucket/path/name/1.ts", u.Path)
+	assert.Equal(strconv.Itoa(int(S3PrivateURLExpiry.Seconds())), u.Query().Get("X-Amz-Expires"))
+	assert.NotEmpty(u.Query().Get("X-Amz-Signature"))
+	resp, err := http.Get(uri)
+	require.Nil(err)
+	resp.Body.Close()
+	assert.Equal(http.StatusOK, resp.StatusCode)
+
+	stub.acl = ""
+	uri, err = sess.SaveData("name/1.mp4", make([]byte, 6*1024*1024), nil)
+	require.Nil(err)
+	assert.Equal("private", stub.acl)
+	assert.Contains(uri, "X-Amz-Signature=")
+
+	// other nodes can't presign, so bare URL is returned
+	stub.acl = ""
+	remote := NewSession(sess.GetInfo())
+	uri, err = remote.SaveData("name/2.ts", []byte("data"), nil)
+	require.Nil(err)
+	assert.Equal("private", stub.acl)
+	assert.Equal(ts.URL+"/bucket/path/name/2.ts", uri)
+}
+
+func TestS3ETag(t *testing.T) {
+	assert := assert.New(t)
+	data := []byte("0123456789")
+	assert.Equal("781e5e245d69b566979b86e28d23f2c7", s3ETag(data, 0))
+	assert.Equal("781e5e245d69b566979b86e28d23f2c7", s3ETag(data, 10))
+	// md5(md5("01234") + md5("56789"))
+	h := md5.New()
+	for _, part := range []string{"01234", "56789"} {
+		sum := md5.Sum([]byte(part))
+		h.Write(sum[:])
+	}
+	assert.Equal(hex.EncodeToString(h.Sum(nil))+"-2", s3ETag(data, 5))
+	h.Reset()
+	for _, part := range []string{"0123", "4567", "89"} {
+		sum := md5.Sum([]byte(part))
+		h.Write(sum[:])
+	}
+	assert.Equal(hex.EncodeToString(h.Sum(nil))+"-3", s3ETag(data, 4))
+
+	// data written in any chunks
+	for _, partSize := range []int64{0, 4, 5, 10} {
+		eh := newS3ETagHash(-1, partSize)
+		for _, chunk := range []string{"0", "123", "456789"} {
+			eh.Write([]byte(chunk))
+		}
+		assert.Equal(s3ETag(data, partSize), eh.ETag())
+	}
+	assert.Equal("d41d8cd98f00b204e9800998ecf8427e", newS3ETagHash(0, 5).ETag())
+}
+
+func TestS3Driver_StrictChecksum(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	defer func(threshold, partSize int64) {
+		S3MultipartThreshold, S3MultipartPartSize = threshold, partSize
+	}(S3MultipartThreshold, S3MultipartPartSize)
+	S3MultipartThreshold = 1024 * 1024
+	S3MultipartPartSize = 5 * 1024 * 1024
+	defer func(attempts int, delay time.Duration) {
+		S3PostMaxAttempts, S3PostRetryDelay = attempts, delay
+	}(S3PostMaxAttempts, S3PostRetryDelay)
+	S3PostMaxAttempts, S3PostRetryDelay = 2, time.Millisecond
+
+	stub := &stubS3{parts: make(map[string]int)}
+	ts := httptest.NewServer(stub)
+	defer ts.Close()
+	data := []byte("data")
+
+	// mismatch is only logged by default
+	sess := NewS3Driver(S3Options{Region: "us-east-1", Bucket: "bucket", AccessKey: "key", AccessKeySecret: "secret", Endpoint: ts.URL, PathStyle: true}).NewSession("path")
+	stub.etag = "corrupted"
+	_, err := sess.SaveData("name/1.ts", data, nil)
+	assert.Nil(err)
+
+	defer func() { S3StrictChecksum = false }()
+	S3StrictChecksum = true
+	sess = NewS3Driver(S3Options{Region: "us-east-1", Bucket: "bucket", AccessKey: "key", AccessKeySecret: "secret", Endpoint: ts.URL, PathStyle: true}).NewSession("path")
+
+	// ETag of the posted object is verified, mismatch is retried
+	stub.posts = 0
+	_, err = sess.SaveData("name/1.ts", data, nil)
+	assert.Equal(ErrS3ChecksumMismatch, err)
+	assert.Equal(2, stub.posts)
+	stub.etag = s3ETag(data, 0)
+	_, err = sess.SaveData("name/1.ts", data, nil)
+	assert.Nil(err)
+	assert.Equal(0, stub.heads)
+
+	// ETag is read if the upload response doesn't have it
+	stub.noPostETag = true
+	_, err = sess.SaveData("name/1.ts", data, nil)
+	assert.Nil(err)
+	assert.Equal(1, stub.heads)
+	stub.etag = "corrupted"
+	_, err = sess.SaveData("name/1.ts", data, nil)
+	assert.Equal(ErrS3ChecksumMismatch, err)
+	assert.Equal(3, stub.heads)
+
+	// multipart object is read
+	big := make([]byte, 6*1024*1024)
+	stub.heads = 0
+	stub.etag = s3ETag(big, 0)
+	_, err = sess.SaveData("name/1.mp4", big, nil)
+	assert.Equal(ErrS3ChecksumMismatch, err)
+	assert.Equal(1, stub.heads)
+	stub.etag = s3ETag(big, S3MultipartPartSize)
+	_, err = sess.SaveData("name/1.mp4", big, nil)
+	require.Nil(err)
+	assert.Equal(2, stub.heads)
+
+	// streamed data is hashed as it is uploaded
+	_, err = sess.SaveDataReader("name/1.mp4", struct{ io.Reader }{bytes.NewReader(big)}, -1)
+	require.Nil(err)
+	assert.Equal(3, stub.heads)
+
+	// ETag of SSE-KMS objects isn't MD5, so not verified
+	sess = NewS3Driver(S3Options{Region: "us-east-1", Bucket: "bucket", AccessKey: "key", AccessKeySecret: "secret", Endpoint: ts.URL, PathStyle: true, SSE: s3.ServerSideEncryptionAwsKms}).NewSession("path")
+	stub.etag = "kms"
+	_, err = sess.SaveData("name/1.ts", data, nil)
+	assert.Nil(err)
+}
+
+func TestDeleteAllData_S3(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	defer func(maxDelete int) { s3MaxDeleteObjects = maxDelete }(s3MaxDeleteObjects)
+	s3MaxDeleteObjects = 2
+
+	stub := &stubS3{parts: make(map[string]int), keys: []string{"path/a.ts", "path/b.ts", "path/c/1.ts", "other/a.ts"}}
+	ts := httptest.NewServer(stub)
+	defer ts.Close()
+
+	// own bucket, deleted in batches
+	sess := NewS3Driver(S3Options{Region: "us-east-1", Bucket: "bucket", AccessKey: "key", AccessKeySecret: "secret", Endpoint: ts.URL, PathStyle: true}).NewSession("path")
+	count, err := DeleteAllData(sess)
+	require.Nil(err)
+	assert.Equal(3, count)
+	assert.Equal(2, stub.deletes)
+	assert.Equal([]string{"path/a.ts", "path/b.ts", "path/c/1.ts"}, stub.deleted)
+
+	// error deleting some of the objects
+	stub.deleted = nil
+	stub.deleteErr = "path/b.ts"
+	count, err = DeleteAllData(sess)
+	assert.EqualError(err, "key=path/b.ts code=AccessDenied message=Access Denied")
+	assert.Equal(1, count)
+	assert.Equal([]string{"path/a.ts"}, stub.deleted)
+
+	// bucket of other node
+	count, err = DeleteAllData(NewSession(sess.GetInfo()))
+	assert.Equal(ErrNotSupported, err)
+	assert.Equal(0, count)
+}
+
+func TestReap_S3(t *testing.T) {
+	assert := assert.New(t)
+
+	defer func(maxDelete int) { s3MaxDeleteObjects = maxDelete }(s3MaxDeleteObjects)
+	s3MaxDeleteObjects = 2
+
+	stub := &stubS3{parts: make(map[string]int), keys: []string{"mid/P144p/1.ts", "mid/P144p/2.ts", "mid/source/1.ts", "index.html"}}
+	ts := httptest.NewServer(stub)
+	defer ts.Close()
+
+	// only segments are deleted, in batches
+	sess := NewS3Driver(S3Options{Region: "us-east-1", Bucket: "bucket", AccessKey: "key", AccessKeySecret: "secret", Endpoint: ts.URL, PathStyle: true}).NewSession("")
+	count, freed, err := reap(sess, time.Hour)
+	assert.Nil(err)
+	assert.Equal(3, count)
+	assert.Equal(int64(12), freed)
+	assert.Equal(2, stub.deletes)
+	assert.Equal([]string{"mid/P144p/1.ts", "mid/P144p/2.ts", "mid/source/1.ts"}, stub.deleted)
+
+	// bytes of objects which weren't deleted aren't counted
+	stub.deleted = nil
+	stub.deleteErr = "mid/P144p/2.ts"
+	count, freed, err = reap(sess, time.Hour)
+	assert.EqualError(err, "key=mid/P144p/2.ts code=AccessDenied message=Access Denied")
+	assert.Equal(1, count)
+	assert.Equal(int64(4), freed)
+}
+
+func TestS3Session_ReadData(t *testing.T) {
+	assert := assert.New(t)
+
+	stub := &stubS3{parts: make(map[string]int)}
+	ts := httptest.NewServer(stub)
+	defer ts.Close()
+
+	// own bucket
+	sess := NewS3Driver(S3Options{Region: "us-east-1", Bucket: "bucket", AccessKey: "key", AccessKeySecret: "secret", Endpoint: ts.URL, PathStyle: true}).NewSession("path")
+	data, err := sess.ReadData("name/1.ts")
+	assert.Nil(err)
+	assert.Equal([]byte("data"), data)
+	_, err = sess.ReadData("name/2.ts")
+	assert.Equal(ErrNotFound, err)
+
+	// bucket of other node
+	sess = NewSession(sess.GetInfo())
+	data, err = sess.ReadData("name/1.ts")
+	assert.Nil(err)
+	assert.Equal([]byte("data"), data)
+	_, err = sess.ReadData("name/2.ts")
+	assert.Equal(ErrNotFound, err)
+}
+
+func TestS3Session_PresignedGetURL(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	stub := &stubS3{parts: make(map[string]int)}
+	ts := httptest.NewServer(stub)
+	defer ts.Close()
+
+	sess := NewS3Driver(S3Options{Region: "us-east-1", Bucket: "bucket", AccessKey: "key", AccessKeySecret: "secret", Endpoint: ts.URL, PathStyle: true}).NewSession("path").(Presigner)
+	_, err := sess.PresignedGetURL("name/1.ts", 0)
+	assert.Equal(ErrPresignExpiry, err)
+	_, err = sess.PresignedGetURL("name/1.ts", S3PresignMaxExpiry+time.Second)
+	assert.Equal(ErrPresignExpiry, err)
+
+	uri, err := sess.PresignedGetURL("name/1.ts", time.Hour)
+	require.Nil(err)
+	u, err := url.Parse(uri)
+	require.Nil(err)
+	assert.Equal("/bucket/path/name/1.ts", u.Path)
+	q := u.Query()
+	assert.Equal("AWS4-HMAC-SHA256", q.Get("X-Amz-Algorithm"))
+	assert.Equal("3600", q.Get("X-Amz-Expires"))
+	assert.Contains(q.Get("X-Amz-Credential"), "key/")
+	assert.NotEmpty(q.Get("X-Amz-Signature"))
+	resp, err := http.Get(uri)
+	require.Nil(err)
+	data, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal([]byte("data"), data)
+
+	// bucket of other node can't be presigned
+	_, err = NewSession(sess.(OSSession).GetInfo()).(Presigner).PresignedGetURL("name/1.ts", time.Hour)
+	assert.Equal(ErrNotSupported, err)
+
+	// google cloud storage
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(err)
+	signer := &gsSigner{jsKey: &gsKeyJSON{ClientEmail: "test@example.com"}, parsedKey: key}
+	gs := &gsOS{s3OS: s3OS{bucket: "bucket"}, gsSigner: signer}
+	expireAt := time.Now().Add(time.Hour)
+	uri, err = gs.NewSession("path").(Presigner).PresignedGetURL("name/1 a.ts", time.Hour)
+	require.Nil(err)
+	u, err = url.Parse(uri)
+	require.Nil(err)
+	assert.Equal("bucket.storage.googleapis.com", u.Host)
+	assert.Equal("/path/name/1%20a.ts", u.EscapedPath())
+	q = u.Query()
+	assert.Equal("test@example.com", q.Get("GoogleAccessId"))
+	expires, err := strconv.ParseInt(q.Get("Expires"), 10, 64)
+	require.Nil(err)
+	assert.InDelta(expireAt.Unix(), expires, 1)
+	sig, err := base64.StdEncoding.DecodeString(q.Get("Signature"))
+	require.Nil(err)
+	digest := sha256.Sum256([]byte("GET\n\n\n" + q.Get("Expires") + "\n/bucket/path/name/1%20a.ts"))
+	assert.Nil(rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], sig))
+}
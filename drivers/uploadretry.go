@@ -0,0 +1,104 @@
+package drivers
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff"
+	"github.com/golang/glog"
+	"github.com/livepeer/go-livepeer/monitor"
+)
+
+// UploadRetries retries failed uploads in background if set, so that
+// segments don't wait for the storage to recover. Disabled if nil.
+var UploadRetries *UploadRetryQueue
+
+// UploadRetryQueue retries saving data to the storage in background with
+// exponential backoff. Number of uploads retried at once is bounded, as the
+// queue holds their data in memory.
+type UploadRetryQueue struct {
+	size        int
+	maxAttempts int
+	delay       time.Duration
+
+	mu      sync.Mutex
+	pending int
+}
+
+// NewUploadRetryQueue returns queue retrying up to size uploads at once, each
+// up to maxAttempts times. First retry is delayed by delay, which is doubled
+// for every next one.
+func NewUploadRetryQueue(size, maxAttempts int, delay time.Duration) *UploadRetryQueue {
+	return &UploadRetryQueue{
+		size:        size,
+		maxAttempts: maxAttempts,
+		delay:       delay,
+	}
+}
+
+// Add queues saving the data to the session for retry, returns false if the
+// queue is full. done is called with the result of the last attempt.
+func (q *UploadRetryQueue) Add(sess OSSession, name string, data []byte, props *FileProperties, done func(uri string, err error)) bool {
+	q.mu.Lock()
+	if q.pending >= q.size {
+		q.mu.Unlock()
+		glog.Errorf("Upload retry queue is full, dropping name=%s", name)
+		if monitor.Enabled {
+			monitor.UploadRetried(monitor.UploadRetryOutcomeDropped)
+		}
+		return false
+	}
+	q.pending++
+	q.recordDepth()
+	q.mu.Unlock()
+
+	go q.retry(sess, name, data, props, done)
+	return true
+}
+
+// Pending returns the number of uploads being retried
+func (q *UploadRetryQueue) Pending() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.pending
+}
+
+func (q *UploadRetryQueue) retry(sess OSSession, name string, data []byte, props *FileProperties, done func(uri string, err error)) {
+	b := backoff.NewExponentialBackOff()
+	b.InitialInterval = q.delay
+	b.MaxElapsedTime = 0
+	b.Reset()
+	var uri string
+	var err error
+	for attempt := 1; attempt <= q.maxAttempts; attempt++ {
+		time.Sleep(b.NextBackOff())
+		uri, err = sess.SaveData(name, data, props)
+		if err == nil {
+			break
+		}
+		glog.Errorf("Upload retry failed name=%s attempt=%d err=%v", name, attempt, err)
+	}
+
+	q.mu.Lock()
+	q.pending--
+	q.recordDepth()
+	q.mu.Unlock()
+
+	if monitor.Enabled {
+		outcome := monitor.UploadRetryOutcomeSuccess
+		if err != nil {
+			outcome = monitor.UploadRetryOutcomeFailure
+		}
+		monitor.UploadRetried(outcome)
+	}
+	if done != nil {
+		done(uri, err)
+	}
+}
+
+// recordDepth should be called with the lock held
+func (q *UploadRetryQueue) recordDepth() {
+	if monitor.Enabled {
+		monitor.UploadRetryQueueDepth(q.pending)
+	}
+}
@@ -0,0 +1,76 @@
+package drivers
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type failingSession struct {
+	OSSession
+	mu       sync.Mutex
+	failures int
+	attempts int
+}
+
+func (s *failingSession) SaveData(name string, data []byte, props *FileProperties) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attempts++
+	if s.attempts <= s.failures {
+		return "", errors.New("save failed")
+	}
+	return "saved/" + name, nil
+}
+
+func (s *failingSession) SaveDataReader(name string, r io.Reader, size int64) (string, error) {
+	return s.SaveData(name, nil, nil)
+}
+
+func TestUploadRetryQueue(t *testing.T) {
+	assert := assert.New(t)
+
+	type result struct {
+		uri string
+		err error
+	}
+	results := make(chan result, 1)
+	done := func(uri string, err error) { results <- result{uri, err} }
+
+	// failing upload eventually succeeds
+	q := NewUploadRetryQueue(1, 5, time.Millisecond)
+	sess := &failingSession{failures: 3}
+	assert.True(q.Add(sess, "seg.ts", []byte("data"), nil, done))
+	assert.Equal(1, q.Pending())
+
+	// queue full
+	assert.False(q.Add(sess, "other.ts", []byte("data"), nil, done))
+
+	select {
+	case r := <-results:
+		assert.Nil(r.err)
+		assert.Equal("saved/seg.ts", r.uri)
+	case <-time.After(5 * time.Second):
+		t.Fatal("upload not retried")
+	}
+	assert.Equal(4, sess.attempts)
+	assert.Eventually(func() bool { return q.Pending() == 0 }, time.Second, time.Millisecond)
+
+	// gives up after max attempts
+	q = NewUploadRetryQueue(1, 2, time.Millisecond)
+	sess = &failingSession{failures: 10}
+	assert.True(q.Add(sess, "seg.ts", []byte("data"), nil, done))
+	select {
+	case r := <-results:
+		assert.EqualError(r.err, "save failed")
+		assert.Equal("", r.uri)
+	case <-time.After(5 * time.Second):
+		t.Fatal("upload not retried")
+	}
+	assert.Equal(2, sess.attempts)
+	assert.Eventually(func() bool { return q.Pending() == 0 }, time.Second, time.Millisecond)
+}
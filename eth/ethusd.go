@@ -0,0 +1,114 @@
+package eth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/livepeer/go-livepeer/monitor"
+)
+
+// ETHUSDPriceSource defines methods for fetching the current ETH price in USD
+type ETHUSDPriceSource interface {
+	ETHUSDPrice(ctx context.Context) (float64, error)
+}
+
+type httpETHUSDPriceSource struct {
+	url    string
+	field  []string
+	client *http.Client
+}
+
+// NewHTTPETHUSDPriceSource returns a ETHUSDPriceSource that fetches the price
+// from a JSON endpoint. field is the dot separated path to the price in the
+// response, e.g. "ethereum.usd" for the CoinGecko simple price API
+func NewHTTPETHUSDPriceSource(url, field string) ETHUSDPriceSource {
+	var path []string
+	if field != "" {
+		path = strings.Split(field, ".")
+	}
+	return &httpETHUSDPriceSource{
+		url:    url,
+		field:  path,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (s *httpETHUSDPriceSource) ETHUSDPrice(ctx context.Context) (float64, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", s.url, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("status=%d body=%s", resp.StatusCode, string(body))
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return 0, err
+	}
+	for _, key := range s.field {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return 0, fmt.Errorf("no field=%s in response", key)
+		}
+		if v, ok = obj[key]; !ok {
+			return 0, fmt.Errorf("no field=%s in response", key)
+		}
+	}
+	price, ok := v.(float64)
+	if !ok || price <= 0 {
+		return 0, fmt.Errorf("invalid ETH/USD price=%v", v)
+	}
+	return price, nil
+}
+
+// RedemptionCostUSD returns the cost of a ticket redemption transaction in USD
+func RedemptionCostUSD(gasPrice *big.Int, redeemGas int, ethUSD float64) float64 {
+	txCost := new(big.Int).Mul(gasPrice, big.NewInt(int64(redeemGas)))
+	ethCost := new(big.Rat).SetFrac(txCost, big.NewInt(1000000000000000000))
+	ethFloat, _ := ethCost.Float64()
+	return ethFloat * ethUSD
+}
+
+// MonitorRedemptionCostUSD periodically records the cost of a ticket redemption
+// transaction in USD using the current gas price until the context is done
+func MonitorRedemptionCostUSD(ctx context.Context, gpm *GasPriceMonitor, src ETHUSDPriceSource, redeemGas int, interval time.Duration) {
+	record := func() {
+		ethUSD, err := src.ETHUSDPrice(ctx)
+		if err != nil {
+			glog.Errorf("Error getting ETH/USD price: %v", err)
+			return
+		}
+		if monitor.Enabled {
+			monitor.SetGasPriceUSD(RedemptionCostUSD(gpm.GasPrice(), redeemGas, ethUSD))
+		}
+	}
+
+	record()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			record()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package eth
+
+import (
+	"context"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPETHUSDPriceSource(t *testing.T) {
+	assert := assert.New(t)
+
+	var body string
+	status := http.StatusOK
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	defer ts.Close()
+
+	src := NewHTTPETHUSDPriceSource(ts.URL, "ethereum.usd")
+
+	body = `{"ethereum":{"usd":1234.5}}`
+	price, err := src.ETHUSDPrice(context.Background())
+	assert.Nil(err)
+	assert.Equal(1234.5, price)
+
+	body = `{"ethereum":{"eur":1000}}`
+	_, err = src.ETHUSDPrice(context.Background())
+	assert.EqualError(err, "no field=usd in response")
+
+	body = `{"ethereum":"foo"}`
+	_, err = src.ETHUSDPrice(context.Background())
+	assert.EqualError(err, "no field=usd in response")
+
+	body = `{"ethereum":{"usd":"1234"}}`
+	_, err = src.ETHUSDPrice(context.Background())
+	assert.EqualError(err, "invalid ETH/USD price=1234")
+
+	body = `not json`
+	_, err = src.ETHUSDPrice(context.Background())
+	assert.NotNil(err)
+
+	status = http.StatusInternalServerError
+	body = "oops"
+	_, err = src.ETHUSDPrice(context.Background())
+	assert.EqualError(err, "status=500 body=oops")
+
+	// plain number response
+	status = http.StatusOK
+	body = "250"
+	price, err = NewHTTPETHUSDPriceSource(ts.URL, "").ETHUSDPrice(context.Background())
+	assert.Nil(err)
+	assert.Equal(250.0, price)
+}
+
+func TestRedemptionCostUSD(t *testing.T) {
+	assert := assert.New(t)
+
+	// 100 gwei * 250000 gas = 0.025 ETH
+	gasPrice := big.NewInt(100000000000)
+	assert.InDelta(50.0, RedemptionCostUSD(gasPrice, 250000, 2000), 1e-9)
+	assert.Equal(0.0, RedemptionCostUSD(big.NewInt(0), 250000, 2000))
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"math/big"
 	"sync"
+	"time"
 
 	ethcommon "github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -16,6 +17,12 @@ import (
 	"github.com/livepeer/go-livepeer/pm"
 )
 
+// Bounds of the delay before a failed top-up of the node's deposit is tried again
+const (
+	minTopUpBackoff = 1 * time.Minute
+	maxTopUpBackoff = 1 * time.Hour
+)
+
 // SenderWatcher maintains a concurrency-safe map with SenderInfo
 type SenderWatcher struct {
 	senders        map[ethcommon.Address]*pm.SenderInfo
@@ -27,6 +34,13 @@ type SenderWatcher struct {
 	lpEth          eth.LivepeerEthClient
 	dec            *EventDecoder
 
+	// automatic top-up of the node's deposit, disabled if topUpAmount is nil
+	topUpThreshold *big.Int
+	topUpAmount    *big.Int
+	topUpPending   bool
+	topUpBackoff   time.Duration
+	topUpRetryAt   time.Time
+
 	// subscriptions
 	reserveChangeFeed  event.Feed
 	reserveChangeScope event.SubscriptionScope
@@ -72,9 +86,73 @@ func (sw *SenderWatcher) setSenderInfo(addr ethcommon.Address, info *pm.SenderIn
 	defer sw.mu.Unlock()
 	sw.senders[addr] = info
 
-	if addr == sw.lpEth.Account().Address && monitor.Enabled {
-		monitor.Deposit(addr.Hex(), info.Deposit)
-		monitor.Reserve(addr.Hex(), info.Reserve.FundsRemaining)
+	if addr == sw.lpEth.Account().Address {
+		if monitor.Enabled {
+			monitor.Deposit(addr.Hex(), info.Deposit)
+			monitor.Reserve(addr.Hex(), info.Reserve.FundsRemaining)
+		}
+		sw.checkDeposit(info.Deposit)
+	}
+}
+
+// EnableDepositTopUp makes the watcher fund the node's deposit with amount
+// whenever it falls below threshold
+func (sw *SenderWatcher) EnableDepositTopUp(threshold, amount *big.Int) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	sw.topUpThreshold = threshold
+	sw.topUpAmount = amount
+}
+
+// checkDeposit starts the top-up of the node's deposit if it is enabled and
+// the deposit is below the threshold. A top-up stays pending until the watcher
+// sees the deposit back above the threshold, and a failed one is only tried
+// again after a backoff. Caller must hold sw.mu.
+func (sw *SenderWatcher) checkDeposit(deposit *big.Int) {
+	if sw.topUpAmount == nil || deposit == nil {
+		return
+	}
+	if deposit.Cmp(sw.topUpThreshold) >= 0 {
+		sw.topUpPending = false
+		return
+	}
+	if sw.topUpPending || time.Now().Before(sw.topUpRetryAt) {
+		return
+	}
+	sw.topUpPending = true
+	go sw.topUpDeposit(deposit, sw.topUpAmount)
+}
+
+// topUpDeposit funds the node's deposit with amount and waits for the
+// transaction to be mined
+func (sw *SenderWatcher) topUpDeposit(deposit, amount *big.Int) {
+	glog.Infof("Topping up deposit=%v with amount=%v", eth.FormatUnits(deposit, "ETH"), eth.FormatUnits(amount, "ETH"))
+	tx, err := sw.lpEth.FundDeposit(amount)
+	if err == nil {
+		err = sw.lpEth.CheckTx(tx)
+	}
+
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	if err != nil {
+		sw.topUpPending = false
+		sw.topUpBackoff *= 2
+		if sw.topUpBackoff < minTopUpBackoff {
+			sw.topUpBackoff = minTopUpBackoff
+		}
+		if sw.topUpBackoff > maxTopUpBackoff {
+			sw.topUpBackoff = maxTopUpBackoff
+		}
+		sw.topUpRetryAt = time.Now().Add(sw.topUpBackoff)
+		glog.Errorf("Could not top up deposit, retrying in %v: %v", sw.topUpBackoff, err)
+		if monitor.Enabled {
+			monitor.AutoTopUpError()
+		}
+		return
+	}
+	sw.topUpBackoff = 0
+	if monitor.Enabled {
+		monitor.AutoTopUp()
 	}
 }
 
@@ -267,9 +345,12 @@ func (sw *SenderWatcher) handleLog(log types.Log) error {
 		}
 	}
 
-	if sender == sw.lpEth.Account().Address && monitor.Enabled {
-		monitor.Deposit(sender.Hex(), sw.senders[sender].Deposit)
-		monitor.Reserve(sender.Hex(), sw.senders[sender].Reserve.FundsRemaining)
+	if info, ok := sw.senders[sender]; ok && sender == sw.lpEth.Account().Address {
+		if monitor.Enabled {
+			monitor.Deposit(sender.Hex(), info.Deposit)
+			monitor.Reserve(sender.Hex(), info.Reserve.FundsRemaining)
+		}
+		sw.checkDeposit(info.Deposit)
 	}
 
 	return nil
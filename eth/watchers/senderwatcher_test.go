@@ -3,11 +3,13 @@ package watchers
 import (
 	"errors"
 	"math/big"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/livepeer/go-livepeer/eth"
 	"github.com/livepeer/go-livepeer/eth/blockwatch"
 	"github.com/livepeer/go-livepeer/pm"
@@ -490,6 +492,129 @@ func TestWinningTicketTransferEvent(t *testing.T) {
 
 }
 
+type topUpClient struct {
+	*eth.StubClient
+	mu      sync.Mutex
+	amounts []*big.Int
+	txErr   error
+}
+
+func (c *topUpClient) CheckTx(tx *types.Transaction) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.txErr
+}
+
+func (c *topUpClient) setTxErr(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.txErr = err
+}
+
+func (c *topUpClient) FundDeposit(amount *big.Int) (*types.Transaction, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.amounts = append(c.amounts, amount)
+	return nil, nil
+}
+
+func (c *topUpClient) topUps() []*big.Int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*big.Int(nil), c.amounts...)
+}
+
+func TestSenderWatcher_DepositTopUp(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	lpEth := &topUpClient{StubClient: &eth.StubClient{TranscoderAddress: stubSender}}
+	watcher := &stubBlockWatcher{}
+	sw, err := NewSenderWatcher(stubTicketBrokerAddr, watcher, lpEth, &stubTimeWatcher{})
+	require.Nil(err)
+	senderInfo := func(deposit int64) *pm.SenderInfo {
+		return &pm.SenderInfo{
+			Deposit: big.NewInt(deposit),
+			Reserve: &pm.ReserveInfo{
+				FundsRemaining:        big.NewInt(0),
+				ClaimedInCurrentRound: big.NewInt(0),
+			},
+		}
+	}
+	pending := func() bool {
+		sw.mu.RLock()
+		defer sw.mu.RUnlock()
+		return sw.topUpPending
+	}
+
+	// disabled by default
+	sw.setSenderInfo(stubSender, senderInfo(10))
+	time.Sleep(20 * time.Millisecond)
+	assert.Empty(lpEth.topUps())
+
+	threshold, amount := big.NewInt(200000000100), big.NewInt(1000000000000)
+	sw.EnableDepositTopUp(threshold, amount)
+
+	// deposit of other senders and deposit above the threshold aren't topped up
+	sw.setSenderInfo(pm.RandAddress(), senderInfo(10))
+	sw.setSenderInfo(stubSender, senderInfo(200000000150))
+	time.Sleep(20 * time.Millisecond)
+	assert.Empty(lpEth.topUps())
+
+	// topped up once winning ticket brings it below the threshold
+	header := defaultMiniHeader()
+	header.Logs = append(header.Logs, newStubWinningTicketLog())
+	go sw.Watch()
+	defer sw.Stop()
+	time.Sleep(20 * time.Millisecond)
+	watcher.sink <- []*blockwatch.Event{{Type: blockwatch.Added, BlockHeader: header}}
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal([]*big.Int{amount}, lpEth.topUps())
+
+	// top-up stays pending until the deposit is seen above the threshold
+	assert.True(pending())
+	sw.setSenderInfo(stubSender, senderInfo(10))
+	time.Sleep(20 * time.Millisecond)
+	assert.Len(lpEth.topUps(), 1)
+	sw.setSenderInfo(stubSender, senderInfo(1200000000000))
+	assert.False(pending())
+
+	// failed top-up is tried again after a backoff
+	lpEth.setTxErr(errors.New("tx failed"))
+	sw.setSenderInfo(stubSender, senderInfo(10))
+	time.Sleep(20 * time.Millisecond)
+	assert.Len(lpEth.topUps(), 2)
+	assert.False(pending())
+	sw.mu.RLock()
+	assert.Equal(minTopUpBackoff, sw.topUpBackoff)
+	sw.mu.RUnlock()
+	sw.setSenderInfo(stubSender, senderInfo(10))
+	time.Sleep(20 * time.Millisecond)
+	assert.Len(lpEth.topUps(), 2)
+
+	sw.mu.Lock()
+	sw.topUpRetryAt = time.Now()
+	sw.mu.Unlock()
+	sw.setSenderInfo(stubSender, senderInfo(10))
+	time.Sleep(20 * time.Millisecond)
+	assert.Len(lpEth.topUps(), 3)
+	sw.mu.RLock()
+	assert.Equal(2*minTopUpBackoff, sw.topUpBackoff)
+	sw.mu.RUnlock()
+
+	// backoff is reset once a top-up succeeds
+	lpEth.setTxErr(nil)
+	sw.mu.Lock()
+	sw.topUpRetryAt = time.Now()
+	sw.mu.Unlock()
+	sw.setSenderInfo(stubSender, senderInfo(10))
+	time.Sleep(20 * time.Millisecond)
+	assert.Len(lpEth.topUps(), 4)
+	assert.True(pending())
+	sw.mu.RLock()
+	assert.Zero(sw.topUpBackoff)
+	sw.mu.RUnlock()
+}
+
 func TestUnlockEvent(t *testing.T) {
 	assert := assert.New(t)
 	startWithdrawRound := big.NewInt(5)
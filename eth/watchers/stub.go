@@ -2,6 +2,7 @@ package watchers
 
 import (
 	"math/big"
+	"time"
 
 	ethcommon "github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
@@ -389,6 +390,16 @@ func (s *stubOrchestratorStore) UpdateOrch(orch *common.DBOrch) error {
 }
 
 func (s *stubOrchestratorStore) OrchCount(filter *common.DBOrchFilter) (int, error) { return 0, nil }
+func (s *stubOrchestratorStore) UpdateOrchProbe(ethereumAddr string, success bool) error {
+	return nil
+}
+func (s *stubOrchestratorStore) BlacklistOrch(ethereumAddr string, ttl time.Duration, reason string) error {
+	return nil
+}
+func (s *stubOrchestratorStore) UnblacklistOrch(ethereumAddr string) error { return nil }
+func (s *stubOrchestratorStore) BlacklistedOrchs() ([]*common.DBBlacklistedOrch, error) {
+	return nil, nil
+}
 func (s *stubOrchestratorStore) SelectOrchs(filter *common.DBOrchFilter) ([]*common.DBOrch, error) {
 	if s.selectErr != nil {
 		return []*common.DBOrch{}, s.selectErr
@@ -2,17 +2,22 @@ package monitor
 
 import (
 	"context"
+	"fmt"
+	"math"
 	"math/big"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/golang/glog"
 
 	"contrib.go.opencensus.io/exporter/prometheus"
 	rprom "github.com/prometheus/client_golang/prometheus"
+	"go.opencensus.io/metric/metricdata"
 	"go.opencensus.io/stats"
 	"go.opencensus.io/stats/view"
 	"go.opencensus.io/tag"
@@ -24,24 +29,57 @@ type (
 )
 
 const (
-	SegmentUploadErrorUnknown               SegmentUploadError    = "Unknown"
-	SegmentUploadErrorGenCreds              SegmentUploadError    = "GenCreds"
-	SegmentUploadErrorOS                    SegmentUploadError    = "ObjectStorage"
-	SegmentUploadErrorSessionEnded          SegmentUploadError    = "SessionEnded"
-	SegmentUploadErrorInsufficientBalance   SegmentUploadError    = "InsufficientBalance"
-	SegmentUploadErrorTimeout               SegmentUploadError    = "Timeout"
-	SegmentTranscodeErrorUnknown            SegmentTranscodeError = "Unknown"
-	SegmentTranscodeErrorUnknownResponse    SegmentTranscodeError = "UnknownResponse"
-	SegmentTranscodeErrorTranscode          SegmentTranscodeError = "Transcode"
-	SegmentTranscodeErrorOrchestratorBusy   SegmentTranscodeError = "OrchestratorBusy"
-	SegmentTranscodeErrorOrchestratorCapped SegmentTranscodeError = "OrchestratorCapped"
-	SegmentTranscodeErrorParseResponse      SegmentTranscodeError = "ParseResponse"
-	SegmentTranscodeErrorReadBody           SegmentTranscodeError = "ReadBody"
-	SegmentTranscodeErrorNoOrchestrators    SegmentTranscodeError = "NoOrchestrators"
-	SegmentTranscodeErrorDownload           SegmentTranscodeError = "Download"
-	SegmentTranscodeErrorSaveData           SegmentTranscodeError = "SaveData"
-	SegmentTranscodeErrorSessionEnded       SegmentTranscodeError = "SessionEnded"
-	SegmentTranscodeErrorPlaylist           SegmentTranscodeError = "Playlist"
+	SegmentUploadErrorUnknown                  SegmentUploadError    = "Unknown"
+	SegmentUploadErrorGenCreds                 SegmentUploadError    = "GenCreds"
+	SegmentUploadErrorOS                       SegmentUploadError    = "ObjectStorage"
+	SegmentUploadErrorSessionEnded             SegmentUploadError    = "SessionEnded"
+	SegmentUploadErrorInsufficientBalance      SegmentUploadError    = "InsufficientBalance"
+	SegmentUploadErrorTimeout                  SegmentUploadError    = "Timeout"
+	SegmentTranscodeErrorUnknown               SegmentTranscodeError = "Unknown"
+	SegmentTranscodeErrorUnknownResponse       SegmentTranscodeError = "UnknownResponse"
+	SegmentTranscodeErrorTranscode             SegmentTranscodeError = "Transcode"
+	SegmentTranscodeErrorOrchestratorBusy      SegmentTranscodeError = "OrchestratorBusy"
+	SegmentTranscodeErrorOrchestratorCapped    SegmentTranscodeError = "OrchestratorCapped"
+	SegmentTranscodeErrorOrchestratorUnhealthy SegmentTranscodeError = "OrchestratorUnhealthy"
+	SegmentTranscodeErrorParseResponse         SegmentTranscodeError = "ParseResponse"
+	SegmentTranscodeErrorReadBody              SegmentTranscodeError = "ReadBody"
+	SegmentTranscodeErrorNoOrchestrators       SegmentTranscodeError = "NoOrchestrators"
+	SegmentTranscodeErrorDownload              SegmentTranscodeError = "Download"
+	SegmentTranscodeErrorSaveData              SegmentTranscodeError = "SaveData"
+	SegmentTranscodeErrorSessionEnded          SegmentTranscodeError = "SessionEnded"
+	SegmentTranscodeErrorPlaylist              SegmentTranscodeError = "Playlist"
+	SegmentTranscodeErrorTimeout               SegmentTranscodeError = "Timeout"
+
+	SegmentBytesUpload   = "upload"
+	SegmentBytesDownload = "download"
+
+	DiscoveryErrorOrchestratorCapped    = "OrchestratorCapped"
+	DiscoveryErrorOrchestratorBusy      = "OrchestratorBusy"
+	DiscoveryErrorOrchestratorUnhealthy = "OrchestratorUnhealthy"
+	DiscoveryErrorCanceled              = "Canceled"
+
+	DiscoveryOutcomeSuccess = "success"
+	DiscoveryOutcomeTimeout = "timeout"
+	DiscoveryOutcomeEmpty   = "empty"
+
+	WarmupOutcomeSuccess = "success"
+	WarmupOutcomeFailure = "failure"
+
+	UploadRetryOutcomeSuccess = "success"
+	UploadRetryOutcomeFailure = "failure"
+	UploadRetryOutcomeDropped = "dropped"
+
+	StorageCacheHit  = "hit"
+	StorageCacheMiss = "miss"
+
+	TenantLimitConcurrent = "concurrent"
+	TenantLimitRate       = "rate"
+
+	PaymentValidationRecipient     = "recipient"
+	PaymentValidationSender        = "sender"
+	PaymentValidationSignature     = "signature"
+	PaymentValidationRecipientRand = "recipient_rand"
+	PaymentValidationPrice         = "price"
 
 	numberOfSegmentsToCalcAverage = 30
 	gweiConversionFactor          = 1000000000
@@ -53,8 +91,79 @@ const (
 // Enabled true if metrics was enabled in command line
 var Enabled bool
 
-var timeToWaitForError = 8500 * time.Millisecond
-var timeoutWatcherPause = 15 * time.Second
+// MaxSourceResolutions is the max number of distinct source resolutions
+// tagged separately, the rest are recorded as SourceResolutionOther
+var MaxSourceResolutions = 50
+
+// SourceResolutionOther tags the source resolutions over MaxSourceResolutions
+const SourceResolutionOther = "other"
+
+// StreamCostRetention is how long the cost of the ended stream is kept around
+var StreamCostRetention = 10 * time.Minute
+
+// OrchestratorSelectionWindow is the rolling window over which the segments
+// transcoded by each orchestrator are counted for orchestrator_selection_gini
+var OrchestratorSelectionWindow = 10 * time.Minute
+
+// orchSelectionBuckets is the number of buckets the selection window is split
+// into, the oldest bucket is dropped once it falls out of the window
+const orchSelectionBuckets = 10
+
+// transcodeTimeWeight is the weight of the latest segment in the moving
+// average returned by AverageTranscodeTime
+const transcodeTimeWeight = 0.1
+
+// both durations are stored in nanoseconds and accessed atomically, so they
+// can be changed while the timeout watcher is running
+var timeToWaitForError = int64(8500 * time.Millisecond)
+var timeoutWatcherPause = int64(15 * time.Second)
+
+// SetSegmentTimeout sets how long to wait for a segment to be transcoded
+// before it is considered lost. Non-positive values are ignored
+func SetSegmentTimeout(d time.Duration) {
+	if d <= 0 {
+		glog.Errorf("Ignoring invalid segment timeout=%s", d)
+		return
+	}
+	atomic.StoreInt64(&timeToWaitForError, int64(d))
+}
+
+// SetTimeoutWatcherInterval sets how often lost segments are swept up.
+// Non-positive values are ignored
+func SetTimeoutWatcherInterval(d time.Duration) {
+	if d <= 0 {
+		glog.Errorf("Ignoring invalid timeout watcher interval=%s", d)
+		return
+	}
+	atomic.StoreInt64(&timeoutWatcherPause, int64(d))
+}
+
+func segmentTimeout() time.Duration {
+	return time.Duration(atomic.LoadInt64(&timeToWaitForError))
+}
+
+func timeoutWatcherInterval() time.Duration {
+	return time.Duration(atomic.LoadInt64(&timeoutWatcherPause))
+}
+
+// censusLock is a mutex that records the time spent waiting on it when
+// built with the lockprofile tag, e.g. `go build -tags lockprofile`.
+// Without the tag there is no overhead.
+type censusLock struct {
+	sync.Mutex
+}
+
+func (l *censusLock) Lock() {
+	if !lockProfiling {
+		l.Mutex.Lock()
+		return
+	}
+	start := time.Now()
+	l.Mutex.Lock()
+	if m := census.mCensusLockWait; m != nil {
+		stats.Record(census.ctx, m.M(time.Since(start).Seconds()))
+	}
+}
 
 type (
 	censusMetricsCounter struct {
@@ -71,12 +180,23 @@ type (
 		kSender                       tag.Key
 		kRecipient                    tag.Key
 		kManifestID                   tag.Key
+		kDirection                    tag.Key
+		kOrchestrator                 tag.Key
+		kOutcome                      tag.Key
+		kReason                       tag.Key
+		kResolution                   tag.Key
+		kTenant                       tag.Key
+		kStage                        tag.Key
 		mSegmentSourceAppeared        *stats.Int64Measure
 		mSegmentEmerged               *stats.Int64Measure
 		mSegmentEmergedUnprocessed    *stats.Int64Measure
 		mSegmentUploaded              *stats.Int64Measure
 		mSegmentUploadFailed          *stats.Int64Measure
+		mSegmentUploadOutOfOrder      *stats.Int64Measure
 		mSegmentTranscoded            *stats.Int64Measure
+		mSegmentTranscodedGPU         *stats.Int64Measure
+		mSegmentTranscodedCPU         *stats.Int64Measure
+		mGPUTranscodeRatio            *stats.Float64Measure
 		mSegmentTranscodedUnprocessed *stats.Int64Measure
 		mSegmentTranscodeFailed       *stats.Int64Measure
 		mSegmentTranscodedAppeared    *stats.Int64Measure
@@ -86,40 +206,140 @@ type (
 		mStreamCreated                *stats.Int64Measure
 		mStreamStarted                *stats.Int64Measure
 		mStreamEnded                  *stats.Int64Measure
+		mStreamKeyRotated             *stats.Int64Measure
+		mStreamWarmup                 *stats.Float64Measure
 		mMaxSessions                  *stats.Int64Measure
 		mCurrentSessions              *stats.Int64Measure
+		mConcurrentStreams            *stats.Int64Measure
+		mDrainMode                    *stats.Int64Measure
+		mNodeUnhealthy                *stats.Int64Measure
+		mStreamMigrations             *stats.Int64Measure
+		mQueuedSegments               *stats.Int64Measure
+		mActiveSegmenters             *stats.Int64Measure
+		mSegmenterMemory              *stats.Int64Measure
+		mUploadQueueDepth             *stats.Int64Measure
+		mS3UploadsInFlight            *stats.Int64Measure
+		mS3UploadsWaiting             *stats.Int64Measure
+		mUploadRetryQueueDepth        *stats.Int64Measure
+		mUploadRetries                *stats.Int64Measure
+		mSourceResolutions            *stats.Int64Measure
+		mStreamsRejectedTenantLimit   *stats.Int64Measure
+		mTranscodeFramesDropped       *stats.Int64Measure
+		mStorageCacheRequests         *stats.Int64Measure
+		mStorageCacheEvictions        *stats.Int64Measure
+		mSegmentFetchesInFlight       *stats.Int64Measure
+		mSegmentFetchesQueued         *stats.Int64Measure
+		mSegmentServeRatio            *stats.Float64Measure
+		mPlaylistRequests             *stats.Int64Measure
+		mStreamHealthScore            *stats.Float64Measure
+		mDistinctOrchestrators        *stats.Int64Measure
 		mDiscoveryError               *stats.Int64Measure
+		mOrchRejectedPrice            *stats.Int64Measure
+		mOrchRejectedTicketParams     *stats.Int64Measure
+		mDiscoveryDuration            *stats.Float64Measure
+		mWarmupDuration               *stats.Float64Measure
+		mDiscoveryCacheHits           *stats.Int64Measure
+		mDiscoveryCacheMisses         *stats.Int64Measure
+		mDiscoveryCacheHitRate        *stats.Float64Measure
+		mOrchSelectionGini            *stats.Float64Measure
+		mOrchsInCooldown              *stats.Int64Measure
+		mHealthyOrchs                 *stats.Int64Measure
+		mCooldownDuration             *stats.Float64Measure
+		mOrchInfoAge                  *stats.Float64Measure
+		mOrchInvalidURI               *stats.Int64Measure
+		mOrchSchemeDowngrade          *stats.Int64Measure
+		mGRPCStreamError              *stats.Int64Measure
+		mGRPCRequestError             *stats.Int64Measure
+		mVerificationChecks           *stats.Int64Measure
+		mVerificationPassed           *stats.Int64Measure
+		mVerificationFailed           *stats.Int64Measure
 		mTranscodeRetried             *stats.Int64Measure
+		mSegmentFailedMaxOrchs        *stats.Int64Measure
+		mSegmentFailover              *stats.Int64Measure
+		mFallbackChainPosition        *stats.Int64Measure
+		mSegmentRetryCount            *stats.Int64Measure
 		mTranscodersNumber            *stats.Int64Measure
 		mTranscodersCapacity          *stats.Int64Measure
 		mTranscodersLoad              *stats.Int64Measure
 		mSuccessRate                  *stats.Float64Measure
 		mTranscodeTime                *stats.Float64Measure
+		mTranscodeTTFB                *stats.Float64Measure
 		mTranscodeLatency             *stats.Float64Measure
 		mTranscodeOverallLatency      *stats.Float64Measure
 		mUploadTime                   *stats.Float64Measure
+		mSegmentBytes                 *stats.Int64Measure
 		mAuthWebhookTime              *stats.Float64Measure
+		mSegmentsReaped               *stats.Int64Measure
+		mSegmentsReapedBytes          *stats.Int64Measure
+		mProfileBitrateCapped         *stats.Int64Measure
+		mCompressionRatio             *stats.Float64Measure
+		mRenditionSizeVariance        *stats.Float64Measure
 
 		// Metrics for sending payments
 		mTicketValueSent    *stats.Float64Measure
+		mStreamCost         *stats.Float64Measure
 		mTicketsSent        *stats.Int64Measure
 		mPaymentCreateError *stats.Int64Measure
 		mDeposit            *stats.Float64Measure
 		mReserve            *stats.Float64Measure
+		mAutoTopUp          *stats.Int64Measure
+		mAutoTopUpError     *stats.Int64Measure
 
 		// Metrics for receiving payments
 		mTicketValueRecv       *stats.Float64Measure
 		mTicketsRecv           *stats.Int64Measure
 		mPaymentRecvErr        *stats.Int64Measure
+		mPaymentValidationErr  *stats.Int64Measure
+		mRecipientRandReuse    *stats.Int64Measure
 		mWinningTicketsRecv    *stats.Int64Measure
 		mValueRedeemed         *stats.Float64Measure
 		mTicketRedemptionError *stats.Int64Measure
+		mTicketRedemptionDefer *stats.Int64Measure
 		mSuggestedGasPrice     *stats.Float64Measure
+		mGasPriceUSD           *stats.Float64Measure
+		mCensusLockWait        *stats.Float64Measure
 		mTranscodingPrice      *stats.Float64Measure
 
-		lock        sync.Mutex
-		emergeTimes map[uint64]map[uint64]time.Time // nonce:seqNo
-		success     map[uint64]*segmentsAverager
+		lock              censusLock
+		streamGauges      map[stats.Measure]map[string]stats.Measurement // measure:manifestID:last recorded value
+		views             []*view.View
+		emergeTimes       map[uint64]map[uint64]time.Time // nonce:seqNo
+		success           map[uint64]*segmentsAverager
+		queuedSegments    map[uint64]int // nonce:number of segments
+		serveCounts       map[uint64]*serveCount
+		streamOrchs       map[uint64]map[string]bool // nonce:set of orchestrators
+		streamCosts       map[string]*streamCost     // manifestID
+		sourceResolutions map[string]bool            // distinct source resolutions tagged separately
+		orchSelections    []*orchSelectionBucket     // oldest first
+		orchCooldowns     map[string]int             // orchestrator:number of streams it is cooling down for
+		discoveryLookups  int64                      // since the counters were reset, for the hit rate
+		discoveryHits     int64
+
+		// last recorded values, exposed through in-process accessors
+		lastSuccessRate     float64
+		lastCurrentSessions int
+		transcodeTimeSum    float64 // seconds
+		transcodeTimeCount  int64
+		transcodeTimeAvg    float64 // seconds, moving average
+		gpuSegments         int64
+		cpuSegments         int64
+	}
+
+	serveCount struct {
+		manifestID string
+		served     int
+		transcoded int
+	}
+
+	streamCost struct {
+		gwei    float64
+		endedAt time.Time // zero while the stream is active
+	}
+
+	// segments transcoded by each orchestrator from the start till the next bucket
+	orchSelectionBucket struct {
+		start  time.Time
+		counts map[string]int
 	}
 
 	segmentCount struct {
@@ -128,6 +348,9 @@ type (
 		emerged     int
 		transcoded  int
 		failed      bool
+		duration    float64       // source segment duration in seconds
+		latency     time.Duration // from segment emerged till fully transcoded
+		tries       int
 	}
 
 	tryData struct {
@@ -153,12 +376,23 @@ var census censusMetricsCounter
 // used in unit tests
 var unitTestMode bool
 
-func InitCensus(nodeType, nodeID, version string) {
+// InitCensus initializes metrics. labels are static tags, such as region or
+// datacenter, that are added to every view
+func InitCensus(nodeType, nodeID, version string, labels map[string]string) {
 	census = censusMetricsCounter{
-		emergeTimes: make(map[uint64]map[uint64]time.Time),
-		nodeID:      nodeID,
-		nodeType:    nodeType,
-		success:     make(map[uint64]*segmentsAverager),
+		emergeTimes:     make(map[uint64]map[uint64]time.Time),
+		nodeID:          nodeID,
+		nodeType:        nodeType,
+		success:         make(map[uint64]*segmentsAverager),
+		queuedSegments:  make(map[uint64]int),
+		serveCounts:     make(map[uint64]*serveCount),
+		streamOrchs:     make(map[uint64]map[string]bool),
+		streamCosts:     make(map[string]*streamCost),
+		streamGauges:    make(map[stats.Measure]map[string]stats.Measurement),
+		lastSuccessRate: 1,
+
+		sourceResolutions: make(map[string]bool),
+		orchCooldowns:     make(map[string]int),
 	}
 	var err error
 	ctx := context.Background()
@@ -171,7 +405,19 @@ func InitCensus(nodeType, nodeID, version string) {
 	census.kTry = tag.MustNewKey("try")
 	census.kSender = tag.MustNewKey("sender")
 	census.kRecipient = tag.MustNewKey("recipient")
+	census.kStage = tag.MustNewKey("stage")
 	census.kManifestID = tag.MustNewKey("manifestID")
+	census.kDirection = tag.MustNewKey("direction")
+	census.kOrchestrator = tag.MustNewKey("orchestrator")
+	census.kOutcome = tag.MustNewKey("outcome")
+	census.kReason = tag.MustNewKey("reason")
+	census.kResolution = tag.MustNewKey("resolution")
+	census.kTenant = tag.MustNewKey("tenant")
+	staticKeys, staticMutators := staticLabels(labels)
+	ctx, err = tag.New(ctx, staticMutators...)
+	if err != nil {
+		glog.Fatal("Error creating context", err)
+	}
 	census.ctx, err = tag.New(ctx, tag.Insert(census.kNodeType, nodeType), tag.Insert(census.kNodeID, nodeID))
 	if err != nil {
 		glog.Fatal("Error creating context", err)
@@ -181,7 +427,11 @@ func InitCensus(nodeType, nodeID, version string) {
 	census.mSegmentEmergedUnprocessed = stats.Int64("segment_source_emerged_unprocessed_total", "SegmentEmerged, counted by number of transcode profiles", "tot")
 	census.mSegmentUploaded = stats.Int64("segment_source_uploaded_total", "SegmentUploaded", "tot")
 	census.mSegmentUploadFailed = stats.Int64("segment_source_upload_failed_total", "SegmentUploadedFailed", "tot")
+	census.mSegmentUploadOutOfOrder = stats.Int64("segment_source_upload_out_of_order_total", "Number of segments uploaded after segment with higher seqNo", "tot")
 	census.mSegmentTranscoded = stats.Int64("segment_transcoded_total", "SegmentTranscoded", "tot")
+	census.mSegmentTranscodedGPU = stats.Int64("segments_transcoded_gpu_total", "Segments transcoded by this node on GPU", "tot")
+	census.mSegmentTranscodedCPU = stats.Int64("segments_transcoded_cpu_total", "Segments transcoded by this node on CPU", "tot")
+	census.mGPUTranscodeRatio = stats.Float64("segments_transcoded_gpu_ratio", "Share of segments transcoded by this node on GPU", "per")
 	census.mSegmentTranscodedUnprocessed = stats.Int64("segment_transcoded_unprocessed_total", "SegmentTranscodedUnprocessed", "tot")
 	census.mSegmentTranscodeFailed = stats.Int64("segment_transcode_failed_total", "SegmentTranscodeFailed", "tot")
 	census.mSegmentTranscodedAppeared = stats.Int64("segment_transcoded_appeared_total", "SegmentTranscodedAppeared", "tot")
@@ -191,37 +441,99 @@ func InitCensus(nodeType, nodeID, version string) {
 	census.mStreamCreated = stats.Int64("stream_created_total", "StreamCreated", "tot")
 	census.mStreamStarted = stats.Int64("stream_started_total", "StreamStarted", "tot")
 	census.mStreamEnded = stats.Int64("stream_ended_total", "StreamEnded", "tot")
+	census.mStreamKeyRotated = stats.Int64("stream_key_rotations_total", "Number of streams transitioned to a new stream key", "tot")
+	census.mStreamWarmup = stats.Float64("stream_warmup_duration_seconds", "Time it took the stream to buffer enough segments to be served to viewers", "sec")
 	census.mMaxSessions = stats.Int64("max_sessions_total", "MaxSessions", "tot")
 	census.mCurrentSessions = stats.Int64("current_sessions_total", "Number of currently transcded streams", "tot")
+	census.mConcurrentStreams = stats.Int64("concurrent_streams", "Number of concurrent streams sampled periodically", "tot")
+	census.mDrainMode = stats.Int64("drain_mode_active", "Whether the orchestrator is draining and rejecting new sessions", "tot")
+	census.mNodeUnhealthy = stats.Int64("node_unhealthy", "Whether the orchestrator is unhealthy and migrating its streams", "tot")
+	census.mStreamMigrations = stats.Int64("stream_migrations_total", "Number of streams migrated to other nodes because of bad health", "tot")
+	census.mQueuedSegments = stats.Int64("queued_segments", "Number of segments waiting to be uploaded and transcoded", "tot")
+	census.mActiveSegmenters = stats.Int64("active_segmenter_goroutines", "Number of running RTMP segmenter goroutines", "tot")
+	census.mDistinctOrchestrators = stats.Int64("distinct_orchestrators_per_stream", "Number of distinct orchestrators used by stream", "tot")
+	census.mS3UploadsInFlight = stats.Int64("s3_uploads_in_flight", "Number of uploads to own S3 bucket in progress", "tot")
+	census.mS3UploadsWaiting = stats.Int64("s3_uploads_waiting", "Number of uploads to own S3 bucket waiting for a free slot", "tot")
+	census.mUploadRetryQueueDepth = stats.Int64("upload_retry_queue_depth", "Number of failed uploads being retried in background", "tot")
+	census.mUploadRetries = stats.Int64("upload_retries_total", "Number of failed uploads retried in background", "tot")
+	census.mTranscodeFramesDropped = stats.Int64("transcode_frames_dropped_total", "Number of frames dropped by the transcoder", "tot")
+	census.mStorageCacheRequests = stats.Int64("storage_cache_requests_total", "Number of reads from the storage cache", "tot")
+	census.mStorageCacheEvictions = stats.Int64("storage_cache_evictions_total", "Number of objects evicted from the storage cache", "tot")
+	census.mSegmentFetchesInFlight = stats.Int64("segment_fetches_in_flight", "Number of segments being fetched from the remote storage to be served", "tot")
+	census.mSegmentFetchesQueued = stats.Int64("segment_fetch_queue_depth", "Number of segment fetches waiting for a free slot", "tot")
+	census.mStreamsRejectedTenantLimit = stats.Int64("streams_rejected_tenant_limit_total", "Number of streams rejected because the tenant is over the limit", "tot")
+	census.mSourceResolutions = stats.Int64("source_resolutions_total", "Number of streams by source resolution", "tot")
+	census.mUploadQueueDepth = stats.Int64("upload_queue_depth", "Number of source segments of the stream waiting to be uploaded", "tot")
+	census.mSegmenterMemory = stats.Int64("segmenter_memory_bytes", "Estimated memory held by stream's segmenter and buffers", "bytes")
+	census.mSegmentServeRatio = stats.Float64("segment_serve_ratio", "Segments served to HLS viewers per transcoded segment", "per")
+	census.mPlaylistRequests = stats.Int64("hls_playlist_requests_total", "Number of HLS media playlist requests", "tot")
+	census.mStreamHealthScore = stats.Float64("stream_health_score", "Stream health score, 0-100", "score")
 	census.mDiscoveryError = stats.Int64("discovery_errors_total", "Number of discover errors", "tot")
+	census.mOrchRejectedPrice = stats.Int64("orchestrator_rejected_price_total", "Number of orchestrators rejected for price above max price", "tot")
+	census.mOrchRejectedTicketParams = stats.Int64("orchestrator_rejected_ticket_params_total", "Number of orchestrators rejected for invalid ticket params", "tot")
+	census.mDiscoveryDuration = stats.Float64("orchestrator_discovery_duration_seconds", "Time it took to select orchestrators", "sec")
+	census.mWarmupDuration = stats.Float64("orchestrator_warmup_duration_seconds", "Time it took selected orchestrator to transcode warmup segment", "sec")
+	census.mDiscoveryCacheHits = stats.Int64("discovery_cache_hits_total", "Number of orchestrator lookups served from the discovery cache", "tot")
+	census.mDiscoveryCacheMisses = stats.Int64("discovery_cache_misses_total", "Number of orchestrator lookups not served from the discovery cache", "tot")
+	census.mDiscoveryCacheHitRate = stats.Float64("discovery_cache_hit_rate", "Share of orchestrator lookups served from the discovery cache", "per")
+	census.mOrchSelectionGini = stats.Float64("orchestrator_selection_gini", "Gini coefficient of the number of segments transcoded by each orchestrator", "per")
+	census.mOrchsInCooldown = stats.Int64("orchestrators_in_cooldown", "Number of orchestrators cooling down after failure", "tot")
+	census.mHealthyOrchs = stats.Int64("healthy_orchestrators", "Number of orchestrators responding to info requests", "tot")
+	census.mCooldownDuration = stats.Float64("cooldown_duration_seconds", "Time orchestrator spent cooling down after failure", "sec")
+	census.mOrchInvalidURI = stats.Int64("orchestrators_invalid_uri", "Orchestrators left out of the pool because their service URI could not be parsed", "tot")
+	census.mOrchSchemeDowngrade = stats.Int64("orchestrator_scheme_downgrades", "Orchestrators reached over http after their info request over https failed", "tot")
+	census.mOrchInfoAge = stats.Float64("orchestrator_info_age_seconds", "Age of the cached orchestrator info at selection time", "sec")
+	census.mGRPCStreamError = stats.Int64("orchestrator_grpc_stream_errors_total", "Number of gRPC stream errors", "tot")
+	census.mVerificationChecks = stats.Int64("verification_checks_total", "Number of checks of orchestrator outputs against another orchestrator's", "tot")
+	census.mVerificationPassed = stats.Int64("verification_passed_total", "Number of passed checks of orchestrator outputs", "tot")
+	census.mVerificationFailed = stats.Int64("verification_failed_total", "Number of failed checks of orchestrator outputs", "tot")
+	census.mGRPCRequestError = stats.Int64("orchestrator_grpc_request_errors_total", "Number of gRPC request errors", "tot")
 	census.mTranscodeRetried = stats.Int64("transcode_retried", "Number of times segment transcode was retried", "tot")
+	census.mSegmentFailover = stats.Int64("segments_triggering_failover_total", "Number of segments switched to another orchestrator after failing", "tot")
+	census.mFallbackChainPosition = stats.Int64("fallback_chain_position", "Position in the stream's fallback chain of the orchestrator that transcoded segment", "tot")
+	census.mSegmentFailedMaxOrchs = stats.Int64("segments_failed_max_orchestrators_total", "Number of segments failed because max number of orchestrators was tried", "tot")
+	census.mSegmentRetryCount = stats.Int64("segment_retry_count", "Number of tries it took to transcode segment", "tot")
 	census.mTranscodersNumber = stats.Int64("transcoders_number", "Number of transcoders currently connected to orchestrator", "tot")
 	census.mTranscodersCapacity = stats.Int64("transcoders_capacity", "Total advertised capacity of transcoders currently connected to orchestrator", "tot")
 	census.mTranscodersLoad = stats.Int64("transcoders_load", "Total load of transcoders currently connected to orchestrator", "tot")
 	census.mSuccessRate = stats.Float64("success_rate", "Success rate", "per")
 	census.mTranscodeTime = stats.Float64("transcode_time_seconds", "Transcoding time", "sec")
+	census.mTranscodeTTFB = stats.Float64("transcode_ttfb_seconds", "Time from transcode request sent till first byte of response received", "sec")
 	census.mTranscodeLatency = stats.Float64("transcode_latency_seconds",
 		"Transcoding latency, from source segment emered from segmenter till transcoded segment apeeared in manifest", "sec")
 	census.mTranscodeOverallLatency = stats.Float64("transcode_overall_latency_seconds",
 		"Transcoding latency, from source segment emered from segmenter till all transcoded segment apeeared in manifest", "sec")
 	census.mUploadTime = stats.Float64("upload_time_seconds", "Upload (to Orchestrator) time", "sec")
+	census.mSegmentBytes = stats.Int64("segment_bytes", "Size of segment uploaded to or downloaded from Orchestrator", "bytes")
 	census.mAuthWebhookTime = stats.Float64("auth_webhook_time_milliseconds", "Authentication webhook execution time", "ms")
+	census.mSegmentsReaped = stats.Int64("segments_reaped_total", "Number of segments deleted from storage by the retention policy", "tot")
+	census.mSegmentsReapedBytes = stats.Int64("segments_reaped_bytes", "Number of bytes freed in storage by the retention policy", "bytes")
+	census.mRenditionSizeVariance = stats.Float64("rendition_size_variance", "Coefficient of variation of the sizes of renditions transcoded from the same source segment", "ratio")
+	census.mCompressionRatio = stats.Float64("transcode_compression_ratio", "Source segment size divided by total size of the transcoded renditions", "ratio")
+	census.mProfileBitrateCapped = stats.Int64("profile_bitrate_capped_total", "Number of renditions encoded with bitrate capped to the profile's ceiling", "tot")
 
 	// Metrics for sending payments
 	census.mTicketValueSent = stats.Float64("ticket_value_sent", "TicketValueSent", "gwei")
+	census.mStreamCost = stats.Float64("stream_cost_gwei", "Total value of tickets sent for the stream", "gwei")
 	census.mTicketsSent = stats.Int64("tickets_sent", "TicketsSent", "tot")
 	census.mPaymentCreateError = stats.Int64("payment_create_errors", "PaymentCreateError", "tot")
 	census.mDeposit = stats.Float64("broadcaster_deposit", "Current remaining deposit for the broadcaster node", "gwei")
 	census.mReserve = stats.Float64("broadcaster_reserve", "Current remaiing reserve for the broadcaster node", "gwei")
+	census.mAutoTopUp = stats.Int64("auto_topup", "Automatic top-ups of the broadcaster deposit", "tot")
+	census.mAutoTopUpError = stats.Int64("auto_topup_errors", "Failed automatic top-ups of the broadcaster deposit", "tot")
 
 	// Metrics for receiving payments
 	census.mTicketValueRecv = stats.Float64("ticket_value_recv", "TicketValueRecv", "gwei")
 	census.mTicketsRecv = stats.Int64("tickets_recv", "TicketsRecv", "tot")
 	census.mPaymentRecvErr = stats.Int64("payment_recv_errors", "PaymentRecvErr", "tot")
+	census.mPaymentValidationErr = stats.Int64("payment_validation_errors_total", "Number of received payments failing the validation", "tot")
+	census.mRecipientRandReuse = stats.Int64("recipient_rand_reuse_total", "RecipientRandReuse", "tot")
 	census.mWinningTicketsRecv = stats.Int64("winning_tickets_recv", "WinningTicketsRecv", "tot")
 	census.mValueRedeemed = stats.Float64("value_redeemed", "ValueRedeemed", "gwei")
 	census.mTicketRedemptionError = stats.Int64("ticket_redemption_errors", "TicketRedemptionError", "tot")
+	census.mTicketRedemptionDefer = stats.Int64("ticket_redemption_deferred_total", "TicketRedemptionDeferred", "tot")
 	census.mSuggestedGasPrice = stats.Float64("suggested_gas_price", "SuggestedGasPrice", "gwei")
+	census.mGasPriceUSD = stats.Float64("gas_price_usd", "Cost of ticket redemption transaction in USD", "usd")
 	census.mTranscodingPrice = stats.Float64("transcoding_price", "TranscodingPrice", "wei")
 
 	glog.Infof("Compiler: %s Arch %s OS %s Go version %s", runtime.Compiler, runtime.GOARCH, runtime.GOOS, runtime.Version())
@@ -276,6 +588,20 @@ func InitCensus(nodeType, nodeID, version string) {
 			TagKeys:     baseTags,
 			Aggregation: view.Count(),
 		},
+		{
+			Name:        "stream_key_rotations_total",
+			Measure:     census.mStreamKeyRotated,
+			Description: "Number of streams transitioned to a new stream key without ending",
+			TagKeys:     baseTags,
+			Aggregation: view.Count(),
+		},
+		{
+			Name:        "stream_warmup_duration_seconds",
+			Measure:     census.mStreamWarmup,
+			Description: "Time from the stream start till the stream was ready to be served to viewers",
+			TagKeys:     baseTags,
+			Aggregation: view.Distribution(0, .5, 1, 2, 4, 6, 8, 10, 15, 20, 30, 60),
+		},
 		{
 			Name:        "stream_create_failed_total",
 			Measure:     census.mStreamCreateFailed,
@@ -318,13 +644,41 @@ func InitCensus(nodeType, nodeID, version string) {
 			TagKeys:     append([]tag.Key{census.kErrorCode}, baseTags...),
 			Aggregation: view.Count(),
 		},
+		{
+			Name:        "segment_source_upload_out_of_order_total",
+			Measure:     census.mSegmentUploadOutOfOrder,
+			Description: "Number of source segments uploaded after the segment with higher seqNo",
+			TagKeys:     baseTags,
+			Aggregation: view.Count(),
+		},
 		{
 			Name:        "segment_transcoded_total",
 			Measure:     census.mSegmentTranscoded,
 			Description: "SegmentTranscoded",
-			TagKeys:     append([]tag.Key{census.kProfiles}, baseTags...),
+			TagKeys:     append([]tag.Key{census.kProfiles, census.kGPU}, baseTags...),
+			Aggregation: view.Count(),
+		},
+		{
+			Name:        "segments_transcoded_gpu_total",
+			Measure:     census.mSegmentTranscodedGPU,
+			Description: "Number of segments transcoded by this node on GPU",
+			TagKeys:     append([]tag.Key{census.kGPU}, baseTags...),
+			Aggregation: view.Count(),
+		},
+		{
+			Name:        "segments_transcoded_cpu_total",
+			Measure:     census.mSegmentTranscodedCPU,
+			Description: "Number of segments transcoded by this node on CPU",
+			TagKeys:     baseTags,
 			Aggregation: view.Count(),
 		},
+		{
+			Name:        "segments_transcoded_gpu_ratio",
+			Measure:     census.mGPUTranscodeRatio,
+			Description: "Share of segments transcoded by this node on GPU, from 0 (all on CPU) to 1 (all on GPU)",
+			TagKeys:     baseTags,
+			Aggregation: view.LastValue(),
+		},
 		{
 			Name:        "segment_transcoded_unprocessed_total",
 			Measure:     census.mSegmentTranscodedUnprocessed,
@@ -364,9 +718,16 @@ func InitCensus(nodeType, nodeID, version string) {
 			Name:        "transcode_time_seconds",
 			Measure:     census.mTranscodeTime,
 			Description: "TranscodeTime, seconds",
-			TagKeys:     append([]tag.Key{census.kProfiles}, baseTags...),
+			TagKeys:     append([]tag.Key{census.kProfiles, census.kGPU}, baseTags...),
 			Aggregation: view.Distribution(0, .250, .500, .750, 1.000, 1.250, 1.500, 2.000, 2.500, 3.000, 3.500, 4.000, 4.500, 5.000, 10.000),
 		},
+		{
+			Name:        "transcode_ttfb_seconds",
+			Measure:     census.mTranscodeTTFB,
+			Description: "Time from transcode request sent to orchestrator till first byte of response received, seconds",
+			TagKeys:     append([]tag.Key{census.kOrchestrator}, baseTags...),
+			Aggregation: view.Distribution(0, .010, .025, .050, .100, .250, .500, .750, 1.000, 1.500, 2.000, 3.000, 5.000, 10.000),
+		},
 		{
 			Name:        "transcode_latency_seconds",
 			Measure:     census.mTranscodeLatency,
@@ -388,6 +749,13 @@ func InitCensus(nodeType, nodeID, version string) {
 			TagKeys:     baseTags,
 			Aggregation: view.Distribution(0, .10, .20, .50, .100, .150, .200, .500, .1000, .5000, 10.000),
 		},
+		{
+			Name:        "segment_bytes",
+			Measure:     census.mSegmentBytes,
+			Description: "Size of segment uploaded to or downloaded from Orchestrator, bytes",
+			TagKeys:     append([]tag.Key{census.kDirection}, baseTags...),
+			Aggregation: view.Distribution(0, 1e5, 2.5e5, 5e5, 1e6, 2e6, 4e6, 8e6, 16e6, 32e6),
+		},
 		{
 			Name:        "auth_webhook_time_milliseconds",
 			Measure:     census.mAuthWebhookTime,
@@ -395,6 +763,41 @@ func InitCensus(nodeType, nodeID, version string) {
 			TagKeys:     baseTags,
 			Aggregation: view.Distribution(0, 100, 250, 500, 750, 1000, 1500, 2000, 2500, 3000, 5000, 10000),
 		},
+		{
+			Name:        "segments_reaped_total",
+			Measure:     census.mSegmentsReaped,
+			Description: "Number of segments deleted from storage by the retention policy",
+			TagKeys:     baseTags,
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        "segments_reaped_bytes",
+			Measure:     census.mSegmentsReapedBytes,
+			Description: "Number of bytes freed in storage by the retention policy",
+			TagKeys:     baseTags,
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        "profile_bitrate_capped_total",
+			Measure:     census.mProfileBitrateCapped,
+			Description: "Number of renditions encoded with bitrate capped to the profile's ceiling",
+			TagKeys:     append([]tag.Key{census.kProfile}, baseTags...),
+			Aggregation: view.Count(),
+		},
+		{
+			Name:        "transcode_compression_ratio",
+			Measure:     census.mCompressionRatio,
+			Description: "Source segment size divided by total size of the transcoded renditions. Low values may point to profiles with too high bitrate",
+			TagKeys:     append([]tag.Key{census.kProfiles}, baseTags...),
+			Aggregation: view.Distribution(0, .25, .5, .75, 1, 1.5, 2, 3, 4, 6, 8, 12, 16, 32),
+		},
+		{
+			Name:        "rendition_size_variance",
+			Measure:     census.mRenditionSizeVariance,
+			Description: "Coefficient of variation of the sizes of renditions transcoded from the same source segment. Values unusual for the profiles may point to encoder issues",
+			TagKeys:     append([]tag.Key{census.kProfiles}, baseTags...),
+			Aggregation: view.Distribution(0, .1, .2, .3, .4, .5, .6, .8, 1, 1.25, 1.5, 2),
+		},
 		{
 			Name:        "max_sessions_total",
 			Measure:     census.mMaxSessions,
@@ -409,6 +812,169 @@ func InitCensus(nodeType, nodeID, version string) {
 			TagKeys:     baseTags,
 			Aggregation: view.LastValue(),
 		},
+		{
+			Name:        "concurrent_streams_distribution",
+			Measure:     census.mConcurrentStreams,
+			Description: "Number of streams transcoding at once, sampled periodically to show how often the node runs near its peak",
+			TagKeys:     baseTags,
+			Aggregation: view.Distribution(0, 1, 2, 4, 8, 16, 32, 64, 128, 256, 512, 1024),
+		},
+		{
+			Name:        "drain_mode_active",
+			Measure:     census.mDrainMode,
+			Description: "1 if the orchestrator is in drain mode and rejects new sessions, 0 otherwise",
+			TagKeys:     baseTags,
+			Aggregation: view.LastValue(),
+		},
+		{
+			Name:        "node_unhealthy",
+			Measure:     census.mNodeUnhealthy,
+			Description: "1 if health metrics of the orchestrator crossed the thresholds and its streams are migrated, 0 otherwise",
+			TagKeys:     baseTags,
+			Aggregation: view.LastValue(),
+		},
+		{
+			Name:        "stream_migrations_total",
+			Measure:     census.mStreamMigrations,
+			Description: "Number of streams migrated to other nodes because of bad health of this node",
+			TagKeys:     append([]tag.Key{census.kReason}, baseTags...),
+			Aggregation: view.Count(),
+		},
+		{
+			Name:        "queued_segments",
+			Measure:     census.mQueuedSegments,
+			Description: "Number of segments waiting to be uploaded and transcoded, across all streams",
+			TagKeys:     []tag.Key{census.kNodeID},
+			Aggregation: view.LastValue(),
+		},
+		{
+			Name:        "s3_uploads_in_flight",
+			Measure:     census.mS3UploadsInFlight,
+			Description: "Number of uploads to own S3 bucket in progress",
+			TagKeys:     baseTags,
+			Aggregation: view.LastValue(),
+		},
+		{
+			Name:        "s3_uploads_waiting",
+			Measure:     census.mS3UploadsWaiting,
+			Description: "Number of uploads to own S3 bucket waiting for a free slot because of -s3MaxConcurrentUploads",
+			TagKeys:     baseTags,
+			Aggregation: view.LastValue(),
+		},
+		{
+			Name:        "upload_retry_queue_depth",
+			Measure:     census.mUploadRetryQueueDepth,
+			Description: "Number of failed uploads being retried in background",
+			TagKeys:     baseTags,
+			Aggregation: view.LastValue(),
+		},
+		{
+			Name:        "upload_retries_total",
+			Measure:     census.mUploadRetries,
+			Description: "Number of failed uploads retried in background, by eventual outcome: success, failure after all the attempts, or dropped because the queue was full",
+			TagKeys:     append([]tag.Key{census.kOutcome}, baseTags...),
+			Aggregation: view.Count(),
+		},
+		{
+			Name:        "transcode_frames_dropped_total",
+			Measure:     census.mTranscodeFramesDropped,
+			Description: "Number of frames missing in the transcoded segments compared to the expected number given the segment duration and the frame rate",
+			TagKeys:     append([]tag.Key{census.kProfile}, baseTags...),
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        "storage_cache_requests_total",
+			Measure:     census.mStorageCacheRequests,
+			Description: "Number of reads from the storage cache, by outcome: hit or miss",
+			TagKeys:     append([]tag.Key{census.kOutcome}, baseTags...),
+			Aggregation: view.Count(),
+		},
+		{
+			Name:        "storage_cache_evictions_total",
+			Measure:     census.mStorageCacheEvictions,
+			Description: "Number of objects evicted from the storage cache to stay within its size",
+			TagKeys:     baseTags,
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        "segment_fetches_in_flight",
+			Measure:     census.mSegmentFetchesInFlight,
+			Description: "Number of segments being fetched from the remote storage to be served",
+			TagKeys:     baseTags,
+			Aggregation: view.LastValue(),
+		},
+		{
+			Name:        "segment_fetch_queue_depth",
+			Measure:     census.mSegmentFetchesQueued,
+			Description: "Number of segment fetches waiting for a free slot because of -maxSegmentFetches",
+			TagKeys:     baseTags,
+			Aggregation: view.LastValue(),
+		},
+		{
+			Name:        "streams_rejected_tenant_limit_total",
+			Measure:     census.mStreamsRejectedTenantLimit,
+			Description: "Number of streams rejected because the tenant is over the limit of concurrent or newly created streams",
+			TagKeys:     append([]tag.Key{census.kTenant, census.kReason}, baseTags...),
+			Aggregation: view.Count(),
+		},
+		{
+			Name:        "source_resolutions_total",
+			Measure:     census.mSourceResolutions,
+			Description: "Number of streams by source resolution (WxH)",
+			TagKeys:     append([]tag.Key{census.kResolution}, baseTags...),
+			Aggregation: view.Count(),
+		},
+		{
+			Name:        "active_segmenter_goroutines",
+			Measure:     census.mActiveSegmenters,
+			Description: "Number of running RTMP segmenter goroutines, should match number of active streams",
+			TagKeys:     baseTags,
+			Aggregation: view.LastValue(),
+		},
+		{
+			Name:        "upload_queue_depth",
+			Measure:     census.mUploadQueueDepth,
+			Description: "Number of source segments of the stream queued for upload and not uploaded yet. Growing queue means storage can't keep up with the stream",
+			TagKeys:     append([]tag.Key{census.kManifestID}, baseTags...),
+			Aggregation: view.LastValue(),
+		},
+		{
+			Name:        "segmenter_memory_bytes",
+			Measure:     census.mSegmenterMemory,
+			Description: "Estimated memory held by the stream's segmenter and buffers: segments in the HLS window and segments being processed",
+			TagKeys:     append([]tag.Key{census.kManifestID}, baseTags...),
+			Aggregation: view.LastValue(),
+		},
+		{
+			Name:        "distinct_orchestrators_per_stream",
+			Measure:     census.mDistinctOrchestrators,
+			Description: "Number of distinct orchestrators that transcoded segments of the stream, recorded at stream end",
+			TagKeys:     baseTags,
+			Aggregation: view.Distribution(0, 1, 2, 3, 4, 5, 6, 8, 10, 15, 20, 50),
+		},
+		{
+			Name:        "segment_serve_ratio",
+			Measure:     census.mSegmentServeRatio,
+			Description: "Number of segments served to HLS viewers divided by number of segments transcoded, per stream",
+			TagKeys:     append([]tag.Key{census.kManifestID}, baseTags...),
+			Aggregation: view.LastValue(),
+		},
+		{
+			// Sum instead of Count so the series of active streams can be
+			// restored after the view is re-registered on stream end
+			Name:        "hls_playlist_requests_total",
+			Measure:     census.mPlaylistRequests,
+			Description: "Number of HLS media playlist requests, per stream",
+			TagKeys:     append([]tag.Key{census.kManifestID}, baseTags...),
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        "stream_health_score",
+			Measure:     census.mStreamHealthScore,
+			Description: "Stream health score from 0 to 100 combining success rate, realtime ratio and number of transcode tries of the recent segments",
+			TagKeys:     append([]tag.Key{census.kManifestID}, baseTags...),
+			Aggregation: view.LastValue(),
+		},
 		{
 			Name:        "discovery_errors_total",
 			Measure:     census.mDiscoveryError,
@@ -416,6 +982,139 @@ func InitCensus(nodeType, nodeID, version string) {
 			TagKeys:     append([]tag.Key{census.kErrorCode}, baseTags...),
 			Aggregation: view.Count(),
 		},
+		{
+			Name:        "orchestrator_rejected_price_total",
+			Measure:     census.mOrchRejectedPrice,
+			Description: "Number of times orchestrator was filtered out during discovery because its price is above the max price",
+			TagKeys:     baseTags,
+			Aggregation: view.Count(),
+		},
+		{
+			Name:        "orchestrator_rejected_ticket_params_total",
+			Measure:     census.mOrchRejectedTicketParams,
+			Description: "Number of times orchestrator was filtered out during discovery because of invalid ticket params",
+			TagKeys:     baseTags,
+			Aggregation: view.Count(),
+		},
+		{
+			Name:        "orchestrator_discovery_duration_seconds",
+			Measure:     census.mDiscoveryDuration,
+			Description: "Time it took to fetch info from orchestrators and select them, by outcome",
+			TagKeys:     append([]tag.Key{census.kOutcome}, baseTags...),
+			Aggregation: view.Distribution(0, .05, .1, .25, .5, .75, 1, 1.5, 2, 2.5, 3, 4, 5, 10),
+		},
+		{
+			Name:        "orchestrator_warmup_duration_seconds",
+			Measure:     census.mWarmupDuration,
+			Description: "Time it took selected orchestrator to transcode warmup segment, by outcome",
+			TagKeys:     append([]tag.Key{census.kOutcome}, baseTags...),
+			Aggregation: view.Distribution(0, .05, .1, .25, .5, .75, 1, 1.5, 2, 2.5, 3, 4, 5, 10),
+		},
+		{
+			Name:        "discovery_cache_hits_total",
+			Measure:     census.mDiscoveryCacheHits,
+			Description: "Number of orchestrator lookups served from the discovery cache",
+			TagKeys:     baseTags,
+			Aggregation: view.Count(),
+		},
+		{
+			Name:        "discovery_cache_misses_total",
+			Measure:     census.mDiscoveryCacheMisses,
+			Description: "Number of orchestrator lookups that weren't served from the discovery cache",
+			TagKeys:     baseTags,
+			Aggregation: view.Count(),
+		},
+		{
+			Name:        "discovery_cache_hit_rate",
+			Measure:     census.mDiscoveryCacheHitRate,
+			Description: "Number of orchestrator lookups served from the discovery cache divided on total number of lookups",
+			TagKeys:     baseTags,
+			Aggregation: view.LastValue(),
+		},
+		{
+			Name:        "orchestrator_selection_gini",
+			Measure:     census.mOrchSelectionGini,
+			Description: "Gini coefficient of the number of segments transcoded by each orchestrator within the selection window, from 0 if the segments are spread evenly to near 1 if they concentrate on single orchestrator",
+			TagKeys:     baseTags,
+			Aggregation: view.LastValue(),
+		},
+		{
+			Name:        "orchestrators_in_cooldown",
+			Measure:     census.mOrchsInCooldown,
+			Description: "Number of distinct orchestrators not used by at least one stream, because they failed to transcode its segment",
+			TagKeys:     baseTags,
+			Aggregation: view.LastValue(),
+		},
+		{
+			Name:        "healthy_orchestrators",
+			Measure:     census.mHealthyOrchs,
+			Description: "Number of active orchestrators in the pool, not left out because they failed -maxOrchProbeFailures info requests in a row",
+			TagKeys:     baseTags,
+			Aggregation: view.LastValue(),
+		},
+		{
+			Name:        "cooldown_duration_seconds",
+			Measure:     census.mCooldownDuration,
+			Description: "Time orchestrator wasn't used by the stream after it failed to transcode its segment",
+			TagKeys:     baseTags,
+			Aggregation: view.Distribution(0, 1, 5, 10, 30, 60, 120, 300, 600, 1800, 3600),
+		},
+		{
+			Name:        "orchestrators_invalid_uri_total",
+			Measure:     census.mOrchInvalidURI,
+			Description: "Number of times orchestrator was left out of the pool because its service URI could not be parsed",
+			TagKeys:     baseTags,
+			Aggregation: view.Count(),
+		},
+		{
+			Name:        "orchestrator_scheme_downgrades_total",
+			Measure:     census.mOrchSchemeDowngrade,
+			Description: "Number of times orchestrator was reached over plain text http, without TLS, after its info request over https failed",
+			TagKeys:     append([]tag.Key{census.kOrchestrator}, baseTags...),
+			Aggregation: view.Count(),
+		},
+		{
+			Name:        "orchestrator_info_age_seconds",
+			Measure:     census.mOrchInfoAge,
+			Description: "How long ago the cached info of the selected orchestrator was last refreshed, recorded for each selected orchestrator",
+			TagKeys:     baseTags,
+			Aggregation: view.Distribution(0, 60, 300, 600, 1800, 3600, 2*3600, 4*3600, 6*3600, 12*3600, 24*3600),
+		},
+		{
+			Name:        "orchestrator_grpc_stream_errors_total",
+			Measure:     census.mGRPCStreamError,
+			Description: "Number of gRPC calls to orchestrator failed because connection or stream broke",
+			TagKeys:     append([]tag.Key{census.kOrchestrator}, baseTags...),
+			Aggregation: view.Count(),
+		},
+		{
+			Name:        "orchestrator_grpc_request_errors_total",
+			Measure:     census.mGRPCRequestError,
+			Description: "Number of gRPC calls to orchestrator failed with an error returned by orchestrator",
+			TagKeys:     append([]tag.Key{census.kOrchestrator}, baseTags...),
+			Aggregation: view.Count(),
+		},
+		{
+			Name:        "verification_checks_total",
+			Measure:     census.mVerificationChecks,
+			Description: "Number of sampled segments transcoded again by another orchestrator to check the outputs of the orchestrator",
+			TagKeys:     append([]tag.Key{census.kOrchestrator}, baseTags...),
+			Aggregation: view.Count(),
+		},
+		{
+			Name:        "verification_passed_total",
+			Measure:     census.mVerificationPassed,
+			Description: "Number of checks the outputs of the orchestrator matched the ones of another orchestrator within the tolerance",
+			TagKeys:     append([]tag.Key{census.kOrchestrator}, baseTags...),
+			Aggregation: view.Count(),
+		},
+		{
+			Name:        "verification_failed_total",
+			Measure:     census.mVerificationFailed,
+			Description: "Number of checks the outputs of the orchestrator didn't match the ones of another orchestrator within the tolerance",
+			TagKeys:     append([]tag.Key{census.kOrchestrator}, baseTags...),
+			Aggregation: view.Count(),
+		},
 		{
 			Name:        "transcode_retried",
 			Measure:     census.mTranscodeRetried,
@@ -423,6 +1122,36 @@ func InitCensus(nodeType, nodeID, version string) {
 			TagKeys:     append([]tag.Key{census.kTry}, baseTags...),
 			Aggregation: view.Count(),
 		},
+		{
+			Name:        "segments_failed_max_orchestrators_total",
+			Measure:     census.mSegmentFailedMaxOrchs,
+			Description: "Number of segments permanently failed because max number of distinct orchestrators was tried",
+			TagKeys:     baseTags,
+			Aggregation: view.Count(),
+		},
+		{
+			// Sum instead of Count so the series of active streams can be
+			// restored after the view is re-registered on stream end
+			Name:        "segments_triggering_failover_total",
+			Measure:     census.mSegmentFailover,
+			Description: "Number of segments that failed and were switched to another orchestrator, counted once per segment",
+			TagKeys:     append([]tag.Key{census.kManifestID}, baseTags...),
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        "fallback_chain_position",
+			Measure:     census.mFallbackChainPosition,
+			Description: "Position in the stream's fallback chain of the orchestrator that transcoded segment, 0 being the primary",
+			TagKeys:     baseTags,
+			Aggregation: view.Distribution(0, 1, 2, 3, 4, 5, 10, 20),
+		},
+		{
+			Name:        "segment_retry_count",
+			Measure:     census.mSegmentRetryCount,
+			Description: "Total number of transcode tries per segment, recorded when segment is transcoded or permanently failed",
+			TagKeys:     baseTags,
+			Aggregation: view.Distribution(0, 1, 2, 3, 4, 5, 6, 8, 10, 15, 20),
+		},
 		{
 			Name:        "transcoders_number",
 			Measure:     census.mTranscodersNumber,
@@ -453,6 +1182,13 @@ func InitCensus(nodeType, nodeID, version string) {
 			TagKeys:     append([]tag.Key{census.kRecipient, census.kManifestID}, baseTags...),
 			Aggregation: view.Sum(),
 		},
+		{
+			Name:        "stream_cost_gwei",
+			Measure:     census.mStreamCost,
+			Description: "Total value of tickets sent to all the orchestrators for the stream, gwei",
+			TagKeys:     append([]tag.Key{census.kManifestID}, baseTags...),
+			Aggregation: view.LastValue(),
+		},
 		{
 			Name:        "tickets_sent",
 			Measure:     census.mTicketsSent,
@@ -481,6 +1217,20 @@ func InitCensus(nodeType, nodeID, version string) {
 			TagKeys:     baseTags,
 			Aggregation: view.LastValue(),
 		},
+		{
+			Name:        "auto_topup_total",
+			Measure:     census.mAutoTopUp,
+			Description: "Number of times the broadcaster deposit was topped up automatically, after it fell below the threshold",
+			TagKeys:     baseTags,
+			Aggregation: view.Count(),
+		},
+		{
+			Name:        "auto_topup_errors_total",
+			Measure:     census.mAutoTopUpError,
+			Description: "Number of automatic top-ups of the broadcaster deposit which failed",
+			TagKeys:     baseTags,
+			Aggregation: view.Count(),
+		},
 
 		// Metrics for receiving payments
 		{
@@ -504,6 +1254,20 @@ func InitCensus(nodeType, nodeID, version string) {
 			TagKeys:     append([]tag.Key{census.kSender, census.kManifestID, census.kErrorCode}, baseTags...),
 			Aggregation: view.Sum(),
 		},
+		{
+			Name:        "payment_validation_errors_total",
+			Measure:     census.mPaymentValidationErr,
+			Description: "Number of received payments failing the validation, by the stage of the validation",
+			TagKeys:     append([]tag.Key{census.kStage}, baseTags...),
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        "recipient_rand_reuse_total",
+			Measure:     census.mRecipientRandReuse,
+			Description: "Number of tickets received with already revealed recipientRand, a potential double-spend attempt",
+			TagKeys:     append([]tag.Key{census.kSender}, baseTags...),
+			Aggregation: view.Sum(),
+		},
 		{
 			Name:        "winning_tickets_recv",
 			Measure:     census.mWinningTicketsRecv,
@@ -526,12 +1290,26 @@ func InitCensus(nodeType, nodeID, version string) {
 			Aggregation: view.Sum(),
 		},
 		{
-			Name:        "suggested_gas_price",
+			Name:        "ticket_redemption_deferred_total",
+			Measure:     census.mTicketRedemptionDefer,
+			Description: "Number of tickets with redemption deferred because redemption tx cost was too high relative to ticket face value, counted once per ticket",
+			TagKeys:     append([]tag.Key{census.kSender}, baseTags...),
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        "suggested_gas_price",
 			Measure:     census.mSuggestedGasPrice,
 			Description: "Suggested gas price for winning ticket redemption",
 			TagKeys:     baseTags,
 			Aggregation: view.LastValue(),
 		},
+		{
+			Name:        "gas_price_usd",
+			Measure:     census.mGasPriceUSD,
+			Description: "Cost of ticket redemption transaction at the suggested gas price in USD",
+			TagKeys:     baseTags,
+			Aggregation: view.LastValue(),
+		},
 		{
 			Name:        "transcoding_price",
 			Measure:     census.mTranscodingPrice,
@@ -539,12 +1317,35 @@ func InitCensus(nodeType, nodeID, version string) {
 			TagKeys:     append([]tag.Key{census.kSender}, baseTags...),
 			Aggregation: view.LastValue(),
 		},
+		{
+			Name:        "transcoding_price_distribution",
+			Measure:     census.mTranscodingPrice,
+			Description: "Distribution of transcoding prices per pixel, wei",
+			TagKeys:     append([]tag.Key{census.kSender}, baseTags...),
+			Aggregation: view.Distribution(0, 1, 10, 50, 100, 250, 500, 750, 1000, 1500, 2000, 2500, 5000, 7500, 10000, 25000, 50000, 100000),
+		},
+	}
+	if lockProfiling {
+		census.mCensusLockWait = stats.Float64("census_lock_wait_seconds", "Time spent waiting on the census lock", "sec")
+		views = append(views, &view.View{
+			Name:        "census_lock_wait_seconds",
+			Measure:     census.mCensusLockWait,
+			Description: "Time spent waiting on the census lock, recorded only if built with lockprofile tag",
+			TagKeys:     baseTags,
+			Aggregation: view.Distribution(0, .000001, .00001, .0001, .001, .01, .1, 1),
+		})
+	}
+	if len(staticKeys) > 0 {
+		for _, v := range views {
+			v.TagKeys = append(append([]tag.Key{}, v.TagKeys...), staticKeys...)
+		}
 	}
 
 	// Register the views
 	if err := view.Register(views...); err != nil {
 		glog.Fatalf("Failed to register views: %v", err)
 	}
+	census.views = views
 	registry := rprom.NewRegistry()
 	registry.MustRegister(rprom.NewProcessCollector(rprom.ProcessCollectorOpts{}))
 	registry.MustRegister(rprom.NewGoCollector())
@@ -572,14 +1373,62 @@ func InitCensus(nodeType, nodeID, version string) {
 	SetTranscodersNumberAndLoad(0, 0, 0)
 }
 
-// LogDiscoveryError records discovery error
+// reserved tag names that can't be used as static labels
+var reservedLabels = map[string]bool{
+	"node_type": true, "node_id": true, "compiler": true, "goarch": true, "goos": true,
+	"goversion": true, "livepeerversion": true,
+}
+
+// staticLabels returns tag keys, sorted by name, and mutators inserting
+// values of the labels
+func staticLabels(labels map[string]string) ([]tag.Key, []tag.Mutator) {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	keys := make([]tag.Key, 0, len(names))
+	mutators := make([]tag.Mutator, 0, len(names))
+	for _, name := range names {
+		if reservedLabels[name] {
+			glog.Fatalf("Metrics label name=%s is reserved", name)
+		}
+		key, err := tag.NewKey(name)
+		if err != nil {
+			glog.Fatalf("Invalid metrics label name=%s err=%v", name, err)
+		}
+		keys = append(keys, key)
+		mutators = append(mutators, tag.Insert(key, labels[name]))
+	}
+	return keys, mutators
+}
+
+// ParseLabels parses comma separated list of static labels in the
+// form name=value, e.g. "region=us-east,datacenter=dc1"
+func ParseLabels(s string) (map[string]string, error) {
+	labels := make(map[string]string)
+	for _, kv := range strings.Split(s, ",") {
+		kv = strings.TrimSpace(kv)
+		if kv == "" {
+			continue
+		}
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("invalid label=%s", kv)
+		}
+		name := strings.TrimSpace(parts[0])
+		if reservedLabels[name] {
+			return nil, fmt.Errorf("label name=%s is reserved", name)
+		}
+		labels[name] = strings.TrimSpace(parts[1])
+	}
+	return labels, nil
+}
+
+// LogDiscoveryError records discovery error. Code should be one of the
+// DiscoveryError* constants for the known error conditions
 func LogDiscoveryError(code string) {
 	glog.Error("Discovery error=" + code)
-	if strings.Contains(code, "OrchestratorCapped") {
-		code = "OrchestratorCapped"
-	} else if strings.Contains(code, "Canceled") {
-		code = "Canceled"
-	}
 	ctx, err := tag.New(census.ctx, tag.Insert(census.kErrorCode, code))
 	if err != nil {
 		glog.Error("Error creating context", err)
@@ -588,6 +1437,89 @@ func LogDiscoveryError(code string) {
 	stats.Record(ctx, census.mDiscoveryError.M(1))
 }
 
+// OrchestratorRejectedPrice records orchestrator filtered out during discovery
+// for its price being above the max price
+func OrchestratorRejectedPrice() {
+	stats.Record(census.ctx, census.mOrchRejectedPrice.M(1))
+}
+
+// OrchestratorRejectedTicketParams records orchestrator filtered out during
+// discovery for invalid ticket params
+func OrchestratorRejectedTicketParams() {
+	stats.Record(census.ctx, census.mOrchRejectedTicketParams.M(1))
+}
+
+// DiscoveryDuration records time it took to select orchestrators. Outcome
+// should be one of the DiscoveryOutcome* constants
+func DiscoveryDuration(dur time.Duration, outcome string) {
+	ctx, err := tag.New(census.ctx, tag.Insert(census.kOutcome, outcome))
+	if err != nil {
+		glog.Error("Error creating context", err)
+		return
+	}
+	stats.Record(ctx, census.mDiscoveryDuration.M(dur.Seconds()))
+}
+
+// OrchestratorWarmup records time it took selected orchestrator to transcode
+// warmup segment and whether it succeeded
+func OrchestratorWarmup(dur time.Duration, success bool) {
+	outcome := WarmupOutcomeSuccess
+	if !success {
+		outcome = WarmupOutcomeFailure
+	}
+	ctx, err := tag.New(census.ctx, tag.Insert(census.kOutcome, outcome))
+	if err != nil {
+		glog.Error("Error creating context", err)
+		return
+	}
+	stats.Record(ctx, census.mWarmupDuration.M(dur.Seconds()))
+}
+
+// OrchestratorGRPCError records failed gRPC call to the orchestrator. stream should
+// be true if the call failed because the underlying connection or stream broke
+func OrchestratorGRPCError(orch string, stream bool) {
+	ctx, err := tag.New(census.ctx, tag.Insert(census.kOrchestrator, orch))
+	if err != nil {
+		glog.Error("Error creating context", err)
+		return
+	}
+	if stream {
+		stats.Record(ctx, census.mGRPCStreamError.M(1))
+	} else {
+		stats.Record(ctx, census.mGRPCRequestError.M(1))
+	}
+}
+
+// VerificationCheck records the check of the outputs of the orchestrator
+// against the ones of another orchestrator transcoding the same segment
+func VerificationCheck(orch string, passed bool) {
+	ctx, err := tag.New(census.ctx, tag.Insert(census.kOrchestrator, orch))
+	if err != nil {
+		glog.Error("Error creating context", err)
+		return
+	}
+	result := census.mVerificationFailed.M(1)
+	if passed {
+		result = census.mVerificationPassed.M(1)
+	}
+	stats.Record(ctx, census.mVerificationChecks.M(1), result)
+}
+
+// DiscoveryCacheLookup records whether orchestrators lookup was served from the cache,
+// or required a live fetch or found no cached orchestrators
+func DiscoveryCacheLookup(hit bool) {
+	census.lock.Lock()
+	defer census.lock.Unlock()
+	census.discoveryLookups++
+	m := census.mDiscoveryCacheMisses.M(1)
+	if hit {
+		census.discoveryHits++
+		m = census.mDiscoveryCacheHits.M(1)
+	}
+	rate := float64(census.discoveryHits) / float64(census.discoveryLookups)
+	stats.Record(census.ctx, m, census.mDiscoveryCacheHitRate.M(rate))
+}
+
 func (cen *censusMetricsCounter) successRate() float64 {
 	var i int
 	var f float64
@@ -613,9 +1545,10 @@ func (sa *segmentsAverager) successRate() (float64, bool) {
 	}
 	i := sa.start
 	now := time.Now()
+	timeout := segmentTimeout()
 	for {
 		item := &sa.segments[i]
-		if item.transcoded > 0 || item.failed || now.Sub(item.emergedTime) > timeToWaitForError {
+		if item.transcoded > 0 || item.failed || now.Sub(item.emergedTime) > timeout {
 			emerged += item.emerged
 			transcoded += item.transcoded
 		}
@@ -630,6 +1563,51 @@ func (sa *segmentsAverager) successRate() (float64, bool) {
 	return 1, false
 }
 
+// health returns the health score of the stream from 0 to 100 and false if
+// none of the recent segments is completed yet. The score is a weighted
+// average of the success rate, the realtime ratio (segment duration divided by
+// the time it took to transcode it, capped at 1) and the inverse of the number
+// of transcode tries. Components without data are left out of the average.
+func (sa *segmentsAverager) health() (float64, bool) {
+	const (
+		successWeight  = 0.5
+		realtimeWeight = 0.3
+		triesWeight    = 0.2
+	)
+	success, has := sa.successRate()
+	if !has {
+		return 100, false
+	}
+	score, weights := success*successWeight, successWeight
+	var realtime, tries float64
+	var realtimeNum, triesNum int
+	i := sa.start
+	for {
+		item := &sa.segments[i]
+		if item.transcoded > 0 && item.duration > 0 && item.latency > 0 {
+			realtime += math.Min(1, item.duration/item.latency.Seconds())
+			realtimeNum++
+		}
+		if item.tries > 0 {
+			tries += 1 / float64(item.tries)
+			triesNum++
+		}
+		if i == sa.end {
+			break
+		}
+		i = sa.advance(i)
+	}
+	if realtimeNum > 0 {
+		score += realtime / float64(realtimeNum) * realtimeWeight
+		weights += realtimeWeight
+	}
+	if triesNum > 0 {
+		score += tries / float64(triesNum) * triesWeight
+		weights += triesWeight
+	}
+	return 100 * score / weights, true
+}
+
 func (sa *segmentsAverager) advance(i int) int {
 	i++
 	if i == len(sa.segments) {
@@ -638,12 +1616,15 @@ func (sa *segmentsAverager) advance(i int) int {
 	return i
 }
 
-func (sa *segmentsAverager) addEmerged(seqNo uint64) {
+func (sa *segmentsAverager) addEmerged(seqNo uint64, dur float64) {
 	item, _ := sa.getAddItem(seqNo)
 	item.emerged = 1
 	item.transcoded = 0
 	item.emergedTime = time.Now()
 	item.seqNo = seqNo
+	item.duration = dur
+	item.latency = 0
+	item.tries = 0
 }
 
 func (sa *segmentsAverager) addTranscoded(seqNo uint64, failed bool) {
@@ -659,6 +1640,23 @@ func (sa *segmentsAverager) addTranscoded(seqNo uint64, failed bool) {
 	item.seqNo = seqNo
 }
 
+// find returns the item of the segment or nil if it isn't among recent segments
+func (sa *segmentsAverager) find(seqNo uint64) *segmentCount {
+	if sa.end == -1 {
+		return nil
+	}
+	i := sa.start
+	for {
+		if sa.segments[i].seqNo == seqNo {
+			return &sa.segments[i]
+		}
+		if i == sa.end {
+			return nil
+		}
+		i = sa.advance(i)
+	}
+}
+
 func (sa *segmentsAverager) getAddItem(seqNo uint64) (*segmentCount, bool) {
 	var index int
 	if sa.end == -1 {
@@ -689,9 +1687,10 @@ func (sa *segmentsAverager) canBeRemoved() bool {
 	}
 	i := sa.start
 	now := time.Now()
+	timeout := segmentTimeout()
 	for {
 		item := &sa.segments[i]
-		if item.transcoded == 0 && !item.failed && now.Sub(item.emergedTime) <= timeToWaitForError {
+		if item.transcoded == 0 && !item.failed && now.Sub(item.emergedTime) <= timeout {
 			return false
 		}
 		if i == sa.end {
@@ -706,10 +1705,11 @@ func (cen *censusMetricsCounter) timeoutWatcher(ctx context.Context) {
 	for {
 		cen.lock.Lock()
 		now := time.Now()
+		timeout := segmentTimeout()
 		for nonce, emerged := range cen.emergeTimes {
 			for seqNo, tm := range emerged {
 				ago := now.Sub(tm)
-				if ago > timeToWaitForError {
+				if ago > timeout {
 					stats.Record(cen.ctx, cen.mSegmentEmerged.M(1))
 					delete(emerged, seqNo)
 					// This shouldn't happen, but if it is, we record
@@ -720,21 +1720,23 @@ func (cen *censusMetricsCounter) timeoutWatcher(ctx context.Context) {
 			}
 		}
 		cen.sendSuccess()
+		cen.sampleConcurrentStreams()
 		for nonce, avg := range cen.success {
-			if avg.removed && now.Sub(avg.removedAt) > 2*timeToWaitForError {
+			if avg.removed && now.Sub(avg.removedAt) > 2*timeout {
 				// need to keep this around for some time to give Prometheus chance to scrape this value
 				// (Prometheus scrapes every 5 seconds)
 				delete(cen.success, nonce)
 			} else {
 				for seqNo, tr := range avg.tries {
-					if now.Sub(tr.first) > 2*timeToWaitForError {
+					if now.Sub(tr.first) > 2*timeout {
 						delete(avg.tries, seqNo)
 					}
 				}
 			}
 		}
+		cen.removeStreamCosts(now)
 		cen.lock.Unlock()
-		time.Sleep(timeoutWatcherPause)
+		time.Sleep(timeoutWatcherInterval())
 	}
 }
 
@@ -747,9 +1749,145 @@ func MaxSessions(maxSessions int) {
 func CurrentSessions(currentSessions int) {
 	census.lock.Lock()
 	defer census.lock.Unlock()
+	census.lastCurrentSessions = currentSessions
 	stats.Record(census.ctx, census.mCurrentSessions.M(int64(currentSessions)))
 }
 
+// DrainMode records whether the orchestrator is in drain mode
+func DrainMode(active bool) {
+	var v int64
+	if active {
+		v = 1
+	}
+	stats.Record(census.ctx, census.mDrainMode.M(v))
+}
+
+// NodeUnhealthy records whether the orchestrator is unhealthy
+func NodeUnhealthy(unhealthy bool) {
+	var v int64
+	if unhealthy {
+		v = 1
+	}
+	stats.Record(census.ctx, census.mNodeUnhealthy.M(v))
+}
+
+// StreamMigrated records the stream signaled to migrate to other node for the reason
+func StreamMigrated(reason string) {
+	ctx, err := tag.New(census.ctx, tag.Insert(census.kReason, reason))
+	if err != nil {
+		glog.Error("Error creating context", err)
+		return
+	}
+	stats.Record(ctx, census.mStreamMigrations.M(1))
+}
+
+// CurrentSuccessRate returns the last recorded success rate
+func CurrentSuccessRate() float64 {
+	census.lock.Lock()
+	defer census.lock.Unlock()
+	return census.lastSuccessRate
+}
+
+// CurrentSessionsCount returns the last recorded number of current sessions
+func CurrentSessionsCount() int {
+	census.lock.Lock()
+	defer census.lock.Unlock()
+	return census.lastCurrentSessions
+}
+
+// AverageTranscodeTime returns the exponentially weighted moving average of
+// the transcode time in seconds, which follows the recent segments
+func AverageTranscodeTime() float64 {
+	census.lock.Lock()
+	defer census.lock.Unlock()
+	return census.transcodeTimeAvg
+}
+
+// TranscodeTimeTotals returns the total transcode time in seconds and the
+// number of all the segments transcoded so far
+func TranscodeTimeTotals() (float64, int64) {
+	census.lock.Lock()
+	defer census.lock.Unlock()
+	return census.transcodeTimeSum, census.transcodeTimeCount
+}
+
+// ResetCounters zeroes all the cumulative metrics (counts, sums and
+// distributions) by re-registering their views; gauges keep their last values.
+// Intended for load testing only: Prometheus sees the drop as a counter reset,
+// so rates across the reset are skewed.
+func ResetCounters() {
+	census.lock.Lock()
+	defer census.lock.Unlock()
+	var views []*view.View
+	for _, v := range census.views {
+		if v.Aggregation.Type != view.AggTypeLastValue {
+			views = append(views, v)
+		}
+	}
+	view.Unregister(views...)
+	if err := view.Register(views...); err != nil {
+		glog.Errorf("Error re-registering views: %v", err)
+		return
+	}
+	for _, v := range views {
+		delete(census.streamGauges, v.Measure)
+	}
+	census.discoveryLookups = 0
+	census.discoveryHits = 0
+	census.transcodeTimeSum = 0
+	census.transcodeTimeCount = 0
+	census.transcodeTimeAvg = 0
+	glog.Infof("Reset %d cumulative metrics", len(views))
+}
+
+// ActiveSegmenterGoroutines records the number of running segmenter goroutines
+func ActiveSegmenterGoroutines(active int64) {
+	stats.Record(census.ctx, census.mActiveSegmenters.M(active))
+}
+
+// S3Uploads records the number of uploads to own S3 bucket in progress and
+// waiting for a free slot
+func S3Uploads(inFlight, waiting int) {
+	stats.Record(census.ctx, census.mS3UploadsInFlight.M(int64(inFlight)), census.mS3UploadsWaiting.M(int64(waiting)))
+}
+
+// UploadRetryQueueDepth records the number of failed uploads being retried
+func UploadRetryQueueDepth(depth int) {
+	stats.Record(census.ctx, census.mUploadRetryQueueDepth.M(int64(depth)))
+}
+
+// UploadRetried records failed upload retried in background. Outcome should
+// be one of the UploadRetryOutcome* constants
+func UploadRetried(outcome string) {
+	ctx, err := tag.New(census.ctx, tag.Insert(census.kOutcome, outcome))
+	if err != nil {
+		glog.Error("Error creating context", err)
+		return
+	}
+	stats.Record(ctx, census.mUploadRetries.M(1))
+}
+
+// SetQueuedSegments records the number of segments of the stream waiting to be
+// uploaded and transcoded. Reported value is the total across all the streams.
+func SetQueuedSegments(nonce uint64, count int) {
+	census.lock.Lock()
+	defer census.lock.Unlock()
+	if count > 0 {
+		census.queuedSegments[nonce] = count
+	} else {
+		delete(census.queuedSegments, nonce)
+	}
+	census.sendQueuedSegments()
+}
+
+func (cen *censusMetricsCounter) sendQueuedSegments() {
+	var total int
+	for _, count := range cen.queuedSegments {
+		total += count
+	}
+	stats.Record(cen.ctx, cen.mQueuedSegments.M(int64(total)))
+}
+
 func TranscodeTry(nonce, seqNo uint64) {
 	census.lock.Lock()
 	defer census.lock.Unlock()
@@ -779,6 +1917,55 @@ func TranscodeTry(nonce, seqNo uint64) {
 	}
 }
 
+// SegmentFailedMaxOrchestrators records segment that won't be retried anymore
+// because max number of distinct orchestrators was tried
+func SegmentFailedMaxOrchestrators(nonce, seqNo uint64) {
+	census.lock.Lock()
+	defer census.lock.Unlock()
+	stats.Record(census.ctx, census.mSegmentFailedMaxOrchs.M(1))
+	census.recordTries(nonce, seqNo)
+}
+
+// SegmentFailover records segment switched to another orchestrator after
+// failing with the previous one. Only the segments of the active streams are
+// recorded, as the series of the stream is dropped on stream end.
+func SegmentFailover(manifestID string) {
+	census.lock.Lock()
+	defer census.lock.Unlock()
+	if !census.streamActive(manifestID) {
+		return
+	}
+	census.sendStreamCounter(manifestID, census.mSegmentFailover)
+}
+
+// FallbackChainPosition records the position in the stream's fallback chain
+// of the orchestrator that transcoded segment of the stream
+func FallbackChainPosition(pos int) {
+	stats.Record(census.ctx, census.mFallbackChainPosition.M(int64(pos)))
+}
+
+// TranscodeTriesExhausted records the number of tries of the segment that
+// won't be retried anymore
+func TranscodeTriesExhausted(nonce, seqNo uint64) {
+	census.lock.Lock()
+	defer census.lock.Unlock()
+	census.recordTries(nonce, seqNo)
+}
+
+// recordTries records total number of transcode tries of the segment once, when
+// the segment is either transcoded or permanently failed
+func (cen *censusMetricsCounter) recordTries(nonce, seqNo uint64) {
+	if av, ok := cen.success[nonce]; ok {
+		if tr, ok := av.tries[seqNo]; ok {
+			stats.Record(cen.ctx, cen.mSegmentRetryCount.M(int64(tr.tries)))
+			delete(av.tries, seqNo)
+			if item := av.find(seqNo); item != nil {
+				item.tries = tr.tries
+			}
+		}
+	}
+}
+
 func SetTranscodersNumberAndLoad(load, capacity, number int) {
 	census.lock.Lock()
 	defer census.lock.Unlock()
@@ -787,19 +1974,21 @@ func SetTranscodersNumberAndLoad(load, capacity, number int) {
 	stats.Record(census.ctx, census.mTranscodersNumber.M(int64(number)))
 }
 
-func SegmentEmerged(nonce, seqNo uint64, profilesNum int) {
-	glog.V(logLevel).Infof("Logging SegmentEmerged... nonce=%d seqNo=%d", nonce, seqNo)
-	census.segmentEmerged(nonce, seqNo, profilesNum)
+// SegmentEmerged records source segment emerged from segmenter. dur is the
+// duration of the segment in seconds
+func SegmentEmerged(nonce, seqNo uint64, profilesNum int, dur float64) {
+	glog.V(logLevel).Infof("Logging SegmentEmerged... nonce=%d seqNo=%d dur=%v", nonce, seqNo, dur)
+	census.segmentEmerged(nonce, seqNo, profilesNum, dur)
 }
 
-func (cen *censusMetricsCounter) segmentEmerged(nonce, seqNo uint64, profilesNum int) {
+func (cen *censusMetricsCounter) segmentEmerged(nonce, seqNo uint64, profilesNum int, dur float64) {
 	cen.lock.Lock()
 	defer cen.lock.Unlock()
 	if _, has := cen.emergeTimes[nonce]; !has {
 		cen.emergeTimes[nonce] = make(map[uint64]time.Time)
 	}
 	if avg, has := cen.success[nonce]; has {
-		avg.addEmerged(seqNo)
+		avg.addEmerged(seqNo, dur)
 	}
 	cen.emergeTimes[nonce][seqNo] = time.Now()
 	stats.Record(cen.ctx, cen.mSegmentEmergedUnprocessed.M(1))
@@ -831,6 +2020,28 @@ func (cen *censusMetricsCounter) segmentUploaded(nonce, seqNo uint64, uploadDur
 	stats.Record(cen.ctx, cen.mSegmentUploaded.M(1), cen.mUploadTime.M(float64(uploadDur/time.Second)))
 }
 
+// TranscodeTTFB records time from transcode request sent to the orchestrator
+// till first byte of its response received
+func TranscodeTTFB(orch string, ttfb time.Duration) {
+	ctx, err := tag.New(census.ctx, tag.Insert(census.kOrchestrator, orch))
+	if err != nil {
+		glog.Error("Error creating context", err)
+		return
+	}
+	stats.Record(ctx, census.mTranscodeTTFB.M(ttfb.Seconds()))
+}
+
+// RecordSegmentBytes records the size of segment data moved in the direction,
+// either SegmentBytesUpload or SegmentBytesDownload
+func RecordSegmentBytes(direction string, bytes int64) {
+	ctx, err := tag.New(census.ctx, tag.Insert(census.kDirection, direction))
+	if err != nil {
+		glog.Error("Error creating context", err)
+		return
+	}
+	stats.Record(ctx, census.mSegmentBytes.M(bytes))
+}
+
 func AuthWebhookFinished(dur time.Duration) {
 	census.authWebhookFinished(dur)
 }
@@ -839,13 +2050,71 @@ func (cen *censusMetricsCounter) authWebhookFinished(dur time.Duration) {
 	stats.Record(cen.ctx, cen.mAuthWebhookTime.M(float64(dur)/float64(time.Millisecond)))
 }
 
+// SegmentsReaped records segments deleted from storage by the retention policy
+func SegmentsReaped(count int, bytes int64) {
+	if count <= 0 {
+		return
+	}
+	stats.Record(census.ctx, census.mSegmentsReaped.M(int64(count)), census.mSegmentsReapedBytes.M(bytes))
+}
+
+// SegmentUploadOutOfOrder records source segment uploaded after the segment
+// with higher seqNo
+func SegmentUploadOutOfOrder() {
+	stats.Record(census.ctx, census.mSegmentUploadOutOfOrder.M(1))
+}
+
+// uploadErrors maps known error messages to upload error codes. Checked in
+// order, so timeouts reported by object storage are classified as timeouts
+var uploadErrors = []struct {
+	substr string // lowercase
+	code   SegmentUploadError
+}{
+	// net/http and context timeouts
+	{"client.timeout", SegmentUploadErrorTimeout},
+	{"context deadline exceeded", SegmentUploadErrorTimeout},
+	{"i/o timeout", SegmentUploadErrorTimeout},
+	{"header timeout", SegmentUploadErrorTimeout},
+	// ffmpeg
+	{"connection timed out", SegmentUploadErrorTimeout},
+	// S3
+	{"requesttimeout", SegmentUploadErrorTimeout},
+
+	{"session ended", SegmentUploadErrorSessionEnded},
+
+	// orchestrator response
+	{"insufficient balance", SegmentUploadErrorInsufficientBalance},
+	{"errsegsig", SegmentUploadErrorGenCreds},
+	{"errorsegencoding", SegmentUploadErrorGenCreds},
+
+	// S3 and GS responses
+	{"accessdenied", SegmentUploadErrorOS},
+	{"nosuchbucket", SegmentUploadErrorOS},
+	{"invalidaccesskeyid", SegmentUploadErrorOS},
+	{"signaturedoesnotmatch", SegmentUploadErrorOS},
+	{"requesttimetooskewed", SegmentUploadErrorOS},
+	{"entitytoolarge", SegmentUploadErrorOS},
+	{"slowdown", SegmentUploadErrorOS},
+	{"oauth2:", SegmentUploadErrorOS},
+}
+
+// classifyUploadError returns the code of the upload error from its message,
+// SegmentUploadErrorUnknown if the message isn't recognized
+func classifyUploadError(reason string) SegmentUploadError {
+	reason = strings.ToLower(reason)
+	for _, e := range uploadErrors {
+		if strings.Contains(reason, e.substr) {
+			return e.code
+		}
+	}
+	return SegmentUploadErrorUnknown
+}
+
+// SegmentUploadFailed records failed upload of the segment. The code is derived
+// from the reason if it is SegmentUploadErrorUnknown
 func SegmentUploadFailed(nonce, seqNo uint64, code SegmentUploadError, reason string, permanent bool) {
 	if code == SegmentUploadErrorUnknown {
-		if strings.Contains(reason, "Client.Timeout") {
-			code = SegmentUploadErrorTimeout
-		} else if reason == "Session ended" {
-			code = SegmentUploadErrorSessionEnded
-		}
+		code = classifyUploadError(reason)
 	}
 	glog.Errorf("Logging SegmentUploadFailed... code=%v reason='%s'", code, reason)
 
@@ -856,6 +2125,7 @@ func (cen *censusMetricsCounter) segmentUploadFailed(nonce, seqNo uint64, code S
 	cen.lock.Lock()
 	defer cen.lock.Unlock()
 	if permanent {
+		cen.recordTries(nonce, seqNo)
 		cen.countSegmentEmerged(nonce, seqNo)
 	}
 
@@ -868,26 +2138,64 @@ func (cen *censusMetricsCounter) segmentUploadFailed(nonce, seqNo uint64, code S
 	if permanent {
 		cen.countSegmentTranscoded(nonce, seqNo, true)
 		cen.sendSuccess()
+		cen.sendHealthScore(nonce)
 	}
 }
 
-func SegmentTranscoded(nonce, seqNo uint64, transcodeDur time.Duration, profiles string) {
-	glog.V(logLevel).Infof("Logging SegmentTranscode nonce=%d seqNo=%d dur=%s", nonce, seqNo, transcodeDur)
-	census.segmentTranscoded(nonce, seqNo, transcodeDur, profiles)
+// SegmentTranscoded records transcoded segment. gpu is the id of GPU device
+// the segment was transcoded on, empty if it was transcoded on CPU
+func SegmentTranscoded(nonce, seqNo uint64, transcodeDur time.Duration, profiles, gpu string) {
+	glog.V(logLevel).Infof("Logging SegmentTranscode nonce=%d seqNo=%d dur=%s gpu=%s", nonce, seqNo, transcodeDur, gpu)
+	census.segmentTranscoded(nonce, seqNo, transcodeDur, profiles, gpu)
 }
 
 func (cen *censusMetricsCounter) segmentTranscoded(nonce, seqNo uint64, transcodeDur time.Duration,
-	profiles string) {
+	profiles, gpu string) {
 	cen.lock.Lock()
 	defer cen.lock.Unlock()
-	ctx, err := tag.New(cen.ctx, tag.Insert(cen.kProfiles, profiles))
+	if gpu == "" {
+		gpu = "cpu"
+	}
+	ctx, err := tag.New(cen.ctx, tag.Insert(cen.kProfiles, profiles), tag.Insert(cen.kGPU, gpu))
 	if err != nil {
 		glog.Error("Error creating context", err)
 		return
 	}
+	if cen.transcodeTimeCount == 0 {
+		cen.transcodeTimeAvg = transcodeDur.Seconds()
+	} else {
+		cen.transcodeTimeAvg += transcodeTimeWeight * (transcodeDur.Seconds() - cen.transcodeTimeAvg)
+	}
+	cen.transcodeTimeSum += transcodeDur.Seconds()
+	cen.transcodeTimeCount++
 	stats.Record(ctx, cen.mSegmentTranscoded.M(1), cen.mTranscodeTime.M(float64(transcodeDur/time.Second)))
 }
 
+// SegmentTranscodedOn records the backend that transcoded the segment on
+// this node: GPU device or, if empty, CPU
+func SegmentTranscodedOn(gpu string) {
+	census.segmentTranscodedOn(gpu)
+}
+
+func (cen *censusMetricsCounter) segmentTranscodedOn(gpu string) {
+	cen.lock.Lock()
+	defer cen.lock.Unlock()
+	if gpu == "" {
+		cen.cpuSegments++
+		stats.Record(cen.ctx, cen.mSegmentTranscodedCPU.M(1))
+	} else {
+		ctx, err := tag.New(cen.ctx, tag.Insert(cen.kGPU, gpu))
+		if err != nil {
+			glog.Error("Error creating context", err)
+			return
+		}
+		cen.gpuSegments++
+		stats.Record(ctx, cen.mSegmentTranscodedGPU.M(1))
+	}
+	ratio := float64(cen.gpuSegments) / float64(cen.gpuSegments+cen.cpuSegments)
+	stats.Record(cen.ctx, cen.mGPUTranscodeRatio.M(ratio))
+}
+
 func SegmentTranscodeFailed(subType SegmentTranscodeError, nonce, seqNo uint64, err error, permanent bool) {
 	glog.Errorf("Logging SegmentTranscodeFailed subtype=%v nonce=%d seqNo=%d error='%s'", subType, nonce, seqNo, err.Error())
 	census.segmentTranscodeFailed(nonce, seqNo, subType, permanent)
@@ -903,9 +2211,11 @@ func (cen *censusMetricsCounter) segmentTranscodeFailed(nonce, seqNo uint64, cod
 	}
 	stats.Record(ctx, cen.mSegmentTranscodeFailed.M(1))
 	if permanent {
+		cen.recordTries(nonce, seqNo)
 		cen.countSegmentEmerged(nonce, seqNo)
 		cen.countSegmentTranscoded(nonce, seqNo, code != SegmentTranscodeErrorSessionEnded)
 		cen.sendSuccess()
+		cen.sendHealthScore(nonce)
 	}
 }
 
@@ -922,8 +2232,24 @@ func (cen *censusMetricsCounter) countSegmentEmerged(nonce, seqNo uint64) {
 	}
 }
 
+// recordWithExemplar records the measurement attaching segment's nonce and seqNo
+// as exemplar, so latency outliers can be traced back to the specific segment
+func recordWithExemplar(ctx context.Context, m stats.Measurement, nonce, seqNo uint64) {
+	attachments := metricdata.Attachments{"nonce": nonce, "seqNo": seqNo}
+	if err := stats.RecordWithOptions(ctx, stats.WithMeasurements(m), stats.WithAttachments(attachments)); err != nil {
+		glog.Error("Error recording measurement ", err)
+	}
+}
+
+// sampleConcurrentStreams records the current number of streams into the
+// concurrency distribution; caller should hold the lock
+func (cen *censusMetricsCounter) sampleConcurrentStreams() {
+	stats.Record(cen.ctx, cen.mConcurrentStreams.M(int64(cen.lastCurrentSessions)))
+}
+
 func (cen *censusMetricsCounter) sendSuccess() {
-	stats.Record(cen.ctx, cen.mSuccessRate.M(cen.successRate()))
+	cen.lastSuccessRate = cen.successRate()
+	stats.Record(cen.ctx, cen.mSuccessRate.M(cen.lastSuccessRate))
 }
 
 func SegmentFullyTranscoded(nonce, seqNo uint64, profiles string, errCode SegmentTranscodeError) {
@@ -938,19 +2264,343 @@ func SegmentFullyTranscoded(nonce, seqNo uint64, profiles string, errCode Segmen
 	if st, ok := census.emergeTimes[nonce][seqNo]; ok {
 		if errCode == "" {
 			latency := time.Since(st)
-			stats.Record(ctx, census.mTranscodeOverallLatency.M(float64(latency/time.Second)))
+			recordWithExemplar(ctx, census.mTranscodeOverallLatency.M(float64(latency/time.Second)), nonce, seqNo)
+			if avg, ok := census.success[nonce]; ok {
+				if item := avg.find(seqNo); item != nil {
+					item.latency = latency
+				}
+			}
 		}
 		census.countSegmentEmerged(nonce, seqNo)
 	}
 	if errCode == "" {
 		stats.Record(ctx, census.mSegmentTranscodedAllAppeared.M(1))
+		if sc, ok := census.serveCounts[nonce]; ok {
+			sc.transcoded++
+			census.sendServeRatio(sc)
+		}
 	}
 	failed := errCode != "" && errCode != SegmentTranscodeErrorSessionEnded
 	census.countSegmentTranscoded(nonce, seqNo, failed)
+	census.recordTries(nonce, seqNo)
 	if !failed {
 		stats.Record(ctx, census.mSegmentTranscodedUnprocessed.M(1))
 	}
 	census.sendSuccess()
+	census.sendHealthScore(nonce)
+}
+
+// StreamHealthScore returns health score of the stream from 0 to 100,
+// calculated over the recent segments. Stream that doesn't have any completed
+// segments yet (or unknown stream) is considered healthy and has score of 100;
+// stream_health_score isn't recorded for it, so dashboards show no data
+// instead of a spurious value.
+func StreamHealthScore(nonce uint64) float64 {
+	census.lock.Lock()
+	defer census.lock.Unlock()
+	if avg, ok := census.success[nonce]; ok {
+		score, _ := avg.health()
+		return score
+	}
+	return 100
+}
+
+func (cen *censusMetricsCounter) sendHealthScore(nonce uint64) {
+	avg, ok := cen.success[nonce]
+	sc, sok := cen.serveCounts[nonce]
+	if !ok || !sok {
+		return
+	}
+	score, has := avg.health()
+	if !has {
+		return
+	}
+	cen.sendStreamGauge(sc.manifestID, cen.mStreamHealthScore.M(score))
+}
+
+// TranscodeCompressionRatio records size of the source segment relative to the
+// total size of its renditions transcoded with the profiles
+func TranscodeCompressionRatio(profiles string, sourceBytes, outputBytes int64) {
+	if sourceBytes <= 0 || outputBytes <= 0 {
+		return
+	}
+	ctx, err := tag.New(census.ctx, tag.Insert(census.kProfiles, profiles))
+	if err != nil {
+		glog.Error("Error creating context", err)
+		return
+	}
+	stats.Record(ctx, census.mCompressionRatio.M(float64(sourceBytes)/float64(outputBytes)))
+}
+
+// RenditionSizeVariance records the coefficient of variation, standard
+// deviation divided by mean, of the sizes of the renditions transcoded with
+// the profiles from single source segment
+func RenditionSizeVariance(profiles string, sizes []int64) {
+	if len(sizes) < 2 {
+		return
+	}
+	var sum float64
+	for _, size := range sizes {
+		sum += float64(size)
+	}
+	mean := sum / float64(len(sizes))
+	if mean <= 0 {
+		return
+	}
+	var sqDiffs float64
+	for _, size := range sizes {
+		sqDiffs += (float64(size) - mean) * (float64(size) - mean)
+	}
+	cv := math.Sqrt(sqDiffs/float64(len(sizes))) / mean
+	ctx, err := tag.New(census.ctx, tag.Insert(census.kProfiles, profiles))
+	if err != nil {
+		glog.Error("Error creating context", err)
+		return
+	}
+	stats.Record(ctx, census.mRenditionSizeVariance.M(cv))
+}
+
+// ProfileBitrateCapped records a rendition encoded at the profile's bitrate ceiling
+func ProfileBitrateCapped(profile string) {
+	ctx, err := tag.New(census.ctx, tag.Insert(census.kProfile, profile))
+	if err != nil {
+		glog.Error("Error creating context", err)
+		return
+	}
+	stats.Record(ctx, census.mProfileBitrateCapped.M(1))
+}
+
+// UploadQueueDepth records the number of source segments of the stream waiting
+// to be uploaded
+func UploadQueueDepth(nonce uint64, depth int) {
+	census.lock.Lock()
+	defer census.lock.Unlock()
+	sc, ok := census.serveCounts[nonce]
+	if !ok {
+		return
+	}
+	census.sendStreamGauge(sc.manifestID, census.mUploadQueueDepth.M(int64(depth)))
+}
+
+// SegmenterMemory records estimated memory held by the stream's segmenter and buffers
+func SegmenterMemory(nonce uint64, bytes int64) {
+	census.lock.Lock()
+	defer census.lock.Unlock()
+	sc, ok := census.serveCounts[nonce]
+	if !ok {
+		return
+	}
+	census.sendStreamGauge(sc.manifestID, census.mSegmenterMemory.M(bytes))
+}
+
+// OrchestratorCooldownStarted records orchestrator suspended by the stream
+// after failure. Orchestrators suspended by multiple streams are counted once.
+func OrchestratorCooldownStarted(orch string) {
+	census.lock.Lock()
+	defer census.lock.Unlock()
+	census.orchCooldowns[orch]++
+	stats.Record(census.ctx, census.mOrchsInCooldown.M(int64(len(census.orchCooldowns))))
+}
+
+// OrchestratorCooldownEnded records orchestrator no longer suspended by the
+// stream, and the time it was suspended for
+func OrchestratorCooldownEnded(orch string, dur time.Duration) {
+	census.lock.Lock()
+	defer census.lock.Unlock()
+	if census.orchCooldowns[orch] <= 1 {
+		delete(census.orchCooldowns, orch)
+	} else {
+		census.orchCooldowns[orch]--
+	}
+	stats.Record(census.ctx, census.mOrchsInCooldown.M(int64(len(census.orchCooldowns))), census.mCooldownDuration.M(dur.Seconds()))
+}
+
+// HealthyOrchestrators records the number of orchestrators in the pool not
+// left out for failing info requests
+func HealthyOrchestrators(count int) {
+	stats.Record(census.ctx, census.mHealthyOrchs.M(int64(count)))
+}
+
+// OrchestratorInvalidURI records orchestrator left out of the pool because its
+// service URI could not be parsed
+func OrchestratorInvalidURI() {
+	stats.Record(census.ctx, census.mOrchInvalidURI.M(1))
+}
+
+// OrchestratorSchemeDowngrade records orchestrator reached over http after its
+// info request over https failed
+func OrchestratorSchemeDowngrade(orch string) {
+	ctx, err := tag.New(census.ctx, tag.Insert(census.kOrchestrator, orch))
+	if err != nil {
+		glog.Error("Error creating context", err)
+		return
+	}
+	stats.Record(ctx, census.mOrchSchemeDowngrade.M(1))
+}
+
+// OrchestratorInfoAge records how old the cached info of the selected
+// orchestrator was
+func OrchestratorInfoAge(age time.Duration) {
+	stats.Record(census.ctx, census.mOrchInfoAge.M(age.Seconds()))
+}
+
+// OrchestratorUsed records orchestrator that transcoded segment of the stream
+func OrchestratorUsed(nonce uint64, orch string) {
+	census.lock.Lock()
+	defer census.lock.Unlock()
+	if orchs, ok := census.streamOrchs[nonce]; ok {
+		orchs[orch] = true
+	}
+	census.orchestratorSelected(orch, time.Now())
+}
+
+// orchestratorSelected counts the segment transcoded by the orchestrator and
+// records the Gini coefficient of the counts within the selection window
+func (cen *censusMetricsCounter) orchestratorSelected(orch string, now time.Time) {
+	i := 0
+	for i < len(cen.orchSelections) && now.Sub(cen.orchSelections[i].start) >= OrchestratorSelectionWindow {
+		i++
+	}
+	cen.orchSelections = cen.orchSelections[i:]
+	n := len(cen.orchSelections)
+	if n == 0 || now.Sub(cen.orchSelections[n-1].start) >= OrchestratorSelectionWindow/orchSelectionBuckets {
+		cen.orchSelections = append(cen.orchSelections, &orchSelectionBucket{start: now, counts: make(map[string]int)})
+	}
+	cen.orchSelections[len(cen.orchSelections)-1].counts[orch]++
+
+	counts := make(map[string]int)
+	for _, b := range cen.orchSelections {
+		for o, c := range b.counts {
+			counts[o] += c
+		}
+	}
+	values := make([]int, 0, len(counts))
+	for _, c := range counts {
+		values = append(values, c)
+	}
+	stats.Record(cen.ctx, cen.mOrchSelectionGini.M(gini(values)))
+}
+
+// gini returns the Gini coefficient of the values, 0 if they are all equal and
+// approaching 1 as they concentrate on single value
+func gini(values []int) float64 {
+	sort.Ints(values)
+	var sum, weighted float64
+	for i, v := range values {
+		sum += float64(v)
+		weighted += float64(i+1) * float64(v)
+	}
+	if sum == 0 {
+		return 0
+	}
+	n := float64(len(values))
+	return 2*weighted/(n*sum) - (n+1)/n
+}
+
+// SegmentServed records segment of the stream served to HLS viewer
+func SegmentServed(nonce uint64) {
+	census.lock.Lock()
+	defer census.lock.Unlock()
+	if sc, ok := census.serveCounts[nonce]; ok {
+		sc.served++
+		census.sendServeRatio(sc)
+	}
+}
+
+// PlaylistRequested records media playlist of the stream requested by HLS viewer
+func PlaylistRequested(nonce uint64) {
+	census.lock.Lock()
+	defer census.lock.Unlock()
+	sc, ok := census.serveCounts[nonce]
+	if !ok {
+		return
+	}
+	census.sendStreamCounter(sc.manifestID, census.mPlaylistRequests)
+}
+
+// reregisterViews drops all the rows of the views of the measure, returns
+// false if the views couldn't be registered again
+func (cen *censusMetricsCounter) reregisterViews(m stats.Measure) bool {
+	for _, v := range cen.views {
+		if v.Measure != m {
+			continue
+		}
+		view.Unregister(v)
+		if err := view.Register(v); err != nil {
+			glog.Errorf("Error re-registering view %s: %v", v.Name, err)
+			return false
+		}
+	}
+	return true
+}
+
+func (cen *censusMetricsCounter) sendServeRatio(sc *serveCount) {
+	if sc.transcoded == 0 {
+		return
+	}
+	cen.sendStreamGauge(sc.manifestID, cen.mSegmentServeRatio.M(float64(sc.served)/float64(sc.transcoded)))
+}
+
+// sendStreamGauge records value of the gauge tagged with the stream's
+// manifestID, the value is kept until removeStreamGauge is called for the stream
+func (cen *censusMetricsCounter) sendStreamGauge(manifestID string, m stats.Measurement) {
+	cen.sendStreamSeries(manifestID, m, m)
+}
+
+// sendStreamCounter increments the counter tagged with the stream's manifestID
+// and keeps its total like sendStreamGauge keeps the value. The view of the
+// counter should sum the measurements, so that recording the total again
+// restores the series.
+func (cen *censusMetricsCounter) sendStreamCounter(manifestID string, measure *stats.Int64Measure) {
+	var total int64
+	if m, ok := cen.streamGauges[measure][manifestID]; ok {
+		total = int64(m.Value())
+	}
+	cen.sendStreamSeries(manifestID, measure.M(1), measure.M(total+1))
+}
+
+// streamActive returns whether stream of the manifestID was created and hasn't
+// ended yet
+func (cen *censusMetricsCounter) streamActive(manifestID string) bool {
+	for _, sc := range cen.serveCounts {
+		if sc.manifestID == manifestID {
+			return true
+		}
+	}
+	return false
+}
+
+func (cen *censusMetricsCounter) sendStreamSeries(manifestID string, m, kept stats.Measurement) {
+	ctx, err := tag.New(cen.ctx, tag.Insert(cen.kManifestID, manifestID))
+	if err != nil {
+		glog.Error("Error creating context", err)
+		return
+	}
+	values, ok := cen.streamGauges[m.Measure()]
+	if !ok {
+		values = make(map[string]stats.Measurement)
+		cen.streamGauges[m.Measure()] = values
+	}
+	values[manifestID] = kept
+	stats.Record(ctx, m)
+}
+
+// removeStreamGauge drops series of the stream from the gauge or counter
+// recorded with sendStreamGauge or sendStreamCounter. OpenCensus can't delete
+// a single row, so the view is re-registered and the last values or totals of
+// the other streams recorded again.
+func (cen *censusMetricsCounter) removeStreamGauge(measure stats.Measure, manifestID string) {
+	values := cen.streamGauges[measure]
+	if _, ok := values[manifestID]; !ok {
+		return
+	}
+	delete(values, manifestID)
+	if !cen.reregisterViews(measure) {
+		return
+	}
+	for mid, m := range values {
+		cen.sendStreamGauge(mid, m)
+	}
 }
 
 func TranscodedSegmentAppeared(nonce, seqNo uint64, profile string) {
@@ -971,7 +2621,7 @@ func (cen *censusMetricsCounter) segmentTranscodedAppeared(nonce, seqNo uint64,
 	if st, ok := cen.emergeTimes[nonce][seqNo]; ok {
 		latency := time.Since(st)
 		glog.V(logLevel).Infof("Recording latency for segment nonce=%d seqNo=%d profile=%s latency=%s", nonce, seqNo, profile, latency)
-		stats.Record(ctx, cen.mTranscodeLatency.M(float64(latency/time.Second)))
+		recordWithExemplar(ctx, cen.mTranscodeLatency.M(float64(latency/time.Second)), nonce, seqNo)
 	}
 
 	stats.Record(ctx, cen.mSegmentTranscodedAppeared.M(1))
@@ -997,14 +2647,89 @@ func newAverager() *segmentsAverager {
 
 func StreamCreated(hlsStrmID string, nonce uint64) {
 	glog.V(logLevel).Infof("Logging StreamCreated... nonce=%d strid=%s", nonce, hlsStrmID)
-	census.streamCreated(nonce)
+	census.streamCreated(hlsStrmID, nonce)
 }
 
-func (cen *censusMetricsCounter) streamCreated(nonce uint64) {
+func (cen *censusMetricsCounter) streamCreated(hlsStrmID string, nonce uint64) {
 	cen.lock.Lock()
 	defer cen.lock.Unlock()
 	stats.Record(cen.ctx, cen.mStreamCreated.M(1))
 	cen.success[nonce] = newAverager()
+	cen.serveCounts[nonce] = &serveCount{manifestID: hlsStrmID}
+	cen.streamOrchs[nonce] = make(map[string]bool)
+}
+
+// TranscodeFramesDropped records frames dropped by the transcoder in the output
+// of the profile
+func TranscodeFramesDropped(profile string, dropped int) {
+	ctx, err := tag.New(census.ctx, tag.Insert(census.kProfile, profile))
+	if err != nil {
+		glog.Error("Error creating context", err)
+		return
+	}
+	stats.Record(ctx, census.mTranscodeFramesDropped.M(int64(dropped)))
+}
+
+// StorageCacheRequested records read from the storage cache
+func StorageCacheRequested(hit bool) {
+	outcome := StorageCacheMiss
+	if hit {
+		outcome = StorageCacheHit
+	}
+	ctx, err := tag.New(census.ctx, tag.Insert(census.kOutcome, outcome))
+	if err != nil {
+		glog.Error("Error creating context", err)
+		return
+	}
+	stats.Record(ctx, census.mStorageCacheRequests.M(1))
+}
+
+// StorageCacheEvicted records objects evicted from the storage cache
+func StorageCacheEvicted(count int) {
+	stats.Record(census.ctx, census.mStorageCacheEvictions.M(int64(count)))
+}
+
+// SegmentFetches records the number of segments being fetched from the remote
+// storage and the number of fetches waiting for a free slot
+func SegmentFetches(inFlight, queued int) {
+	stats.Record(census.ctx, census.mSegmentFetchesInFlight.M(int64(inFlight)), census.mSegmentFetchesQueued.M(int64(queued)))
+}
+
+// StreamRejectedTenantLimit records stream rejected because the tenant is over
+// the limit. Reason is either TenantLimitConcurrent or TenantLimitRate
+func StreamRejectedTenantLimit(tenant, reason string) {
+	ctx, err := tag.New(census.ctx, tag.Insert(census.kTenant, tenant), tag.Insert(census.kReason, reason))
+	if err != nil {
+		glog.Error("Error creating context", err)
+		return
+	}
+	stats.Record(ctx, census.mStreamsRejectedTenantLimit.M(1))
+}
+
+// SourceResolution records the stream with the source of the resolution
+// (WxH). Resolutions beyond the first MaxSourceResolutions distinct ones are
+// recorded as SourceResolutionOther
+func SourceResolution(resolution string) {
+	census.sourceResolution(resolution)
+}
+
+func (cen *censusMetricsCounter) sourceResolution(resolution string) {
+	cen.lock.Lock()
+	if !cen.sourceResolutions[resolution] {
+		if len(cen.sourceResolutions) < MaxSourceResolutions {
+			cen.sourceResolutions[resolution] = true
+		} else {
+			resolution = SourceResolutionOther
+		}
+	}
+	cen.lock.Unlock()
+
+	ctx, err := tag.New(cen.ctx, tag.Insert(cen.kResolution, resolution))
+	if err != nil {
+		glog.Error("Error creating context", err)
+		return
+	}
+	stats.Record(ctx, cen.mSourceResolutions.M(1))
 }
 
 func StreamStarted(nonce uint64) {
@@ -1018,6 +2743,22 @@ func (cen *censusMetricsCounter) streamStarted(nonce uint64) {
 	stats.Record(cen.ctx, cen.mStreamStarted.M(1))
 }
 
+// StreamKeyRotated records the stream published again with a new stream key
+// and continued under it
+func StreamKeyRotated(nonce uint64) {
+	glog.V(logLevel).Infof("Logging StreamKeyRotated... nonce=%d", nonce)
+	census.lock.Lock()
+	defer census.lock.Unlock()
+	stats.Record(census.ctx, census.mStreamKeyRotated.M(1))
+}
+
+// StreamWarmup records how long the stream was buffering before it was
+// served to viewers
+func StreamWarmup(nonce uint64, dur time.Duration) {
+	glog.V(logLevel).Infof("Logging StreamWarmup... nonce=%d dur=%v", nonce, dur)
+	stats.Record(census.ctx, census.mStreamWarmup.M(dur.Seconds()))
+}
+
 func StreamEnded(nonce uint64) {
 	glog.V(logLevel).Infof("Logging StreamEnded... nonce=%d", nonce)
 	census.streamEnded(nonce)
@@ -1028,6 +2769,27 @@ func (cen *censusMetricsCounter) streamEnded(nonce uint64) {
 	defer cen.lock.Unlock()
 	stats.Record(cen.ctx, cen.mStreamEnded.M(1))
 	delete(cen.emergeTimes, nonce)
+	if sc, has := cen.serveCounts[nonce]; has {
+		delete(cen.serveCounts, nonce)
+		// stream_cost_gwei is kept for StreamCostRetention
+		for measure := range cen.streamGauges {
+			if measure != cen.mStreamCost {
+				cen.removeStreamGauge(measure, sc.manifestID)
+			}
+		}
+		if cost, ok := cen.streamCosts[sc.manifestID]; ok {
+			cost.endedAt = time.Now()
+		}
+	}
+	// streams that weren't transcoded aren't recorded
+	if orchs := cen.streamOrchs[nonce]; len(orchs) > 0 {
+		stats.Record(cen.ctx, cen.mDistinctOrchestrators.M(int64(len(orchs))))
+	}
+	delete(cen.streamOrchs, nonce)
+	if _, has := cen.queuedSegments[nonce]; has {
+		delete(cen.queuedSegments, nonce)
+		cen.sendQueuedSegments()
+	}
 	if avg, has := cen.success[nonce]; has {
 		if avg.canBeRemoved() {
 			delete(cen.success, nonce)
@@ -1054,6 +2816,38 @@ func TicketValueSent(recipient string, manifestID string, value *big.Rat) {
 	}
 
 	stats.Record(ctx, census.mTicketValueSent.M(fracwei2gwei(value)))
+
+	cost, ok := census.streamCosts[manifestID]
+	if !ok {
+		cost = &streamCost{}
+		census.streamCosts[manifestID] = cost
+	}
+	cost.gwei += fracwei2gwei(value)
+	cost.endedAt = time.Time{}
+	census.sendStreamGauge(manifestID, census.mStreamCost.M(cost.gwei))
+}
+
+// StreamCosts returns total value of tickets sent per manifestID, in gwei, for
+// active streams and streams ended less than StreamCostRetention ago
+func StreamCosts() map[string]float64 {
+	census.lock.Lock()
+	defer census.lock.Unlock()
+	costs := make(map[string]float64, len(census.streamCosts))
+	for mid, cost := range census.streamCosts {
+		costs[mid] = cost.gwei
+	}
+	return costs
+}
+
+// removeStreamCosts drops costs of the streams ended more than
+// StreamCostRetention ago, along with their stream_cost_gwei series
+func (cen *censusMetricsCounter) removeStreamCosts(now time.Time) {
+	for mid, cost := range cen.streamCosts {
+		if !cost.endedAt.IsZero() && now.Sub(cost.endedAt) > StreamCostRetention {
+			delete(cen.streamCosts, mid)
+			cen.removeStreamGauge(cen.mStreamCost, mid)
+		}
+	}
 }
 
 // TicketsSent records the number of tickets sent to a recipient for a manifestID
@@ -1095,6 +2889,16 @@ func Reserve(sender string, reserve *big.Int) {
 	stats.Record(census.ctx, census.mReserve.M(wei2gwei(reserve)))
 }
 
+// AutoTopUp records the automatic top-up of the broadcaster deposit
+func AutoTopUp() {
+	stats.Record(census.ctx, census.mAutoTopUp.M(1))
+}
+
+// AutoTopUpError records the failed automatic top-up of the broadcaster deposit
+func AutoTopUpError() {
+	stats.Record(census.ctx, census.mAutoTopUpError.M(1))
+}
+
 // TicketValueRecv records the ticket value received from a sender for a manifestID
 func TicketValueRecv(sender string, manifestID string, value *big.Rat) {
 	census.lock.Lock()
@@ -1129,21 +2933,27 @@ func TicketsRecv(sender string, manifestID string, numTickets int) {
 	stats.Record(ctx, census.mTicketsRecv.M(int64(numTickets)))
 }
 
-// PaymentRecvError records an error from receiving a payment
-func PaymentRecvError(sender string, manifestID string, errStr string) {
+// paymentErrorCodes are the error codes of payment_recv_errors by the
+// validation stage. Tickets of other face value, win prob or price per pixel
+// than advertised fail the recipientRand check, so they are counted as
+// InvalidRecipientRand rather than by codes of their own.
+var paymentErrorCodes = map[string]string{
+	PaymentValidationRecipient:     "InvalidTicketRecipient",
+	PaymentValidationSender:        "InvalidTicketSender",
+	PaymentValidationSignature:     "InvalidTicketSignature",
+	PaymentValidationRecipientRand: "InvalidRecipientRand",
+	PaymentValidationPrice:         "InvalidPrice",
+}
+
+// PaymentRecvError records an error from receiving a payment. Stage is the
+// failed validation stage, one of the PaymentValidation* constants, or empty
+// if the payment failed for other reasons.
+func PaymentRecvError(sender string, manifestID string, stage string) {
 	census.lock.Lock()
 	defer census.lock.Unlock()
 
-	var errCode string
-	if strings.Contains(errStr, "Expected price") {
-		errCode = "InvalidPrice"
-	} else if strings.Contains(errStr, "invalid already revealed recipientRand") {
-		errCode = "InvalidRecipientRand"
-	} else if strings.Contains(errStr, "invalid ticket faceValue") {
-		errCode = "InvalidTicketFaceValue"
-	} else if strings.Contains(errStr, "invalid ticket winProb") {
-		errCode = "InvalidTicketWinProb"
-	} else {
+	errCode, ok := paymentErrorCodes[stage]
+	if !ok {
 		errCode = "PaymentError"
 	}
 
@@ -1158,6 +2968,28 @@ func PaymentRecvError(sender string, manifestID string, errStr string) {
 	}
 
 	stats.Record(ctx, census.mPaymentRecvErr.M(1))
+
+	if stage == "" {
+		return
+	}
+	ctx, err = tag.New(census.ctx, tag.Insert(census.kStage, stage))
+	if err != nil {
+		glog.Fatal(err)
+	}
+	stats.Record(ctx, census.mPaymentValidationErr.M(1))
+}
+
+// RecipientRandReused records ticket received with already revealed
+// recipientRand
+func RecipientRandReused(sender string) {
+	census.lock.Lock()
+	defer census.lock.Unlock()
+
+	ctx, err := tag.New(census.ctx, tag.Insert(census.kSender, sender))
+	if err != nil {
+		glog.Fatal(err)
+	}
+	stats.Record(ctx, census.mRecipientRandReuse.M(1))
 }
 
 // WinningTicketsRecv records the number of winning tickets received from a sender
@@ -1207,6 +3039,16 @@ func TicketRedemptionError(sender string) {
 	stats.Record(ctx, census.mTicketRedemptionError.M(1))
 }
 
+// TicketRedemptionDeferred records a ticket redemption deferred due to high gas price
+func TicketRedemptionDeferred(sender string) {
+	ctx, err := tag.New(census.ctx, tag.Insert(census.kSender, sender))
+	if err != nil {
+		glog.Error("Error creating context", err)
+		return
+	}
+	stats.Record(ctx, census.mTicketRedemptionDefer.M(1))
+}
+
 // SuggestedGasPrice records the last suggested gas price
 func SuggestedGasPrice(gasPrice *big.Int) {
 	census.lock.Lock()
@@ -1215,6 +3057,14 @@ func SuggestedGasPrice(gasPrice *big.Int) {
 	stats.Record(census.ctx, census.mSuggestedGasPrice.M(wei2gwei(gasPrice)))
 }
 
+// SetGasPriceUSD records the cost of ticket redemption transaction in USD
+func SetGasPriceUSD(usd float64) {
+	census.lock.Lock()
+	defer census.lock.Unlock()
+
+	stats.Record(census.ctx, census.mGasPriceUSD.M(usd))
+}
+
 // TranscodingPrice records the last transcoding price
 func TranscodingPrice(sender string, price *big.Rat) {
 	census.lock.Lock()
@@ -2,22 +2,32 @@ package monitor
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/big"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opencensus.io/stats/view"
 )
 
+// initCensus initializes census for the test. Views can only be registered
+// once, so the ones left from the previous test are dropped first.
+func initCensus(labels map[string]string) {
+	view.Unregister(census.views...)
+	InitCensus("tst", "testid", "testversion", labels)
+}
+
 func TestAveragerCanBeRemoved(t *testing.T) {
 	a1 := newAverager()
 	if !a1.canBeRemoved() {
 		t.Fatal("Should be able to remove empty buffer")
 	}
-	a1.addEmerged(1)
+	a1.addEmerged(1, 0)
 	time.Sleep(time.Millisecond)
-	a1.addEmerged(2)
+	a1.addEmerged(2, 0)
 	rate, has := a1.successRate()
 	if rate != 1 {
 		t.Fatalf("Rate should be 1, got %v", rate)
@@ -34,20 +44,20 @@ func TestAveragerCanBeRemoved(t *testing.T) {
 		t.Fatal("Should be able to remove buffer with all transcoded segments")
 	}
 	a2 := newAverager()
-	a2.addEmerged(1)
-	old := timeToWaitForError
-	timeToWaitForError = time.Millisecond
+	a2.addEmerged(1, 0)
+	old := segmentTimeout()
+	SetSegmentTimeout(time.Millisecond)
 	time.Sleep(10 * time.Millisecond)
 	if !a2.canBeRemoved() {
 		t.Fatal("Should be able to remove buffer with timeouted segments")
 	}
-	timeToWaitForError = old
+	SetSegmentTimeout(old)
 }
 
 func TestLastSegmentTimeout(t *testing.T) {
 	unitTestMode = true
 	defer func() { unitTestMode = false }()
-	InitCensus("tst", "testid", "testversion")
+	initCensus(nil)
 	// defer func() {
 	// 	shutDown <- nil
 	// }()
@@ -55,7 +65,7 @@ func TestLastSegmentTimeout(t *testing.T) {
 	if len(census.success) != 1 {
 		t.Fatal("Should be one stream")
 	}
-	SegmentEmerged(1, 1, 3)
+	SegmentEmerged(1, 1, 3, 0)
 	if sr := census.successRate(); sr != 1 {
 		t.Fatalf("Success rate should be 1, not %f", sr)
 	}
@@ -63,14 +73,14 @@ func TestLastSegmentTimeout(t *testing.T) {
 	if sr := census.successRate(); sr != 1 {
 		t.Fatalf("Success rate should be 1, not %f", sr)
 	}
-	SegmentEmerged(1, 2, 3)
+	SegmentEmerged(1, 2, 3, 0)
 	SegmentTranscodeFailed(SegmentTranscodeErrorOrchestratorBusy, 1, 2, fmt.Errorf("some"), true)
 	if sr := census.successRate(); sr != 0.5 {
 		t.Fatalf("Success rate should be 0.5, not %f", sr)
 	}
-	SegmentEmerged(1, 3, 3)
+	SegmentEmerged(1, 3, 3, 0)
 	SegmentTranscodeFailed(SegmentTranscodeErrorSessionEnded, 1, 3, fmt.Errorf("some"), true)
-	SegmentEmerged(1, 4, 3)
+	SegmentEmerged(1, 4, 3, 0)
 	SegmentFullyTranscoded(1, 4, "ps", "")
 	if sr := census.successRate(); sr != 0.75 {
 		t.Fatalf("Success rate should be 0.75, not %f", sr)
@@ -81,9 +91,9 @@ func TestLastSegmentTimeout(t *testing.T) {
 	}
 
 	StreamCreated("h1", 2)
-	SegmentEmerged(2, 1, 3)
+	SegmentEmerged(2, 1, 3, 0)
 	SegmentFullyTranscoded(2, 1, "ps", "")
-	SegmentEmerged(2, 2, 3)
+	SegmentEmerged(2, 2, 3, 0)
 	StreamEnded(2)
 	if len(census.success) != 1 {
 		t.Fatalf("Should be one stream, instead have %d", len(census.success))
@@ -91,8 +101,8 @@ func TestLastSegmentTimeout(t *testing.T) {
 	if sr := census.successRate(); sr != 1 {
 		t.Fatalf("Success rate should be 1, not %f", sr)
 	}
-	old1 := timeToWaitForError
-	timeToWaitForError = time.Nanosecond
+	old1 := segmentTimeout()
+	SetSegmentTimeout(time.Nanosecond)
 	if sr := census.successRate(); sr != 0.5 {
 		t.Fatalf("Success rate should be 0.5, not %f", sr)
 	}
@@ -104,12 +114,12 @@ func TestLastSegmentTimeout(t *testing.T) {
 	if sr := census.successRate(); sr != 1 {
 		t.Fatalf("Success rate should be 1, not %f", sr)
 	}
-	timeToWaitForError = old1
+	SetSegmentTimeout(old1)
 
 	StreamCreated("h3", 3)
-	SegmentEmerged(3, 1, 3)
+	SegmentEmerged(3, 1, 3, 0)
 	SegmentFullyTranscoded(3, 1, "ps", "")
-	SegmentEmerged(3, 2, 3)
+	SegmentEmerged(3, 2, 3, 0)
 	StreamEnded(3)
 	if len(census.success) != 1 {
 		t.Fatalf("Should be one stream, instead have %d", len(census.success))
@@ -172,3 +182,1862 @@ func TestFracWei2Gwei(t *testing.T) {
 	wei = big.NewRat(gweiConversionFactor*2, 7)
 	assert.InDelta(.285714286, fracwei2gwei(wei), delta)
 }
+
+func TestTranscodingPriceDistribution(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	unitTestMode = true
+	defer func() { unitTestMode = false }()
+	initCensus(nil)
+
+	TranscodingPrice("sender", big.NewRat(5, 1))
+	TranscodingPrice("sender", big.NewRat(1500, 1))
+	TranscodingPrice("sender", big.NewRat(3001, 3))
+
+	rows, err := view.RetrieveData("transcoding_price_distribution")
+	require.Nil(err)
+	require.Len(rows, 1)
+	dist, ok := rows[0].Data.(*view.DistributionData)
+	require.True(ok)
+	assert.Equal(int64(3), dist.Count)
+	assert.Equal(5.0, dist.Min)
+	assert.Equal(1500.0, dist.Max)
+
+	// LastValue view is kept
+	rows, err = view.RetrieveData("transcoding_price")
+	require.Nil(err)
+	require.Len(rows, 1)
+	assert.InDelta(1000.33, rows[0].Data.(*view.LastValueData).Value, 0.01)
+}
+
+func TestDiscoveryCacheLookup(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	unitTestMode = true
+	defer func() { unitTestMode = false }()
+	initCensus(nil)
+
+	count := func(name string) int64 {
+		rows, err := view.RetrieveData(name)
+		require.Nil(err)
+		if len(rows) == 0 {
+			return 0
+		}
+		return rows[0].Data.(*view.CountData).Value
+	}
+
+	hitRate := func() float64 {
+		rows, err := view.RetrieveData("discovery_cache_hit_rate")
+		require.Nil(err)
+		require.Len(rows, 1)
+		return rows[0].Data.(*view.LastValueData).Value
+	}
+
+	DiscoveryCacheLookup(false)
+	assert.Equal(int64(0), count("discovery_cache_hits_total"))
+	assert.Equal(int64(1), count("discovery_cache_misses_total"))
+	assert.Equal(0.0, hitRate())
+	DiscoveryCacheLookup(true)
+	DiscoveryCacheLookup(true)
+	DiscoveryCacheLookup(true)
+	assert.Equal(int64(3), count("discovery_cache_hits_total"))
+	assert.Equal(int64(1), count("discovery_cache_misses_total"))
+	assert.Equal(0.75, hitRate())
+
+	// rate starts over with the counters
+	ResetCounters()
+	DiscoveryCacheLookup(true)
+	assert.Equal(1.0, hitRate())
+}
+
+func TestQueuedSegments(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	unitTestMode = true
+	defer func() { unitTestMode = false }()
+	initCensus(nil)
+
+	queued := func() float64 {
+		rows, err := view.RetrieveData("queued_segments")
+		require.Nil(err)
+		require.Len(rows, 1)
+		require.Len(rows[0].Tags, 1)
+		assert.Equal("node_id", rows[0].Tags[0].Key.Name())
+		return rows[0].Data.(*view.LastValueData).Value
+	}
+
+	StreamCreated("h1", 1)
+	StreamCreated("h2", 2)
+	SetQueuedSegments(1, 2)
+	assert.Equal(2.0, queued())
+	SetQueuedSegments(2, 3)
+	assert.Equal(5.0, queued())
+	SetQueuedSegments(1, 1)
+	assert.Equal(4.0, queued())
+
+	// ended stream doesn't count anymore
+	StreamEnded(2)
+	assert.Equal(1.0, queued())
+	StreamEnded(1)
+	assert.Equal(0.0, queued())
+	assert.Len(census.queuedSegments, 0)
+}
+
+func TestProfileBitrateCapped(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	unitTestMode = true
+	defer func() { unitTestMode = false }()
+	initCensus(nil)
+
+	ProfileBitrateCapped("P720p30fps16x9")
+	ProfileBitrateCapped("P720p30fps16x9")
+	ProfileBitrateCapped("P360p30fps16x9")
+
+	rows, err := view.RetrieveData("profile_bitrate_capped_total")
+	require.Nil(err)
+	require.Len(rows, 2)
+	counts := make(map[string]int64)
+	for _, r := range rows {
+		for _, tg := range r.Tags {
+			if tg.Key == census.kProfile {
+				counts[tg.Value] = r.Data.(*view.CountData).Value
+			}
+		}
+	}
+	assert.Equal(map[string]int64{"P720p30fps16x9": 2, "P360p30fps16x9": 1}, counts)
+}
+
+func TestTranscodeLatencyExemplars(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	unitTestMode = true
+	defer func() { unitTestMode = false }()
+	initCensus(nil)
+
+	StreamCreated("h", 5)
+	SegmentEmerged(5, 11, 1, 0)
+	TranscodedSegmentAppeared(5, 11, "P240p30fps16x9")
+	SegmentFullyTranscoded(5, 11, "P240p30fps16x9", "")
+
+	for _, name := range []string{"transcode_latency_seconds", "transcode_overall_latency_seconds"} {
+		rows, err := view.RetrieveData(name)
+		require.Nil(err)
+		require.Len(rows, 1)
+		dist := rows[0].Data.(*view.DistributionData)
+		var found bool
+		for _, e := range dist.ExemplarsPerBucket {
+			if e == nil {
+				continue
+			}
+			found = true
+			assert.Equal(uint64(5), e.Attachments["nonce"])
+			assert.Equal(uint64(11), e.Attachments["seqNo"])
+		}
+		assert.True(found, "no exemplar for view=%s", name)
+	}
+}
+
+func TestCurrentValueAccessors(t *testing.T) {
+	assert := assert.New(t)
+	unitTestMode = true
+	defer func() { unitTestMode = false }()
+	initCensus(nil)
+
+	assert.Equal(1.0, CurrentSuccessRate())
+	assert.Equal(0, CurrentSessionsCount())
+	assert.Equal(0.0, AverageTranscodeTime())
+
+	CurrentSessions(3)
+	assert.Equal(3, CurrentSessionsCount())
+	CurrentSessions(2)
+	assert.Equal(2, CurrentSessionsCount())
+
+	SegmentTranscoded(1, 1, time.Second, "ps", "")
+	assert.Equal(1.0, AverageTranscodeTime())
+	SegmentTranscoded(1, 2, 2*time.Second, "ps", "")
+	assert.InDelta(1.1, AverageTranscodeTime(), 0.0001)
+	// older segments weigh less
+	for i := 0; i < 50; i++ {
+		SegmentTranscoded(1, uint64(3+i), 3*time.Second, "ps", "")
+	}
+	assert.InDelta(3.0, AverageTranscodeTime(), 0.01)
+
+	StreamCreated("h1", 1)
+	SegmentEmerged(1, 1, 3, 0)
+	SegmentFullyTranscoded(1, 1, "ps", "")
+	SegmentEmerged(1, 2, 3, 0)
+	SegmentTranscodeFailed(SegmentTranscodeErrorOrchestratorBusy, 1, 2, fmt.Errorf("some"), true)
+	assert.Equal(0.5, CurrentSuccessRate())
+}
+
+func TestRecipientRandReuse(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	unitTestMode = true
+	defer func() { unitTestMode = false }()
+	initCensus(nil)
+
+	RecipientRandReused("sender1")
+	RecipientRandReused("sender1")
+	RecipientRandReused("sender2")
+
+	rows, err := view.RetrieveData("recipient_rand_reuse_total")
+	require.Nil(err)
+	require.Len(rows, 2)
+	counts := make(map[string]float64)
+	for _, r := range rows {
+		for _, tg := range r.Tags {
+			if tg.Key == census.kSender {
+				counts[tg.Value] = r.Data.(*view.SumData).Value
+			}
+		}
+	}
+	assert.Equal(map[string]float64{"sender1": 2, "sender2": 1}, counts)
+}
+
+func TestPaymentRecvError(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	unitTestMode = true
+	defer func() { unitTestMode = false }()
+	initCensus(nil)
+
+	PaymentRecvError("sender1", "mid", PaymentValidationRecipientRand)
+	PaymentRecvError("sender1", "mid", PaymentValidationRecipientRand)
+	PaymentRecvError("sender2", "mid", PaymentValidationSignature)
+	PaymentRecvError("sender2", "mid", PaymentValidationRecipient)
+	PaymentRecvError("sender2", "mid", PaymentValidationSender)
+	PaymentRecvError("sender3", "mid", PaymentValidationPrice)
+	// not a validation error
+	PaymentRecvError("sender3", "mid", "")
+
+	rows, err := view.RetrieveData("payment_recv_errors")
+	require.Nil(err)
+	codes := make(map[string]float64)
+	for _, r := range rows {
+		for _, tg := range r.Tags {
+			if tg.Key == census.kErrorCode {
+				codes[tg.Value] += r.Data.(*view.SumData).Value
+			}
+		}
+	}
+	assert.Equal(map[string]float64{
+		"InvalidRecipientRand":   2,
+		"InvalidTicketSignature": 1,
+		"InvalidTicketRecipient": 1,
+		"InvalidTicketSender":    1,
+		"InvalidPrice":           1,
+		"PaymentError":           1,
+	}, codes)
+
+	rows, err = view.RetrieveData("payment_validation_errors_total")
+	require.Nil(err)
+	stages := make(map[string]float64)
+	for _, r := range rows {
+		for _, tg := range r.Tags {
+			if tg.Key == census.kStage {
+				stages[tg.Value] = r.Data.(*view.SumData).Value
+			}
+		}
+	}
+	assert.Equal(map[string]float64{
+		PaymentValidationRecipientRand: 2,
+		PaymentValidationSignature:     1,
+		PaymentValidationRecipient:     1,
+		PaymentValidationSender:        1,
+		PaymentValidationPrice:         1,
+	}, stages)
+}
+
+func TestRecordSegmentBytes(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	unitTestMode = true
+	defer func() { unitTestMode = false }()
+	initCensus(nil)
+
+	RecordSegmentBytes(SegmentBytesUpload, 1000000)
+	RecordSegmentBytes(SegmentBytesUpload, 3000000)
+	RecordSegmentBytes(SegmentBytesDownload, 500000)
+
+	rows, err := view.RetrieveData("segment_bytes")
+	require.Nil(err)
+	require.Len(rows, 2)
+	dists := make(map[string]*view.DistributionData)
+	for _, r := range rows {
+		for _, tg := range r.Tags {
+			if tg.Key == census.kDirection {
+				dists[tg.Value] = r.Data.(*view.DistributionData)
+			}
+		}
+	}
+	require.Contains(dists, SegmentBytesUpload)
+	require.Contains(dists, SegmentBytesDownload)
+	assert.Equal(int64(2), dists[SegmentBytesUpload].Count)
+	assert.Equal(2000000.0, dists[SegmentBytesUpload].Mean)
+	assert.Equal(int64(1), dists[SegmentBytesDownload].Count)
+	assert.Equal(500000.0, dists[SegmentBytesDownload].Max)
+}
+
+func TestSegmentTranscodedGPUTag(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	unitTestMode = true
+	defer func() { unitTestMode = false }()
+	initCensus(nil)
+
+	SegmentTranscoded(1, 1, time.Second, "ps", "0")
+	SegmentTranscoded(1, 2, time.Second, "ps", "1")
+	SegmentTranscoded(1, 3, time.Second, "ps", "1")
+	// CPU-only node
+	SegmentTranscoded(1, 4, time.Second, "ps", "")
+
+	rows, err := view.RetrieveData("transcode_time_seconds")
+	require.Nil(err)
+	counts := make(map[string]int64)
+	for _, r := range rows {
+		for _, tg := range r.Tags {
+			if tg.Key == census.kGPU {
+				counts[tg.Value] = r.Data.(*view.DistributionData).Count
+			}
+		}
+	}
+	assert.Equal(map[string]int64{"0": 1, "1": 2, "cpu": 1}, counts)
+}
+
+func TestSetGasPriceUSD(t *testing.T) {
+	require := require.New(t)
+	unitTestMode = true
+	defer func() { unitTestMode = false }()
+	initCensus(nil)
+
+	SetGasPriceUSD(12.5)
+	SetGasPriceUSD(7.25)
+
+	rows, err := view.RetrieveData("gas_price_usd")
+	require.Nil(err)
+	require.Len(rows, 1)
+	assert.Equal(t, 7.25, rows[0].Data.(*view.LastValueData).Value)
+}
+
+func TestSegmentServeRatio(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	unitTestMode = true
+	defer func() { unitTestMode = false }()
+	initCensus(nil)
+
+	ratio := func(mid string) (float64, bool) {
+		rows, err := view.RetrieveData("segment_serve_ratio")
+		require.Nil(err)
+		for _, r := range rows {
+			for _, tg := range r.Tags {
+				if tg.Key == census.kManifestID && tg.Value == mid {
+					return r.Data.(*view.LastValueData).Value, true
+				}
+			}
+		}
+		return 0, false
+	}
+
+	StreamCreated("mid1", 1)
+	StreamCreated("mid2", 2)
+	// nothing transcoded yet
+	SegmentServed(1)
+	_, ok := ratio("mid1")
+	assert.False(ok)
+
+	SegmentEmerged(1, 1, 1, 0)
+	SegmentFullyTranscoded(1, 1, "ps", "")
+	SegmentEmerged(1, 2, 1, 0)
+	SegmentFullyTranscoded(1, 2, "ps", "")
+	// failed segments aren't counted as transcoded
+	SegmentEmerged(1, 3, 1, 0)
+	SegmentFullyTranscoded(1, 3, "ps", SegmentTranscodeErrorOrchestratorBusy)
+	r, ok := ratio("mid1")
+	assert.True(ok)
+	assert.Equal(0.5, r)
+	SegmentServed(1)
+	SegmentServed(1)
+	r, _ = ratio("mid1")
+	assert.Equal(1.5, r)
+
+	// stream without viewers
+	SegmentEmerged(2, 1, 1, 0)
+	SegmentFullyTranscoded(2, 1, "ps", "")
+	r, ok = ratio("mid2")
+	assert.True(ok)
+	assert.Equal(0.0, r)
+
+	// cleaned up on stream end
+	StreamEnded(1)
+	assert.NotContains(census.serveCounts, uint64(1))
+	assert.Contains(census.serveCounts, uint64(2))
+	SegmentServed(1)
+	_, ok = ratio("mid1")
+	assert.False(ok)
+	// series of the other streams are kept
+	r, ok = ratio("mid2")
+	assert.True(ok)
+	assert.Equal(0.0, r)
+}
+
+func TestSetTimeouts(t *testing.T) {
+	oldTimeout, oldInterval := segmentTimeout(), timeoutWatcherInterval()
+	defer func() {
+		SetSegmentTimeout(oldTimeout)
+		SetTimeoutWatcherInterval(oldInterval)
+	}()
+
+	SetSegmentTimeout(20 * time.Second)
+	if d := segmentTimeout(); d != 20*time.Second {
+		t.Fatalf("Segment timeout should be 20s, not %s", d)
+	}
+	SetTimeoutWatcherInterval(time.Second)
+	if d := timeoutWatcherInterval(); d != time.Second {
+		t.Fatalf("Watcher interval should be 1s, not %s", d)
+	}
+
+	// non-positive values are ignored
+	SetSegmentTimeout(0)
+	SetSegmentTimeout(-time.Second)
+	if d := segmentTimeout(); d != 20*time.Second {
+		t.Fatalf("Segment timeout should be 20s, not %s", d)
+	}
+	SetTimeoutWatcherInterval(0)
+	if d := timeoutWatcherInterval(); d != time.Second {
+		t.Fatalf("Watcher interval should be 1s, not %s", d)
+	}
+}
+
+func TestOrchestratorGRPCError(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	unitTestMode = true
+	defer func() { unitTestMode = false }()
+	initCensus(nil)
+
+	OrchestratorGRPCError("https://orch1:8935", true)
+	OrchestratorGRPCError("https://orch1:8935", false)
+	OrchestratorGRPCError("https://orch1:8935", false)
+	OrchestratorGRPCError("https://orch2:8935", true)
+
+	counts := func(name string) map[string]int64 {
+		rows, err := view.RetrieveData(name)
+		require.Nil(err)
+		res := make(map[string]int64)
+		for _, r := range rows {
+			for _, tg := range r.Tags {
+				if tg.Key == census.kOrchestrator {
+					res[tg.Value] = r.Data.(*view.CountData).Value
+				}
+			}
+		}
+		return res
+	}
+	assert.Equal(map[string]int64{"https://orch1:8935": 1, "https://orch2:8935": 1}, counts("orchestrator_grpc_stream_errors_total"))
+	assert.Equal(map[string]int64{"https://orch1:8935": 2}, counts("orchestrator_grpc_request_errors_total"))
+}
+
+func TestSegmentRetryCount(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	unitTestMode = true
+	defer func() { unitTestMode = false }()
+	initCensus(nil)
+
+	retries := func() *view.DistributionData {
+		rows, err := view.RetrieveData("segment_retry_count")
+		require.Nil(err)
+		if len(rows) == 0 {
+			return &view.DistributionData{}
+		}
+		require.Len(rows, 1)
+		return rows[0].Data.(*view.DistributionData)
+	}
+
+	StreamCreated("h1", 1)
+	// transcoded on first try
+	SegmentEmerged(1, 1, 3, 0)
+	TranscodeTry(1, 1)
+	SegmentFullyTranscoded(1, 1, "ps", "")
+	assert.Equal(int64(1), retries().Count)
+	assert.Equal(1.0, retries().Max)
+
+	// transcoded on third try
+	SegmentEmerged(1, 2, 3, 0)
+	TranscodeTry(1, 2)
+	TranscodeTry(1, 2)
+	TranscodeTry(1, 2)
+	SegmentFullyTranscoded(1, 2, "ps", "")
+	assert.Equal(int64(2), retries().Count)
+	assert.Equal(3.0, retries().Max)
+
+	// permanently failed after two tries
+	SegmentEmerged(1, 3, 3, 0)
+	TranscodeTry(1, 3)
+	TranscodeTry(1, 3)
+	SegmentTranscodeFailed(SegmentTranscodeErrorNoOrchestrators, 1, 3, fmt.Errorf("some"), true)
+	assert.Equal(int64(3), retries().Count)
+	assert.Equal(2.0, retries().Mean)
+
+	// retries exhausted, recorded only once
+	SegmentEmerged(1, 4, 3, 0)
+	for i := 0; i < 5; i++ {
+		TranscodeTry(1, 4)
+	}
+	TranscodeTriesExhausted(1, 4)
+	TranscodeTriesExhausted(1, 4)
+	assert.Equal(int64(4), retries().Count)
+	assert.Equal(5.0, retries().Max)
+	assert.Equal([]int64{0, 0, 1, 1, 1, 0, 1, 0, 0, 0, 0, 0}, retries().CountPerBucket)
+
+	// segment that was never tried isn't recorded
+	SegmentEmerged(1, 5, 3, 0)
+	SegmentFullyTranscoded(1, 5, "ps", "")
+	assert.Equal(int64(4), retries().Count)
+}
+
+func TestStaticLabels(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	unitTestMode = true
+	defer func() { unitTestMode = false }()
+	initCensus(map[string]string{"region": "us-east", "datacenter": "dc1"})
+
+	StreamCreated("h1", 1)
+	for _, name := range []string{"stream_created_total", "versions"} {
+		rows, err := view.RetrieveData(name)
+		require.Nil(err)
+		require.Len(rows, 1)
+		tags := make(map[string]string)
+		for _, tg := range rows[0].Tags {
+			tags[tg.Key.Name()] = tg.Value
+		}
+		assert.Equal("us-east", tags["region"], name)
+		assert.Equal("dc1", tags["datacenter"], name)
+		assert.Equal("tst", tags["node_type"], name)
+	}
+	// labels are added to the tag keys of the registered views
+	v := view.Find("stream_created_total")
+	require.NotNil(v)
+	assert.Len(v.TagKeys, 4)
+}
+
+func TestParseLabels(t *testing.T) {
+	assert := assert.New(t)
+
+	labels, err := ParseLabels("region=us-east, datacenter = dc1,")
+	assert.Nil(err)
+	assert.Equal(map[string]string{"region": "us-east", "datacenter": "dc1"}, labels)
+
+	labels, err = ParseLabels("")
+	assert.Nil(err)
+	assert.Len(labels, 0)
+
+	_, err = ParseLabels("region")
+	assert.EqualError(err, "invalid label=region")
+	_, err = ParseLabels("=us-east")
+	assert.EqualError(err, "invalid label==us-east")
+	_, err = ParseLabels("node_id=abc")
+	assert.EqualError(err, "label name=node_id is reserved")
+}
+
+func TestClassifyUploadError(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		reason string
+		code   SegmentUploadError
+	}{
+		// http
+		{`Post "https://127.0.0.1:8935/segment": net/http: request canceled (Client.Timeout exceeded while awaiting headers)`, SegmentUploadErrorTimeout},
+		{`Post "https://127.0.0.1:8935/segment": context deadline exceeded`, SegmentUploadErrorTimeout},
+		{`header timeout: Post "https://127.0.0.1:8935/segment": context deadline exceeded`, SegmentUploadErrorTimeout},
+		{"read tcp 127.0.0.1:55000->127.0.0.1:8935: i/o timeout", SegmentUploadErrorTimeout},
+		{`Post "https://127.0.0.1:8935/segment": dial tcp 127.0.0.1:8935: connect: connection refused`, SegmentUploadErrorUnknown},
+		// ffmpeg
+		{"Connection timed out", SegmentUploadErrorTimeout},
+		{"Server returned 404 Not Found", SegmentUploadErrorUnknown},
+		// orchestrator responses
+		{"Code: 400 Error: Insufficient balance", SegmentUploadErrorInsufficientBalance},
+		{"Code: 403 Error: ErrSegSig", SegmentUploadErrorGenCreds},
+		{"Code: 403 Error: ErrorSegEncoding", SegmentUploadErrorGenCreds},
+		{"Code: 500 Error: Internal Server Error", SegmentUploadErrorUnknown},
+		// object storage
+		{"Session ended", SegmentUploadErrorSessionEnded},
+		{`<?xml version="1.0" encoding="UTF-8"?><Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>`, SegmentUploadErrorOS},
+		{"<Error><Code>NoSuchBucket</Code><Message>The specified bucket does not exist</Message></Error>", SegmentUploadErrorOS},
+		{"<Error><Code>InvalidAccessKeyId</Code></Error>", SegmentUploadErrorOS},
+		{"<Error><Code>SignatureDoesNotMatch</Code></Error>", SegmentUploadErrorOS},
+		{"<Error><Code>RequestTimeTooSkewed</Code></Error>", SegmentUploadErrorOS},
+		{"<Error><Code>EntityTooLarge</Code></Error>", SegmentUploadErrorOS},
+		{"<Error><Code>SlowDown</Code><Message>Please reduce your request rate.</Message></Error>", SegmentUploadErrorOS},
+		{"<Error><Code>RequestTimeout</Code></Error>", SegmentUploadErrorTimeout},
+		{"oauth2: private key is invalid", SegmentUploadErrorOS},
+		// parsed S3 error responses
+		{"AccessDenied: Access Denied requestId=4442587FB7D0A2F9", SegmentUploadErrorOS},
+		{"EntityTooLarge: Your proposed upload exceeds the maximum allowed size", SegmentUploadErrorOS},
+		{"RequestTimeout: Your socket connection to the server was not read from or written to within the timeout period.", SegmentUploadErrorTimeout},
+		// unknown
+		{"", SegmentUploadErrorUnknown},
+		{"some error", SegmentUploadErrorUnknown},
+	}
+	for _, tt := range tests {
+		assert.Equal(tt.code, classifyUploadError(tt.reason), tt.reason)
+	}
+}
+
+func TestSegmentUploadFailed_ErrorCode(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	unitTestMode = true
+	defer func() { unitTestMode = false }()
+	initCensus(nil)
+
+	failed := func() map[string]int64 {
+		rows, err := view.RetrieveData("segment_source_upload_failed_total")
+		require.Nil(err)
+		res := make(map[string]int64)
+		for _, row := range rows {
+			for _, tag := range row.Tags {
+				if tag.Key == census.kErrorCode {
+					res[tag.Value] = row.Data.(*view.CountData).Value
+				}
+			}
+		}
+		return res
+	}
+
+	SegmentUploadFailed(1, 1, SegmentUploadErrorUnknown, "Code: 400 Error: Insufficient balance", false)
+	SegmentUploadFailed(1, 2, SegmentUploadErrorUnknown, "some error", false)
+	// explicit code isn't overridden
+	SegmentUploadFailed(1, 3, SegmentUploadErrorGenCreds, "context deadline exceeded", false)
+	assert.Equal(map[string]int64{
+		string(SegmentUploadErrorInsufficientBalance): 1,
+		string(SegmentUploadErrorUnknown):             1,
+		string(SegmentUploadErrorGenCreds):            1,
+	}, failed())
+}
+
+func TestStreamHealthScore(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	unitTestMode = true
+	defer func() { unitTestMode = false }()
+	initCensus(nil)
+
+	score := func(manifestID string) (float64, bool) {
+		rows, err := view.RetrieveData("stream_health_score")
+		require.Nil(err)
+		for _, row := range rows {
+			for _, tag := range row.Tags {
+				if tag.Key == census.kManifestID && tag.Value == manifestID {
+					return row.Data.(*view.LastValueData).Value, true
+				}
+			}
+		}
+		return 0, false
+	}
+
+	// unknown stream
+	assert.Equal(100.0, StreamHealthScore(1))
+
+	// no completed segments yet
+	StreamCreated("h1", 1)
+	SegmentEmerged(1, 1, 3, 2)
+	TranscodeTry(1, 1)
+	assert.Equal(100.0, StreamHealthScore(1))
+	_, ok := score("h1")
+	assert.False(ok)
+
+	// healthy segment transcoded faster than realtime on first try
+	SegmentFullyTranscoded(1, 1, "ps", "")
+	assert.Equal(100.0, StreamHealthScore(1))
+	val, ok := score("h1")
+	assert.True(ok)
+	assert.Equal(100.0, val)
+
+	// segment transcoded on second try slower than realtime
+	SegmentEmerged(1, 2, 3, 2)
+	TranscodeTry(1, 2)
+	TranscodeTry(1, 2)
+	census.emergeTimes[1][2] = time.Now().Add(-4 * time.Second)
+	SegmentFullyTranscoded(1, 2, "ps", "")
+	// success 1, realtime (1+0.5)/2, tries (1+0.5)/2
+	assert.InDelta(100*(0.5+0.3*0.75+0.2*0.75), StreamHealthScore(1), 0.1)
+	val, _ = score("h1")
+	assert.InDelta(StreamHealthScore(1), val, 0.0001)
+
+	// permanently failed segment lowers success rate
+	SegmentEmerged(1, 3, 3, 2)
+	TranscodeTry(1, 3)
+	SegmentTranscodeFailed(SegmentTranscodeErrorTranscode, 1, 3, errors.New("some error"), true)
+	// success 2/3, realtime (1+0.5)/2, tries (1+0.5+1)/3
+	assert.InDelta(100*(0.5*2/3+0.3*0.75+0.2*2.5/3), StreamHealthScore(1), 0.1)
+	val, _ = score("h1")
+	assert.InDelta(StreamHealthScore(1), val, 0.0001)
+
+	// segments of unknown duration are left out of realtime ratio
+	StreamCreated("h2", 2)
+	SegmentEmerged(2, 1, 3, 0)
+	SegmentFullyTranscoded(2, 1, "ps", "")
+	assert.Equal(100.0, StreamHealthScore(2))
+	SegmentEmerged(2, 2, 3, 0)
+	SegmentUploadFailed(2, 2, SegmentUploadErrorUnknown, "some error", true)
+	assert.Equal(50.0, StreamHealthScore(2))
+
+	// series is dropped at stream end
+	StreamEnded(1)
+	_, ok = score("h1")
+	assert.False(ok)
+	val, ok = score("h2")
+	assert.True(ok)
+	assert.Equal(50.0, val)
+}
+
+func TestTranscodeCompressionRatio(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	unitTestMode = true
+	defer func() { unitTestMode = false }()
+	initCensus(nil)
+
+	ratios := func() map[string]*view.DistributionData {
+		rows, err := view.RetrieveData("transcode_compression_ratio")
+		require.Nil(err)
+		res := make(map[string]*view.DistributionData)
+		for _, row := range rows {
+			for _, tag := range row.Tags {
+				if tag.Key == census.kProfiles {
+					res[tag.Value] = row.Data.(*view.DistributionData)
+				}
+			}
+		}
+		return res
+	}
+
+	TranscodeCompressionRatio("P240p30fps16x9,P144p30fps16x9", 1000, 250)
+	TranscodeCompressionRatio("P240p30fps16x9,P144p30fps16x9", 1000, 500)
+	// misconfigured profile producing more data than the source
+	TranscodeCompressionRatio("P720p60fps16x9", 1000, 4000)
+	// sizes unknown
+	TranscodeCompressionRatio("P720p60fps16x9", 1000, 0)
+	TranscodeCompressionRatio("P720p60fps16x9", 0, 1000)
+
+	r := ratios()
+	require.Len(r, 2)
+	assert.Equal(int64(2), r["P240p30fps16x9,P144p30fps16x9"].Count)
+	assert.Equal(3.0, r["P240p30fps16x9,P144p30fps16x9"].Mean)
+	assert.Equal(int64(1), r["P720p60fps16x9"].Count)
+	assert.Equal(0.25, r["P720p60fps16x9"].Mean)
+}
+
+func TestRenditionSizeVariance(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	unitTestMode = true
+	defer func() { unitTestMode = false }()
+	initCensus(nil)
+
+	variances := func() map[string]*view.DistributionData {
+		rows, err := view.RetrieveData("rendition_size_variance")
+		require.Nil(err)
+		res := make(map[string]*view.DistributionData)
+		for _, row := range rows {
+			for _, tag := range row.Tags {
+				if tag.Key == census.kProfiles {
+					res[tag.Value] = row.Data.(*view.DistributionData)
+				}
+			}
+		}
+		return res
+	}
+
+	// renditions of similar sizes
+	RenditionSizeVariance("P240p30fps16x9,P144p30fps16x9", []int64{1100, 900})
+	RenditionSizeVariance("P240p30fps16x9,P144p30fps16x9", []int64{1000, 1000})
+	// one of the renditions is almost empty
+	RenditionSizeVariance("P720p30fps16x9,P360p30fps16x9,P144p30fps16x9", []int64{4000, 4000, 10})
+	// nothing to compare
+	RenditionSizeVariance("P720p60fps16x9", []int64{1000})
+	RenditionSizeVariance("P240p30fps16x9,P144p30fps16x9", []int64{0, 0})
+
+	v := variances()
+	require.Len(v, 2)
+	normal := v["P240p30fps16x9,P144p30fps16x9"]
+	assert.Equal(int64(2), normal.Count)
+	assert.InDelta(0.1, normal.Max, 0.0001)
+	assert.Equal(0.0, normal.Min)
+	anomalous := v["P720p30fps16x9,P360p30fps16x9,P144p30fps16x9"]
+	assert.Equal(int64(1), anomalous.Count)
+	assert.InDelta(0.7045, anomalous.Mean, 0.0001)
+	assert.True(anomalous.Mean > 5*normal.Max)
+}
+
+func TestOrchestratorRejected(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	unitTestMode = true
+	defer func() { unitTestMode = false }()
+	initCensus(nil)
+
+	count := func(name string) int64 {
+		rows, err := view.RetrieveData(name)
+		require.Nil(err)
+		if len(rows) == 0 {
+			return 0
+		}
+		require.Len(rows, 1)
+		return rows[0].Data.(*view.CountData).Value
+	}
+
+	OrchestratorRejectedPrice()
+	OrchestratorRejectedPrice()
+	OrchestratorRejectedTicketParams()
+	assert.Equal(int64(2), count("orchestrator_rejected_price_total"))
+	assert.Equal(int64(1), count("orchestrator_rejected_ticket_params_total"))
+}
+
+func TestDiscoveryDuration(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	unitTestMode = true
+	defer func() { unitTestMode = false }()
+	initCensus(nil)
+
+	DiscoveryDuration(100*time.Millisecond, DiscoveryOutcomeSuccess)
+	DiscoveryDuration(300*time.Millisecond, DiscoveryOutcomeSuccess)
+	DiscoveryDuration(3*time.Second, DiscoveryOutcomeTimeout)
+	DiscoveryDuration(time.Millisecond, DiscoveryOutcomeEmpty)
+
+	rows, err := view.RetrieveData("orchestrator_discovery_duration_seconds")
+	require.Nil(err)
+	durations := make(map[string]*view.DistributionData)
+	for _, row := range rows {
+		for _, tag := range row.Tags {
+			if tag.Key == census.kOutcome {
+				durations[tag.Value] = row.Data.(*view.DistributionData)
+			}
+		}
+	}
+	require.Len(durations, 3)
+	assert.Equal(int64(2), durations[DiscoveryOutcomeSuccess].Count)
+	assert.InDelta(0.2, durations[DiscoveryOutcomeSuccess].Mean, 0.0001)
+	assert.Equal(int64(1), durations[DiscoveryOutcomeTimeout].Count)
+	assert.Equal(3.0, durations[DiscoveryOutcomeTimeout].Max)
+	assert.Equal(int64(1), durations[DiscoveryOutcomeEmpty].Count)
+}
+
+func TestS3Uploads(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	unitTestMode = true
+	defer func() { unitTestMode = false }()
+	initCensus(nil)
+
+	lastValue := func(name string) float64 {
+		rows, err := view.RetrieveData(name)
+		require.Nil(err)
+		require.Len(rows, 1)
+		return rows[0].Data.(*view.LastValueData).Value
+	}
+
+	S3Uploads(4, 2)
+	assert.Equal(4.0, lastValue("s3_uploads_in_flight"))
+	assert.Equal(2.0, lastValue("s3_uploads_waiting"))
+	S3Uploads(0, 0)
+	assert.Equal(0.0, lastValue("s3_uploads_in_flight"))
+	assert.Equal(0.0, lastValue("s3_uploads_waiting"))
+}
+
+func TestUploadRetries(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	unitTestMode = true
+	defer func() { unitTestMode = false }()
+	initCensus(nil)
+
+	UploadRetryQueueDepth(3)
+	rows, err := view.RetrieveData("upload_retry_queue_depth")
+	require.Nil(err)
+	require.Len(rows, 1)
+	assert.Equal(3.0, rows[0].Data.(*view.LastValueData).Value)
+
+	UploadRetried(UploadRetryOutcomeSuccess)
+	UploadRetried(UploadRetryOutcomeSuccess)
+	UploadRetried(UploadRetryOutcomeDropped)
+	rows, err = view.RetrieveData("upload_retries_total")
+	require.Nil(err)
+	counts := map[string]int64{}
+	for _, r := range rows {
+		for _, t := range r.Tags {
+			if t.Key == census.kOutcome {
+				counts[t.Value] = r.Data.(*view.CountData).Value
+			}
+		}
+	}
+	assert.Equal(map[string]int64{UploadRetryOutcomeSuccess: 2, UploadRetryOutcomeDropped: 1}, counts)
+}
+
+func TestTranscodeFramesDropped(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	unitTestMode = true
+	defer func() { unitTestMode = false }()
+	initCensus(nil)
+
+	TranscodeFramesDropped("P240p30fps16x9", 15)
+	TranscodeFramesDropped("P240p30fps16x9", 5)
+	TranscodeFramesDropped("P360p30fps16x9", 3)
+
+	rows, err := view.RetrieveData("transcode_frames_dropped_total")
+	require.Nil(err)
+	dropped := map[string]float64{}
+	for _, r := range rows {
+		for _, t := range r.Tags {
+			if t.Key == census.kProfile {
+				dropped[t.Value] = r.Data.(*view.SumData).Value
+			}
+		}
+	}
+	assert.Equal(map[string]float64{"P240p30fps16x9": 20, "P360p30fps16x9": 3}, dropped)
+}
+
+func TestHealthyOrchestrators(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	unitTestMode = true
+	defer func() { unitTestMode = false }()
+	initCensus(nil)
+
+	HealthyOrchestrators(5)
+	HealthyOrchestrators(4)
+	rows, err := view.RetrieveData("healthy_orchestrators")
+	require.Nil(err)
+	require.Len(rows, 1)
+	assert.Equal(4.0, rows[0].Data.(*view.LastValueData).Value)
+}
+
+func TestVerificationCheck(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	unitTestMode = true
+	defer func() { unitTestMode = false }()
+	initCensus(nil)
+
+	VerificationCheck("orch1", true)
+	VerificationCheck("orch1", false)
+	VerificationCheck("orch2", true)
+
+	counts := func(name string) map[string]int64 {
+		rows, err := view.RetrieveData(name)
+		require.Nil(err)
+		res := make(map[string]int64)
+		for _, r := range rows {
+			for _, tg := range r.Tags {
+				if tg.Key == census.kOrchestrator {
+					res[tg.Value] = r.Data.(*view.CountData).Value
+				}
+			}
+		}
+		return res
+	}
+	assert.Equal(map[string]int64{"orch1": 2, "orch2": 1}, counts("verification_checks_total"))
+	assert.Equal(map[string]int64{"orch1": 1, "orch2": 1}, counts("verification_passed_total"))
+	assert.Equal(map[string]int64{"orch1": 1}, counts("verification_failed_total"))
+}
+
+func TestAutoTopUp(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	unitTestMode = true
+	defer func() { unitTestMode = false }()
+	initCensus(nil)
+
+	AutoTopUp()
+	AutoTopUp()
+	AutoTopUpError()
+	rows, err := view.RetrieveData("auto_topup_total")
+	require.Nil(err)
+	require.Len(rows, 1)
+	assert.Equal(int64(2), rows[0].Data.(*view.CountData).Value)
+	rows, err = view.RetrieveData("auto_topup_errors_total")
+	require.Nil(err)
+	require.Len(rows, 1)
+	assert.Equal(int64(1), rows[0].Data.(*view.CountData).Value)
+}
+
+func TestOrchestratorInvalidURI(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	unitTestMode = true
+	defer func() { unitTestMode = false }()
+	initCensus(nil)
+
+	OrchestratorInvalidURI()
+	OrchestratorInvalidURI()
+	rows, err := view.RetrieveData("orchestrators_invalid_uri_total")
+	require.Nil(err)
+	require.Len(rows, 1)
+	assert.Equal(int64(2), rows[0].Data.(*view.CountData).Value)
+}
+
+func TestOrchestratorSchemeDowngrade(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	unitTestMode = true
+	defer func() { unitTestMode = false }()
+	initCensus(nil)
+
+	OrchestratorSchemeDowngrade("https://127.0.0.1:8935")
+	OrchestratorSchemeDowngrade("https://127.0.0.1:8935")
+	OrchestratorSchemeDowngrade("https://127.0.0.1:8936")
+	rows, err := view.RetrieveData("orchestrator_scheme_downgrades_total")
+	require.Nil(err)
+	require.Len(rows, 2)
+	counts := map[string]int64{}
+	for _, row := range rows {
+		for _, t := range row.Tags {
+			if t.Key == census.kOrchestrator {
+				counts[t.Value] = row.Data.(*view.CountData).Value
+			}
+		}
+	}
+	assert.Equal(map[string]int64{"https://127.0.0.1:8935": 2, "https://127.0.0.1:8936": 1}, counts)
+}
+
+func TestOrchestratorInfoAge(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	unitTestMode = true
+	defer func() { unitTestMode = false }()
+	initCensus(nil)
+
+	OrchestratorInfoAge(30 * time.Second)
+	OrchestratorInfoAge(2 * time.Hour)
+	OrchestratorInfoAge(5 * time.Hour)
+	rows, err := view.RetrieveData("orchestrator_info_age_seconds")
+	require.Nil(err)
+	require.Len(rows, 1)
+	data := rows[0].Data.(*view.DistributionData)
+	assert.Equal(int64(3), data.Count)
+	assert.Equal(30.0, data.Min)
+	assert.Equal(5*3600.0, data.Max)
+}
+
+func TestConcurrentStreamsDistribution(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	unitTestMode = true
+	defer func() { unitTestMode = false }()
+	initCensus(nil)
+
+	sample := func(streams int) {
+		CurrentSessions(streams)
+		census.lock.Lock()
+		defer census.lock.Unlock()
+		census.sampleConcurrentStreams()
+	}
+	for _, streams := range []int{0, 3, 10, 10, 3, 1} {
+		sample(streams)
+	}
+
+	rows, err := view.RetrieveData("concurrent_streams_distribution")
+	require.Nil(err)
+	require.Len(rows, 1)
+	data := rows[0].Data.(*view.DistributionData)
+	assert.Equal(int64(6), data.Count)
+	assert.Equal(0.0, data.Min)
+	assert.Equal(10.0, data.Max)
+	assert.Equal(27.0/6, data.Mean)
+	// buckets: <0 [0,1) [1,2) [2,4) [4,8) [8,16) ...
+	assert.Equal([]int64{0, 1, 1, 2, 0, 2}, data.CountPerBucket[:6])
+}
+
+func TestStreamWarmup(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	unitTestMode = true
+	defer func() { unitTestMode = false }()
+	initCensus(nil)
+
+	StreamWarmup(1, 3*time.Second)
+	StreamWarmup(2, 500*time.Millisecond)
+	rows, err := view.RetrieveData("stream_warmup_duration_seconds")
+	require.Nil(err)
+	require.Len(rows, 1)
+	data := rows[0].Data.(*view.DistributionData)
+	assert.Equal(int64(2), data.Count)
+	assert.Equal(0.5, data.Min)
+	assert.Equal(3.0, data.Max)
+}
+
+func TestStreamKeyRotated(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	unitTestMode = true
+	defer func() { unitTestMode = false }()
+	initCensus(nil)
+
+	StreamKeyRotated(1)
+	StreamKeyRotated(1)
+	rows, err := view.RetrieveData("stream_key_rotations_total")
+	require.Nil(err)
+	require.Len(rows, 1)
+	assert.Equal(int64(2), rows[0].Data.(*view.CountData).Value)
+}
+
+func TestSegmentFetches(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	unitTestMode = true
+	defer func() { unitTestMode = false }()
+	initCensus(nil)
+
+	SegmentFetches(3, 5)
+	rows, err := view.RetrieveData("segment_fetches_in_flight")
+	require.Nil(err)
+	require.Len(rows, 1)
+	assert.Equal(3.0, rows[0].Data.(*view.LastValueData).Value)
+	rows, err = view.RetrieveData("segment_fetch_queue_depth")
+	require.Nil(err)
+	require.Len(rows, 1)
+	assert.Equal(5.0, rows[0].Data.(*view.LastValueData).Value)
+}
+
+func TestStorageCache(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	unitTestMode = true
+	defer func() { unitTestMode = false }()
+	initCensus(nil)
+
+	StorageCacheRequested(true)
+	StorageCacheRequested(true)
+	StorageCacheRequested(false)
+	StorageCacheEvicted(2)
+	StorageCacheEvicted(1)
+
+	rows, err := view.RetrieveData("storage_cache_requests_total")
+	require.Nil(err)
+	requests := map[string]int64{}
+	for _, r := range rows {
+		for _, t := range r.Tags {
+			if t.Key == census.kOutcome {
+				requests[t.Value] = r.Data.(*view.CountData).Value
+			}
+		}
+	}
+	assert.Equal(map[string]int64{StorageCacheHit: 2, StorageCacheMiss: 1}, requests)
+
+	rows, err = view.RetrieveData("storage_cache_evictions_total")
+	require.Nil(err)
+	require.Len(rows, 1)
+	assert.Equal(float64(3), rows[0].Data.(*view.SumData).Value)
+}
+
+func TestGini(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(0.0, gini(nil))
+	assert.Equal(0.0, gini([]int{10}))
+	assert.Equal(0.0, gini([]int{5, 5, 5, 5}))
+	assert.InDelta(0.72, gini([]int{97, 1, 1, 1}), 1e-9)
+	assert.InDelta(0.25, gini([]int{1, 3}), 1e-9)
+}
+
+func TestOrchestratorSelectionGini(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	unitTestMode = true
+	defer func() { unitTestMode = false }()
+	initCensus(nil)
+	gauge := func() float64 {
+		rows, err := view.RetrieveData("orchestrator_selection_gini")
+		require.Nil(err)
+		require.Len(rows, 1)
+		return rows[0].Data.(*view.LastValueData).Value
+	}
+
+	// even distribution
+	for i := 0; i < 10; i++ {
+		for _, orch := range []string{"o1", "o2", "o3", "o4"} {
+			OrchestratorUsed(1, orch)
+		}
+	}
+	assert.Equal(0.0, gauge())
+
+	// skewed distribution
+	for i := 0; i < 120; i++ {
+		OrchestratorUsed(1, "o1")
+	}
+	// 130 of 160 segments on o1
+	assert.InDelta(0.5625, gauge(), 1e-9)
+
+	// segments out of the window are dropped
+	now := time.Now().Add(OrchestratorSelectionWindow)
+	census.orchestratorSelected("o2", now)
+	assert.Equal(0.0, gauge())
+	census.orchestratorSelected("o3", now.Add(time.Second))
+	census.orchestratorSelected("o3", now.Add(OrchestratorSelectionWindow/2))
+	assert.InDelta(1.0/6, gauge(), 1e-9)
+	assert.Len(census.orchSelections, 2)
+}
+
+func TestOrchestratorCooldown(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	unitTestMode = true
+	defer func() { unitTestMode = false }()
+	initCensus(nil)
+	gauge := func() int64 {
+		rows, err := view.RetrieveData("orchestrators_in_cooldown")
+		require.Nil(err)
+		require.Len(rows, 1)
+		return int64(rows[0].Data.(*view.LastValueData).Value)
+	}
+
+	// distinct orchestrators are counted
+	OrchestratorCooldownStarted("o1")
+	OrchestratorCooldownStarted("o2")
+	OrchestratorCooldownStarted("o1")
+	assert.Equal(int64(2), gauge())
+
+	// until none of the streams suspends them
+	OrchestratorCooldownEnded("o1", 2*time.Second)
+	assert.Equal(int64(2), gauge())
+	OrchestratorCooldownEnded("o1", 20*time.Second)
+	assert.Equal(int64(1), gauge())
+	OrchestratorCooldownEnded("o2", 90*time.Second)
+	assert.Equal(int64(0), gauge())
+	assert.Empty(census.orchCooldowns)
+
+	rows, err := view.RetrieveData("cooldown_duration_seconds")
+	require.Nil(err)
+	require.Len(rows, 1)
+	dist := rows[0].Data.(*view.DistributionData)
+	assert.Equal(int64(3), dist.Count)
+	assert.Equal(2.0, dist.Min)
+	assert.Equal(90.0, dist.Max)
+}
+
+func TestStreamRejectedTenantLimit(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	unitTestMode = true
+	defer func() { unitTestMode = false }()
+	initCensus(nil)
+
+	StreamRejectedTenantLimit("acme", TenantLimitConcurrent)
+	StreamRejectedTenantLimit("acme", TenantLimitConcurrent)
+	StreamRejectedTenantLimit("acme", TenantLimitRate)
+	StreamRejectedTenantLimit("other", TenantLimitRate)
+
+	rows, err := view.RetrieveData("streams_rejected_tenant_limit_total")
+	require.Nil(err)
+	counts := map[string]int64{}
+	for _, r := range rows {
+		var tenant, reason string
+		for _, t := range r.Tags {
+			switch t.Key {
+			case census.kTenant:
+				tenant = t.Value
+			case census.kReason:
+				reason = t.Value
+			}
+		}
+		counts[tenant+"/"+reason] = r.Data.(*view.CountData).Value
+	}
+	assert.Equal(map[string]int64{"acme/concurrent": 2, "acme/rate": 1, "other/rate": 1}, counts)
+}
+
+func TestSourceResolution(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	unitTestMode = true
+	defer func() { unitTestMode = false }()
+	initCensus(nil)
+	defer func(max int) { MaxSourceResolutions = max }(MaxSourceResolutions)
+	MaxSourceResolutions = 3
+
+	for _, res := range []string{"1920x1080", "720x1280", "1920x1080", "3840x2160", "640x360", "1920x1080", "426x240"} {
+		SourceResolution(res)
+	}
+
+	rows, err := view.RetrieveData("source_resolutions_total")
+	require.Nil(err)
+	counts := map[string]int64{}
+	for _, r := range rows {
+		for _, t := range r.Tags {
+			if t.Key == census.kResolution {
+				counts[t.Value] = r.Data.(*view.CountData).Value
+			}
+		}
+	}
+	assert.Equal(map[string]int64{
+		"1920x1080":           3,
+		"720x1280":            1,
+		"3840x2160":           1,
+		SourceResolutionOther: 2,
+	}, counts)
+}
+
+func TestOrchestratorWarmup(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	unitTestMode = true
+	defer func() { unitTestMode = false }()
+	initCensus(nil)
+
+	OrchestratorWarmup(100*time.Millisecond, true)
+	OrchestratorWarmup(300*time.Millisecond, true)
+	OrchestratorWarmup(2*time.Second, false)
+
+	rows, err := view.RetrieveData("orchestrator_warmup_duration_seconds")
+	require.Nil(err)
+	durations := make(map[string]*view.DistributionData)
+	for _, row := range rows {
+		for _, tag := range row.Tags {
+			if tag.Key == census.kOutcome {
+				durations[tag.Value] = row.Data.(*view.DistributionData)
+			}
+		}
+	}
+	require.Len(durations, 2)
+	assert.Equal(int64(2), durations[WarmupOutcomeSuccess].Count)
+	assert.InDelta(0.2, durations[WarmupOutcomeSuccess].Mean, 0.0001)
+	assert.Equal(int64(1), durations[WarmupOutcomeFailure].Count)
+	assert.Equal(2.0, durations[WarmupOutcomeFailure].Max)
+}
+
+func TestDrainMode(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	unitTestMode = true
+	defer func() { unitTestMode = false }()
+	initCensus(nil)
+
+	lastValue := func() float64 {
+		rows, err := view.RetrieveData("drain_mode_active")
+		require.Nil(err)
+		require.Len(rows, 1)
+		return rows[0].Data.(*view.LastValueData).Value
+	}
+
+	DrainMode(true)
+	assert.Equal(1.0, lastValue())
+	DrainMode(false)
+	assert.Equal(0.0, lastValue())
+}
+
+func TestNodeHealth(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	unitTestMode = true
+	defer func() { unitTestMode = false }()
+	initCensus(nil)
+
+	unhealthy := func() float64 {
+		rows, err := view.RetrieveData("node_unhealthy")
+		require.Nil(err)
+		require.Len(rows, 1)
+		return rows[0].Data.(*view.LastValueData).Value
+	}
+	migrations := func() map[string]int64 {
+		rows, err := view.RetrieveData("stream_migrations_total")
+		require.Nil(err)
+		counts := make(map[string]int64)
+		for _, r := range rows {
+			for _, tag := range r.Tags {
+				if tag.Key.Name() == "reason" {
+					counts[tag.Value] = r.Data.(*view.CountData).Value
+				}
+			}
+		}
+		return counts
+	}
+
+	NodeUnhealthy(true)
+	assert.Equal(1.0, unhealthy())
+	StreamMigrated("success_rate")
+	StreamMigrated("success_rate")
+	StreamMigrated("transcode_time")
+	assert.Equal(map[string]int64{"success_rate": 2, "transcode_time": 1}, migrations())
+	NodeUnhealthy(false)
+	assert.Equal(0.0, unhealthy())
+
+	sum, count := TranscodeTimeTotals()
+	assert.Equal(0.0, sum)
+	assert.Equal(int64(0), count)
+}
+
+func TestSegmentTranscodedOn(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	unitTestMode = true
+	defer func() { unitTestMode = false }()
+	initCensus(nil)
+
+	gpuCounts := func() map[string]int64 {
+		rows, err := view.RetrieveData("segments_transcoded_gpu_total")
+		require.Nil(err)
+		counts := make(map[string]int64)
+		for _, r := range rows {
+			for _, tag := range r.Tags {
+				if tag.Key.Name() == "gpu" {
+					counts[tag.Value] = r.Data.(*view.CountData).Value
+				}
+			}
+		}
+		return counts
+	}
+	cpuCount := func() int64 {
+		rows, err := view.RetrieveData("segments_transcoded_cpu_total")
+		require.Nil(err)
+		if len(rows) == 0 {
+			return 0
+		}
+		return rows[0].Data.(*view.CountData).Value
+	}
+	ratio := func() float64 {
+		rows, err := view.RetrieveData("segments_transcoded_gpu_ratio")
+		require.Nil(err)
+		require.Len(rows, 1)
+		return rows[0].Data.(*view.LastValueData).Value
+	}
+
+	// CPU
+	SegmentTranscodedOn("")
+	assert.Equal(int64(1), cpuCount())
+	assert.Len(gpuCounts(), 0)
+	assert.Equal(0.0, ratio())
+
+	// GPU, counted per device
+	SegmentTranscodedOn("0")
+	SegmentTranscodedOn("1")
+	SegmentTranscodedOn("1")
+	assert.Equal(map[string]int64{"0": 1, "1": 2}, gpuCounts())
+	assert.Equal(int64(1), cpuCount())
+	assert.Equal(0.75, ratio())
+
+	SegmentTranscodedOn("")
+	assert.Equal(int64(2), cpuCount())
+	assert.Equal(0.6, ratio())
+}
+
+func TestPlaylistRequested(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	unitTestMode = true
+	defer func() { unitTestMode = false }()
+	initCensus(nil)
+
+	requests := func(mid string) (float64, bool) {
+		rows, err := view.RetrieveData("hls_playlist_requests_total")
+		require.Nil(err)
+		for _, r := range rows {
+			for _, tg := range r.Tags {
+				if tg.Key == census.kManifestID && tg.Value == mid {
+					return r.Data.(*view.SumData).Value, true
+				}
+			}
+		}
+		return 0, false
+	}
+
+	// unknown stream isn't recorded
+	PlaylistRequested(1)
+	_, ok := requests("mid1")
+	assert.False(ok)
+
+	StreamCreated("mid1", 1)
+	StreamCreated("mid2", 2)
+	for i := 1; i <= 3; i++ {
+		PlaylistRequested(1)
+		r, _ := requests("mid1")
+		assert.Equal(float64(i), r)
+	}
+	PlaylistRequested(2)
+	r, _ := requests("mid2")
+	assert.Equal(1.0, r)
+
+	// series of the ended stream is removed, other streams keep their totals
+	StreamEnded(1)
+	_, ok = requests("mid1")
+	assert.False(ok)
+	r, ok = requests("mid2")
+	assert.True(ok)
+	assert.Equal(1.0, r)
+	PlaylistRequested(2)
+	r, _ = requests("mid2")
+	assert.Equal(2.0, r)
+
+	// requests after stream end aren't recorded
+	PlaylistRequested(1)
+	_, ok = requests("mid1")
+	assert.False(ok)
+
+	// totals start over after the counters are reset
+	ResetCounters()
+	PlaylistRequested(2)
+	StreamCreated("mid3", 3)
+	PlaylistRequested(3)
+	StreamEnded(3)
+	r, _ = requests("mid2")
+	assert.Equal(1.0, r)
+	StreamEnded(2)
+}
+
+func TestTranscodeTTFB(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	unitTestMode = true
+	defer func() { unitTestMode = false }()
+	initCensus(nil)
+
+	TranscodeTTFB("orch1", 100*time.Millisecond)
+	TranscodeTTFB("orch1", 300*time.Millisecond)
+	TranscodeTTFB("orch2", 2*time.Second)
+
+	rows, err := view.RetrieveData("transcode_ttfb_seconds")
+	require.Nil(err)
+	dists := make(map[string]*view.DistributionData)
+	for _, r := range rows {
+		for _, tg := range r.Tags {
+			if tg.Key == census.kOrchestrator {
+				dists[tg.Value] = r.Data.(*view.DistributionData)
+			}
+		}
+	}
+	require.Len(dists, 2)
+	assert.Equal(int64(2), dists["orch1"].Count)
+	assert.InDelta(0.2, dists["orch1"].Mean, 0.0001)
+	assert.Equal(int64(1), dists["orch2"].Count)
+	assert.InDelta(2.0, dists["orch2"].Mean, 0.0001)
+}
+
+func TestSegmentFailover(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	unitTestMode = true
+	defer func() { unitTestMode = false }()
+	initCensus(nil)
+
+	counts := func() map[string]float64 {
+		rows, err := view.RetrieveData("segments_triggering_failover_total")
+		require.Nil(err)
+		counts := make(map[string]float64)
+		for _, r := range rows {
+			for _, tg := range r.Tags {
+				if tg.Key == census.kManifestID {
+					counts[tg.Value] = r.Data.(*view.SumData).Value
+				}
+			}
+		}
+		return counts
+	}
+
+	StreamCreated("mid1", 1)
+	StreamCreated("mid2", 2)
+	SegmentFailover("mid1")
+	SegmentFailover("mid1")
+	SegmentFailover("mid2")
+	// unknown stream isn't recorded
+	SegmentFailover("mid3")
+	assert.Equal(map[string]float64{"mid1": 2, "mid2": 1}, counts())
+
+	// series of the ended stream is removed, other streams keep their totals
+	StreamEnded(1)
+	assert.Equal(map[string]float64{"mid2": 1}, counts())
+	SegmentFailover("mid2")
+	SegmentFailover("mid1")
+	assert.Equal(map[string]float64{"mid2": 2}, counts())
+	StreamEnded(2)
+	assert.Empty(counts())
+}
+
+func TestFallbackChainPosition(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	unitTestMode = true
+	defer func() { unitTestMode = false }()
+	initCensus(nil)
+
+	FallbackChainPosition(0)
+	FallbackChainPosition(2)
+	FallbackChainPosition(0)
+
+	rows, err := view.RetrieveData("fallback_chain_position")
+	require.Nil(err)
+	require.Len(rows, 1)
+	data := rows[0].Data.(*view.DistributionData)
+	assert.Equal(int64(3), data.Count)
+	assert.Equal(0.0, data.Min)
+	assert.Equal(2.0, data.Max)
+}
+
+func TestSegmentFailedMaxOrchestrators(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	unitTestMode = true
+	defer func() { unitTestMode = false }()
+	initCensus(nil)
+
+	StreamCreated("mid1", 1)
+	TranscodeTry(1, 1)
+	TranscodeTry(1, 1)
+	SegmentFailedMaxOrchestrators(1, 1)
+
+	rows, err := view.RetrieveData("segments_failed_max_orchestrators_total")
+	require.Nil(err)
+	require.Len(rows, 1)
+	assert.Equal(int64(1), rows[0].Data.(*view.CountData).Value)
+
+	// tries of the failed segment are recorded
+	rows, err = view.RetrieveData("segment_retry_count")
+	require.Nil(err)
+	require.Len(rows, 1)
+	dist := rows[0].Data.(*view.DistributionData)
+	assert.Equal(int64(1), dist.Count)
+	assert.Equal(2.0, dist.Mean)
+}
+
+func TestStreamCosts(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	unitTestMode = true
+	defer func() { unitTestMode = false }()
+	initCensus(nil)
+
+	gauge := func(mid string) (float64, bool) {
+		rows, err := view.RetrieveData("stream_cost_gwei")
+		require.Nil(err)
+		for _, r := range rows {
+			for _, tg := range r.Tags {
+				if tg.Key == census.kManifestID && tg.Value == mid {
+					return r.Data.(*view.LastValueData).Value, true
+				}
+			}
+		}
+		return 0, false
+	}
+
+	StreamCreated("mid1", 1)
+	StreamCreated("mid2", 2)
+	// costs accumulate across segments and orchestrators
+	TicketValueSent("recipient1", "mid1", big.NewRat(1000000000, 1))
+	TicketValueSent("recipient2", "mid1", big.NewRat(2500000000, 1))
+	TicketValueSent("recipient1", "mid2", big.NewRat(500000000, 1))
+	// non-positive values aren't counted
+	TicketValueSent("recipient1", "mid2", big.NewRat(0, 1))
+	assert.Equal(map[string]float64{"mid1": 3.5, "mid2": 0.5}, StreamCosts())
+	v, _ := gauge("mid1")
+	assert.Equal(3.5, v)
+	v, _ = gauge("mid2")
+	assert.Equal(0.5, v)
+
+	// cost of the ended stream is kept for the retention period
+	StreamEnded(1)
+	census.lock.Lock()
+	census.removeStreamCosts(time.Now())
+	census.lock.Unlock()
+	assert.Equal(map[string]float64{"mid1": 3.5, "mid2": 0.5}, StreamCosts())
+
+	census.lock.Lock()
+	census.removeStreamCosts(time.Now().Add(StreamCostRetention + time.Second))
+	census.lock.Unlock()
+	assert.Equal(map[string]float64{"mid2": 0.5}, StreamCosts())
+	_, ok := gauge("mid1")
+	assert.False(ok)
+	v, ok = gauge("mid2")
+	assert.True(ok)
+	assert.Equal(0.5, v)
+	StreamEnded(2)
+}
+
+func TestResetCounters(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	unitTestMode = true
+	defer func() { unitTestMode = false }()
+	initCensus(nil)
+
+	count := func(name string) int64 {
+		rows, err := view.RetrieveData(name)
+		require.Nil(err)
+		var total int64
+		for _, row := range rows {
+			switch data := row.Data.(type) {
+			case *view.CountData:
+				total += data.Value
+			case *view.DistributionData:
+				total += data.Count
+			}
+		}
+		return total
+	}
+	lastValue := func(name string) float64 {
+		rows, err := view.RetrieveData(name)
+		require.Nil(err)
+		require.Len(rows, 1)
+		return rows[0].Data.(*view.LastValueData).Value
+	}
+
+	SegmentTranscoded(1, 1, time.Second, "ps", "")
+	SegmentTranscoded(1, 2, time.Second, "ps", "")
+	CurrentSessions(3)
+	assert.Equal(int64(2), count("segment_transcoded_total"))
+	assert.Equal(int64(2), count("transcode_time_seconds"))
+	assert.Equal(1.0, AverageTranscodeTime())
+
+	ResetCounters()
+	assert.Equal(int64(0), count("segment_transcoded_total"))
+	assert.Equal(int64(0), count("transcode_time_seconds"))
+	assert.Equal(0.0, AverageTranscodeTime())
+	// gauges keep their values
+	assert.Equal(3.0, lastValue("current_sessions_total"))
+
+	// counting continues after reset
+	SegmentTranscoded(1, 3, time.Second, "ps", "")
+	assert.Equal(int64(1), count("segment_transcoded_total"))
+}
+
+func TestUploadQueueDepth(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	unitTestMode = true
+	defer func() { unitTestMode = false }()
+	initCensus(nil)
+
+	depth := func(manifestID string) float64 {
+		rows, err := view.RetrieveData("upload_queue_depth")
+		require.Nil(err)
+		for _, row := range rows {
+			for _, tag := range row.Tags {
+				if tag.Key == census.kManifestID && tag.Value == manifestID {
+					return row.Data.(*view.LastValueData).Value
+				}
+			}
+		}
+		return -1
+	}
+
+	// unknown stream isn't recorded
+	UploadQueueDepth(1, 1)
+	assert.Equal(-1.0, depth("h1"))
+
+	// queue fills and drains
+	StreamCreated("h1", 1)
+	StreamCreated("h2", 2)
+	for i := 1; i <= 3; i++ {
+		UploadQueueDepth(1, i)
+	}
+	UploadQueueDepth(2, 1)
+	assert.Equal(3.0, depth("h1"))
+	assert.Equal(1.0, depth("h2"))
+	UploadQueueDepth(1, 2)
+	assert.Equal(2.0, depth("h1"))
+
+	// series is dropped at stream end
+	StreamEnded(1)
+	assert.Equal(-1.0, depth("h1"))
+	assert.Equal(1.0, depth("h2"))
+	UploadQueueDepth(1, 1)
+	assert.Equal(-1.0, depth("h1"))
+}
+
+func TestSegmenterMemory(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	unitTestMode = true
+	defer func() { unitTestMode = false }()
+	initCensus(nil)
+
+	memory := func(manifestID string) float64 {
+		rows, err := view.RetrieveData("segmenter_memory_bytes")
+		require.Nil(err)
+		for _, row := range rows {
+			for _, tag := range row.Tags {
+				if tag.Key == census.kManifestID && tag.Value == manifestID {
+					return row.Data.(*view.LastValueData).Value
+				}
+			}
+		}
+		return -1
+	}
+
+	// unknown stream isn't recorded
+	SegmenterMemory(1, 100)
+	assert.Equal(-1.0, memory("m1"))
+
+	// gauge tracks buffer growth
+	StreamCreated("m1", 1)
+	StreamCreated("m2", 2)
+	SegmenterMemory(1, 100)
+	assert.Equal(100.0, memory("m1"))
+	SegmenterMemory(1, 300)
+	assert.Equal(300.0, memory("m1"))
+	SegmenterMemory(2, 50)
+	assert.Equal(300.0, memory("m1"))
+	assert.Equal(50.0, memory("m2"))
+
+	// buffers drain
+	SegmenterMemory(1, 200)
+	assert.Equal(200.0, memory("m1"))
+
+	// series is dropped at stream end
+	StreamEnded(1)
+	assert.Equal(-1.0, memory("m1"))
+	assert.Equal(50.0, memory("m2"))
+	SegmenterMemory(1, 0)
+	assert.Equal(-1.0, memory("m1"))
+}
+
+func TestDistinctOrchestrators(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	unitTestMode = true
+	defer func() { unitTestMode = false }()
+	initCensus(nil)
+
+	distinct := func() *view.DistributionData {
+		rows, err := view.RetrieveData("distinct_orchestrators_per_stream")
+		require.Nil(err)
+		if len(rows) == 0 {
+			return &view.DistributionData{}
+		}
+		require.Len(rows, 1)
+		return rows[0].Data.(*view.DistributionData)
+	}
+
+	// segments spread across multiple orchestrators
+	StreamCreated("h1", 1)
+	OrchestratorUsed(1, "https://orch1:8935")
+	OrchestratorUsed(1, "https://orch2:8935")
+	OrchestratorUsed(1, "https://orch1:8935")
+	OrchestratorUsed(1, "https://orch3:8935")
+	// not recorded before stream end
+	assert.Equal(int64(0), distinct().Count)
+	StreamEnded(1)
+	assert.Equal(int64(1), distinct().Count)
+	assert.Equal(3.0, distinct().Max)
+
+	// stream pinned to single orchestrator
+	StreamCreated("h2", 2)
+	OrchestratorUsed(2, "https://orch1:8935")
+	OrchestratorUsed(2, "https://orch1:8935")
+	StreamEnded(2)
+	assert.Equal(int64(2), distinct().Count)
+	assert.Equal(1.0, distinct().Min)
+
+	// stream that wasn't transcoded isn't recorded
+	StreamCreated("h3", 3)
+	StreamEnded(3)
+	assert.Equal(int64(2), distinct().Count)
+
+	// segments of ended or unknown streams are ignored
+	OrchestratorUsed(1, "https://orch4:8935")
+	OrchestratorUsed(4, "https://orch4:8935")
+	assert.Len(census.streamOrchs, 0)
+}
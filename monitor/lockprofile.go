@@ -0,0 +1,6 @@
+// +build lockprofile
+
+package monitor
+
+// lockProfiling enables recording of the time spent waiting on the census lock
+const lockProfiling = true
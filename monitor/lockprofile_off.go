@@ -0,0 +1,5 @@
+// +build !lockprofile
+
+package monitor
+
+const lockProfiling = false
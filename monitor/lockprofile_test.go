@@ -0,0 +1,28 @@
+// +build lockprofile
+
+package monitor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opencensus.io/stats/view"
+)
+
+func TestCensusLockWait(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	unitTestMode = true
+	defer func() { unitTestMode = false }()
+	initCensus(nil)
+
+	StreamCreated("h1", 1)
+	SegmentEmerged(1, 1, 3, 0)
+	StreamEnded(1)
+
+	rows, err := view.RetrieveData("census_lock_wait_seconds")
+	require.Nil(err)
+	require.Len(rows, 1)
+	assert.True(rows[0].Data.(*view.DistributionData).Count >= 3)
+}
@@ -0,0 +1,341 @@
+package monitor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+
+	"go.opencensus.io/metric/metricdata"
+	"go.opencensus.io/stats/view"
+)
+
+const (
+	otlpMetricsPath = "/v1/metrics"
+	otlpScopeName   = "livepeer"
+
+	// OTLP AggregationTemporality enum value for cumulative data
+	otlpCumulative = 2
+)
+
+var otlpPushInterval = 10 * time.Second
+
+// The OTLP/JSON encoding of the views is written by hand. The OpenCensus bridge
+// and the exporters of OpenTelemetry require a newer Go than the node is built
+// with, and pushing the cumulative views takes only the few message types below.
+type (
+	otlpExporter struct {
+		url      string
+		client   *http.Client
+		resource []otlpKeyValue
+
+		mu      sync.Mutex
+		pending map[string]*otlpMetric // view name:latest data
+
+		quit chan struct{}
+		done chan struct{}
+	}
+
+	otlpAnyValue struct {
+		StringValue string `json:"stringValue"`
+	}
+
+	otlpKeyValue struct {
+		Key   string       `json:"key"`
+		Value otlpAnyValue `json:"value"`
+	}
+
+	otlpNumberDataPoint struct {
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		TimeUnixNano      string         `json:"timeUnixNano"`
+		AsInt             string         `json:"asInt,omitempty"`
+		AsDouble          *float64       `json:"asDouble,omitempty"`
+	}
+
+	otlpExemplar struct {
+		FilteredAttributes []otlpKeyValue `json:"filteredAttributes,omitempty"`
+		TimeUnixNano       string         `json:"timeUnixNano"`
+		AsDouble           float64        `json:"asDouble"`
+	}
+
+	otlpHistogramDataPoint struct {
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		TimeUnixNano      string         `json:"timeUnixNano"`
+		Count             string         `json:"count"`
+		Sum               float64        `json:"sum"`
+		BucketCounts      []string       `json:"bucketCounts"`
+		ExplicitBounds    []float64      `json:"explicitBounds"`
+		Exemplars         []otlpExemplar `json:"exemplars,omitempty"`
+	}
+
+	otlpSum struct {
+		DataPoints             []otlpNumberDataPoint `json:"dataPoints"`
+		AggregationTemporality int                   `json:"aggregationTemporality"`
+		IsMonotonic            bool                  `json:"isMonotonic"`
+	}
+
+	otlpGauge struct {
+		DataPoints []otlpNumberDataPoint `json:"dataPoints"`
+	}
+
+	otlpHistogram struct {
+		DataPoints             []otlpHistogramDataPoint `json:"dataPoints"`
+		AggregationTemporality int                      `json:"aggregationTemporality"`
+	}
+
+	otlpMetric struct {
+		Name        string         `json:"name"`
+		Description string         `json:"description,omitempty"`
+		Unit        string         `json:"unit,omitempty"`
+		Sum         *otlpSum       `json:"sum,omitempty"`
+		Gauge       *otlpGauge     `json:"gauge,omitempty"`
+		Histogram   *otlpHistogram `json:"histogram,omitempty"`
+	}
+
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+
+	otlpScopeMetrics struct {
+		Scope   otlpScope     `json:"scope"`
+		Metrics []*otlpMetric `json:"metrics"`
+	}
+
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+
+	otlpResourceMetrics struct {
+		Resource     otlpResource       `json:"resource"`
+		ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+	}
+
+	otlpExportRequest struct {
+		ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+	}
+)
+
+// InitCensusOTLP registers an exporter that pushes all census views to an
+// OpenTelemetry collector using OTLP over HTTP. Should be called after InitCensus.
+// Can be used together with the Prometheus exporter. Returns the function that
+// stops the exporter and pushes the metrics not pushed yet.
+func InitCensusOTLP(endpoint string, insecure bool) func() {
+	url := otlpURL(endpoint, insecure)
+	exp := newOTLPExporter(url, census.nodeType, census.nodeID)
+	view.RegisterExporter(exp)
+	go exp.pushLoop()
+	glog.Infof("Pushing metrics to OTLP endpoint=%s", url)
+	return exp.stop
+}
+
+func otlpURL(endpoint string, insecure bool) string {
+	url := endpoint
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		if insecure {
+			url = "http://" + url
+		} else {
+			url = "https://" + url
+		}
+	}
+	if !strings.HasSuffix(url, otlpMetricsPath) {
+		url = strings.TrimSuffix(url, "/") + otlpMetricsPath
+	}
+	return url
+}
+
+func newOTLPExporter(url, nodeType, nodeID string) *otlpExporter {
+	return &otlpExporter{
+		url:    url,
+		client: &http.Client{Timeout: otlpPushInterval},
+		resource: []otlpKeyValue{
+			{Key: "node_type", Value: otlpAnyValue{StringValue: nodeType}},
+			{Key: "node_id", Value: otlpAnyValue{StringValue: nodeID}},
+		},
+		pending: make(map[string]*otlpMetric),
+		quit:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// ExportView implements view.Exporter. Data is cumulative, so only
+// the latest data for each view is kept until the next push.
+func (e *otlpExporter) ExportView(vd *view.Data) {
+	m := otlpMetricFromView(vd)
+	if m == nil {
+		return
+	}
+	e.mu.Lock()
+	e.pending[vd.View.Name] = m
+	e.mu.Unlock()
+}
+
+func (e *otlpExporter) pushLoop() {
+	defer close(e.done)
+	ticker := time.NewTicker(otlpPushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			e.pushLogged()
+		case <-e.quit:
+			return
+		}
+	}
+}
+
+// stop ends the push loop and flushes the pending metrics
+func (e *otlpExporter) stop() {
+	view.UnregisterExporter(e)
+	close(e.quit)
+	<-e.done
+	e.pushLogged()
+}
+
+func (e *otlpExporter) pushLogged() {
+	if err := e.push(); err != nil {
+		glog.Errorf("Error pushing metrics to OTLP endpoint=%s err=%v", e.url, err)
+	}
+}
+
+func (e *otlpExporter) push() error {
+	e.mu.Lock()
+	if len(e.pending) == 0 {
+		e.mu.Unlock()
+		return nil
+	}
+	metrics := make([]*otlpMetric, 0, len(e.pending))
+	for _, m := range e.pending {
+		metrics = append(metrics, m)
+	}
+	e.pending = make(map[string]*otlpMetric)
+	e.mu.Unlock()
+
+	req := otlpExportRequest{
+		ResourceMetrics: []otlpResourceMetrics{{
+			Resource: otlpResource{Attributes: e.resource},
+			ScopeMetrics: []otlpScopeMetrics{{
+				Scope:   otlpScope{Name: otlpScopeName},
+				Metrics: metrics,
+			}},
+		}},
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	resp, err := e.client.Post(e.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		rb, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("status=%d body=%s", resp.StatusCode, string(rb))
+	}
+	return nil
+}
+
+func otlpMetricFromView(vd *view.Data) *otlpMetric {
+	v := vd.View
+	m := &otlpMetric{
+		Name:        v.Name,
+		Description: v.Description,
+		Unit:        v.Measure.Unit(),
+	}
+	start := strconv.FormatInt(vd.Start.UnixNano(), 10)
+	end := strconv.FormatInt(vd.End.UnixNano(), 10)
+	switch v.Aggregation.Type {
+	case view.AggTypeCount:
+		m.Sum = &otlpSum{AggregationTemporality: otlpCumulative, IsMonotonic: true}
+	case view.AggTypeSum:
+		m.Sum = &otlpSum{AggregationTemporality: otlpCumulative}
+	case view.AggTypeLastValue:
+		m.Gauge = &otlpGauge{}
+	case view.AggTypeDistribution:
+		m.Histogram = &otlpHistogram{AggregationTemporality: otlpCumulative}
+	default:
+		return nil
+	}
+	for _, row := range vd.Rows {
+		attrs := make([]otlpKeyValue, 0, len(row.Tags))
+		for _, t := range row.Tags {
+			attrs = append(attrs, otlpKeyValue{Key: t.Key.Name(), Value: otlpAnyValue{StringValue: t.Value}})
+		}
+		switch data := row.Data.(type) {
+		case *view.CountData:
+			m.Sum.DataPoints = append(m.Sum.DataPoints, otlpNumberDataPoint{
+				Attributes:        attrs,
+				StartTimeUnixNano: start,
+				TimeUnixNano:      end,
+				AsInt:             strconv.FormatInt(data.Value, 10),
+			})
+		case *view.SumData:
+			val := data.Value
+			m.Sum.DataPoints = append(m.Sum.DataPoints, otlpNumberDataPoint{
+				Attributes:        attrs,
+				StartTimeUnixNano: start,
+				TimeUnixNano:      end,
+				AsDouble:          &val,
+			})
+		case *view.LastValueData:
+			val := data.Value
+			m.Gauge.DataPoints = append(m.Gauge.DataPoints, otlpNumberDataPoint{
+				Attributes:        attrs,
+				StartTimeUnixNano: start,
+				TimeUnixNano:      end,
+				AsDouble:          &val,
+			})
+		case *view.DistributionData:
+			counts := make([]string, len(data.CountPerBucket))
+			for i, c := range data.CountPerBucket {
+				counts[i] = strconv.FormatInt(c, 10)
+			}
+			m.Histogram.DataPoints = append(m.Histogram.DataPoints, otlpHistogramDataPoint{
+				Attributes:        attrs,
+				StartTimeUnixNano: start,
+				TimeUnixNano:      end,
+				Count:             strconv.FormatInt(data.Count, 10),
+				Sum:               data.Mean * float64(data.Count),
+				BucketCounts:      counts,
+				ExplicitBounds:    v.Aggregation.Buckets,
+				Exemplars:         otlpExemplars(data.ExemplarsPerBucket),
+			})
+		}
+	}
+	return m
+}
+
+// otlpExemplars converts exemplars recorded with attachments (like segment's seqNo)
+func otlpExemplars(exemplars []*metricdata.Exemplar) []otlpExemplar {
+	var res []otlpExemplar
+	for _, e := range exemplars {
+		if e == nil {
+			continue
+		}
+		keys := make([]string, 0, len(e.Attachments))
+		for k := range e.Attachments {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		attrs := make([]otlpKeyValue, 0, len(keys))
+		for _, k := range keys {
+			attrs = append(attrs, otlpKeyValue{Key: k, Value: otlpAnyValue{StringValue: fmt.Sprint(e.Attachments[k])}})
+		}
+		res = append(res, otlpExemplar{
+			FilteredAttributes: attrs,
+			TimeUnixNano:       strconv.FormatInt(e.Timestamp.UnixNano(), 10),
+			AsDouble:           e.Value,
+		})
+	}
+	return res
+}
@@ -0,0 +1,187 @@
+package monitor
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opencensus.io/metric/metricdata"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+func TestOTLPURL(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("https://collector:4318/v1/metrics", otlpURL("collector:4318", false))
+	assert.Equal("http://collector:4318/v1/metrics", otlpURL("collector:4318", true))
+	assert.Equal("http://collector:4318/v1/metrics", otlpURL("http://collector:4318/", false))
+	assert.Equal("https://collector/custom/v1/metrics", otlpURL("https://collector/custom/v1/metrics", true))
+}
+
+func TestOTLPExporter_Push(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var got otlpExportRequest
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(otlpMetricsPath, r.URL.Path)
+		assert.Equal("application/json", r.Header.Get("Content-Type"))
+		body, _ := ioutil.ReadAll(r.Body)
+		assert.Nil(json.Unmarshal(body, &got))
+	}))
+	defer ts.Close()
+
+	exp := newOTLPExporter(otlpURL(ts.URL, true), "orch", "testid")
+	// nothing to push
+	assert.Nil(exp.push())
+
+	kNode := tag.MustNewKey("node_id")
+	start := time.Unix(100, 0)
+	end := time.Unix(200, 0)
+	exp.ExportView(&view.Data{
+		View: &view.View{
+			Name:        "test_count",
+			Description: "test count",
+			Measure:     stats.Int64("otlp_test_count", "test count", "tot"),
+			Aggregation: view.Count(),
+		},
+		Start: start,
+		End:   end,
+		Rows:  []*view.Row{{Tags: []tag.Tag{{Key: kNode, Value: "testid"}}, Data: &view.CountData{Value: 5}}},
+	})
+	exp.ExportView(&view.Data{
+		View: &view.View{
+			Name:        "test_dist",
+			Measure:     stats.Float64("otlp_test_dist", "test dist", "sec"),
+			Aggregation: view.Distribution(0, 1, 2),
+		},
+		Start: start,
+		End:   end,
+		Rows: []*view.Row{{Data: &view.DistributionData{
+			Count:          4,
+			Mean:           1.5,
+			CountPerBucket: []int64{0, 1, 2, 1},
+			ExemplarsPerBucket: []*metricdata.Exemplar{nil, nil, {
+				Value:       1.5,
+				Timestamp:   end,
+				Attachments: metricdata.Attachments{"seqNo": uint64(7), "nonce": uint64(3)},
+			}, nil},
+		}}},
+	})
+	require.Nil(exp.push())
+
+	require.Len(got.ResourceMetrics, 1)
+	rm := got.ResourceMetrics[0]
+	assert.Equal([]otlpKeyValue{
+		{Key: "node_type", Value: otlpAnyValue{StringValue: "orch"}},
+		{Key: "node_id", Value: otlpAnyValue{StringValue: "testid"}},
+	}, rm.Resource.Attributes)
+	require.Len(rm.ScopeMetrics, 1)
+	assert.Equal(otlpScopeName, rm.ScopeMetrics[0].Scope.Name)
+	metrics := make(map[string]*otlpMetric)
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		metrics[m.Name] = m
+	}
+	require.Len(metrics, 2)
+
+	count := metrics["test_count"]
+	require.NotNil(count.Sum)
+	assert.True(count.Sum.IsMonotonic)
+	assert.Equal(otlpCumulative, count.Sum.AggregationTemporality)
+	require.Len(count.Sum.DataPoints, 1)
+	assert.Equal("5", count.Sum.DataPoints[0].AsInt)
+	assert.Equal("100000000000", count.Sum.DataPoints[0].StartTimeUnixNano)
+	assert.Equal("200000000000", count.Sum.DataPoints[0].TimeUnixNano)
+	assert.Equal([]otlpKeyValue{{Key: "node_id", Value: otlpAnyValue{StringValue: "testid"}}}, count.Sum.DataPoints[0].Attributes)
+
+	dist := metrics["test_dist"]
+	assert.Equal("sec", dist.Unit)
+	require.NotNil(dist.Histogram)
+	require.Len(dist.Histogram.DataPoints, 1)
+	dp := dist.Histogram.DataPoints[0]
+	assert.Equal("4", dp.Count)
+	assert.Equal(6.0, dp.Sum)
+	assert.Equal([]string{"0", "1", "2", "1"}, dp.BucketCounts)
+	assert.Equal([]float64{0, 1, 2}, dp.ExplicitBounds)
+	assert.Equal([]otlpExemplar{{
+		FilteredAttributes: []otlpKeyValue{
+			{Key: "nonce", Value: otlpAnyValue{StringValue: "3"}},
+			{Key: "seqNo", Value: otlpAnyValue{StringValue: "7"}},
+		},
+		TimeUnixNano: "200000000000",
+		AsDouble:     1.5,
+	}}, dp.Exemplars)
+
+	// pending metrics are cleared after push
+	got = otlpExportRequest{}
+	require.Nil(exp.push())
+	assert.Len(got.ResourceMetrics, 0)
+}
+
+func TestOTLPExporter_PushError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("bad data"))
+	}))
+	defer ts.Close()
+
+	exp := newOTLPExporter(otlpURL(ts.URL, true), "orch", "testid")
+	exp.ExportView(&view.Data{
+		View: &view.View{
+			Name:        "test_last",
+			Measure:     stats.Float64("otlp_test_last", "test last", "tot"),
+			Aggregation: view.LastValue(),
+		},
+		Rows: []*view.Row{{Data: &view.LastValueData{Value: 1}}},
+	})
+	err := exp.push()
+	assert.EqualError(t, err, "status=400 body=bad data")
+}
+
+func TestOTLPExporter_Stop(t *testing.T) {
+	assert := assert.New(t)
+
+	pushes := make(chan otlpExportRequest, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req otlpExportRequest
+		body, _ := ioutil.ReadAll(r.Body)
+		assert.Nil(json.Unmarshal(body, &req))
+		pushes <- req
+	}))
+	defer ts.Close()
+
+	defer func(interval time.Duration) { otlpPushInterval = interval }(otlpPushInterval)
+	otlpPushInterval = time.Hour
+	exp := newOTLPExporter(otlpURL(ts.URL, true), "orch", "testid")
+	go exp.pushLoop()
+	exp.ExportView(&view.Data{
+		View: &view.View{
+			Name:        "test_last",
+			Measure:     stats.Float64("otlp_test_last", "test last", "tot"),
+			Aggregation: view.LastValue(),
+		},
+		Rows: []*view.Row{{Data: &view.LastValueData{Value: 1}}},
+	})
+
+	// pending metrics are pushed once stopped
+	exp.stop()
+	select {
+	case req := <-pushes:
+		if assert.Len(req.ResourceMetrics, 1) {
+			assert.Equal("test_last", req.ResourceMetrics[0].ScopeMetrics[0].Metrics[0].Name)
+		}
+	default:
+		assert.Fail("metrics weren't pushed")
+	}
+	select {
+	case <-exp.done:
+	default:
+		assert.Fail("push loop didn't stop")
+	}
+}
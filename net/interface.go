@@ -18,6 +18,7 @@ type NodeStatus struct {
 	GOOS                        string
 	RegisteredTranscodersNumber int
 	RegisteredTranscoders       []RemoteTranscoderInfo
-	LocalTranscoding            bool // Indicates orchestrator that is also transcoder
+	LocalTranscoding            bool               // Indicates orchestrator that is also transcoder
+	StreamCosts                 map[string]float64 `json:",omitempty"` // Total value of tickets sent per manifestID, in gwei; only with monitoring enabled
 	// xxx add transcoder's version here
 }
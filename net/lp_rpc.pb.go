@@ -30,18 +30,21 @@ const (
 	OSInfo_DIRECT OSInfo_StorageType = 0
 	OSInfo_S3     OSInfo_StorageType = 1
 	OSInfo_GOOGLE OSInfo_StorageType = 2
+	OSInfo_AZURE  OSInfo_StorageType = 3
 )
 
 var OSInfo_StorageType_name = map[int32]string{
 	0: "DIRECT",
 	1: "S3",
 	2: "GOOGLE",
+	3: "AZURE",
 }
 
 var OSInfo_StorageType_value = map[string]int32{
 	"DIRECT": 0,
 	"S3":     1,
 	"GOOGLE": 2,
+	"AZURE":  3,
 }
 
 func (x OSInfo_StorageType) String() string {
@@ -75,7 +78,7 @@ func (x VideoProfile_Format) String() string {
 }
 
 func (VideoProfile_Format) EnumDescriptor() ([]byte, []int) {
-	return fileDescriptor_034e29c79f9ba827, []int{8, 0}
+	return fileDescriptor_034e29c79f9ba827, []int{9, 0}
 }
 
 type VideoProfile_Profile int32
@@ -109,7 +112,7 @@ func (x VideoProfile_Profile) String() string {
 }
 
 func (VideoProfile_Profile) EnumDescriptor() ([]byte, []int) {
-	return fileDescriptor_034e29c79f9ba827, []int{8, 1}
+	return fileDescriptor_034e29c79f9ba827, []int{9, 1}
 }
 
 type PingPong struct {
@@ -210,6 +213,7 @@ type OSInfo struct {
 	// Storage type: direct, s3, ipfs.
 	StorageType          OSInfo_StorageType `protobuf:"varint,1,opt,name=storageType,proto3,enum=net.OSInfo_StorageType" json:"storageType,omitempty"`
 	S3Info               *S3OSInfo          `protobuf:"bytes,16,opt,name=s3info,proto3" json:"s3info,omitempty"`
+	AzureInfo            *AzureOSInfo       `protobuf:"bytes,17,opt,name=azureInfo,proto3" json:"azureInfo,omitempty"`
 	XXX_NoUnkeyedLiteral struct{}           `json:"-"`
 	XXX_unrecognized     []byte             `json:"-"`
 	XXX_sizecache        int32              `json:"-"`
@@ -254,6 +258,13 @@ func (m *OSInfo) GetS3Info() *S3OSInfo {
 	return nil
 }
 
+func (m *OSInfo) GetAzureInfo() *AzureOSInfo {
+	if m != nil {
+		return m.AzureInfo
+	}
+	return nil
+}
+
 type S3OSInfo struct {
 	// Host to use to connect to S3
 	Host string `protobuf:"bytes,1,opt,name=host,proto3" json:"host,omitempty"`
@@ -339,6 +350,65 @@ func (m *S3OSInfo) GetXAmzDate() string {
 	return ""
 }
 
+type AzureOSInfo struct {
+	// URL of the Blob Storage container
+	Host string `protobuf:"bytes,1,opt,name=host,proto3" json:"host,omitempty"`
+	// Key (prefix) to use when uploading the blob.
+	Key string `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	// Time-limited SAS token that container owner node creates to give write
+	// access to other node.
+	SasToken             string   `protobuf:"bytes,3,opt,name=sasToken,proto3" json:"sasToken,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *AzureOSInfo) Reset()         { *m = AzureOSInfo{} }
+func (m *AzureOSInfo) String() string { return proto.CompactTextString(m) }
+func (*AzureOSInfo) ProtoMessage()    {}
+func (*AzureOSInfo) Descriptor() ([]byte, []int) {
+	return fileDescriptor_034e29c79f9ba827, []int{4}
+}
+
+func (m *AzureOSInfo) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_AzureOSInfo.Unmarshal(m, b)
+}
+func (m *AzureOSInfo) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_AzureOSInfo.Marshal(b, m, deterministic)
+}
+func (m *AzureOSInfo) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_AzureOSInfo.Merge(m, src)
+}
+func (m *AzureOSInfo) XXX_Size() int {
+	return xxx_messageInfo_AzureOSInfo.Size(m)
+}
+func (m *AzureOSInfo) XXX_DiscardUnknown() {
+	xxx_messageInfo_AzureOSInfo.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_AzureOSInfo proto.InternalMessageInfo
+
+func (m *AzureOSInfo) GetHost() string {
+	if m != nil {
+		return m.Host
+	}
+	return ""
+}
+
+func (m *AzureOSInfo) GetKey() string {
+	if m != nil {
+		return m.Key
+	}
+	return ""
+}
+
+func (m *AzureOSInfo) GetSasToken() string {
+	if m != nil {
+		return m.SasToken
+	}
+	return ""
+}
+
 // PriceInfo conveys pricing info for transcoding services
 type PriceInfo struct {
 	// price in wei
@@ -355,7 +425,7 @@ func (m *PriceInfo) Reset()         { *m = PriceInfo{} }
 func (m *PriceInfo) String() string { return proto.CompactTextString(m) }
 func (*PriceInfo) ProtoMessage()    {}
 func (*PriceInfo) Descriptor() ([]byte, []int) {
-	return fileDescriptor_034e29c79f9ba827, []int{4}
+	return fileDescriptor_034e29c79f9ba827, []int{5}
 }
 
 func (m *PriceInfo) XXX_Unmarshal(b []byte) error {
@@ -404,7 +474,7 @@ func (m *Capabilities) Reset()         { *m = Capabilities{} }
 func (m *Capabilities) String() string { return proto.CompactTextString(m) }
 func (*Capabilities) ProtoMessage()    {}
 func (*Capabilities) Descriptor() ([]byte, []int) {
-	return fileDescriptor_034e29c79f9ba827, []int{5}
+	return fileDescriptor_034e29c79f9ba827, []int{6}
 }
 
 func (m *Capabilities) XXX_Unmarshal(b []byte) error {
@@ -450,7 +520,7 @@ func (m *Capabilities_Constraints) Reset()         { *m = Capabilities_Constrain
 func (m *Capabilities_Constraints) String() string { return proto.CompactTextString(m) }
 func (*Capabilities_Constraints) ProtoMessage()    {}
 func (*Capabilities_Constraints) Descriptor() ([]byte, []int) {
-	return fileDescriptor_034e29c79f9ba827, []int{5, 0}
+	return fileDescriptor_034e29c79f9ba827, []int{6, 0}
 }
 
 func (m *Capabilities_Constraints) XXX_Unmarshal(b []byte) error {
@@ -495,7 +565,7 @@ func (m *OrchestratorInfo) Reset()         { *m = OrchestratorInfo{} }
 func (m *OrchestratorInfo) String() string { return proto.CompactTextString(m) }
 func (*OrchestratorInfo) ProtoMessage()    {}
 func (*OrchestratorInfo) Descriptor() ([]byte, []int) {
-	return fileDescriptor_034e29c79f9ba827, []int{6}
+	return fileDescriptor_034e29c79f9ba827, []int{7}
 }
 
 func (m *OrchestratorInfo) XXX_Unmarshal(b []byte) error {
@@ -595,7 +665,7 @@ func (m *SegData) Reset()         { *m = SegData{} }
 func (m *SegData) String() string { return proto.CompactTextString(m) }
 func (*SegData) ProtoMessage()    {}
 func (*SegData) Descriptor() ([]byte, []int) {
-	return fileDescriptor_034e29c79f9ba827, []int{7}
+	return fileDescriptor_034e29c79f9ba827, []int{8}
 }
 
 func (m *SegData) XXX_Unmarshal(b []byte) error {
@@ -720,7 +790,7 @@ func (m *VideoProfile) Reset()         { *m = VideoProfile{} }
 func (m *VideoProfile) String() string { return proto.CompactTextString(m) }
 func (*VideoProfile) ProtoMessage()    {}
 func (*VideoProfile) Descriptor() ([]byte, []int) {
-	return fileDescriptor_034e29c79f9ba827, []int{8}
+	return fileDescriptor_034e29c79f9ba827, []int{9}
 }
 
 func (m *VideoProfile) XXX_Unmarshal(b []byte) error {
@@ -819,7 +889,7 @@ func (m *TranscodedSegmentData) Reset()         { *m = TranscodedSegmentData{} }
 func (m *TranscodedSegmentData) String() string { return proto.CompactTextString(m) }
 func (*TranscodedSegmentData) ProtoMessage()    {}
 func (*TranscodedSegmentData) Descriptor() ([]byte, []int) {
-	return fileDescriptor_034e29c79f9ba827, []int{9}
+	return fileDescriptor_034e29c79f9ba827, []int{10}
 }
 
 func (m *TranscodedSegmentData) XXX_Unmarshal(b []byte) error {
@@ -869,7 +939,7 @@ func (m *TranscodeData) Reset()         { *m = TranscodeData{} }
 func (m *TranscodeData) String() string { return proto.CompactTextString(m) }
 func (*TranscodeData) ProtoMessage()    {}
 func (*TranscodeData) Descriptor() ([]byte, []int) {
-	return fileDescriptor_034e29c79f9ba827, []int{10}
+	return fileDescriptor_034e29c79f9ba827, []int{11}
 }
 
 func (m *TranscodeData) XXX_Unmarshal(b []byte) error {
@@ -925,7 +995,7 @@ func (m *TranscodeResult) Reset()         { *m = TranscodeResult{} }
 func (m *TranscodeResult) String() string { return proto.CompactTextString(m) }
 func (*TranscodeResult) ProtoMessage()    {}
 func (*TranscodeResult) Descriptor() ([]byte, []int) {
-	return fileDescriptor_034e29c79f9ba827, []int{11}
+	return fileDescriptor_034e29c79f9ba827, []int{12}
 }
 
 func (m *TranscodeResult) XXX_Unmarshal(b []byte) error {
@@ -1020,7 +1090,7 @@ func (m *RegisterRequest) Reset()         { *m = RegisterRequest{} }
 func (m *RegisterRequest) String() string { return proto.CompactTextString(m) }
 func (*RegisterRequest) ProtoMessage()    {}
 func (*RegisterRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_034e29c79f9ba827, []int{12}
+	return fileDescriptor_034e29c79f9ba827, []int{13}
 }
 
 func (m *RegisterRequest) XXX_Unmarshal(b []byte) error {
@@ -1075,7 +1145,7 @@ func (m *NotifySegment) Reset()         { *m = NotifySegment{} }
 func (m *NotifySegment) String() string { return proto.CompactTextString(m) }
 func (*NotifySegment) ProtoMessage()    {}
 func (*NotifySegment) Descriptor() ([]byte, []int) {
-	return fileDescriptor_034e29c79f9ba827, []int{13}
+	return fileDescriptor_034e29c79f9ba827, []int{14}
 }
 
 func (m *NotifySegment) XXX_Unmarshal(b []byte) error {
@@ -1151,7 +1221,7 @@ func (m *TicketParams) Reset()         { *m = TicketParams{} }
 func (m *TicketParams) String() string { return proto.CompactTextString(m) }
 func (*TicketParams) ProtoMessage()    {}
 func (*TicketParams) Descriptor() ([]byte, []int) {
-	return fileDescriptor_034e29c79f9ba827, []int{14}
+	return fileDescriptor_034e29c79f9ba827, []int{15}
 }
 
 func (m *TicketParams) XXX_Unmarshal(b []byte) error {
@@ -1237,7 +1307,7 @@ func (m *TicketSenderParams) Reset()         { *m = TicketSenderParams{} }
 func (m *TicketSenderParams) String() string { return proto.CompactTextString(m) }
 func (*TicketSenderParams) ProtoMessage()    {}
 func (*TicketSenderParams) Descriptor() ([]byte, []int) {
-	return fileDescriptor_034e29c79f9ba827, []int{15}
+	return fileDescriptor_034e29c79f9ba827, []int{16}
 }
 
 func (m *TicketSenderParams) XXX_Unmarshal(b []byte) error {
@@ -1287,7 +1357,7 @@ func (m *TicketExpirationParams) Reset()         { *m = TicketExpirationParams{}
 func (m *TicketExpirationParams) String() string { return proto.CompactTextString(m) }
 func (*TicketExpirationParams) ProtoMessage()    {}
 func (*TicketExpirationParams) Descriptor() ([]byte, []int) {
-	return fileDescriptor_034e29c79f9ba827, []int{16}
+	return fileDescriptor_034e29c79f9ba827, []int{17}
 }
 
 func (m *TicketExpirationParams) XXX_Unmarshal(b []byte) error {
@@ -1345,7 +1415,7 @@ func (m *Payment) Reset()         { *m = Payment{} }
 func (m *Payment) String() string { return proto.CompactTextString(m) }
 func (*Payment) ProtoMessage()    {}
 func (*Payment) Descriptor() ([]byte, []int) {
-	return fileDescriptor_034e29c79f9ba827, []int{17}
+	return fileDescriptor_034e29c79f9ba827, []int{18}
 }
 
 func (m *Payment) XXX_Unmarshal(b []byte) error {
@@ -1409,6 +1479,7 @@ func init() {
 	proto.RegisterType((*OrchestratorRequest)(nil), "net.OrchestratorRequest")
 	proto.RegisterType((*OSInfo)(nil), "net.OSInfo")
 	proto.RegisterType((*S3OSInfo)(nil), "net.S3OSInfo")
+	proto.RegisterType((*AzureOSInfo)(nil), "net.AzureOSInfo")
 	proto.RegisterType((*PriceInfo)(nil), "net.PriceInfo")
 	proto.RegisterType((*Capabilities)(nil), "net.Capabilities")
 	proto.RegisterType((*Capabilities_Constraints)(nil), "net.Capabilities.Constraints")
@@ -1429,97 +1500,100 @@ func init() {
 func init() { proto.RegisterFile("net/lp_rpc.proto", fileDescriptor_034e29c79f9ba827) }
 
 var fileDescriptor_034e29c79f9ba827 = []byte{
-	// 1427 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x57, 0xdd, 0x6f, 0xdb, 0x38,
-	0x12, 0x8f, 0x2c, 0xc7, 0x1f, 0x63, 0x3b, 0x51, 0x98, 0x8f, 0x2a, 0xb9, 0x6b, 0xe1, 0xea, 0xda,
-	0x43, 0xfa, 0xd0, 0x5c, 0xe1, 0xb4, 0x3d, 0xf4, 0xed, 0xf2, 0xe1, 0x26, 0x2e, 0x5a, 0xc7, 0xa0,
-	0xd3, 0x02, 0xf7, 0x70, 0x30, 0x14, 0x89, 0x76, 0x78, 0x71, 0x24, 0x95, 0xa2, 0xaf, 0x49, 0xff,
-	0x83, 0x7b, 0xdc, 0xb7, 0xdd, 0x7d, 0xd9, 0xc5, 0x02, 0xfb, 0x7f, 0x2e, 0x38, 0xa4, 0x14, 0x39,
-	0xc9, 0x02, 0xc5, 0x62, 0xdf, 0xf8, 0xfb, 0xcd, 0x70, 0x38, 0x1c, 0x0d, 0x7f, 0xa4, 0xc0, 0x89,
-	0x98, 0xfc, 0xc7, 0x34, 0x19, 0x89, 0x24, 0xd8, 0x49, 0x44, 0x2c, 0x63, 0x62, 0x47, 0x4c, 0x7a,
-	0x6d, 0xa8, 0x0d, 0x78, 0x34, 0x19, 0xc4, 0xd1, 0x84, 0xac, 0xc1, 0xe2, 0xff, 0xfc, 0xe9, 0x8c,
-	0xb9, 0x56, 0xdb, 0xda, 0x6e, 0x52, 0x0d, 0xbc, 0x3d, 0x58, 0x3d, 0x11, 0xc1, 0x39, 0x4b, 0xa5,
-	0xf0, 0x65, 0x2c, 0x28, 0xfb, 0x3c, 0x63, 0xa9, 0x24, 0x2e, 0x54, 0xfd, 0x30, 0x14, 0x2c, 0x4d,
-	0x8d, 0x7b, 0x06, 0x89, 0x03, 0x76, 0xca, 0x27, 0x6e, 0x09, 0x59, 0x35, 0xf4, 0x7e, 0xb0, 0xa0,
-	0x72, 0x32, 0xec, 0x45, 0xe3, 0x98, 0xbc, 0x81, 0x46, 0x2a, 0x63, 0xe1, 0x4f, 0xd8, 0xe9, 0x75,
-	0xa2, 0x57, 0x5a, 0xea, 0x3c, 0xd8, 0x89, 0x98, 0xdc, 0xd1, 0x1e, 0x3b, 0xc3, 0x1b, 0x33, 0x2d,
-	0xfa, 0x92, 0xa7, 0x50, 0x49, 0x77, 0x79, 0x34, 0x8e, 0x5d, 0xa7, 0x6d, 0x6d, 0x37, 0x3a, 0x2d,
-	0x9c, 0x35, 0xdc, 0xd5, 0xf3, 0xa8, 0x31, 0x7a, 0xcf, 0xa1, 0x51, 0x08, 0x41, 0x00, 0x2a, 0x87,
-	0x3d, 0xda, 0x3d, 0x38, 0x75, 0x16, 0x48, 0x05, 0x4a, 0xc3, 0x5d, 0xc7, 0x52, 0xdc, 0xd1, 0xc9,
-	0xc9, 0xd1, 0xfb, 0xae, 0x53, 0xf2, 0x7e, 0xb1, 0xa0, 0x96, 0xc5, 0x20, 0x04, 0xca, 0xe7, 0x71,
-	0x2a, 0x31, 0xad, 0x3a, 0xc5, 0xb1, 0xda, 0xce, 0x05, 0xbb, 0xc6, 0xed, 0xd4, 0xa9, 0x1a, 0x92,
-	0x0d, 0xa8, 0x24, 0xf1, 0x94, 0x07, 0xd7, 0xae, 0x8d, 0xa4, 0x41, 0xe4, 0xaf, 0x50, 0x4f, 0xf9,
-	0x24, 0xf2, 0xe5, 0x4c, 0x30, 0xb7, 0x8c, 0xa6, 0x1b, 0x82, 0x3c, 0x02, 0x08, 0x04, 0x0b, 0x59,
-	0x24, 0xb9, 0x3f, 0x75, 0x17, 0xd1, 0x5c, 0x60, 0xc8, 0x16, 0xd4, 0xae, 0xf6, 0x2e, 0xbf, 0x1e,
-	0xfa, 0x92, 0xb9, 0x15, 0xb4, 0xe6, 0xd8, 0xfb, 0x08, 0xf5, 0x81, 0xe0, 0x01, 0xc3, 0x24, 0x3d,
-	0x68, 0x26, 0x0a, 0x0c, 0x98, 0xf8, 0x18, 0x71, 0x9d, 0xac, 0x4d, 0xe7, 0x38, 0xf2, 0x04, 0x5a,
-	0x09, 0xbf, 0x62, 0xd3, 0x34, 0x73, 0x2a, 0xa1, 0xd3, 0x3c, 0xe9, 0xfd, 0x07, 0x9a, 0x07, 0x7e,
-	0xe2, 0x9f, 0xf1, 0x29, 0x97, 0x9c, 0xa5, 0x6a, 0x03, 0x67, 0x5c, 0xa6, 0x52, 0xf0, 0x68, 0xe2,
-	0x5a, 0x6d, 0x7b, 0xbb, 0x4c, 0x6f, 0x08, 0xd2, 0x86, 0xc6, 0xa5, 0x1f, 0x85, 0xaa, 0x09, 0x38,
-	0x4b, 0xdd, 0x12, 0xda, 0x8b, 0xd4, 0x56, 0x0b, 0x1a, 0x07, 0x71, 0xa4, 0x1a, 0x85, 0x47, 0x32,
-	0xf5, 0xbe, 0x2b, 0x81, 0x53, 0x6c, 0x1d, 0xcc, 0xfe, 0x11, 0x80, 0x14, 0x7e, 0x94, 0x06, 0x71,
-	0xc8, 0x84, 0x29, 0x74, 0x81, 0x21, 0xaf, 0xa1, 0x25, 0x79, 0x70, 0xc1, 0xe4, 0x28, 0xf1, 0x85,
-	0x7f, 0x99, 0x62, 0xe6, 0x8d, 0xce, 0x0a, 0x7e, 0xec, 0x53, 0xb4, 0x0c, 0xd0, 0x40, 0x9b, 0xb2,
-	0x80, 0xc8, 0x73, 0x00, 0xac, 0xc0, 0x08, 0x3b, 0xc4, 0xc6, 0x49, 0x4b, 0x38, 0x29, 0xaf, 0x1c,
-	0xad, 0x27, 0x79, 0x11, 0x0b, 0xed, 0x5b, 0x9e, 0x6f, 0xdf, 0x57, 0xd0, 0x0c, 0x0a, 0x45, 0xc1,
-	0x2f, 0x95, 0xad, 0x5f, 0xac, 0x16, 0x9d, 0x73, 0x23, 0x4f, 0xa1, 0x6a, 0x9a, 0xd5, 0x6d, 0xb7,
-	0xed, 0xed, 0x46, 0xa7, 0x51, 0x68, 0x6a, 0x9a, 0xd9, 0xbc, 0x9f, 0x6d, 0xa8, 0x0e, 0xd9, 0xe4,
-	0xd0, 0x97, 0xbe, 0x2a, 0xc5, 0xa5, 0x1f, 0xf1, 0x31, 0x4b, 0x65, 0x2f, 0x34, 0xa7, 0xa8, 0xc0,
-	0xe0, 0x41, 0x62, 0x9f, 0xcd, 0xa7, 0x53, 0x43, 0xec, 0x4f, 0x3f, 0x3d, 0xc7, 0xed, 0x35, 0x29,
-	0x8e, 0x55, 0xdf, 0x24, 0x22, 0x1e, 0xf3, 0x29, 0xcb, 0xb6, 0x92, 0xe3, 0xec, 0x28, 0x2e, 0xe6,
-	0x47, 0x51, 0x79, 0x87, 0x33, 0xe1, 0x4b, 0x1e, 0x47, 0xd8, 0x65, 0x8b, 0x34, 0xc7, 0x77, 0x76,
-	0x5e, 0xfd, 0x33, 0x77, 0xae, 0xa2, 0x8f, 0x67, 0xd3, 0xe9, 0x20, 0xcb, 0xf5, 0x31, 0xfa, 0xea,
-	0xe8, 0x9f, 0x78, 0xc8, 0x62, 0x63, 0xa1, 0x73, 0x6e, 0xe4, 0x9f, 0xd0, 0x2a, 0xe2, 0x8e, 0xeb,
-	0xfd, 0xde, 0xbc, 0x79, 0xbf, 0xdb, 0x13, 0x77, 0xdd, 0xbf, 0x7d, 0xd3, 0xc4, 0x5d, 0xef, 0x7b,
-	0x1b, 0x9a, 0x45, 0xbb, 0xaa, 0x7a, 0xe4, 0x5f, 0x32, 0x94, 0x9d, 0x3a, 0xc5, 0xb1, 0xd2, 0xca,
-	0x2f, 0x3c, 0x94, 0xe7, 0xee, 0x0a, 0x16, 0x51, 0x03, 0xa5, 0x0c, 0xe7, 0x8c, 0x4f, 0xce, 0xa5,
-	0x4b, 0x90, 0x36, 0x48, 0x75, 0xdb, 0x19, 0x57, 0x87, 0x80, 0xb9, 0xab, 0x68, 0xc8, 0xa0, 0xfa,
-	0x42, 0xe3, 0x24, 0x75, 0xd7, 0xda, 0xd6, 0x76, 0x8b, 0xaa, 0x21, 0x79, 0x01, 0x95, 0x71, 0x2c,
-	0x2e, 0x7d, 0xe9, 0xae, 0xa3, 0x38, 0xba, 0x77, 0x12, 0xde, 0x79, 0x8b, 0x76, 0x6a, 0xfc, 0xd4,
-	0xaa, 0xe3, 0x24, 0x3d, 0x64, 0x91, 0xbb, 0x81, 0x61, 0x0c, 0x22, 0xbb, 0x50, 0x35, 0x9d, 0xe0,
-	0x3e, 0xc0, 0x50, 0x9b, 0x77, 0x43, 0x65, 0x35, 0xc8, 0x3c, 0x55, 0x42, 0x93, 0x38, 0x71, 0x5d,
-	0x4c, 0x53, 0x0d, 0xbd, 0x87, 0x50, 0xd1, 0x0b, 0x2a, 0xdd, 0xfc, 0x30, 0xe8, 0x1e, 0x9d, 0x0e,
-	0x9d, 0x05, 0x52, 0x05, 0xfb, 0xc3, 0xe0, 0xa5, 0x63, 0x79, 0xff, 0x85, 0x6a, 0x56, 0xa8, 0x55,
-	0x58, 0xee, 0xf6, 0x0f, 0x4e, 0x0e, 0xbb, 0x74, 0x74, 0xd8, 0x7d, 0xbb, 0xf7, 0xf1, 0xbd, 0x12,
-	0xdd, 0x15, 0x68, 0x1d, 0x77, 0x5e, 0xbf, 0x1c, 0xed, 0xef, 0x0d, 0xbb, 0xef, 0x7b, 0xfd, 0xae,
-	0x63, 0x91, 0x16, 0xd4, 0x91, 0xfa, 0xb0, 0xd7, 0xeb, 0x3b, 0xa5, 0x1c, 0x1e, 0xf7, 0x8e, 0x8e,
-	0x1d, 0x9b, 0x6c, 0xc2, 0x3a, 0xc2, 0x83, 0x93, 0xfe, 0xf0, 0x94, 0xee, 0xf5, 0xfa, 0xdd, 0x43,
-	0x6d, 0x2a, 0x7b, 0x7b, 0xb0, 0x7e, 0x9a, 0x49, 0x45, 0x38, 0x64, 0x93, 0x4b, 0x16, 0x49, 0x3c,
-	0x4a, 0x0e, 0xd8, 0x33, 0x31, 0x35, 0x72, 0xa2, 0x86, 0x28, 0xd2, 0x28, 0x76, 0xe6, 0xfc, 0x18,
-	0xe4, 0xfd, 0x1b, 0x5a, 0x79, 0x08, 0x9c, 0xfa, 0x1a, 0x6a, 0xa9, 0x8e, 0x94, 0xa2, 0xe6, 0x35,
-	0x3a, 0x5b, 0x5a, 0x6b, 0xee, 0x5b, 0x88, 0xe6, 0xbe, 0xf7, 0x5c, 0x73, 0x3f, 0x5a, 0xb0, 0x9c,
-	0xcf, 0xa2, 0x2c, 0x9d, 0x4d, 0x65, 0x76, 0x86, 0xad, 0x9b, 0x33, 0xbc, 0x01, 0x8b, 0x4c, 0x88,
-	0x58, 0xe8, 0x1b, 0xe5, 0x78, 0x81, 0x6a, 0x48, 0xb6, 0xa1, 0x1c, 0xfa, 0xd2, 0x37, 0xd2, 0x45,
-	0xe6, 0x73, 0x50, 0x6b, 0x1f, 0x2f, 0x50, 0xf4, 0x20, 0xcf, 0xa0, 0x5c, 0xb8, 0x06, 0xd7, 0xf5,
-	0x69, 0xbb, 0xa5, 0xb3, 0x14, 0x5d, 0xf6, 0x6b, 0x50, 0x11, 0x98, 0x88, 0xd7, 0x85, 0x65, 0xca,
-	0x26, 0x3c, 0x95, 0x2c, 0xbf, 0xc2, 0x37, 0xa0, 0x92, 0xb2, 0x40, 0xb0, 0xec, 0xbe, 0x33, 0x48,
-	0x69, 0x84, 0x3a, 0xe0, 0x01, 0x97, 0xd7, 0xa6, 0x78, 0x39, 0xf6, 0xfe, 0x6f, 0x41, 0xab, 0x1f,
-	0x4b, 0x3e, 0xbe, 0x36, 0x55, 0xb9, 0xa7, 0xf4, 0x7f, 0x87, 0x6a, 0xaa, 0x25, 0xce, 0x6c, 0xa6,
-	0xa9, 0x6f, 0x6a, 0xcd, 0xd1, 0xcc, 0xa8, 0xd6, 0x97, 0x7e, 0x7a, 0xd1, 0x0b, 0x71, 0x27, 0x36,
-	0x35, 0x68, 0x4e, 0xd1, 0x56, 0xe6, 0x15, 0xed, 0x5d, 0xb9, 0x56, 0x72, 0xec, 0x77, 0xe5, 0xda,
-	0x63, 0xc7, 0xf3, 0x7e, 0x2a, 0x41, 0xb3, 0x78, 0x23, 0xa8, 0xfb, 0x4b, 0xb0, 0x80, 0x27, 0x9c,
-	0x45, 0xd2, 0xe8, 0xe9, 0x0d, 0x41, 0x1e, 0x02, 0x8c, 0xfd, 0x80, 0x8d, 0xf4, 0x1b, 0x47, 0x7f,
-	0xb7, 0xba, 0x62, 0x3e, 0x29, 0x82, 0x6c, 0x42, 0xed, 0x0b, 0x8f, 0x46, 0x89, 0x88, 0xcf, 0x8c,
-	0xbe, 0x56, 0xbf, 0xf0, 0x68, 0x20, 0xe2, 0x33, 0xb2, 0x03, 0xab, 0x79, 0x98, 0x91, 0xf0, 0xa3,
-	0x70, 0x84, 0x2a, 0xac, 0xd5, 0x76, 0x25, 0x37, 0x51, 0x3f, 0x0a, 0x8f, 0x95, 0x24, 0x13, 0x28,
-	0xa7, 0x8c, 0x85, 0x46, 0x77, 0x71, 0x4c, 0x9e, 0x81, 0xc3, 0xae, 0x12, 0xae, 0xa5, 0x76, 0x74,
-	0x36, 0x8d, 0x83, 0x0b, 0x14, 0xe0, 0x26, 0x5d, 0xbe, 0xe1, 0xf7, 0x15, 0x4d, 0x8e, 0x61, 0xa5,
-	0xe0, 0x6a, 0xae, 0x41, 0x2d, 0xc6, 0x7f, 0x29, 0x5c, 0x83, 0xdd, 0xdc, 0xc7, 0x5c, 0x88, 0x85,
-	0x05, 0x34, 0xe3, 0xf5, 0x80, 0x68, 0xdf, 0x21, 0x8b, 0x42, 0x26, 0x4c, 0x99, 0x1e, 0x43, 0x33,
-	0x45, 0x3c, 0x8a, 0xe2, 0x28, 0xd0, 0x8f, 0xb0, 0x16, 0x6d, 0x68, 0xae, 0xaf, 0xa8, 0x7b, 0x9a,
-	0xfb, 0x2b, 0x6c, 0xdc, 0xbf, 0x2c, 0x79, 0x0a, 0x4b, 0x81, 0x60, 0x3a, 0x59, 0x11, 0xcf, 0xa2,
-	0xd0, 0x74, 0x7b, 0x2b, 0x63, 0xa9, 0x22, 0xc9, 0x1b, 0xd8, 0x9c, 0x77, 0xd3, 0x45, 0xd0, 0xa5,
-	0xd4, 0x0b, 0x6d, 0xcc, 0xcd, 0xc0, 0x62, 0xa8, 0x7a, 0x7a, 0xbf, 0x96, 0xa0, 0x3a, 0xf0, 0xaf,
-	0xb1, 0xdd, 0xee, 0xbc, 0x0f, 0xac, 0x6f, 0x7b, 0x1f, 0x60, 0xb3, 0xab, 0x0d, 0x9a, 0xb5, 0x0c,
-	0xba, 0xbf, 0xd8, 0xf6, 0x1f, 0x28, 0x36, 0xe9, 0xc1, 0x9a, 0xc9, 0xcc, 0x54, 0xd7, 0x04, 0x2b,
-	0xa3, 0xa8, 0x3c, 0x28, 0x04, 0x2b, 0x7e, 0x0d, 0x4a, 0xe4, 0xdd, 0x2f, 0xf4, 0x0a, 0x96, 0xd8,
-	0x55, 0xc2, 0x02, 0xc9, 0xc2, 0x11, 0xbe, 0x59, 0xcc, 0x2b, 0xe4, 0xf6, 0x83, 0xa6, 0x95, 0x79,
-	0x21, 0xd5, 0xb9, 0x82, 0x66, 0x51, 0x07, 0xc8, 0x3e, 0x2c, 0x1f, 0x31, 0x39, 0x47, 0xb9, 0x77,
-	0xd4, 0xc2, 0xa8, 0xc1, 0xd6, 0xfd, 0x3a, 0x42, 0x9e, 0x40, 0x59, 0xfd, 0x20, 0x10, 0xfd, 0xda,
-	0xce, 0xfe, 0x15, 0xb6, 0xe6, 0x61, 0xa7, 0x0f, 0x70, 0x7a, 0xf3, 0x86, 0xfb, 0x17, 0x90, 0x4c,
-	0x6b, 0x0a, 0xec, 0x1a, 0x4e, 0xb9, 0x25, 0x42, 0x5b, 0x5a, 0xe8, 0xe6, 0x24, 0xe5, 0x85, 0x75,
-	0x56, 0xc1, 0x5f, 0x94, 0xdd, 0xdf, 0x02, 0x00, 0x00, 0xff, 0xff, 0xb3, 0xe9, 0x2a, 0x3a, 0xb6,
-	0x0c, 0x00, 0x00,
+	// 1474 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x57, 0x5f, 0x6f, 0xdb, 0x46,
+	0x12, 0xb7, 0x44, 0xfd, 0x1d, 0x49, 0x36, 0xbd, 0xfe, 0x13, 0xda, 0x77, 0x09, 0x14, 0x5e, 0x72,
+	0x70, 0x1e, 0xce, 0x17, 0xc8, 0x49, 0xee, 0xf2, 0x76, 0xb2, 0xad, 0xd8, 0x0a, 0x12, 0x59, 0x58,
+	0xc9, 0x01, 0xae, 0x40, 0x21, 0xd0, 0xe4, 0x4a, 0xde, 0x5a, 0x26, 0x99, 0xe5, 0xaa, 0xb1, 0xf3,
+	0x0d, 0xfa, 0xd8, 0xb7, 0xa2, 0x2f, 0x2d, 0x0a, 0xf4, 0x1b, 0xf5, 0x03, 0x15, 0x3b, 0x5c, 0x52,
+	0x94, 0xad, 0x02, 0x41, 0xd1, 0x27, 0xed, 0xef, 0x37, 0xb3, 0xbb, 0xb3, 0xc3, 0x99, 0xdf, 0xae,
+	0xc0, 0xf4, 0x99, 0xfc, 0xf7, 0x34, 0x1c, 0x89, 0xd0, 0xdd, 0x0f, 0x45, 0x20, 0x03, 0x62, 0xf8,
+	0x4c, 0xda, 0x4d, 0xa8, 0xf4, 0xb9, 0x3f, 0xe9, 0x07, 0xfe, 0x84, 0x6c, 0x42, 0xf1, 0x5b, 0x67,
+	0x3a, 0x63, 0x56, 0xae, 0x99, 0xdb, 0xab, 0xd3, 0x18, 0xd8, 0x6d, 0xd8, 0x38, 0x13, 0xee, 0x25,
+	0x8b, 0xa4, 0x70, 0x64, 0x20, 0x28, 0xfb, 0x38, 0x63, 0x91, 0x24, 0x16, 0x94, 0x1d, 0xcf, 0x13,
+	0x2c, 0x8a, 0xb4, 0x7b, 0x02, 0x89, 0x09, 0x46, 0xc4, 0x27, 0x56, 0x1e, 0x59, 0x35, 0xb4, 0x7f,
+	0xcb, 0x41, 0xe9, 0x6c, 0xd0, 0xf5, 0xc7, 0x01, 0x79, 0x0d, 0xb5, 0x48, 0x06, 0xc2, 0x99, 0xb0,
+	0xe1, 0x6d, 0x18, 0xef, 0xb4, 0xda, 0x7a, 0xb0, 0xef, 0x33, 0xb9, 0x1f, 0x7b, 0xec, 0x0f, 0xe6,
+	0x66, 0x9a, 0xf5, 0x25, 0x4f, 0xa1, 0x14, 0x1d, 0x70, 0x7f, 0x1c, 0x58, 0x66, 0x33, 0xb7, 0x57,
+	0x6b, 0x35, 0x70, 0xd6, 0xe0, 0x20, 0x9e, 0x47, 0xb5, 0x91, 0xec, 0x43, 0xd5, 0xf9, 0x3c, 0x13,
+	0x4c, 0x91, 0xd6, 0x3a, 0x7a, 0x9a, 0xe8, 0xd9, 0x56, 0xac, 0x76, 0x9e, 0xbb, 0xd8, 0xff, 0x85,
+	0x5a, 0x66, 0x4b, 0x02, 0x50, 0x3a, 0xee, 0xd2, 0xce, 0xd1, 0xd0, 0x5c, 0x21, 0x25, 0xc8, 0x0f,
+	0x0e, 0xcc, 0x9c, 0xe2, 0x4e, 0xce, 0xce, 0x4e, 0xde, 0x75, 0xcc, 0x3c, 0xa9, 0x42, 0xb1, 0xfd,
+	0xd5, 0x39, 0xed, 0x98, 0x86, 0xfd, 0x4b, 0x0e, 0x2a, 0xc9, 0xf6, 0x84, 0x40, 0xe1, 0x32, 0x88,
+	0x24, 0x9e, 0xa8, 0x4a, 0x71, 0xac, 0x32, 0x71, 0xc5, 0x6e, 0x31, 0x13, 0x55, 0xaa, 0x86, 0x64,
+	0x1b, 0x4a, 0x61, 0x30, 0xe5, 0xee, 0xad, 0x65, 0x20, 0xa9, 0x11, 0xf9, 0x3b, 0x54, 0x23, 0x3e,
+	0xf1, 0x1d, 0x39, 0x13, 0xcc, 0x2a, 0xa0, 0x69, 0x4e, 0x90, 0x47, 0x00, 0xae, 0x60, 0x1e, 0xf3,
+	0x25, 0x77, 0xa6, 0x56, 0x11, 0xcd, 0x19, 0x86, 0xec, 0x42, 0xe5, 0xa6, 0x7d, 0xfd, 0xf9, 0xd8,
+	0x91, 0xcc, 0x2a, 0xa1, 0x35, 0xc5, 0xf6, 0x19, 0xd4, 0x32, 0x07, 0xff, 0xc2, 0x30, 0x77, 0xa1,
+	0x12, 0x39, 0xd1, 0x30, 0xb8, 0x62, 0xbe, 0x0e, 0x34, 0xc5, 0xf6, 0x39, 0x54, 0xfb, 0x82, 0xbb,
+	0x98, 0x3c, 0x62, 0x43, 0x3d, 0x54, 0xa0, 0xcf, 0xc4, 0xb9, 0xcf, 0xe3, 0x65, 0x0d, 0xba, 0xc0,
+	0x91, 0x27, 0xd0, 0x08, 0xf9, 0x0d, 0x9b, 0x46, 0x89, 0x53, 0x1e, 0x9d, 0x16, 0x49, 0xfb, 0x6b,
+	0xa8, 0x1f, 0x39, 0xa1, 0x73, 0xc1, 0xa7, 0x5c, 0x72, 0x16, 0xa9, 0x8c, 0x5c, 0x70, 0x19, 0x49,
+	0xc1, 0xfd, 0x89, 0x95, 0x6b, 0x1a, 0x7b, 0x05, 0x3a, 0x27, 0x48, 0x13, 0x6a, 0xd7, 0x8e, 0xef,
+	0xa9, 0x82, 0xe4, 0x2c, 0xb2, 0xf2, 0x68, 0xcf, 0x52, 0xbb, 0x0d, 0xa8, 0x1d, 0x05, 0xbe, 0x2a,
+	0x5a, 0xee, 0xcb, 0xc8, 0xfe, 0x3e, 0x0f, 0x66, 0xb6, 0x8c, 0x31, 0xfa, 0x47, 0x00, 0x52, 0x38,
+	0x7e, 0xe4, 0x06, 0x1e, 0x13, 0x3a, 0x25, 0x19, 0x86, 0xbc, 0x82, 0x86, 0xe4, 0xee, 0x15, 0x93,
+	0xa3, 0xd0, 0x11, 0xce, 0x75, 0x84, 0x91, 0xd7, 0x5a, 0xeb, 0x58, 0x4e, 0x43, 0xb4, 0xf4, 0xd1,
+	0x40, 0xeb, 0x32, 0x83, 0xc8, 0xbf, 0x00, 0x30, 0x03, 0x23, 0xac, 0x56, 0x03, 0x27, 0xad, 0xe2,
+	0xa4, 0x34, 0x73, 0xb4, 0x1a, 0x26, 0xc3, 0x6c, 0x2b, 0x15, 0x16, 0x5b, 0xe9, 0x25, 0xd4, 0xdd,
+	0x4c, 0x52, 0xac, 0x62, 0x66, 0xff, 0x6c, 0xb6, 0xe8, 0x82, 0x1b, 0x79, 0x0a, 0x65, 0xdd, 0x38,
+	0x56, 0xb3, 0x69, 0xec, 0xd5, 0x5a, 0xb5, 0x4c, 0x83, 0xd1, 0xc4, 0x66, 0xff, 0x6c, 0x40, 0x79,
+	0xc0, 0x26, 0xc7, 0x8e, 0x74, 0x54, 0x2a, 0xae, 0x1d, 0x9f, 0x8f, 0x59, 0x24, 0xbb, 0x9e, 0xee,
+	0xe8, 0x0c, 0x83, 0x4d, 0xcd, 0x3e, 0xea, 0x4f, 0xa7, 0x86, 0x58, 0x49, 0x4e, 0x74, 0x89, 0xc7,
+	0xab, 0x53, 0x1c, 0xab, 0xba, 0x09, 0x45, 0x30, 0xe6, 0x53, 0x96, 0x1c, 0x25, 0xc5, 0x89, 0x2c,
+	0x14, 0x53, 0x59, 0x50, 0xde, 0xde, 0x4c, 0x38, 0x92, 0x07, 0x3e, 0x96, 0x6d, 0x91, 0xa6, 0xf8,
+	0xde, 0xc9, 0xcb, 0x7f, 0xe5, 0xc9, 0xd5, 0xea, 0xe3, 0xd9, 0x74, 0xda, 0x4f, 0x62, 0x7d, 0xdc,
+	0x34, 0xd2, 0xd5, 0x3f, 0x70, 0x8f, 0x05, 0xda, 0x42, 0x17, 0xdc, 0xc8, 0x7f, 0xa0, 0x91, 0xc5,
+	0x2d, 0xcb, 0xfe, 0xa3, 0x79, 0x8b, 0x7e, 0x77, 0x27, 0x1e, 0x58, 0xff, 0xf8, 0xa2, 0x89, 0x07,
+	0xf6, 0x0f, 0x06, 0xd4, 0xb3, 0x76, 0x95, 0x75, 0xdf, 0xb9, 0x66, 0x28, 0x81, 0x55, 0x8a, 0x63,
+	0xa5, 0xdb, 0x9f, 0xb8, 0x27, 0x2f, 0x51, 0xed, 0x8a, 0x34, 0x06, 0x4a, 0x6a, 0x2e, 0x19, 0x9f,
+	0x5c, 0x4a, 0x8b, 0x20, 0xad, 0x91, 0xaa, 0xb6, 0x0b, 0xae, 0x9a, 0x80, 0x59, 0x1b, 0x68, 0x48,
+	0xa0, 0xfa, 0x42, 0xe3, 0x30, 0xb2, 0x36, 0x9b, 0xb9, 0xbd, 0x06, 0x55, 0x43, 0xf2, 0x1c, 0x4a,
+	0xe3, 0x40, 0x5c, 0x3b, 0xd2, 0xda, 0x42, 0xa1, 0xb6, 0xee, 0x05, 0xbc, 0xff, 0x06, 0xed, 0x54,
+	0xfb, 0xa9, 0x5d, 0xc7, 0x61, 0x74, 0xcc, 0x7c, 0x6b, 0x1b, 0x97, 0xd1, 0x88, 0x1c, 0x40, 0x59,
+	0x57, 0x82, 0xf5, 0x00, 0x97, 0xda, 0xb9, 0xbf, 0x94, 0xfe, 0xa5, 0x89, 0xa7, 0x0a, 0x68, 0x12,
+	0x84, 0x96, 0x85, 0x61, 0xaa, 0xa1, 0xfd, 0x10, 0x4a, 0xf1, 0x86, 0x4a, 0x93, 0xdf, 0xf7, 0x3b,
+	0x27, 0xc3, 0x81, 0xb9, 0x42, 0xca, 0x60, 0xbc, 0xef, 0xbf, 0x30, 0x73, 0xf6, 0x37, 0x50, 0x4e,
+	0x12, 0xb5, 0x01, 0x6b, 0x9d, 0xde, 0xd1, 0xd9, 0x71, 0x87, 0x8e, 0x8e, 0x3b, 0x6f, 0xda, 0xe7,
+	0xef, 0x94, 0xa0, 0xaf, 0x43, 0xe3, 0xb4, 0xf5, 0xea, 0xc5, 0xe8, 0xb0, 0x3d, 0xe8, 0xbc, 0xeb,
+	0xf6, 0x3a, 0x66, 0x8e, 0x34, 0xa0, 0x8a, 0xd4, 0xfb, 0x76, 0xb7, 0x67, 0xe6, 0x53, 0x78, 0xda,
+	0x3d, 0x39, 0x35, 0x0d, 0xb2, 0x03, 0x5b, 0x08, 0x8f, 0xce, 0x7a, 0x83, 0x21, 0x6d, 0x77, 0x7b,
+	0x9d, 0xe3, 0xd8, 0x54, 0xb0, 0xdb, 0xb0, 0x35, 0x4c, 0xa4, 0xc2, 0x1b, 0xb0, 0xc9, 0x35, 0xf3,
+	0x25, 0xb6, 0x92, 0x09, 0xc6, 0x4c, 0x4c, 0xb5, 0x9c, 0xa8, 0x21, 0xaa, 0x3e, 0x8a, 0x9d, 0xee,
+	0x1f, 0x8d, 0xec, 0xff, 0x43, 0x23, 0x5d, 0x02, 0xa7, 0xbe, 0x82, 0x4a, 0x14, 0xaf, 0x14, 0xa1,
+	0xe6, 0xd5, 0x5a, 0xbb, 0xb1, 0xd6, 0x2c, 0xdb, 0x88, 0xa6, 0xbe, 0x4b, 0xae, 0xdc, 0x1f, 0x73,
+	0xb0, 0x96, 0xce, 0xa2, 0x2c, 0x9a, 0x4d, 0x65, 0xd2, 0xc3, 0xb9, 0x79, 0x0f, 0x6f, 0x43, 0x91,
+	0x09, 0x11, 0x88, 0x58, 0xfb, 0x4f, 0x57, 0x68, 0x0c, 0xc9, 0x1e, 0x14, 0x3c, 0x47, 0x3a, 0x5a,
+	0xba, 0xc8, 0x62, 0x0c, 0x6a, 0xef, 0xd3, 0x15, 0x8a, 0x1e, 0xe4, 0x19, 0x14, 0x32, 0x57, 0xf2,
+	0x56, 0xdc, 0x6d, 0x77, 0x74, 0x96, 0xa2, 0xcb, 0x61, 0x05, 0x4a, 0x02, 0x03, 0xb1, 0x3b, 0xb0,
+	0x46, 0xd9, 0x84, 0x47, 0x92, 0xa5, 0xcf, 0x89, 0x6d, 0x28, 0x45, 0xcc, 0x15, 0x2c, 0xb9, 0x99,
+	0x34, 0x52, 0x1a, 0xa1, 0x1a, 0xdc, 0xe5, 0xf2, 0x56, 0x27, 0x2f, 0xc5, 0xf6, 0x77, 0x39, 0x68,
+	0xf4, 0x02, 0xc9, 0xc7, 0xb7, 0x3a, 0x2b, 0x4b, 0x52, 0xff, 0x4f, 0x28, 0x47, 0xb1, 0xc4, 0xe9,
+	0xc3, 0xd4, 0xe3, 0x57, 0x43, 0xcc, 0xd1, 0xc4, 0xa8, 0xf6, 0x97, 0x4e, 0x74, 0xd5, 0xf5, 0xf0,
+	0x24, 0x06, 0xd5, 0x68, 0x41, 0xd1, 0xd6, 0x17, 0x15, 0xed, 0x6d, 0xa1, 0x92, 0x37, 0x8d, 0xb7,
+	0x85, 0xca, 0x63, 0xd3, 0xb6, 0x7f, 0xca, 0x43, 0x3d, 0x7b, 0x23, 0xa8, 0xfb, 0x4b, 0x30, 0x97,
+	0x87, 0x9c, 0xf9, 0x52, 0xeb, 0xe9, 0x9c, 0x20, 0x0f, 0x01, 0xc6, 0x8e, 0xcb, 0x46, 0xf1, 0x7b,
+	0x2b, 0xfe, 0x6e, 0x55, 0xc5, 0x7c, 0x50, 0x04, 0xd9, 0x81, 0xca, 0x27, 0xee, 0x8f, 0x42, 0x11,
+	0x5c, 0x68, 0x7d, 0x2d, 0x7f, 0xe2, 0x7e, 0x5f, 0x04, 0x17, 0x64, 0x1f, 0x36, 0xd2, 0x65, 0x46,
+	0xc2, 0xf1, 0xbd, 0x11, 0xaa, 0x70, 0xac, 0xb6, 0xeb, 0xa9, 0x89, 0x3a, 0xbe, 0x77, 0xaa, 0x24,
+	0x99, 0x40, 0x21, 0x62, 0xcc, 0xd3, 0xba, 0x8b, 0x63, 0xf2, 0x0c, 0x4c, 0x76, 0x13, 0xf2, 0x58,
+	0x6a, 0x47, 0x17, 0xd3, 0xc0, 0xbd, 0x42, 0x01, 0xae, 0xd3, 0xb5, 0x39, 0x7f, 0xa8, 0x68, 0x72,
+	0x0a, 0xeb, 0x19, 0x57, 0x7d, 0x0d, 0xc6, 0x62, 0xfc, 0xb7, 0xcc, 0x35, 0xd8, 0x49, 0x7d, 0xf4,
+	0x85, 0x68, 0xb2, 0x3b, 0x8c, 0xdd, 0x05, 0x12, 0xfb, 0x0e, 0x98, 0xef, 0x31, 0xa1, 0xd3, 0xf4,
+	0x18, 0xea, 0x11, 0xe2, 0x91, 0x1f, 0xf8, 0x6e, 0xfc, 0x20, 0x6c, 0xd0, 0x5a, 0xcc, 0xf5, 0x14,
+	0xb5, 0xa4, 0xb8, 0x3f, 0xc3, 0xf6, 0xf2, 0x6d, 0xc9, 0x53, 0x58, 0x75, 0x05, 0x8b, 0x83, 0x15,
+	0xc1, 0xcc, 0xf7, 0x74, 0xb5, 0x37, 0x12, 0x96, 0x2a, 0x92, 0xbc, 0x86, 0x9d, 0x45, 0xb7, 0x38,
+	0x09, 0x71, 0x2a, 0xe3, 0x8d, 0xb6, 0x17, 0x66, 0x60, 0x32, 0x54, 0x3e, 0xed, 0x5f, 0xf3, 0x50,
+	0xee, 0x3b, 0xb7, 0x58, 0x6e, 0xf7, 0xde, 0x07, 0xb9, 0x2f, 0x7b, 0x1f, 0x60, 0xb1, 0xab, 0x03,
+	0xea, 0xbd, 0x34, 0x5a, 0x9e, 0x6c, 0xe3, 0x4f, 0x24, 0x9b, 0x74, 0x61, 0x53, 0x47, 0xa6, 0xb3,
+	0xab, 0x17, 0x2b, 0xa0, 0xa8, 0x3c, 0xc8, 0x2c, 0x96, 0xfd, 0x1a, 0x94, 0xc8, 0xfb, 0x5f, 0xe8,
+	0x25, 0xac, 0xb2, 0x9b, 0x90, 0xb9, 0x92, 0x79, 0x23, 0x7c, 0xb3, 0x58, 0xc5, 0xa5, 0x0f, 0x9a,
+	0x46, 0xe2, 0x85, 0x54, 0xeb, 0x06, 0xea, 0x59, 0x1d, 0x20, 0x87, 0xb0, 0x76, 0xc2, 0xe4, 0x02,
+	0x65, 0xdd, 0x53, 0x0b, 0xad, 0x06, 0xbb, 0xcb, 0x75, 0x84, 0x3c, 0x81, 0x82, 0xfa, 0xb3, 0x42,
+	0xe2, 0x97, 0x7f, 0xf2, 0xbf, 0x65, 0x77, 0x11, 0xb6, 0x7a, 0x00, 0xc3, 0xf9, 0x1b, 0xee, 0x7f,
+	0x40, 0x12, 0xad, 0xc9, 0xb0, 0x9b, 0x38, 0xe5, 0x8e, 0x08, 0xed, 0xc6, 0x42, 0xb7, 0x20, 0x29,
+	0xcf, 0x73, 0x17, 0x25, 0xfc, 0xbb, 0x74, 0xf0, 0xfb, 0x00, 0xc3, 0xb9, 0xea, 0x15, 0x42, 0x0d,
+	0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -108,8 +108,10 @@ ticketLoop:
 				glog.Errorf("Error getting queue length err=%v", err)
 				continue
 			}
+			// number of deferred tickets to skip, earliest first
+			deferred := 0
 			for i := 0; i < int(numTickets); i++ {
-				nextTicket, err := q.store.SelectEarliestWinningTicket(q.sender)
+				nextTicket, err := q.store.SelectWinningTicketAt(q.sender, deferred)
 				if err != nil {
 					glog.Errorf("Unable select earliest winning ticket err=%v", err)
 					continue ticketLoop
@@ -129,6 +131,12 @@ ticketLoop:
 					case res := <-resCh:
 						// after receiving the response we can close the channel so it can be GC'd
 						close(resCh)
+						if res.err == errRedemptionDeferred {
+							// leave the ticket in the queue till the next block
+							// and try the ones behind it
+							deferred++
+							continue
+						}
 						if res.err != nil {
 							glog.Errorf("Error redeeming err=%v", res.err)
 							continue
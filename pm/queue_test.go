@@ -183,6 +183,58 @@ func TestTicketQueueLoopConcurrent(t *testing.T) {
 	assert.Equal(0, qlen)
 }
 
+func TestTicketQueueLoop_RedemptionDeferred(t *testing.T) {
+	assert := assert.New(t)
+
+	sender := RandAddress()
+	ts := newStubTicketStore()
+	tm := &stubTimeManager{}
+
+	q := newTicketQueue(ts, sender, tm.SubscribeBlocks)
+	q.Start()
+	defer q.Stop()
+
+	for i := 0; i < 3; i++ {
+		q.Add(defaultSignedTicket(sender, uint32(i)))
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	// Deferred ticket is left in the queue and the tickets behind it are redeemed
+	attempts := make(chan uint32, 10)
+	respond := func(err error) {
+		red := <-q.Redeemable()
+		attempts <- red.SignedTicket.SenderNonce
+		red.resCh <- struct {
+			txHash ethcommon.Hash
+			err    error
+		}{RandHash(), err}
+	}
+	go func() {
+		respond(errRedemptionDeferred)
+		respond(nil)
+		respond(nil)
+	}()
+	tm.blockNumSink <- big.NewInt(1)
+	time.Sleep(20 * time.Millisecond)
+	assert.Len(attempts, 3)
+	assert.Equal(uint32(0), <-attempts)
+	assert.Equal(uint32(1), <-attempts)
+	assert.Equal(uint32(2), <-attempts)
+	qlen, err := q.Length()
+	assert.Nil(err)
+	assert.Equal(1, qlen)
+
+	// Deferred ticket is retried on the next block
+	go respond(nil)
+	tm.blockNumSink <- big.NewInt(2)
+	time.Sleep(20 * time.Millisecond)
+	assert.Len(attempts, 1)
+	assert.Equal(uint32(0), <-attempts)
+	qlen, err = q.Length()
+	assert.Nil(err)
+	assert.Equal(0, qlen)
+}
+
 func TestTicketQueueConsumeBlockNums(t *testing.T) {
 	assert := assert.New(t)
 
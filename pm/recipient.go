@@ -10,6 +10,7 @@ import (
 	ethcommon "github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/golang/glog"
+	"github.com/livepeer/go-livepeer/monitor"
 	"github.com/pkg/errors"
 )
 
@@ -18,6 +19,8 @@ var ErrTicketParamsExpired = errors.New("TicketParams expired")
 
 var errInsufficientSenderReserve = errors.New("insufficient sender reserve")
 
+var errInvalidTicketSenderNonce = errors.New("invalid ticket senderNonce")
+
 // maxWinProb = 2^256 - 1
 var maxWinProb = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
 
@@ -310,7 +313,11 @@ func (r *recipient) updateSenderNonce(rand *big.Int, ticket *Ticket) error {
 	randStr := rand.String()
 	sn, ok := r.senderNonces[randStr]
 	if ok && ticket.SenderNonce <= sn.nonce {
-		return errors.Errorf("invalid ticket senderNonce sender=%v nonce=%v highest=%v", ticket.Sender.Hex(), ticket.SenderNonce, sn.nonce)
+		// the ticket reuses recipientRand already used with the same or higher senderNonce
+		if monitor.Enabled {
+			monitor.RecipientRandReused(ticket.Sender.Hex())
+		}
+		return errors.Wrapf(errInvalidTicketSenderNonce, "sender=%v nonce=%v highest=%v", ticket.Sender.Hex(), ticket.SenderNonce, sn.nonce)
 	}
 
 	r.senderNonces[randStr] = &struct {
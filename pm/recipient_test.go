@@ -12,6 +12,7 @@ import (
 
 	ethcommon "github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/livepeer/go-livepeer/monitor"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -104,6 +105,7 @@ func TestReceiveTicket_InvalidRecipientRand(t *testing.T) {
 	assert.Equal(sessionID, "")
 	assert.Equal(won, false)
 	assert.Equal(err.Error(), errInvalidTicketRecipientRand.Error())
+	assert.Equal(monitor.PaymentValidationRecipientRand, ValidationStage(err))
 	_, ok := err.(*FatalReceiveErr)
 	assert.True(ok)
 
@@ -115,6 +117,7 @@ func TestReceiveTicket_InvalidRecipientRand(t *testing.T) {
 	assert.Equal(sessionID, "")
 	assert.Equal(won, false)
 	assert.Equal(err.Error(), errInvalidTicketRecipientRand.Error())
+	assert.Equal(monitor.PaymentValidationRecipientRand, ValidationStage(err))
 	_, ok = err.(*FatalReceiveErr)
 	assert.True(ok)
 
@@ -136,6 +139,7 @@ func TestReceiveTicket_InvalidRecipientRand(t *testing.T) {
 	assert.Equal(sessionID, "")
 	assert.Equal(won, false)
 	assert.Equal(err.Error(), errInvalidTicketRecipientRand.Error())
+	assert.Equal(monitor.PaymentValidationRecipientRand, ValidationStage(err))
 	_, ok = err.(*FatalReceiveErr)
 	assert.True(ok)
 
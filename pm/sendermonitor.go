@@ -19,6 +19,14 @@ import (
 // pending amount to be ignored when calculating the sender's max float
 const minDepositPendingRatio = 3.0
 
+// errRedemptionDeferred is returned when the redemption tx cost is too high relative to the ticket's
+// face value. The ticket stays in the queue and the redemption is retried on the next block
+var errRedemptionDeferred = errors.New("ticket redemption deferred due to high gas price")
+
+// ticketValidityPeriod is the number of rounds a ticket can be redeemed for after its
+// creation round, as set in the TicketBroker
+const ticketValidityPeriod = 2
+
 // unixNow returns the current unix time
 // This is a wrapper function that can be stubbed in tests
 var unixNow = func() int64 {
@@ -72,6 +80,10 @@ type LocalSenderMonitorConfig struct {
 	RedeemGas       int
 	SuggestGasPrice func(context.Context) (*big.Int, error)
 	RPCTimeout      time.Duration
+
+	// Redemption is deferred while the redeem tx cost exceeds this fraction of the ticket's
+	// face value. Disabled if 0
+	MaxRedeemGasCostRatio float64
 }
 
 type LocalSenderMonitor struct {
@@ -91,6 +103,9 @@ type LocalSenderMonitor struct {
 
 	ticketStore TicketStore
 
+	// hashes of the tickets with the redemption deferred, protected by mu
+	deferred map[ethcommon.Hash]bool
+
 	quit chan struct{}
 }
 
@@ -104,6 +119,7 @@ func NewSenderMonitor(cfg *LocalSenderMonitorConfig, broker Broker, smgr SenderM
 		senders:     make(map[ethcommon.Address]*remoteSender),
 		redeemable:  make(chan *redemption),
 		ticketStore: store,
+		deferred:    make(map[ethcommon.Hash]bool),
 		quit:        make(chan struct{}),
 	}
 }
@@ -369,6 +385,13 @@ func (sm *LocalSenderMonitor) redeemWinningTicket(ticket *SignedTicket) (*types.
 		return nil, errors.New("insufficient sender funds for redeem tx cost")
 	}
 
+	// Defer the redemption if the redeem tx cost is too high relative to the ticket's face value
+	// The redemption will be retried on the next block when the gas price might have dropped,
+	// unless the ticket is about to expire
+	if sm.deferRedemption(ticket, txCost) {
+		return nil, errRedemptionDeferred
+	}
+
 	// Subtract the ticket face value from the sender's current max float
 	// This amount will be considered pending until the ticket redemption
 	// transaction confirms on-chain
@@ -416,6 +439,50 @@ func (sm *LocalSenderMonitor) redeemWinningTicket(ticket *SignedTicket) (*types.
 	return tx, nil
 }
 
+// deferRedemption checks if the redemption of the ticket should be deferred because of the
+// high redeem tx cost. Each ticket is logged and recorded as deferred only once.
+func (sm *LocalSenderMonitor) deferRedemption(ticket *SignedTicket, txCost *big.Int) bool {
+	hash := ticket.Ticket.Hash()
+	tooHigh := sm.redeemCostTooHigh(txCost, ticket.Ticket.FaceValue)
+	expiring := tooHigh && sm.closeToExpiry(ticket.Ticket)
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if !tooHigh || expiring {
+		if expiring {
+			glog.Warningf("Redeeming ticket about to expire despite high tx cost sender=%v faceValue=%v txCost=%v", ticket.Ticket.Sender.Hex(), ticket.Ticket.FaceValue, txCost)
+		}
+		delete(sm.deferred, hash)
+		return false
+	}
+	if !sm.deferred[hash] {
+		sm.deferred[hash] = true
+		glog.Infof("Deferring ticket redemption sender=%v faceValue=%v txCost=%v", ticket.Ticket.Sender.Hex(), ticket.Ticket.FaceValue, txCost)
+		if monitor.Enabled {
+			monitor.TicketRedemptionDeferred(ticket.Ticket.Sender.String())
+		}
+	}
+	return true
+}
+
+// closeToExpiry returns true if the last initialized round is the last round the ticket can be redeemed in
+func (sm *LocalSenderMonitor) closeToExpiry(ticket *Ticket) bool {
+	round := sm.tm.LastInitializedRound()
+	if round == nil {
+		return false
+	}
+	return round.Int64() >= ticket.CreationRound+ticketValidityPeriod-1
+}
+
+// redeemCostTooHigh returns true if the redeem tx cost exceeds the configured fraction of the ticket's face value
+func (sm *LocalSenderMonitor) redeemCostTooHigh(txCost, faceValue *big.Int) bool {
+	if sm.cfg.MaxRedeemGasCostRatio <= 0 {
+		return false
+	}
+	maxCost := new(big.Rat).Mul(new(big.Rat).SetInt(faceValue), new(big.Rat).SetFloat64(sm.cfg.MaxRedeemGasCostRatio))
+	return new(big.Rat).SetInt(txCost).Cmp(maxCost) > 0
+}
+
 // SubscribeMaxFloatChange notifies subcribers when the max float for a sender has changed
 // and that it should call LocalSenderMonitor.MaxFloat() to get the latest value
 func (sm *LocalSenderMonitor) SubscribeMaxFloatChange(sender ethcommon.Address, sink chan<- struct{}) event.Subscription {
@@ -681,6 +681,74 @@ func TestRedeemWinningTicket_CheckAvailableFunds(t *testing.T) {
 	assert.NotNil(tx)
 }
 
+func TestRedeemWinningTicket_HighGasDefersRedemption(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg, b, smgr, tm := localSenderMonitorFixture()
+	addr := RandAddress()
+	smgr.info[addr] = &SenderInfo{
+		Deposit:       big.NewInt(500),
+		WithdrawRound: big.NewInt(0),
+		Reserve: &ReserveInfo{
+			FundsRemaining:        big.NewInt(1000),
+			ClaimedInCurrentRound: big.NewInt(0),
+		},
+	}
+	smgr.claimedReserve[addr] = big.NewInt(0)
+	ts := newStubTicketStore()
+
+	// faceValue = 50, so redemption is deferred if txCost > 25
+	cfg.MaxRedeemGasCostRatio = 0.5
+	cfg.RedeemGas = 1
+	gasPrice := big.NewInt(26)
+	cfg.SuggestGasPrice = func(ctx context.Context) (*big.Int, error) { return gasPrice, nil }
+	sm := NewSenderMonitor(cfg, b, smgr, tm, ts)
+	signedT := defaultSignedTicket(addr, uint32(0))
+
+	tx, err := sm.redeemWinningTicket(signedT)
+	assert.Equal(errRedemptionDeferred, err)
+	assert.Nil(tx)
+	used, err := b.IsUsedTicket(signedT.Ticket)
+	assert.Nil(err)
+	assert.False(used)
+	// pending amount is untouched
+	assert.Equal(big.NewInt(0), sm.senders[addr].pendingAmount)
+
+	// ticket deferred again is tracked once
+	_, err = sm.redeemWinningTicket(signedT)
+	assert.Equal(errRedemptionDeferred, err)
+	assert.Len(sm.deferred, 1)
+
+	// redeemed once gas drops
+	gasPrice = big.NewInt(25)
+	tx, err = sm.redeemWinningTicket(signedT)
+	assert.Nil(err)
+	assert.NotNil(tx)
+	used, err = b.IsUsedTicket(signedT.Ticket)
+	assert.Nil(err)
+	assert.True(used)
+	assert.Empty(sm.deferred)
+
+	// redeemed despite high gas in the last round the ticket is valid for
+	gasPrice = big.NewInt(26)
+	expiring := defaultSignedTicket(addr, uint32(2))
+	tm.round = big.NewInt(expiring.CreationRound)
+	_, err = sm.redeemWinningTicket(expiring)
+	assert.Equal(errRedemptionDeferred, err)
+	tm.round = big.NewInt(expiring.CreationRound + ticketValidityPeriod - 1)
+	tx, err = sm.redeemWinningTicket(expiring)
+	assert.Nil(err)
+	assert.NotNil(tx)
+	assert.Empty(sm.deferred)
+
+	// no deferral if disabled
+	cfg.MaxRedeemGasCostRatio = 0
+	gasPrice = big.NewInt(100)
+	sm = NewSenderMonitor(cfg, b, smgr, tm, ts)
+	_, err = sm.redeemWinningTicket(defaultSignedTicket(addr, uint32(1)))
+	assert.Nil(err)
+}
+
 func TestRedeemWinningTicket_SingleTicket_RedeemError(t *testing.T) {
 	cfg, b, smgr, tm := localSenderMonitorFixture()
 	addr := RandAddress()
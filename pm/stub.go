@@ -55,6 +55,10 @@ func (ts *stubTicketStore) StoreWinningTicket(ticket *SignedTicket) error {
 }
 
 func (ts *stubTicketStore) SelectEarliestWinningTicket(sender ethcommon.Address) (*SignedTicket, error) {
+	return ts.SelectWinningTicketAt(sender, 0)
+}
+
+func (ts *stubTicketStore) SelectWinningTicketAt(sender ethcommon.Address, offset int) (*SignedTicket, error) {
 	ts.lock.Lock()
 	defer ts.lock.Unlock()
 	if ts.loadShouldFail {
@@ -62,7 +66,10 @@ func (ts *stubTicketStore) SelectEarliestWinningTicket(sender ethcommon.Address)
 	}
 	for _, t := range ts.tickets[sender] {
 		if !ts.submitted[fmt.Sprintf("%x", t.Sig)] {
-			return t, nil
+			if offset == 0 {
+				return t, nil
+			}
+			offset--
 		}
 	}
 	return nil, nil
@@ -9,6 +9,10 @@ type TicketStore interface {
 	// which is not yet redeemed
 	SelectEarliestWinningTicket(sender ethcommon.Address) (*SignedTicket, error)
 
+	// SelectWinningTicketAt selects the stored winning ticket for a 'sender' which is not
+	// yet redeemed, skipping the 'offset' earliest ones
+	SelectWinningTicketAt(sender ethcommon.Address, offset int) (*SignedTicket, error)
+
 	// RemoveWinningTicket removes a ticket
 	RemoveWinningTicket(ticket *SignedTicket) error
 
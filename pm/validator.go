@@ -5,6 +5,7 @@ import (
 
 	ethcommon "github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/livepeer/go-livepeer/monitor"
 	"github.com/pkg/errors"
 )
 
@@ -63,6 +64,34 @@ func (v *validator) ValidateTicket(recipient ethcommon.Address, ticket *Ticket,
 	return nil
 }
 
+// ValidationStage returns the stage of the ticket validation which failed
+// with the error returned by Recipient.ReceiveTicket, one of the
+// monitor.PaymentValidation* constants. Empty if the error isn't from one of
+// these stages.
+//
+// Face value, win prob and price per pixel of the ticket have no stages of
+// their own. They are part of the recipientRand the recipient derives for the
+// ticket, so the ticket not matching the advertised ones fails the
+// recipientRand check and is reported under the recipient_rand stage. Expected
+// price missing from the payment is reported by the orchestrator under the
+// price stage.
+func ValidationStage(err error) string {
+	if fatal, ok := err.(*FatalReceiveErr); ok {
+		err = fatal.error
+	}
+	switch errors.Cause(err) {
+	case errInvalidTicketRecipient:
+		return monitor.PaymentValidationRecipient
+	case errInvalidTicketSender:
+		return monitor.PaymentValidationSender
+	case errInvalidTicketSignature:
+		return monitor.PaymentValidationSignature
+	case errInvalidTicketRecipientRand, errInvalidTicketSenderNonce:
+		return monitor.PaymentValidationRecipientRand
+	}
+	return ""
+}
+
 // IsWinningTicket checks if a ticket won
 // Note: This method does not check if a ticket is valid which is done using IsValidTicket
 // A ticket wins if:
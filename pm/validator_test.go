@@ -6,6 +6,9 @@ import (
 
 	ethcommon "github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/livepeer/go-livepeer/monitor"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestValidateTicket(t *testing.T) {
@@ -167,3 +170,18 @@ func TestIsWinningTicket(t *testing.T) {
 		t.Error("expected winning ticket")
 	}
 }
+
+func TestValidationStage(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(monitor.PaymentValidationSignature, ValidationStage(errInvalidTicketSignature))
+	assert.Equal(monitor.PaymentValidationRecipientRand, ValidationStage(&FatalReceiveErr{errInvalidTicketRecipientRand}))
+	assert.Equal(monitor.PaymentValidationRecipientRand, ValidationStage(errors.Wrapf(errInvalidTicketSenderNonce, "nonce=%v", 1)))
+	assert.Equal(monitor.PaymentValidationRecipient, ValidationStage(&FatalReceiveErr{errInvalidTicketRecipient}))
+	assert.Equal(monitor.PaymentValidationSender, ValidationStage(&FatalReceiveErr{errInvalidTicketSender}))
+
+	// not from the validation stages
+	assert.Empty(ValidationStage(&FatalReceiveErr{errInvalidCreationRound}))
+	assert.Empty(ValidationStage(ErrTicketParamsExpired))
+	assert.Empty(ValidationStage(errors.New("some error")))
+}
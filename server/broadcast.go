@@ -6,9 +6,11 @@ import (
 	"fmt"
 	"math"
 	"math/big"
+	"math/rand"
 	"net/url"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/golang/glog"
@@ -22,6 +24,8 @@ import (
 	"github.com/livepeer/go-livepeer/verification"
 
 	"github.com/livepeer/lpms/stream"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
 )
 
 var refreshTimeout = 2500 * time.Millisecond
@@ -32,12 +36,55 @@ var Policy *verification.Policy
 var BroadcastCfg = &BroadcastConfig{}
 var MaxAttempts = 3
 
+// MaxOrchestratorsPerSegment is the max number of distinct orchestrators tried
+// for a segment before it's failed. Zero means no limit besides MaxAttempts.
+var MaxOrchestratorsPerSegment = 0
+
+// OrchestratorWarmup enables transcoding of the test segment by newly selected
+// orchestrators before they are used for the stream, so that the first real
+// segment doesn't bear the cost of cold connections and transcoding sessions.
+// Orchestrators failing the warmup aren't used.
+var OrchestratorWarmup = false
+
+// VerificationSampleRate is the share of the transcoded segments which are
+// transcoded again by another orchestrator, to check the outputs of the first
+// one match. Each checked segment is paid for twice. Not checked if 0.
+var VerificationSampleRate = 0.0
+
+// VerificationTolerance is the max difference of the encoded sizes of the
+// renditions of the checked segment, relative to the larger one, for the
+// check to pass
+var VerificationTolerance = 0.1
+
 var getOrchestratorInfoRPC = GetOrchestratorInfo
 var downloadSeg = drivers.GetSegmentData
+var segmentFailover = func(mid core.ManifestID) {
+	if monitor.Enabled {
+		monitor.SegmentFailover(string(mid))
+	}
+}
+var verificationSampled = func() bool {
+	return rand.Float64() < VerificationSampleRate
+}
+var outputsMatch = verification.OutputsMatch
+var verificationChecked = func(orch string, passed bool) {
+	if monitor.Enabled {
+		monitor.VerificationCheck(orch, passed)
+	}
+}
+var fallbackChainUsed = func(pos int) {
+	if monitor.Enabled {
+		monitor.FallbackChainPosition(pos)
+	}
+}
 
 type BroadcastConfig struct {
-	maxPrice *big.Rat
-	mu       sync.RWMutex
+	maxPrice      *big.Rat
+	priceWeighted bool
+	latencyAware  bool
+	byStake       bool
+	fallbackChain bool
+	mu            sync.RWMutex
 }
 
 func (cfg *BroadcastConfig) MaxPrice() *big.Rat {
@@ -52,6 +99,66 @@ func (cfg *BroadcastConfig) SetMaxPrice(price *big.Rat) {
 	cfg.maxPrice = price
 }
 
+// PriceWeightedSelection returns true if the orchestrators are picked from the
+// discovery pool at random weighted inversely by their price per pixel, rather
+// than the first ones to respond
+func (cfg *BroadcastConfig) PriceWeightedSelection() bool {
+	cfg.mu.RLock()
+	defer cfg.mu.RUnlock()
+	return cfg.priceWeighted
+}
+
+func (cfg *BroadcastConfig) SetPriceWeightedSelection(priceWeighted bool) {
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+	cfg.priceWeighted = priceWeighted
+}
+
+// LatencyAwareSelection returns true if the orchestrators with the lowest
+// recorded round trip time are picked from the discovery pool, rather than the
+// first ones to respond
+func (cfg *BroadcastConfig) LatencyAwareSelection() bool {
+	cfg.mu.RLock()
+	defer cfg.mu.RUnlock()
+	return cfg.latencyAware
+}
+
+func (cfg *BroadcastConfig) SetLatencyAwareSelection(latencyAware bool) {
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+	cfg.latencyAware = latencyAware
+}
+
+// SelectByStake returns true if the orchestrators are picked from the
+// discovery pool at random weighted by their stake, rather than the first ones
+// to respond
+func (cfg *BroadcastConfig) SelectByStake() bool {
+	cfg.mu.RLock()
+	defer cfg.mu.RUnlock()
+	return cfg.byStake
+}
+
+func (cfg *BroadcastConfig) SetSelectByStake(byStake bool) {
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+	cfg.byStake = byStake
+}
+
+// FallbackChainSelection returns true if the sessions of the new streams are
+// selected along the fallback chain of the orchestrators, rather than by their
+// latency score
+func (cfg *BroadcastConfig) FallbackChainSelection() bool {
+	cfg.mu.RLock()
+	defer cfg.mu.RUnlock()
+	return cfg.fallbackChain
+}
+
+func (cfg *BroadcastConfig) SetFallbackChainSelection(fallbackChain bool) {
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+	cfg.fallbackChain = fallbackChain
+}
+
 type BroadcastSessionsManager struct {
 	// Accessing or changing any of the below requires ownership of this mutex
 	sessLock *sync.Mutex
@@ -124,6 +231,48 @@ func (bsm *BroadcastSessionsManager) completeSession(sess *BroadcastSession) {
 	}
 }
 
+// selectSpareSession selects a session of another orchestrator than orch,
+// as long as another session is left for the live segments of the stream.
+// Returns nil otherwise.
+func (bsm *BroadcastSessionsManager) selectSpareSession(orch string) *BroadcastSession {
+	bsm.sessLock.Lock()
+	defer bsm.sessLock.Unlock()
+
+	var spare *BroadcastSession
+	var skipped []*BroadcastSession
+	for spare == nil && bsm.sel.Size()+len(skipped) > 1 {
+		sess := bsm.sel.Select()
+		if sess == nil {
+			break
+		}
+		if _, ok := bsm.sessMap[sess.OrchestratorInfo.Transcoder]; !ok {
+			continue
+		}
+		if orchestratorID(sess.OrchestratorInfo) == orch {
+			skipped = append(skipped, sess)
+			continue
+		}
+		spare = sess
+	}
+	for _, sess := range skipped {
+		bsm.sel.Complete(sess)
+	}
+	return spare
+}
+
+// chainPosition returns the position of the session's orchestrator in the
+// stream's fallback chain, if the sessions are selected along one
+func (bsm *BroadcastSessionsManager) chainPosition(sess *BroadcastSession) (int, bool) {
+	bsm.sessLock.Lock()
+	defer bsm.sessLock.Unlock()
+	chain, ok := bsm.sel.(*FallbackChainSelector)
+	if !ok {
+		return 0, false
+	}
+	pos := chain.Position(sess)
+	return pos, pos >= 0
+}
+
 func (bsm *BroadcastSessionsManager) refreshSessions() {
 
 	started := time.Now()
@@ -147,6 +296,10 @@ func (bsm *BroadcastSessionsManager) refreshSessions() {
 		return
 	}
 
+	if OrchestratorWarmup {
+		newBroadcastSessions = bsm.warmupSessions(newBroadcastSessions)
+	}
+
 	// if newBroadcastSessions is empty, exit without refreshing list
 	if len(newBroadcastSessions) <= 0 {
 		bsm.sessLock.Lock()
@@ -175,12 +328,84 @@ func (bsm *BroadcastSessionsManager) refreshSessions() {
 	bsm.sel.Add(uniqueSessions)
 }
 
+// warmupSessions has the orchestrators of the sessions that aren't used yet
+// transcode the test segment in parallel, returns the sessions that succeeded
+// and the ones already used
+func (bsm *BroadcastSessionsManager) warmupSessions(sessions []*BroadcastSession) []*BroadcastSession {
+	bsm.sessLock.Lock()
+	warm := make([]*BroadcastSession, len(sessions))
+	var cold []int
+	for i, sess := range sessions {
+		if _, ok := bsm.sessMap[sess.OrchestratorInfo.Transcoder]; ok {
+			warm[i] = sess
+		} else {
+			cold = append(cold, i)
+		}
+	}
+	bsm.sessLock.Unlock()
+
+	var wg sync.WaitGroup
+	for _, i := range cold {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sess, err := warmupSession(sessions[i])
+			if err != nil {
+				glog.Errorf("Orchestrator warmup failed manifestID=%s orch=%s err=%v", bsm.mid, sessions[i].OrchestratorInfo.Transcoder, err)
+				return
+			}
+			warm[i] = sess
+		}(i)
+	}
+	wg.Wait()
+
+	var res []*BroadcastSession
+	for _, sess := range warm {
+		if sess != nil {
+			res = append(res, sess)
+		}
+	}
+	return res
+}
+
+// warmupSeqNo is the sequence number of the test segment the orchestrators
+// are warmed up with. The stream's segments never get to it, so the renditions
+// of the test segment can't collide with theirs.
+const warmupSeqNo = math.MaxInt64
+
+// warmupSession submits the test segment to the session's orchestrator and
+// returns the session updated with the orchestrator info from the result.
+// The test segment is paid for from the stream's balance like its segments.
+func warmupSession(sess *BroadcastSession) (*BroadcastSession, error) {
+	data, err := core.TestSegmentData()
+	if err != nil {
+		return nil, err
+	}
+	seg := &stream.HLSSegment{SeqNo: warmupSeqNo, Data: data, Duration: core.TestSegmentDuration}
+	start := time.Now()
+	res, err := SubmitSegment(sess, seg, 0)
+	if err == nil && res == nil {
+		err = errors.New("empty response")
+	}
+	dur := time.Since(start)
+	if monitor.Enabled {
+		monitor.OrchestratorWarmup(dur, err == nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	glog.V(common.DEBUG).Infof("Orchestrator warmed up manifestID=%s orch=%s dur=%s", sess.Params.ManifestID, sess.OrchestratorInfo.Transcoder, dur)
+	// the tiny segment says nothing about the latency of real ones
+	return updateSession(sess, &ReceivedTranscodeResult{Info: res.Info, LatencyScore: sess.LatencyScore}), nil
+}
+
 func (bsm *BroadcastSessionsManager) cleanup() {
 	bsm.sessLock.Lock()
 	defer bsm.sessLock.Unlock()
 	bsm.finished = true
 	bsm.sel.Clear()
 	bsm.sessMap = make(map[string]*BroadcastSession) // prevent segfaults
+	bsm.sus.clear()
 }
 
 func (bsm *BroadcastSessionsManager) suspendOrch(sess *BroadcastSession) {
@@ -274,11 +499,15 @@ func selectOrchestrator(n *core.LivepeerNode, params *core.StreamParameters, cou
 
 func processSegment(cxn *rtmpConnection, seg *stream.HLSSegment) ([]string, error) {
 
-	rtmpStrm := cxn.stream
+	rtmpStrm, _ := cxn.publisher()
 	nonce := cxn.nonce
 	cpl := cxn.pl
 	mid := cxn.mid
 	vProfile := cxn.profile
+	// release the segments waiting for this one if it wasn't uploaded
+	defer cxn.uploads.done(seg.SeqNo, false)
+	defer cxn.segMem.processed(seg.SeqNo)
+	defer cxn.warmup.segmentBuffered()
 
 	if seg.Duration > maxDurationSec || seg.Duration < 0 {
 		glog.Errorf("Invalid duration nonce=%d manifestID=%s seqNo=%d dur=%v", nonce, mid, seg.SeqNo, seg.Duration)
@@ -287,7 +516,11 @@ func processSegment(cxn *rtmpConnection, seg *stream.HLSSegment) ([]string, erro
 
 	glog.V(common.DEBUG).Infof("Processing segment nonce=%d manifestID=%s seqNo=%d dur=%v", nonce, mid, seg.SeqNo, seg.Duration)
 	if monitor.Enabled {
-		monitor.SegmentEmerged(nonce, seg.SeqNo, len(BroadcastJobVideoProfiles))
+		monitor.SegmentEmerged(nonce, seg.SeqNo, len(BroadcastJobVideoProfiles), seg.Duration)
+		monitor.SetQueuedSegments(nonce, int(atomic.AddInt64(&cxn.queuedSegments, 1)))
+		defer func() {
+			monitor.SetQueuedSegments(nonce, int(atomic.AddInt64(&cxn.queuedSegments, -1)))
+		}()
 	}
 
 	seg.Name = "" // hijack seg.Name to convey the uploaded URI
@@ -297,26 +530,41 @@ func processSegment(cxn *rtmpConnection, seg *stream.HLSSegment) ([]string, erro
 		return nil, err
 	}
 	name := fmt.Sprintf("%s/%d%s", vProfile.Name, seg.SeqNo, ext)
-	uri, err := cpl.GetOSSession().SaveData(name, seg.Data)
-	if err != nil {
-		glog.Errorf("Error saving segment nonce=%d seqNo=%d: %v", nonce, seg.SeqNo, err)
+	cxn.uploads.wait(seg.SeqNo)
+	uri, err := cpl.GetOSSession().SaveData(name, seg.Data, nil)
+	cxn.uploads.done(seg.SeqNo, err == nil)
+	insertSource := func(uri string) {
+		err := cpl.InsertHLSSegment(vProfile, seg.SeqNo, uri, seg.Duration, len(seg.Data))
 		if monitor.Enabled {
-			monitor.SegmentUploadFailed(nonce, seg.SeqNo, monitor.SegmentUploadErrorUnknown, err.Error(), true)
+			monitor.SourceSegmentAppeared(nonce, seg.SeqNo, string(mid), vProfile.Name)
+		}
+		if err != nil {
+			glog.Errorf("Error inserting segment nonce=%d seqNo=%d: %v", nonce, seg.SeqNo, err)
+			if monitor.Enabled {
+				monitor.SegmentUploadFailed(nonce, seg.SeqNo, monitor.SegmentUploadErrorUnknown, err.Error(), true)
+			}
 		}
-		return nil, err
-	}
-	if cpl.GetOSSession().IsExternal() {
-		seg.Name = uri // hijack seg.Name to convey the uploaded URI
-	}
-	err = cpl.InsertHLSSegment(vProfile, seg.SeqNo, uri, seg.Duration)
-	if monitor.Enabled {
-		monitor.SourceSegmentAppeared(nonce, seg.SeqNo, string(mid), vProfile.Name)
 	}
 	if err != nil {
-		glog.Errorf("Error inserting segment nonce=%d seqNo=%d: %v", nonce, seg.SeqNo, err)
+		glog.Errorf("Error saving segment nonce=%d seqNo=%d: %v", nonce, seg.SeqNo, err)
+		// transcode anyway and insert the source into the playlist once it is saved
+		retried := drivers.UploadRetries != nil && drivers.UploadRetries.Add(cpl.GetOSSession(), name, seg.Data, nil,
+			func(uri string, err error) {
+				if err == nil {
+					insertSource(uri)
+				}
+			})
 		if monitor.Enabled {
-			monitor.SegmentUploadFailed(nonce, seg.SeqNo, monitor.SegmentUploadErrorUnknown, err.Error(), true)
+			monitor.SegmentUploadFailed(nonce, seg.SeqNo, monitor.SegmentUploadErrorUnknown, err.Error(), !retried)
+		}
+		if !retried {
+			return nil, err
 		}
+	} else {
+		if cpl.GetOSSession().IsExternal() {
+			seg.Name = uri // hijack seg.Name to convey the uploaded URI
+		}
+		insertSource(uri)
 	}
 
 	var sv *verification.SegmentVerifier
@@ -324,16 +572,33 @@ func processSegment(cxn *rtmpConnection, seg *stream.HLSSegment) ([]string, erro
 		sv = verification.NewSegmentVerifier(Policy)
 	}
 
+	orchs := make(map[string]bool)
+	failedOver := false
 	for i := 0; i < MaxAttempts; i++ {
 		// if fails, retry; rudimentary
 		var urls []string
-		if urls, err = transcodeSegment(cxn, seg, name, sv); err == nil {
+		urls, err = transcodeSegment(cxn, seg, name, sv, orchs)
+		// attempts with another orchestrator are only made after a failure
+		if !failedOver && len(orchs) > 1 {
+			failedOver = true
+			segmentFailover(mid)
+		}
+		if err == nil {
 			return urls, nil
 		}
+		if err == errMaxOrchs {
+			if monitor.Enabled {
+				monitor.SegmentFailedMaxOrchestrators(nonce, seg.SeqNo)
+			}
+			return nil, err
+		}
 
 		if shouldStopStream(err) {
 			glog.Warningf("Stopping current stream due to: %v", err)
 			rtmpStrm.Close()
+			if monitor.Enabled {
+				monitor.TranscodeTriesExhausted(nonce, seg.SeqNo)
+			}
 			return nil, err
 		}
 
@@ -341,12 +606,18 @@ func processSegment(cxn *rtmpConnection, seg *stream.HLSSegment) ([]string, erro
 	}
 	if err != nil {
 		err = fmt.Errorf("Hit max transcode attempts: %w", err)
+		if monitor.Enabled {
+			monitor.TranscodeTriesExhausted(nonce, seg.SeqNo)
+		}
 	}
 	return nil, err
 }
 
+// transcodeSegment makes single attempt to transcode the segment. Orchestrator
+// the attempt is made with is added to orchs, if not nil. Returns errMaxOrchs
+// without making the attempt if it would exceed MaxOrchestratorsPerSegment.
 func transcodeSegment(cxn *rtmpConnection, seg *stream.HLSSegment, name string,
-	verifier *verification.SegmentVerifier) ([]string, error) {
+	verifier *verification.SegmentVerifier, orchs map[string]bool) ([]string, error) {
 
 	nonce := cxn.nonce
 	cpl := cxn.pl
@@ -364,15 +635,26 @@ func transcodeSegment(cxn *rtmpConnection, seg *stream.HLSSegment, name string,
 		return nil, nil
 	}
 
+	if orchs != nil {
+		orch := orchestratorID(sess.OrchestratorInfo)
+		if !orchs[orch] && MaxOrchestratorsPerSegment > 0 && len(orchs) >= MaxOrchestratorsPerSegment {
+			// session is kept for the next segments
+			cxn.sessManager.completeSession(sess)
+			return nil, errMaxOrchs
+		}
+		orchs[orch] = true
+	}
+
 	glog.Infof("Trying to transcode segment nonce=%d seqNo=%d", nonce, seg.SeqNo)
 	if monitor.Enabled {
 		monitor.TranscodeTry(nonce, seg.SeqNo)
 	}
+	sampled := VerificationSampleRate > 0 && verificationSampled()
 
 	// storage the orchestrator prefers
 	if ios := sess.OrchestratorOS; ios != nil {
 		// XXX handle case when orch expects direct upload
-		uri, err := ios.SaveData(name, seg.Data)
+		uri, err := ios.SaveData(name, seg.Data, nil)
 		if err != nil {
 			glog.Errorf("Error saving segment to OS nonce=%d seqNo=%d: %v", nonce, seg.SeqNo, err)
 			if monitor.Enabled {
@@ -416,6 +698,13 @@ func transcodeSegment(cxn *rtmpConnection, seg *stream.HLSSegment, name string,
 	}
 
 	cxn.sessManager.completeSession(updateSession(sess, res))
+	if monitor.Enabled {
+		monitor.OrchestratorUsed(nonce, orchestratorID(sess.OrchestratorInfo))
+	}
+	if pos, ok := cxn.sessManager.chainPosition(sess); ok {
+		glog.V(common.DEBUG).Infof("Segment transcoded along fallback chain nonce=%d seqNo=%d orch=%s pos=%d", nonce, seg.SeqNo, sess.OrchestratorInfo.Transcoder, pos)
+		fallbackChainUsed(pos)
+	}
 
 	// download transcoded segments from the transcoder
 	gotErr := false // only send one error msg per segment list
@@ -435,6 +724,13 @@ func transcodeSegment(cxn *rtmpConnection, seg *stream.HLSSegment, name string,
 	segURLs := make([]string, len(res.Segments))
 	segLock := &sync.Mutex{}
 	cond := sync.NewCond(segLock)
+	// renditions saved in background are inserted into the playlist once
+	// saved, if the segment is accepted by then, rather than pointing the
+	// playlist to the orchestrator
+	retrying := make([]bool, len(res.Segments))
+	accepted := false
+	segDone := make(chan struct{})
+	defer close(segDone)
 
 	dlFunc := func(url string, pixels int64, i int) {
 		defer func() {
@@ -452,8 +748,9 @@ func transcodeSegment(cxn *rtmpConnection, seg *stream.HLSSegment, name string,
 		var data []byte
 		// Download segment data in the following cases:
 		// - A verification policy is set. The segment data is needed for signature verification and/or pixel count verification
+		// - The segment is sampled for the check against another orchestrator
 		// - The segment data needs to be uploaded to the broadcaster's own OS
-		if verifier != nil || (bos != nil && !drivers.IsOwnExternal(url)) {
+		if verifier != nil || sampled || (bos != nil && !drivers.IsOwnExternal(url)) {
 			d, err := downloadSeg(url)
 			if err != nil {
 				errFunc(monitor.SegmentTranscodeErrorDownload, url, err)
@@ -464,6 +761,9 @@ func transcodeSegment(cxn *rtmpConnection, seg *stream.HLSSegment, name string,
 				cxn.sessManager.removeSession(sess)
 				return
 			}
+			if monitor.Enabled {
+				monitor.RecordSegmentBytes(monitor.SegmentBytesDownload, int64(len(d)))
+			}
 
 			data = d
 		}
@@ -475,7 +775,24 @@ func transcodeSegment(cxn *rtmpConnection, seg *stream.HLSSegment, name string,
 				return
 			}
 			name := fmt.Sprintf("%s/%d%s", profile.Name, seg.SeqNo, ext)
-			newURL, err := bos.SaveData(name, data)
+			newURL, err := bos.SaveData(name, data, nil)
+			if err != nil && drivers.UploadRetries != nil && drivers.UploadRetries.Add(bos, name, data, nil, func(uri string, err error) {
+				<-segDone
+				if err != nil || !accepted {
+					return
+				}
+				if err := cpl.InsertHLSSegment(&profile, seg.SeqNo, uri, seg.Duration, len(data)); err != nil {
+					glog.Errorf("Playlist insertion error nonce=%d manifestID=%s seqNo=%d err=%s", nonce, cxn.mid, seg.SeqNo, err)
+				}
+			}) {
+				// the orchestrator's URL is only used for verification and
+				// the response while the rendition is saved in background
+				glog.Errorf("Error saving segment to own storage, retrying in background nonce=%d seqNo=%d name=%s err=%v", nonce, seg.SeqNo, name, err)
+				segLock.Lock()
+				retrying[i] = true
+				segLock.Unlock()
+				newURL, err = url, nil
+			}
 			if err != nil {
 				switch err.Error() {
 				case "Session ended":
@@ -526,7 +843,10 @@ func transcodeSegment(cxn *rtmpConnection, seg *stream.HLSSegment, name string,
 	}
 
 	for i, url := range segURLs {
-		err := cpl.InsertHLSSegment(&sess.Params.Profiles[i], seg.SeqNo, url, seg.Duration)
+		if retrying[i] {
+			continue
+		}
+		err := cpl.InsertHLSSegment(&sess.Params.Profiles[i], seg.SeqNo, url, seg.Duration, len(segData[i]))
 		if err != nil {
 			// InsertHLSSegment only returns ErrSegmentAlreadyExists error
 			// Right now InsertHLSSegment call is atomic regarding transcoded segments - we either inserting
@@ -543,11 +863,67 @@ func transcodeSegment(cxn *rtmpConnection, seg *stream.HLSSegment, name string,
 		monitor.SegmentFullyTranscoded(nonce, seg.SeqNo, common.ProfilesNames(sess.Params.Profiles), errCode)
 	}
 
+	if sampled {
+		go checkDeterminism(cxn, seg, sess, segData)
+	}
+
 	glog.V(common.DEBUG).Infof("Successfully validated segment nonce=%d seqNo=%d", nonce, seg.SeqNo)
+	accepted = true
 	return segURLs, nil
 }
 
-var sessionErrStrings = []string{"dial tcp", "unexpected EOF", core.ErrOrchBusy.Error(), core.ErrOrchCap.Error()}
+// checkDeterminism transcodes the segment again with another orchestrator
+// than the one of the session and checks the outputs of the session match.
+// The check is skipped if it would take the last session available to the
+// live segments of the stream.
+func checkDeterminism(cxn *rtmpConnection, seg *stream.HLSSegment, sess *BroadcastSession, outputs [][]byte) {
+	orch := orchestratorID(sess.OrchestratorInfo)
+	other := cxn.sessManager.selectSpareSession(orch)
+	if other == nil {
+		glog.V(common.DEBUG).Infof("No spare orchestrator to check segment nonce=%d seqNo=%d orch=%s", cxn.nonce, seg.SeqNo, orch)
+		return
+	}
+
+	res, err := SubmitSegment(other, seg, cxn.nonce)
+	if err != nil || res == nil {
+		// the check failing says nothing about the orchestrator's live
+		// segments, so it isn't suspended
+		glog.Errorf("Could not transcode segment to check nonce=%d seqNo=%d orch=%s err=%v", cxn.nonce, seg.SeqNo, orch, err)
+		cxn.sessManager.removeSession(other)
+		return
+	}
+	cxn.sessManager.completeSession(updateSession(other, res))
+
+	otherOutputs := make([][]byte, len(res.Segments))
+	for i, v := range res.Segments {
+		if otherOutputs[i], err = downloadSeg(v.Url); err != nil {
+			glog.Errorf("Could not download segment to check nonce=%d seqNo=%d orch=%s err=%v", cxn.nonce, seg.SeqNo, orch, err)
+			return
+		}
+	}
+
+	passed, err := outputsMatch(outputs, otherOutputs, VerificationTolerance)
+	if err != nil {
+		glog.Errorf("Could not compare segment outputs nonce=%d seqNo=%d orch=%s err=%v", cxn.nonce, seg.SeqNo, orch, err)
+		return
+	}
+	if !passed {
+		glog.Warningf("Outputs don't match the ones of another orchestrator nonce=%d seqNo=%d orch=%s otherOrch=%s",
+			cxn.nonce, seg.SeqNo, orch, orchestratorID(other.OrchestratorInfo))
+	}
+	verificationChecked(orch, passed)
+}
+
+// orchestratorID returns the orchestrator's ETH address, or its service URI
+// when running off-chain
+func orchestratorID(info *net.OrchestratorInfo) string {
+	if addr := info.GetAddress(); len(addr) > 0 {
+		return ethcommon.BytesToAddress(addr).Hex()
+	}
+	return info.GetTranscoder()
+}
+
+var sessionErrStrings = []string{"dial tcp", "unexpected EOF", core.ErrOrchBusy.Error(), core.ErrOrchCap.Error(), core.ErrOrchUnhealthy.Error()}
 
 var sessionErrRegex = common.GenErrRegex(sessionErrStrings)
 
@@ -601,7 +977,7 @@ func verify(verifier *verification.SegmentVerifier, cxn *rtmpConnection,
 				// Hence, trim the /stream/<manifestID> prefix if it exists.
 				pfx := fmt.Sprintf("/stream/%s/", sess.Params.ManifestID)
 				uri := strings.TrimPrefix(accepted.URIs[i], pfx)
-				_, err := sess.BroadcasterOS.SaveData(uri, data)
+				_, err := sess.BroadcasterOS.SaveData(uri, data, nil)
 				if err != nil {
 					return err
 				}
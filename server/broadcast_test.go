@@ -2,11 +2,15 @@ package server
 
 import (
 	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"math/big"
 	"net/http"
 	"net/url"
+	"reflect"
 	"strings"
 	"sync"
 	"testing"
@@ -111,12 +115,25 @@ type stubOSSession struct {
 	err      error
 }
 
-func (s *stubOSSession) SaveData(name string, data []byte) (string, error) {
+func (s *stubOSSession) SaveData(name string, data []byte, props *drivers.FileProperties) (string, error) {
+	s.saved = append(s.saved, name)
+	return "saved_" + name, s.err
+}
+func (s *stubOSSession) SaveDataReader(name string, r io.Reader, size int64) (string, error) {
 	s.saved = append(s.saved, name)
 	return "saved_" + name, s.err
 }
 func (s *stubOSSession) EndSession() {
 }
+func (s *stubOSSession) ListData() ([]*drivers.FileInfo, error) {
+	return nil, nil
+}
+func (s *stubOSSession) DeleteData(name string) error {
+	return nil
+}
+func (s *stubOSSession) ReadData(name string) ([]byte, error) {
+	return nil, drivers.ErrNotFound
+}
 func (s *stubOSSession) GetInfo() *net.OSInfo {
 	return nil
 }
@@ -136,7 +153,7 @@ func (pm *stubPlaylistManager) ManifestID() core.ManifestID {
 	return pm.manifestID
 }
 
-func (pm *stubPlaylistManager) InsertHLSSegment(profile *ffmpeg.VideoProfile, seqNo uint64, uri string, duration float64) error {
+func (pm *stubPlaylistManager) InsertHLSSegment(profile *ffmpeg.VideoProfile, seqNo uint64, uri string, duration float64, size int) error {
 	pm.profile = *profile
 	pm.seq = seqNo
 	pm.uri = uri
@@ -151,6 +168,10 @@ func (pm *stubPlaylistManager) GetHLSMediaPlaylist(rendition string) *m3u8.Media
 	return nil
 }
 
+func (pm *stubPlaylistManager) GetHLSMediaPlaylistStart(rendition string) float64 {
+	return 0
+}
+
 func (pm *stubPlaylistManager) GetOSSession() drivers.OSSession {
 	return pm.os
 }
@@ -176,6 +197,7 @@ func TestStopSessionErrors(t *testing.T) {
 		"Unable to submit segment 5 Post https://127.0.0.1:8936/segment: dial tcp 127.0.0.1:8936: getsockopt: connection refused",
 		core.ErrOrchBusy.Error(),
 		core.ErrOrchCap.Error(),
+		core.ErrOrchUnhealthy.Error(),
 	}
 
 	// Sanity check that we're checking each failure case
@@ -465,6 +487,93 @@ func TestRefreshSessions(t *testing.T) {
 	assert.True(wgWait(&wg), "Session refresh timed out")
 }
 
+func TestRefreshSessions_Warmup(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	defer func() { OrchestratorWarmup = false }()
+	OrchestratorWarmup = true
+
+	warmupData, err := core.TestSegmentData()
+	require.Nil(err)
+
+	// orchestrator recording the segments it receives
+	var mu sync.Mutex
+	var received [][]byte
+	var seqNos []int64
+	ts, mux := stubTLSServer()
+	defer ts.Close()
+	buf, err := proto.Marshal(&net.TranscodeResult{
+		Result: &net.TranscodeResult_Data{
+			Data: &net.TranscodeData{Segments: []*net.TranscodedSegmentData{{Url: "test.flv"}}},
+		},
+		Info: &net.OrchestratorInfo{Transcoder: ts.URL, PriceInfo: &net.PriceInfo{PricePerUnit: 7, PixelsPerUnit: 7}},
+	})
+	require.Nil(err)
+	mux.HandleFunc("/segment", func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		creds, _ := base64.StdEncoding.DecodeString(r.Header.Get(segmentHeader))
+		var segData net.SegData
+		proto.Unmarshal(creds, &segData)
+		mu.Lock()
+		received = append(received, data)
+		seqNos = append(seqNos, segData.Seq)
+		mu.Unlock()
+		w.Write(buf)
+	})
+	// orchestrator failing to transcode
+	failing, failingMux := stubTLSServer()
+	defer failing.Close()
+	failingMux.HandleFunc("/segment", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	sess := StubBroadcastSession(ts.URL)
+	sess.Params.Profiles = []ffmpeg.VideoProfile{ffmpeg.P144p30fps16x9}
+	sess.LatencyScore = 1.5
+	failingSess := StubBroadcastSession(failing.URL)
+	failingSess.Params.Profiles = []ffmpeg.VideoProfile{ffmpeg.P144p30fps16x9}
+
+	bsm := bsmWithSessList([]*BroadcastSession{})
+	bsm.createSessions = func() ([]*BroadcastSession, error) {
+		return []*BroadcastSession{sess, failingSess}, nil
+	}
+	bsm.refreshSessions()
+
+	// warmup runs on selection, failed orchestrator isn't used
+	require.Len(received, 1)
+	assert.Equal(warmupData, received[0])
+	// under a sequence number the stream's segments don't use
+	assert.Equal(int64(warmupSeqNo), seqNos[0])
+	require.Len(bsm.sessMap, 1)
+	warmSess := bsm.sessMap[ts.URL]
+	require.NotNil(warmSess)
+	assert.Equal(int64(7), warmSess.OrchestratorInfo.PriceInfo.PricePerUnit)
+	assert.Equal(1.5, warmSess.LatencyScore)
+
+	// and before the first real segment
+	cxn := &rtmpConnection{
+		mid:         core.ManifestID("foo"),
+		nonce:       7,
+		pl:          &stubPlaylistManager{manifestID: core.ManifestID("foo")},
+		profile:     &ffmpeg.P144p30fps16x9,
+		sessManager: bsm,
+	}
+	_, err = transcodeSegment(cxn, &stream.HLSSegment{Data: []byte("dummy"), Duration: 2.0}, "dummy", nil, nil)
+	assert.Nil(err)
+	require.Len(received, 2)
+	assert.Equal([]byte("dummy"), received[1])
+	assert.Equal(int64(0), seqNos[1])
+
+	// sessions already in use aren't warmed up again
+	bsm.createSessions = func() ([]*BroadcastSession, error) {
+		return []*BroadcastSession{StubBroadcastSession(ts.URL)}, nil
+	}
+	bsm.refreshSessions()
+	assert.Len(received, 2)
+	assert.Len(bsm.sessMap, 1)
+}
+
 func TestCleanupSessions(t *testing.T) {
 	bsm := newSessionsManagerLIFO(StubBroadcastSessionsManager())
 
@@ -487,7 +596,8 @@ func TestTranscodeSegment_UploadFailed_SuspendAndRemove(t *testing.T) {
 	assert := assert.New(t)
 	mid := core.ManifestID("foo")
 	pl := &stubPlaylistManager{manifestID: mid}
-	drivers.S3BUCKET = "livepeer"
+	drivers.NewS3Driver(drivers.S3Options{Bucket: "livepeer"}) // own bucket
+	defer drivers.ResetOwnS3Buckets()
 	mem := &stubOSSession{err: errors.New("some error")}
 	assert.NotNil(mem)
 
@@ -503,13 +613,58 @@ func TestTranscodeSegment_UploadFailed_SuspendAndRemove(t *testing.T) {
 		sessManager: bsm,
 	}
 	seg := &stream.HLSSegment{}
-	_, err := transcodeSegment(cxn, seg, "dummy", nil)
+	_, err := transcodeSegment(cxn, seg, "dummy", nil, nil)
 	assert.EqualError(err, "some error")
 	_, ok := cxn.sessManager.sessMap[sess.OrchestratorInfo.GetTranscoder()]
 	assert.False(ok)
 	assert.Greater(cxn.sessManager.sus.Suspended(sess.OrchestratorInfo.GetTranscoder()), 0)
 }
 
+func TestTranscodeSegment_HungTranscode_TimeoutAndRemove(t *testing.T) {
+	assert := assert.New(t)
+
+	oldFactor, oldTimeout := TranscodeTimeoutFactor, common.HTTPTimeout
+	defer func() { TranscodeTimeoutFactor, common.HTTPTimeout = oldFactor, oldTimeout }()
+	TranscodeTimeoutFactor = 2
+	common.HTTPTimeout = 0
+
+	ts, mux := stubTLSServer()
+	defer ts.Close()
+	hang := make(chan struct{})
+	defer close(hang)
+	mux.HandleFunc("/segment", func(w http.ResponseWriter, r *http.Request) {
+		// transcode never finishes
+		select {
+		case <-hang:
+		case <-r.Context().Done():
+		}
+	})
+
+	sess := StubBroadcastSession(ts.URL)
+	sess.Params.Profiles = []ffmpeg.VideoProfile{ffmpeg.P144p30fps16x9}
+	bsm := bsmWithSessList([]*BroadcastSession{sess})
+	cxn := &rtmpConnection{
+		mid:         core.ManifestID("foo"),
+		nonce:       7,
+		pl:          &stubPlaylistManager{manifestID: core.ManifestID("foo")},
+		profile:     &ffmpeg.P144p30fps16x9,
+		sessManager: bsm,
+	}
+
+	start := time.Now()
+	_, err := transcodeSegment(cxn, &stream.HLSSegment{Data: []byte("dummy"), Duration: 0.1}, "dummy", nil, nil)
+	took := time.Since(start)
+
+	// cancelled at 2x segment duration
+	assert.Contains(err.Error(), "context deadline exceeded")
+	assert.GreaterOrEqual(int64(took), int64(200*time.Millisecond))
+	assert.Less(int64(took), int64(time.Second))
+	// session is dropped so the retry goes to another orchestrator
+	_, ok := bsm.sessMap[ts.URL]
+	assert.False(ok)
+	assert.Greater(bsm.sus.Suspended(ts.URL), 0)
+}
+
 func TestTranscodeSegment_ExpiredParams_GetOrchestratorInfoAndRetry(t *testing.T) {
 	require := require.New(t)
 	assert := assert.New(t)
@@ -559,7 +714,7 @@ func TestTranscodeSegment_ExpiredParams_GetOrchestratorInfoAndRetry(t *testing.T
 
 	// Validate TicketParams error (not ErrTicketParamsExpired) -> Don't refresh, remove session & suspend orch
 	sender.On("ValidateTicketParams", mock.Anything).Return(errors.New("some error")).Once()
-	_, err = transcodeSegment(cxn, &stream.HLSSegment{Data: []byte("dummy"), Duration: 2.0}, "dummy", nil)
+	_, err = transcodeSegment(cxn, &stream.HLSSegment{Data: []byte("dummy"), Duration: 2.0}, "dummy", nil, nil)
 	assert.True(strings.Contains(err.Error(), "some error"))
 	_, ok := cxn.sessManager.sessMap[ts.URL]
 	assert.False(ok)
@@ -574,7 +729,7 @@ func TestTranscodeSegment_ExpiredParams_GetOrchestratorInfoAndRetry(t *testing.T
 	}
 	// Expired Orchestrator Info -> GetOrchestratorInfo error -> Error
 	sender.On("ValidateTicketParams", mock.Anything).Return(pm.ErrTicketParamsExpired)
-	_, err = transcodeSegment(cxn, &stream.HLSSegment{Data: []byte("dummy"), Duration: 2.0}, "dummy", nil)
+	_, err = transcodeSegment(cxn, &stream.HLSSegment{Data: []byte("dummy"), Duration: 2.0}, "dummy", nil, nil)
 	assert.True(strings.Contains(err.Error(), "unable to refresh ticket params"))
 	_, ok = cxn.sessManager.sessMap[ts.URL]
 	assert.False(ok)
@@ -610,7 +765,7 @@ func TestTranscodeSegment_ExpiredParams_GetOrchestratorInfoAndRetry(t *testing.T
 	balance.On("StageUpdate", mock.Anything, mock.Anything).Return(1, big.NewRat(100, 1), big.NewRat(100, 1))
 	sender.On("CreateTicketBatch", mock.Anything, mock.Anything).Return(nil, pm.ErrTicketParamsExpired).Once()
 	balance.On("Credit", mock.Anything)
-	_, err = transcodeSegment(cxn, &stream.HLSSegment{Data: []byte("dummy"), Duration: 2.0}, "dummy", nil)
+	_, err = transcodeSegment(cxn, &stream.HLSSegment{Data: []byte("dummy"), Duration: 2.0}, "dummy", nil, nil)
 	assert.EqualError(err, pm.ErrTicketParamsExpired.Error())
 	_, ok = cxn.sessManager.sessMap[ts.URL]
 	assert.False(ok)
@@ -627,7 +782,7 @@ func TestTranscodeSegment_ExpiredParams_GetOrchestratorInfoAndRetry(t *testing.T
 
 	sender.On("ValidateTicketParams", mock.Anything).Return(nil)
 	sender.On("CreateTicketBatch", mock.Anything, mock.Anything).Return(defaultTicketBatch(), nil).Once()
-	_, err = transcodeSegment(cxn, &stream.HLSSegment{Data: []byte("dummy"), Duration: 2.0}, "dummy", nil)
+	_, err = transcodeSegment(cxn, &stream.HLSSegment{Data: []byte("dummy"), Duration: 2.0}, "dummy", nil, nil)
 	assert.Nil(err)
 
 	completedSess := cxn.sessManager.sessMap[ts.URL]
@@ -682,7 +837,7 @@ func TestTranscodeSegment_SuspendOrchestrator(t *testing.T) {
 		sessManager: bsm,
 	}
 
-	_, err = transcodeSegment(cxn, &stream.HLSSegment{Data: []byte("dummy"), Duration: 2.0}, "dummy", nil)
+	_, err = transcodeSegment(cxn, &stream.HLSSegment{Data: []byte("dummy"), Duration: 2.0}, "dummy", nil, nil)
 
 	assert.EqualError(err, "OrchestratorBusy")
 	assert.Equal(bsm.sus.Suspended(ts.URL), bsm.poolSize/bsm.numOrchs)
@@ -722,7 +877,7 @@ func TestTranscodeSegment_CompleteSession(t *testing.T) {
 		sessManager: bsm,
 	}
 
-	_, err = transcodeSegment(cxn, &stream.HLSSegment{Data: []byte("dummy"), Duration: 2.0}, "dummy", nil)
+	_, err = transcodeSegment(cxn, &stream.HLSSegment{Data: []byte("dummy"), Duration: 2.0}, "dummy", nil, nil)
 	assert.Nil(err)
 
 	completedSess := bsm.sessMap[ts.URL]
@@ -739,7 +894,7 @@ func TestTranscodeSegment_CompleteSession(t *testing.T) {
 	buf, err = proto.Marshal(tr)
 	require.Nil(err)
 
-	_, err = transcodeSegment(cxn, &stream.HLSSegment{Data: []byte("dummy"), Duration: 2.0}, "dummy", nil)
+	_, err = transcodeSegment(cxn, &stream.HLSSegment{Data: []byte("dummy"), Duration: 2.0}, "dummy", nil, nil)
 	assert.Nil(err)
 
 	// Check that BroadcastSession.OrchestratorInfo was updated
@@ -749,6 +904,65 @@ func TestTranscodeSegment_CompleteSession(t *testing.T) {
 	assert.Equal(tr.Info.PriceInfo.PixelsPerUnit, completedSessInfo.PriceInfo.PixelsPerUnit)
 }
 
+// flakyOSSession fails the first saves
+type flakyOSSession struct {
+	stubOSSession
+	mu    sync.Mutex
+	fails int
+}
+
+func (s *flakyOSSession) SaveData(name string, data []byte, props *drivers.FileProperties) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fails > 0 {
+		s.fails--
+		return "", errors.New("SaveData error")
+	}
+	return "saved_" + name, nil
+}
+
+// insertedPlaylistManager reports the URIs inserted into the playlist
+type insertedPlaylistManager struct {
+	stubPlaylistManager
+	inserted chan string
+}
+
+func (pm *insertedPlaylistManager) InsertHLSSegment(profile *ffmpeg.VideoProfile, seqNo uint64, uri string, duration float64, size int) error {
+	pm.inserted <- uri
+	return nil
+}
+
+func TestTranscodeSegment_RenditionUploadRetried(t *testing.T) {
+	assert := assert.New(t)
+
+	oldRetries := drivers.UploadRetries
+	defer func() { drivers.UploadRetries = oldRetries }()
+	drivers.UploadRetries = drivers.NewUploadRetryQueue(1, 2, time.Millisecond)
+	oldDownloadSeg := downloadSeg
+	defer func() { downloadSeg = oldDownloadSeg }()
+	downloadSeg = func(url string) ([]byte, error) { return []byte(url), nil }
+
+	bcastOS := &flakyOSSession{fails: 1}
+	sess := genBcastSess(t, "orch/0.ts", bcastOS, "")
+	pl := &insertedPlaylistManager{inserted: make(chan string, 1)}
+	cxn := &rtmpConnection{
+		pl:          pl,
+		profile:     &ffmpeg.P144p30fps16x9,
+		sessManager: bsmWithSessList([]*BroadcastSession{sess}),
+	}
+
+	// rendition isn't inserted with the orchestrator's URL while saved in background
+	urls, err := transcodeSegment(cxn, &stream.HLSSegment{Data: []byte("dummy"), Duration: 2.0}, "dummy", nil, nil)
+	assert.Nil(err)
+	assert.Equal([]string{"orch/0.ts"}, urls)
+	select {
+	case uri := <-pl.inserted:
+		assert.Equal("saved_P144p30fps16x9/0.ts", uri)
+	case <-time.After(time.Second):
+		assert.Fail("rendition not inserted after retry")
+	}
+}
+
 func TestProcessSegment_MaxAttempts(t *testing.T) {
 	assert := assert.New(t)
 
@@ -808,6 +1022,186 @@ func TestProcessSegment_MaxAttempts(t *testing.T) {
 	assert.Len(bsm.sessMap, 0)
 }
 
+func TestProcessSegment_MaxOrchestrators(t *testing.T) {
+	assert := assert.New(t)
+
+	defer func(attempts, orchs int) {
+		MaxAttempts, MaxOrchestratorsPerSegment = attempts, orchs
+	}(MaxAttempts, MaxOrchestratorsPerSegment)
+	transcodeCalls := 0
+	resp := func(w http.ResponseWriter, r *http.Request) {
+		transcodeCalls++
+	}
+	var sessList []*BroadcastSession
+	for i := 0; i < 3; i++ {
+		ts, mux := stubTLSServer()
+		defer ts.Close()
+		mux.HandleFunc("/segment", resp)
+		sessList = append(sessList, StubBroadcastSession(ts.URL))
+	}
+	bsm := bsmWithSessList(sessList)
+	cxn := &rtmpConnection{
+		profile:     &ffmpeg.VideoProfile{Name: "unused"},
+		sessManager: bsm,
+		pl:          &stubPlaylistManager{os: &stubOSSession{}},
+	}
+
+	// segment is failed once max orchestrators are tried, before max attempts
+	MaxAttempts = 3
+	MaxOrchestratorsPerSegment = 2
+	_, err := processSegment(cxn, &stream.HLSSegment{})
+	assert.Equal(errMaxOrchs, err)
+	assert.Equal(2, transcodeCalls)
+	assert.Len(bsm.sessMap, 1)
+
+	// orchestrator that was already tried can be tried again
+	sessList[2].OrchestratorInfo.Address = []byte("orch1")
+	sessList[1].OrchestratorInfo.Address = []byte("orch1")
+	sessList[0].OrchestratorInfo.Address = []byte("orch2")
+	MaxOrchestratorsPerSegment = 1
+	transcodeCalls = 0
+	bsm = bsmWithSessList(sessList)
+	cxn.sessManager = bsm
+	_, err = processSegment(cxn, &stream.HLSSegment{})
+	assert.Equal(errMaxOrchs, err)
+	assert.Equal(2, transcodeCalls)
+	assert.Len(bsm.sessMap, 1)
+	for _, sess := range sessList {
+		sess.OrchestratorInfo.Address = nil
+	}
+
+	// no limit besides max attempts
+	MaxOrchestratorsPerSegment = 0
+	transcodeCalls = 0
+	bsm = bsmWithSessList(sessList)
+	cxn.sessManager = bsm
+	_, err = processSegment(cxn, &stream.HLSSegment{})
+	assert.EqualError(err, "Hit max transcode attempts: UnknownResponse")
+	assert.Equal(3, transcodeCalls)
+	assert.Len(bsm.sessMap, 0)
+}
+
+func TestProcessSegment_Failover(t *testing.T) {
+	assert := assert.New(t)
+
+	defer func(attempts int) { MaxAttempts = attempts }(MaxAttempts)
+	defer func(f func(core.ManifestID)) { segmentFailover = f }(segmentFailover)
+	failovers := make(map[core.ManifestID]int)
+	segmentFailover = func(mid core.ManifestID) { failovers[mid]++ }
+
+	// first orchestrator fails, the rest succeed
+	newSess := func(fail bool) *BroadcastSession {
+		ts, mux := stubTLSServer()
+		t.Cleanup(ts.Close)
+		mux.HandleFunc("/segment", func(w http.ResponseWriter, r *http.Request) {
+			if fail {
+				return
+			}
+			buf, err := proto.Marshal(&net.TranscodeResult{Result: &net.TranscodeResult_Data{Data: &net.TranscodeData{}}})
+			require.Nil(t, err)
+			w.Write(buf)
+		})
+		return StubBroadcastSession(ts.URL)
+	}
+	MaxAttempts = 3
+	// sessions are selected last in, first out
+	bsm := bsmWithSessList([]*BroadcastSession{newSess(false), newSess(true)})
+	cxn := &rtmpConnection{
+		mid:         "failovermid",
+		profile:     &ffmpeg.VideoProfile{Name: "unused"},
+		sessManager: bsm,
+		pl:          &stubPlaylistManager{os: &stubOSSession{}},
+	}
+
+	// the segment failing with the first orchestrator is switched to another
+	_, err := processSegment(cxn, &stream.HLSSegment{SeqNo: 1})
+	assert.Nil(err)
+	assert.Equal(1, failovers["failovermid"])
+
+	// segment transcoded by the first orchestrator doesn't fail over
+	_, err = processSegment(cxn, &stream.HLSSegment{SeqNo: 2})
+	assert.Nil(err)
+	assert.Equal(1, failovers["failovermid"])
+
+	// segment failing with all the orchestrators is counted once
+	bsm = bsmWithSessList([]*BroadcastSession{newSess(true), newSess(true), newSess(true)})
+	cxn.sessManager = bsm
+	_, err = processSegment(cxn, &stream.HLSSegment{SeqNo: 3})
+	assert.EqualError(err, "Hit max transcode attempts: UnknownResponse")
+	assert.Equal(2, failovers["failovermid"])
+}
+
+func TestProcessSegment_FallbackChain(t *testing.T) {
+	assert := assert.New(t)
+
+	defer func(attempts int) { MaxAttempts = attempts }(MaxAttempts)
+	defer func(f func(int)) { fallbackChainUsed = f }(fallbackChainUsed)
+	var positions []int
+	fallbackChainUsed = func(pos int) { positions = append(positions, pos) }
+
+	var mu sync.Mutex
+	failing := make(map[int]bool)
+	setFailing := func(i int, fail bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		failing[i] = fail
+	}
+	var sessList []*BroadcastSession
+	for i := 0; i < 3; i++ {
+		i := i
+		ts, mux := stubTLSServer()
+		defer ts.Close()
+		mux.HandleFunc("/segment", func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			fail := failing[i]
+			mu.Unlock()
+			if fail {
+				return
+			}
+			buf, err := proto.Marshal(&net.TranscodeResult{Result: &net.TranscodeResult_Data{Data: &net.TranscodeData{}}})
+			require.Nil(t, err)
+			w.Write(buf)
+		})
+		sessList = append(sessList, StubBroadcastSession(ts.URL))
+	}
+	MaxAttempts = 3
+	bsm := bsmWithSessList(sessList)
+	bsm.sel = NewFallbackChainSelector()
+	bsm.sel.Add(sessList)
+	cxn := &rtmpConnection{
+		mid:         "chainmid",
+		profile:     &ffmpeg.VideoProfile{Name: "unused"},
+		sessManager: bsm,
+		pl:          &stubPlaylistManager{os: &stubOSSession{}},
+	}
+	process := func(seqNo uint64) {
+		_, err := processSegment(cxn, &stream.HLSSegment{SeqNo: seqNo})
+		assert.Nil(err)
+	}
+
+	// primary is used while it works
+	process(1)
+	process(2)
+	assert.Equal([]int{0, 0}, positions)
+
+	// walks down the chain on successive failures
+	setFailing(0, true)
+	process(3)
+	process(4)
+	assert.Equal([]int{0, 0, 1, 1}, positions)
+	setFailing(1, true)
+	process(5)
+	assert.Equal([]int{0, 0, 1, 1, 2}, positions)
+	_, ok := bsm.sessMap[sessList[0].OrchestratorInfo.Transcoder]
+	assert.False(ok)
+
+	// returns to the primary once it recovers and is added back
+	setFailing(0, false)
+	bsm.refreshSessions()
+	process(6)
+	assert.Equal([]int{0, 0, 1, 1, 2, 0}, positions)
+}
+
 func TestTranscodeSegment_VerifyPixels(t *testing.T) {
 	require := require.New(t)
 	assert := assert.New(t)
@@ -850,7 +1244,7 @@ func TestTranscodeSegment_VerifyPixels(t *testing.T) {
 		sessManager: bsm,
 	}
 
-	urls, err := transcodeSegment(cxn, &stream.HLSSegment{Data: []byte("dummy")}, "dummy", nil)
+	urls, err := transcodeSegment(cxn, &stream.HLSSegment{Data: []byte("dummy")}, "dummy", nil, nil)
 	assert.Nil(err)
 	assert.NotNil(urls)
 	assert.Len(urls, 1)
@@ -871,7 +1265,7 @@ func TestTranscodeSegment_VerifyPixels(t *testing.T) {
 
 	sender.On("ValidateTicketParams", mock.Anything).Return(nil)
 
-	urls, err = transcodeSegment(cxn, &stream.HLSSegment{Data: []byte("dummy")}, "dummy", nil)
+	urls, err = transcodeSegment(cxn, &stream.HLSSegment{Data: []byte("dummy")}, "dummy", nil, nil)
 	assert.Nil(err)
 	assert.Equal("test.flv", urls[0])
 
@@ -881,7 +1275,7 @@ func TestTranscodeSegment_VerifyPixels(t *testing.T) {
 	bsm = bsmWithSessList([]*BroadcastSession{sess})
 	cxn.sessManager = bsm
 
-	_, err = transcodeSegment(cxn, &stream.HLSSegment{Data: []byte("dummy")}, "dummy", nil)
+	_, err = transcodeSegment(cxn, &stream.HLSSegment{Data: []byte("dummy")}, "dummy", nil, nil)
 	assert.Nil(err)
 
 	// Wait for async pixels verification to finish
@@ -977,7 +1371,7 @@ func TestHLSInsertion(t *testing.T) {
 	}
 
 	seg := &stream.HLSSegment{SeqNo: 93}
-	_, err = transcodeSegment(cxn, seg, "dummy", nil)
+	_, err = transcodeSegment(cxn, seg, "dummy", nil, nil)
 	assert.Nil(err)
 
 	// some sanity checks
@@ -1029,29 +1423,29 @@ func TestVerifier_Invocation(t *testing.T) {
 	}
 
 	seg := &stream.HLSSegment{}
-	_, err = transcodeSegment(cxn, seg, "dummy", segmentVerifier)
+	_, err = transcodeSegment(cxn, seg, "dummy", segmentVerifier, nil)
 	assert.Nil(err)
 	assert.Equal(1, verifier.calls)
 	require.NotNil(verifier.params)
 	assert.Equal(cxn.mid, verifier.params.ManifestID)
 	assert.Equal(seg, verifier.params.Source)
 	// Do it again for good measure
-	_, err = transcodeSegment(cxn, seg, "dummy", segmentVerifier)
+	_, err = transcodeSegment(cxn, seg, "dummy", segmentVerifier, nil)
 	assert.Nil(err)
 	assert.Equal(2, verifier.calls)
 
 	// now "disable" the verifier and ensure no calls
-	_, err = transcodeSegment(cxn, seg, "dummy", nil)
+	_, err = transcodeSegment(cxn, seg, "dummy", nil, nil)
 	assert.Nil(err)
 	assert.Equal(2, verifier.calls)
 
 	// Pass in a nil policy
-	_, err = transcodeSegment(cxn, seg, "dummy", verification.NewSegmentVerifier(nil))
+	_, err = transcodeSegment(cxn, seg, "dummy", verification.NewSegmentVerifier(nil), nil)
 	assert.Nil(err)
 
 	// Pass in a policy but no verifier specified
 	policy = &verification.Policy{}
-	_, err = transcodeSegment(cxn, seg, "dummy", verification.NewSegmentVerifier(policy))
+	_, err = transcodeSegment(cxn, seg, "dummy", verification.NewSegmentVerifier(policy), nil)
 	assert.Nil(err)
 }
 
@@ -1112,7 +1506,7 @@ func TestVerifier_Verify(t *testing.T) {
 	}
 	mem, ok := drivers.NewMemoryDriver(nil).NewSession("streamName").(*drivers.MemorySession)
 	assert.True(ok)
-	name, err := mem.SaveData("/rendition/seg/1", []byte("attempt1"))
+	name, err := mem.SaveData("/rendition/seg/1", []byte("attempt1"), nil)
 	assert.Nil(err)
 	assert.Equal([]byte("attempt1"), mem.GetData(name))
 	sess.BroadcasterOS = mem
@@ -1124,7 +1518,7 @@ func TestVerifier_Verify(t *testing.T) {
 
 	// Now "insert" 2nd attempt into OS
 	// and ensure 1st attempt is what remains after verification
-	_, err = mem.SaveData("/rendition/seg/1", []byte("attempt2"))
+	_, err = mem.SaveData("/rendition/seg/1", []byte("attempt2"), nil)
 	assert.Nil(err)
 	assert.Equal([]byte("attempt2"), mem.GetData(name))
 	renditionData = [][]byte{[]byte("attempt2")}
@@ -1146,8 +1540,8 @@ func TestVerifier_HLSInsertion(t *testing.T) {
 	//   6. Insert seg2 into playlist
 	mid := core.ManifestID("foo")
 	pl := &stubPlaylistManager{manifestID: mid}
-	drivers.S3BUCKET = "livepeer"
-	mem := drivers.NewS3Driver("", drivers.S3BUCKET, "", "").NewSession(string(mid))
+	mem := drivers.NewS3Driver(drivers.S3Options{Bucket: "livepeer"}).NewSession(string(mid))
+	defer drivers.ResetOwnS3Buckets()
 	assert.NotNil(mem)
 
 	baseURL := "https://livepeer.s3.amazonaws.com"
@@ -1177,15 +1571,15 @@ func TestVerifier_HLSInsertion(t *testing.T) {
 	defer func() { downloadSeg = oldDownloadSeg }()
 	downloadSeg = func(url string) ([]byte, error) { return []byte("foo"), nil }
 
-	_, err := transcodeSegment(cxn, seg, "dummy", verifier)
+	_, err := transcodeSegment(cxn, seg, "dummy", verifier, nil)
 	assert.Equal(verification.ErrTampered, err)
 	assert.Empty(pl.uri) // sanity check that no insertion happened
 
-	_, err = transcodeSegment(cxn, seg, "dummy", verifier)
+	_, err = transcodeSegment(cxn, seg, "dummy", verifier, nil)
 	assert.Equal(verification.ErrTampered, err)
 	assert.Empty(pl.uri)
 
-	_, err = transcodeSegment(cxn, seg, "dummy", verifier)
+	_, err = transcodeSegment(cxn, seg, "dummy", verifier, nil)
 	assert.Nil(err)
 	assert.Equal(baseURL+"/resp2", pl.uri)
 }
@@ -1194,8 +1588,8 @@ func TestDownloadSegError_SuspendAndRemove(t *testing.T) {
 	assert := assert.New(t)
 	mid := core.ManifestID("foo")
 	pl := &stubPlaylistManager{manifestID: mid}
-	drivers.S3BUCKET = "livepeer"
-	mem := drivers.NewS3Driver("", drivers.S3BUCKET, "", "").NewSession(string(mid))
+	mem := drivers.NewS3Driver(drivers.S3Options{Bucket: "livepeer"}).NewSession(string(mid))
+	defer drivers.ResetOwnS3Buckets()
 	assert.NotNil(mem)
 
 	baseURL := "https://livepeer.s3.amazonaws.com"
@@ -1221,7 +1615,7 @@ func TestDownloadSegError_SuspendAndRemove(t *testing.T) {
 	oldDownloadSeg := downloadSeg
 	defer func() { downloadSeg = oldDownloadSeg }()
 	downloadSeg = func(url string) ([]byte, error) { return nil, errors.New("some error") }
-	_, err := transcodeSegment(cxn, seg, "dummy", verifier)
+	_, err := transcodeSegment(cxn, seg, "dummy", verifier, nil)
 	assert.EqualError(err, "some error")
 	_, ok := cxn.sessManager.sessMap[sess.OrchestratorInfo.GetTranscoder()]
 	assert.False(ok)
@@ -1307,7 +1701,8 @@ func TestVerifier_SegDownload(t *testing.T) {
 
 	mid := core.ManifestID("foo")
 
-	drivers.S3BUCKET = "livepeer"
+	drivers.NewS3Driver(drivers.S3Options{Bucket: "livepeer"}) // own bucket
+	defer drivers.ResetOwnS3Buckets()
 	externalOS := &stubOSSession{}
 	bsm := bsmWithSessList([]*BroadcastSession{})
 	cxn := &rtmpConnection{
@@ -1335,21 +1730,21 @@ func TestVerifier_SegDownload(t *testing.T) {
 	// When there is no broadcaster OS, segments should not be downloaded
 	url := "somewhere1"
 	cxn.sessManager = bsmWithSessList([]*BroadcastSession{genBcastSess(t, url, nil, mid)})
-	_, err := transcodeSegment(cxn, seg, "dummy", nil)
+	_, err := transcodeSegment(cxn, seg, "dummy", nil, nil)
 	assert.Nil(err)
 	assert.False(downloaded[url])
 
 	// When segments are in the broadcaster's external OS, segments should not be downloaded
 	url = "https://livepeer.s3.amazonaws.com/resp1"
 	cxn.sessManager = bsmWithSessList([]*BroadcastSession{genBcastSess(t, url, externalOS, mid)})
-	_, err = transcodeSegment(cxn, seg, "dummy", nil)
+	_, err = transcodeSegment(cxn, seg, "dummy", nil, nil)
 	assert.Nil(err)
 	assert.False(downloaded[url])
 
 	// When segments are not in the broadcaster's external OS, segments should be downloaded
 	url = "somewhere2"
 	cxn.sessManager = bsmWithSessList([]*BroadcastSession{genBcastSess(t, url, externalOS, mid)})
-	_, err = transcodeSegment(cxn, seg, "dummy", nil)
+	_, err = transcodeSegment(cxn, seg, "dummy", nil, nil)
 	assert.Nil(err)
 	assert.True(downloaded[url])
 
@@ -1362,25 +1757,124 @@ func TestVerifier_SegDownload(t *testing.T) {
 	// When there is no broadcaster OS, segments should be downloaded
 	url = "somewhere3"
 	cxn.sessManager = bsmWithSessList([]*BroadcastSession{genBcastSess(t, url, nil, mid)})
-	_, err = transcodeSegment(cxn, seg, "dummy", verifier)
+	_, err = transcodeSegment(cxn, seg, "dummy", verifier, nil)
 	assert.Nil(err)
 	assert.True(downloaded[url])
 
 	// When segments are in the broadcaster's external OS, segments should be downloaded
 	url = "https://livepeer.s3.amazonaws.com/resp2"
 	cxn.sessManager = bsmWithSessList([]*BroadcastSession{genBcastSess(t, url, externalOS, mid)})
-	_, err = transcodeSegment(cxn, seg, "dummy", verifier)
+	_, err = transcodeSegment(cxn, seg, "dummy", verifier, nil)
 	assert.Nil(err)
 	assert.True(downloaded[url])
 
 	// When segments are not in the broadcaster's exernal OS, segments should be downloaded
 	url = "somewhere4"
 	cxn.sessManager = bsmWithSessList([]*BroadcastSession{genBcastSess(t, url, externalOS, mid)})
-	_, err = transcodeSegment(cxn, seg, "dummy", verifier)
+	_, err = transcodeSegment(cxn, seg, "dummy", verifier, nil)
 	assert.Nil(err)
 	assert.True(downloaded[url])
 }
 
+func TestTranscodeSegment_CheckDeterminism(t *testing.T) {
+	assert := assert.New(t)
+
+	mid := core.ManifestID("foo")
+	newCxn := func(sessList []*BroadcastSession) *rtmpConnection {
+		return &rtmpConnection{
+			mid:         mid,
+			pl:          &stubPlaylistManager{manifestID: mid},
+			profile:     &ffmpeg.P240p30fps16x9,
+			sessManager: bsmWithSessList(sessList),
+		}
+	}
+
+	oldDownloadSeg := downloadSeg
+	defer func() { downloadSeg = oldDownloadSeg }()
+	downloadSeg = func(url string) ([]byte, error) {
+		if url == "mismatch" {
+			return []byte("bar"), nil
+		}
+		return []byte("foo"), nil
+	}
+	defer func(rate float64) { VerificationSampleRate = rate }(VerificationSampleRate)
+	defer func(f func() bool) { verificationSampled = f }(verificationSampled)
+	defer func(f func(string, bool)) { verificationChecked = f }(verificationChecked)
+	defer func(f func(a, b [][]byte, maxDistance float64) (bool, error)) { outputsMatch = f }(outputsMatch)
+	VerificationSampleRate = 1
+	verificationSampled = func() bool { return true }
+	outputsMatch = func(a, b [][]byte, maxDistance float64) (bool, error) {
+		return reflect.DeepEqual(a, b), nil
+	}
+	type check struct {
+		orch   string
+		passed bool
+	}
+	checks := make(chan check, 1)
+	verificationChecked = func(orch string, passed bool) { checks <- check{orch, passed} }
+	nextCheck := func() *check {
+		select {
+		case c := <-checks:
+			return &c
+		case <-time.After(500 * time.Millisecond):
+			return nil
+		}
+	}
+
+	// sessions are selected last in, first out
+	transcode := func(otherURL string) *BroadcastSession {
+		sess := genBcastSess(t, "orig", nil, mid)
+		cxn := newCxn([]*BroadcastSession{genBcastSess(t, otherURL, nil, mid), sess})
+		_, err := transcodeSegment(cxn, &stream.HLSSegment{SeqNo: 1}, "dummy", nil, nil)
+		assert.Nil(err)
+		return sess
+	}
+
+	// outputs matching the other orchestrator's
+	sess := transcode("match")
+	assert.Equal(&check{orch: sess.OrchestratorInfo.Transcoder, passed: true}, nextCheck())
+
+	// outputs not matching
+	sess = transcode("mismatch")
+	assert.Equal(&check{orch: sess.OrchestratorInfo.Transcoder, passed: false}, nextCheck())
+
+	// not checked without another orchestrator
+	cxn := newCxn([]*BroadcastSession{genBcastSess(t, "orig", nil, mid)})
+	_, err := transcodeSegment(cxn, &stream.HLSSegment{SeqNo: 2}, "dummy", nil, nil)
+	assert.Nil(err)
+	assert.Nil(nextCheck())
+
+	// nor if not sampled
+	verificationSampled = func() bool { return false }
+	transcode("mismatch")
+	assert.Nil(nextCheck())
+}
+
+func TestSelectSpareSession(t *testing.T) {
+	assert := assert.New(t)
+
+	mid := core.ManifestID("foo")
+	orig := genBcastSess(t, "orig", nil, mid)
+	other := genBcastSess(t, "other", nil, mid)
+	orch := orchestratorID(orig.OrchestratorInfo)
+
+	// session of the orchestrator is skipped and put back
+	bsm := bsmWithSessList([]*BroadcastSession{other, orig})
+	assert.Equal(other, bsm.selectSpareSession(orch))
+	assert.Equal(1, bsm.sel.Size())
+	assert.Equal(orig, bsm.selectSession())
+
+	// the last session available is left for the live segments
+	bsm = bsmWithSessList([]*BroadcastSession{other})
+	assert.Nil(bsm.selectSpareSession(orch))
+	assert.Equal(1, bsm.sel.Size())
+
+	// no other orchestrator
+	bsm = bsmWithSessList([]*BroadcastSession{orig})
+	assert.Nil(bsm.selectSpareSession(orch))
+	assert.Equal(1, bsm.sel.Size())
+}
+
 func TestProcessSegment_VideoFormat(t *testing.T) {
 	// Test format from saving "transcoder" data into broadcaster/transcoder OS.
 	// For each rendition, check extension based on format (none, mp4, mpegts).
@@ -1510,3 +2004,13 @@ func genBcastSess(t *testing.T, url string, os drivers.OSSession, mid core.Manif
 		OrchestratorInfo: &net.OrchestratorInfo{Transcoder: ts.URL},
 	}
 }
+
+func TestOrchestratorID(t *testing.T) {
+	assert := assert.New(t)
+	// off-chain orchestrator is identified by its service URI
+	assert.Equal("https://orch:8935", orchestratorID(&net.OrchestratorInfo{Transcoder: "https://orch:8935"}))
+	// on-chain orchestrator is identified by its ETH address
+	info := &net.OrchestratorInfo{Transcoder: "https://orch:8935", Address: []byte{0x01, 0x02}}
+	assert.Equal("0x0000000000000000000000000000000000000102", orchestratorID(info))
+	assert.Equal("", orchestratorID(nil))
+}
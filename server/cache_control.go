@@ -0,0 +1,57 @@
+package server
+
+import (
+	"net/http"
+	"path"
+	"strings"
+)
+
+// Cache-Control header values of the successful HLS responses under /stream/
+// and DASH responses under /dash/. Live playlists change with every new segment so they shouldn't be cached,
+// while segments never change once written and can be cached for long by
+// CDNs. Empty value keeps the LPMS default.
+var (
+	PlaylistCacheControl = "no-cache"
+	SegmentCacheControl  = "public, max-age=31536000, immutable"
+)
+
+// cacheControlHandler sets Cache-Control header of the HLS and DASH responses according
+// to the response type, overriding the default set by LPMS
+func cacheControlHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/stream/") || strings.HasPrefix(r.URL.Path, "/dash/") {
+			value := SegmentCacheControl
+			if ext := path.Ext(r.URL.Path); ext == ".m3u8" || ext == ".mpd" {
+				value = PlaylistCacheControl
+			}
+			w = &cacheControlWriter{ResponseWriter: w, value: value}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// cacheControlWriter sets the Cache-Control header right before the headers
+// are written, so it takes precedence over the value set by the handler
+type cacheControlWriter struct {
+	http.ResponseWriter
+	value       string
+	wroteHeader bool
+}
+
+func (w *cacheControlWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		// errors keep the handler's value, so missing resources aren't cached for long
+		if code == http.StatusOK && w.value != "" {
+			w.Header().Set("Cache-Control", w.value)
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *cacheControlWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
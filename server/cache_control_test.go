@@ -0,0 +1,85 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheControlHandler(t *testing.T) {
+	assert := assert.New(t)
+
+	defer func(playlist, segment string) {
+		PlaylistCacheControl, SegmentCacheControl = playlist, segment
+	}(PlaylistCacheControl, SegmentCacheControl)
+
+	// mimics LPMS, which sets the same header for all the HLS responses
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stream/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=5")
+		if r.URL.Path == "/stream/missing.ts" {
+			http.Error(w, "ErrNotFound", http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("data"))
+	})
+	mux.HandleFunc("/dash/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("data"))
+	})
+	mux.HandleFunc("/other", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=5")
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := cacheControlHandler(mux)
+
+	cacheControl := func(path string) (int, string) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w.Code, w.Header().Get("Cache-Control")
+	}
+
+	// live playlists
+	code, cc := cacheControl("/stream/mid.m3u8")
+	assert.Equal(http.StatusOK, code)
+	assert.Equal("no-cache", cc)
+	_, cc = cacheControl("/stream/mid/P144p30fps16x9.m3u8")
+	assert.Equal("no-cache", cc)
+
+	// immutable segments
+	_, cc = cacheControl("/stream/mid/P144p30fps16x9/1.ts")
+	assert.Equal("public, max-age=31536000, immutable", cc)
+	_, cc = cacheControl("/stream/mid/source/1.mp4")
+	assert.Equal("public, max-age=31536000, immutable", cc)
+
+	// DASH manifests and segments
+	_, cc = cacheControl("/dash/mid.mpd")
+	assert.Equal("no-cache", cc)
+	_, cc = cacheControl("/dash/mid/P144p30fps16x9/init.mp4")
+	assert.Equal("public, max-age=31536000, immutable", cc)
+	_, cc = cacheControl("/dash/mid/P144p30fps16x9/1.m4s")
+	assert.Equal("public, max-age=31536000, immutable", cc)
+
+	// errors keep the default
+	code, cc = cacheControl("/stream/missing.ts")
+	assert.Equal(http.StatusNotFound, code)
+	assert.Equal("max-age=5", cc)
+
+	// other responses aren't affected
+	_, cc = cacheControl("/other")
+	assert.Equal("max-age=5", cc)
+
+	// configured values
+	PlaylistCacheControl = "max-age=1"
+	SegmentCacheControl = "max-age=600"
+	_, cc = cacheControl("/stream/mid.m3u8")
+	assert.Equal("max-age=1", cc)
+	_, cc = cacheControl("/stream/mid/source/1.ts")
+	assert.Equal("max-age=600", cc)
+
+	// empty value keeps the default
+	SegmentCacheControl = ""
+	_, cc = cacheControl("/stream/mid/source/1.ts")
+	assert.Equal("max-age=5", cc)
+}
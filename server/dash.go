@@ -0,0 +1,629 @@
+package server
+
+import (
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/livepeer/go-livepeer/core"
+	"github.com/livepeer/go-livepeer/drivers"
+	"github.com/livepeer/go-livepeer/monitor"
+	ffmpeg "github.com/livepeer/lpms/ffmpeg"
+	"github.com/livepeer/m3u8"
+)
+
+// DASH output of the streams, next to the HLS output served by LPMS:
+//
+//	/dash/<manifestID>.mpd                     manifest
+//	/dash/<manifestID>/<rendition>/init.mp4    initialization segment
+//	/dash/<manifestID>/<rendition>/<seqNo>.m4s media segment
+//
+// The manifest is built from the HLS media playlists of the stream, so DASH
+// shares their seqNo and duration bookkeeping. The media segments are the
+// MPEG-TS segments of the HLS playlists remuxed into fragmented MP4, retimed
+// to the presentation time the playlist gives them.
+
+var errDASHNoInit = errors.New("no initialization segment")
+
+// dashMuxer remuxes MPEG-TS segment data into fragmented MP4 without
+// transcoding it
+var dashMuxer = func(data []byte) ([]byte, error) {
+	dir, err := ioutil.TempDir("", "dash")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	in := filepath.Join(dir, "in.ts")
+	if err := ioutil.WriteFile(in, data, 0644); err != nil {
+		return nil, err
+	}
+	out := filepath.Join(dir, "out.mp4")
+	_, err = ffmpeg.Transcode3(&ffmpeg.TranscodeOptionsIn{Fname: in}, []ffmpeg.TranscodeOptions{{
+		Oname:        out,
+		VideoEncoder: ffmpeg.ComponentOptions{Name: "copy"},
+		AudioEncoder: ffmpeg.ComponentOptions{Name: "copy"},
+		Muxer: ffmpeg.ComponentOptions{
+			Name: "mp4",
+			Opts: map[string]string{"movflags": "frag_keyframe+empty_moov+default_base_moof"},
+		},
+	}})
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadFile(out)
+}
+
+// dashState holds the DASH output of a stream
+type dashState struct {
+	mu sync.Mutex
+	// wall clock time of the presentation time zero, set by the first
+	// manifest request
+	start time.Time
+	// initialization segments and codecs, by rendition
+	inits map[string]*dashInit
+}
+
+type dashInit struct {
+	data   []byte
+	codecs string
+}
+
+// availabilityStart returns the wall clock time of the presentation time zero
+// of the stream, given the presentation time the stream is live at now
+func (d *dashState) availabilityStart(liveEdge float64) time.Time {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.start.IsZero() {
+		d.start = time.Now().Add(-time.Duration(liveEdge * float64(time.Second))).UTC().Truncate(time.Millisecond)
+	}
+	return d.start
+}
+
+// HandleDASH serves the DASH manifests and segments of the streams
+func (s *LivepeerServer) HandleDASH(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	reqPath := strings.TrimPrefix(r.URL.Path, "/dash/")
+	var (
+		data        []byte
+		contentType string
+		err         error
+	)
+	if path.Ext(reqPath) == ".mpd" {
+		mid := core.ManifestID(strings.TrimSuffix(reqPath, ".mpd"))
+		if s.ExposeCurrentManifest && strings.ToLower(reqPath) == "current.mpd" {
+			mid = s.LastManifestID()
+		}
+		data, err = s.dashManifest(mid)
+		contentType = "application/dash+xml"
+	} else {
+		parts := strings.Split(reqPath, "/")
+		if len(parts) != 3 {
+			http.Error(w, "ErrNotFound", http.StatusNotFound)
+			return
+		}
+		data, err = s.dashSegment(core.ManifestID(parts[0]), parts[1], parts[2])
+		contentType = "video/mp4"
+	}
+	if err != nil {
+		glog.Errorf("Error serving DASH path=%s err=%v", r.URL.Path, err)
+		http.Error(w, "ErrNotFound", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Write(data)
+}
+
+func (s *LivepeerServer) dashConnection(mid core.ManifestID) (*rtmpConnection, error) {
+	s.connectionLock.RLock()
+	defer s.connectionLock.RUnlock()
+	cxn, ok := s.rtmpConnections[mid]
+	if !ok || cxn.pl == nil || !cxn.warmup.isReady() || cxn.pl.ManifestID() != mid {
+		return nil, errUnknownStream
+	}
+	return cxn, nil
+}
+
+// dashManifest returns the manifest of the stream, listing the segments of
+// its HLS media playlists
+func (s *LivepeerServer) dashManifest(mid core.ManifestID) ([]byte, error) {
+	cxn, err := s.dashConnection(mid)
+	if err != nil {
+		return nil, err
+	}
+	master := cxn.pl.GetHLSMasterPlaylist()
+	if master == nil {
+		return nil, errUnknownStream
+	}
+
+	var reps []dashRepresentation
+	var liveEdge, window, maxDuration float64
+	for _, v := range master.Variants {
+		rendition := strings.TrimSuffix(path.Base(v.URI), ".m3u8")
+		segs := playlistSegments(v.Chunklist)
+		if len(segs) == 0 {
+			continue
+		}
+		init, err := s.dashInitSegment(cxn, rendition, segs[len(segs)-1].URI)
+		if err != nil {
+			glog.Errorf("Error getting DASH initialization segment manifestID=%s rendition=%s err=%v", mid, rendition, err)
+			continue
+		}
+		start := cxn.pl.GetHLSMediaPlaylistStart(rendition)
+		rep := newDASHRepresentation(string(mid), rendition, start, segs)
+		rep.Bandwidth = v.Bandwidth
+		rep.Codecs = init.codecs
+		if res := strings.SplitN(v.Resolution, "x", 2); len(res) == 2 {
+			rep.Width, _ = strconv.Atoi(res[0])
+			rep.Height, _ = strconv.Atoi(res[1])
+		}
+		reps = append(reps, rep)
+
+		end := start
+		for _, seg := range segs {
+			end += seg.Duration
+			maxDuration = math.Max(maxDuration, seg.Duration)
+		}
+		liveEdge = math.Max(liveEdge, end)
+		window = math.Max(window, end-start)
+	}
+	if len(reps) == 0 {
+		return nil, errUnknownStream
+	}
+	if monitor.Enabled {
+		monitor.PlaylistRequested(cxn.nonce)
+	}
+
+	mpd := dashMPD{
+		XMLNS:                 "urn:mpeg:dash:schema:mpd:2011",
+		Profiles:              "urn:mpeg:dash:profile:isoff-live:2011",
+		Type:                  "dynamic",
+		AvailabilityStartTime: cxn.dash.availabilityStart(liveEdge).Format(time.RFC3339Nano),
+		PublishTime:           time.Now().UTC().Format(time.RFC3339),
+		MinimumUpdatePeriod:   dashDuration(maxDuration),
+		MinBufferTime:         dashDuration(maxDuration),
+		TimeShiftBufferDepth:  dashDuration(window),
+		Period: dashPeriod{
+			ID:    "0",
+			Start: dashDuration(0),
+			AdaptationSet: dashAdaptationSet{
+				MimeType:         "video/mp4",
+				SegmentAlignment: true,
+				StartWithSAP:     1,
+				Representations:  reps,
+			},
+		},
+	}
+	out, err := xml.MarshalIndent(mpd, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
+
+// dashSegment returns the initialization or the media segment of the
+// rendition of the stream
+func (s *LivepeerServer) dashSegment(mid core.ManifestID, rendition, name string) ([]byte, error) {
+	cxn, err := s.dashConnection(mid)
+	if err != nil {
+		return nil, err
+	}
+	segs := playlistSegments(cxn.pl.GetHLSMediaPlaylist(rendition))
+	if len(segs) == 0 {
+		return nil, errUnknownStream
+	}
+	if name == "init.mp4" {
+		init, err := s.dashInitSegment(cxn, rendition, segs[len(segs)-1].URI)
+		if err != nil {
+			return nil, err
+		}
+		return init.data, nil
+	}
+
+	seqNo, err := strconv.ParseUint(strings.TrimSuffix(name, ".m4s"), 10, 64)
+	if err != nil || path.Ext(name) != ".m4s" {
+		return nil, fmt.Errorf("invalid segment name=%s", name)
+	}
+	start := cxn.pl.GetHLSMediaPlaylistStart(rendition)
+	var seg *m3u8.MediaSegment
+	for _, sg := range segs {
+		if sg.SeqId == seqNo {
+			seg = sg
+			break
+		}
+		start += sg.Duration
+	}
+	if seg == nil {
+		return nil, fmt.Errorf("segment seqNo=%d not in the playlist", seqNo)
+	}
+	data, err := dashSegmentData(seg.URI)
+	if err != nil {
+		return nil, err
+	}
+	fmp4, err := dashMuxer(data)
+	if err != nil {
+		return nil, err
+	}
+	init, media, err := splitFMP4(fmp4)
+	if err != nil {
+		return nil, err
+	}
+	if err := retimeFMP4(init, media, start); err != nil {
+		return nil, err
+	}
+	if monitor.Enabled {
+		monitor.SegmentServed(cxn.nonce)
+	}
+	return media, nil
+}
+
+// dashInitSegment returns the initialization segment of the rendition, taken
+// from the given segment on the first request
+func (s *LivepeerServer) dashInitSegment(cxn *rtmpConnection, rendition, uri string) (*dashInit, error) {
+	d := &cxn.dash
+	d.mu.Lock()
+	init := d.inits[rendition]
+	d.mu.Unlock()
+	if init != nil {
+		return init, nil
+	}
+
+	data, err := dashSegmentData(uri)
+	if err != nil {
+		return nil, err
+	}
+	fmp4, err := dashMuxer(data)
+	if err != nil {
+		return nil, err
+	}
+	initData, _, err := splitFMP4(fmp4)
+	if err != nil {
+		return nil, err
+	}
+	init = &dashInit{data: initData, codecs: fmp4Codecs(initData)}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.inits == nil {
+		d.inits = make(map[string]*dashInit)
+	}
+	if prev := d.inits[rendition]; prev != nil {
+		return prev, nil
+	}
+	d.inits[rendition] = init
+	return init, nil
+}
+
+// dashSegmentData returns the data of the playlist segment, from the node
+// storage or from the external storage it was uploaded to
+func dashSegmentData(uri string) ([]byte, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	if segName := cleanStreamPrefix(u.Path); segName != "" && drivers.NodeStorage != nil {
+		if data := getNodeSegmentData(segName); len(data) > 0 {
+			return data, nil
+		}
+	}
+	if u.Scheme == "http" || u.Scheme == "https" {
+		return drivers.GetSegmentData(uri)
+	}
+	return nil, fmt.Errorf("segment not found uri=%s", uri)
+}
+
+// playlistSegments returns the segments of the media playlist in order
+func playlistSegments(pl *m3u8.MediaPlaylist) []*m3u8.MediaSegment {
+	if pl == nil {
+		return nil
+	}
+	var segs []*m3u8.MediaSegment
+	for _, seg := range pl.Segments {
+		if seg != nil {
+			segs = append(segs, seg)
+		}
+	}
+	// the playlist is a ring buffer, possibly with stale entries before its
+	// window
+	sort.Slice(segs, func(i, j int) bool { return segs[i].SeqId < segs[j].SeqId })
+	if n := int(pl.Count()); len(segs) > n {
+		segs = segs[len(segs)-n:]
+	}
+	return segs
+}
+
+// newDASHRepresentation lists the segments of the rendition, the first one
+// starting at the given presentation time in seconds
+func newDASHRepresentation(mid, rendition string, start float64, segs []*m3u8.MediaSegment) dashRepresentation {
+	list := dashSegmentList{
+		Timescale:      1000,
+		Initialization: dashURL{SourceURL: fmt.Sprintf("%s/%s/init.mp4", mid, rendition)},
+	}
+	// rounding the segment boundaries rather than the durations keeps the
+	// timeline from drifting
+	for _, seg := range segs {
+		t := int64(math.Round(start * 1000))
+		start += seg.Duration
+		d := int64(math.Round(start*1000)) - t
+		list.Timeline = append(list.Timeline, dashTimelineSegment{T: t, D: d})
+		list.SegmentURLs = append(list.SegmentURLs, dashSegmentURL{Media: fmt.Sprintf("%s/%s/%d.m4s", mid, rendition, seg.SeqId)})
+	}
+	return dashRepresentation{ID: rendition, SegmentList: list}
+}
+
+func dashDuration(secs float64) string {
+	return "PT" + strconv.FormatFloat(secs, 'f', 3, 64) + "S"
+}
+
+type dashMPD struct {
+	XMLName               xml.Name   `xml:"MPD"`
+	XMLNS                 string     `xml:"xmlns,attr"`
+	Profiles              string     `xml:"profiles,attr"`
+	Type                  string     `xml:"type,attr"`
+	AvailabilityStartTime string     `xml:"availabilityStartTime,attr"`
+	PublishTime           string     `xml:"publishTime,attr"`
+	MinimumUpdatePeriod   string     `xml:"minimumUpdatePeriod,attr"`
+	MinBufferTime         string     `xml:"minBufferTime,attr"`
+	TimeShiftBufferDepth  string     `xml:"timeShiftBufferDepth,attr"`
+	Period                dashPeriod `xml:"Period"`
+}
+
+type dashPeriod struct {
+	ID            string            `xml:"id,attr"`
+	Start         string            `xml:"start,attr"`
+	AdaptationSet dashAdaptationSet `xml:"AdaptationSet"`
+}
+
+type dashAdaptationSet struct {
+	MimeType         string               `xml:"mimeType,attr"`
+	SegmentAlignment bool                 `xml:"segmentAlignment,attr"`
+	StartWithSAP     int                  `xml:"startWithSAP,attr"`
+	Representations  []dashRepresentation `xml:"Representation"`
+}
+
+type dashRepresentation struct {
+	ID          string          `xml:"id,attr"`
+	Bandwidth   uint32          `xml:"bandwidth,attr"`
+	Width       int             `xml:"width,attr,omitempty"`
+	Height      int             `xml:"height,attr,omitempty"`
+	Codecs      string          `xml:"codecs,attr,omitempty"`
+	SegmentList dashSegmentList `xml:"SegmentList"`
+}
+
+type dashSegmentList struct {
+	Timescale      int                   `xml:"timescale,attr"`
+	Initialization dashURL               `xml:"Initialization"`
+	Timeline       []dashTimelineSegment `xml:"SegmentTimeline>S"`
+	SegmentURLs    []dashSegmentURL      `xml:"SegmentURL"`
+}
+
+type dashURL struct {
+	SourceURL string `xml:"sourceURL,attr"`
+}
+
+type dashTimelineSegment struct {
+	T int64 `xml:"t,attr"`
+	D int64 `xml:"d,attr"`
+}
+
+type dashSegmentURL struct {
+	Media string `xml:"media,attr"`
+}
+
+// mp4Box is an ISO BMFF box; data holds the whole box, header included
+type mp4Box struct {
+	typ  string
+	hdr  int
+	data []byte
+}
+
+func (b mp4Box) payload() []byte {
+	return b.data[b.hdr:]
+}
+
+// parseMP4Boxes splits the data into boxes, sharing the underlying array
+func parseMP4Boxes(data []byte) ([]mp4Box, error) {
+	var boxes []mp4Box
+	for len(data) > 0 {
+		if len(data) < 8 {
+			return nil, errors.New("truncated box header")
+		}
+		size, hdr := uint64(binary.BigEndian.Uint32(data)), 8
+		switch size {
+		case 0:
+			size = uint64(len(data))
+		case 1:
+			if len(data) < 16 {
+				return nil, errors.New("truncated box header")
+			}
+			size, hdr = binary.BigEndian.Uint64(data[8:]), 16
+		}
+		if size < uint64(hdr) || size > uint64(len(data)) {
+			return nil, fmt.Errorf("invalid box size=%d", size)
+		}
+		boxes = append(boxes, mp4Box{typ: string(data[4:8]), hdr: hdr, data: data[:size]})
+		data = data[size:]
+	}
+	return boxes, nil
+}
+
+// findMP4Boxes returns the boxes found at the path of box types in the data
+func findMP4Boxes(data []byte, types ...string) []mp4Box {
+	boxes, err := parseMP4Boxes(data)
+	if err != nil {
+		return nil
+	}
+	var found []mp4Box
+	for _, b := range boxes {
+		if b.typ != types[0] {
+			continue
+		}
+		if len(types) == 1 {
+			found = append(found, b)
+			continue
+		}
+		found = append(found, findMP4Boxes(b.payload(), types[1:]...)...)
+	}
+	return found
+}
+
+// splitFMP4 splits fragmented MP4 into the initialization segment, the boxes
+// before the first fragment, and the media segment, the fragments
+func splitFMP4(data []byte) ([]byte, []byte, error) {
+	boxes, err := parseMP4Boxes(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	var init, media []byte
+	for _, b := range boxes {
+		switch {
+		case b.typ == "moof" || b.typ == "mdat":
+			media = append(media, b.data...)
+		case media == nil:
+			init = append(init, b.data...)
+		}
+	}
+	if len(findMP4Boxes(init, "moov")) == 0 {
+		return nil, nil, errDASHNoInit
+	}
+	if media == nil {
+		return nil, nil, errors.New("no media fragments")
+	}
+	return init, media, nil
+}
+
+// retimeFMP4 shifts the decode times of the fragments so the media segment
+// starts at the given presentation time in seconds. The tracks keep their
+// offsets relative to each other.
+func retimeFMP4(init, media []byte, start float64) error {
+	timescales := map[uint32]uint32{}
+	for _, trak := range findMP4Boxes(init, "moov", "trak") {
+		tkhd := findMP4Boxes(trak.payload(), "tkhd")
+		mdhd := findMP4Boxes(trak.payload(), "mdia", "mdhd")
+		if len(tkhd) == 0 || len(mdhd) == 0 {
+			return errDASHNoInit
+		}
+		// track ID and timescale follow the creation and modification times
+		id, ok := fullBoxUint32(tkhd[0].payload(), 12, 20)
+		timescale, ok2 := fullBoxUint32(mdhd[0].payload(), 12, 20)
+		if !ok || !ok2 || timescale == 0 {
+			return errDASHNoInit
+		}
+		timescales[id] = timescale
+	}
+
+	type fragment struct {
+		track uint32
+		tfdt  []byte
+	}
+	var frags []fragment
+	first := map[uint32]uint64{}
+	for _, traf := range findMP4Boxes(media, "moof", "traf") {
+		tfhd := findMP4Boxes(traf.payload(), "tfhd")
+		tfdt := findMP4Boxes(traf.payload(), "tfdt")
+		if len(tfhd) == 0 || len(tfdt) == 0 || len(tfhd[0].payload()) < 8 {
+			return errors.New("fragment without decode time")
+		}
+		track := binary.BigEndian.Uint32(tfhd[0].payload()[4:])
+		t, ok := tfdtTime(tfdt[0].payload())
+		if _, known := timescales[track]; !ok || !known {
+			return fmt.Errorf("invalid fragment of track=%d", track)
+		}
+		if _, ok := first[track]; !ok {
+			first[track] = t
+		}
+		frags = append(frags, fragment{track: track, tfdt: tfdt[0].payload()})
+	}
+
+	// the earliest track starts at the given time
+	earliest := math.Inf(1)
+	for track, t := range first {
+		earliest = math.Min(earliest, float64(t)/float64(timescales[track]))
+	}
+	for _, f := range frags {
+		shift := int64(math.Round((start - earliest) * float64(timescales[f.track])))
+		if f.tfdt[0] == 1 {
+			t := binary.BigEndian.Uint64(f.tfdt[4:])
+			binary.BigEndian.PutUint64(f.tfdt[4:], uint64(int64(t)+shift))
+		} else {
+			t := binary.BigEndian.Uint32(f.tfdt[4:])
+			binary.BigEndian.PutUint32(f.tfdt[4:], uint32(int64(t)+shift))
+		}
+	}
+	return nil
+}
+
+// fullBoxUint32 reads the 32 bit field of the full box payload at the offset
+// for the box version
+func fullBoxUint32(payload []byte, offset0, offset1 int) (uint32, bool) {
+	offset := offset0
+	if len(payload) > 0 && payload[0] == 1 {
+		offset = offset1
+	}
+	if len(payload) < offset+4 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint32(payload[offset:]), true
+}
+
+// tfdtTime reads the base media decode time of the tfdt box payload, 64 bit
+// wide in version 1
+func tfdtTime(payload []byte) (uint64, bool) {
+	if len(payload) >= 12 && payload[0] == 1 {
+		return binary.BigEndian.Uint64(payload[4:]), true
+	}
+	if len(payload) >= 8 && payload[0] == 0 {
+		return uint64(binary.BigEndian.Uint32(payload[4:])), true
+	}
+	return 0, false
+}
+
+// fmp4Codecs returns the RFC 6381 codecs of the tracks of the initialization
+// segment
+func fmp4Codecs(init []byte) string {
+	var codecs []string
+	for _, stsd := range findMP4Boxes(init, "moov", "trak", "mdia", "minf", "stbl", "stsd") {
+		// entry count precedes the sample entries
+		if len(stsd.payload()) < 8 {
+			continue
+		}
+		entries, err := parseMP4Boxes(stsd.payload()[8:])
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			switch e.typ {
+			case "avc1", "avc3":
+				// the visual sample entry fields precede the child boxes
+				if len(e.payload()) < 78 {
+					continue
+				}
+				avcC := findMP4Boxes(e.payload()[78:], "avcC")
+				if len(avcC) == 0 || len(avcC[0].payload()) < 4 {
+					continue
+				}
+				p := avcC[0].payload()
+				codecs = append(codecs, fmt.Sprintf("%s.%02X%02X%02X", e.typ, p[1], p[2], p[3]))
+			case "mp4a":
+				// the transcoder encodes the audio as AAC-LC
+				codecs = append(codecs, "mp4a.40.2")
+			}
+		}
+	}
+	return strings.Join(codecs, ",")
+}
@@ -0,0 +1,232 @@
+package server
+
+import (
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/livepeer/go-livepeer/core"
+	ffmpeg "github.com/livepeer/lpms/ffmpeg"
+	"github.com/livepeer/lpms/stream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testMP4Box(typ string, payload ...[]byte) []byte {
+	size := 8
+	for _, p := range payload {
+		size += len(p)
+	}
+	b := make([]byte, 8, size)
+	binary.BigEndian.PutUint32(b, uint32(size))
+	copy(b[4:], typ)
+	for _, p := range payload {
+		b = append(b, p...)
+	}
+	return b
+}
+
+func testUint32s(vals ...uint32) []byte {
+	b := make([]byte, 4*len(vals))
+	for i, v := range vals {
+		binary.BigEndian.PutUint32(b[4*i:], v)
+	}
+	return b
+}
+
+// testTrak returns trak box of the track with the sample entry
+func testTrak(id, timescale uint32, entry []byte) []byte {
+	tkhd := testMP4Box("tkhd", testUint32s(0, 0, 0, id), make([]byte, 68))
+	mdhd := testMP4Box("mdhd", testUint32s(0, 0, 0, timescale, 0, 0))
+	stsd := testMP4Box("stsd", testUint32s(0, 1), entry)
+	return testMP4Box("trak", tkhd, testMP4Box("mdia", mdhd, testMP4Box("minf", testMP4Box("stbl", stsd))))
+}
+
+// testFMP4 returns fragmented MP4 with H.264 video track 1 at 90kHz and AAC
+// audio track 2 at 48kHz, as muxed by ffmpeg, the decode times of the video
+// fragments and the audio fragment given
+func testFMP4(video1, video2 uint64, audio uint32) []byte {
+	avc1 := testMP4Box("avc1", make([]byte, 78), testMP4Box("avcC", []byte{1, 0x64, 0x00, 0x1f, 0xff}))
+	mp4a := testMP4Box("mp4a", make([]byte, 28))
+	moov := testMP4Box("moov", testMP4Box("mvhd", make([]byte, 100)), testTrak(1, 90000, avc1), testTrak(2, 48000, mp4a))
+
+	tfdt64 := func(t uint64) []byte {
+		b := make([]byte, 12)
+		b[0] = 1
+		binary.BigEndian.PutUint64(b[4:], t)
+		return testMP4Box("tfdt", b)
+	}
+	traf := func(track uint32, tfdt []byte) []byte {
+		return testMP4Box("traf", testMP4Box("tfhd", testUint32s(0, track)), tfdt)
+	}
+	moof1 := testMP4Box("moof", testMP4Box("mfhd", testUint32s(0, 1)),
+		traf(1, tfdt64(video1)), traf(2, testMP4Box("tfdt", testUint32s(0, audio))))
+	moof2 := testMP4Box("moof", testMP4Box("mfhd", testUint32s(0, 2)), traf(1, tfdt64(video2)))
+
+	var data []byte
+	for _, b := range [][]byte{
+		testMP4Box("ftyp", []byte("isom")),
+		moov,
+		moof1,
+		testMP4Box("mdat", []byte{1, 2, 3}),
+		moof2,
+		testMP4Box("mdat", []byte{4, 5}),
+		testMP4Box("mfra", make([]byte, 8)),
+	} {
+		data = append(data, b...)
+	}
+	return data
+}
+
+func testTfdts(t *testing.T, media []byte) []uint64 {
+	var times []uint64
+	for _, tfdt := range findMP4Boxes(media, "moof", "traf", "tfdt") {
+		tm, ok := tfdtTime(tfdt.payload())
+		require.True(t, ok)
+		times = append(times, tm)
+	}
+	return times
+}
+
+func TestDASH_FMP4(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// audio starts 10ms after the video
+	init, media, err := splitFMP4(testFMP4(900000, 990000, 480480))
+	require.Nil(err)
+	assert.Len(findMP4Boxes(init, "ftyp"), 1)
+	assert.Len(findMP4Boxes(init, "moov"), 1)
+	assert.Len(findMP4Boxes(media, "moof"), 2)
+	assert.Len(findMP4Boxes(media, "mdat"), 2)
+	assert.Empty(findMP4Boxes(media, "mfra"))
+	assert.Equal("avc1.64001F,mp4a.40.2", fmp4Codecs(init))
+
+	// retimed to start at 3s, the tracks keep their offsets
+	require.Nil(retimeFMP4(init, media, 3))
+	assert.Equal([]uint64{270000, 144480, 360000}, testTfdts(t, media))
+	require.Nil(retimeFMP4(init, media, 3))
+	assert.Equal([]uint64{270000, 144480, 360000}, testTfdts(t, media))
+
+	// fragments of unknown tracks
+	_, media, err = splitFMP4(testFMP4(0, 0, 0))
+	require.Nil(err)
+	assert.NotNil(retimeFMP4(testMP4Box("moov"), media, 3))
+
+	// not fragmented
+	_, _, err = splitFMP4(testMP4Box("moov"))
+	assert.EqualError(err, "no media fragments")
+	_, _, err = splitFMP4(testMP4Box("moof"))
+	assert.Equal(errDASHNoInit, err)
+	_, _, err = splitFMP4([]byte{0, 0, 0, 9, 'm', 'o', 'o', 'v'})
+	assert.EqualError(err, "invalid box size=9")
+}
+
+func TestDASH_Handler(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	s := setupServer()
+	defer serverCleanup(s)
+
+	defer func(muxer func([]byte) ([]byte, error)) { dashMuxer = muxer }(dashMuxer)
+	var remuxed [][]byte
+	dashMuxer = func(data []byte) ([]byte, error) {
+		remuxed = append(remuxed, data)
+		if string(data) == "bad" {
+			return nil, errors.New("remux error")
+		}
+		return testFMP4(0, 180000, 0), nil
+	}
+
+	mid := core.SplitStreamIDString(t.Name()).ManifestID
+	strm := stream.NewBasicRTMPVideoStream(newStreamParams(mid, "source"))
+	cxn, err := s.registerConnection(strm)
+	require.Nil(err)
+	defer removeRTMPStream(s, mid)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.HandleDASH(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+	manifest := func() *dashMPD {
+		w := get(fmt.Sprintf("/dash/%s.mpd", mid))
+		require.Equal(http.StatusOK, w.Code)
+		assert.Equal("application/dash+xml", w.Header().Get("Content-Type"))
+		var mpd dashMPD
+		require.Nil(xml.Unmarshal(w.Body.Bytes(), &mpd))
+		return &mpd
+	}
+
+	// no segments yet
+	assert.Equal(http.StatusNotFound, get(fmt.Sprintf("/dash/%s.mpd", mid)).Code)
+
+	// the last segment slides the first one out of the HLS playlist
+	vProfile := ffmpeg.P144p30fps16x9
+	for i := uint64(1); i <= uint64(core.LIVE_LIST_LENGTH)+1; i++ {
+		name := fmt.Sprintf("%s/%d.ts", vProfile.Name, i)
+		uri, err := cxn.pl.GetOSSession().SaveData(name, []byte(name), nil)
+		require.Nil(err)
+		require.Nil(cxn.pl.InsertHLSSegment(&vProfile, i, uri, 2, 0))
+	}
+
+	mpd := manifest()
+	assert.Equal("dynamic", mpd.Type)
+	assert.Equal("PT12.000S", mpd.TimeShiftBufferDepth)
+	require.Len(mpd.Period.AdaptationSet.Representations, 1)
+	rep := mpd.Period.AdaptationSet.Representations[0]
+	assert.Equal(vProfile.Name, rep.ID)
+	assert.Equal(256, rep.Width)
+	assert.Equal(144, rep.Height)
+	assert.Equal("avc1.64001F,mp4a.40.2", rep.Codecs)
+	assert.Equal(fmt.Sprintf("%s/%s/init.mp4", mid, vProfile.Name), rep.SegmentList.Initialization.SourceURL)
+	require.Len(rep.SegmentList.Timeline, int(core.LIVE_LIST_LENGTH))
+	require.Len(rep.SegmentList.SegmentURLs, int(core.LIVE_LIST_LENGTH))
+	// the timeline starts where the HLS playlist does
+	assert.Equal(dashTimelineSegment{T: 2000, D: 2000}, rep.SegmentList.Timeline[0])
+	assert.Equal(dashTimelineSegment{T: 12000, D: 2000}, rep.SegmentList.Timeline[5])
+	assert.Equal(fmt.Sprintf("%s/%s/2.m4s", mid, vProfile.Name), rep.SegmentList.SegmentURLs[0].Media)
+	// the initialization segment comes from the last segment
+	require.Len(remuxed, 1)
+	assert.Equal(vProfile.Name+"/7.ts", string(remuxed[0]))
+
+	// the availability start stays and the initialization segment is cached
+	assert.Equal(mpd.AvailabilityStartTime, manifest().AvailabilityStartTime)
+	assert.Len(remuxed, 1)
+	w := get(fmt.Sprintf("/dash/%s/%s/init.mp4", mid, vProfile.Name))
+	assert.Equal(http.StatusOK, w.Code)
+	init, _, err := splitFMP4(testFMP4(0, 0, 0))
+	require.Nil(err)
+	assert.Equal(init, w.Body.Bytes())
+	assert.Len(remuxed, 1)
+
+	// media segments are retimed to their start in the playlist
+	w = get(fmt.Sprintf("/dash/%s/%s/3.m4s", mid, vProfile.Name))
+	require.Equal(http.StatusOK, w.Code)
+	assert.Equal("video/mp4", w.Header().Get("Content-Type"))
+	assert.Equal(vProfile.Name+"/3.ts", string(remuxed[1]))
+	assert.Equal([]uint64{4 * 90000, 4 * 48000, 6 * 90000}, testTfdts(t, w.Body.Bytes()))
+
+	// segments out of the playlist, unknown renditions and streams
+	for _, path := range []string{
+		fmt.Sprintf("/dash/%s/%s/1.m4s", mid, vProfile.Name),
+		fmt.Sprintf("/dash/%s/%s/8.m4s", mid, vProfile.Name),
+		fmt.Sprintf("/dash/%s/%s/3.ts", mid, vProfile.Name),
+		fmt.Sprintf("/dash/%s/source/3.m4s", mid),
+		fmt.Sprintf("/dash/%s/%s", mid, vProfile.Name),
+		"/dash/unknown.mpd",
+		fmt.Sprintf("/dash/unknown/%s/3.m4s", vProfile.Name),
+	} {
+		assert.Equal(http.StatusNotFound, get(path).Code, path)
+	}
+
+	// remux errors
+	uri, err := cxn.pl.GetOSSession().SaveData(vProfile.Name+"/8.ts", []byte("bad"), nil)
+	require.Nil(err)
+	require.Nil(cxn.pl.InsertHLSSegment(&vProfile, 8, uri, 2, 0))
+	assert.Equal(http.StatusNotFound, get(fmt.Sprintf("/dash/%s/%s/8.m4s", mid, vProfile.Name)).Code)
+}
@@ -5,12 +5,16 @@ import (
 	"fmt"
 	"math/big"
 	"net/http"
+	"strconv"
+	"time"
 
 	ethcommon "github.com/ethereum/go-ethereum/common"
 	"github.com/golang/glog"
 	"github.com/livepeer/go-livepeer/common"
+	"github.com/livepeer/go-livepeer/core"
 	"github.com/livepeer/go-livepeer/eth"
 	"github.com/livepeer/go-livepeer/eth/types"
+	"github.com/livepeer/go-livepeer/monitor"
 	"github.com/livepeer/go-livepeer/pm"
 )
 
@@ -154,6 +158,124 @@ func fundDepositHandler(client eth.LivepeerEthClient) http.Handler {
 	})
 }
 
+// resetMetricsHandler zeroes the cumulative metrics between load test runs
+func resetMetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			respondWithError(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		monitor.ResetCounters()
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("metrics reset"))
+	})
+}
+
+// setDrainModeHandler toggles orchestrator drain mode. While draining, new
+// sessions are rejected but existing sessions keep being transcoded
+func setDrainModeHandler(node *core.LivepeerNode) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		drain, err := strconv.ParseBool(r.FormValue("drain"))
+		if err != nil {
+			respondWith400(w, fmt.Sprintf("invalid drain: %v", err))
+			return
+		}
+
+		node.SetDrainMode(drain)
+		glog.Infof("Set drain mode to %v", drain)
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(fmt.Sprintf("drain mode %v", drain)))
+	})
+}
+
+// blacklistOrchestratorHandler excludes the orchestrator from the selection
+// for the ttl. Blacklisting of the orchestrator already blacklisted replaces
+// the previous one.
+func blacklistOrchestratorHandler(store common.OrchestratorStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if store == nil {
+			respondWith500(w, "missing orchestrator store")
+			return
+		}
+
+		addr := r.FormValue("address")
+		if !ethcommon.IsHexAddress(addr) {
+			respondWith400(w, fmt.Sprintf("invalid address: %v", addr))
+			return
+		}
+		ttl, err := time.ParseDuration(r.FormValue("ttl"))
+		if err != nil || ttl <= 0 {
+			respondWith400(w, fmt.Sprintf("invalid ttl: %v", r.FormValue("ttl")))
+			return
+		}
+
+		addr = ethcommon.HexToAddress(addr).Hex()
+		if err := store.BlacklistOrch(addr, ttl, r.FormValue("reason")); err != nil {
+			respondWith500(w, fmt.Sprintf("could not blacklist orchestrator: %v", err))
+			return
+		}
+		glog.Infof("Blacklisted orchestrator=%s ttl=%v reason=%q", addr, ttl, r.FormValue("reason"))
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(fmt.Sprintf("blacklisted %v for %v", addr, ttl)))
+	})
+}
+
+// unblacklistOrchestratorHandler removes the orchestrator from the blacklist
+func unblacklistOrchestratorHandler(store common.OrchestratorStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if store == nil {
+			respondWith500(w, "missing orchestrator store")
+			return
+		}
+
+		addr := r.FormValue("address")
+		if !ethcommon.IsHexAddress(addr) {
+			respondWith400(w, fmt.Sprintf("invalid address: %v", addr))
+			return
+		}
+
+		addr = ethcommon.HexToAddress(addr).Hex()
+		if err := store.UnblacklistOrch(addr); err != nil {
+			respondWith500(w, fmt.Sprintf("could not unblacklist orchestrator: %v", err))
+			return
+		}
+		glog.Infof("Unblacklisted orchestrator=%s", addr)
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(fmt.Sprintf("unblacklisted %v", addr)))
+	})
+}
+
+// orchestratorBlacklistHandler lists the orchestrators blacklisted until later
+func orchestratorBlacklistHandler(store common.OrchestratorStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if store == nil {
+			respondWith500(w, "missing orchestrator store")
+			return
+		}
+
+		orchs, err := store.BlacklistedOrchs()
+		if err != nil {
+			respondWith500(w, fmt.Sprintf("could not query orchestrator blacklist: %v", err))
+			return
+		}
+
+		data, err := json.Marshal(orchs)
+		if err != nil {
+			respondWith500(w, fmt.Sprintf("could not parse orchestrator blacklist: %v", err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(data)
+	})
+}
+
 func unlockHandler(client eth.LivepeerEthClient) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if client == nil {
@@ -15,6 +15,8 @@ import (
 
 	"github.com/ethereum/go-ethereum/accounts"
 	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/livepeer/go-livepeer/common"
+	"github.com/livepeer/go-livepeer/core"
 	"github.com/livepeer/go-livepeer/eth"
 	"github.com/livepeer/go-livepeer/pm"
 	"github.com/stretchr/testify/assert"
@@ -146,6 +148,105 @@ func TestCurrentBlockHandler_Success(t *testing.T) {
 	assert.Equal(http.StatusOK, resp.StatusCode)
 	assert.Equal(big.NewInt(50), new(big.Int).SetBytes(body))
 }
+
+func TestResetMetricsHandler(t *testing.T) {
+	assert := assert.New(t)
+	handler := resetMetricsHandler()
+
+	resp := httpGetResp(handler)
+	body, _ := ioutil.ReadAll(resp.Body)
+	assert.Equal(http.StatusMethodNotAllowed, resp.StatusCode)
+	assert.Equal("method not allowed", strings.TrimSpace(string(body)))
+
+	resp = httpPostResp(handler, nil, nil)
+	body, _ = ioutil.ReadAll(resp.Body)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal("metrics reset", string(body))
+}
+
+func TestSetDrainModeHandler(t *testing.T) {
+	assert := assert.New(t)
+	n, _ := core.NewLivepeerNode(nil, "", nil)
+	handler := setDrainModeHandler(n)
+
+	form := url.Values{"drain": {"foo"}}
+	resp := httpPostFormResp(handler, strings.NewReader(form.Encode()))
+	body, _ := ioutil.ReadAll(resp.Body)
+	assert.Equal(http.StatusBadRequest, resp.StatusCode)
+	assert.Contains(string(body), "invalid drain")
+	assert.False(n.Draining())
+
+	form = url.Values{"drain": {"true"}}
+	resp = httpPostFormResp(handler, strings.NewReader(form.Encode()))
+	body, _ = ioutil.ReadAll(resp.Body)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal("drain mode true", string(body))
+	assert.True(n.Draining())
+
+	form = url.Values{"drain": {"false"}}
+	resp = httpPostFormResp(handler, strings.NewReader(form.Encode()))
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.False(n.Draining())
+}
+
+func TestOrchestratorBlacklistHandlers(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	dbh, dbraw, err := common.TempDB(t)
+	require.Nil(err)
+	defer dbh.Close()
+	defer dbraw.Close()
+	blacklist := blacklistOrchestratorHandler(dbh)
+	unblacklist := unblacklistOrchestratorHandler(dbh)
+	list := orchestratorBlacklistHandler(dbh)
+	addr := pm.RandAddress()
+
+	// missing store
+	resp := httpPostFormResp(blacklistOrchestratorHandler(nil), nil)
+	assert.Equal(http.StatusInternalServerError, resp.StatusCode)
+	resp = httpGetResp(orchestratorBlacklistHandler(nil))
+	assert.Equal(http.StatusInternalServerError, resp.StatusCode)
+
+	// invalid params
+	form := url.Values{"address": {"foo"}, "ttl": {"1h"}}
+	resp = httpPostFormResp(blacklist, strings.NewReader(form.Encode()))
+	body, _ := ioutil.ReadAll(resp.Body)
+	assert.Equal(http.StatusBadRequest, resp.StatusCode)
+	assert.Equal("invalid address: foo", strings.TrimSpace(string(body)))
+	form = url.Values{"address": {addr.Hex()}, "ttl": {"-1h"}}
+	resp = httpPostFormResp(blacklist, strings.NewReader(form.Encode()))
+	body, _ = ioutil.ReadAll(resp.Body)
+	assert.Equal(http.StatusBadRequest, resp.StatusCode)
+	assert.Equal("invalid ttl: -1h", strings.TrimSpace(string(body)))
+
+	// blacklisted with the address normalized
+	form = url.Values{"address": {strings.ToLower(addr.Hex())}, "ttl": {"1h"}, "reason": {"bad transcodes"}}
+	resp = httpPostFormResp(blacklist, strings.NewReader(form.Encode()))
+	body, _ = ioutil.ReadAll(resp.Body)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal(fmt.Sprintf("blacklisted %v for 1h0m0s", addr.Hex()), string(body))
+
+	resp = httpGetResp(list)
+	body, _ = ioutil.ReadAll(resp.Body)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	var orchs []*common.DBBlacklistedOrch
+	require.Nil(json.Unmarshal(body, &orchs))
+	require.Len(orchs, 1)
+	assert.Equal(addr.Hex(), orchs[0].EthereumAddr)
+	assert.Equal("bad transcodes", orchs[0].Reason)
+
+	// unblacklisted
+	form = url.Values{"address": {addr.Hex()}}
+	resp = httpPostFormResp(unblacklist, strings.NewReader(form.Encode()))
+	body, _ = ioutil.ReadAll(resp.Body)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal(fmt.Sprintf("unblacklisted %v", addr.Hex()), string(body))
+
+	resp = httpGetResp(list)
+	body, _ = ioutil.ReadAll(resp.Body)
+	assert.Equal("[]", string(body))
+}
+
 func TestCurrentRoundHandler(t *testing.T) {
 	assert := assert.New(t)
 
@@ -22,6 +22,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/livepeer/go-livepeer/drivers"
@@ -46,6 +47,7 @@ var errLowDeposit = errors.New("ErrLowDeposit")
 var errStorage = errors.New("ErrStorage")
 var errDiscovery = errors.New("ErrDiscovery")
 var errNoOrchs = errors.New("ErrNoOrchs")
+var errMaxOrchs = errors.New("Hit max orchestrators per segment")
 var errUnknownStream = errors.New("ErrUnknownStream")
 var errMismatchedParams = errors.New("Mismatched type for stream params")
 
@@ -61,6 +63,11 @@ var BroadcastJobVideoProfiles = []ffmpeg.VideoProfile{ffmpeg.P240p30fps4x3, ffmp
 
 var AuthWebhookURL string
 
+// StreamKeyRotation allows publishing the active stream again under a new
+// stream key accepted by the auth webhook. The stream continues from the new
+// publisher and the previous one is disconnected. Requires AuthWebhookURL.
+var StreamKeyRotation bool
+
 // For HTTP push watchdog
 var httpPushTimeout = 1 * time.Minute
 var httpPushResetTimer = func() (context.Context, context.CancelFunc) {
@@ -69,14 +76,32 @@ var httpPushResetTimer = func() (context.Context, context.CancelFunc) {
 }
 
 type rtmpConnection struct {
-	mid         core.ManifestID
-	nonce       uint64
-	stream      stream.RTMPVideoStream
+	mid   core.ManifestID
+	nonce uint64
+
+	// current publisher of the stream and its stream key, replaced by the
+	// stream key rotation
+	pubLock sync.RWMutex
+	stream  stream.RTMPVideoStream
+	rtmpKey string
+
 	pl          core.PlaylistManager
 	profile     *ffmpeg.VideoProfile
 	params      *core.StreamParameters
 	sessManager *BroadcastSessionsManager
 	lastUsed    time.Time
+
+	// number of segments currently being processed, accessed atomically
+	queuedSegments int64
+
+	uploads *uploadOrder
+	segMem  *segmenterMemory
+	warmup  *streamWarmup
+
+	// seqNo following the last segment of the publisher, accessed atomically
+	nextSeqNo uint64
+
+	dash dashState
 }
 
 type LivepeerServer struct {
@@ -107,7 +132,12 @@ type authWebhookResponse struct {
 		FPSDen  uint   `json:"fpsDen"`
 		Profile string `json:"profile"`
 		GOP     string `json:"gop"`
+		GOPSize int    `json:"gopSize"`
 	} `json:"profiles"`
+	// Overrides -inOrderUploads for the stream if set
+	InOrderUploads *bool `json:"inOrderUploads"`
+	// Tenant the stream is counted against for the per-tenant limits
+	Tenant string `json:"tenant"`
 }
 
 func NewLivepeerServer(rtmpAddr string, lpNode *core.LivepeerNode, httpIngest bool, transcodingOptions string) (*LivepeerServer, error) {
@@ -164,6 +194,7 @@ func (s *LivepeerServer) StartMediaServer(ctx context.Context, httpAddr string)
 
 	//LPMS hanlder for handling HLS video play
 	s.LPMS.HandleHLSPlay(getHLSMasterPlaylistHandler(s), getHLSMediaPlaylistHandler(s), getHLSSegmentHandler(s))
+	s.HTTPMux.HandleFunc("/dash/", s.HandleDASH)
 
 	//Start the LPMS server
 	lpmsCtx, cancel := context.WithCancel(ctx)
@@ -179,7 +210,7 @@ func (s *LivepeerServer) StartMediaServer(ctx context.Context, httpAddr string)
 	if s.LivepeerNode.NodeType == core.BroadcasterNode {
 		go func() {
 			glog.V(4).Infof("HTTP Server listening on http://%v", httpAddr)
-			ec <- http.ListenAndServe(httpAddr, s.HTTPMux)
+			ec <- http.ListenAndServe(httpAddr, cacheControlHandler(s.HTTPMux))
 		}()
 	}
 
@@ -205,14 +236,18 @@ func createRTMPStreamIDHandler(s *LivepeerServer) func(url *url.URL) (strmID str
 		var resp *authWebhookResponse
 		var mid core.ManifestID
 		var err error
-		var key string
+		var key, tenant string
 		profiles := []ffmpeg.VideoProfile{}
+		inOrderUploads := InOrderUploads
 		if resp, err = authenticateStream(url.String()); err != nil {
 			glog.Error("Authentication denied for ", err)
 			return nil
 		}
 		if resp != nil {
-			mid, key = parseManifestID(resp.ManifestID), resp.StreamKey
+			mid, key, tenant = parseManifestID(resp.ManifestID), resp.StreamKey, resp.Tenant
+			if resp.InOrderUploads != nil {
+				inOrderUploads = *resp.InOrderUploads
+			}
 			// Process transcoding options presets
 			if len(resp.Presets) > 0 {
 				profiles = parsePresets(resp.Presets)
@@ -243,11 +278,14 @@ func createRTMPStreamIDHandler(s *LivepeerServer) func(url *url.URL) (strmID str
 		// Ensure there's no concurrent StreamID with the same name
 		s.connectionLock.RLock()
 		defer s.connectionLock.RUnlock()
-		if core.MaxSessions > 0 && len(s.rtmpConnections) >= core.MaxSessions {
+		cxn, exists := s.rtmpConnections[mid]
+		// rotated stream keeps its session, so isn't a new connection
+		rotation := exists && canRotateKey(resp, cxn, key)
+		if !rotation && core.MaxSessions > 0 && len(s.rtmpConnections) >= core.MaxSessions {
 			glog.Error("Too many connections")
 			return nil
 		}
-		if _, exists := s.rtmpConnections[mid]; exists {
+		if exists && !rotation {
 			glog.Error("Manifest already exists ", mid)
 			return nil
 		}
@@ -260,11 +298,23 @@ func createRTMPStreamIDHandler(s *LivepeerServer) func(url *url.URL) (strmID str
 			ManifestID: mid,
 			RtmpKey:    key,
 			// HTTP push mutates `profiles` so make a copy of it
-			Profiles: append([]ffmpeg.VideoProfile(nil), profiles...),
+			Profiles:       append([]ffmpeg.VideoProfile(nil), profiles...),
+			InOrderUploads: inOrderUploads,
+			Tenant:         streamTenant(tenant, mid),
 		}
 	}
 }
 
+// canRotateKey checks if the publish authenticated by the auth webhook can take
+// over the active stream under a new stream key
+func canRotateKey(resp *authWebhookResponse, cxn *rtmpConnection, key string) bool {
+	if !StreamKeyRotation || resp == nil || key == "" {
+		return false
+	}
+	_, current := cxn.publisher()
+	return key != current
+}
+
 func authenticateStream(url string) (*authWebhookResponse, error) {
 	if AuthWebhookURL == "" {
 		return nil, nil
@@ -329,6 +379,23 @@ func jsonProfileToVideoProfile(resp *authWebhookResponse) ([]ffmpeg.VideoProfile
 				gop = time.Duration(gopFloat * float64(time.Second))
 			}
 		}
+		if profile.GOPSize != 0 {
+			// GOP size in frames is converted to the keyframe interval
+			if profile.GOP != "" {
+				return nil, errors.New("only one of gop or gopSize can be set")
+			}
+			if profile.GOPSize < 0 {
+				return nil, errors.New("invalid gopSize value")
+			}
+			if profile.FPS == 0 {
+				return nil, errors.New("gopSize requires fps")
+			}
+			gop = framesDuration(profile.GOPSize, profile.FPS, profile.FPSDen)
+		}
+		if gop > 0 && profile.FPS > 0 && gop < framesDuration(1, profile.FPS, profile.FPSDen) {
+			// keyframe interval can't be shorter than a single frame
+			return nil, errors.New("gop is shorter than frame interval")
+		}
 		encodingProfile, err := common.EncoderProfileNameToValue(profile.Profile)
 		if err != nil {
 			return nil, err
@@ -347,6 +414,14 @@ func jsonProfileToVideoProfile(resp *authWebhookResponse) ([]ffmpeg.VideoProfile
 	return profiles, nil
 }
 
+// framesDuration returns duration of the number of frames at the given framerate
+func framesDuration(frames int, fps, fpsDen uint) time.Duration {
+	if fpsDen == 0 {
+		fpsDen = 1
+	}
+	return time.Duration(frames) * time.Duration(fpsDen) * time.Second / time.Duration(fps)
+}
+
 func streamParams(rtmpStrm stream.RTMPVideoStream) *core.StreamParameters {
 	d := rtmpStrm.AppData()
 	p, ok := d.(*core.StreamParameters)
@@ -360,18 +435,30 @@ func streamParams(rtmpStrm stream.RTMPVideoStream) *core.StreamParameters {
 func gotRTMPStreamHandler(s *LivepeerServer) func(url *url.URL, rtmpStrm stream.RTMPVideoStream) (err error) {
 	return func(url *url.URL, rtmpStrm stream.RTMPVideoStream) (err error) {
 
-		cxn, err := s.registerConnection(rtmpStrm)
-		if err != nil {
-			return err
+		cxn, rotated := s.rotateStreamKey(rtmpStrm)
+		if !rotated {
+			cxn, err = s.registerConnection(rtmpStrm)
+			if err != nil {
+				return err
+			}
 		}
 
 		mid := cxn.mid
 		nonce := cxn.nonce
-		startSeq := 0
+		// segments of the new publisher follow the previous publisher's ones
+		startSeq := int(atomic.LoadUint64(&cxn.nextSeqNo))
 
-		streamStarted := false
+		streamStarted := rotated
 		//Segment the stream, insert the segments into the broadcaster
 		go func(rtmpStrm stream.RTMPVideoStream) {
+			segmenterStarted()
+			defer segmenterEnded()
+			defer func() {
+				// segmenter of the new publisher is still running
+				if s.isPublisher(cxn, rtmpStrm) {
+					cxn.segMem.end()
+				}
+			}()
 			hid := string(core.RandomManifestID()) // ffmpeg m3u8 output name
 			hlsStrm := stream.NewBasicHLSVideoStream(hid, stream.DefaultHLSStreamWin)
 			hlsStrm.SetSubscriber(func(seg *stream.HLSSegment, eof bool) {
@@ -379,12 +466,20 @@ func gotRTMPStreamHandler(s *LivepeerServer) func(url *url.URL, rtmpStrm stream.
 					// XXX update HLS manifest
 					return
 				}
+				if !s.isPublisher(cxn, rtmpStrm) {
+					// publisher was replaced by the stream key rotation
+					return
+				}
 				if streamStarted == false {
 					streamStarted = true
-					if monitor.Enabled {
+					// stream with the warmup is started once the warmup completes
+					if cxn.warmup == nil && monitor.Enabled {
 						monitor.StreamStarted(nonce)
 					}
 				}
+				atomic.StoreUint64(&cxn.nextSeqNo, seg.SeqNo+1)
+				cxn.uploads.queue(seg.SeqNo)
+				cxn.segMem.add(seg)
 				go processSegment(cxn, seg)
 			})
 
@@ -401,6 +496,10 @@ func gotRTMPStreamHandler(s *LivepeerServer) func(url *url.URL, rtmpStrm stream.
 
 		}(rtmpStrm)
 
+		if rotated {
+			return nil
+		}
+
 		if monitor.Enabled {
 			monitor.StreamCreated(string(mid), nonce)
 		}
@@ -411,6 +510,23 @@ func gotRTMPStreamHandler(s *LivepeerServer) func(url *url.URL, rtmpStrm stream.
 	}
 }
 
+// number of running segmenter goroutines; should return to zero once all streams end
+var activeSegmenters int64
+
+func segmenterStarted() {
+	active := atomic.AddInt64(&activeSegmenters, 1)
+	if monitor.Enabled {
+		monitor.ActiveSegmenterGoroutines(active)
+	}
+}
+
+func segmenterEnded() {
+	active := atomic.AddInt64(&activeSegmenters, -1)
+	if monitor.Enabled {
+		monitor.ActiveSegmenterGoroutines(active)
+	}
+}
+
 func endRTMPStreamHandler(s *LivepeerServer) func(url *url.URL, rtmpStrm stream.RTMPVideoStream) error {
 	return func(url *url.URL, rtmpStrm stream.RTMPVideoStream) error {
 		params := streamParams(rtmpStrm)
@@ -418,6 +534,14 @@ func endRTMPStreamHandler(s *LivepeerServer) func(url *url.URL, rtmpStrm stream.
 			return errMismatchedParams
 		}
 
+		s.connectionLock.RLock()
+		cxn, ok := s.rtmpConnections[params.ManifestID]
+		s.connectionLock.RUnlock()
+		if ok && !s.isPublisher(cxn, rtmpStrm) {
+			// stream continues under the new stream key
+			return nil
+		}
+
 		//Remove RTMP stream
 		err := removeRTMPStream(s, params.ManifestID)
 		if err != nil {
@@ -427,6 +551,62 @@ func endRTMPStreamHandler(s *LivepeerServer) func(url *url.URL, rtmpStrm stream.
 	}
 }
 
+// rotateStreamKey moves the active stream of the manifest ID to the publisher
+// of the new stream key and disconnects the previous publisher. Returns false
+// if there is no active stream to rotate.
+func (s *LivepeerServer) rotateStreamKey(rtmpStrm stream.RTMPVideoStream) (*rtmpConnection, bool) {
+	if !StreamKeyRotation || AuthWebhookURL == "" {
+		return nil, false
+	}
+	params := streamParams(rtmpStrm)
+	if params == nil {
+		return nil, false
+	}
+	s.connectionLock.Lock()
+	cxn, ok := s.rtmpConnections[params.ManifestID]
+	if !ok {
+		s.connectionLock.Unlock()
+		return nil, false
+	}
+	if _, key := cxn.publisher(); key == params.RtmpKey {
+		s.connectionLock.Unlock()
+		return nil, false
+	}
+	prev := cxn.setPublisher(rtmpStrm, params.RtmpKey)
+	s.connectionLock.Unlock()
+
+	// ends the segmenter of the previous publisher
+	prev.Close()
+	glog.Infof("Rotated stream key manifestID=%s nonce=%d", cxn.mid, cxn.nonce)
+	if monitor.Enabled {
+		monitor.StreamKeyRotated(cxn.nonce)
+	}
+	return cxn, true
+}
+
+// isPublisher checks if the RTMP stream is the current publisher of the
+// connection
+func (s *LivepeerServer) isPublisher(cxn *rtmpConnection, rtmpStrm stream.RTMPVideoStream) bool {
+	strm, _ := cxn.publisher()
+	return strm == rtmpStrm
+}
+
+// publisher returns the current publisher of the stream and its stream key
+func (cxn *rtmpConnection) publisher() (stream.RTMPVideoStream, string) {
+	cxn.pubLock.RLock()
+	defer cxn.pubLock.RUnlock()
+	return cxn.stream, cxn.rtmpKey
+}
+
+// setPublisher replaces the publisher of the stream, returns the previous one
+func (cxn *rtmpConnection) setPublisher(rtmpStrm stream.RTMPVideoStream, rtmpKey string) stream.RTMPVideoStream {
+	cxn.pubLock.Lock()
+	defer cxn.pubLock.Unlock()
+	prev := cxn.stream
+	cxn.stream, cxn.rtmpKey = rtmpStrm, rtmpKey
+	return prev
+}
+
 func (s *LivepeerServer) registerConnection(rtmpStrm stream.RTMPVideoStream) (*rtmpConnection, error) {
 	nonce := rand.Uint64()
 
@@ -471,21 +651,32 @@ func (s *LivepeerServer) registerConnection(rtmpStrm stream.RTMPVideoStream) (*r
 		// We can only have one concurrent stream per ManifestID
 		return nil, errAlreadyExists
 	}
+	if err := TenantLimits.acquire(params.Tenant); err != nil {
+		return nil, err
+	}
 
 	playlist := core.NewBasicPlaylistManager(mid, storage)
 	var stakeRdr stakeReader
 	if s.LivepeerNode.Eth != nil {
 		stakeRdr = &storeStakeReader{store: s.LivepeerNode.Database}
 	}
+	var sel BroadcastSessionsSelector = NewMinLSSelector(stakeRdr, 1.0)
+	if BroadcastCfg.FallbackChainSelection() {
+		sel = NewFallbackChainSelector()
+	}
 	cxn := &rtmpConnection{
 		mid:         mid,
 		nonce:       nonce,
 		stream:      rtmpStrm,
+		rtmpKey:     params.RtmpKey,
 		pl:          playlist,
 		profile:     &vProfile,
 		params:      params,
-		sessManager: NewSessionManager(s.LivepeerNode, params, NewMinLSSelector(stakeRdr, 1.0)),
+		sessManager: NewSessionManager(s.LivepeerNode, params, sel),
 		lastUsed:    time.Now(),
+		uploads:     newUploadOrder(nonce, params.InOrderUploads),
+		segMem:      newSegmenterMemory(nonce, stream.DefaultHLSStreamWin),
+		warmup:      newStreamWarmup(nonce, WarmupSegments, WarmupTimeout),
 	}
 
 	s.connectionLock.Lock()
@@ -495,6 +686,8 @@ func (s *LivepeerServer) registerConnection(rtmpStrm stream.RTMPVideoStream) (*r
 	if exists {
 		// We can only have one concurrent stream per ManifestID
 		s.connectionLock.Unlock()
+		TenantLimits.release(params.Tenant)
+		cxn.warmup.stop()
 		return nil, errAlreadyExists
 	}
 	s.rtmpConnections[mid] = cxn
@@ -505,6 +698,7 @@ func (s *LivepeerServer) registerConnection(rtmpStrm stream.RTMPVideoStream) (*r
 
 	if monitor.Enabled {
 		monitor.CurrentSessions(sessionsNumber)
+		monitor.SourceResolution(params.Resolution)
 	}
 
 	return cxn, nil
@@ -518,11 +712,14 @@ func removeRTMPStream(s *LivepeerServer, mid core.ManifestID) error {
 		glog.Error("Attempted to end unknown stream with manifest ID ", mid)
 		return errUnknownStream
 	}
-	cxn.stream.Close()
+	rtmpStrm, _ := cxn.publisher()
+	rtmpStrm.Close()
 	cxn.sessManager.cleanup()
 	cxn.pl.Cleanup()
+	cxn.warmup.stop()
 	glog.Infof("Ended stream with id=%s", mid)
 	delete(s.rtmpConnections, mid)
+	TenantLimits.release(cxn.params.Tenant)
 
 	if monitor.Enabled {
 		monitor.StreamEnded(cxn.nonce)
@@ -552,7 +749,7 @@ func getHLSMasterPlaylistHandler(s *LivepeerServer) func(url *url.URL) (*m3u8.Ma
 		s.connectionLock.RLock()
 		defer s.connectionLock.RUnlock()
 		cxn, ok := s.rtmpConnections[manifestID]
-		if !ok || cxn.pl == nil {
+		if !ok || cxn.pl == nil || !cxn.warmup.isReady() {
 			return nil, vidplayer.ErrNotFound
 		}
 		cpl := cxn.pl
@@ -571,7 +768,7 @@ func getHLSMediaPlaylistHandler(s *LivepeerServer) func(url *url.URL) (*m3u8.Med
 		s.connectionLock.RLock()
 		defer s.connectionLock.RUnlock()
 		cxn, ok := s.rtmpConnections[mid]
-		if !ok || cxn.pl == nil {
+		if !ok || cxn.pl == nil || !cxn.warmup.isReady() {
 			return nil, vidplayer.ErrNotFound
 		}
 
@@ -580,6 +777,9 @@ func getHLSMediaPlaylistHandler(s *LivepeerServer) func(url *url.URL) (*m3u8.Med
 		if pl == nil {
 			return nil, vidplayer.ErrNotFound
 		}
+		if monitor.Enabled {
+			monitor.PlaylistRequested(cxn.nonce)
+		}
 		return pl, nil
 	}
 }
@@ -597,22 +797,48 @@ func getHLSSegmentHandler(s *LivepeerServer) func(url *url.URL) ([]byte, error)
 			glog.Error("Unexpected path structure")
 			return nil, vidplayer.ErrNotFound
 		}
-		memoryOS, ok := drivers.NodeStorage.(*drivers.MemoryOS)
-		if !ok {
-			return nil, vidplayer.ErrNotFound
+		data := getNodeSegmentData(segName)
+		if len(data) > 0 {
+			if monitor.Enabled {
+				s.connectionLock.RLock()
+				cxn, ok := s.rtmpConnections[core.ManifestID(parts[0])]
+				s.connectionLock.RUnlock()
+				if ok {
+					monitor.SegmentServed(cxn.nonce)
+				}
+			}
+			return data, nil
 		}
+		return nil, vidplayer.ErrNotFound
+	}
+}
+
+// getNodeSegmentData returns the data of the segment from the node storage,
+// nil if not found. The segment name is <manifestID>/<more-path>/<data>
+func getNodeSegmentData(segName string) []byte {
+	parts := strings.SplitN(segName, "/", 2)
+	switch storage := drivers.NodeStorage.(type) {
+	case *drivers.MemoryOS:
 		// We index the session by the first entry of the path, eg
 		// <session>/<more-path>/<data>
-		os := memoryOS.GetSession(parts[0])
+		os := storage.GetSession(parts[0])
 		if os == nil {
-			return nil, vidplayer.ErrNotFound
+			return nil
 		}
-		data := os.GetData(segName)
-		if len(data) > 0 {
-			return data, nil
+		return os.GetData(segName)
+	case *drivers.FSOS:
+		return storage.GetData(segName)
+	case *drivers.CacheOS:
+		data := storage.GetData(segName)
+		if data == nil && len(parts) == 2 {
+			// not cached, fetch from the remote storage
+			data = SegmentFetches.do(func() []byte {
+				return storage.NewSession(parts[0]).(*drivers.CacheSession).GetData(parts[1])
+			})
 		}
-		return nil, vidplayer.ErrNotFound
+		return data
 	}
+	return nil
 }
 
 //End HLS Play Handlers
@@ -630,7 +856,8 @@ func getRTMPStreamHandler(s *LivepeerServer) func(url *url.URL) (stream.RTMPVide
 		}
 
 		//Could use a subscriber, but not going to here because the RTMP stream doesn't need to be available for consumption by multiple views.  It's only for the segmenter.
-		return cxn.stream, nil
+		rtmpStrm, _ := cxn.publisher()
+		return rtmpStrm, nil
 	}
 }
 
@@ -759,6 +986,7 @@ func (s *LivepeerServer) HandlePush(w http.ResponseWriter, r *http.Request) {
 	}()
 
 	// Do the transcoding!
+	cxn.uploads.queue(seg.SeqNo)
 	urls, err := processSegment(cxn, seg)
 	if err != nil {
 		// TODO distinguish between user errors (400) and server errors (500)
@@ -771,10 +999,10 @@ func (s *LivepeerServer) HandlePush(w http.ResponseWriter, r *http.Request) {
 	}
 	renditionData := make([][]byte, len(urls))
 	// find data in local storage
-	memOS, ok := cxn.pl.GetOSSession().(*drivers.MemorySession)
+	localOS, ok := cxn.pl.GetOSSession().(interface{ GetData(string) []byte })
 	if ok {
 		for i, fname := range urls {
-			data := memOS.GetData(fname)
+			data := localOS.GetData(fname)
 			if data != nil {
 				renditionData[i] = data
 			}
@@ -897,6 +1125,9 @@ func (s *LivepeerServer) GetNodeStatus() *net.NodeStatus {
 		RegisteredTranscoders: []net.RemoteTranscoderInfo{},
 		LocalTranscoding:      s.LivepeerNode.TranscoderManager == nil,
 	}
+	if monitor.Enabled {
+		res.StreamCosts = monitor.StreamCosts()
+	}
 	if s.LivepeerNode.TranscoderManager != nil {
 		res.RegisteredTranscodersNumber = s.LivepeerNode.TranscoderManager.RegisteredTranscodersCount()
 		res.RegisteredTranscoders = s.LivepeerNode.TranscoderManager.RegisteredTranscodersInfo()
@@ -12,6 +12,7 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -28,6 +29,7 @@ import (
 	ffmpeg "github.com/livepeer/lpms/ffmpeg"
 	"github.com/livepeer/lpms/segmenter"
 	"github.com/livepeer/lpms/stream"
+	"github.com/livepeer/lpms/vidplayer"
 )
 
 var S *LivepeerServer
@@ -66,8 +68,11 @@ func setupServerWithCancel() (*LivepeerServer, context.CancelFunc) {
 func serverCleanup(s *LivepeerServer) {
 	s.connectionLock.Lock()
 	for _, cxn := range s.rtmpConnections {
-		if cxn != nil && cxn.stream != nil {
-			cxn.stream.Close()
+		if cxn == nil {
+			continue
+		}
+		if rtmpStrm, _ := cxn.publisher(); rtmpStrm != nil {
+			rtmpStrm.Close()
 		}
 	}
 	s.connectionLock.Unlock()
@@ -491,6 +496,33 @@ func TestCreateRTMPStreamHandlerWebhook(t *testing.T) {
 	params = createSid(u).(*core.StreamParameters)
 	assert.Len(params.Profiles, 1)
 	assert.Equal(ffmpeg.GOPIntraOnly, params.Profiles[0].GOP)
+
+	// upload ordering defaults to -inOrderUploads
+	params = createSid(u).(*core.StreamParameters)
+	assert.False(params.InOrderUploads)
+	InOrderUploads = true
+	defer func() { InOrderUploads = false }()
+	params = createSid(u).(*core.StreamParameters)
+	assert.True(params.InOrderUploads)
+
+	// upload ordering set by webhook
+	ts15 := makeServer(`{"manifestID":"a", "inOrderUploads": false}`)
+	defer ts15.Close()
+	params = createSid(u).(*core.StreamParameters)
+	assert.False(params.InOrderUploads)
+
+	// tenant set by webhook takes precedence over manifest ID prefix
+	TenantSeparator = "-"
+	defer func() { TenantSeparator = "" }()
+	assert.Equal("", params.Tenant)
+	ts16 := makeServer(`{"manifestID":"acme-a"}`)
+	defer ts16.Close()
+	params = createSid(u).(*core.StreamParameters)
+	assert.Equal("acme", params.Tenant)
+	ts17 := makeServer(`{"manifestID":"acme-a", "tenant": "claim"}`)
+	defer ts17.Close()
+	params = createSid(u).(*core.StreamParameters)
+	assert.Equal("claim", params.Tenant)
 }
 
 func TestCreateRTMPStreamHandler(t *testing.T) {
@@ -596,6 +628,48 @@ func TestEndRTMPStreamHandler(t *testing.T) {
 	}
 }
 
+// blocks like a real segmenter until the RTMP stream is closed
+type blockingSegmenter struct{}
+
+func (s *blockingSegmenter) SegmentRTMPToHLS(ctx context.Context, rs stream.RTMPVideoStream, hs stream.HLSVideoStream, segOptions segmenter.SegmenterOptions) error {
+	<-rs.(*stream.BasicRTMPVideoStream).EOF
+	return nil
+}
+
+func TestActiveSegmenters(t *testing.T) {
+	s := setupServer()
+	defer serverCleanup(s)
+	s.RTMPSegmenter = &blockingSegmenter{}
+	createSid := createRTMPStreamIDHandler(s)
+	handler := gotRTMPStreamHandler(s)
+	endHandler := endRTMPStreamHandler(s)
+
+	// segmenters of the previous tests may still be running
+	common.WaitUntil(time.Second, func() bool { return atomic.LoadInt64(&activeSegmenters) == 0 })
+	base := atomic.LoadInt64(&activeSegmenters)
+	activeEquals := func(n int64) func() bool {
+		return func() bool { return atomic.LoadInt64(&activeSegmenters) == base+n }
+	}
+
+	var streams []stream.RTMPVideoStream
+	for i := 0; i < 3; i++ {
+		u, _ := url.Parse("rtmp://localhost")
+		st := stream.NewBasicRTMPVideoStream(createSid(u))
+		require.Nil(t, handler(u, st))
+		streams = append(streams, st)
+	}
+	common.WaitAssert(t, time.Second, activeEquals(3), "segmenters not started")
+
+	u, _ := url.Parse("rtmp://localhost")
+	require.Nil(t, endHandler(u, streams[0]))
+	common.WaitAssert(t, time.Second, activeEquals(2), "segmenter did not exit")
+
+	for _, st := range streams[1:] {
+		require.Nil(t, endHandler(u, st))
+	}
+	common.WaitAssert(t, time.Second, activeEquals(0), "segmenters did not exit")
+}
+
 // Should publish RTMP stream, turn the RTMP stream into HLS, and broadcast the HLS stream.
 func TestGotRTMPStreamHandler(t *testing.T) {
 	s := setupServer()
@@ -665,6 +739,171 @@ func TestGotRTMPStreamHandler(t *testing.T) {
 	}
 }
 
+func TestGotRTMPStreamHandler_TenantLimit(t *testing.T) {
+	assert := assert.New(t)
+	s := setupServer()
+	defer serverCleanup(s)
+	s.RTMPSegmenter = &StubSegmenter{skip: true}
+	handler := gotRTMPStreamHandler(s)
+	u, _ := url.Parse("rtmp://localhost")
+
+	TenantLimits = NewTenantLimiter(2, 0, time.Minute)
+	defer func() { TenantLimits = nil }()
+	newStream := func(mid, tenant string) stream.RTMPVideoStream {
+		return stream.NewBasicRTMPVideoStream(&core.StreamParameters{ManifestID: core.ManifestID(mid), Tenant: tenant})
+	}
+
+	assert.Nil(handler(u, newStream("tenant-limit-1", "acme")))
+	assert.Nil(handler(u, newStream("tenant-limit-2", "acme")))
+	defer removeRTMPStream(s, "tenant-limit-2")
+	// the tenant is at its limit
+	assert.Equal(errTenantStreamLimit, handler(u, newStream("tenant-limit-3", "acme")))
+	s.connectionLock.RLock()
+	_, exists := s.rtmpConnections["tenant-limit-3"]
+	s.connectionLock.RUnlock()
+	assert.False(exists)
+	// other tenants aren't limited
+	assert.Nil(handler(u, newStream("tenant-limit-4", "other")))
+	defer removeRTMPStream(s, "tenant-limit-4")
+	// rejected duplicate doesn't count
+	assert.Equal(errAlreadyExists, handler(u, newStream("tenant-limit-4", "other")))
+	assert.Equal(1, TenantLimits.active["other"])
+
+	// ended stream frees the slot
+	assert.Nil(removeRTMPStream(s, "tenant-limit-1"))
+	assert.Nil(handler(u, newStream("tenant-limit-3", "acme")))
+	defer removeRTMPStream(s, "tenant-limit-3")
+}
+
+// emits two segments from the start seqNo, then blocks till the RTMP stream
+// is closed
+type rotationSegmenter struct {
+	mu        sync.Mutex
+	startSeqs map[stream.RTMPVideoStream]int
+	emitted   map[stream.RTMPVideoStream]bool
+}
+
+func (s *rotationSegmenter) SegmentRTMPToHLS(ctx context.Context, rs stream.RTMPVideoStream, hs stream.HLSVideoStream, segOptions segmenter.SegmenterOptions) error {
+	s.mu.Lock()
+	s.startSeqs[rs] = segOptions.StartSeq
+	s.mu.Unlock()
+	for i := 0; i < 2; i++ {
+		seqNo := uint64(segOptions.StartSeq + i)
+		hs.AddHLSSegment(&stream.HLSSegment{SeqNo: seqNo, Name: fmt.Sprintf("seg%d.ts", seqNo)})
+	}
+	s.mu.Lock()
+	s.emitted[rs] = true
+	s.mu.Unlock()
+	<-rs.(*stream.BasicRTMPVideoStream).EOF
+	return nil
+}
+
+func TestGotRTMPStreamHandler_StreamKeyRotation(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	s := setupServer()
+	defer serverCleanup(s)
+	seg := &rotationSegmenter{startSeqs: make(map[stream.RTMPVideoStream]int), emitted: make(map[stream.RTMPVideoStream]bool)}
+	s.RTMPSegmenter = seg
+	createSid := createRTMPStreamIDHandler(s)
+	handler := gotRTMPStreamHandler(s)
+	endHandler := endRTMPStreamHandler(s)
+
+	// accepts any stream key of the manifest
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req authWebhookReq
+		require.Nil(json.NewDecoder(r.Body).Decode(&req))
+		u, _ := url.Parse(req.URL)
+		sid := parseStreamID(u.Path)
+		w.Write([]byte(fmt.Sprintf(`{"manifestID":"rotmani","streamKey":"%s"}`, sid.Rendition)))
+	}))
+	defer ts.Close()
+	defer func(url string) { AuthWebhookURL = url }(AuthWebhookURL)
+	AuthWebhookURL = ts.URL
+	StreamKeyRotation = true
+	defer func() { StreamKeyRotation = false }()
+
+	publish := func(key string) stream.RTMPVideoStream {
+		u, _ := url.Parse("rtmp://localhost/live/rotmani/" + key)
+		sid := createSid(u)
+		if sid == nil {
+			return nil
+		}
+		return stream.NewBasicRTMPVideoStream(sid)
+	}
+	u, _ := url.Parse("rtmp://localhost")
+	// the segments are queued for upload as the segmenter emits them and
+	// dequeued once processed, after they're inserted into the playlist
+	processed := func(cxn *rtmpConnection, st stream.RTMPVideoStream) bool {
+		seg.mu.Lock()
+		emitted := seg.emitted[st]
+		seg.mu.Unlock()
+		return emitted && cxn.uploads.depth() == 0
+	}
+	sourceSegs := func() int {
+		pl := s.LatestPlaylist().GetHLSMediaPlaylist("source")
+		if pl == nil {
+			return 0
+		}
+		return int(pl.Count())
+	}
+
+	st1 := publish("key1")
+	require.NotNil(st1)
+	require.Nil(handler(u, st1))
+	s.connectionLock.RLock()
+	cxn := s.rtmpConnections["rotmani"]
+	s.connectionLock.RUnlock()
+	require.NotNil(cxn)
+	common.WaitAssert(t, time.Second, func() bool { return processed(cxn, st1) }, "segments not processed")
+	assert.Equal(2, sourceSegs())
+
+	// same key isn't a rotation
+	assert.Nil(publish("key1"))
+
+	// rotate the key mid-stream
+	st2 := publish("key2")
+	require.NotNil(st2)
+	require.Nil(handler(u, st2))
+	select {
+	case <-st1.(*stream.BasicRTMPVideoStream).EOF:
+	case <-time.After(time.Second):
+		assert.Fail("previous publisher wasn't disconnected")
+	}
+	// ending the previous publisher doesn't end the stream
+	assert.Nil(endHandler(u, st1))
+	s.connectionLock.RLock()
+	assert.Equal(cxn, s.rtmpConnections["rotmani"])
+	s.connectionLock.RUnlock()
+	publisher, key := cxn.publisher()
+	assert.Equal("key2", key)
+	assert.Equal(st2, publisher)
+
+	// segmentation continues with the following seqNos
+	common.WaitAssert(t, time.Second, func() bool { return processed(cxn, st2) }, "segments not processed")
+	assert.Equal(4, sourceSegs())
+	seg.mu.Lock()
+	assert.Equal(0, seg.startSeqs[st1])
+	assert.Equal(2, seg.startSeqs[st2])
+	seg.mu.Unlock()
+	pl := s.LatestPlaylist().GetHLSMediaPlaylist("source")
+	for i := 0; i < 4; i++ {
+		assert.Equal(fmt.Sprintf("/stream/rotmani/source/%d.ts", i), pl.Segments[i].URI)
+	}
+
+	// rotation is disabled
+	StreamKeyRotation = false
+	assert.Nil(publish("key3"))
+	StreamKeyRotation = true
+
+	// ending the current publisher ends the stream
+	assert.Nil(endHandler(u, st2))
+	s.connectionLock.RLock()
+	_, exists := s.rtmpConnections["rotmani"]
+	s.connectionLock.RUnlock()
+	assert.False(exists)
+}
+
 func TestMultiStream(t *testing.T) {
 	// set unlimited sessions because this tests creates 500 streams
 	core.MaxSessions = 0
@@ -745,7 +984,7 @@ func TestGetHLSMasterPlaylistHandler(t *testing.T) {
 	}
 
 	segName := "test_seg/1.ts"
-	err := s.LatestPlaylist().InsertHLSSegment(&vProfile, 1, segName, 12)
+	err := s.LatestPlaylist().InsertHLSSegment(&vProfile, 1, segName, 12, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -772,6 +1011,46 @@ func TestGetHLSMasterPlaylistHandler(t *testing.T) {
 	}
 }
 
+func TestGetHLSPlaylistHandlers_Warmup(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	s := setupServer()
+	defer serverCleanup(s)
+	defer func(segs int) { WarmupSegments = segs }(WarmupSegments)
+	WarmupSegments = 2
+
+	vProfile := ffmpeg.P720p30fps16x9
+	mid := core.SplitStreamIDString(t.Name()).ManifestID
+	strmID := core.MakeStreamID(mid, &vProfile)
+	strm := stream.NewBasicRTMPVideoStream(newStreamParams(mid, "source"))
+	cxn, err := s.registerConnection(strm)
+	require.Nil(err)
+	defer removeRTMPStream(s, mid)
+	require.Nil(cxn.pl.InsertHLSSegment(&vProfile, 1, "test_seg/1.ts", 12, 0))
+
+	mlHandler := getHLSMasterPlaylistHandler(s)
+	mediaHandler := getHLSMediaPlaylistHandler(s)
+	masterURL, _ := url.Parse(fmt.Sprintf("http://localhost/stream/%s.m3u8", mid))
+	mediaURL, _ := url.Parse(fmt.Sprintf("http://localhost/stream/%s.m3u8", strmID))
+
+	// viewers aren't served while the stream is warming up
+	for i := 0; i < WarmupSegments; i++ {
+		_, err = mlHandler(masterURL)
+		assert.Equal(vidplayer.ErrNotFound, err)
+		_, err = mediaHandler(mediaURL)
+		assert.Equal(vidplayer.ErrNotFound, err)
+		cxn.warmup.segmentBuffered()
+	}
+
+	// viewers are served once the warmup completes
+	mpl, err := mlHandler(masterURL)
+	assert.Nil(err)
+	assert.NotNil(mpl)
+	pl, err := mediaHandler(mediaURL)
+	assert.Nil(err)
+	assert.NotNil(pl)
+}
+
 func TestRegisterConnection(t *testing.T) {
 	assert := assert.New(t)
 	s := setupServer()
@@ -807,7 +1086,7 @@ func TestRegisterConnection(t *testing.T) {
 	assert.Equal(err, errAlreadyExists)
 
 	// Check for params with an existing OS assigned
-	storage := drivers.NewS3Driver("", "", "", "").NewSession("")
+	storage := drivers.NewS3Driver(drivers.S3Options{}).NewSession("")
 	strm = stream.NewBasicRTMPVideoStream(&core.StreamParameters{ManifestID: core.RandomManifestID(), OS: storage})
 	cxn, err = s.registerConnection(strm)
 	assert.Nil(err)
@@ -1070,6 +1349,56 @@ func TestJsonProfileToVideoProfiles(t *testing.T) {
 	assert.Contains(err.Error(), "strconv.ParseFloat: parsing")
 	resp.Profiles[0].GOP = ""
 
+	// test gop shorter than a frame
+	resp.Profiles[0].FPS = 30
+	resp.Profiles[0].GOP = "0.01"
+	p, err = jsonProfileToVideoProfile(resp)
+	assert.Nil(p)
+	assert.EqualError(err, "gop is shorter than frame interval")
+
+	// test gop of a single frame
+	resp.Profiles[0].GOP = "0.04"
+	p, err = jsonProfileToVideoProfile(resp)
+	assert.Nil(err)
+	assert.Equal(40*time.Millisecond, p[0].GOP)
+	resp.Profiles[0].GOP = ""
+
+	// test gop size in frames
+	resp.Profiles[0].GOPSize = 60
+	p, err = jsonProfileToVideoProfile(resp)
+	assert.Nil(err)
+	assert.Equal(2*time.Second, p[0].GOP)
+
+	// test gop size with fractional fps
+	resp.Profiles[0].FPS = 30000
+	resp.Profiles[0].FPSDen = 1001
+	resp.Profiles[0].GOPSize = 30
+	p, err = jsonProfileToVideoProfile(resp)
+	assert.Nil(err)
+	assert.Equal(1001*time.Millisecond, p[0].GOP)
+
+	// test gop size together with gop
+	resp.Profiles[0].GOP = "2"
+	p, err = jsonProfileToVideoProfile(resp)
+	assert.Nil(p)
+	assert.EqualError(err, "only one of gop or gopSize can be set")
+	resp.Profiles[0].GOP = ""
+
+	// test negative gop size
+	resp.Profiles[0].GOPSize = -1
+	p, err = jsonProfileToVideoProfile(resp)
+	assert.Nil(p)
+	assert.EqualError(err, "invalid gopSize value")
+
+	// test gop size without fps
+	resp.Profiles[0].FPS = 0
+	resp.Profiles[0].FPSDen = 0
+	resp.Profiles[0].GOPSize = 60
+	p, err = jsonProfileToVideoProfile(resp)
+	assert.Nil(p)
+	assert.EqualError(err, "gopSize requires fps")
+	resp.Profiles[0].GOPSize = 0
+
 	// test default encoding profile
 	p, err = jsonProfileToVideoProfile(resp)
 	assert.Nil(err)
@@ -92,6 +92,7 @@ func runTranscoder(n *core.LivepeerNode, orchAddr string, capacity int) error {
 	// Silence linter
 	defer cancel()
 	r, err := c.RegisterTranscoder(ctx, &net.RegisterRequest{Secret: n.OrchSecret, Capacity: int64(capacity)})
+	recordGRPCError(orchAddr, err)
 	if err := checkTranscoderError(err); err != nil {
 		glog.Error("Could not register transcoder to orchestrator ", err)
 		return err
@@ -115,6 +116,7 @@ func runTranscoder(n *core.LivepeerNode, orchAddr string, capacity int) error {
 	var wg sync.WaitGroup
 	for {
 		notify, err := r.Recv()
+		recordGRPCError(orchAddr, err)
 		if err := checkTranscoderError(err); err != nil {
 			glog.Infof(`End of stream receive cycle because of err="%v", waiting for running transcode jobs to complete`, err)
 			wg.Wait()
@@ -291,6 +291,53 @@ func TestPush_HTTPIngest(t *testing.T) {
 	assert.Equal("No sessions available", strings.TrimSpace(string(body)))
 }
 
+func TestGetHLSSegmentHandler_FS(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	s := setupServer()
+	defer serverCleanup(s)
+	defer func(storage drivers.OSDriver) { drivers.NodeStorage = storage }(drivers.NodeStorage)
+
+	dir, err := ioutil.TempDir("", t.Name())
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+	drivers.NodeStorage = drivers.NewFSDriver(dir, "")
+	uri, err := drivers.NodeStorage.NewSession("fsmani").SaveData("source/1.ts", []byte("data"), nil)
+	require.Nil(err)
+
+	segHandler := getHLSSegmentHandler(s)
+	u, _ := url.Parse(uri)
+	data, err := segHandler(u)
+	assert.Nil(err)
+	assert.Equal("data", string(data))
+
+	u, _ = url.Parse("/stream/fsmani/source/2.ts")
+	_, err = segHandler(u)
+	assert.Equal(vidplayer.ErrNotFound, err)
+}
+
+func TestGetHLSSegmentHandler_Cache(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	s := setupServer()
+	defer serverCleanup(s)
+	defer func(storage drivers.OSDriver) { drivers.NodeStorage = storage }(drivers.NodeStorage)
+
+	drivers.NodeStorage = drivers.NewCacheDriver(drivers.NewMemoryDriver(nil), 1024)
+	_, err := drivers.NodeStorage.NewSession("cachemani").SaveData("source/1.ts", []byte("data"), nil)
+	require.Nil(err)
+
+	segHandler := getHLSSegmentHandler(s)
+	u, _ := url.Parse("/stream/cachemani/source/1.ts")
+	data, err := segHandler(u)
+	assert.Nil(err)
+	assert.Equal("data", string(data))
+
+	u, _ = url.Parse("/stream/cachemani/source/2.ts")
+	_, err = segHandler(u)
+	assert.Equal(vidplayer.ErrNotFound, err)
+}
+
 func TestPush_MP4(t *testing.T) {
 
 	// Do a bunch of setup. Would be nice to simplify this one day...
@@ -3,6 +3,7 @@ package server
 import (
 	"context"
 	"fmt"
+	"io"
 	"math/big"
 	"net/http"
 	"net/url"
@@ -10,11 +11,14 @@ import (
 	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
 
 	"github.com/livepeer/go-livepeer/common"
 	"github.com/livepeer/go-livepeer/core"
 	"github.com/livepeer/go-livepeer/drivers"
+	"github.com/livepeer/go-livepeer/monitor"
 	"github.com/livepeer/go-livepeer/net"
 	"github.com/livepeer/go-livepeer/pm"
 	ffmpeg "github.com/livepeer/lpms/ffmpeg"
@@ -155,7 +159,7 @@ func StartTranscodeServer(orch Orchestrator, bind string, mux *http.ServeMux, wo
 	glog.Info("Listening for RPC on ", bind)
 	srv := http.Server{
 		Addr:    bind,
-		Handler: &lp,
+		Handler: cacheControlHandler(&lp),
 		// XXX doesn't handle streaming RPC well; split remote transcoder RPC?
 		//ReadTimeout:  HTTPTimeout,
 		//WriteTimeout: HTTPTimeout,
@@ -184,6 +188,7 @@ func CheckOrchestratorAvailability(orch Orchestrator) bool {
 
 	pong, err := orchClient.Ping(ctx, &net.PingPong{Value: ping})
 	if err != nil {
+		recordGRPCError(orch.ServiceURI().String(), err)
 		glog.Error("Was not able to submit Ping: ", err)
 		return false
 	}
@@ -212,17 +217,45 @@ func GetOrchestratorInfo(ctx context.Context, bcast common.Broadcaster, orchestr
 	req, err := genOrchestratorReq(bcast)
 	r, err := c.GetOrchestrator(ctx, req)
 	if err != nil {
+		recordGRPCError(orchestratorServer.String(), err)
 		glog.Errorf("Could not get orchestrator orch=%v err=%v", orchestratorServer, err)
-		return nil, errors.New("Could not get orchestrator err=" + err.Error())
+		return nil, fmt.Errorf("Could not get orchestrator err=%w", err)
 	}
 
 	return r, nil
 }
 
+// isGRPCStreamError returns true if the gRPC call failed because the connection
+// or the stream to the orchestrator broke, rather than because the orchestrator
+// returned an error for this particular request
+func isGRPCStreamError(err error) bool {
+	if err == io.EOF {
+		return true
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.Aborted, codes.DataLoss:
+		return true
+	}
+	return false
+}
+
+func recordGRPCError(orch string, err error) {
+	// cancellation is initiated by us, so isn't counted as error
+	if err == nil || status.Code(err) == codes.Canceled || !monitor.Enabled {
+		return
+	}
+	monitor.OrchestratorGRPCError(orch, isGRPCStreamError(err))
+}
+
 func startOrchestratorClient(uri *url.URL) (net.OrchestratorClient, *grpc.ClientConn, error) {
 	glog.Infof("Connecting RPC to %v", uri)
+	creds := grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))
+	if uri.Scheme == "http" {
+		// plain text orchestrators, e.g. in dev and test setups
+		creds = grpc.WithInsecure()
+	}
 	conn, err := grpc.Dial(uri.Host,
-		grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)),
+		creds,
 		grpc.WithBlock(),
 		grpc.WithTimeout(GRPCConnectTimeout))
 	if err != nil {
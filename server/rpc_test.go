@@ -6,6 +6,7 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"io"
 	"math/big"
 	"net/url"
 	"testing"
@@ -16,6 +17,9 @@ import (
 	"github.com/stretchr/testify/require"
 	"pgregory.net/rapid"
 
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
 	"github.com/ethereum/go-ethereum/accounts"
 	ethcommon "github.com/ethereum/go-ethereum/common"
 	ethcrypto "github.com/ethereum/go-ethereum/crypto"
@@ -628,6 +632,22 @@ func TestPing(t *testing.T) {
 	}
 }
 
+func TestIsGRPCStreamError(t *testing.T) {
+	assert := assert.New(t)
+
+	// connection or stream broke
+	assert.True(isGRPCStreamError(status.Error(codes.Unavailable, "transport is closing")))
+	assert.True(isGRPCStreamError(status.Error(codes.Aborted, "aborted")))
+	assert.True(isGRPCStreamError(status.Error(codes.DataLoss, "data loss")))
+	assert.True(isGRPCStreamError(io.EOF))
+
+	// orchestrator returned error for the request
+	assert.False(isGRPCStreamError(status.Error(codes.Unknown, "invalid secret")))
+	assert.False(isGRPCStreamError(status.Error(codes.Internal, "some error")))
+	assert.False(isGRPCStreamError(status.Error(codes.DeadlineExceeded, "context deadline exceeded")))
+	assert.False(isGRPCStreamError(errors.New("some error")))
+}
+
 func TestValidatePrice(t *testing.T) {
 	assert := assert.New(t)
 	mid := core.RandomManifestID()
@@ -943,7 +963,12 @@ type mockOSSession struct {
 	mock.Mock
 }
 
-func (s *mockOSSession) SaveData(name string, data []byte) (string, error) {
+func (s *mockOSSession) SaveData(name string, data []byte, props *drivers.FileProperties) (string, error) {
+	args := s.Called()
+	return args.String(0), args.Error(1)
+}
+
+func (s *mockOSSession) SaveDataReader(name string, r io.Reader, size int64) (string, error) {
 	args := s.Called()
 	return args.String(0), args.Error(1)
 }
@@ -952,6 +977,27 @@ func (s *mockOSSession) EndSession() {
 	s.Called()
 }
 
+func (s *mockOSSession) ListData() ([]*drivers.FileInfo, error) {
+	args := s.Called()
+	if args.Get(0) != nil {
+		return args.Get(0).([]*drivers.FileInfo), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (s *mockOSSession) DeleteData(name string) error {
+	args := s.Called(name)
+	return args.Error(0)
+}
+
+func (s *mockOSSession) ReadData(name string) ([]byte, error) {
+	args := s.Called(name)
+	if args.Get(0) != nil {
+		return args.Get(0).([]byte), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
 func (s *mockOSSession) GetInfo() *net.OSInfo {
 	args := s.Called()
 	if args.Get(0) != nil {
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"sync"
+
+	"github.com/livepeer/go-livepeer/monitor"
+)
+
+// SegmentFetches limits the segments the HLS handlers fetch from the remote
+// storage at once. Unlimited if nil.
+var SegmentFetches *SegmentFetchLimiter
+
+// SegmentFetchLimiter bounds the number of segment fetches in progress at
+// once, across all streams. Fetches above the limit are queued till one of
+// the fetches in progress finishes.
+type SegmentFetchLimiter struct {
+	slots chan struct{}
+
+	mu       sync.Mutex
+	inFlight int
+	queued   int
+}
+
+// NewSegmentFetchLimiter returns limiter allowing maxFetches fetches at once
+func NewSegmentFetchLimiter(maxFetches int) *SegmentFetchLimiter {
+	return &SegmentFetchLimiter{slots: make(chan struct{}, maxFetches)}
+}
+
+// do runs the fetch once there is a free slot
+func (l *SegmentFetchLimiter) do(fetch func() []byte) []byte {
+	if l == nil {
+		return fetch()
+	}
+	l.update(0, 1)
+	l.slots <- struct{}{}
+	l.update(1, -1)
+	defer func() {
+		<-l.slots
+		l.update(-1, 0)
+	}()
+	return fetch()
+}
+
+func (l *SegmentFetchLimiter) update(inFlight, queued int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inFlight += inFlight
+	l.queued += queued
+	if monitor.Enabled {
+		monitor.SegmentFetches(l.inFlight, l.queued)
+	}
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"fmt"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/livepeer/go-livepeer/drivers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// slowOSDriver counts the reads in progress at once
+type slowOSDriver struct {
+	drivers.OSDriver
+	delay time.Duration
+
+	mu      sync.Mutex
+	reading int
+	max     int
+}
+
+type slowOSSession struct {
+	drivers.OSSession
+	d *slowOSDriver
+}
+
+func (d *slowOSDriver) NewSession(path string) drivers.OSSession {
+	return &slowOSSession{OSSession: d.OSDriver.NewSession(path), d: d}
+}
+
+func (sess *slowOSSession) ReadData(name string) ([]byte, error) {
+	sess.d.mu.Lock()
+	sess.d.reading++
+	if sess.d.reading > sess.d.max {
+		sess.d.max = sess.d.reading
+	}
+	sess.d.mu.Unlock()
+	defer func() {
+		sess.d.mu.Lock()
+		sess.d.reading--
+		sess.d.mu.Unlock()
+	}()
+	time.Sleep(sess.d.delay)
+	return sess.OSSession.ReadData(name)
+}
+
+func TestSegmentFetchLimiter(t *testing.T) {
+	assert := assert.New(t)
+
+	var nilLimiter *SegmentFetchLimiter
+	assert.Equal([]byte("data"), nilLimiter.do(func() []byte { return []byte("data") }))
+
+	l := NewSegmentFetchLimiter(1)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	go l.do(func() []byte {
+		close(started)
+		<-release
+		return nil
+	})
+	<-started
+
+	done := make(chan []byte)
+	go func() { done <- l.do(func() []byte { return []byte("queued") }) }()
+	assert.Eventually(func() bool {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		return l.queued == 1
+	}, time.Second, time.Millisecond)
+	select {
+	case <-done:
+		assert.Fail("fetch should wait for a free slot")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+	assert.Equal([]byte("queued"), <-done)
+	l.mu.Lock()
+	assert.Zero(l.inFlight)
+	assert.Zero(l.queued)
+	l.mu.Unlock()
+}
+
+func TestGetHLSSegmentHandler_MaxSegmentFetches(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	s := setupServer()
+	defer serverCleanup(s)
+	defer func(storage drivers.OSDriver) { drivers.NodeStorage = storage }(drivers.NodeStorage)
+	defer func() { SegmentFetches = nil }()
+
+	const segments = 20
+	remote := &slowOSDriver{OSDriver: drivers.NewMemoryDriver(nil), delay: 10 * time.Millisecond}
+	rsess := remote.NewSession("fetchmani")
+	// memory storage keeps only the last few segments of each rendition
+	for i := 0; i < segments; i++ {
+		_, err := rsess.SaveData(fmt.Sprintf("P%dp/1.ts", i), []byte(fmt.Sprintf("data%d", i)), nil)
+		require.Nil(err)
+	}
+	// too small to cache any segment, so each request is fetched
+	drivers.NodeStorage = drivers.NewCacheDriver(remote, 1)
+	SegmentFetches = NewSegmentFetchLimiter(3)
+
+	segHandler := getHLSSegmentHandler(s)
+	var wg sync.WaitGroup
+	for i := 0; i < segments; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			u, _ := url.Parse(fmt.Sprintf("/stream/fetchmani/P%dp/1.ts", i))
+			data, err := segHandler(u)
+			assert.Nil(err)
+			assert.Equal(fmt.Sprintf("data%d", i), string(data))
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(3, remote.max)
+	assert.Zero(SegmentFetches.inFlight)
+	assert.Zero(SegmentFetches.queued)
+}
@@ -10,7 +10,9 @@ import (
 	"math"
 	"math/big"
 	"net/http"
+	"net/http/httptrace"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/livepeer/go-livepeer/common"
@@ -43,6 +45,11 @@ var errProfile = errors.New("unrecognized encoder profile")
 var errDuration = errors.New("invalid duration")
 var errCapCompat = errors.New("incompatible capabilities")
 
+// TranscodeTimeoutFactor is how many times the segment duration the
+// broadcaster waits for the segment to be transcoded before cancelling the
+// request and retrying with another orchestrator
+var TranscodeTimeoutFactor = 4.0
+
 var tlsConfig = &tls.Config{InsecureSkipVerify: true}
 var httpClient = &http.Client{
 	Transport: &http2.Transport{TLSClientConfig: tlsConfig},
@@ -155,7 +162,7 @@ func (h *lphttp) ServeSegment(w http.ResponseWriter, r *http.Request) {
 		}
 		name := fmt.Sprintf("%s/%d%s", segData.Profiles[i].Name, segData.Seq, ext)
 		// The use of := here is probably a bug?!?
-		uri, err := res.OS.SaveData(name, res.TranscodeData.Segments[i].Data)
+		uri, err := res.OS.SaveData(name, res.TranscodeData.Segments[i].Data, nil)
 		if err != nil {
 			glog.Error("Could not upload segment ", segData.Seq)
 			break
@@ -304,6 +311,18 @@ func verifySegCreds(orch Orchestrator, segCreds string, broadcaster ethcommon.Ad
 	return md, nil
 }
 
+// transcodeTimeout returns how long to wait for the segment of the duration
+// (in seconds) to be transcoded. It's TranscodeTimeoutFactor times the
+// duration, with a minimum of common.HTTPTimeout to accommodate transport and
+// processing overhead
+func transcodeTimeout(dur float64) time.Duration {
+	timeout := time.Duration(TranscodeTimeoutFactor * dur * float64(time.Second))
+	if timeout < common.HTTPTimeout {
+		return common.HTTPTimeout
+	}
+	return timeout
+}
+
 func SubmitSegment(sess *BroadcastSession, seg *stream.HLSSegment, nonce uint64) (*ReceivedTranscodeResult, error) {
 	uploaded := seg.Name != "" // hijack seg.Name to convey the uploaded URI
 
@@ -353,17 +372,12 @@ func SubmitSegment(sess *BroadcastSession, seg *stream.HLSSegment, nonce uint64)
 		return nil, err
 	}
 
-	// set a minimum timeout to accommodate transport / processing overhead
-	dur := common.HTTPTimeout
-	paddedDur := 4.0 * seg.Duration // use a multiplier of 4 for now
-	if paddedDur > dur.Seconds() {
-		dur = time.Duration(paddedDur * float64(time.Second))
-	}
-	ctx, cancel := context.WithTimeout(context.Background(), dur)
+	ctx, cancel := context.WithTimeout(context.Background(), transcodeTimeout(seg.Duration))
 	defer cancel()
 
 	ti := sess.OrchestratorInfo
-	req, err := http.NewRequestWithContext(ctx, "POST", ti.Transcoder+"/segment", bytes.NewBuffer(data))
+	tracer := &ttfbTracer{}
+	req, err := http.NewRequestWithContext(tracer.trace(ctx), "POST", ti.Transcoder+"/segment", bytes.NewBuffer(data))
 	if err != nil {
 		glog.Errorf("Could not generate transcode request to orch=%s", ti.Transcoder)
 		if monitor.Enabled {
@@ -389,7 +403,11 @@ func SubmitSegment(sess *BroadcastSession, seg *stream.HLSSegment, nonce uint64)
 	if err != nil {
 		glog.Errorf("Unable to submit segment orch=%v nonce=%d manifestID=%s seqNo=%d orch=%s err=%v", ti.Transcoder, nonce, params.ManifestID, seg.SeqNo, ti.Transcoder, err)
 		if monitor.Enabled {
-			monitor.SegmentUploadFailed(nonce, seg.SeqNo, monitor.SegmentUploadErrorUnknown, err.Error(), false)
+			if ctx.Err() == context.DeadlineExceeded {
+				monitor.SegmentTranscodeFailed(monitor.SegmentTranscodeErrorTimeout, nonce, seg.SeqNo, err, false)
+			} else {
+				monitor.SegmentUploadFailed(nonce, seg.SeqNo, monitor.SegmentUploadErrorUnknown, err.Error(), false)
+			}
 		}
 		return nil, fmt.Errorf("header timeout: %w", err)
 	}
@@ -411,7 +429,7 @@ func SubmitSegment(sess *BroadcastSession, seg *stream.HLSSegment, nonce uint64)
 		errorString := strings.TrimSpace(string(data))
 		glog.Errorf("Error submitting segment nonce=%d manifestID=%s seqNo=%d code=%d orch=%s err=%v", nonce, params.ManifestID, seg.SeqNo, resp.StatusCode, ti.Transcoder, string(data))
 		if monitor.Enabled {
-			monitor.SegmentUploadFailed(nonce, seg.SeqNo, monitor.SegmentUploadError(resp.Status),
+			monitor.SegmentUploadFailed(nonce, seg.SeqNo, monitor.SegmentUploadErrorUnknown,
 				fmt.Sprintf("Code: %d Error: %s", resp.StatusCode, errorString), false)
 		}
 		return nil, fmt.Errorf(errorString)
@@ -419,6 +437,10 @@ func SubmitSegment(sess *BroadcastSession, seg *stream.HLSSegment, nonce uint64)
 	glog.Infof("Uploaded segment nonce=%d manifestID=%s seqNo=%d orch=%s dur=%s", nonce, params.ManifestID, seg.SeqNo, ti.Transcoder, uploadDur)
 	if monitor.Enabled {
 		monitor.SegmentUploaded(nonce, seg.SeqNo, uploadDur)
+		if ttfb, ok := tracer.ttfb(); ok {
+			monitor.TranscodeTTFB(orchestratorID(ti), ttfb)
+		}
+		monitor.RecordSegmentBytes(monitor.SegmentBytesUpload, int64(len(data)))
 	}
 
 	data, err = ioutil.ReadAll(resp.Body)
@@ -427,7 +449,11 @@ func SubmitSegment(sess *BroadcastSession, seg *stream.HLSSegment, nonce uint64)
 	if err != nil {
 		glog.Errorf("Unable to read response body for segment nonce=%d manifestID=%s seqNo=%d orch=%s err=%v", nonce, params.ManifestID, seg.SeqNo, ti.Transcoder, err)
 		if monitor.Enabled {
-			monitor.SegmentTranscodeFailed(monitor.SegmentTranscodeErrorReadBody, nonce, seg.SeqNo, err, false)
+			code := monitor.SegmentTranscodeErrorReadBody
+			if ctx.Err() == context.DeadlineExceeded {
+				code = monitor.SegmentTranscodeErrorTimeout
+			}
+			monitor.SegmentTranscodeFailed(code, nonce, seg.SeqNo, err, false)
 		}
 		return nil, fmt.Errorf("body timeout: %w", err)
 	}
@@ -458,6 +484,8 @@ func SubmitSegment(sess *BroadcastSession, seg *stream.HLSSegment, nonce uint64)
 				monitor.SegmentTranscodeFailed(monitor.SegmentTranscodeErrorOrchestratorBusy, nonce, seg.SeqNo, err, false)
 			case "OrchestratorCapped":
 				monitor.SegmentTranscodeFailed(monitor.SegmentTranscodeErrorOrchestratorCapped, nonce, seg.SeqNo, err, false)
+			case core.ErrOrchUnhealthy.Error():
+				monitor.SegmentTranscodeFailed(monitor.SegmentTranscodeErrorOrchestratorUnhealthy, nonce, seg.SeqNo, err, false)
 			default:
 				monitor.SegmentTranscodeFailed(monitor.SegmentTranscodeErrorTranscode, nonce, seg.SeqNo, err, false)
 			}
@@ -490,7 +518,7 @@ func SubmitSegment(sess *BroadcastSession, seg *stream.HLSSegment, nonce uint64)
 
 	// transcode succeeded; continue processing response
 	if monitor.Enabled {
-		monitor.SegmentTranscoded(nonce, seg.SeqNo, transcodeDur, common.ProfilesNames(params.Profiles))
+		monitor.SegmentTranscoded(nonce, seg.SeqNo, transcodeDur, common.ProfilesNames(params.Profiles), "")
 	}
 
 	glog.Infof("Successfully transcoded segment nonce=%d manifestID=%s segName=%s seqNo=%d orch=%s dur=%s", nonce,
@@ -715,3 +743,37 @@ func validatePrice(sess *BroadcastSession) error {
 	}
 	return nil
 }
+
+// ttfbTracer measures time from the request written till the first byte of
+// the response received
+type ttfbTracer struct {
+	mu        sync.Mutex
+	wrote     time.Time
+	firstByte time.Time
+}
+
+func (t *ttfbTracer) trace(ctx context.Context) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			t.mu.Lock()
+			t.wrote = time.Now()
+			t.mu.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			t.mu.Lock()
+			t.firstByte = time.Now()
+			t.mu.Unlock()
+		},
+	})
+}
+
+// ttfb returns false if the request wasn't written or the response wasn't
+// received yet
+func (t *ttfbTracer) ttfb() (time.Duration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.wrote.IsZero() || t.firstByte.IsZero() || t.firstByte.Before(t.wrote) {
+		return 0, false
+	}
+	return t.firstByte.Sub(t.wrote), true
+}
@@ -2,6 +2,7 @@ package server
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/base64"
 	"errors"
@@ -1600,6 +1601,25 @@ func TestSubmitSegment_TranscodeResultError(t *testing.T) {
 	balance.AssertNotCalled(t, "Credit", mock.Anything)
 }
 
+func TestTranscodeTimeout(t *testing.T) {
+	assert := assert.New(t)
+
+	oldFactor, oldTimeout := TranscodeTimeoutFactor, common.HTTPTimeout
+	defer func() { TranscodeTimeoutFactor, common.HTTPTimeout = oldFactor, oldTimeout }()
+	common.HTTPTimeout = 8 * time.Second
+
+	// scales with segment duration
+	TranscodeTimeoutFactor = 4
+	assert.Equal(12*time.Second, transcodeTimeout(3))
+	assert.Equal(40*time.Second, transcodeTimeout(10))
+	TranscodeTimeoutFactor = 1.5
+	assert.Equal(15*time.Second, transcodeTimeout(10))
+
+	// minimum of HTTPTimeout for short or missing durations
+	assert.Equal(8*time.Second, transcodeTimeout(2))
+	assert.Equal(8*time.Second, transcodeTimeout(0))
+}
+
 func TestSubmitSegment_Timeout(t *testing.T) {
 	assert := assert.New(t)
 
@@ -1902,3 +1922,31 @@ func stubTLSServer() (*httptest.Server, *http.ServeMux) {
 
 	return ts, mux
 }
+
+func TestTTFBTracer(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	delay := 50 * time.Millisecond
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+		time.Sleep(delay)
+		w.Write([]byte("transcoded"))
+	}))
+	defer ts.Close()
+
+	tracer := &ttfbTracer{}
+	_, ok := tracer.ttfb()
+	assert.False(ok)
+
+	req, err := http.NewRequestWithContext(tracer.trace(context.Background()), "POST", ts.URL, strings.NewReader("segment"))
+	require.Nil(err)
+	resp, err := http.DefaultClient.Do(req)
+	require.Nil(err)
+	resp.Body.Close()
+
+	ttfb, ok := tracer.ttfb()
+	assert.True(ok)
+	assert.GreaterOrEqual(int64(ttfb), int64(delay))
+	assert.Less(int64(ttfb), int64(time.Second))
+}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"sync"
+
+	"github.com/livepeer/go-livepeer/monitor"
+	"github.com/livepeer/lpms/stream"
+)
+
+type bufferedSegment struct {
+	size      int64
+	inWindow  bool
+	inProcess bool
+}
+
+// segmenterMemory estimates the memory held by the stream's segmenter and its
+// buffers: data of the segments kept in the segmenter's HLS window plus data of
+// the segments still being processed. Each segment is counted once.
+type segmenterMemory struct {
+	nonce   uint64
+	winSize int
+
+	mu     sync.Mutex
+	window []uint64 // seqNos of the segments in the HLS window, oldest first
+	segs   map[uint64]*bufferedSegment
+	total  int64
+	ended  bool
+}
+
+func newSegmenterMemory(nonce uint64, winSize uint) *segmenterMemory {
+	return &segmenterMemory{
+		nonce:   nonce,
+		winSize: int(winSize),
+		segs:    make(map[uint64]*bufferedSegment),
+	}
+}
+
+// add accounts for the segment emitted by the segmenter
+func (m *segmenterMemory) add(seg *stream.HLSSegment) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ended {
+		return
+	}
+	if _, ok := m.segs[seg.SeqNo]; ok {
+		return
+	}
+	m.segs[seg.SeqNo] = &bufferedSegment{size: int64(len(seg.Data)), inWindow: true, inProcess: true}
+	m.total += int64(len(seg.Data))
+	m.window = append(m.window, seg.SeqNo)
+	// segmenter drops the oldest segment when the window is full
+	if len(m.window) > m.winSize {
+		evicted := m.window[0]
+		m.window = m.window[1:]
+		if s, ok := m.segs[evicted]; ok {
+			s.inWindow = false
+			m.release(evicted, s)
+		}
+	}
+	m.record()
+}
+
+// processed marks the segment as no longer being processed
+func (m *segmenterMemory) processed(seqNo uint64) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.segs[seqNo]
+	if m.ended || !ok || !s.inProcess {
+		return
+	}
+	s.inProcess = false
+	m.release(seqNo, s)
+	m.record()
+}
+
+// end resets the estimate once the segmenter exits
+func (m *segmenterMemory) end() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ended = true
+	m.segs = make(map[uint64]*bufferedSegment)
+	m.window = nil
+	m.total = 0
+	m.record()
+}
+
+func (m *segmenterMemory) bytes() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.total
+}
+
+// caller should hold the lock
+func (m *segmenterMemory) release(seqNo uint64, s *bufferedSegment) {
+	if !s.inWindow && !s.inProcess {
+		m.total -= s.size
+		delete(m.segs, seqNo)
+	}
+}
+
+// caller should hold the lock
+func (m *segmenterMemory) record() {
+	if monitor.Enabled {
+		monitor.SegmenterMemory(m.nonce, m.total)
+	}
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/livepeer/lpms/stream"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSegmenterMemory(t *testing.T) {
+	assert := assert.New(t)
+
+	mem := newSegmenterMemory(1, 2)
+	seg := func(seqNo uint64, size int) *stream.HLSSegment {
+		return &stream.HLSSegment{SeqNo: seqNo, Data: make([]byte, size)}
+	}
+
+	// buffer grows with segments emitted by the segmenter
+	mem.add(seg(1, 10))
+	assert.Equal(int64(10), mem.bytes())
+	mem.add(seg(2, 20))
+	assert.Equal(int64(30), mem.bytes())
+	// duplicate segment isn't counted twice
+	mem.add(seg(2, 20))
+	assert.Equal(int64(30), mem.bytes())
+
+	// segment evicted from the window is still held while being processed
+	mem.add(seg(3, 40))
+	assert.Equal(int64(70), mem.bytes())
+	mem.processed(1)
+	assert.Equal(int64(60), mem.bytes())
+	// processed segment in the window is still held
+	mem.processed(3)
+	assert.Equal(int64(60), mem.bytes())
+	mem.processed(3)
+	assert.Equal(int64(60), mem.bytes())
+
+	// eviction of processed segment releases it
+	mem.processed(2)
+	mem.add(seg(4, 5))
+	assert.Equal(int64(45), mem.bytes())
+
+	// unknown segment
+	mem.processed(10)
+	assert.Equal(int64(45), mem.bytes())
+
+	// reset at segmenter end and ignore updates afterwards
+	mem.end()
+	assert.Equal(int64(0), mem.bytes())
+	mem.processed(4)
+	mem.add(seg(5, 10))
+	assert.Equal(int64(0), mem.bytes())
+
+	// nil safe
+	var nilMem *segmenterMemory
+	nilMem.add(seg(1, 10))
+	nilMem.processed(1)
+	nilMem.end()
+}
@@ -3,6 +3,8 @@ package server
 import (
 	"container/heap"
 	"math/rand"
+	"sync"
+	"time"
 
 	ethcommon "github.com/ethereum/go-ethereum/common"
 	"github.com/golang/glog"
@@ -61,6 +63,43 @@ func (h *sessHeap) Peek() interface{} {
 	return (*h)[0]
 }
 
+// SelectionSeed seeds the random selection of orchestrators if non-zero, both
+// the stake weighted selection of sessions and the discovery of orchestrators.
+// Selectors and pools created with the same seed select the same sequence of
+// orchestrators given the same candidates in the same order, which makes
+// selection reproducible for debugging and tests. Zero means the seed is time
+// based.
+var SelectionSeed int64
+
+// NewSelectionRand returns source of randomness for the selection of
+// orchestrators seeded by SelectionSeed, safe for concurrent use
+func NewSelectionRand() *rand.Rand {
+	seed := SelectionSeed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return rand.New(&lockedSource{src: rand.NewSource(seed)})
+}
+
+// lockedSource is rand.Source safe for concurrent use, like the one of the
+// top level functions of math/rand
+type lockedSource struct {
+	mu  sync.Mutex
+	src rand.Source
+}
+
+func (s *lockedSource) Int63() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Int63()
+}
+
+func (s *lockedSource) Seed(seed int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.src.Seed(seed)
+}
+
 type stakeReader interface {
 	Stakes(addrs []ethcommon.Address) (map[ethcommon.Address]int64, error)
 }
@@ -94,6 +133,8 @@ type MinLSSelector struct {
 	stakeRdr stakeReader
 
 	minLS float64
+
+	rand *rand.Rand
 }
 
 // NewMinLSSelector returns an instance of MinLSSelector configured with a good enough latency score
@@ -105,6 +146,7 @@ func NewMinLSSelector(stakeRdr stakeReader, minLS float64) *MinLSSelector {
 		knownSessions: knownSessions,
 		stakeRdr:      stakeRdr,
 		minLS:         minLS,
+		rand:          NewSelectionRand(),
 	}
 }
 
@@ -186,7 +228,7 @@ func (s *MinLSSelector) selectUnknownSession() *BroadcastSession {
 	r := int64(0)
 	// Generate a random stake weight between 1 and totalStake
 	if totalStake > 0 {
-		r = 1 + rand.Int63n(totalStake)
+		r = 1 + s.rand.Int63n(totalStake)
 	}
 
 	// Run a weighted random selection on unknownSessions
@@ -248,3 +290,80 @@ func (s *LIFOSelector) Size() int {
 func (s *LIFOSelector) Clear() {
 	*s = nil
 }
+
+// FallbackChainSelector selects the BroadcastSession along the stream's
+// fallback chain of orchestrators. The chain is ranked in the order the
+// sessions were first added, so the orchestrators returned by discovery at
+// stream start come first, primary being the first one. Orchestrators first
+// seen later are ranked after them. The session of the highest ranked
+// orchestrator available is selected, so the stream moves down the chain as
+// failed sessions are removed and returns to the primary once its session is
+// added back by a refresh.
+// FallbackChainSelector is not concurrency safe so the caller is responsible for ensuring safety for concurrent method calls
+type FallbackChainSelector struct {
+	chain    []string                     // orchestrators, primary first
+	rank     map[string]int               // positions of the orchestrators in the chain
+	sessions map[string]*BroadcastSession // available sessions by orchestrator
+}
+
+// NewFallbackChainSelector returns an instance of FallbackChainSelector with an empty chain
+func NewFallbackChainSelector() *FallbackChainSelector {
+	return &FallbackChainSelector{
+		rank:     make(map[string]int),
+		sessions: make(map[string]*BroadcastSession),
+	}
+}
+
+// Add adds the sessions to the selector, extending the chain with the
+// orchestrators not in it yet
+func (s *FallbackChainSelector) Add(sessions []*BroadcastSession) {
+	for _, sess := range sessions {
+		s.add(sess)
+	}
+}
+
+// Complete adds the session back to the selector
+func (s *FallbackChainSelector) Complete(sess *BroadcastSession) {
+	s.add(sess)
+}
+
+// Select returns the session of the highest ranked orchestrator available
+func (s *FallbackChainSelector) Select() *BroadcastSession {
+	for _, orch := range s.chain {
+		if sess, ok := s.sessions[orch]; ok {
+			delete(s.sessions, orch)
+			return sess
+		}
+	}
+	return nil
+}
+
+// Size returns the number of sessions stored by the selector
+func (s *FallbackChainSelector) Size() int {
+	return len(s.sessions)
+}
+
+// Clear resets the selector's state
+func (s *FallbackChainSelector) Clear() {
+	s.chain = nil
+	s.rank = make(map[string]int)
+	s.sessions = make(map[string]*BroadcastSession)
+}
+
+// Position returns the position of the session's orchestrator in the chain,
+// 0 being the primary, or -1 if the orchestrator isn't in the chain
+func (s *FallbackChainSelector) Position(sess *BroadcastSession) int {
+	if pos, ok := s.rank[sess.OrchestratorInfo.GetTranscoder()]; ok {
+		return pos
+	}
+	return -1
+}
+
+func (s *FallbackChainSelector) add(sess *BroadcastSession) {
+	orch := sess.OrchestratorInfo.GetTranscoder()
+	if _, ok := s.rank[orch]; !ok {
+		s.rank[orch] = len(s.chain)
+		s.chain = append(s.chain, orch)
+	}
+	s.sessions[orch] = sess
+}
@@ -8,6 +8,7 @@ import (
 	"sort"
 	"strconv"
 	"testing"
+	"time"
 
 	ethcommon "github.com/ethereum/go-ethereum/common"
 	"github.com/golang/glog"
@@ -24,6 +25,16 @@ type stubOrchestratorStore struct {
 
 func (s *stubOrchestratorStore) OrchCount(filter *common.DBOrchFilter) (int, error) { return 0, nil }
 func (s *stubOrchestratorStore) UpdateOrch(orch *common.DBOrch) error               { return nil }
+func (s *stubOrchestratorStore) UpdateOrchProbe(ethereumAddr string, success bool) error {
+	return nil
+}
+func (s *stubOrchestratorStore) BlacklistOrch(ethereumAddr string, ttl time.Duration, reason string) error {
+	return nil
+}
+func (s *stubOrchestratorStore) UnblacklistOrch(ethereumAddr string) error { return nil }
+func (s *stubOrchestratorStore) BlacklistedOrchs() ([]*common.DBBlacklistedOrch, error) {
+	return nil, nil
+}
 func (s *stubOrchestratorStore) SelectOrchs(filter *common.DBOrchFilter) ([]*common.DBOrch, error) {
 	if s.err != nil {
 		return nil, s.err
@@ -453,6 +464,46 @@ func TestMinLSSelector_SelectUnknownSession_NilStakeReader(t *testing.T) {
 	}
 }
 
+func TestMinLSSelector_SelectUnknownSession_Seed(t *testing.T) {
+	assert := assert.New(t)
+
+	defer func(seed int64) { SelectionSeed = seed }(SelectionSeed)
+
+	stakeRdr := newStubStakeReader()
+	stakeMap := make(map[ethcommon.Address]int64)
+	var sessions []*BroadcastSession
+	for i := 0; i < 10; i++ {
+		addr := ethcommon.BytesToAddress([]byte(strconv.Itoa(i)))
+		stakeMap[addr] = int64(1000 * (i + 1))
+		sessions = append(sessions, &BroadcastSession{
+			OrchestratorInfo: &net.OrchestratorInfo{
+				TicketParams: &net.TicketParams{Recipient: addr.Bytes()},
+			},
+		})
+	}
+	stakeRdr.SetStakes(stakeMap)
+
+	selectAll := func() []*BroadcastSession {
+		sel := NewMinLSSelector(stakeRdr, 1.0)
+		sel.Add(sessions)
+		var selected []*BroadcastSession
+		for sel.Size() > 0 {
+			selected = append(selected, sel.Select())
+		}
+		return selected
+	}
+
+	// same seed yields same selection
+	SelectionSeed = 42
+	first := selectAll()
+	assert.Len(first, len(sessions))
+	assert.Equal(first, selectAll())
+
+	// different seed yields different selection
+	SelectionSeed = 43
+	assert.NotEqual(first, selectAll())
+}
+
 func TestMinLSSelector_RemoveUnknownSession(t *testing.T) {
 	assert := assert.New(t)
 
@@ -497,3 +548,56 @@ func TestMinLSSelector_RemoveUnknownSession(t *testing.T) {
 	sel.removeUnknownSession(0)
 	assert.Empty(sel.unknownSessions)
 }
+
+func TestFallbackChainSelector(t *testing.T) {
+	assert := assert.New(t)
+
+	sel := NewFallbackChainSelector()
+	assert.Nil(sel.Select())
+
+	sessions := []*BroadcastSession{
+		StubBroadcastSession("primary"),
+		StubBroadcastSession("standby1"),
+		StubBroadcastSession("standby2"),
+	}
+	sel.Add(sessions)
+	assert.Equal(3, sel.Size())
+	for i, sess := range sessions {
+		assert.Equal(i, sel.Position(sess))
+	}
+
+	// primary is selected again once completed
+	sess := sel.Select()
+	assert.Same(sessions[0], sess)
+	assert.Equal(2, sel.Size())
+	sel.Complete(sess)
+	assert.Same(sessions[0], sel.Select())
+
+	// next in the chain is selected while the primary isn't completed
+	sess = sel.Select()
+	assert.Same(sessions[1], sess)
+	assert.Same(sessions[2], sel.Select())
+	assert.Nil(sel.Select())
+	assert.Equal(0, sel.Size())
+
+	// primary added back with a new session keeps its position
+	newPrimary := StubBroadcastSession("primary")
+	sel.Add([]*BroadcastSession{sessions[2], newPrimary})
+	assert.Same(newPrimary, sel.Select())
+	assert.Equal(0, sel.Position(newPrimary))
+
+	// orchestrator first seen later is ranked after the initial ones
+	late := StubBroadcastSession("late")
+	sel.Add([]*BroadcastSession{late})
+	assert.Equal(3, sel.Position(late))
+	assert.Same(sessions[2], sel.Select())
+	assert.Same(late, sel.Select())
+
+	assert.Equal(-1, sel.Position(StubBroadcastSession("unknown")))
+
+	sel.Add(sessions)
+	sel.Clear()
+	assert.Equal(0, sel.Size())
+	assert.Nil(sel.Select())
+	assert.Equal(-1, sel.Position(sessions[0]))
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/livepeer/go-livepeer/monitor"
+)
+
+// WarmupSegments is the number of segments a new stream should buffer before
+// it is served to viewers. Zero disables the warmup.
+var WarmupSegments int
+
+// WarmupTimeout limits how long a new stream buffers before it is served to
+// viewers regardless of the number of buffered segments
+var WarmupTimeout = 10 * time.Second
+
+// streamWarmup holds off viewers of a new stream until the stream buffers the
+// target number of processed segments or the timeout expires, whichever comes
+// first. The stream is considered started once the warmup completes.
+type streamWarmup struct {
+	nonce   uint64
+	target  int
+	started time.Time
+
+	mu       sync.Mutex
+	buffered int
+	ready    bool
+	timer    *time.Timer
+}
+
+// newStreamWarmup returns nil if the warmup is disabled
+func newStreamWarmup(nonce uint64, target int, timeout time.Duration) *streamWarmup {
+	if target <= 0 {
+		return nil
+	}
+	w := &streamWarmup{
+		nonce:   nonce,
+		target:  target,
+		started: time.Now(),
+	}
+	// the timer may fire before it's assigned
+	w.mu.Lock()
+	w.timer = time.AfterFunc(timeout, w.timedOut)
+	w.mu.Unlock()
+	return w
+}
+
+// segmentBuffered accounts for the processed segment of the stream
+func (w *streamWarmup) segmentBuffered() {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buffered++
+	if w.buffered >= w.target {
+		w.markReady()
+	}
+}
+
+func (w *streamWarmup) timedOut() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.ready {
+		glog.Warningf("Stream warmup timed out nonce=%d buffered=%d target=%d", w.nonce, w.buffered, w.target)
+	}
+	w.markReady()
+}
+
+// isReady checks if the stream can be served to viewers
+func (w *streamWarmup) isReady() bool {
+	if w == nil {
+		return true
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.ready
+}
+
+// stop releases the timer once the stream ends
+func (w *streamWarmup) stop() {
+	if w == nil {
+		return
+	}
+	w.timer.Stop()
+}
+
+// caller should hold the lock
+func (w *streamWarmup) markReady() {
+	if w.ready {
+		return
+	}
+	w.ready = true
+	w.timer.Stop()
+	dur := time.Since(w.started)
+	glog.Infof("Stream warmed up nonce=%d buffered=%d dur=%v", w.nonce, w.buffered, dur)
+	if monitor.Enabled {
+		monitor.StreamStarted(w.nonce)
+		monitor.StreamWarmup(w.nonce, dur)
+	}
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamWarmup(t *testing.T) {
+	assert := assert.New(t)
+
+	// disabled warmup is always ready
+	w := newStreamWarmup(1, 0, time.Hour)
+	assert.Nil(w)
+	assert.True(w.isReady())
+	w.segmentBuffered()
+	w.stop()
+
+	// ready once the target number of segments is buffered
+	w = newStreamWarmup(1, 2, time.Hour)
+	defer w.stop()
+	assert.False(w.isReady())
+	w.segmentBuffered()
+	assert.False(w.isReady())
+	w.segmentBuffered()
+	assert.True(w.isReady())
+	w.segmentBuffered()
+	assert.True(w.isReady())
+
+	// ready once the timeout expires
+	w2 := newStreamWarmup(2, 5, 10*time.Millisecond)
+	defer w2.stop()
+	w2.segmentBuffered()
+	assert.False(w2.isReady())
+	assert.Eventually(w2.isReady, time.Second, 5*time.Millisecond)
+}
@@ -2,20 +2,25 @@ package server
 
 import (
 	"sync"
+	"time"
+
+	"github.com/livepeer/go-livepeer/monitor"
 )
 
 // suspender is a list that keep track of suspender orchestrators
 // and the count until which they are suspended
 type suspender struct {
 	mu    sync.Mutex
-	list  map[string]int // list of orchestrator => refresh count at which the orchestrator is no longer suspended
+	list  map[string]int       // list of orchestrator => refresh count at which the orchestrator is no longer suspended
+	since map[string]time.Time // list of orchestrator => time it was suspended at
 	count int
 }
 
 // newSuspender returns the pointer to a new Suspender instance
 func newSuspender() *suspender {
 	return &suspender{
-		list: make(map[string]int),
+		list:  make(map[string]int),
+		since: make(map[string]time.Time),
 	}
 }
 
@@ -23,6 +28,12 @@ func newSuspender() *suspender {
 func (s *suspender) suspend(orch string, penalty int) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	if _, ok := s.list[orch]; !ok {
+		s.since[orch] = time.Now()
+		if monitor.Enabled {
+			monitor.OrchestratorCooldownStarted(orch)
+		}
+	}
 	s.list[orch] += penalty
 }
 
@@ -33,14 +44,41 @@ func (s *suspender) Suspended(orch string) int {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	if s.list[orch] < s.count {
-		delete(s.list, orch)
+		s.unsuspend(orch)
 	}
 	return s.list[orch]
 }
 
-// signalRefresh increases Suspender.count
+// signalRefresh increases Suspender.count, lifting the suspensions that ended
 func (s *suspender) signalRefresh() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.count++
+	for orch, count := range s.list {
+		if count < s.count {
+			s.unsuspend(orch)
+		}
+	}
+}
+
+// clear lifts all the suspensions, once the stream ended
+func (s *suspender) clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for orch := range s.list {
+		s.unsuspend(orch)
+	}
+}
+
+// unsuspend should be called with the lock held
+func (s *suspender) unsuspend(orch string) {
+	delete(s.list, orch)
+	since, ok := s.since[orch]
+	if !ok {
+		return
+	}
+	delete(s.since, orch)
+	if monitor.Enabled {
+		monitor.OrchestratorCooldownEnded(orch, time.Since(since))
+	}
 }
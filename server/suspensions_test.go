@@ -2,6 +2,7 @@ package server
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -22,3 +23,32 @@ func TestSuspender(t *testing.T) {
 	s.signalRefresh()
 	assert.Equal(s.count, 12)
 }
+
+func TestSuspender_Cooldown(t *testing.T) {
+	assert := assert.New(t)
+	s := newSuspender()
+
+	// suspension start isn't moved by further penalties
+	s.suspend("foo", 1)
+	since := s.since["foo"]
+	assert.False(since.IsZero())
+	time.Sleep(time.Millisecond)
+	s.suspend("foo", 1)
+	assert.Equal(since, s.since["foo"])
+	s.suspend("bar", 3)
+
+	// ended suspensions are lifted on refresh
+	s.signalRefresh()
+	s.signalRefresh()
+	s.signalRefresh()
+	assert.NotContains(s.list, "foo")
+	assert.NotContains(s.since, "foo")
+	assert.Contains(s.since, "bar")
+	assert.Equal(3, s.Suspended("bar"))
+
+	// and all of them once the stream ends
+	s.clear()
+	assert.Empty(s.list)
+	assert.Empty(s.since)
+	assert.Equal(0, s.Suspended("bar"))
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/livepeer/go-livepeer/core"
+	"github.com/livepeer/go-livepeer/monitor"
+)
+
+var errTenantStreamLimit = errors.New("TenantStreamLimit")
+var errTenantRateLimit = errors.New("TenantRateLimit")
+
+// TenantSeparator splits the tenant from the rest of the manifest ID of the
+// streams that don't get the tenant from the auth webhook. Streams aren't
+// attributed to a tenant by their manifest ID if empty.
+var TenantSeparator string
+
+// TenantLimits limits the streams created by each tenant. Disabled if nil
+var TenantLimits *TenantLimiter
+
+// TenantLimiter limits the number of streams each tenant has at once and the
+// number of streams each tenant creates within the window
+type TenantLimiter struct {
+	maxStreams    int // unlimited if 0
+	maxNewStreams int // unlimited if 0
+	window        time.Duration
+
+	mu      sync.Mutex
+	active  map[string]int
+	created map[string][]time.Time // creation times within the window, oldest first
+}
+
+// NewTenantLimiter returns limiter allowing each tenant maxStreams streams at
+// once and maxNewStreams streams created within the window. Zero disables the
+// respective limit.
+func NewTenantLimiter(maxStreams, maxNewStreams int, window time.Duration) *TenantLimiter {
+	return &TenantLimiter{
+		maxStreams:    maxStreams,
+		maxNewStreams: maxNewStreams,
+		window:        window,
+		active:        make(map[string]int),
+		created:       make(map[string][]time.Time),
+	}
+}
+
+// streamTenant returns the tenant of the stream, either set by the auth
+// webhook or the prefix of the manifest ID before TenantSeparator
+func streamTenant(tenant string, mid core.ManifestID) string {
+	if tenant != "" || TenantSeparator == "" {
+		return tenant
+	}
+	if i := strings.Index(string(mid), TenantSeparator); i > 0 {
+		return string(mid)[:i]
+	}
+	return ""
+}
+
+// acquire counts new stream of the tenant, returns error if the tenant is over
+// the limit. Streams without tenant aren't limited.
+func (l *TenantLimiter) acquire(tenant string) error {
+	if l == nil || tenant == "" {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	created := l.created[tenant]
+	for len(created) > 0 && now.Sub(created[0]) >= l.window {
+		created = created[1:]
+	}
+
+	var err error
+	var reason string
+	if l.maxStreams > 0 && l.active[tenant] >= l.maxStreams {
+		err, reason = errTenantStreamLimit, monitor.TenantLimitConcurrent
+	} else if l.maxNewStreams > 0 && len(created) >= l.maxNewStreams {
+		err, reason = errTenantRateLimit, monitor.TenantLimitRate
+	}
+	if err != nil {
+		l.setCreated(tenant, created)
+		glog.Errorf("Rejecting stream of tenant=%s over the limit: %v", tenant, err)
+		if monitor.Enabled {
+			monitor.StreamRejectedTenantLimit(tenant, reason)
+		}
+		return err
+	}
+
+	l.active[tenant]++
+	if l.maxNewStreams > 0 {
+		created = append(created, now)
+	}
+	l.setCreated(tenant, created)
+	return nil
+}
+
+// release uncounts ended stream of the tenant
+func (l *TenantLimiter) release(tenant string) {
+	if l == nil || tenant == "" {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.active[tenant] <= 1 {
+		delete(l.active, tenant)
+		return
+	}
+	l.active[tenant]--
+}
+
+// setCreated should be called with the lock held
+func (l *TenantLimiter) setCreated(tenant string, created []time.Time) {
+	if len(created) == 0 {
+		delete(l.created, tenant)
+		return
+	}
+	l.created[tenant] = created
+}
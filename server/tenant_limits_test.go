@@ -0,0 +1,78 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/livepeer/go-livepeer/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamTenant(t *testing.T) {
+	assert := assert.New(t)
+	defer func(sep string) { TenantSeparator = sep }(TenantSeparator)
+
+	TenantSeparator = ""
+	assert.Equal("", streamTenant("", core.ManifestID("acme-abc")))
+	assert.Equal("claim", streamTenant("claim", core.ManifestID("acme-abc")))
+
+	TenantSeparator = "-"
+	assert.Equal("acme", streamTenant("", core.ManifestID("acme-abc-def")))
+	assert.Equal("claim", streamTenant("claim", core.ManifestID("acme-abc")))
+	assert.Equal("", streamTenant("", core.ManifestID("abc")))
+	assert.Equal("", streamTenant("", core.ManifestID("-abc")))
+}
+
+func TestTenantLimiter_Concurrent(t *testing.T) {
+	assert := assert.New(t)
+	l := NewTenantLimiter(2, 0, time.Minute)
+
+	assert.Nil(l.acquire("a"))
+	assert.Nil(l.acquire("a"))
+	assert.Equal(errTenantStreamLimit, l.acquire("a"))
+	// other tenants and streams without tenant aren't affected
+	assert.Nil(l.acquire("b"))
+	assert.Nil(l.acquire(""))
+	assert.Nil(l.acquire(""))
+	assert.Nil(l.acquire(""))
+
+	l.release("a")
+	assert.Nil(l.acquire("a"))
+	assert.Equal(errTenantStreamLimit, l.acquire("a"))
+
+	l.release("a")
+	l.release("a")
+	l.release("b")
+	l.release("")
+	assert.Empty(l.active)
+	assert.Empty(l.created)
+
+	// nil limiter doesn't limit
+	var nilLimiter *TenantLimiter
+	assert.Nil(nilLimiter.acquire("a"))
+	nilLimiter.release("a")
+}
+
+func TestTenantLimiter_Rate(t *testing.T) {
+	assert := assert.New(t)
+	l := NewTenantLimiter(0, 2, time.Minute)
+
+	assert.Nil(l.acquire("a"))
+	assert.Nil(l.acquire("a"))
+	// ended streams still count within the window
+	l.release("a")
+	l.release("a")
+	assert.Equal(errTenantRateLimit, l.acquire("a"))
+	assert.Nil(l.acquire("b"))
+
+	// streams created before the window don't count
+	l.created["a"][0] = time.Now().Add(-time.Minute)
+	assert.Nil(l.acquire("a"))
+	assert.Len(l.created["a"], 2)
+	assert.Equal(errTenantRateLimit, l.acquire("a"))
+
+	// concurrency limit is reported first when both are hit
+	l = NewTenantLimiter(1, 1, time.Minute)
+	assert.Nil(l.acquire("a"))
+	assert.Equal(errTenantStreamLimit, l.acquire("a"))
+}
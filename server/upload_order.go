@@ -0,0 +1,114 @@
+package server
+
+import (
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/livepeer/go-livepeer/monitor"
+)
+
+// InOrderUploads is the default upload ordering mode for the streams that
+// don't set it through the auth webhook
+var InOrderUploads bool
+
+// uploadOrder tracks source segment uploads of a stream. In the in-order mode
+// upload of a segment waits till uploads of all the segments with lower seqNo
+// queued before it are completed. Otherwise uploads may complete in any order,
+// and completions out of seqNo order are recorded as ordering violations.
+type uploadOrder struct {
+	nonce   uint64
+	inOrder bool
+
+	mu         sync.Mutex
+	cond       *sync.Cond
+	pending    map[uint64]bool
+	uploaded   bool   // whether any segment was uploaded yet
+	highest    uint64 // highest seqNo uploaded so far
+	violations int
+}
+
+func newUploadOrder(nonce uint64, inOrder bool) *uploadOrder {
+	o := &uploadOrder{
+		nonce:   nonce,
+		inOrder: inOrder,
+		pending: make(map[uint64]bool),
+	}
+	o.cond = sync.NewCond(&o.mu)
+	return o
+}
+
+// queue registers the segment for upload. Should be called in the order the
+// segments are produced, before the segments are processed concurrently.
+func (o *uploadOrder) queue(seqNo uint64) {
+	if o == nil {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.pending[seqNo] = true
+	o.recordDepth()
+}
+
+// wait blocks till the segment can be uploaded
+func (o *uploadOrder) wait(seqNo uint64) {
+	if o == nil || !o.inOrder {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for o.pendingBefore(seqNo) {
+		o.cond.Wait()
+	}
+}
+
+func (o *uploadOrder) pendingBefore(seqNo uint64) bool {
+	for s := range o.pending {
+		if s < seqNo {
+			return true
+		}
+	}
+	return false
+}
+
+// done marks upload of the queued segment as completed, successfully or not.
+// Calling it more than once for the same segment is a no-op.
+func (o *uploadOrder) done(seqNo uint64, uploaded bool) {
+	if o == nil {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if !o.pending[seqNo] {
+		return
+	}
+	delete(o.pending, seqNo)
+	o.cond.Broadcast()
+	o.recordDepth()
+	if !uploaded {
+		return
+	}
+	if o.uploaded && seqNo < o.highest {
+		o.violations++
+		glog.Warningf("Segment uploaded out of order nonce=%d seqNo=%d highestSeqNo=%d", o.nonce, seqNo, o.highest)
+		if monitor.Enabled {
+			monitor.SegmentUploadOutOfOrder()
+		}
+		return
+	}
+	o.uploaded = true
+	o.highest = seqNo
+}
+
+// depth returns the number of segments queued for upload and not uploaded yet
+func (o *uploadOrder) depth() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.pending)
+}
+
+// caller should hold the lock
+func (o *uploadOrder) recordDepth() {
+	if monitor.Enabled {
+		monitor.UploadQueueDepth(o.nonce, len(o.pending))
+	}
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUploadOrder_InOrder(t *testing.T) {
+	assert := assert.New(t)
+	o := newUploadOrder(1, true)
+	for i := uint64(1); i <= 3; i++ {
+		o.queue(i)
+	}
+
+	var mu sync.Mutex
+	var uploaded []uint64
+	var wg sync.WaitGroup
+	upload := func(seqNo uint64) {
+		defer wg.Done()
+		o.wait(seqNo)
+		mu.Lock()
+		uploaded = append(uploaded, seqNo)
+		mu.Unlock()
+		o.done(seqNo, true)
+	}
+	wg.Add(2)
+	go upload(3)
+	go upload(2)
+	time.Sleep(20 * time.Millisecond)
+	mu.Lock()
+	assert.Empty(uploaded, "uploads should wait for the first segment")
+	mu.Unlock()
+
+	// failed upload releases the waiting segments too
+	o.done(1, false)
+	wg.Wait()
+	assert.Equal([]uint64{2, 3}, uploaded)
+	assert.Equal(0, o.violations)
+
+	// segment with no lower seqNo pending doesn't wait
+	o.queue(5)
+	o.queue(4)
+	done := make(chan struct{})
+	go func() {
+		o.wait(4)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("upload should not wait")
+	}
+}
+
+func TestUploadOrder_OutOfOrder(t *testing.T) {
+	assert := assert.New(t)
+	o := newUploadOrder(1, false)
+	for i := uint64(1); i <= 4; i++ {
+		o.queue(i)
+	}
+
+	// uploads don't wait for each other
+	done := make(chan struct{})
+	go func() {
+		o.wait(4)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("upload should not wait")
+	}
+
+	o.done(2, true)
+	o.done(4, true)
+	assert.Equal(0, o.violations)
+	o.done(3, true)
+	assert.Equal(1, o.violations)
+	// failed uploads aren't violations
+	o.done(1, false)
+	assert.Equal(1, o.violations)
+	// done is no-op for already completed segments
+	o.done(3, true)
+	assert.Equal(1, o.violations)
+	assert.Equal(uint64(4), o.highest)
+}
+
+func TestUploadOrder_Nil(t *testing.T) {
+	var o *uploadOrder
+	// nil order doesn't block nor panic
+	o.queue(1)
+	o.wait(2)
+	o.done(1, true)
+}
+
+func TestUploadOrder_Depth(t *testing.T) {
+	assert := assert.New(t)
+	o := newUploadOrder(1, true)
+	assert.Equal(0, o.depth())
+
+	// queue fills while uploads wait
+	for i := uint64(1); i <= 3; i++ {
+		o.queue(i)
+		assert.Equal(int(i), o.depth())
+	}
+
+	// and drains as uploads complete, successfully or not
+	o.done(1, true)
+	assert.Equal(2, o.depth())
+	o.done(2, false)
+	assert.Equal(1, o.depth())
+	o.done(2, true)
+	assert.Equal(1, o.depth())
+	o.done(3, true)
+	assert.Equal(0, o.depth())
+}
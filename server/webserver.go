@@ -1165,6 +1165,14 @@ func (s *LivepeerServer) cliWebServerHandlers(bindAddr string) *http.ServeMux {
 
 	mux.Handle("/currentBlock", currentBlockHandler(s.LivepeerNode.Database))
 
+	mux.Handle("/setDrainMode", mustHaveFormParams(setDrainModeHandler(s.LivepeerNode), "drain"))
+
+	// Orchestrator blacklist
+
+	mux.Handle("/blacklistOrchestrator", mustHaveFormParams(blacklistOrchestratorHandler(s.LivepeerNode.Database), "address", "ttl"))
+	mux.Handle("/unblacklistOrchestrator", mustHaveFormParams(unblacklistOrchestratorHandler(s.LivepeerNode.Database), "address"))
+	mux.Handle("/orchestratorBlacklist", orchestratorBlacklistHandler(s.LivepeerNode.Database))
+
 	// TicketBroker
 
 	mux.Handle("/fundDepositAndReserve", mustHaveFormParams(fundDepositAndReserveHandler(s.LivepeerNode.Eth), "depositAmount", "reserveAmount"))
@@ -1178,7 +1186,8 @@ func (s *LivepeerServer) cliWebServerHandlers(bindAddr string) *http.ServeMux {
 	// Metrics
 	if monitor.Enabled {
 		mux.Handle("/metrics", monitor.Exporter)
-
+		// for load testing only
+		mux.Handle("/resetMetrics", resetMetricsHandler())
 	}
 	return mux
 }
@@ -144,8 +144,8 @@ func TestEpic_WriteSegments(t *testing.T) {
 	// TODO Trigger an error writing to disk, for both source and renditions
 
 	// Set an external bucket
-	drivers.S3BUCKET = "livepeer"
-	defer func() { drivers.S3BUCKET = "" }()
+	drivers.NewS3Driver(drivers.S3Options{Bucket: "livepeer"})
+	defer drivers.ResetOwnS3Buckets()
 	srcPath, rPaths, err = writeSegments(p, dir)
 	assert.Nil(err)
 	assert.Equal(p.Source.Name, srcPath)
@@ -164,8 +164,8 @@ func TestEpic_Verify(t *testing.T) {
 	assert := assert.New(t)
 
 	// Use external S3 bucket
-	drivers.S3BUCKET = "livepeer"
-	defer func() { drivers.S3BUCKET = "" }()
+	drivers.NewS3Driver(drivers.S3Options{Bucket: "livepeer"})
+	defer drivers.ResetOwnS3Buckets()
 
 	ts, mux := stubVerificationServer()
 	defer ts.Close()
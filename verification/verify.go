@@ -210,6 +210,45 @@ func (sv *SegmentVerifier) sigVerification(params *Params) error {
 	return nil
 }
 
+// OutputsMatch compares the renditions of the same segment transcoded by two
+// orchestrators. Encoders aren't bit-exact, so the renditions are decoded
+// instead of hashed. Each pair of renditions matches if the decoded frame and
+// pixel counts are equal and the encoded sizes differ by at most maxDistance,
+// relative to the larger one.
+func OutputsMatch(a, b [][]byte, maxDistance float64) (bool, error) {
+	if len(a) != len(b) {
+		return false, nil
+	}
+	for i := range a {
+		infoA, err := decode(a[i])
+		if err != nil {
+			return false, err
+		}
+		infoB, err := decode(b[i])
+		if err != nil {
+			return false, err
+		}
+		if infoA.Frames != infoB.Frames || infoA.Pixels != infoB.Pixels {
+			return false, nil
+		}
+		if sizeDistance(len(a[i]), len(b[i])) > maxDistance {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// sizeDistance returns the difference of the sizes relative to the larger one
+func sizeDistance(a, b int) float64 {
+	if a < b {
+		a, b = b, a
+	}
+	if a == 0 {
+		return 0
+	}
+	return float64(a-b) / float64(a)
+}
+
 func countPixelParams(params *Params) ([]int64, error) {
 
 	if len(params.Results.Segments) != len(params.Renditions) {
@@ -229,36 +268,48 @@ func countPixelParams(params *Params) ([]int64, error) {
 }
 
 func countPixels(data []byte) (int64, error) {
+	info, err := decode(data)
+	if err != nil {
+		return 0, err
+	}
+	return info.Pixels, nil
+}
+
+func decode(data []byte) (*ffmpeg.MediaInfo, error) {
 	// write the data to a temp file
 	tempfile, err := ioutil.TempFile("", common.RandName())
 	if err != nil {
-		return 0, fmt.Errorf("error creating temp file for pixels verification: %w", err)
+		return nil, fmt.Errorf("error creating temp file for pixels verification: %w", err)
 	}
 	defer os.Remove(tempfile.Name())
 
 	if _, err := tempfile.Write(data); err != nil {
 		tempfile.Close()
-		return 0, fmt.Errorf("error writing temp file for pixels verification: %w", err)
+		return nil, fmt.Errorf("error writing temp file for pixels verification: %w", err)
 	}
 
 	if err = tempfile.Close(); err != nil {
-		return 0, fmt.Errorf("error closing temp file for pixels verification: %w", err)
+		return nil, fmt.Errorf("error closing temp file for pixels verification: %w", err)
 	}
 
-	p, err := pixels(tempfile.Name())
+	return mediaInfo(tempfile.Name())
+}
+
+func pixels(fname string) (int64, error) {
+	info, err := mediaInfo(fname)
 	if err != nil {
 		return 0, err
 	}
 
-	return p, nil
+	return info.Pixels, nil
 }
 
-func pixels(fname string) (int64, error) {
+func mediaInfo(fname string) (*ffmpeg.MediaInfo, error) {
 	in := &ffmpeg.TranscodeOptionsIn{Fname: fname}
 	res, err := ffmpeg.Transcode3(in, nil)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
-	return res.Decoded.Pixels, nil
+	return &res.Decoded, nil
 }
@@ -293,6 +293,48 @@ func TestVerify(t *testing.T) {
 	assert.Nil(res)
 }
 
+func TestOutputsMatch(t *testing.T) {
+	ffmpeg.InitFFmpeg()
+
+	assert := assert.New(t)
+	require := require.New(t)
+
+	data, err := ioutil.ReadFile("../server/test.flv")
+	require.Nil(err)
+	a := [][]byte{data, data}
+
+	ok, err := OutputsMatch(a, [][]byte{data, data}, 0)
+	assert.Nil(err)
+	assert.True(ok)
+	ok, err = OutputsMatch(nil, nil, 0)
+	assert.Nil(err)
+	assert.True(ok)
+
+	// renditions missing
+	ok, err = OutputsMatch(a, a[:1], 1)
+	assert.Nil(err)
+	assert.False(ok)
+
+	// frames missing
+	cut := data[:len(data)/2]
+	ok, err = OutputsMatch(a, [][]byte{data, cut}, 1)
+	assert.Nil(err)
+	assert.False(ok)
+
+	// rendition that can't be decoded
+	_, err = OutputsMatch(a, [][]byte{data, []byte("foo")}, 1)
+	assert.NotNil(err)
+}
+
+func TestSizeDistance(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(0.0, sizeDistance(0, 0))
+	assert.Equal(0.0, sizeDistance(100, 100))
+	assert.Equal(0.1, sizeDistance(100, 90))
+	assert.Equal(0.1, sizeDistance(90, 100))
+	assert.Equal(1.0, sizeDistance(0, 100))
+}
+
 func TestPixels(t *testing.T) {
 	ffmpeg.InitFFmpeg()
 
@@ -333,7 +375,7 @@ func TestVerifyPixels(t *testing.T) {
 	bos := drivers.NewMemoryDriver(nil).NewSession("foo")
 	data, err := ioutil.ReadFile("../server/test.flv")
 	require.Nil(err)
-	fname, err := bos.SaveData("test.ts", data)
+	fname, err := bos.SaveData("test.ts", data, nil)
 	require.Nil(err)
 	memOS, ok := bos.(*drivers.MemorySession)
 	require.True(ok)